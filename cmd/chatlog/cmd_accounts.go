@@ -0,0 +1,137 @@
+package chatlog
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/aspnmy/chatlog/internal/chatlog"
+	"github.com/aspnmy/chatlog/internal/chatlog/conf"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(accountsCmd)
+	accountsCmd.AddCommand(accountsUseCmd)
+	accountsCmd.AddCommand(accountsRemoveCmd)
+
+	accountsCmd.AddCommand(accountsAddCmd)
+	accountsAddCmd.Flags().StringVarP(&accountsAdd.DataDir, "data-dir", "d", "", "wechat data dir of the account")
+	accountsAddCmd.Flags().StringVarP(&accountsAdd.DataKey, "key", "k", "", "data key of the account")
+	accountsAddCmd.Flags().StringVar(&accountsAdd.ImgKey, "img-key", "", "image key of the account, wechat 4.x only")
+	accountsAddCmd.Flags().StringVarP(&accountsAdd.Platform, "platform", "p", "", "platform, default to the current platform")
+	accountsAddCmd.Flags().IntVarP(&accountsAdd.Version, "version", "v", 0, "wechat major version, default to 4")
+	accountsAddCmd.Flags().StringVarP(&accountsAdd.WorkDir, "work-dir", "w", "", "work dir, default to a dir named after the account")
+	accountsAddCmd.MarkFlagRequired("data-dir")
+	accountsAddCmd.MarkFlagRequired("key")
+}
+
+var accountsAdd conf.ProcessConfig
+
+var accountsCmd = &cobra.Command{
+	Use:   "accounts",
+	Short: "List the wechat accounts saved in the config file",
+	Long: `List the wechat accounts saved in the config file. Every command uses the last account by
+default; pass --account to use another one for a single run, or "chatlog accounts use" to change
+the default.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		m, err := chatlog.New("")
+		if err != nil {
+			log.Err(err).Msg("failed to create chatlog instance")
+			return
+		}
+		accounts := m.Accounts()
+		if len(accounts) == 0 {
+			fmt.Println(`no saved accounts, run "chatlog auto" with wechat running or "chatlog accounts add"`)
+			return
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "\tACCOUNT\tVERSION\tKEY\tDATA DIR\tWORK DIR")
+		for _, a := range accounts {
+			current := ""
+			if a.Account == m.CurrentAccount() {
+				current = "*"
+			}
+			key := "-"
+			if a.DataKey != "" {
+				key = "saved"
+				if a.KeyTime > 0 {
+					key = time.Unix(a.KeyTime, 0).Format("2006-01-02")
+				}
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s %s\t%s\t%s\t%s\n", current, a.Account, a.Platform, accountVersion(a), key, a.DataDir, a.WorkDir)
+		}
+		w.Flush()
+	},
+}
+
+// accountVersion 返回账号的完整版本号，未记录时返回主版本号
+func accountVersion(a conf.ProcessConfig) string {
+	if a.FullVersion != "" {
+		return a.FullVersion
+	}
+	return fmt.Sprint(a.Version)
+}
+
+var accountsUseCmd = &cobra.Command{
+	Use:   "use <account>",
+	Short: "Set the account used by default",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		m, err := chatlog.New("")
+		if err != nil {
+			log.Err(err).Msg("failed to create chatlog instance")
+			return
+		}
+		if err := m.CommandUseAccount(args[0]); err != nil {
+			log.Err(err).Msg("failed to switch account")
+			return
+		}
+		fmt.Printf("%s is now the default account\n", args[0])
+	},
+}
+
+var accountsAddCmd = &cobra.Command{
+	Use:   "add <account>",
+	Short: "Register an account with its data dir and key",
+	Long: `Register an account whose wechat is not running on this machine, such as a data dir copied
+from another computer with a key extracted there. The key is validated against the data dir before
+the account is saved; an existing account is updated.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		m, err := chatlog.New("")
+		if err != nil {
+			log.Err(err).Msg("failed to create chatlog instance")
+			return
+		}
+		accountsAdd.Account = args[0]
+		a, err := m.CommandAddAccount(accountsAdd)
+		if err != nil {
+			log.Err(err).Msg("failed to add account")
+			return
+		}
+		fmt.Printf("account %s saved, work dir %s\n", a.Account, a.WorkDir)
+		fmt.Printf("run \"chatlog --account %s auto\" to decrypt and serve it\n", a.Account)
+	},
+}
+
+var accountsRemoveCmd = &cobra.Command{
+	Use:   "remove <account>",
+	Short: "Remove an account from the config file, the decrypted data in its work dir is kept",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		m, err := chatlog.New("")
+		if err != nil {
+			log.Err(err).Msg("failed to create chatlog instance")
+			return
+		}
+		if err := m.CommandRemoveAccount(args[0]); err != nil {
+			log.Err(err).Msg("failed to remove account")
+			return
+		}
+		fmt.Printf("account %s removed\n", args[0])
+	},
+}
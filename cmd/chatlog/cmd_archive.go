@@ -0,0 +1,40 @@
+package chatlog
+
+import (
+	"fmt"
+
+	"github.com/aspnmy/chatlog/internal/chatlog"
+	"github.com/aspnmy/chatlog/pkg/util"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(archiveCmd)
+	archiveCmd.Flags().StringVarP(&archiveDataDir, "data-dir", "d", "", "wechat data dir, empty for the current account")
+	archiveCmd.Flags().StringVarP(&archiveWorkDir, "work-dir", "w", "", "work dir, empty for the current account")
+}
+
+var (
+	archiveDataDir string
+	archiveWorkDir string
+)
+
+var archiveCmd = &cobra.Command{
+	Use:   "archive",
+	Short: "Copy images, videos and files into the work dir, so they stay available with --archive-only after wechat is uninstalled",
+	Run: func(cmd *cobra.Command, args []string) {
+		m, err := chatlog.New("")
+		if err != nil {
+			log.Err(err).Msg("failed to create chatlog instance")
+			return
+		}
+		result, err := m.CommandArchiveMedia(archiveDataDir, archiveWorkDir)
+		if err != nil {
+			log.Err(err).Msg("failed to archive media")
+			return
+		}
+		fmt.Printf("copied %d files (%s), %d up to date, to %s\n", result.Copied, util.ByteCountSI(result.Bytes), result.Skipped, result.Dir)
+	},
+}
@@ -0,0 +1,69 @@
+package chatlog
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/aspnmy/chatlog/internal/chatlog"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(autoCmd)
+	autoCmd.Flags().StringVarP(&autoOpts.Addr, "addr", "a", "", "server address, default to the saved address or 127.0.0.1:5030")
+	autoCmd.Flags().IntVar(&autoOpts.PID, "pid", 0, "wechat process to use when there are several")
+	autoCmd.Flags().StringVarP(&autoOpts.WorkDir, "work-dir", "w", "", "work dir, default to the saved work dir or a dir named after the account")
+	autoCmd.Flags().BoolVar(&autoOpts.ForceKey, "force-key", false, "extract the key again even if the saved key is still valid")
+	autoCmd.Flags().BoolVar(&autoOpts.NoIndex, "no-index", false, "do not build or update the search index")
+	autoCmd.Flags().BoolVar(&autoOpts.Watch, "watch", true, "decrypt new data automatically while the server is running")
+}
+
+var autoOpts chatlog.AutoOptions
+
+var autoCmd = &cobra.Command{
+	Use:   "auto",
+	Short: "Extract the key, decrypt, index and start the HTTP server in one command",
+	Long: `Run every step needed for a usable server in order: detect the wechat process, extract
+the key, locate the data dir, decrypt the databases to the work dir, build the search index and
+start the HTTP server. Re-running is cheap: a saved key that still works is reused, only changed
+pages are decrypted and only new messages are indexed. When wechat is not running, the account
+saved in the config file is served.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		m, err := chatlog.New("")
+		if err != nil {
+			log.Err(err).Msg("failed to create chatlog instance")
+			return
+		}
+		err = m.CommandAuto(autoOpts, printAutoStage)
+		exitAutoError(err)
+	},
+}
+
+// printAutoStage 输出 chatlog auto 与 chatlog daemon 中已完成的一个阶段
+func printAutoStage(s chatlog.AutoStage) {
+	result := "OK"
+	if s.Skipped {
+		result = "SKIP"
+	}
+	fmt.Printf("[%-4s] %-8s %8s  %s\n", result, s.Name, s.Elapsed.Round(time.Millisecond), s.Detail)
+}
+
+// exitAutoError 输出失败的阶段、原因与处理建议并以非零状态退出
+func exitAutoError(err error) {
+	var autoErr *chatlog.AutoError
+	if errors.As(err, &autoErr) {
+		fmt.Printf("[FAIL] %-8s %8s  %v\n", autoErr.Stage, "", autoErr.Err)
+		if autoErr.Hint != "" {
+			fmt.Printf("       %s\n", autoErr.Hint)
+		}
+		os.Exit(1)
+	}
+	if err != nil {
+		log.Err(err).Msg("failed to run")
+		os.Exit(1)
+	}
+}
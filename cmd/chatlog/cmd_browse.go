@@ -0,0 +1,48 @@
+package chatlog
+
+import (
+	"runtime"
+
+	"github.com/aspnmy/chatlog/internal/chatlog"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(browseCmd)
+	browseCmd.Flags().StringVarP(&browseDataDir, "data-dir", "d", "", "data dir")
+	browseCmd.Flags().StringVarP(&browseWorkDir, "work-dir", "w", "", "work dir")
+	browseCmd.Flags().StringVarP(&browsePlatform, "platform", "p", runtime.GOOS, "platform")
+	browseCmd.Flags().IntVarP(&browseVer, "version", "v", 3, "version")
+}
+
+var (
+	browseDataDir  string
+	browseWorkDir  string
+	browsePlatform string
+	browseVer      int
+)
+
+var browseCmd = &cobra.Command{
+	Use:   "browse",
+	Short: "Browse decrypted chat history in the terminal",
+	Long: `Browse the decrypted chat history in a full-screen terminal UI without starting the server.
+Keys: Tab switch pane, / filter sessions or search messages, n/N next/previous match,
+g jump to a date, [/] previous/next month, r reload sessions, q quit.`,
+	PreRun: initTuiLog,
+	Run: func(cmd *cobra.Command, args []string) {
+		m, err := chatlog.New("")
+		if err != nil {
+			initLog(cmd, args)
+			log.Err(err).Msg("failed to create chatlog instance")
+			return
+		}
+		if err := m.CommandBrowse(browseDataDir, browseWorkDir, browsePlatform, browseVer); err != nil {
+			// 界面已退出，错误输出到终端
+			initLog(cmd, args)
+			log.Err(err).Msg("failed to browse")
+			return
+		}
+	},
+}
@@ -0,0 +1,315 @@
+package chatlog
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/aspnmy/chatlog/internal/chatlog/conf"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+func init() {
+	rootCmd.AddCommand(configCmd)
+	configCmd.AddCommand(configValidateCmd)
+	configValidateCmd.Flags().StringVarP(&configDir, "config-dir", "c", "", "config dir, default is $CHATLOG_DIR or ~/.chatlog")
+	configValidateCmd.Flags().BoolVar(&configJSON, "json", false, "output as json")
+
+	configCmd.AddCommand(configEncryptCmd)
+	configEncryptCmd.Flags().StringVarP(&configDir, "config-dir", "c", "", "config dir, default is $CHATLOG_DIR or ~/.chatlog")
+	configEncryptCmd.Flags().BoolVar(&configKeychain, "keychain", false, "store the passphrase in the system keychain so it is not asked again")
+
+	configCmd.AddCommand(configDecryptCmd)
+	configDecryptCmd.Flags().StringVarP(&configDir, "config-dir", "c", "", "config dir, default is $CHATLOG_DIR or ~/.chatlog")
+
+	for _, c := range []*cobra.Command{configSetCmd, configGetCmd, configUnsetCmd, configListCmd} {
+		configCmd.AddCommand(c)
+		c.Flags().StringVarP(&configDir, "config-dir", "c", "", "config dir, default is $CHATLOG_DIR or ~/.chatlog")
+	}
+}
+
+var (
+	configDir      string
+	configJSON     bool
+	configKeychain bool
+)
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Manage configuration",
+}
+
+var configValidateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Validate configuration and print the effective settings with their sources",
+	Run: func(cmd *cobra.Command, args []string) {
+		report, err := conf.Validate(configDir)
+		if err != nil {
+			log.Err(err).Msg("failed to validate config")
+			os.Exit(1)
+		}
+
+		// 全局参数，未在命令行中指定时按环境变量、配置文件中的值显示来源
+		flags := cmd.Root().PersistentFlags()
+		// 配置文件无效时错误已由 Validate 报告
+		saved, _ := conf.FlagDefaults(configDir, true)
+		flags.VisitAll(func(f *pflag.Flag) {
+			value, source := f.Value.String(), conf.SourceDefault
+			if f.Changed {
+				source = conf.SourceFlag
+			} else if v, ok := os.LookupEnv(conf.FlagEnv(f.Name)); ok {
+				value, source = v, conf.SourceEnv
+			} else if v, ok := saved[f.Name]; ok {
+				value, source = v, conf.SourceFile
+			}
+			report.Add(source, "--"+f.Name, conf.MaskFlag(f.Name, value))
+		})
+		known := knownFlags()
+		for name, value := range saved {
+			f, ok := known[name]
+			if !ok {
+				report.Issue(conf.LevelError, "flags."+name, "unknown flag")
+			} else if err := checkFlagValue(f, value); err != nil {
+				report.Issue(conf.LevelError, "flags."+name, err.Error())
+			}
+		}
+
+		if configJSON {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			enc.Encode(report)
+		} else {
+			fmt.Printf("config file: %s\n\n", report.File)
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "KEY\tVALUE\tSOURCE")
+			for _, s := range report.Settings {
+				fmt.Fprintf(w, "%s\t%s\t%s\n", s.Key, s.Value, s.Source)
+			}
+			w.Flush()
+
+			fmt.Println()
+			if len(report.Issues) == 0 {
+				fmt.Println("config is valid")
+			}
+			for _, i := range report.Issues {
+				fmt.Println(i.String())
+			}
+		}
+
+		if report.HasError() {
+			os.Exit(1)
+		}
+	},
+}
+
+var configEncryptCmd = &cobra.Command{
+	Use:   "encrypt",
+	Short: "Encrypt the config file and the keys it holds with a passphrase, or change the passphrase",
+	Long: `Encrypt the config file and the keys it holds with a passphrase (scrypt + AES-GCM).
+The passphrase is read from $` + conf.EnvPassphrase + `, the system keychain, or asked once per run.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		passphrase := os.Getenv(conf.EnvPassphrase)
+		if passphrase == "" {
+			var err error
+			if passphrase, err = newPassphrase(); err != nil {
+				log.Err(err).Msg("failed to read passphrase")
+				os.Exit(1)
+			}
+		}
+		file, err := conf.Encrypt(configDir, passphrase, configKeychain)
+		if err != nil {
+			log.Err(err).Msg("failed to encrypt config")
+			os.Exit(1)
+		}
+		fmt.Printf("encrypted %s\n", file)
+	},
+}
+
+var configDecryptCmd = &cobra.Command{
+	Use:   "decrypt",
+	Short: "Store the config file as plain JSON again and remove the passphrase from the keychain",
+	Run: func(cmd *cobra.Command, args []string) {
+		file, err := conf.Decrypt(configDir)
+		if err != nil {
+			log.Err(err).Msg("failed to decrypt config")
+			os.Exit(1)
+		}
+		fmt.Printf("decrypted %s\n", file)
+	},
+}
+
+// newPassphrase 在终端中读取两次新口令并确认一致
+func newPassphrase() (string, error) {
+	passphrase, err := conf.ReadPassphrase("New passphrase: ")
+	if err != nil {
+		return "", err
+	}
+	if passphrase == "" {
+		return "", fmt.Errorf("passphrase is empty")
+	}
+	confirm, err := conf.ReadPassphrase("Repeat passphrase: ")
+	if err != nil {
+		return "", err
+	}
+	if confirm != passphrase {
+		return "", fmt.Errorf("passphrases do not match")
+	}
+	return passphrase, nil
+}
+
+var configSetCmd = &cobra.Command{
+	Use:   "set <flag> <value>",
+	Short: "Save a flag value used by every command when the flag is not given",
+	Long: `Save a flag value in the config file, e.g. "chatlog config set work-dir D:\chatlog" or
+"chatlog config set strategies weixin_dll". Commands that have the flag use the saved value when it
+is not given on the command line. The environment variable CHATLOG_<FLAG>, e.g. CHATLOG_WORK_DIR,
+takes precedence over the config file.`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		name, value := strings.TrimPrefix(args[0], "--"), args[1]
+		f, ok := knownFlags()[name]
+		if !ok || name == "config-dir" || name == "help" {
+			log.Error().Msgf("unknown flag --%s", name)
+			os.Exit(1)
+		}
+		if err := checkFlagValue(f, value); err != nil {
+			log.Err(err).Msgf("invalid value for --%s", name)
+			os.Exit(1)
+		}
+		s, err := conf.NewService(configDir)
+		if err != nil {
+			log.Err(err).Msg("failed to load config")
+			os.Exit(1)
+		}
+		if err := s.GetConfig().SetFlag(name, value); err != nil {
+			log.Err(err).Msg("failed to save config")
+			os.Exit(1)
+		}
+		fmt.Printf("--%s = %s\n", name, conf.MaskFlag(name, value))
+		if env := conf.FlagEnv(name); os.Getenv(env) != "" {
+			fmt.Printf("note: %s is set and takes precedence\n", env)
+		}
+	},
+}
+
+var configGetCmd = &cobra.Command{
+	Use:   "get <flag>",
+	Short: "Print the value a flag takes when it is not given, and where it comes from",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		name := strings.TrimPrefix(args[0], "--")
+		f, ok := knownFlags()[name]
+		if !ok {
+			log.Error().Msgf("unknown flag --%s", name)
+			os.Exit(1)
+		}
+		saved, err := conf.FlagDefaults(configDir, true)
+		if err != nil {
+			log.Err(err).Msg("failed to load config")
+			os.Exit(1)
+		}
+		value, source := f.DefValue, conf.SourceDefault
+		if v, ok := os.LookupEnv(conf.FlagEnv(name)); ok {
+			value, source = v, conf.SourceEnv
+		} else if v, ok := saved[name]; ok {
+			value, source = v, conf.SourceFile
+		}
+		fmt.Printf("%s\t(%s)\n", conf.MaskFlag(name, value), source)
+	},
+}
+
+var configUnsetCmd = &cobra.Command{
+	Use:   "unset <flag>",
+	Short: "Remove a saved flag value",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		name := strings.TrimPrefix(args[0], "--")
+		s, err := conf.NewService(configDir)
+		if err != nil {
+			log.Err(err).Msg("failed to load config")
+			os.Exit(1)
+		}
+		if err := s.GetConfig().UnsetFlag(name); err != nil {
+			log.Err(err).Msg("failed to remove flag")
+			os.Exit(1)
+		}
+		fmt.Printf("--%s removed\n", name)
+	},
+}
+
+var configListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List flag values saved in the config file or set by CHATLOG_* environment variables",
+	Run: func(cmd *cobra.Command, args []string) {
+		saved, err := conf.FlagDefaults(configDir, true)
+		if err != nil {
+			log.Err(err).Msg("failed to load config")
+			os.Exit(1)
+		}
+		known := knownFlags()
+		names := make([]string, 0, len(known))
+		for name := range known {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "FLAG\tVALUE\tSOURCE")
+		for _, name := range names {
+			if v, ok := os.LookupEnv(conf.FlagEnv(name)); ok {
+				fmt.Fprintf(w, "--%s\t%s\t%s (%s)\n", name, conf.MaskFlag(name, v), conf.SourceEnv, conf.FlagEnv(name))
+			} else if v, ok := saved[name]; ok {
+				fmt.Fprintf(w, "--%s\t%s\t%s\n", name, conf.MaskFlag(name, v), conf.SourceFile)
+			}
+		}
+		w.Flush()
+	},
+}
+
+// knownFlags 返回全部命令的参数，同名参数取第一个定义
+func knownFlags() map[string]*pflag.Flag {
+	flags := make(map[string]*pflag.Flag)
+	var walk func(c *cobra.Command)
+	walk = func(c *cobra.Command) {
+		for _, fs := range []*pflag.FlagSet{c.PersistentFlags(), c.Flags()} {
+			fs.VisitAll(func(f *pflag.Flag) {
+				if _, ok := flags[f.Name]; !ok {
+					flags[f.Name] = f
+				}
+			})
+		}
+		for _, sub := range c.Commands() {
+			walk(sub)
+		}
+	}
+	walk(rootCmd)
+	return flags
+}
+
+// checkFlagValue 按参数类型检查保存的值能否解析
+func checkFlagValue(f *pflag.Flag, value string) error {
+	var err error
+	switch f.Value.Type() {
+	case "bool":
+		_, err = strconv.ParseBool(value)
+	case "int", "int64", "int32":
+		_, err = strconv.ParseInt(value, 10, 64)
+	case "uint", "uint64", "uint32":
+		_, err = strconv.ParseUint(value, 10, 64)
+	case "float64", "float32":
+		_, err = strconv.ParseFloat(value, 64)
+	case "duration":
+		_, err = time.ParseDuration(value)
+	}
+	if err != nil {
+		return fmt.Errorf("invalid %s value %q", f.Value.Type(), value)
+	}
+	return nil
+}
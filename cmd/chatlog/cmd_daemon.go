@@ -0,0 +1,38 @@
+package chatlog
+
+import (
+	"github.com/aspnmy/chatlog/internal/chatlog"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(daemonCmd)
+	daemonCmd.Flags().StringVarP(&daemonOpts.Addr, "addr", "a", "", "server address, default to the saved address or 127.0.0.1:5030")
+	daemonCmd.Flags().IntVar(&daemonOpts.PID, "pid", 0, "wechat process to use when there are several")
+	daemonCmd.Flags().StringVarP(&daemonOpts.WorkDir, "work-dir", "w", "", "work dir, default to the saved work dir or a dir named after the account")
+	daemonCmd.Flags().BoolVar(&daemonOpts.ForceKey, "force-key", false, "extract the key again even if the saved key is still valid")
+	daemonCmd.Flags().BoolVar(&daemonOpts.NoIndex, "no-index", false, "do not build or update the search index")
+	daemonCmd.Flags().DurationVar(&daemonOpts.Interval, "interval", 0, "poll the data dir at this interval, e.g. 30s, instead of watching file changes")
+	daemonCmd.Flags().BoolVar(&daemonOpts.NoServer, "no-server", false, "only keep the work dir and the search index up to date, without the http server")
+}
+
+var daemonOpts chatlog.DaemonOptions
+
+var daemonCmd = &cobra.Command{
+	Use:   "daemon",
+	Short: "Keep the decrypted data and the search index in sync while wechat is running",
+	Long: `Prepare the data like "chatlog auto", then keep it current: databases changed by wechat are
+decrypted again incrementally and the search index is updated after each sync. Changes are found by
+watching the data dir, or by polling it with --interval where file watching does not work, such as
+network drives. The http server runs at the same time unless --no-server is given.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		m, err := chatlog.New("")
+		if err != nil {
+			log.Err(err).Msg("failed to create chatlog instance")
+			return
+		}
+		exitAutoError(m.CommandDaemon(daemonOpts, printAutoStage))
+	},
+}
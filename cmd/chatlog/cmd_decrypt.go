@@ -5,6 +5,8 @@ import (
 	"runtime"
 
 	"github.com/aspnmy/chatlog/internal/chatlog"
+	wechatsvc "github.com/aspnmy/chatlog/internal/chatlog/wechat"
+	"github.com/aspnmy/chatlog/internal/wechat"
 
 	"github.com/rs/zerolog/log"
 	"github.com/spf13/cobra"
@@ -12,11 +14,12 @@ import (
 
 func init() {
 	rootCmd.AddCommand(decryptCmd)
-	decryptCmd.Flags().StringVarP(&dataDir, "data-dir", "d", "", "data dir")
+	decryptCmd.Flags().StringVarP(&dataDir, "data-dir", "d", "", "data dir, found automatically if empty")
 	decryptCmd.Flags().StringVarP(&workDir, "work-dir", "w", "", "work dir")
 	decryptCmd.Flags().StringVarP(&key, "key", "k", "", "key")
 	decryptCmd.Flags().StringVarP(&decryptPlatform, "platform", "p", runtime.GOOS, "platform")
 	decryptCmd.Flags().IntVarP(&decryptVer, "version", "v", 3, "version")
+	decryptCmd.Flags().BoolVar(&decryptIncremental, "incremental", false, "only decrypt pages changed since the last decryption")
 }
 
 var (
@@ -25,6 +28,8 @@ var (
 	key             string
 	decryptPlatform string
 	decryptVer      int
+
+	decryptIncremental bool
 )
 
 var decryptCmd = &cobra.Command{
@@ -36,6 +41,29 @@ var decryptCmd = &cobra.Command{
 			log.Err(err).Msg("failed to create chatlog instance")
 			return
 		}
+		if cmd.Flags().Changed("incremental") {
+			wechatsvc.IncrementalDecrypt = decryptIncremental
+		}
+		// 未指定数据目录时自动查找，版本与平台跟随找到的目录
+		if dataDir == "" {
+			version := 0
+			if cmd.Flags().Changed("version") {
+				version = decryptVer
+			}
+			d, err := wechat.DefaultDataDir(version)
+			if err != nil {
+				log.Err(err).Msg("failed to find data dir, use --data-dir to specify it")
+				return
+			}
+			dataDir = d.Dir
+			if !cmd.Flags().Changed("version") {
+				decryptVer = d.Version
+			}
+			if !cmd.Flags().Changed("platform") {
+				decryptPlatform = d.Platform
+			}
+			log.Info().Msgf("using data dir %s (%s %d.x)", d.Dir, d.Platform, d.Version)
+		}
 		if err := m.CommandDecrypt(dataDir, workDir, key, decryptPlatform, decryptVer); err != nil {
 			log.Err(err).Msg("failed to decrypt")
 			return
@@ -0,0 +1,54 @@
+package chatlog
+
+import (
+	"fmt"
+	"runtime"
+
+	"github.com/aspnmy/chatlog/internal/chatlog"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(digestCmd)
+	digestCmd.Flags().StringVarP(&digestWorkDir, "work-dir", "w", "", "work dir")
+	digestCmd.Flags().StringVarP(&digestPlatform, "platform", "p", runtime.GOOS, "platform")
+	digestCmd.Flags().IntVarP(&digestVer, "version", "v", 3, "version")
+	digestCmd.Flags().StringVar(&digestTime, "time", "last-7d", "time range, e.g. last-7d or 2024-01-01~2024-01-07")
+	digestCmd.Flags().BoolVar(&digestSend, "send", false, "send the digest by email instead of only printing it, requires smtp")
+}
+
+var (
+	digestWorkDir  string
+	digestPlatform string
+	digestVer      int
+	digestTime     string
+	digestSend     bool
+)
+
+var digestCmd = &cobra.Command{
+	Use:   "digest",
+	Short: "Print or send a digest of the most active conversations, shared media and keyword hits",
+	Long: `Print a digest of the most active conversations, shared files and links, and hits of the
+keywords configured in the digest section of the config file. Locked talkers are excluded.
+Use --send to email it with the smtp settings, or run "chatlog server --digest" to send it weekly.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		m, err := chatlog.New("")
+		if err != nil {
+			log.Err(err).Msg("failed to create chatlog instance")
+			return
+		}
+		d, err := m.CommandDigest(digestWorkDir, digestPlatform, digestVer, digestTime, digestSend)
+		if d != nil && !digestSend {
+			fmt.Print(d.PlainText())
+		}
+		if err != nil {
+			log.Err(err).Msg("failed to create digest")
+			return
+		}
+		if digestSend {
+			fmt.Println("digest sent")
+		}
+	},
+}
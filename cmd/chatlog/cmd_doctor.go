@@ -0,0 +1,58 @@
+package chatlog
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/aspnmy/chatlog/internal/chatlog"
+	"github.com/aspnmy/chatlog/internal/wechat"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(doctorCmd)
+	doctorCmd.Flags().BoolVar(&doctorJSON, "json", false, "output as json")
+}
+
+var doctorJSON bool
+
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Check why the key of wechat can or cannot be extracted on this machine",
+	Long: `Check the permissions, the running wechat processes, their data dirs and main modules, and the
+data dirs on this machine. Wechat running inside a Sandboxie sandbox or a virtual machine is detected
+and the reason why extraction can't proceed is explained.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		m, err := chatlog.New("")
+		if err != nil {
+			log.Err(err).Msg("failed to create chatlog instance")
+			return
+		}
+		findings := m.CommandDoctor()
+		if doctorJSON {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			enc.Encode(findings)
+			return
+		}
+		failed := 0
+		for _, f := range findings {
+			fmt.Printf("[%-4s] %s\n", strings.ToUpper(f.Level), f.Title)
+			if f.Detail != "" {
+				fmt.Printf("       %s\n", f.Detail)
+			}
+			if f.Level == wechat.FindingFail {
+				failed++
+			}
+		}
+		if failed > 0 {
+			fmt.Printf("\n%d problems found\n", failed)
+		} else {
+			fmt.Println("\nno problems found")
+		}
+	},
+}
@@ -0,0 +1,207 @@
+package chatlog
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"runtime"
+	"sort"
+	"strings"
+
+	"github.com/aspnmy/chatlog/internal/chatlog"
+	"github.com/aspnmy/chatlog/internal/chatlog/conf"
+	"github.com/aspnmy/chatlog/internal/chatlog/export"
+	"github.com/aspnmy/chatlog/internal/wechat/media"
+	"github.com/aspnmy/chatlog/pkg/util"
+	"github.com/aspnmy/chatlog/pkg/util/silk"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+func init() {
+	rootCmd.AddCommand(exportCmd)
+	exportCmd.Flags().StringVarP(&exportWorkDir, "work-dir", "w", "", "work dir")
+	exportCmd.Flags().StringVarP(&exportPlatform, "platform", "p", runtime.GOOS, "platform")
+	exportCmd.Flags().IntVarP(&exportVer, "version", "v", 3, "version")
+	exportCmd.Flags().StringVarP(&exportOpts.Talker, "talker", "t", "", "talker, multiple separated by comma, empty for all sessions")
+	exportCmd.Flags().StringVar(&exportOpts.Time, "time", "", "time range, e.g. 2024-01-01~2024-12-31")
+	exportCmd.Flags().StringVarP(&exportOpts.Format, "format", "f", export.FormatText, "format: txt, json, jsonl, csv, gallery, obsidian, markdown, voice, html, attachments")
+	exportCmd.Flags().StringVarP(&exportOpts.Dest, "dest", "o", "", "destination: local dir, sftp://user@host/path, smb://server/share/path")
+	exportCmd.Flags().StringVarP(&exportOpts.DataDir, "data-dir", "d", "", "wechat data dir, required by the gallery and attachments formats, used for obsidian, markdown and html attachments")
+	exportCmd.Flags().StringVar(&exportOpts.ImgKey, "img-key", "", "image key of wechat 4.0, used by the gallery, obsidian, markdown and html formats")
+	exportCmd.Flags().BoolVar(&exportOpts.NormalizeTime, "normalize-time", false, "replace abnormal timestamps caused by device clock issues with the previous message's time")
+	exportCmd.Flags().BoolVar(&exportOpts.EncryptPerTalker, "encrypt-per-talker", false, "pack each talker into its own AES-256 encrypted zip with a distinct password")
+	exportCmd.Flags().StringVar(&exportPasswordFile, "password-file", "", "file of talker=password lines, talkers not listed get a random password")
+	exportCmd.Flags().BoolVar(&exportOpts.Notify, "notify", true, "send a summary email when finished, if smtp is configured")
+	exportCmd.Flags().StringVar(&exportPasswordOut, "password-out", "export_passwords.txt", "local file to save the password of each talker")
+	exportCmd.Flags().StringVar(&exportOpts.After, "after", "", "export only messages after this cursor, printed at the end of the previous export")
+	exportCmd.Flags().StringVar(&exportOpts.Lang, "lang", "", "also export translations of text messages into this language, e.g. en, translate config required for untranslated messages")
+	exportCmd.Flags().StringVar(&exportOpts.VoiceFormat, "voice-format", media.VoiceMP3, "audio format of the voice export: mp3, wav, silk; builds without cgo keep the original silk/amr")
+	exportCmd.Flags().IntVar(&exportOpts.MP3.SampleRate, "mp3-sample-rate", 0, "sample rate of exported mp3 voices in Hz, 0 keeps the rate of the voice")
+	exportCmd.Flags().IntVar(&exportOpts.MP3.Bitrate, "mp3-bitrate", silk.DefaultOptions.Bitrate, "bitrate of exported mp3 voices in kbps, the average bitrate with --mp3-vbr")
+	exportCmd.Flags().IntVar(&exportOpts.MP3.Channels, "mp3-channels", silk.DefaultOptions.Channels, "channels of exported mp3 voices, 1 or 2")
+	exportCmd.Flags().BoolVar(&exportOpts.MP3.VBR, "mp3-vbr", false, "encode exported mp3 voices with an average bitrate (ABR) instead of a constant bitrate")
+	exportCmd.Flags().BoolVar(&exportOpts.Inline, "inline", false, "embed images and voices into the pages of the html format as data URIs instead of separate files")
+	exportCmd.Flags().BoolVar(&exportOpts.Link, "link", false, "hard link videos and files of the attachments format into a local output dir instead of copying, falls back to copying")
+	exportCmd.Flags().BoolVar(&exportOpts.Offline, "offline", false, "do not download custom stickers for the html and markdown formats, use only those cached in the work dir")
+	exportCmd.Flags().StringVar(&exportOpts.NameTemplate, "name-template", "", "file name template of exported media and transcripts, e.g. \"{talker}/{date}/{msgid}_{type}.{ext}\", placeholders: talker, name, sender, date, time, datetime, year, month, msgid, key, type, ext")
+	exportCmd.Flags().StringVar(&exportOpts.Topic, "topic", "", "export only messages tagged with this topic, a topic from topics in the config file or a hashtag, e.g. decision")
+	exportCmd.Flags().StringVar(&exportOpts.Status, "status", "", "export only messages with this delivery status: sending, sent, failed or received")
+	// --output 为 --dest 的别名
+	exportCmd.Flags().SetNormalizeFunc(func(f *pflag.FlagSet, name string) pflag.NormalizedName {
+		if name == "output" {
+			name = "dest"
+		}
+		return pflag.NormalizedName(name)
+	})
+	exportCmd.Flags().StringVar(&exportProfile, "profile", "", "named export profile from export_profiles in the config file, flags given on the command line take precedence")
+}
+
+var (
+	exportWorkDir  string
+	exportPlatform string
+	exportVer      int
+	exportOpts     export.Options
+
+	exportPasswordFile string
+	exportPasswordOut  string
+	exportProfile      string
+)
+
+var exportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export chat history",
+	Run: func(cmd *cobra.Command, args []string) {
+		m, err := chatlog.New("")
+		if err != nil {
+			log.Err(err).Msg("failed to create chatlog instance")
+			return
+		}
+		if exportProfile != "" {
+			p, err := m.ExportProfile(exportProfile)
+			if err != nil {
+				log.Err(err).Msg("failed to load export profile")
+				return
+			}
+			applyProfile(cmd, p)
+		}
+		if exportOpts.EncryptPerTalker && exportPasswordFile != "" {
+			if exportOpts.Passwords, err = readPasswords(exportPasswordFile); err != nil {
+				log.Err(err).Msg("failed to read password file")
+				return
+			}
+		}
+		result, err := m.CommandExport(exportWorkDir, exportPlatform, exportVer, exportOpts)
+		if err != nil {
+			log.Err(err).Msg("failed to export")
+			return
+		}
+		fmt.Printf("export %d messages in %d files (%s) to %s\n", result.Messages, len(result.Files), util.ByteCountSI(result.Bytes), result.Dest)
+		if len(result.Failed) > 0 {
+			fmt.Printf("failed: %v\n", result.Failed)
+		}
+		if result.Cursor != "" {
+			fmt.Printf("cursor: %s, use --after to export only newer messages next time\n", result.Cursor)
+		}
+		if result.TimeAnomalies > 0 {
+			if exportOpts.NormalizeTime {
+				fmt.Printf("normalized %d messages with abnormal time\n", result.TimeAnomalies)
+			} else {
+				fmt.Printf("warning: %d messages with abnormal time, use --normalize-time to fix them\n", result.TimeAnomalies)
+			}
+		}
+		if len(result.Passwords) > 0 {
+			if err := writePasswords(exportPasswordOut, result.Passwords); err != nil {
+				log.Err(err).Msg("failed to save passwords")
+				return
+			}
+			fmt.Printf("passwords saved to %s, keep it safe\n", exportPasswordOut)
+		}
+	},
+}
+
+// applyProfile 将 profile 中的参数应用到命令行未显式指定的参数上
+func applyProfile(cmd *cobra.Command, p *conf.ExportProfile) {
+	flags := cmd.Flags()
+	set := func(name string, dst *string, v string) {
+		if v != "" && !flags.Changed(name) {
+			*dst = v
+		}
+	}
+	setBool := func(name string, dst *bool, v *bool) {
+		if v != nil && !flags.Changed(name) {
+			*dst = *v
+		}
+	}
+	setInt := func(name string, dst *int, v int) {
+		if v != 0 && !flags.Changed(name) {
+			*dst = v
+		}
+	}
+	set("work-dir", &exportWorkDir, p.WorkDir)
+	set("platform", &exportPlatform, p.Platform)
+	setInt("version", &exportVer, p.Version)
+	set("format", &exportOpts.Format, p.Format)
+	set("talker", &exportOpts.Talker, p.Talker)
+	set("time", &exportOpts.Time, p.Time)
+	set("dest", &exportOpts.Dest, p.Dest)
+	set("data-dir", &exportOpts.DataDir, p.DataDir)
+	set("img-key", &exportOpts.ImgKey, p.ImgKey)
+	set("lang", &exportOpts.Lang, p.Lang)
+	set("voice-format", &exportOpts.VoiceFormat, p.VoiceFormat)
+	set("name-template", &exportOpts.NameTemplate, p.NameTemplate)
+	set("topic", &exportOpts.Topic, p.Topic)
+	set("status", &exportOpts.Status, p.Status)
+	setInt("mp3-sample-rate", &exportOpts.MP3.SampleRate, p.MP3SampleRate)
+	setInt("mp3-bitrate", &exportOpts.MP3.Bitrate, p.MP3Bitrate)
+	setInt("mp3-channels", &exportOpts.MP3.Channels, p.MP3Channels)
+	setBool("mp3-vbr", &exportOpts.MP3.VBR, p.MP3VBR)
+	set("password-file", &exportPasswordFile, p.PasswordFile)
+	setBool("normalize-time", &exportOpts.NormalizeTime, p.NormalizeTime)
+	setBool("encrypt-per-talker", &exportOpts.EncryptPerTalker, p.EncryptPerTalker)
+	setBool("notify", &exportOpts.Notify, p.Notify)
+	setBool("inline", &exportOpts.Inline, p.Inline)
+	setBool("offline", &exportOpts.Offline, p.Offline)
+	setBool("link", &exportOpts.Link, p.Link)
+}
+
+// readPasswords 读取 talker=password 格式的密码文件，忽略空行与 # 开头的注释
+func readPasswords(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	passwords := make(map[string]string)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		talker, password, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid line in password file: %s", line)
+		}
+		passwords[strings.TrimSpace(talker)] = strings.TrimSpace(password)
+	}
+	return passwords, scanner.Err()
+}
+
+// writePasswords 以 readPasswords 可读取的格式保存密码，仅当前用户可读
+func writePasswords(path string, passwords map[string]string) error {
+	talkers := make([]string, 0, len(passwords))
+	for talker := range passwords {
+		talkers = append(talkers, talker)
+	}
+	sort.Strings(talkers)
+
+	var sb strings.Builder
+	for _, talker := range talkers {
+		fmt.Fprintf(&sb, "%s=%s\n", talker, passwords[talker])
+	}
+	return os.WriteFile(path, []byte(sb.String()), 0600)
+}
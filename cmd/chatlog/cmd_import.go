@@ -0,0 +1,57 @@
+package chatlog
+
+import (
+	"fmt"
+
+	"github.com/aspnmy/chatlog/internal/chatlog"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(importCmd)
+	importCmd.Flags().StringVarP(&importWorkDir, "work-dir", "w", "", "work dir, empty for the current account")
+	importCmd.Flags().StringSliceVar(&importJSONL, "jsonl", nil, "jsonl file exported by chatlog export -f jsonl, can be repeated")
+	importCmd.Flags().BoolVar(&importDedup, "dedup", false, "skip messages already imported with the same talker, time, sender, type and content, e.g. from another device")
+}
+
+var (
+	importWorkDir string
+	importJSONL   []string
+	importDedup   bool
+)
+
+var importCmd = &cobra.Command{
+	Use:   "import",
+	Short: "Import messages exported as jsonl back into the work dir",
+	Long: `Import messages exported with "chatlog export -f jsonl" into the work dir.
+Imported messages are merged with the wechat data when querying, searching and exporting.
+A message with the same talker and seq replaces the previously imported one, so files processed elsewhere can be imported again.
+With --dedup, messages whose talker, time, sender, type and content match an imported message are skipped,
+so exports of the same chats from several devices can be merged. Deduplication uses an on-disk bloom filter
+(imported.bloom in the work dir) and runs in bounded memory, see --max-mem.
+Restart the server afterwards, and run "chatlog index rebuild" if the search index is used.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		m, err := chatlog.New("")
+		if err != nil {
+			log.Err(err).Msg("failed to create chatlog instance")
+			return
+		}
+		result, err := m.CommandImport(importWorkDir, importJSONL, importDedup)
+		if result != nil {
+			for _, e := range result.Errors {
+				fmt.Println("skipped", e)
+			}
+		}
+		if err != nil {
+			log.Err(err).Msg("failed to import")
+			return
+		}
+		fmt.Printf("imported %d messages of %d talkers, %d lines skipped", result.Messages, result.Talkers, result.Skipped)
+		if importDedup {
+			fmt.Printf(", %d duplicates skipped", result.Duplicates)
+		}
+		fmt.Println()
+	},
+}
@@ -0,0 +1,90 @@
+package chatlog
+
+import (
+	"fmt"
+	"runtime"
+	"time"
+
+	"github.com/aspnmy/chatlog/internal/chatlog"
+	"github.com/aspnmy/chatlog/pkg/search"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(indexCmd)
+	indexCmd.AddCommand(indexRebuildCmd)
+	indexRebuildCmd.Flags().StringVarP(&indexWorkDir, "work-dir", "w", "", "work dir")
+	indexRebuildCmd.Flags().StringVarP(&indexPlatform, "platform", "p", runtime.GOOS, "platform")
+	indexRebuildCmd.Flags().IntVarP(&indexVer, "version", "v", 3, "version")
+	indexRebuildCmd.Flags().StringVarP(&indexOpts.Tokenizer, "tokenizer", "t", search.DefaultTokenizer, "tokenizer: unigram, bigram, jieba")
+	indexRebuildCmd.Flags().StringVar(&indexOpts.Dict, "dict", "", "jieba format dictionary for the jieba tokenizer")
+	indexRebuildCmd.Flags().StringVar(&indexOpts.UserDict, "user-dict", "", "jieba format user dictionary, e.g. names and slang")
+	indexRebuildCmd.Flags().BoolVar(&indexRestart, "restart", false, "start over instead of resuming an interrupted build")
+	indexCmd.AddCommand(indexUpdateCmd)
+	indexUpdateCmd.Flags().StringVarP(&indexWorkDir, "work-dir", "w", "", "work dir")
+	indexUpdateCmd.Flags().StringVarP(&indexPlatform, "platform", "p", runtime.GOOS, "platform")
+	indexUpdateCmd.Flags().IntVarP(&indexVer, "version", "v", 3, "version")
+}
+
+var (
+	indexWorkDir  string
+	indexPlatform string
+	indexVer      int
+	indexOpts     search.Options
+	indexRestart  bool
+)
+
+var indexCmd = &cobra.Command{
+	Use:   "index",
+	Short: "Manage the search index",
+}
+
+var indexRebuildCmd = &cobra.Command{
+	Use:   "rebuild",
+	Short: "Rebuild the search index, also used to switch tokenizer",
+	Run: func(cmd *cobra.Command, args []string) {
+		m, err := chatlog.New("")
+		if err != nil {
+			log.Err(err).Msg("failed to create chatlog instance")
+			return
+		}
+		result, err := m.CommandIndexRebuild(indexWorkDir, indexPlatform, indexVer, indexOpts, indexRestart)
+		if err != nil {
+			log.Err(err).Msg("failed to rebuild index")
+			return
+		}
+		fmt.Printf("indexed %d messages of %d talkers with %s tokenizer in %s\n", result.Messages, result.Talkers, result.Info.Options.Tokenizer, result.Duration.Round(time.Millisecond))
+		if result.Resumed > 0 {
+			fmt.Printf("resumed, skipped %d talkers indexed before interruption\n", result.Resumed)
+		}
+		fmt.Printf("index: %s (%d messages)\n", result.Info.Path, result.Info.Docs)
+		if len(result.Failed) > 0 {
+			fmt.Printf("failed: %v\n", result.Failed)
+			fmt.Println("index build not finished, run \"chatlog index rebuild\" again to retry the failed talkers")
+		}
+	},
+}
+
+var indexUpdateCmd = &cobra.Command{
+	Use:   "update",
+	Short: "Add messages synced since the last build or update to the search index",
+	Run: func(cmd *cobra.Command, args []string) {
+		m, err := chatlog.New("")
+		if err != nil {
+			log.Err(err).Msg("failed to create chatlog instance")
+			return
+		}
+		result, err := m.CommandIndexUpdate(indexWorkDir, indexPlatform, indexVer)
+		if err != nil {
+			log.Err(err).Msg("failed to update index")
+			return
+		}
+		fmt.Printf("checked %d talkers, indexed %d messages in %s\n", result.Talkers, result.Messages, result.Duration.Round(time.Millisecond))
+		fmt.Printf("index: %s (%d messages)\n", result.Info.Path, result.Info.Docs)
+		if len(result.Failed) > 0 {
+			fmt.Printf("failed: %v\n", result.Failed)
+		}
+	},
+}
@@ -1,19 +1,60 @@
 package chatlog
 
 import (
+	"encoding/json"
 	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
 
 	"github.com/aspnmy/chatlog/internal/chatlog"
+	"github.com/aspnmy/chatlog/pkg/keybag"
+	"github.com/aspnmy/chatlog/pkg/memscan"
+	"github.com/aspnmy/chatlog/pkg/util"
 
 	"github.com/rs/zerolog/log"
 	"github.com/spf13/cobra"
+	"golang.org/x/term"
 )
 
 func init() {
 	rootCmd.AddCommand(keyCmd)
 	keyCmd.Flags().IntVarP(&pid, "pid", "p", 0, "pid")
+
+	keyCmd.AddCommand(keyExportCmd)
+	keyExportCmd.Flags().StringVarP(&keyAccount, "account", "a", "", "account, empty for all saved accounts")
+	keyExportCmd.Flags().StringVarP(&keyFile, "output", "o", "", "output file, default to stdout")
+
+	keyCmd.AddCommand(keyImportCmd)
+	keyImportCmd.Flags().BoolVar(&keyOverwrite, "overwrite", false, "overwrite keys already saved for an account")
+
+	keyCmd.AddCommand(keyClearCacheCmd)
+
+	keyCmd.AddCommand(keyRegionsCmd)
+	keyRegionsCmd.Flags().IntVarP(&pid, "pid", "p", 0, "pid, required when more than one wechat process is running")
+
+	keyCmd.AddCommand(keyBenchCmd)
+	keyBenchCmd.Flags().StringVar(&keyBenchDump, "dump", "", "memory dump of a wechat 4.x process")
+	keyBenchCmd.Flags().StringVarP(&keyBenchDataDir, "data-dir", "d", "", "wechat data dir, used to validate candidate keys")
+	keyBenchCmd.Flags().StringVar(&keyBenchDB, "db", "", "encrypted database file to validate candidate keys, instead of --data-dir")
+	keyBenchCmd.Flags().BoolVar(&keyBench32, "32bit", false, "the dump comes from a 32-bit process")
+	keyBenchCmd.Flags().BoolVar(&keyBenchJSON, "json", false, "output as json")
+	keyBenchCmd.MarkFlagRequired("dump")
 }
 
+var (
+	keyAccount   string
+	keyFile      string
+	keyOverwrite bool
+
+	keyBenchDump    string
+	keyBenchDataDir string
+	keyBenchDB      string
+	keyBench32      bool
+	keyBenchJSON    bool
+)
+
 var pid int
 var keyCmd = &cobra.Command{
 	Use:   "key",
@@ -24,7 +65,17 @@ var keyCmd = &cobra.Command{
 			log.Err(err).Msg("failed to create chatlog instance")
 			return
 		}
-		ret, err := m.CommandKey(pid)
+		// 终端中显示内存扫描进度，重定向输出时不显示
+		var progress memscan.ProgressFunc
+		var bar *memscan.Bar
+		if term.IsTerminal(int(os.Stderr.Fd())) {
+			bar = memscan.NewBar(os.Stderr, 200*time.Millisecond)
+			progress = bar.Update
+		}
+		ret, err := m.CommandKey(pid, progress)
+		if bar != nil {
+			bar.Done()
+		}
 		if err != nil {
 			log.Err(err).Msg("failed to get key")
 			return
@@ -32,3 +83,148 @@ var keyCmd = &cobra.Command{
 		fmt.Println(ret)
 	},
 }
+
+var keyExportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export saved keys as a keybag json for other tools",
+	Run: func(cmd *cobra.Command, args []string) {
+		m, err := chatlog.New("")
+		if err != nil {
+			log.Err(err).Msg("failed to create chatlog instance")
+			return
+		}
+		b, err := m.CommandKeyExport(keyAccount)
+		if err != nil {
+			log.Err(err).Msg("failed to export keys")
+			return
+		}
+		if keyFile == "" {
+			b.Write(os.Stdout)
+			return
+		}
+		f, err := os.OpenFile(keyFile, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+		if err != nil {
+			log.Err(err).Msg("failed to create keybag file")
+			return
+		}
+		defer f.Close()
+		if err := b.Write(f); err != nil {
+			log.Err(err).Msg("failed to write keybag file")
+			return
+		}
+		fmt.Printf("exported %d keys to %s, keep it safe\n", len(b.Keys), keyFile)
+	},
+}
+
+var keyImportCmd = &cobra.Command{
+	Use:   "import <file>",
+	Short: "Import keys from a keybag json, - for stdin",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		m, err := chatlog.New("")
+		if err != nil {
+			log.Err(err).Msg("failed to create chatlog instance")
+			return
+		}
+		var r io.Reader = os.Stdin
+		if args[0] != "-" {
+			f, err := os.Open(args[0])
+			if err != nil {
+				log.Err(err).Msg("failed to open keybag file")
+				return
+			}
+			defer f.Close()
+			r = f
+		}
+		b, err := keybag.Read(r)
+		if err != nil {
+			log.Err(err).Msg("failed to read keybag")
+			return
+		}
+		result, err := m.CommandKeyImport(b, keyOverwrite)
+		if err != nil {
+			log.Err(err).Msg("failed to import keys")
+			return
+		}
+		for _, account := range result.Imported {
+			fmt.Printf("imported %s\n", account)
+		}
+		for _, account := range result.Skipped {
+			fmt.Printf("skipped %s, a different key is already saved, use --overwrite to replace it\n", account)
+		}
+	},
+}
+
+var keyClearCacheCmd = &cobra.Command{
+	Use:   "clear-cache",
+	Short: "Remove the encrypted key cache, keys are extracted from the wechat process again on next use",
+	Run: func(cmd *cobra.Command, args []string) {
+		m, err := chatlog.New("")
+		if err != nil {
+			log.Err(err).Msg("failed to create chatlog instance")
+			return
+		}
+		path, err := m.CommandKeyClearCache()
+		if err != nil {
+			log.Err(err).Msg("failed to clear key cache")
+			return
+		}
+		fmt.Printf("removed %s\n", path)
+	},
+}
+
+var keyRegionsCmd = &cobra.Command{
+	Use:   "regions",
+	Short: "Print the memory regions scanned for keys, to diagnose a key that can't be found",
+	Run: func(cmd *cobra.Command, args []string) {
+		m, err := chatlog.New("")
+		if err != nil {
+			log.Err(err).Msg("failed to create chatlog instance")
+			return
+		}
+		ins, regions, err := m.CommandKeyRegions(pid)
+		if err != nil {
+			log.Err(err).Msg("failed to list memory regions")
+			return
+		}
+		fmt.Printf("PID: %d %s [Platform: %s Version: %d %s Status: %s]\n", ins.PID, ins.Name, ins.Platform, ins.Version, ins.FullVersion, ins.Status)
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "START\tEND\tSIZE\tPROT\tMODULE")
+		var total uint64
+		for _, r := range regions {
+			fmt.Fprintf(w, "0x%X\t0x%X\t%s\t%s\t%s\n", r.Start, r.Start+r.Size, util.ByteCountSI(int64(r.Size)), r.Protect, r.Module)
+			total += r.Size
+		}
+		w.Flush()
+		fmt.Printf("%d regions, %s in total\n", len(regions), util.ByteCountSI(int64(total)))
+	},
+}
+
+var keyBenchCmd = &cobra.Command{
+	Use:   "bench",
+	Short: "Run every registered 4.x key search strategy on a memory dump and report time, candidates tested and hits",
+	Run: func(cmd *cobra.Command, args []string) {
+		m, err := chatlog.New("")
+		if err != nil {
+			log.Err(err).Msg("failed to create chatlog instance")
+			return
+		}
+		results, err := m.CommandKeyBench(keyBenchDump, keyBenchDataDir, keyBenchDB, keyBench32)
+		if err != nil {
+			log.Err(err).Msg("failed to bench key search strategies")
+			return
+		}
+		if keyBenchJSON {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			enc.Encode(results)
+			return
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "STRATEGY\tTIME\tCANDIDATES\tFOUND")
+		for _, r := range results {
+			fmt.Fprintf(w, "%s\t%s\t%d\t%v\n", r.Strategy, r.Duration.Round(time.Millisecond), r.Candidates, r.Found)
+		}
+		w.Flush()
+	},
+}
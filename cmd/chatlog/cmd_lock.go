@@ -0,0 +1,105 @@
+package chatlog
+
+import (
+	"fmt"
+
+	"github.com/aspnmy/chatlog/internal/chatlog"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(lockCmd)
+	lockCmd.AddCommand(lockAddCmd)
+	lockCmd.AddCommand(lockRemoveCmd)
+	lockCmd.AddCommand(lockListCmd)
+	lockCmd.AddCommand(lockPassphraseCmd)
+}
+
+var lockCmd = &cobra.Command{
+	Use:   "lock",
+	Short: "Manage locked talkers, which require a passphrase header to be viewed over HTTP and are hidden from MCP",
+}
+
+var lockAddCmd = &cobra.Command{
+	Use:   "add <talker>...",
+	Short: "Lock talkers by ID (wxid or chatroom ID), asks for a passphrase if none is set",
+	Args:  cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		m, err := chatlog.New("")
+		if err != nil {
+			log.Err(err).Msg("failed to create chatlog instance")
+			return
+		}
+		passphrase := ""
+		if m.LockConfig().Passphrase == "" {
+			if passphrase, err = newPassphrase(); err != nil {
+				log.Err(err).Msg("failed to read passphrase")
+				return
+			}
+		}
+		lock, err := m.CommandLock(args, nil, passphrase)
+		if err != nil {
+			log.Err(err).Msg("failed to lock talkers")
+			return
+		}
+		fmt.Printf("%d talkers locked\n", len(lock.Talkers))
+	},
+}
+
+var lockRemoveCmd = &cobra.Command{
+	Use:   "remove <talker>...",
+	Short: "Unlock talkers",
+	Args:  cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		m, err := chatlog.New("")
+		if err != nil {
+			log.Err(err).Msg("failed to create chatlog instance")
+			return
+		}
+		lock, err := m.CommandLock(nil, args, "")
+		if err != nil {
+			log.Err(err).Msg("failed to unlock talkers")
+			return
+		}
+		fmt.Printf("%d talkers locked\n", len(lock.Talkers))
+	},
+}
+
+var lockListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List locked talkers",
+	Run: func(cmd *cobra.Command, args []string) {
+		m, err := chatlog.New("")
+		if err != nil {
+			log.Err(err).Msg("failed to create chatlog instance")
+			return
+		}
+		for _, talker := range m.LockConfig().Talkers {
+			fmt.Println(talker)
+		}
+	},
+}
+
+var lockPassphraseCmd = &cobra.Command{
+	Use:   "passphrase",
+	Short: "Set the passphrase required to view locked talkers",
+	Run: func(cmd *cobra.Command, args []string) {
+		m, err := chatlog.New("")
+		if err != nil {
+			log.Err(err).Msg("failed to create chatlog instance")
+			return
+		}
+		passphrase, err := newPassphrase()
+		if err != nil {
+			log.Err(err).Msg("failed to read passphrase")
+			return
+		}
+		if _, err := m.CommandLock(nil, nil, passphrase); err != nil {
+			log.Err(err).Msg("failed to set lock passphrase")
+			return
+		}
+		fmt.Println("lock passphrase updated")
+	},
+}
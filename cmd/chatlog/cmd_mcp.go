@@ -0,0 +1,58 @@
+package chatlog
+
+import (
+	"runtime"
+
+	"github.com/aspnmy/chatlog/internal/chatlog"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(mcpCmd)
+	mcpCmd.Flags().StringVarP(&mcpTransport, "transport", "t", "stdio", "transport, stdio or sse")
+	mcpCmd.Flags().StringVarP(&mcpAddr, "addr", "a", "127.0.0.1:5030", "server address for the sse transport")
+	mcpCmd.Flags().StringVarP(&mcpDataDir, "data-dir", "d", "", "data dir")
+	mcpCmd.Flags().StringVarP(&mcpWorkDir, "work-dir", "w", "", "work dir")
+	mcpCmd.Flags().StringVarP(&mcpPlatform, "platform", "p", runtime.GOOS, "platform")
+	mcpCmd.Flags().IntVarP(&mcpVer, "version", "v", 3, "version")
+}
+
+var (
+	mcpTransport string
+	mcpAddr      string
+	mcpDataDir   string
+	mcpWorkDir   string
+	mcpPlatform  string
+	mcpVer       int
+)
+
+var mcpCmd = &cobra.Command{
+	Use:   "mcp",
+	Short: "Start MCP server",
+	Long: `Start an MCP (Model Context Protocol) server exposing the decrypted chat history to MCP clients.
+The stdio transport reads requests from stdin and writes responses to stdout, so the client can start chatlog as a subprocess.
+The sse transport starts the HTTP server, MCP clients connect to http://<addr>/sse.
+Locked talkers are never returned.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		m, err := chatlog.New("")
+		if err != nil {
+			log.Err(err).Msg("failed to create chatlog instance")
+			return
+		}
+		switch mcpTransport {
+		case "stdio":
+			err = m.CommandMCPStdio(mcpDataDir, mcpWorkDir, mcpPlatform, mcpVer)
+		case "sse":
+			err = m.CommandHTTPServer(mcpAddr, mcpDataDir, mcpWorkDir, mcpPlatform, mcpVer)
+		default:
+			log.Error().Msgf("unsupported transport %s, expected stdio or sse", mcpTransport)
+			return
+		}
+		if err != nil {
+			log.Err(err).Msg("failed to start mcp server")
+			return
+		}
+	},
+}
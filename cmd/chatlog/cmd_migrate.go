@@ -0,0 +1,53 @@
+package chatlog
+
+import (
+	"fmt"
+
+	"github.com/aspnmy/chatlog/internal/chatlog"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(migrateCmd)
+	migrateCmd.Flags().StringSliceVar(&migrateOpts.Roots, "root", nil, "dirs containing account data dirs, e.g. WeChat Files and xwechat_files, default to system locations")
+	migrateCmd.Flags().StringVarP(&migrateOpts.Account, "account", "a", "", "account, default to the current account")
+	migrateCmd.Flags().StringVar(&migrateOpts.LegacyDir, "legacy-dir", "", "3.x data dir, if it can not be located automatically")
+	migrateCmd.Flags().StringVar(&migrateOpts.LegacyWorkDir, "legacy-work-dir", "", "work dir of the decrypted 3.x data")
+	migrateCmd.Flags().BoolVar(&migrateOpts.Link, "link", false, "merge 3.x history into the 4.x account")
+}
+
+var migrateOpts chatlog.MigrateOptions
+
+var migrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Locate 3.x and 4.x data dirs and merge their histories",
+	Run: func(cmd *cobra.Command, args []string) {
+		m, err := chatlog.New("")
+		if err != nil {
+			log.Err(err).Msg("failed to create chatlog instance")
+			return
+		}
+		result, err := m.CommandMigrate(migrateOpts)
+		if result != nil {
+			for _, d := range result.Dirs {
+				mark := " "
+				if d.Account == result.Account {
+					mark = "*"
+				}
+				fmt.Printf("%s %-24s v%d  %s  %s\n", mark, d.Account, d.Version, d.Modified.Format("2006-01-02"), d.Dir)
+			}
+			if len(result.Dirs) == 0 {
+				fmt.Println("no data dir found, use --root to specify where to look")
+			}
+		}
+		if err != nil {
+			log.Err(err).Msg("failed to migrate")
+			return
+		}
+		if result.Linked != nil {
+			fmt.Printf("linked %s (%s) to %s\n", result.Linked.DataDir, result.Linked.WorkDir, result.Account)
+		}
+	},
+}
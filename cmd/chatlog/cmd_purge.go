@@ -0,0 +1,61 @@
+package chatlog
+
+import (
+	"fmt"
+	"runtime"
+
+	"github.com/aspnmy/chatlog/internal/chatlog"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(purgeCmd)
+	purgeCmd.Flags().StringVarP(&purgeOpts.WorkDir, "work-dir", "w", "", "work dir")
+	purgeCmd.Flags().StringVarP(&purgeOpts.Platform, "platform", "p", runtime.GOOS, "platform")
+	purgeCmd.Flags().IntVarP(&purgeOpts.Version, "version", "v", 3, "version")
+	purgeCmd.Flags().StringVarP(&purgeOpts.Talker, "talker", "t", "", "talker, id or name")
+	purgeCmd.Flags().StringVar(&purgeOpts.Time, "time", "", "time range, e.g. 2024-01-01~2024-12-31, empty for all")
+	purgeCmd.Flags().StringVar(&purgeOpts.Sender, "sender", "", "only messages from this sender")
+	purgeCmd.Flags().StringVar(&purgeOpts.Keyword, "keyword", "", "only messages containing this keyword")
+	purgeCmd.Flags().BoolVar(&purgeOpts.Tombstone, "tombstone", false, "record tombstones so the messages stay hidden after re-decrypting or merging older data")
+	purgeCmd.Flags().BoolVar(&purgeOpts.Restore, "restore", false, "remove tombstones of the talker in the time range")
+}
+
+var purgeOpts chatlog.PurgeOptions
+
+var purgeCmd = &cobra.Command{
+	Use:   "purge",
+	Short: "Hide purged messages from queries, search and export",
+	Long: `Hide purged messages from queries, search and export.
+
+chatlog never modifies WeChat data, so purging works through tombstones recorded in
+tombstones.json in the work dir. Tombstoned messages are filtered from the merged
+timeline, including copies that still exist in linked 3.x data or in older snapshots.
+Without --tombstone only the number of matching messages is printed.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		m, err := chatlog.New("")
+		if err != nil {
+			log.Err(err).Msg("failed to create chatlog instance")
+			return
+		}
+		result, err := m.CommandPurge(purgeOpts)
+		if err != nil {
+			log.Err(err).Msg("failed to purge")
+			return
+		}
+		switch {
+		case purgeOpts.Restore:
+			fmt.Printf("removed %d tombstones\n", result.Removed)
+		case purgeOpts.Tombstone:
+			fmt.Printf("tombstoned %d of %d matching messages\n", result.Added, result.Matched)
+		default:
+			fmt.Printf("%d messages match, use --tombstone to purge them\n", result.Matched)
+		}
+		fmt.Printf("tombstones: %d\n", result.Total)
+		if result.Modified {
+			fmt.Println("restart chatlog server and run chatlog index rebuild to apply the change")
+		}
+	},
+}
@@ -0,0 +1,58 @@
+package chatlog
+
+import (
+	"fmt"
+	"runtime"
+
+	"github.com/aspnmy/chatlog/internal/chatlog"
+	"github.com/aspnmy/chatlog/internal/chatlog/conf"
+	"github.com/aspnmy/chatlog/internal/chatlog/export"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(pushCmd)
+	pushCmd.Flags().StringVarP(&pushWorkDir, "work-dir", "w", "", "work dir")
+	pushCmd.Flags().StringVarP(&pushPlatform, "platform", "p", runtime.GOOS, "platform")
+	pushCmd.Flags().IntVarP(&pushVer, "version", "v", 3, "version")
+	pushCmd.Flags().StringVarP(&pushOpts.Talker, "talker", "t", "", "talker, multiple separated by comma, empty for all sessions")
+	pushCmd.Flags().StringVar(&pushOpts.Time, "time", "", "time range, e.g. 2024-01-01~2024-12-31")
+	pushCmd.Flags().BoolVar(&pushOpts.NormalizeTime, "normalize-time", false, "replace abnormal timestamps caused by device clock issues with the previous message's time")
+	pushCmd.Flags().StringVar(&pushOpts.Lang, "lang", "", "also push translations of text messages into this language, e.g. en")
+}
+
+var (
+	pushWorkDir  string
+	pushPlatform string
+	pushVer      int
+	pushOpts     export.Options
+)
+
+var pushCmd = &cobra.Command{
+	Use:       "push <notion|feishu>",
+	Short:     "Push conversations to Notion or Feishu docs, one document per talker",
+	Long:      "Push conversations to Notion or Feishu docs, one document per talker.\n\nTokens and the parent page or folder are read from the notion / feishu section of the config file.",
+	Args:      cobra.ExactArgs(1),
+	ValidArgs: []string{conf.PublishNotion, conf.PublishFeishu},
+	Run: func(cmd *cobra.Command, args []string) {
+		m, err := chatlog.New("")
+		if err != nil {
+			log.Err(err).Msg("failed to create chatlog instance")
+			return
+		}
+		result, err := m.CommandPush(pushWorkDir, pushPlatform, pushVer, args[0], pushOpts)
+		if err != nil {
+			log.Err(err).Msg("failed to push")
+			return
+		}
+		fmt.Printf("pushed %d messages in %d documents to %s\n", result.Messages, len(result.Files), result.Dest)
+		for _, url := range result.Files {
+			fmt.Println(url)
+		}
+		if len(result.Failed) > 0 {
+			fmt.Printf("failed: %v\n", result.Failed)
+		}
+	},
+}
@@ -0,0 +1,59 @@
+package chatlog
+
+import (
+	"fmt"
+	"runtime"
+
+	"github.com/aspnmy/chatlog/internal/chatlog"
+	"github.com/aspnmy/chatlog/internal/chatlog/export"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(screenshotCmd)
+	screenshotCmd.Flags().StringVarP(&screenshotWorkDir, "work-dir", "w", "", "work dir")
+	screenshotCmd.Flags().StringVarP(&screenshotPlatform, "platform", "p", runtime.GOOS, "platform")
+	screenshotCmd.Flags().IntVarP(&screenshotVer, "version", "v", 3, "version")
+	screenshotCmd.Flags().StringVarP(&screenshotOpts.Talker, "talker", "t", "", "talker to render")
+	screenshotCmd.Flags().StringVar(&screenshotOpts.Time, "time", "", "time range, e.g. 2024-01-01~2024-01-31, rendering starts at its beginning")
+	screenshotCmd.Flags().IntVarP(&screenshotOpts.Limit, "limit", "n", export.DefaultScreenshotLimit, "max messages to render")
+	screenshotCmd.Flags().IntVar(&screenshotOpts.Width, "width", export.DefaultScreenshotWidth, "viewport width in pixels")
+	screenshotCmd.Flags().IntVar(&screenshotOpts.Height, "height", export.DefaultScreenshotHeight, "viewport height in pixels, content below it is cut off")
+	screenshotCmd.Flags().IntVar(&screenshotSynthetic, "synthetic", 0, "render this many synthetic messages instead of a real conversation, no work dir needed")
+	screenshotCmd.Flags().StringVarP(&screenshotOut, "output", "o", "screenshot.png", "output png file")
+}
+
+var (
+	screenshotWorkDir   string
+	screenshotPlatform  string
+	screenshotVer       int
+	screenshotOpts      export.ScreenshotOptions
+	screenshotSynthetic int
+	screenshotOut       string
+)
+
+var screenshotCmd = &cobra.Command{
+	Use:   "screenshot",
+	Short: "Render a conversation through the html exporter into a png for bug reports",
+	Long: `Render a conversation range as the html export would show it and save it as a png.
+The page is rendered by a local Chrome, Chromium or Edge in headless mode with a fixed
+viewport; set CHATLOG_BROWSER if the browser is not found. Text of real conversations is
+replaced with placeholder characters, so the image shows the layout without the content of
+the messages; images, videos and voices are drawn as placeholders. Use --synthetic to render
+generated messages when the layout problem does not depend on real data.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		m, err := chatlog.New("")
+		if err != nil {
+			log.Err(err).Msg("failed to create chatlog instance")
+			return
+		}
+		n, err := m.CommandScreenshot(screenshotWorkDir, screenshotPlatform, screenshotVer, screenshotOut, screenshotSynthetic, screenshotOpts)
+		if err != nil {
+			log.Err(err).Msg("failed to render screenshot")
+			return
+		}
+		fmt.Printf("rendered %d messages to %s\n", n, screenshotOut)
+	},
+}
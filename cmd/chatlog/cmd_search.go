@@ -0,0 +1,70 @@
+package chatlog
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+
+	"github.com/aspnmy/chatlog/internal/chatlog"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(searchCmd)
+	searchCmd.Flags().StringVarP(&searchWorkDir, "work-dir", "w", "", "work dir")
+	searchCmd.Flags().StringVarP(&searchPlatform, "platform", "p", runtime.GOOS, "platform")
+	searchCmd.Flags().IntVarP(&searchVer, "version", "v", 3, "version")
+	searchCmd.Flags().StringVarP(&searchTalker, "talker", "t", "", "talker, multiple separated by comma")
+	searchCmd.Flags().StringVarP(&searchSender, "sender", "s", "", "sender, multiple separated by comma")
+	searchCmd.Flags().StringVar(&searchSince, "since", "", "only messages after the start of the time range, e.g. 2024-01-01, last-7d")
+	searchCmd.Flags().IntVarP(&searchLimit, "limit", "n", 20, "max number of results, 0 for all")
+	searchCmd.Flags().BoolVar(&searchJSON, "json", false, "output as json")
+}
+
+var (
+	searchWorkDir  string
+	searchPlatform string
+	searchVer      int
+	searchTalker   string
+	searchSender   string
+	searchSince    string
+	searchLimit    int
+	searchJSON     bool
+)
+
+var searchCmd = &cobra.Command{
+	Use:   "search <keyword>...",
+	Short: "Search messages with the search index",
+	Long: `Search messages with the search index, all keywords must appear in a message.
+Without an index built by "chatlog index rebuild", messages are matched one by one
+and the keyword is a regular expression.`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		m, err := chatlog.New("")
+		if err != nil {
+			log.Err(err).Msg("failed to create chatlog instance")
+			return
+		}
+		resp, err := m.CommandSearch(searchWorkDir, searchPlatform, searchVer, strings.Join(args, " "), searchTalker, searchSender, searchSince, searchLimit)
+		if err != nil {
+			log.Err(err).Msg("failed to search messages")
+			return
+		}
+		if searchJSON {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			enc.Encode(resp)
+			return
+		}
+		for _, hit := range resp.Items {
+			fmt.Printf("[%d] %s %s %s\n%s\n\n", hit.Seq, hit.Time.Format("2006-01-02 15:04:05"),
+				cmp.Or(hit.TalkerName, hit.Talker), cmp.Or(hit.SenderName, hit.Sender), hit.Snippet.Mark("**", "**"))
+		}
+		fmt.Printf("%d of %d messages\n", len(resp.Items), resp.Total)
+	},
+}
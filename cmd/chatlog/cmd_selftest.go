@@ -0,0 +1,64 @@
+package chatlog
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aspnmy/chatlog/internal/chatlog"
+	"github.com/aspnmy/chatlog/pkg/version"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(selftestCmd)
+	selftestCmd.Flags().StringVarP(&selftestDataDir, "data-dir", "d", "", "data dir, default to the data dir of the running wechat or the last account")
+	selftestCmd.Flags().StringVarP(&selftestWorkDir, "work-dir", "w", "", "work dir, used to read a voice sample")
+	selftestCmd.Flags().StringVarP(&selftestKey, "key", "k", "", "key, default to the key saved for the data dir, never printed")
+	selftestCmd.Flags().StringVarP(&selftestPlatform, "platform", "p", "", "platform, detected when empty")
+	selftestCmd.Flags().IntVarP(&selftestVer, "version", "v", 0, "version, detected when 0")
+	selftestCmd.Flags().BoolVar(&selftestJSON, "json", false, "output as json")
+}
+
+var (
+	selftestDataDir  string
+	selftestWorkDir  string
+	selftestKey      string
+	selftestPlatform string
+	selftestVer      int
+	selftestJSON     bool
+)
+
+var selftestCmd = &cobra.Command{
+	Use:   "selftest",
+	Short: "Run an end-to-end check and print a pass/fail matrix for bug reports",
+	Long: `Run an end-to-end check in order: detect the wechat process, validate the key, decrypt
+the smallest database to a temp file, run a query on it and convert one voice from the work dir
+to mp3. The key is never printed, so the output can be pasted into bug reports as is.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		m, err := chatlog.New("")
+		if err != nil {
+			log.Err(err).Msg("failed to create chatlog instance")
+			return
+		}
+		steps := m.CommandSelftest(selftestDataDir, selftestWorkDir, selftestKey, selftestPlatform, selftestVer)
+		if selftestJSON {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			enc.Encode(steps)
+			return
+		}
+		failed := 0
+		for _, s := range steps {
+			fmt.Printf("[%-4s] %-8s %8s  %s\n", strings.ToUpper(s.Result), s.Name, s.Elapsed.Round(time.Millisecond), s.Detail)
+			if s.Result == chatlog.SelftestFail {
+				failed++
+			}
+		}
+		fmt.Printf("\nchatlog %s%d of %d steps failed\n", version.GetMore(false), failed, len(steps))
+	},
+}
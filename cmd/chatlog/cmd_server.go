@@ -16,6 +16,8 @@ func init() {
 	serverCmd.Flags().StringVarP(&serverWorkDir, "work-dir", "w", "", "work dir")
 	serverCmd.Flags().StringVarP(&serverPlatform, "platform", "p", runtime.GOOS, "platform")
 	serverCmd.Flags().IntVarP(&serverVer, "version", "v", 3, "version")
+	serverCmd.Flags().StringVar(&serverSnapshot, "serve-snapshot", "", "serve read-only from a snapshot dir created by chatlog snapshot")
+	serverCmd.Flags().BoolVar(&serverDigest, "digest", false, "send a weekly digest email while running, scheduled by the digest section of the config file, requires smtp")
 }
 
 var (
@@ -24,6 +26,8 @@ var (
 	serverWorkDir  string
 	serverPlatform string
 	serverVer      int
+	serverSnapshot string
+	serverDigest   bool
 )
 
 var serverCmd = &cobra.Command{
@@ -35,7 +39,15 @@ var serverCmd = &cobra.Command{
 			log.Err(err).Msg("failed to create chatlog instance")
 			return
 		}
-		if err := m.CommandHTTPServer(serverAddr, serverDataDir, serverWorkDir, serverPlatform, serverVer); err != nil {
+		if serverDigest {
+			m.EnableDigest()
+		}
+		if serverSnapshot != "" {
+			err = m.CommandServeSnapshot(serverAddr, serverSnapshot)
+		} else {
+			err = m.CommandHTTPServer(serverAddr, serverDataDir, serverWorkDir, serverPlatform, serverVer)
+		}
+		if err != nil {
 			log.Err(err).Msg("failed to start server")
 			return
 		}
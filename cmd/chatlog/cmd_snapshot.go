@@ -0,0 +1,49 @@
+package chatlog
+
+import (
+	"fmt"
+	"runtime"
+
+	"github.com/aspnmy/chatlog/internal/chatlog"
+	"github.com/aspnmy/chatlog/internal/chatlog/snapshot"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(snapshotCmd)
+	snapshotCmd.Flags().StringVarP(&snapshotWorkDir, "work-dir", "w", "", "work dir")
+	snapshotCmd.Flags().StringVarP(&snapshotPlatform, "platform", "p", runtime.GOOS, "platform")
+	snapshotCmd.Flags().IntVarP(&snapshotVer, "version", "v", 3, "version")
+	snapshotCmd.Flags().StringVarP(&snapshotOutput, "output", "o", "", "snapshot dir, e.g. on a NAS")
+	snapshotCmd.Flags().IntVar(&snapshotKeep, "keep", snapshot.DefaultKeep, "number of snapshots to keep")
+	snapshotCmd.Flags().BoolVar(&snapshotNotify, "notify", true, "send a summary email when finished, if smtp is configured")
+}
+
+var (
+	snapshotWorkDir  string
+	snapshotPlatform string
+	snapshotVer      int
+	snapshotOutput   string
+	snapshotKeep     int
+	snapshotNotify   bool
+)
+
+var snapshotCmd = &cobra.Command{
+	Use:   "snapshot",
+	Short: "Copy the work dir as a read-only snapshot for chatlog server --serve-snapshot",
+	Run: func(cmd *cobra.Command, args []string) {
+		m, err := chatlog.New("")
+		if err != nil {
+			log.Err(err).Msg("failed to create chatlog instance")
+			return
+		}
+		info, err := m.CommandSnapshot(snapshotWorkDir, snapshotOutput, snapshotPlatform, snapshotVer, snapshotKeep, snapshotNotify)
+		if err != nil {
+			log.Err(err).Msg("failed to create snapshot")
+			return
+		}
+		fmt.Printf("snapshot %s created in %s\n", info.Version, info.Dir(snapshotOutput))
+	},
+}
@@ -0,0 +1,55 @@
+package chatlog
+
+import (
+	"fmt"
+	"runtime"
+	"time"
+
+	"github.com/aspnmy/chatlog/internal/chatlog"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(translateCmd)
+	translateCmd.Flags().StringVarP(&translateWorkDir, "work-dir", "w", "", "work dir")
+	translateCmd.Flags().StringVarP(&translatePlatform, "platform", "p", runtime.GOOS, "platform")
+	translateCmd.Flags().IntVarP(&translateVer, "version", "v", 3, "version")
+	translateCmd.Flags().StringVarP(&translateTalker, "talker", "t", "", "talker, multiple separated by comma, empty for all sessions")
+	translateCmd.Flags().StringVar(&translateTime, "time", "", "time range, e.g. 2024-01-01~2024-12-31")
+	translateCmd.Flags().StringVarP(&translateLang, "lang", "l", "", "target language, e.g. en, defaults to translate.lang in the config file")
+}
+
+var (
+	translateWorkDir  string
+	translatePlatform string
+	translateVer      int
+	translateTalker   string
+	translateTime     string
+	translateLang     string
+)
+
+var translateCmd = &cobra.Command{
+	Use:   "translate",
+	Short: "Translate text messages with the configured provider and store the translations in the work dir",
+	Run: func(cmd *cobra.Command, args []string) {
+		m, err := chatlog.New("")
+		if err != nil {
+			log.Err(err).Msg("failed to create chatlog instance")
+			return
+		}
+		result, err := m.CommandTranslate(translateWorkDir, translatePlatform, translateVer, translateTalker, translateTime, translateLang)
+		if err != nil {
+			log.Err(err).Msg("failed to translate messages")
+			return
+		}
+		fmt.Printf("%d messages of %d talkers translated into %s in %s\n", result.Messages, result.Talkers, result.Lang, result.Duration.Round(time.Millisecond))
+		if result.Missing > 0 {
+			fmt.Printf("%d messages not translated, run again to retry\n", result.Missing)
+		}
+		if len(result.Failed) > 0 {
+			fmt.Printf("failed: %v\n", result.Failed)
+		}
+	},
+}
@@ -0,0 +1,29 @@
+package chatlog
+
+import (
+	"github.com/aspnmy/chatlog/internal/chatlog"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(trayCmd)
+}
+
+var trayCmd = &cobra.Command{
+	Use:    "tray",
+	Short:  "Run in system tray (Windows only)",
+	PreRun: initTuiLog,
+	Run: func(cmd *cobra.Command, args []string) {
+		m, err := chatlog.New("")
+		if err != nil {
+			log.Err(err).Msg("failed to create chatlog instance")
+			return
+		}
+		if err := m.CommandTray(); err != nil {
+			log.Err(err).Msg("failed to run tray")
+			return
+		}
+	},
+}
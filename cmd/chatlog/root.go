@@ -1,10 +1,23 @@
 package chatlog
 
 import (
+	"fmt"
+	"os"
+	"strings"
+
 	"github.com/aspnmy/chatlog/internal/chatlog"
+	"github.com/aspnmy/chatlog/internal/chatlog/conf"
+	"github.com/aspnmy/chatlog/internal/wechat/decrypt"
+	"github.com/aspnmy/chatlog/internal/wechat/decrypt/common"
+	"github.com/aspnmy/chatlog/internal/wechat/key/windows"
+	"github.com/aspnmy/chatlog/pkg/membudget"
+	"github.com/aspnmy/chatlog/pkg/memscan"
+	"github.com/aspnmy/chatlog/pkg/throttle"
+	"github.com/aspnmy/chatlog/pkg/trace"
 
 	"github.com/rs/zerolog/log"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 )
 
 func init() {
@@ -12,13 +25,169 @@ func init() {
 	cobra.MousetrapHelpText = ""
 
 	rootCmd.PersistentFlags().BoolVar(&Debug, "debug", false, "debug")
-	rootCmd.PersistentPreRun = initLog
+	rootCmd.PersistentFlags().StringVar(&MaxMem, "max-mem", "", "memory budget for decrypt, scan, index and export buffers, e.g. 2G, empty for unlimited")
+	rootCmd.PersistentFlags().IntVar(&Workers, "workers", 0, "number of concurrent decrypt/index/export workers, 0 for cpu count")
+	rootCmd.PersistentFlags().StringVar(&Priority, "priority", throttle.PriorityNormal, "process priority: low, normal")
+	rootCmd.PersistentFlags().StringVar(&IOLimit, "io-limit", "", "disk write limit per second for background jobs, e.g. 20M, empty for unlimited")
+	rootCmd.PersistentFlags().StringVar(&Trace, "trace", "", "write timing traces to a file (OTLP JSON lines) or send to an OTLP/HTTP endpoint, e.g. http://localhost:4318")
+	rootCmd.PersistentFlags().StringSliceVar(&Strategies, "strategies", nil, "wechat 4.x key search strategies, e.g. base_pattern,weixin_dll, prefix with - to exclude one, available: "+strings.Join(windows.Strategies(), ","))
+	rootCmd.PersistentFlags().StringVar(&ScanChunk, "scan-chunk", "16M", "chunk size for reading process memory during key search, 0 to read whole regions")
+	rootCmd.PersistentFlags().StringVar(&ScanOverlap, "scan-overlap", "4K", "overlap between adjacent memory chunks so patterns on chunk boundaries are not missed")
+	rootCmd.PersistentFlags().StringVar(&CipherProfile, "cipher-profile", "", "override SQLCipher parameters for key validation and decryption, a preset ("+strings.Join(common.CipherProfileNames(), ", ")+") and/or page_size=,kdf_iter=,hmac=sha1|sha256|sha512,cipher=aes-256-cbc")
+	rootCmd.PersistentFlags().StringVar(&chatlog.Account, "account", "", "use this saved account instead of the last one, see \"chatlog accounts\"")
+	rootCmd.PersistentFlags().BoolVar(&chatlog.ArchiveOnly, "archive-only", false, "use only the decrypted data in the work dir, never look for wechat processes or read the wechat data dir")
+	rootCmd.PersistentPreRun = func(cmd *cobra.Command, args []string) {
+		flagErrs := initFlags(cmd)
+		initLog(cmd, args)
+		for _, err := range flagErrs {
+			log.Warn().Err(err).Msg("ignored a flag value from the environment or the config file")
+		}
+		initMemBudget()
+		initMemScan()
+		initThrottle()
+		initTrace()
+		initStrategies()
+		initCipherProfile()
+	}
+}
+
+var (
+	MaxMem   string
+	Workers  int
+	Priority string
+	IOLimit  string
+	Trace    string
+
+	Strategies []string
+
+	ScanChunk   string
+	ScanOverlap string
+
+	CipherProfile string
+)
+
+// initFlags 未在命令行中指定的参数依次使用 --account 账号保存的数据目录、工作目录、平台、版本与密钥，
+// 环境变量 CHATLOG_<参数名>，配置文件 flags 与 config.yaml 中的值
+// chatlog config 下的命令管理配置文件本身，只使用环境变量；配置文件已加密时不询问口令，见 conf.FlagDefaults；
+// 无效的值被忽略，返回对应的错误
+func initFlags(cmd *cobra.Command) []error {
+	var errs []error
+	var saved map[string]string
+	if !isConfigCmd(cmd) {
+		var err error
+		if saved, err = conf.FlagDefaults("", false); err != nil {
+			errs = append(errs, err)
+		}
+		errs = append(errs, initAccountFlags(cmd)...)
+	}
+	cmd.Flags().VisitAll(func(f *pflag.Flag) {
+		if f.Changed {
+			return
+		}
+		value, source := saved[f.Name], conf.SourceFile
+		if v, ok := os.LookupEnv(conf.FlagEnv(f.Name)); ok {
+			value, source = v, conf.SourceEnv
+		} else if _, ok := saved[f.Name]; !ok {
+			return
+		}
+		if err := f.Value.Set(value); err != nil {
+			errs = append(errs, fmt.Errorf("--%s from %s: %w", f.Name, source, err))
+		}
+	})
+	return errs
+}
+
+// initAccountFlags 使用 --account 账号保存的参数填充命令行中未指定的参数，填充后视为已指定，
+// 不再使用环境变量与配置文件中的值
+func initAccountFlags(cmd *cobra.Command) []error {
+	if chatlog.Account == "" {
+		return nil
+	}
+	account, err := conf.AccountFlags("", chatlog.Account, false)
+	if err != nil {
+		return []error{err}
+	}
+	var errs []error
+	cmd.Flags().VisitAll(func(f *pflag.Flag) {
+		value, ok := account[f.Name]
+		if !ok || f.Changed {
+			return
+		}
+		if err := cmd.Flags().Set(f.Name, value); err != nil {
+			errs = append(errs, fmt.Errorf("--%s from account %s: %w", f.Name, chatlog.Account, err))
+		}
+	})
+	return errs
+}
+
+func isConfigCmd(cmd *cobra.Command) bool {
+	for c := cmd; c != nil; c = c.Parent() {
+		if c == configCmd {
+			return true
+		}
+	}
+	return false
+}
+
+func initMemBudget() {
+	limit, err := membudget.ParseSize(MaxMem)
+	if err != nil {
+		log.Err(err).Msg("invalid --max-mem, memory budget disabled")
+		return
+	}
+	membudget.Default.SetLimit(limit)
+}
+
+func initMemScan() {
+	chunk, err := membudget.ParseSize(ScanChunk)
+	if err != nil {
+		log.Err(err).Msg("invalid --scan-chunk, using default chunk size")
+	} else {
+		memscan.ChunkSize = chunk
+	}
+	overlap, err := membudget.ParseSize(ScanOverlap)
+	if err != nil {
+		log.Err(err).Msg("invalid --scan-overlap, using default overlap")
+	} else {
+		memscan.Overlap = overlap
+	}
+}
+
+func initThrottle() {
+	throttle.SetWorkers(Workers)
+	if err := throttle.SetPriority(Priority); err != nil {
+		log.Err(err).Msg("failed to set process priority")
+	}
+	rate, err := membudget.ParseSize(IOLimit)
+	if err != nil {
+		log.Err(err).Msg("invalid --io-limit, io throttling disabled")
+		return
+	}
+	throttle.Default.SetRate(rate)
+}
+
+func initStrategies() {
+	if err := windows.SetEnabledStrategies(Strategies); err != nil {
+		log.Err(err).Msg("invalid --strategies, using all key search strategies")
+	}
+}
+
+func initCipherProfile() {
+	info, err := common.ParseCipherProfile(CipherProfile)
+	if err != nil {
+		log.Err(err).Msg("invalid --cipher-profile, using default cipher parameters")
+		return
+	}
+	decrypt.DefaultCipher = info
 }
 
 func Execute() {
 	if err := rootCmd.Execute(); err != nil {
 		log.Err(err).Msg("command execution failed")
 	}
+	if err := trace.Shutdown(); err != nil {
+		log.Err(err).Msg("failed to flush traces")
+	}
 }
 
 var rootCmd = &cobra.Command{
@@ -46,3 +215,14 @@ func Root(cmd *cobra.Command, args []string) {
 		log.Err(err).Msg("failed to run chatlog instance")
 	}
 }
+
+func initTrace() {
+	if Trace == "" {
+		return
+	}
+	if err := trace.Init(Trace); err != nil {
+		log.Err(err).Msg("failed to init trace")
+		return
+	}
+	log.Info().Msgf("trace enabled, writing to %s", Trace)
+}
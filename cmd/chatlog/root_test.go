@@ -0,0 +1,54 @@
+package chatlog
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/aspnmy/chatlog/internal/chatlog"
+	"github.com/aspnmy/chatlog/internal/chatlog/conf"
+)
+
+func TestAccountFlags(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv(conf.EnvPassphrase, "")
+	// --account 保存的参数优先于环境变量
+	t.Setenv(conf.FlagEnv("work-dir"), "/env")
+	dir, workDir := t.TempDir(), t.TempDir()
+	t.Setenv(conf.EnvConfigDir, dir)
+	content := `{"last_account": "wxid_a", "history": [
+		{"account": "wxid_a", "platform": "windows", "version": 3, "work_dir": "/a"},
+		{"account": "wxid_b", "platform": "darwin", "version": 4, "data_dir": "/data/b", "work_dir": ` + strconv.Quote(workDir) + `}
+	]}`
+	if err := os.WriteFile(filepath.Join(dir, "chatlog.json"), []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	// 日志写入标准错误，记录下来检查命令使用的参数
+	stderr, err := os.Create(filepath.Join(t.TempDir(), "stderr"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stderr.Close()
+	saved := os.Stderr
+	os.Stderr = stderr
+	t.Cleanup(func() {
+		os.Stderr = saved
+		chatlog.Account = ""
+	})
+
+	rootCmd.SetArgs([]string{"--account", "wxid_b", "export", "-o", filepath.Join(t.TempDir(), "out")})
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	if exportWorkDir != workDir || exportPlatform != "darwin" || exportVer != 4 || exportOpts.DataDir != "/data/b" {
+		t.Errorf("work dir = %s, platform = %s, version = %d, data dir = %s", exportWorkDir, exportPlatform, exportVer, exportOpts.DataDir)
+	}
+	out, _ := os.ReadFile(stderr.Name())
+	// 工作目录中没有数据库，命令在打开数据库时失败，而不是缺少参数
+	if strings.Contains(string(out), "is required") || !strings.Contains(string(out), workDir) {
+		t.Errorf("export did not open the account's work dir:\n%s", out)
+	}
+}
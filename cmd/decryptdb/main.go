@@ -0,0 +1,181 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+
+	"github.com/aspnmy/chatlog/internal/errors"
+	"github.com/aspnmy/chatlog/internal/wechat"
+	"github.com/aspnmy/chatlog/internal/wechat/decrypt"
+	"github.com/aspnmy/chatlog/internal/wechat/decrypt/common"
+)
+
+func main() {
+	// 初始化日志
+	zerolog.TimeFieldFormat = zerolog.TimeFormatUnix
+	log.Logger = log.Output(zerolog.ConsoleWriter{Out: os.Stderr})
+
+	// 解析命令行参数
+	dataDir := flag.String("data-dir", "", "微信数据目录，为空时自动查找")
+	key := flag.String("key", "", "数据库密钥（十六进制），可通过 v4getKey 或 chatlog key 获取")
+	outputDir := flag.String("output-dir", "", "输出目录，保留与数据目录相同的结构")
+	platform := flag.String("platform", runtime.GOOS, "数据来源平台 windows/darwin")
+	version := flag.Int("version", 0, "微信版本 3/4，0 表示根据数据目录判断")
+	workers := flag.Int("workers", 2, "同时解密的数据库文件数")
+	pageWorkers := flag.Int("page-workers", runtime.NumCPU(), "单个数据库文件内并发解密的页面数")
+	flag.Parse()
+
+	if *key == "" || *outputDir == "" {
+		fmt.Println("使用方法: decryptdb -key <数据库密钥> -output-dir <输出目录> [-data-dir <微信数据目录>]")
+		fmt.Println("示例: decryptdb -key 0123...cdef -data-dir D:\\xwechat_files\\wxid_xxx -output-dir D:\\decrypted")
+		os.Exit(1)
+	}
+
+	// 未指定数据目录或版本时，按 chatlog decrypt 相同的规则查找
+	platformSet := false
+	flag.Visit(func(f *flag.Flag) { platformSet = platformSet || f.Name == "platform" })
+	if *dataDir == "" {
+		d, err := wechat.DefaultDataDir(*version)
+		if err != nil {
+			log.Err(err).Msg("查找数据目录失败，请通过 -data-dir 指定")
+			os.Exit(1)
+		}
+		*dataDir, *version = d.Dir, d.Version
+		if !platformSet {
+			*platform = d.Platform
+		}
+		log.Info().Msgf("使用数据目录 %s（%s %d.x）", d.Dir, d.Platform, d.Version)
+	} else if *version == 0 {
+		for _, d := range wechat.LocateDataDirs([]string{filepath.Dir(*dataDir)}) {
+			if filepath.Clean(d.Dir) == filepath.Clean(*dataDir) {
+				*version = d.Version
+			}
+		}
+		if *version == 0 {
+			log.Error().Msgf("无法判断 %s 的微信版本，请通过 -version 指定", *dataDir)
+			os.Exit(1)
+		}
+	}
+	if _, err := decrypt.NewDecryptor(*platform, *version); err != nil {
+		log.Err(err).Msg("不支持的平台或版本")
+		os.Exit(1)
+	}
+	common.PageWorkers = *pageWorkers
+
+	files, err := listDBFiles(*dataDir)
+	if err != nil {
+		log.Err(err).Msgf("读取数据目录 %s 失败", *dataDir)
+		os.Exit(1)
+	}
+	if len(files) == 0 {
+		log.Error().Msgf("数据目录 %s 中没有数据库文件", *dataDir)
+		os.Exit(1)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	var failed int64
+	sem := make(chan struct{}, max(*workers, 1))
+	var wg sync.WaitGroup
+	for _, file := range files {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(file string) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			rel, _ := filepath.Rel(*dataDir, file)
+			if err := decryptFile(ctx, *platform, *version, *key, file, filepath.Join(*outputDir, rel)); err != nil {
+				atomic.AddInt64(&failed, 1)
+				log.Err(err).Msgf("解密 %s 失败", rel)
+				return
+			}
+			log.Info().Msgf("已解密 %s", rel)
+		}(file)
+	}
+	wg.Wait()
+
+	log.Info().Msgf("共 %d 个数据库，失败 %d 个，输出目录 %s", len(files), failed, *outputDir)
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
+// listDBFiles 返回数据目录下的所有数据库文件，跳过全文索引（fts）目录
+func listDBFiles(dir string) ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if strings.EqualFold(d.Name(), "fts") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if strings.HasSuffix(strings.ToLower(d.Name()), ".db") {
+			files = append(files, path)
+		}
+		return nil
+	})
+	return files, err
+}
+
+// decryptFile 将 src 解密为 dst，先写入临时文件，成功后再替换，未加密的数据库直接复制
+func decryptFile(ctx context.Context, platform string, version int, key, src, dst string) (err error) {
+	decryptor, err := decrypt.NewDecryptor(platform, version)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+
+	tmp := dst + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			f.Close()
+			os.Remove(tmp)
+		}
+	}()
+
+	w := bufio.NewWriterSize(f, 1<<20)
+	if err = decryptor.Decrypt(ctx, src, key, w); err == errors.ErrAlreadyDecrypted {
+		var in *os.File
+		if in, err = os.Open(src); err != nil {
+			return err
+		}
+		_, err = io.Copy(w, in)
+		in.Close()
+	}
+	if err != nil {
+		return err
+	}
+	if err = w.Flush(); err != nil {
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, dst)
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"runtime"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+
+	"github.com/aspnmy/chatlog/internal/wechat/media"
+)
+
+func main() {
+	// 初始化日志
+	zerolog.TimeFieldFormat = zerolog.TimeFormatUnix
+	log.Logger = log.Output(zerolog.ConsoleWriter{Out: os.Stderr})
+
+	// 解析命令行参数
+	src := flag.String("src", "", "图片目录，例如 3.x 的 FileStorage\\MsgAttach 或 4.x 的 msg\\attach")
+	dst := flag.String("dst", "", "输出目录，保留与 -src 相同的目录结构")
+	imgKey := flag.String("img-key", "", "4.x 图片密钥（十六进制），可通过 v4getKey 获取，3.x 不需要")
+	dataDir := flag.String("data-dir", "", "用于推算 4.x XOR 密钥的目录，为空时使用 -src")
+	workers := flag.Int("workers", runtime.NumCPU(), "并发解密的文件数")
+	overwrite := flag.Bool("overwrite", false, "覆盖已存在的输出文件，默认跳过")
+	thumbs := flag.Bool("thumbs", false, "同时转换 _t.dat 缩略图与 _h.dat 预览图")
+	verbose := flag.Bool("v", false, "输出每个失败文件的原因")
+	flag.Parse()
+
+	if *src == "" || *dst == "" {
+		fmt.Println("使用方法: decryptimg -src <图片目录> -dst <输出目录> [-img-key <图片密钥>]")
+		fmt.Println("示例: decryptimg -src D:\\xwechat_files\\wxid_xxx\\msg\\attach -dst D:\\images -img-key 0123456789abcdef0123456789abcdef")
+		os.Exit(1)
+	}
+
+	scanDir := *dataDir
+	if scanDir == "" {
+		scanDir = *src
+	}
+	if err := media.Configure(*imgKey, scanDir); err != nil {
+		log.Err(err).Msg("设置图片密钥失败")
+		os.Exit(1)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	opts := media.Options{
+		Workers:    *workers,
+		Overwrite:  *overwrite,
+		SkipThumbs: !*thumbs,
+	}
+	stats, err := media.ConvertTree(ctx, *src, *dst, opts, func(path string, err error) {
+		if err != nil && *verbose {
+			log.Warn().Err(err).Msgf("解密 %s 失败", path)
+		}
+	})
+	if stats != nil {
+		log.Info().Msgf("转换 %d 个，跳过 %d 个，失败 %d 个", stats.Converted, stats.Skipped, stats.Failed)
+	}
+	if err != nil {
+		log.Err(err).Msg("转换中断")
+		os.Exit(1)
+	}
+	if stats.Failed > 0 && stats.Converted == 0 {
+		os.Exit(1)
+	}
+}
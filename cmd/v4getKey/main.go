@@ -2,18 +2,48 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"flag"
 	"fmt"
+	"maps"
 	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
+	gopsutil "github.com/shirou/gopsutil/v4/process"
 
+	"github.com/aspnmy/chatlog/internal/wechat"
 	"github.com/aspnmy/chatlog/internal/wechat/decrypt"
+	"github.com/aspnmy/chatlog/internal/wechat/key"
 	"github.com/aspnmy/chatlog/internal/wechat/key/windows"
 	"github.com/aspnmy/chatlog/internal/wechat/model"
+	"github.com/aspnmy/chatlog/internal/wechat/process"
+	"github.com/aspnmy/chatlog/pkg/membudget"
+	"github.com/aspnmy/chatlog/pkg/memscan"
+	"golang.org/x/term"
 )
 
+const (
+	outputText = "text"
+	outputJSON = "json"
+)
+
+// result 为 -output json 时输出的提取结果，便于脚本处理
+type result struct {
+	PID        int    `json:"pid"`
+	Account    string `json:"account,omitempty"`  // 仅 -all 时输出
+	DataDir    string `json:"data_dir,omitempty"` // 仅 -all 时输出
+	DataKey    string `json:"data_key,omitempty"`
+	ImgKey     string `json:"img_key,omitempty"`
+	Strategy   string `json:"strategy,omitempty"` // 找到密钥的搜索策略，多个时以逗号分隔
+	DurationMs int64  `json:"duration_ms"`
+	Error      string `json:"error,omitempty"` // 提取中断或失败时的原因，已找到的密钥仍会输出
+}
+
 func main() {
 	// 初始化日志
 	zerolog.TimeFieldFormat = zerolog.TimeFormatUnix
@@ -22,10 +52,70 @@ func main() {
 	// 解析命令行参数
 	pid := flag.Int("pid", 0, "微信进程PID")
 	dataDir := flag.String("data-dir", ".", "微信数据目录路径")
+	auto := flag.Bool("auto", false, "自动查找正在运行的微信 4.x 进程及其数据目录，无需指定 -pid 与 -data-dir")
+	all := flag.Bool("all", false, "依次提取所有已登录微信账号的密钥，每个密钥与对应的数据目录一起输出")
+	timeout := flag.Duration("timeout", 0, "提取超时时间，例如 5m，0 表示不限制")
+	maxScan := flag.String("max-memory-scan", "", "最多扫描的进程内存大小，例如 4G，为空表示不限制")
+	output := flag.String("output", outputText, "输出格式 text/json，json 时标准输出只有一行结果")
 	flag.Parse()
 
+	if *output != outputText && *output != outputJSON {
+		log.Error().Msgf("无效的 -output %s，可选 text 或 json", *output)
+		os.Exit(1)
+	}
+
+	scanLimit, err := membudget.ParseSize(*maxScan)
+	if err != nil {
+		log.Err(err).Msg("无效的 -max-memory-scan")
+		os.Exit(1)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	if *timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *timeout)
+		defer cancel()
+	}
+
+	if *all {
+		if !extractAll(ctx, uint64(scanLimit), *output) {
+			os.Exit(1)
+		}
+		return
+	}
+
+	// 显式指定的 -data-dir 优先
+	dataDirSet := false
+	flag.Visit(func(f *flag.Flag) { dataDirSet = dataDirSet || f.Name == "data-dir" })
+
+	if *auto && *pid == 0 {
+		proc, err := findWeChat()
+		if err != nil {
+			log.Err(err).Msg("自动查找微信进程失败")
+			os.Exit(1)
+		}
+		*pid = int(proc.PID)
+		if !dataDirSet {
+			if proc.DataDir == "" {
+				log.Error().Msgf("无法确定微信进程 %d 的数据目录，微信可能尚未登录，请通过 -data-dir 指定", proc.PID)
+				os.Exit(1)
+			}
+			*dataDir = proc.DataDir
+		}
+		log.Info().Msgf("使用微信进程 %d %s，数据目录 %s", proc.PID, proc.AccountName, *dataDir)
+	}
+
+	// 未指定数据目录时查找默认位置、微信设置的保存位置与运行中微信打开的目录
+	if !dataDirSet && !*auto {
+		if d, err := wechat.DefaultDataDir(4); err == nil {
+			*dataDir = d.Dir
+			log.Info().Msgf("使用数据目录 %s", d.Dir)
+		}
+	}
+
 	if *pid == 0 {
-		fmt.Println("请指定微信进程PID")
+		fmt.Println("请指定微信进程PID，或使用 -auto 自动查找")
 		fmt.Println("使用方法: v4getKey -pid <进程ID> -data-dir <微信数据目录>")
 		fmt.Println("示例: v4getKey -pid 13676 -data-dir C:\\Users\\用户名\\Documents\\WeChat Files")
 		os.Exit(1)
@@ -37,12 +127,20 @@ func main() {
 	// 创建验证器
 	validator, err := decrypt.NewValidator("windows", 4, *dataDir)
 	if err != nil {
-		log.Err(err).Msgf("创建验证器失败，请确保指定的微信数据目录包含 db_storage\\message\\message_0.db 文件")
+		log.Err(err).Msgf("创建验证器失败，请确保指定的微信数据目录包含 db_storage 中的加密数据库（如 message\\message_0.db、contact\\contact.db）")
 		fmt.Println("使用方法: v4getKey -pid <进程ID> -data-dir <微信数据目录>")
 		fmt.Println("示例: v4getKey -pid 13676 -data-dir C:\\Users\\用户名\\Documents\\WeChat Files")
 		os.Exit(1)
 	}
 	extractor.SetValidate(validator)
+	extractor.SetScanLimit(uint64(scanLimit))
+
+	// 终端中显示内存扫描进度
+	var bar *memscan.Bar
+	if term.IsTerminal(int(os.Stderr.Fd())) {
+		bar = memscan.NewBar(os.Stderr, 200*time.Millisecond)
+		extractor.SetProgressFunc(bar.Update)
+	}
 
 	// 创建进程信息
 	proc := &model.Process{
@@ -50,15 +148,40 @@ func main() {
 		Status: model.StatusOnline,
 	}
 
-	// 提取密钥
-	ctx := context.Background()
+	// 提取密钥，超时或按 Ctrl+C 时输出已找到的密钥
+	begin := time.Now()
 	dataKey, imgKey, err := extractor.Extract(ctx, proc)
+	if bar != nil {
+		bar.Done()
+	}
 	if err != nil {
 		log.Err(err).Msg("提取密钥失败")
-		os.Exit(1)
 	}
 
 	// 输出结果
+	if *output == outputJSON {
+		r := result{
+			PID:        *pid,
+			DataKey:    dataKey,
+			ImgKey:     imgKey,
+			Strategy:   strings.Join(slices.Sorted(maps.Keys(extractor.Hits())), ","),
+			DurationMs: time.Since(begin).Milliseconds(),
+		}
+		if err != nil {
+			r.Error = err.Error()
+		} else if dataKey == "" && imgKey == "" {
+			r.Error = "未找到有效密钥"
+		}
+		json.NewEncoder(os.Stdout).Encode(r)
+		if r.Error != "" {
+			os.Exit(1)
+		}
+		return
+	}
+
+	if err != nil && dataKey == "" && imgKey == "" {
+		os.Exit(1)
+	}
 	fmt.Println("=== Windows V4 微信密钥提取结果 ===")
 	if dataKey != "" {
 		fmt.Printf("数据密钥: %s\n", dataKey)
@@ -70,4 +193,119 @@ func main() {
 		fmt.Println("未找到有效密钥")
 		os.Exit(1)
 	}
+	if err != nil {
+		// 提取中断，结果可能不完整
+		os.Exit(1)
+	}
+}
+
+// extractAll 提取所有已登录账号的密钥并输出，全部成功时返回 true
+// 超时或按 Ctrl+C 时停止处理剩余的账号，已完成的结果仍会输出
+func extractAll(ctx context.Context, scanLimit uint64, output string) bool {
+	var bar *memscan.Bar
+	configure := func(ex key.Extractor, p *model.Process) {
+		if v4, ok := ex.(*windows.V4Extractor); ok {
+			v4.SetScanLimit(scanLimit)
+			if term.IsTerminal(int(os.Stderr.Fd())) {
+				if bar != nil {
+					bar.Done()
+				}
+				bar = memscan.NewBar(os.Stderr, 200*time.Millisecond)
+				v4.SetProgressFunc(bar.Update)
+			}
+		}
+		log.Info().Msgf("提取微信进程 %d %s 的密钥，数据目录 %s", p.PID, key.AccountOf(p), p.DataDir)
+	}
+	results, err := key.ExtractAll(ctx, configure)
+	if bar != nil {
+		bar.Done()
+	}
+	if err != nil && len(results) == 0 {
+		log.Err(err).Msg("提取密钥失败")
+		return false
+	}
+	if err != nil {
+		log.Err(err).Msg("提取中断，剩余的账号未处理")
+	}
+
+	ok := err == nil
+	out := make([]result, 0, len(results))
+	for _, account := range slices.Sorted(maps.Keys(results)) {
+		r := results[account]
+		item := result{
+			PID:        int(r.Process.PID),
+			Account:    account,
+			DataDir:    r.Process.DataDir,
+			DataKey:    r.DataKey,
+			ImgKey:     r.ImgKey,
+			Strategy:   r.Strategy,
+			DurationMs: r.Duration.Milliseconds(),
+		}
+		if r.Err != nil {
+			item.Error = r.Err.Error()
+		} else if r.DataKey == "" && r.ImgKey == "" {
+			item.Error = "未找到有效密钥"
+		}
+		if item.Error != "" {
+			ok = false
+		}
+		out = append(out, item)
+	}
+
+	if output == outputJSON {
+		json.NewEncoder(os.Stdout).Encode(out)
+		return ok
+	}
+	for _, r := range out {
+		fmt.Printf("=== %s (PID %d) ===\n", r.Account, r.PID)
+		fmt.Printf("数据目录: %s\n", r.DataDir)
+		if r.DataKey != "" {
+			fmt.Printf("数据密钥: %s\n", r.DataKey)
+		}
+		if r.ImgKey != "" {
+			fmt.Printf("图片密钥: %s\n", r.ImgKey)
+		}
+		if r.Error != "" {
+			fmt.Printf("错误: %s\n", r.Error)
+		}
+		fmt.Println()
+	}
+	return ok
+}
+
+// findWeChat 查找正在运行的微信 4.x 进程，数据目录来自进程打开的数据库文件
+// 有多个进程时优先选择已登录（打开了数据库）的进程，其次选择最近启动的进程
+func findWeChat() (*model.Process, error) {
+	processes, err := process.NewDetector(model.PlatformWindows).FindProcesses()
+	if err != nil {
+		return nil, err
+	}
+
+	var best *model.Process
+	var bestCreated int64
+	for _, p := range processes {
+		if p.Version != 4 {
+			continue
+		}
+		var created int64
+		if gp, err := gopsutil.NewProcess(int32(p.PID)); err == nil {
+			created, _ = gp.CreateTime()
+		}
+		if best == nil || better(p, created, best, bestCreated) {
+			best, bestCreated = p, created
+		}
+	}
+	if best == nil {
+		return nil, fmt.Errorf("未找到运行中的微信 4.x 进程")
+	}
+	return best, nil
+}
+
+// better 判断进程 a 是否比 b 更适合提取密钥
+func better(a *model.Process, aCreated int64, b *model.Process, bCreated int64) bool {
+	aOnline, bOnline := a.Status == model.StatusOnline, b.Status == model.StatusOnline
+	if aOnline != bOnline {
+		return aOnline
+	}
+	return aCreated > bCreated
 }
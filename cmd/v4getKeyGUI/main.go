@@ -2,19 +2,28 @@ package main
 
 import (
 	"bufio"
+	"cmp"
 	"context"
+	"flag"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 
+	"github.com/aspnmy/chatlog/internal/wechat"
 	"github.com/aspnmy/chatlog/internal/wechat/decrypt"
 	"github.com/aspnmy/chatlog/internal/wechat/key/windows"
 	"github.com/aspnmy/chatlog/internal/wechat/model"
+	"github.com/aspnmy/chatlog/pkg/keybag"
+	"github.com/aspnmy/chatlog/pkg/memscan"
 	"github.com/shirou/gopsutil/v4/process"
+	"golang.org/x/term"
 )
 
 func init() {
@@ -23,89 +32,141 @@ func init() {
 	log.Logger = log.Output(zerolog.ConsoleWriter{Out: os.Stderr})
 }
 
+var (
+	pidFlag        = flag.Int("pid", 0, "微信进程PID，不指定时使用上次选择的微信或唯一的微信进程")
+	dataDirFlag    = flag.String("data-dir", "", "微信数据目录，不指定时使用上次的目录")
+	formatFlag     = flag.String("format", "", "输出格式 text/json")
+	outputFlag     = flag.String("output", "", "结果同时保存到的文件，- 表示不保存")
+	nonInteractive = flag.Bool("non-interactive", false, "不询问，只使用参数与上次的设置；标准输入不是终端时自动启用")
+	batchFlag      = flag.Bool("batch", false, "批处理模式，供计划任务等没有终端的程序调用：不询问，不读取也不修改上次的设置，只使用 -pid、-data-dir、-out 等参数")
+)
+
+func init() {
+	flag.StringVar(outputFlag, "out", "", "同 -output")
+}
+
+var (
+	// interactive 为 true 时询问用户，并在退出前等待回车，双击运行时窗口不会立即关闭
+	interactive bool
+	reader      = bufio.NewReader(os.Stdin)
+
+	// discoveredDataDir 自动查找到的微信 4.x 数据目录，没有上次的设置时作为默认值
+	discoveredDataDir string
+
+	// info 输出提示信息，非交互模式下写到标准错误，标准输出只有提取结果，便于脚本处理
+	info io.Writer = os.Stdout
+)
+
 func main() {
-	fmt.Println("========================================")
-	fmt.Println("微信V4密钥提取工具")
-	fmt.Println("========================================")
-	fmt.Println()
+	flag.Parse()
+	interactive = !*nonInteractive && !*batchFlag && isTerminal(os.Stdin)
+	if !interactive {
+		info = os.Stderr
+	}
+
+	fmt.Fprintln(info, "========================================")
+	fmt.Fprintln(info, "微信V4密钥提取工具")
+	fmt.Fprintln(info, "========================================")
+	fmt.Fprintln(info)
+
+	// 批处理模式下每次运行的结果只取决于参数，不受上次手动运行的影响
+	settings := &Settings{Format: FormatText}
+	if !*batchFlag {
+		settings = loadSettings()
+	}
+	if *dataDirFlag != "" {
+		settings.DataDir = *dataDirFlag
+	}
+	if *formatFlag != "" {
+		settings.Format = *formatFlag
+	}
+	if *outputFlag != "" {
+		settings.OutputFile = strings.TrimPrefix(*outputFlag, "-")
+	}
+	if settings.Format != FormatText && settings.Format != FormatJSON {
+		fail("无效的输出格式 - %s", settings.Format)
+	}
+	// 没有上次的设置时，自动查找数据目录作为默认值
+	if settings.DataDir == "" {
+		if d, err := wechat.DefaultDataDir(4); err == nil {
+			discoveredDataDir = d.Dir
+		}
+	}
 
 	// 1. 获取微信进程列表
-	fmt.Println("1. 正在获取微信进程列表...")
+	fmt.Fprintln(info, "1. 正在获取微信进程列表...")
 	processes, err := getWeChatProcesses()
 	if err != nil {
-		fmt.Printf("错误: 获取进程列表失败 - %v\n", err)
-		os.Exit(1)
+		fail("获取进程列表失败 - %v", err)
 	}
 
 	if len(processes) == 0 {
-		fmt.Println("错误: 未找到微信进程")
-		os.Exit(1)
+		fail("未找到微信进程")
 	}
 
 	// 显示进程列表
-	fmt.Println("微信进程列表:")
-	for i, pid := range processes {
-		fmt.Printf("  %d. PID: %s\n", i+1, pid)
-	}
-
-	// 2. 选择微信进程
-	fmt.Println()
-	fmt.Print("请选择微信进程 (输入编号): ")
-	reader := bufio.NewReader(os.Stdin)
-	input, err := reader.ReadString('\n')
-	if err != nil {
-		fmt.Printf("错误: 读取输入失败 - %v\n", err)
-		os.Exit(1)
+	fmt.Fprintln(info, "微信进程列表:")
+	for i, p := range processes {
+		fmt.Fprintf(info, "  %d. PID: %d %s %s\n", i+1, p.PID, p.Name, p.ExePath)
 	}
-
-	// 解析输入
-	selection, err := strconv.Atoi(input[:len(input)-1])
-	if err != nil || selection < 1 || selection > len(processes) {
-		fmt.Println("错误: 无效的选择")
-		os.Exit(1)
+	selection := defaultProcess(processes, settings)
+	if *pidFlag != 0 {
+		selection = 0
+		for i, p := range processes {
+			if int(p.PID) == *pidFlag {
+				selection = i + 1
+			}
+		}
+		if selection == 0 {
+			fail("未找到 PID 为 %d 的微信进程", *pidFlag)
+		}
 	}
 
-	// 获取选中的PID
-	pidStr := processes[selection-1]
-	pid, err := strconv.Atoi(pidStr)
-	if err != nil {
-		fmt.Printf("错误: 无效的PID - %v\n", err)
-		os.Exit(1)
+	if interactive {
+		selection = promptSettings(processes, selection, settings)
+	} else {
+		if selection == 0 {
+			fail("无法确定要提取的微信进程，请通过 -pid 指定")
+		}
+		settings.DataDir = cmp.Or(settings.DataDir, discoveredDataDir)
+		if settings.DataDir == "" {
+			fail("请通过 -data-dir 指定微信数据目录")
+		}
 	}
+	selected := processes[selection-1]
+	dataDir := settings.DataDir
 
-	// 3. 获取微信数据目录
-	fmt.Println()
-	fmt.Print("请输入微信数据目录 (默认为当前目录): ")
-	dataDirInput, err := reader.ReadString('\n')
-	if err != nil {
-		fmt.Printf("错误: 读取输入失败 - %v\n", err)
-		os.Exit(1)
+	// 检查目录是否存在
+	if _, statErr := os.Stat(dataDir); os.IsNotExist(statErr) {
+		fail("目录不存在 - %s", dataDir)
 	}
 
-	// 处理输入
-	dataDir := dataDirInput[:len(dataDirInput)-1]
-	if dataDir == "" {
-		dataDir = "."
+	// 检查是否包含可用于验证密钥的数据库
+	found := false
+	for _, file := range decrypt.GetCandidateDBFiles("windows", 4) {
+		if _, statErr := os.Stat(filepath.Join(dataDir, file)); statErr == nil {
+			found = true
+			break
+		}
 	}
-
-	// 检查目录是否存在
-	if _, statErr := os.Stat(dataDir); os.IsNotExist(statErr) {
-		fmt.Printf("错误: 目录不存在 - %s\n", dataDir)
-		os.Exit(1)
+	if !found {
+		fmt.Fprintf(info, "警告: 未找到加密数据库 - %s\n", filepath.Join(dataDir, "db_storage"))
+		fmt.Fprintf(info, "请确保 %s 是正确的微信数据目录\n", dataDir)
 	}
 
-	// 检查是否包含所需文件
-	requiredFile := filepath.Join(dataDir, "db_storage", "message", "message_0.db")
-	if _, statErr := os.Stat(requiredFile); os.IsNotExist(statErr) {
-		fmt.Printf("警告: 未找到所需文件 - %s\n", requiredFile)
-		fmt.Printf("请确保 %s 是正确的微信数据目录\n", dataDir)
+	// 记住本次的选择
+	if !*batchFlag {
+		settings.ProcessName = selected.Name
+		settings.ExePath = selected.ExePath
+		if err := settings.save(); err != nil {
+			fmt.Fprintf(info, "警告: 保存设置失败 - %v\n", err)
+		}
 	}
 
 	// 4. 提取密钥
-	fmt.Println()
-	fmt.Println("正在提取密钥...")
-	fmt.Println("这可能需要一些时间，请稍候...")
-	fmt.Println()
+	fmt.Fprintln(info)
+	fmt.Fprintln(info, "正在提取密钥...")
+	fmt.Fprintln(info)
 
 	// 创建V4提取器
 	extractor := windows.NewV4Extractor()
@@ -113,53 +174,128 @@ func main() {
 	// 创建验证器
 	validator, err := decrypt.NewValidator("windows", 4, dataDir)
 	if err != nil {
-		fmt.Printf("错误: 创建验证器失败 - %v\n", err)
-		os.Exit(1)
+		fail("创建验证器失败 - %v", err)
 	}
 	extractor.SetValidate(validator)
 
+	// 显示内存扫描进度，输出不是终端时只显示一次提示
+	var bar *memscan.Bar
+	if f, ok := info.(*os.File); ok && isTerminal(f) {
+		bar = memscan.NewBar(info, 200*time.Millisecond)
+		extractor.SetProgressFunc(bar.Update)
+	} else {
+		fmt.Fprintln(info, "这可能需要一些时间，请稍候...")
+	}
+
 	// 创建进程信息
 	proc := &model.Process{
-		PID:    uint32(pid),
+		PID:    uint32(selected.PID),
 		Status: model.StatusOnline,
 	}
 
 	// 提取密钥
 	ctx := context.Background()
 	dataKey, imgKey, err := extractor.Extract(ctx, proc)
+	if bar != nil {
+		bar.Done()
+	}
 	if err != nil {
-		fmt.Printf("错误: 提取密钥失败 - %v\n", err)
-		os.Exit(1)
+		fail("提取密钥失败 - %v", err)
 	}
 
 	// 5. 显示结果
-	fmt.Println("========================================")
-	fmt.Println("提取结果:")
-	fmt.Println("========================================")
+	fmt.Fprintln(info, "========================================")
+	fmt.Fprintln(info, "提取结果:")
+	fmt.Fprintln(info, "========================================")
 
-	if dataKey != "" {
-		fmt.Printf("数据密钥: %s\n", dataKey)
+	if dataKey == "" && imgKey == "" {
+		fail("未找到有效密钥")
 	}
-
-	if imgKey != "" {
-		fmt.Printf("图片密钥: %s\n", imgKey)
+	result, err := formatResult(settings.Format, dataDir, dataKey, imgKey)
+	if err != nil {
+		fail("%v", err)
+	}
+	fmt.Print(result)
+	if settings.OutputFile != "" {
+		if err := os.WriteFile(settings.OutputFile, []byte(result), 0600); err != nil {
+			fail("保存结果失败 - %v", err)
+		}
+		fmt.Fprintf(info, "结果已保存到 %s\n", settings.OutputFile)
 	}
+	fmt.Fprintln(info)
+	fmt.Fprintln(info, "密钥提取成功!")
 
-	if dataKey == "" && imgKey == "" {
-		fmt.Println("未找到有效密钥")
-	} else {
+	wait()
+}
+
+// promptSettings 询问要提取的进程、数据目录与输出设置，返回选择的进程编号
+// 有上次的设置且找到了同一个微信时，直接回车即可沿用
+func promptSettings(processes []wechatProcess, selection int, settings *Settings) int {
+	if selection > 0 && settings.DataDir != "" {
 		fmt.Println()
-		fmt.Println("密钥提取成功!")
+		fmt.Println("上次的设置:")
+		fmt.Printf("  进程: %d. PID: %d\n", selection, processes[selection-1].PID)
+		fmt.Printf("  数据目录: %s\n", settings.DataDir)
+		fmt.Printf("  输出: %s\n", outputDescription(settings))
+		if strings.ToLower(prompt("直接回车使用上次的设置，输入 n 重新选择: ", "y")) != "n" {
+			return selection
+		}
 	}
 
+	// 2. 选择微信进程
+	fmt.Println()
+	input := prompt(withDefault("请选择微信进程 (输入编号", selection)+": ", strconv.Itoa(selection))
+	selection, err := strconv.Atoi(input)
+	if err != nil || selection < 1 || selection > len(processes) {
+		fail("无效的选择 - %s", input)
+	}
+
+	// 3. 获取微信数据目录
+	fmt.Println()
+	settings.DataDir = prompt(fmt.Sprintf("请输入微信数据目录 (默认为 %s): ", cmp.Or(settings.DataDir, discoveredDataDir, "当前目录")), cmp.Or(settings.DataDir, discoveredDataDir, "."))
+
+	// 输出设置
+	settings.Format = strings.ToLower(prompt(fmt.Sprintf("输出格式 text/json (默认为 %s): ", settings.Format), settings.Format))
+	if settings.Format != FormatText && settings.Format != FormatJSON {
+		fail("无效的输出格式 - %s", settings.Format)
+	}
+	output := prompt(fmt.Sprintf("结果同时保存到文件 (默认为 %s，输入 - 不保存): ", cmp.Or(settings.OutputFile, "不保存")), settings.OutputFile)
+	settings.OutputFile = strings.TrimPrefix(output, "-")
+	return selection
+}
+
+// fail 输出错误并退出，交互模式下先等待回车
+func fail(format string, args ...any) {
+	fmt.Fprintf(info, "错误: "+format+"\n", args...)
+	wait()
+	os.Exit(1)
+}
+
+// wait 交互模式下等待回车后再退出
+func wait() {
+	if !interactive {
+		return
+	}
 	fmt.Println()
 	fmt.Println("========================================")
 	fmt.Println("按回车键退出...")
 	reader.ReadString('\n')
 }
 
+// isTerminal 判断文件是否为终端，通过管道或重定向运行时返回 false
+func isTerminal(f *os.File) bool {
+	return term.IsTerminal(int(f.Fd()))
+}
+
+// wechatProcess 是可供选择的微信进程
+type wechatProcess struct {
+	PID     int32
+	Name    string
+	ExePath string
+}
+
 // getWeChatProcesses 获取微信进程列表
-func getWeChatProcesses() ([]string, error) {
+func getWeChatProcesses() ([]wechatProcess, error) {
 	// 获取所有进程
 	processes, err := process.Processes()
 	if err != nil {
@@ -167,19 +303,101 @@ func getWeChatProcesses() ([]string, error) {
 	}
 
 	// 过滤微信进程
-	var wechatProcesses []string
+	var wechatProcesses []wechatProcess
 	for _, p := range processes {
 		name, err := p.Name()
 		if err != nil {
 			continue
 		}
 
-		// 判断是否是微信进程
-		if name == "WeChat.exe" {
-			pid := p.Pid
-			wechatProcesses = append(wechatProcesses, fmt.Sprintf("%d", pid))
+		// 判断是否是微信进程，4.x 的子进程命令行带有 -- 参数
+		if name != "WeChat.exe" && name != "Weixin.exe" {
+			continue
+		}
+		if cmdline, err := p.Cmdline(); err == nil && strings.Contains(cmdline, "--") {
+			continue
 		}
+		exePath, _ := p.Exe()
+		wechatProcesses = append(wechatProcesses, wechatProcess{PID: p.Pid, Name: name, ExePath: exePath})
 	}
 
 	return wechatProcesses, nil
 }
+
+// defaultProcess 返回默认选择的进程编号，没有合适的默认值时返回 0
+// PID 每次启动都会变化，优先选择与上次路径相同的进程，只有一个进程时直接选择它
+func defaultProcess(processes []wechatProcess, settings *Settings) int {
+	match := 0
+	for i, p := range processes {
+		if settings.ExePath != "" && p.ExePath == settings.ExePath && p.Name == settings.ProcessName {
+			if match != 0 {
+				return 0 // 同一路径有多个进程时无法判断
+			}
+			match = i + 1
+		}
+	}
+	if match == 0 && len(processes) == 1 {
+		match = 1
+	}
+	return match
+}
+
+// prompt 读取一行输入，直接回车或输入已结束时返回 def
+func prompt(text string, def string) string {
+	fmt.Print(text)
+	input, _ := reader.ReadString('\n')
+	if input = strings.TrimSpace(input); input == "" {
+		return def
+	}
+	return input
+}
+
+// withDefault 在提示后附加默认编号
+func withDefault(text string, selection int) string {
+	if selection > 0 {
+		return fmt.Sprintf("%s，默认为 %d)", text, selection)
+	}
+	return text + ")"
+}
+
+func outputDescription(s *Settings) string {
+	if s.OutputFile == "" {
+		return s.Format + "，不保存到文件"
+	}
+	return s.Format + "，保存到 " + s.OutputFile
+}
+
+// formatResult 按输出格式生成结果，json 格式为 keybag，可以直接用 chatlog key import 导入
+func formatResult(format, dataDir, dataKey, imgKey string) (string, error) {
+	if format != FormatJSON {
+		var sb strings.Builder
+		if dataKey != "" {
+			fmt.Fprintf(&sb, "数据密钥: %s\n", dataKey)
+		}
+		if imgKey != "" {
+			fmt.Fprintf(&sb, "图片密钥: %s\n", imgKey)
+		}
+		return sb.String(), nil
+	}
+
+	absDir, err := filepath.Abs(dataDir)
+	if err != nil {
+		absDir = dataDir
+	}
+	now := time.Now()
+	b := keybag.New()
+	b.Keys = append(b.Keys, keybag.Key{
+		Account:     filepath.Base(absDir),
+		Platform:    model.PlatformWindows,
+		Version:     4,
+		DataDir:     absDir,
+		DataKey:     dataKey,
+		ImgKey:      imgKey,
+		ExtractedAt: &now,
+	})
+	var sb strings.Builder
+	if err := b.Write(&sb); err != nil {
+		return "", err
+	}
+	return sb.String(), nil
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+)
+
+const (
+	// settingsFile 保存在 $CHATLOG_DIR 或 ~/.chatlog 下，与 chatlog 的配置放在一起
+	settingsFile = "v4getKeyGUI.json"
+
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// Settings 记录上次提取时的选择，下次运行时作为默认值，直接回车即可沿用
+type Settings struct {
+	DataDir     string `json:"data_dir"`
+	ProcessName string `json:"process_name"` // 上次选择的进程名
+	ExePath     string `json:"exe_path"`     // 上次选择的进程路径，PID 每次启动都会变化，按路径匹配
+	OutputFile  string `json:"output_file"`  // 结果同时写入的文件，为空时只打印
+	Format      string `json:"format"`       // text 或 json
+}
+
+// settingsPath 返回设置文件路径
+func settingsPath() string {
+	dir := os.Getenv("CHATLOG_DIR")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			home = os.TempDir()
+		}
+		dir = filepath.Join(home, ".chatlog")
+	}
+	return filepath.Join(dir, settingsFile)
+}
+
+// loadSettings 读取设置，文件不存在或无法解析时返回默认设置
+func loadSettings() *Settings {
+	s := &Settings{}
+	if b, err := os.ReadFile(settingsPath()); err == nil {
+		json.Unmarshal(b, s)
+	}
+	if s.Format != FormatJSON {
+		s.Format = FormatText
+	}
+	return s
+}
+
+// save 保存设置
+func (s *Settings) save() error {
+	path := settingsPath()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, b, 0600)
+}
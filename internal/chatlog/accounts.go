@@ -0,0 +1,194 @@
+package chatlog
+
+import (
+	"cmp"
+	"fmt"
+	"os"
+	"runtime"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/aspnmy/chatlog/internal/chatlog/conf"
+	"github.com/aspnmy/chatlog/internal/errors"
+	iwechat "github.com/aspnmy/chatlog/internal/wechat"
+	"github.com/aspnmy/chatlog/pkg/util"
+	"github.com/aspnmy/chatlog/pkg/util/dat2img"
+)
+
+// Account 本次运行使用的账号，由 --account 设置，为空时使用配置文件中上次的账号
+// 存在多个微信进程时，也用于选择该账号的进程
+var Account string
+
+// useAccount 切换到 --account 指定的账号，账号不在配置文件中时返回错误
+func (m *Manager) useAccount() error {
+	if Account == "" {
+		return nil
+	}
+	if _, ok := m.ctx.AccountConfig(Account); !ok {
+		return fmt.Errorf("account %s not found in the config file, run \"chatlog accounts\" to list the saved accounts", Account)
+	}
+	m.ctx.SwitchHistory(Account)
+	return nil
+}
+
+// accountInstance 返回账号为 account 的微信进程，account 为空时返回第一个进程，没有时返回 nil
+// 启动时传入 Account，未指定 --account 时使用第一个进程
+func accountInstance(instances []*iwechat.Account, account string) *iwechat.Account {
+	for _, ins := range instances {
+		if account == "" || ins.Name == account {
+			return ins
+		}
+	}
+	return nil
+}
+
+// Accounts 返回配置文件中保存的全部账号，按账号排序
+func (m *Manager) Accounts() []conf.ProcessConfig {
+	accounts, _ := m.ctx.Accounts()
+	return accounts
+}
+
+// CurrentAccount 返回当前使用的账号
+func (m *Manager) CurrentAccount() string {
+	_, current := m.ctx.Accounts()
+	return current
+}
+
+// CommandUseAccount 设置下次启动时默认使用的账号
+func (m *Manager) CommandUseAccount(account string) error {
+	if _, ok := m.ctx.AccountConfig(account); !ok {
+		return fmt.Errorf("account %s not found in the config file", account)
+	}
+	if err := m.conf.GetConfig().SetLastAccount(account); err != nil {
+		return err
+	}
+	return m.conf.Reload()
+}
+
+// CommandAddAccount 注册一个账号，用于微信不在本机运行、数据目录与密钥来自其他途径的情况
+// 平台默认为本机平台，版本默认为 4，工作目录默认为以账号命名的目录；密钥需要能解密数据目录中的数据库
+// 账号已存在时更新其数据目录、密钥与工作目录
+func (m *Manager) CommandAddAccount(p conf.ProcessConfig) (*conf.ProcessConfig, error) {
+	if p.Account == "" {
+		return nil, fmt.Errorf("account is empty")
+	}
+	if p.DataDir == "" || p.DataKey == "" {
+		return nil, fmt.Errorf("data dir and key are required")
+	}
+	p.Type = cmp.Or(p.Type, "wechat")
+	p.Platform = cmp.Or(p.Platform, runtime.GOOS)
+	p.Version = cmp.Or(p.Version, 4)
+	p.WorkDir = cmp.Or(p.WorkDir, util.DefaultWorkDir(p.Account))
+	if old, ok := m.ctx.AccountConfig(p.Account); ok {
+		p.HTTPEnabled, p.HTTPAddr, p.Legacy = old.HTTPEnabled, old.HTTPAddr, old.Legacy
+		if p.DataKey == old.DataKey && p.DataDir == old.DataDir {
+			p.Cipher = old.Cipher
+		}
+	}
+	if err := validateKey(p.Platform, p.Version, p.DataDir, p.DataKey, p.Cipher); err != nil {
+		return nil, fmt.Errorf("the key does not match the data dir %s: %w", p.DataDir, err)
+	}
+	p.KeyTime = time.Now().Unix()
+	if err := m.conf.GetConfig().MergeHistory([]conf.ProcessConfig{p}); err != nil {
+		return nil, err
+	}
+	m.ctx.SetAccountConfig(p)
+	return &p, m.conf.Reload()
+}
+
+// CommandRemoveAccount 从配置文件中删除账号，不删除工作目录中已解密的数据
+func (m *Manager) CommandRemoveAccount(account string) error {
+	if err := m.conf.GetConfig().RemoveHistory(account); err != nil {
+		return err
+	}
+	m.ctx.RemoveAccountConfig(account)
+	return m.conf.Reload()
+}
+
+// SwitchAccount 切换到配置文件中保存的另一个账号，实现 http.Admin
+// 只重新打开数据库，HTTP 与 MCP 服务保持运行；开启了自动解密时，改为监控新账号的数据目录
+// 先检查新账号的工作目录与密钥，新账号的数据库无法打开时切换回原账号
+func (m *Manager) SwitchAccount(account string) error {
+	target, ok := m.ctx.AccountConfig(account)
+	if !ok {
+		return errors.WeChatAccountNotFound(account)
+	}
+	previous, previousIns := m.CurrentAccount(), m.ctx.Current
+	if account == previous {
+		return nil
+	}
+	var ins *iwechat.Account
+	if !m.ctx.ArchiveOnly {
+		ins = accountInstance(m.wechat.GetWeChatInstances(), account)
+	}
+	if err := checkAccount(target, ins, m.ctx.ArchiveOnly); err != nil {
+		return err
+	}
+
+	autoDecrypt := m.ctx.AutoDecrypt
+	if autoDecrypt {
+		if err := m.wechat.StopAutoDecrypt(); err != nil {
+			return err
+		}
+	}
+	if err := m.db.Stop(); err != nil {
+		return err
+	}
+	m.switchContext(account, ins)
+	if err := m.db.Start(); err != nil {
+		m.switchContext(previous, previousIns)
+		if rerr := m.db.Start(); rerr != nil {
+			log.Err(rerr).Msgf("failed to reopen the database of account %s", previous)
+		}
+		m.restartAutoDecrypt(autoDecrypt)
+		return err
+	}
+	if err := m.conf.GetConfig().SetLastAccount(account); err != nil {
+		return err
+	}
+	if err := m.conf.Reload(); err != nil {
+		return err
+	}
+	if m.ctx.Version == 4 && m.ctx.DataDir != "" {
+		dat2img.SetAesKey(m.ctx.ImgKey)
+		go dat2img.ScanAndSetXorKey(m.ctx.DataDir)
+	}
+	return m.restartAutoDecrypt(autoDecrypt)
+}
+
+// checkAccount 检查切换的目标账号能否使用：工作目录需已存在，非归档模式下需有密钥
+// 在停止当前账号的数据库之前调用，ins 为该账号正在运行的微信进程，没有时为 nil
+func checkAccount(p conf.ProcessConfig, ins *iwechat.Account, archiveOnly bool) error {
+	if p.WorkDir == "" {
+		return errors.WeChatAccountUnusable(p.Account, "work dir is not set")
+	}
+	if info, err := os.Stat(p.WorkDir); err != nil || !info.IsDir() {
+		return errors.WeChatAccountUnusable(p.Account, fmt.Sprintf("work dir %s does not exist", p.WorkDir))
+	}
+	if !archiveOnly && p.DataKey == "" && (ins == nil || ins.Key == "") {
+		return errors.WeChatAccountUnusable(p.Account, "no data key, run \"chatlog key\" first")
+	}
+	return nil
+}
+
+// switchContext 将上下文切换到账号 account，ins 为该账号正在运行的微信进程，没有时为 nil
+func (m *Manager) switchContext(account string, ins *iwechat.Account) {
+	if ins != nil {
+		m.ctx.SwitchCurrent(ins)
+	} else {
+		m.ctx.SwitchHistory(account)
+	}
+}
+
+// restartAutoDecrypt 切换账号后重新开启自动解密，enabled 为切换前是否开启，开启失败时关闭自动解密
+func (m *Manager) restartAutoDecrypt(enabled bool) error {
+	if !enabled {
+		return nil
+	}
+	if err := m.wechat.StartAutoDecrypt(); err != nil {
+		m.ctx.SetAutoDecrypt(false)
+		return err
+	}
+	return nil
+}
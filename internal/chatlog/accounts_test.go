@@ -0,0 +1,70 @@
+package chatlog
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/viper"
+
+	"github.com/aspnmy/chatlog/internal/chatlog/ctx"
+)
+
+func TestAccounts(t *testing.T) {
+	dir := t.TempDir()
+	config := `{"last_account": "wxid_a", "history": [
+		{"account": "wxid_b", "platform": "windows", "version": 4, "data_dir": "/data/b", "work_dir": "/work/b"},
+		{"account": "wxid_a", "platform": "windows", "version": 4, "data_dir": "/data/a", "work_dir": "/work/a"}]}`
+	if err := os.WriteFile(filepath.Join(dir, "chatlog.json"), []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	Account = "wxid_b"
+	// 保存配置时写入 viper 的值会覆盖之后测试读取的配置文件
+	t.Cleanup(func() {
+		Account = ""
+		viper.Reset()
+	})
+	m, err := New(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 归档构建中数据目录为工作目录中的媒体文件副本
+	dataDir := "/data/b"
+	if archiveBuild {
+		dataDir = ctx.ArchiveMediaDir("/work/b")
+	}
+	if m.ctx.Account != "wxid_b" || m.ctx.DataDir != dataDir {
+		t.Fatalf("account = %s, data dir = %s", m.ctx.Account, m.ctx.DataDir)
+	}
+	if accounts := m.Accounts(); len(accounts) != 2 || accounts[0].Account != "wxid_a" {
+		t.Errorf("accounts = %+v", accounts)
+	}
+
+	// 工作目录不存在的账号在停止当前数据库之前被拒绝
+	if err := m.SwitchAccount("wxid_a"); err == nil || m.CurrentAccount() != "wxid_b" {
+		t.Errorf("switch to an account without work dir: %v, current = %s", err, m.CurrentAccount())
+	}
+
+	if err := m.CommandUseAccount("wxid_c"); err == nil {
+		t.Error("unknown account accepted")
+	}
+	if err := m.CommandUseAccount("wxid_b"); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.CommandRemoveAccount("wxid_b"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := New(dir); err == nil {
+		t.Error("removed account accepted by --account")
+	}
+
+	// 删除默认账号后，配置文件中只剩另一个账号，也没有默认账号
+	Account = ""
+	if m, err = New(dir); err != nil {
+		t.Fatal(err)
+	}
+	if c := m.conf.GetConfig(); len(c.History) != 1 || c.History[0].Account != "wxid_a" || c.LastAccount != "" {
+		t.Errorf("history = %+v, last account = %s", c.History, c.LastAccount)
+	}
+}
@@ -0,0 +1,69 @@
+package chatlog
+
+import (
+	"fmt"
+
+	"github.com/aspnmy/chatlog/internal/wechat/key"
+	"github.com/aspnmy/chatlog/pkg/util/dat2img"
+)
+
+// 以下方法实现 http.Admin，供 /api/v1/admin 管理接口控制常驻进程
+
+// PauseSync 暂停自动解密，未开启时不做任何事
+func (m *Manager) PauseSync() error {
+	if !m.ctx.AutoDecrypt {
+		return nil
+	}
+	return m.StopAutoDecrypt()
+}
+
+// ResumeSync 恢复自动解密，已开启时不做任何事
+func (m *Manager) ResumeSync() error {
+	if m.ctx.AutoDecrypt {
+		return nil
+	}
+	return m.StartAutoDecrypt()
+}
+
+// SyncNow 立即解密一次全部数据库
+func (m *Manager) SyncNow() error {
+	return m.DecryptDBFiles()
+}
+
+// RotateKey 重新从微信进程提取密钥，微信升级或重新登录导致原密钥失效时使用
+// 提取失败时保留原密钥
+func (m *Manager) RotateKey() error {
+	if m.ctx.Current == nil {
+		for _, ins := range m.wechat.GetWeChatInstances() {
+			if ins.Name == m.ctx.Account || (m.ctx.Account == "" && ins.DataDir == m.ctx.DataDir) {
+				m.ctx.Current = ins
+				break
+			}
+		}
+	}
+	if m.ctx.Current == nil {
+		return fmt.Errorf("wechat process of %s not found", m.ctx.Account)
+	}
+
+	current := m.ctx.Current
+	dataKey, imgKey := current.Key, current.ImgKey
+	current.Key, current.ImgKey = "", ""
+	if err := m.GetDataKey(); err != nil {
+		current.Key, current.ImgKey = dataKey, imgKey
+		return err
+	}
+	if m.ctx.Version == 4 {
+		dat2img.SetAesKey(m.ctx.ImgKey)
+	}
+	return nil
+}
+
+// ReloadConfig 重新读取配置文件，不会重启正在运行的服务
+func (m *Manager) ReloadConfig() error {
+	if err := m.ctx.Reload(); err != nil {
+		return err
+	}
+	key.StatsFile = m.conf.GetConfig().KeyStatsPath()
+	m.setTranslator()
+	return nil
+}
@@ -7,11 +7,13 @@ import (
 	"time"
 
 	"github.com/aspnmy/chatlog/internal/chatlog/ctx"
+	"github.com/aspnmy/chatlog/internal/ui/browser"
 	"github.com/aspnmy/chatlog/internal/ui/footer"
 	"github.com/aspnmy/chatlog/internal/ui/form"
 	"github.com/aspnmy/chatlog/internal/ui/help"
 	"github.com/aspnmy/chatlog/internal/ui/infobar"
 	"github.com/aspnmy/chatlog/internal/ui/menu"
+	"github.com/aspnmy/chatlog/internal/ui/palette"
 	"github.com/aspnmy/chatlog/internal/wechat"
 
 	"github.com/gdamore/tcell/v2"
@@ -40,6 +42,9 @@ type App struct {
 	help      *help.Help
 	activeTab int
 	tabCount  int
+
+	// browser 打开的聊天记录浏览器，未打开时为 nil
+	browser *browser.Browser
 }
 
 func NewApp(ctx *ctx.Context, m *Manager) *App {
@@ -172,6 +177,16 @@ func (a *App) refresh() {
 
 func (a *App) inputCapture(event *tcell.EventKey) *tcell.EventKey {
 
+	// 命令面板打开时由命令面板处理按键
+	if a.mainPages.HasPage(palette.Title) {
+		return event
+	}
+
+	if event.Key() == tcell.KeyCtrlP && !a.mainPages.HasPage("modal") {
+		a.showPalette()
+		return nil
+	}
+
 	// 如果当前页面不是主页面，ESC 键返回主页面
 	if a.mainPages.HasPage("submenu") && event.Key() == tcell.KeyEscape {
 		a.mainPages.RemovePage("submenu")
@@ -450,6 +465,13 @@ func (a *App) initMenu() {
 
 	a.menu.AddItem(&menu.Item{
 		Index:       8,
+		Name:        "浏览聊天记录",
+		Description: "在终端中浏览已解密的会话与消息",
+		Selected:    a.browseSelected,
+	})
+
+	a.menu.AddItem(&menu.Item{
+		Index:       9,
 		Name:        "退出",
 		Description: "退出程序",
 		Selected: func(i *menu.Item) {
@@ -709,7 +731,8 @@ func (a *App) selectAccountSelected(i *menu.Item) {
 
 		// 添加历史账号列表
 		idx := 101
-		for account, hist := range a.ctx.History {
+		for _, hist := range a.m.Accounts() {
+			account := hist.Account
 			// 创建一个账号描述
 			description := fmt.Sprintf("版本: %s 目录: %s", hist.FullVersion, hist.DataDir)
 
@@ -0,0 +1,136 @@
+package chatlog
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/aspnmy/chatlog/internal/chatlog/ctx"
+	"github.com/aspnmy/chatlog/internal/errors"
+	"github.com/aspnmy/chatlog/pkg/throttle"
+)
+
+// ArchiveOnly 以归档模式运行，只使用工作目录中的数据，不查找微信进程，也不访问微信数据目录，
+// 由 --archive-only 或配置文件的 archive_only 设置，使用 archive 构建标签编译时始终启用
+var ArchiveOnly bool
+
+// ArchiveResult 是 CommandArchiveMedia 的结果
+type ArchiveResult struct {
+	Dir     string // 媒体文件副本所在目录
+	Copied  int
+	Skipped int   // 已是最新的文件
+	Bytes   int64 // 复制的字节数
+}
+
+// CommandArchiveMedia 将数据目录中数据库以外的文件（图片、视频、文件等）复制到工作目录的 media 目录，保留相对路径，
+// 已存在且大小与修改时间都相同的文件跳过，可以重复执行。卸载微信后，归档模式从这里读取媒体文件
+func (m *Manager) CommandArchiveMedia(dataDir string, workDir string) (*ArchiveResult, error) {
+	if m.ctx.ArchiveOnly {
+		return nil, errors.ErrArchiveOnly
+	}
+	if dataDir == "" {
+		dataDir = m.ctx.DataDir
+	}
+	if workDir == "" {
+		workDir = m.ctx.WorkDir
+	}
+	if dataDir == "" {
+		return nil, fmt.Errorf("dataDir is required")
+	}
+	if workDir == "" {
+		return nil, fmt.Errorf("workDir is required")
+	}
+	return archiveMedia(dataDir, ctx.ArchiveMediaDir(workDir))
+}
+
+// archiveMedia 将 src 中数据库以外的文件复制到 dst
+func archiveMedia(src string, dst string) (*ArchiveResult, error) {
+	if _, err := os.Stat(src); err != nil {
+		return nil, errors.StatFileFailed(src, err)
+	}
+	result := &ArchiveResult{Dir: dst}
+	dstAbs, _ := filepath.Abs(dst)
+	err := filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			// 工作目录位于数据目录中时，跳过副本自身
+			if abs, _ := filepath.Abs(path); abs == dstAbs {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() || isDatabaseFile(d.Name()) {
+			return nil
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		if t, err := os.Stat(target); err == nil && t.Size() == info.Size() && t.ModTime().Equal(info.ModTime()) {
+			result.Skipped++
+			return nil
+		}
+		n, err := copyFile(path, target, info)
+		if err != nil {
+			return err
+		}
+		result.Copied++
+		result.Bytes += n
+		return nil
+	})
+	return result, err
+}
+
+// isDatabaseFile 返回文件是否为加密的数据库或其日志，这些文件已解密到工作目录中
+func isDatabaseFile(name string) bool {
+	name = strings.ToLower(name)
+	for _, suffix := range []string{".db", ".db-wal", ".db-shm", ".db-journal"} {
+		if strings.HasSuffix(name, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// copyFile 复制文件并保留修改时间，先写入临时文件再重命名，中断时不会留下不完整的副本
+func copyFile(src string, dst string, info fs.FileInfo) (int64, error) {
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return 0, err
+	}
+	in, err := os.Open(src)
+	if err != nil {
+		return 0, err
+	}
+	defer in.Close()
+
+	tmp := dst + ".tmp"
+	out, err := os.Create(tmp)
+	if err != nil {
+		return 0, err
+	}
+	n, err := io.Copy(throttle.Writer(out), in)
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Chtimes(tmp, info.ModTime(), info.ModTime())
+	}
+	if err == nil {
+		err = os.Rename(tmp, dst)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return n, err
+	}
+	return n, nil
+}
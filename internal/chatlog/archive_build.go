@@ -0,0 +1,6 @@
+//go:build archive
+
+package chatlog
+
+// archiveBuild 使用 archive 构建标签编译，始终以归档模式运行
+const archiveBuild = true
@@ -0,0 +1,19 @@
+//go:build archive
+
+package chatlog
+
+import "testing"
+
+// go test -tags archive ./... 验证归档构建中所有功能都不依赖微信进程与数据目录
+func TestArchiveBuild(t *testing.T) {
+	m, err := New(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !m.ctx.ArchiveOnly {
+		t.Error("archive build is not in archive-only mode")
+	}
+	if _, err := m.CommandArchiveMedia(t.TempDir(), t.TempDir()); err == nil {
+		t.Error("read the wechat data dir in archive build")
+	}
+}
@@ -0,0 +1,5 @@
+//go:build !archive
+
+package chatlog
+
+const archiveBuild = false
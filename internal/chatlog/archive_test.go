@@ -0,0 +1,82 @@
+package chatlog
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aspnmy/chatlog/internal/chatlog/ctx"
+	"github.com/aspnmy/chatlog/internal/errors"
+)
+
+func TestArchiveMedia(t *testing.T) {
+	src, dst := t.TempDir(), filepath.Join(t.TempDir(), "media")
+	files := map[string]string{
+		"db_storage/message/message_0.db":     "db",
+		"db_storage/message/message_0.db-wal": "wal",
+		"msg/attach/abc/2024-01/Img/1.dat":    "image",
+		"msg/video/2024-01/2.mp4":             "video",
+	}
+	for name, content := range files {
+		path := filepath.Join(src, name)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	result, err := archiveMedia(src, dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Copied != 2 || result.Bytes != int64(len("image")+len("video")) {
+		t.Errorf("copied %d files, %d bytes", result.Copied, result.Bytes)
+	}
+	if _, err := os.Stat(filepath.Join(dst, "db_storage/message/message_0.db")); err == nil {
+		t.Error("database copied")
+	}
+	if b, _ := os.ReadFile(filepath.Join(dst, "msg/video/2024-01/2.mp4")); string(b) != "video" {
+		t.Errorf("video = %q", b)
+	}
+
+	result, err = archiveMedia(src, dst)
+	if err != nil || result.Copied != 0 || result.Skipped != 2 {
+		t.Errorf("second run: %+v, %v", result, err)
+	}
+}
+
+func TestArchiveOnly(t *testing.T) {
+	dir, workDir := t.TempDir(), t.TempDir()
+	config := `{"archive_only": true, "last_account": "wxid_a", "history": [{"account": "wxid_a", "platform": "windows", "version": 4, "data_dir": "/uninstalled/wechat", "work_dir": ` + quote(workDir) + `}]}`
+	if err := os.WriteFile(filepath.Join(dir, "chatlog.json"), []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+	m, err := New(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !m.ctx.ArchiveOnly || m.ctx.DataDir != ctx.ArchiveMediaDir(workDir) {
+		t.Fatalf("archive only = %v, data dir = %s", m.ctx.ArchiveOnly, m.ctx.DataDir)
+	}
+	if m.wechat.GetWeChatInstances() != nil {
+		t.Error("looked for wechat processes")
+	}
+	if _, err := m.CommandKey(0, nil); err != errors.ErrArchiveOnly {
+		t.Errorf("CommandKey: %v", err)
+	}
+	if err := m.CommandDecrypt("/uninstalled/wechat", workDir, "key", "windows", 4); err != errors.ErrArchiveOnly {
+		t.Errorf("CommandDecrypt: %v", err)
+	}
+
+	// 保存配置时保留原来的数据目录
+	m.ctx.UpdateConfig()
+	if h := m.conf.GetConfig().History; len(h) != 1 || h[0].DataDir != "/uninstalled/wechat" {
+		t.Errorf("history = %+v", h)
+	}
+}
+
+func quote(s string) string {
+	b, _ := json.Marshal(s)
+	return string(b)
+}
@@ -0,0 +1,256 @@
+package chatlog
+
+import (
+	"cmp"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/aspnmy/chatlog/internal/chatlog/wechat"
+	"github.com/aspnmy/chatlog/internal/errors"
+	iwechat "github.com/aspnmy/chatlog/internal/wechat"
+	"github.com/aspnmy/chatlog/internal/wechat/decrypt"
+	"github.com/aspnmy/chatlog/internal/wechat/decrypt/common"
+	"github.com/aspnmy/chatlog/pkg/search"
+	"github.com/aspnmy/chatlog/pkg/util"
+	"github.com/aspnmy/chatlog/pkg/util/dat2img"
+)
+
+// 自动模式的阶段，按执行顺序排列
+const (
+	AutoProcess = "process" // 检测微信进程
+	AutoKey     = "key"     // 提取密钥，保存的密钥仍有效时跳过
+	AutoDataDir = "datadir" // 确定数据目录
+	AutoDecrypt = "decrypt" // 增量解密到工作目录
+	AutoIndex   = "index"   // 建立或更新搜索索引
+	AutoServer  = "server"  // 启动 HTTP 服务
+)
+
+// autoHints 各阶段失败时给出的处理建议
+var autoHints = map[string]string{
+	AutoProcess: `start and log in to wechat, or run "chatlog doctor" to see why the process is not detected`,
+	AutoKey:     `run "chatlog doctor" to check permissions, or extract the key with "chatlog key" and pass it to "chatlog decrypt"`,
+	AutoDataDir: `specify the data dir with "chatlog decrypt --data-dir"`,
+	AutoDecrypt: `check the free space of the work dir, or run "chatlog selftest" to find the failing step`,
+	AutoIndex:   `run "chatlog index rebuild" to rebuild the index, or skip it with --no-index`,
+	AutoServer:  `the address may be in use, specify another one with --addr`,
+	AutoSync:    `run "chatlog config validate" to check the webhooks in the config file`,
+}
+
+// AutoOptions 自动模式参数
+type AutoOptions struct {
+	Addr     string // HTTP 服务地址，为空时使用配置中的地址或 127.0.0.1:5030
+	PID      int    // 存在多个微信进程时选择的进程
+	WorkDir  string // 工作目录，为空时使用保存的工作目录或默认目录
+	ForceKey bool   // 即使保存的密钥仍然有效，也重新提取
+	NoIndex  bool   // 不建立搜索索引
+	Watch    bool   // 启动服务后自动解密新数据
+}
+
+// AutoStage 自动模式中一个已完成的阶段，Skipped 表示已是最新状态或条件不满足而跳过，Detail 中不包含密钥
+type AutoStage struct {
+	Name    string
+	Detail  string
+	Skipped bool
+	Elapsed time.Duration
+}
+
+// AutoError 自动模式在某个阶段失败，Hint 为处理建议
+type AutoError struct {
+	Stage string
+	Err   error
+	Hint  string
+}
+
+func (e *AutoError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Stage, e.Err)
+}
+
+func (e *AutoError) Unwrap() error {
+	return e.Err
+}
+
+// CommandAuto 依次检测微信进程、提取密钥、确定数据目录、解密到工作目录、建立搜索索引并启动 HTTP 服务，
+// 每个阶段完成后调用 report，服务运行期间阻塞；某个阶段失败时返回 *AutoError
+// 重复执行时复用保存的密钥与工作目录，只解密变化的页面、只索引新消息，微信未运行时使用上次的账号启动服务
+func (m *Manager) CommandAuto(opts AutoOptions, report func(AutoStage)) error {
+	if err := m.autoPrepare(opts, report); err != nil {
+		return err
+	}
+	detail := ""
+	if opts.Watch && m.ctx.Current != nil {
+		if err := m.StartAutoDecrypt(); err != nil {
+			return &AutoError{Stage: AutoServer, Err: err, Hint: autoHints[AutoDecrypt]}
+		}
+		detail = "decrypting new data automatically"
+	}
+	return m.autoServe(opts.Addr, detail, report)
+}
+
+// autoPrepare 执行启动服务之前的各个阶段，数据库服务在 index 阶段启动
+func (m *Manager) autoPrepare(opts AutoOptions, report func(AutoStage)) error {
+	if m.ctx.ArchiveOnly {
+		return &AutoError{Stage: AutoProcess, Err: errors.ErrArchiveOnly}
+	}
+	for _, stage := range []struct {
+		name string
+		run  func(AutoOptions) (string, error)
+	}{
+		{AutoProcess, m.autoProcess},
+		{AutoKey, m.autoKey},
+		{AutoDataDir, m.autoDataDir},
+		{AutoDecrypt, m.autoDecrypt},
+		{AutoIndex, m.autoIndex},
+	} {
+		start := time.Now()
+		detail, err := stage.run(opts)
+		s := AutoStage{Name: stage.name, Detail: detail, Elapsed: time.Since(start)}
+		if skip, ok := err.(skipStep); ok {
+			s.Detail, s.Skipped, err = string(skip), true, nil
+		}
+		if err != nil {
+			return &AutoError{Stage: stage.name, Err: err, Hint: autoHints[stage.name]}
+		}
+		report(s)
+	}
+	return nil
+}
+
+// autoServe 启动 MCP 与 HTTP 服务，服务运行期间阻塞，detail 附加在 server 阶段的结果中
+func (m *Manager) autoServe(addr, detail string, report func(AutoStage)) error {
+	m.ctx.HTTPAddr = cmp.Or(addr, m.ctx.HTTPAddr, "127.0.0.1:5030")
+	if m.ctx.Version == 4 && m.ctx.DataDir != "" {
+		dat2img.SetAesKey(m.ctx.ImgKey)
+		go dat2img.ScanAndSetXorKey(m.ctx.DataDir)
+	}
+	if err := m.mcp.Start(); err != nil {
+		return &AutoError{Stage: AutoServer, Err: err, Hint: autoHints[AutoServer]}
+	}
+	m.startDigest()
+	if detail != "" {
+		detail = ", " + detail
+	}
+	report(AutoStage{Name: AutoServer, Detail: "http://" + m.ctx.HTTPAddr + detail})
+	if err := m.http.ListenAndServe(); err != nil {
+		return &AutoError{Stage: AutoServer, Err: err, Hint: autoHints[AutoServer]}
+	}
+	return nil
+}
+
+// autoProcess 选择微信进程，没有运行的微信时使用配置文件中上次的账号
+func (m *Manager) autoProcess(opts AutoOptions) (string, error) {
+	instances := m.wechat.GetWeChatInstances()
+	var ins *iwechat.Account
+	switch {
+	case opts.PID != 0:
+		for _, i := range instances {
+			if i.PID == uint32(opts.PID) {
+				ins = i
+			}
+		}
+		if ins == nil {
+			return "", fmt.Errorf("wechat process %d not found", opts.PID)
+		}
+	case Account != "":
+		ins = accountInstance(instances, Account)
+	case len(instances) == 1:
+		ins = instances[0]
+	case len(instances) > 1:
+		return "", fmt.Errorf("found %d wechat processes, use --pid or --account to select one", len(instances))
+	}
+	if ins == nil {
+		if m.ctx.Account == "" || m.ctx.DataKey == "" || m.ctx.DataDir == "" {
+			return "", fmt.Errorf("wechat process not found and no account saved in the config file")
+		}
+		return "", skipStep(fmt.Sprintf("wechat is not running, using the saved account %s", m.ctx.Account))
+	}
+	m.ctx.WeChatInstances = instances
+	m.ctx.SwitchCurrent(ins)
+	return fmt.Sprintf("pid %d, %s %s, account %s", ins.PID, ins.Platform, ins.FullVersion, ins.Name), nil
+}
+
+// autoKey 验证保存的密钥，无效或指定了 ForceKey 时从微信进程中提取
+func (m *Manager) autoKey(opts AutoOptions) (string, error) {
+	if !opts.ForceKey && m.ctx.DataKey != "" && m.ctx.DataDir != "" {
+		if err := validateKey(m.ctx.Platform, m.ctx.Version, m.ctx.DataDir, m.ctx.DataKey, m.ctx.Cipher); err == nil {
+			return "", skipStep("the saved key is valid")
+		}
+	}
+	if m.ctx.Current == nil {
+		return "", fmt.Errorf("the saved key of %s does not match %s and wechat is not running", m.ctx.Account, m.ctx.DataDir)
+	}
+	if err := m.GetDataKey(); err != nil {
+		return "", err
+	}
+	return "key extracted and saved to the config file", nil
+}
+
+// validateKey 使用数据目录中的数据库验证密钥，cipher 为账号记录的加密参数
+func validateKey(platform string, version int, dataDir, key string, cipher *common.CipherInfo) error {
+	b, err := hex.DecodeString(key)
+	if err != nil {
+		return err
+	}
+	validator, err := decrypt.NewValidatorWithCipher(platform, version, dataDir, cipher)
+	if err != nil {
+		return err
+	}
+	if !validator.Validate(b) {
+		return errors.ErrDecryptIncorrectKey
+	}
+	return nil
+}
+
+// autoDataDir 使用微信进程的数据目录，进程中读不到时查找本机最可能的数据目录
+func (m *Manager) autoDataDir(opts AutoOptions) (string, error) {
+	if m.ctx.DataDir != "" {
+		return m.ctx.DataDir, nil
+	}
+	d, err := iwechat.DefaultDataDir(m.ctx.Version)
+	if err != nil {
+		return "", err
+	}
+	m.ctx.SetDataDir(d.Dir)
+	if err := validateKey(m.ctx.Platform, m.ctx.Version, d.Dir, m.ctx.DataKey, m.ctx.Cipher); err != nil {
+		return "", fmt.Errorf("the key does not match the data dir %s found: %w", d.Dir, err)
+	}
+	return d.Dir + " (found on disk)", nil
+}
+
+// autoDecrypt 增量解密到工作目录，重复执行时只解密变化的页面
+func (m *Manager) autoDecrypt(opts AutoOptions) (string, error) {
+	workDir := cmp.Or(opts.WorkDir, m.ctx.WorkDir, util.DefaultWorkDir(m.ctx.Account))
+	if workDir != m.ctx.WorkDir {
+		m.ctx.SetWorkDir(workDir)
+	}
+	wechat.IncrementalDecrypt = true
+	if err := m.DecryptDBFiles(); err != nil {
+		return "", err
+	}
+	return workDir, nil
+}
+
+// autoIndex 打开工作目录中的数据，更新已建立的搜索索引，未建立时建立，中断过的建立继续进行
+func (m *Manager) autoIndex(opts AutoOptions) (string, error) {
+	if err := m.db.Start(); err != nil {
+		return "", err
+	}
+	if opts.NoIndex {
+		return "", skipStep("disabled by --no-index")
+	}
+	result, err := m.db.UpdateIndex()
+	building := err == search.ErrNotBuilt
+	if building {
+		result, err = m.db.RebuildIndex(search.Options{Tokenizer: search.DefaultTokenizer}, false)
+	}
+	if err != nil {
+		return "", err
+	}
+	if building && len(result.Failed) > 0 {
+		return fmt.Sprintf("%d messages of %d talkers indexed, failed: %v, index build will be retried on the next run", result.Messages, result.Talkers, result.Failed), nil
+	}
+	if len(result.Failed) > 0 {
+		return fmt.Sprintf("%d messages of %d talkers indexed, failed: %v", result.Messages, result.Talkers, result.Failed), nil
+	}
+	return fmt.Sprintf("%d messages of %d talkers indexed", result.Messages, result.Talkers), nil
+}
@@ -0,0 +1,70 @@
+package chatlog
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/aspnmy/chatlog/internal/chatlog/snapshot"
+	"github.com/aspnmy/chatlog/internal/wechatdb"
+)
+
+// backupSampleMessages 每个抽查的会话读取的消息数
+const backupSampleMessages = 5
+
+// CommandBackupVerify 将备份恢复到临时目录并校验：对照清单校验文件哈希（数据库以外的文件抽查 sample 个），
+// 打开每个数据库检查完整性，再抽查 sample 个会话的消息，确认备份确实可以恢复使用
+func (m *Manager) CommandBackupVerify(archive string, sample int) (*snapshot.Report, error) {
+	if archive == "" {
+		return nil, fmt.Errorf("archive is required")
+	}
+
+	tmp, err := os.MkdirTemp("", "chatlog-verify-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmp)
+
+	info, dir, err := snapshot.Restore(archive, tmp)
+	if err != nil {
+		return nil, err
+	}
+	report := snapshot.Verify(dir, info, sample)
+	report.Archive = archive
+	if !report.Passed() {
+		return report, nil
+	}
+
+	db, err := wechatdb.New(dir, info.Platform, info.WeChat)
+	if err != nil {
+		report.Fail("open restored data: %v", err)
+		return report, nil
+	}
+	defer db.Close()
+
+	// sample 小于 0 时读取全部会话（limit 为 0），为 0 时至少读取一个
+	limit := sample
+	if sample == 0 {
+		limit = 1
+	} else if sample < 0 {
+		limit = 0
+	}
+	sessions, err := db.GetSessions("", limit, 0)
+	if err != nil {
+		report.Fail("read sessions: %v", err)
+		return report, nil
+	}
+	for _, s := range sessions.Items {
+		report.Sessions++
+		messages, err := db.GetMessages(time.Time{}, time.Now(), s.UserName, "", "", backupSampleMessages, 0)
+		if err != nil {
+			report.Fail("read messages of %s: %v", s.UserName, err)
+			continue
+		}
+		report.Messages += len(messages)
+	}
+	if len(sessions.Items) == 0 {
+		report.Warn("no sessions found in the backup")
+	}
+	return report, nil
+}
@@ -0,0 +1,92 @@
+package chatlog
+
+import (
+	"fmt"
+
+	"github.com/aspnmy/chatlog/internal/chatlog/ctx"
+	"github.com/aspnmy/chatlog/internal/ui/browser"
+	"github.com/aspnmy/chatlog/internal/ui/menu"
+	"github.com/aspnmy/chatlog/internal/ui/palette"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// CommandBrowse 在终端中全屏浏览工作目录中的聊天记录，不启动 HTTP 服务
+func (m *Manager) CommandBrowse(dataDir string, workDir string, platform string, version int) error {
+	if workDir == "" {
+		return fmt.Errorf("workDir is required")
+	}
+
+	if platform == "" {
+		return fmt.Errorf("platform is required")
+	}
+
+	if version == 0 {
+		return fmt.Errorf("version is required")
+	}
+
+	m.ctx.DataDir = dataDir
+	m.ctx.WorkDir = workDir
+	m.ctx.Platform = platform
+	m.ctx.Version = version
+	if m.ctx.ArchiveOnly {
+		m.ctx.DataDir = ctx.ArchiveMediaDir(workDir)
+	}
+
+	if err := m.db.Start(); err != nil {
+		return err
+	}
+	defer m.db.Stop()
+
+	app := tview.NewApplication()
+	b := browser.New(app, m.db, m.ctx.Locked, func() string { return "" })
+	b.SetDoneFunc(app.Stop)
+	pages := tview.NewPages().AddPage(browser.Title, b, true, true)
+	app.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		if event.Key() == tcell.KeyCtrlP && !pages.HasPage(palette.Title) {
+			palette.Show(app, pages, b.Commands())
+			return nil
+		}
+		return event
+	})
+	go b.Load()
+	return app.SetRoot(pages, true).Run()
+}
+
+// browseSelected 在主界面中打开聊天记录浏览器，HTTP 服务未启动时临时打开数据库，退出浏览时关闭
+func (a *App) browseSelected(i *menu.Item) {
+	started := !a.ctx.HTTPEnabled
+	if started {
+		if err := a.m.db.Start(); err != nil {
+			modal := tview.NewModal().
+				SetText("打开数据库失败: " + err.Error()).
+				AddButtons([]string{"OK"}).
+				SetDoneFunc(func(buttonIndex int, buttonLabel string) {
+					a.mainPages.RemovePage("modal")
+				})
+			a.mainPages.AddPage("modal", modal, true, true)
+			a.SetFocus(modal)
+			return
+		}
+	}
+
+	b := browser.New(a.Application, a.m.db, a.ctx.Locked, func() string {
+		if a.ctx.HTTPEnabled {
+			return a.ctx.HTTPAddr
+		}
+		return ""
+	})
+	b.SetDoneFunc(func() {
+		a.browser = nil
+		a.mainPages.RemovePage(browser.Title)
+		a.mainPages.SwitchToPage("main")
+		if started {
+			a.m.db.Stop()
+		}
+	})
+	a.browser = b
+	a.mainPages.AddPage(browser.Title, b, true, true)
+	a.SetFocus(b)
+	go b.Load()
+}
@@ -1,11 +1,214 @@
 package conf
 
-import "github.com/aspnmy/chatlog/pkg/config"
+import (
+	"cmp"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/aspnmy/chatlog/internal/wechat/decrypt/common"
+	"github.com/aspnmy/chatlog/pkg/config"
+	"github.com/aspnmy/chatlog/pkg/publish"
+	"github.com/aspnmy/chatlog/pkg/translate"
+)
+
+// DefaultSynonymFile 未配置 synonym_file 时使用配置目录下的同义词文件
+const DefaultSynonymFile = "synonyms.txt"
+
+// KeyStatsFile 配置目录下记录密钥搜索策略命中次数的文件
+const KeyStatsFile = "key_stats.json"
+
+// KeyStoreFile 配置目录下加密保存各账号密钥的缓存文件
+const KeyStoreFile = "keys.dat"
+
+// ExportDir 配置目录下保存通过 HTTP 接口生成的导出文件的目录
+const ExportDir = "exports"
 
 type Config struct {
 	ConfigDir   string          `mapstructure:"-"`
 	LastAccount string          `mapstructure:"last_account" json:"last_account"`
 	History     []ProcessConfig `mapstructure:"history" json:"history"`
+	SynonymFile string          `mapstructure:"synonym_file" json:"synonym_file"`
+	SMTP        *SMTPConfig     `mapstructure:"smtp" json:"smtp,omitempty"`
+	Notion      *NotionConfig   `mapstructure:"notion" json:"notion,omitempty"`
+	Feishu      *FeishuConfig   `mapstructure:"feishu" json:"feishu,omitempty"`
+
+	// Flags 命令行参数的默认值，键为参数名（不含 --），如 work-dir、strategies，由 chatlog config set 设置
+	// 值为命令行中的写法，列表也可以写为数组；命令行中指定的参数优先，其次为环境变量 CHATLOG_<参数名>，见 FlagEnv
+	Flags map[string]interface{} `mapstructure:"flags" json:"flags,omitempty"`
+
+	// Translate 翻译消息使用的服务，见 TranslateConfig
+	Translate *TranslateConfig `mapstructure:"translate" json:"translate,omitempty"`
+
+	// ExportProfiles 命名的导出参数，见 ExportProfile
+	ExportProfiles map[string]ExportProfile `mapstructure:"export_profiles" json:"export_profiles,omitempty"`
+
+	// Digest 每周发送的聊天记录周报，见 DigestConfig
+	Digest *DigestConfig `mapstructure:"digest" json:"digest,omitempty"`
+
+	// Webhooks chatlog daemon 推送新消息的 Webhook，见 WebhookConfig
+	Webhooks []WebhookConfig `mapstructure:"webhooks" json:"webhooks,omitempty"`
+
+	// Dates 导出、统计与 Web 页面中日期的显示方式，见 DatesConfig
+	Dates *DatesConfig `mapstructure:"dates" json:"dates,omitempty"`
+
+	// Topics 话题标签的约定，键为话题名称，值为该话题的标签，如 decision: ["#决定"]
+	// 带有任一标签的消息属于该话题，用于按话题导出与查询，见 hashtag.Matcher
+	Topics map[string][]string `mapstructure:"topics" json:"topics,omitempty"`
+
+	// Lock 需要口令才能查看的会话，见 LockConfig
+	Lock *LockConfig `mapstructure:"lock" json:"lock,omitempty"`
+
+	// AdminToken 访问 /api/v1/admin 管理接口的令牌，为空时管理接口不可用
+	AdminToken string `mapstructure:"admin_token" json:"admin_token,omitempty"`
+
+	// NoKeyCache 不在本机缓存提取到的密钥，每次都从微信进程中提取
+	NoKeyCache bool `mapstructure:"no_key_cache" json:"no_key_cache,omitempty"`
+
+	// IncrementalDecrypt 重新解密时只解密变化的页面，见 wechat.IncrementalDecrypt
+	IncrementalDecrypt bool `mapstructure:"incremental_decrypt" json:"incremental_decrypt,omitempty"`
+
+	// ArchiveOnly 只使用工作目录中的数据，不访问微信进程与数据目录，见 ctx.Context.ArchiveOnly
+	ArchiveOnly bool `mapstructure:"archive_only" json:"archive_only,omitempty"`
+}
+
+// EnvAdminToken 未在配置文件中设置管理令牌时从该环境变量读取
+const EnvAdminToken = "CHATLOG_ADMIN_TOKEN"
+
+// GetAdminToken 返回管理接口的令牌，优先使用配置文件中的设置
+func (c *Config) GetAdminToken() string {
+	return cmp.Or(c.AdminToken, os.Getenv(EnvAdminToken))
+}
+
+// EnvSMTPPassword 未在配置文件中设置 SMTP 密码时从该环境变量读取
+const EnvSMTPPassword = "CHATLOG_SMTP_PASSWORD"
+
+// SMTPConfig 导出与快照完成后发送摘要邮件、发送周报使用的 SMTP 服务器
+type SMTPConfig struct {
+	Host     string   `mapstructure:"host" json:"host"`
+	Port     int      `mapstructure:"port" json:"port"`
+	Username string   `mapstructure:"username" json:"username"`
+	Password string   `mapstructure:"password" json:"password"`
+	From     string   `mapstructure:"from" json:"from"`
+	To       []string `mapstructure:"to" json:"to"`
+
+	// OnlyFailures 仅在失败或部分会话失败时发送
+	OnlyFailures bool `mapstructure:"only_failures" json:"only_failures"`
+}
+
+// DefaultSMTPPort 未配置端口时使用的 SMTP 提交端口
+const DefaultSMTPPort = 587
+
+// Enabled 返回是否配置了邮件通知
+func (c *SMTPConfig) Enabled() bool {
+	return c != nil && c.Host != "" && len(c.To) > 0
+}
+
+const (
+	// EnvNotionToken 未在配置文件中设置 Notion 令牌时从该环境变量读取
+	EnvNotionToken = "CHATLOG_NOTION_TOKEN"
+	// EnvFeishuAppSecret 未在配置文件中设置飞书应用密钥时从该环境变量读取
+	EnvFeishuAppSecret = "CHATLOG_FEISHU_APP_SECRET"
+)
+
+// NotionConfig chatlog push notion 使用的 Notion 集成，会话创建为 parent 页面的子页面
+type NotionConfig struct {
+	Token  string  `mapstructure:"token" json:"token"`
+	Parent string  `mapstructure:"parent" json:"parent"`
+	Rate   float64 `mapstructure:"rate" json:"rate,omitempty"` // 每秒请求数，默认 3
+}
+
+// FeishuConfig chatlog push feishu 使用的飞书自建应用，会话创建为 folder 中的云文档
+type FeishuConfig struct {
+	AppID     string  `mapstructure:"app_id" json:"app_id"`
+	AppSecret string  `mapstructure:"app_secret" json:"app_secret"`
+	Folder    string  `mapstructure:"folder" json:"folder"`       // 文件夹 token，为空时创建在应用的根目录
+	API       string  `mapstructure:"api" json:"api,omitempty"`   // 开放平台地址，Lark 国际版为 https://open.larksuite.com
+	Rate      float64 `mapstructure:"rate" json:"rate,omitempty"` // 每秒请求数，默认 3
+}
+
+// 推送目标
+const (
+	PublishNotion = "notion"
+	PublishFeishu = "feishu"
+)
+
+// Publisher 按配置创建推送目标，target 为 notion 或 feishu
+func (c *Config) Publisher(target string) (publish.Publisher, error) {
+	switch target {
+	case PublishNotion:
+		n := c.Notion
+		if n == nil || n.Parent == "" {
+			return nil, fmt.Errorf("notion.parent is not configured")
+		}
+		token := cmp.Or(n.Token, os.Getenv(EnvNotionToken))
+		if token == "" {
+			return nil, fmt.Errorf("notion token is empty, set notion.token in the config file or %s", EnvNotionToken)
+		}
+		return publish.NewNotion(token, n.Parent, n.Rate), nil
+	case PublishFeishu:
+		f := c.Feishu
+		if f == nil || f.AppID == "" {
+			return nil, fmt.Errorf("feishu.app_id is not configured")
+		}
+		secret := cmp.Or(f.AppSecret, os.Getenv(EnvFeishuAppSecret))
+		if secret == "" {
+			return nil, fmt.Errorf("feishu app secret is empty, set feishu.app_secret in the config file or %s", EnvFeishuAppSecret)
+		}
+		return publish.NewFeishu(f.API, f.AppID, secret, f.Folder, f.Rate), nil
+	default:
+		return nil, fmt.Errorf("unsupported push target %q, use %s or %s", target, PublishNotion, PublishFeishu)
+	}
+}
+
+// EnvTranslateAPIKey 未在配置文件中设置翻译服务密钥时从该环境变量读取
+const EnvTranslateAPIKey = "CHATLOG_TRANSLATE_API_KEY"
+
+// TranslateConfig 翻译消息使用的服务，译文保存在工作目录中，同一段原文只翻译一次
+type TranslateConfig struct {
+	Provider string `mapstructure:"provider" json:"provider"`             // openai 或 deepl
+	API      string `mapstructure:"api" json:"api,omitempty"`             // 服务地址，兼容 OpenAI 接口的服务（如 Ollama）在这里指定
+	APIKey   string `mapstructure:"api_key" json:"api_key,omitempty"`     // 服务密钥
+	Model    string `mapstructure:"model" json:"model,omitempty"`         // openai 使用的模型
+	Lang     string `mapstructure:"lang" json:"lang,omitempty"`           // chatlog translate 未指定 --lang 时的目标语言
+	OnDemand bool   `mapstructure:"on_demand" json:"on_demand,omitempty"` // 请求带 lang 参数时翻译尚未翻译的消息，否则只返回已保存的译文
+}
+
+// Translator 按配置创建翻译服务，未配置时返回 nil
+func (c *Config) Translator() (translate.Translator, error) {
+	t := c.Translate
+	if t == nil || t.Provider == "" {
+		return nil, nil
+	}
+	key := cmp.Or(t.APIKey, os.Getenv(EnvTranslateAPIKey))
+	if key == "" && t.Provider == translate.ProviderDeepL {
+		return nil, fmt.Errorf("translate api key is empty, set translate.api_key in the config file or %s", EnvTranslateAPIKey)
+	}
+	return translate.New(t.Provider, t.API, key, t.Model)
+}
+
+// SynonymPath 返回搜索使用的同义词文件路径
+func (c *Config) SynonymPath() string {
+	if c.SynonymFile != "" {
+		return c.SynonymFile
+	}
+	return filepath.Join(c.ConfigDir, DefaultSynonymFile)
+}
+
+// KeyStatsPath 返回密钥搜索策略命中统计的文件路径
+func (c *Config) KeyStatsPath() string {
+	return filepath.Join(c.ConfigDir, KeyStatsFile)
+}
+
+// KeyStorePath 返回密钥缓存文件路径，见 pkg/keystore
+func (c *Config) KeyStorePath() string {
+	return filepath.Join(c.ConfigDir, KeyStoreFile)
+}
+
+// ExportPath 返回通过 HTTP 接口生成的导出文件所在目录
+func (c *Config) ExportPath() string {
+	return filepath.Join(c.ConfigDir, ExportDir)
 }
 
 type ProcessConfig struct {
@@ -21,7 +224,21 @@ type ProcessConfig struct {
 	HTTPEnabled bool   `mapstructure:"http_enabled" json:"http_enabled"`
 	HTTPAddr    string `mapstructure:"http_addr" json:"http_addr"`
 	LastTime    int64  `mapstructure:"last_time" json:"last_time"`
+	KeyTime     int64  `mapstructure:"key_time" json:"key_time,omitempty"`
 	Files       []File `mapstructure:"files" json:"files"`
+
+	// Cipher 验证密钥时使用的数据库文件与加密参数，解密时使用相同的参数
+	Cipher *common.CipherInfo `mapstructure:"cipher" json:"cipher,omitempty"`
+
+	// Legacy 同一账号旧版本（如 3.x）的数据，与当前版本的数据合并为一条时间线
+	Legacy *LegacyConfig `mapstructure:"legacy" json:"legacy,omitempty"`
+}
+
+type LegacyConfig struct {
+	Platform string `mapstructure:"platform" json:"platform"`
+	Version  int    `mapstructure:"version" json:"version"`
+	DataDir  string `mapstructure:"data_dir" json:"data_dir"`
+	WorkDir  string `mapstructure:"work_dir" json:"work_dir"`
 }
 
 type File struct {
@@ -60,3 +277,43 @@ func (c *Config) UpdateHistory(account string, conf ProcessConfig) error {
 	config.SetConfig("last_account", account)
 	return config.SetConfig("history", c.History)
 }
+
+// RemoveHistory 删除账号的历史记录，删除的是 last_account 时清空 last_account
+func (c *Config) RemoveHistory(account string) error {
+	for i, v := range c.History {
+		if v.Account == account {
+			// GetConfig 返回的副本与原配置共用 History，不能原地删除
+			c.History = slices.Delete(slices.Clone(c.History), i, i+1)
+			if c.LastAccount == account {
+				c.LastAccount = ""
+				config.SetConfig("last_account", "")
+			}
+			return config.SetConfig("history", c.History)
+		}
+	}
+	return fmt.Errorf("account %s not found in history", account)
+}
+
+// SetLastAccount 设置下次启动时使用的账号
+func (c *Config) SetLastAccount(account string) error {
+	c.LastAccount = account
+	return config.SetConfig("last_account", account)
+}
+
+// MergeHistory 按账号更新或追加多条历史记录，不改变 last_account
+func (c *Config) MergeHistory(confs []ProcessConfig) error {
+	for _, conf := range confs {
+		isFind := false
+		for i, v := range c.History {
+			if v.Account == conf.Account {
+				isFind = true
+				c.History[i] = conf
+				break
+			}
+		}
+		if !isFind {
+			c.History = append(c.History, conf)
+		}
+	}
+	return config.SetConfig("history", c.History)
+}
@@ -0,0 +1,23 @@
+package conf
+
+import "github.com/aspnmy/chatlog/pkg/datefmt"
+
+// DatesConfig 导出、统计与 Web 页面中日期的显示方式，见 datefmt.Style
+type DatesConfig struct {
+	// Format 日期格式，预置格式 iso、zh、us、eu 或 Go 时间格式（如 2006/01/02 Mon），默认 iso
+	Format string `mapstructure:"format" json:"format,omitempty"`
+	// Locale 星期与月份名称的语言，en 或 zh，默认 en
+	Locale string `mapstructure:"locale" json:"locale,omitempty"`
+	// WeekStart 每周的第一天，如 sunday，默认周一，用于按周统计
+	WeekStart string `mapstructure:"week_start" json:"week_start,omitempty"`
+	// Lunar 在日期后附加农历月日与传统节日，如 2024-02-10（正月初一 春节）
+	Lunar bool `mapstructure:"lunar" json:"lunar,omitempty"`
+}
+
+// Style 返回日期的显示方式，未配置时为 datefmt.Default
+func (c *DatesConfig) Style() (datefmt.Style, error) {
+	if c == nil {
+		return datefmt.Default, nil
+	}
+	return datefmt.New(c.Format, c.Locale, c.WeekStart, c.Lunar)
+}
@@ -0,0 +1,42 @@
+package conf
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/aspnmy/chatlog/pkg/datefmt"
+)
+
+// DigestConfig 服务运行期间每周通过邮件发送的聊天记录周报，需要同时配置 smtp
+type DigestConfig struct {
+	Enabled bool `mapstructure:"enabled" json:"enabled"`
+
+	// Weekday 发送日，如 monday，默认周一
+	Weekday string `mapstructure:"weekday" json:"weekday,omitempty"`
+	// At 发送时刻（本地时间），格式 15:04，默认 09:00
+	At string `mapstructure:"at" json:"at,omitempty"`
+	// Keywords 统计命中次数并列出部分消息的关键词，不区分大小写
+	Keywords []string `mapstructure:"keywords" json:"keywords,omitempty"`
+	// Top 每一项最多列出的会话或消息数，默认 5
+	Top int `mapstructure:"top" json:"top,omitempty"`
+}
+
+// Schedule 返回每周发送的星期与时刻（从 0 点开始的时长）
+func (c *DigestConfig) Schedule() (time.Weekday, time.Duration, error) {
+	weekday := time.Monday
+	if c.Weekday != "" {
+		var ok bool
+		if weekday, ok = datefmt.ParseWeekday(c.Weekday); !ok {
+			return 0, 0, fmt.Errorf("invalid weekday %q, expected monday-sunday", c.Weekday)
+		}
+	}
+	at := 9 * time.Hour
+	if c.At != "" {
+		t, err := time.Parse("15:04", c.At)
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid time %q, expected HH:MM", c.At)
+		}
+		at = time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute
+	}
+	return weekday, at, nil
+}
@@ -0,0 +1,104 @@
+package conf
+
+import (
+	"fmt"
+	"os"
+
+	"golang.org/x/term"
+
+	"github.com/aspnmy/chatlog/pkg/config"
+	"github.com/aspnmy/chatlog/pkg/keychain"
+)
+
+const (
+	// EnvPassphrase 配置文件加密时从该环境变量读取口令，适用于服务、容器等无法交互输入的场景
+	EnvPassphrase = "CHATLOG_PASSPHRASE"
+
+	// KeychainService 系统凭据存储中保存口令使用的服务名，账号为配置目录
+	KeychainService = "chatlog"
+)
+
+func init() {
+	config.Unlock = unlock
+}
+
+// unlock 获取加密配置文件的口令，依次尝试环境变量、系统凭据存储与终端输入
+// 成功解密后口令在本次运行中缓存，不会重复询问
+func unlock() (string, error) {
+	if p := storedPassphrase(); p != "" {
+		return p, nil
+	}
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return "", fmt.Errorf("%w, set %s or store it in the keychain with chatlog config encrypt --keychain", config.ErrPassphraseRequired, EnvPassphrase)
+	}
+	return ReadPassphrase(fmt.Sprintf("Passphrase for %s: ", config.File()))
+}
+
+// storedPassphrase 返回环境变量或系统凭据存储中的口令，都没有时返回空
+func storedPassphrase() string {
+	if p := os.Getenv(EnvPassphrase); p != "" {
+		return p
+	}
+	if p, err := keychain.Get(KeychainService, config.ConfigPath); err == nil {
+		return p
+	}
+	return ""
+}
+
+// ReadPassphrase 在终端中读取口令，输入内容不回显
+func ReadPassphrase(prompt string) (string, error) {
+	fmt.Fprint(os.Stderr, prompt)
+	b, err := term.ReadPassword(int(os.Stdin.Fd()))
+	fmt.Fprintln(os.Stderr)
+	return string(b), err
+}
+
+// open 初始化并加载配置目录，与 Service.Load 相同，但出错时返回错误而不是退出
+func open(configPath string) error {
+	if configPath == "" {
+		configPath = os.Getenv(EnvConfigDir)
+	}
+	if err := config.Init(ConfigName, ConfigType, configPath); err != nil {
+		return err
+	}
+	return config.Load(&Config{})
+}
+
+// Encrypt 使用口令加密配置文件（包括其中保存的各账号密钥），已加密时更换为新口令
+// useKeychain 为 true 时将口令保存到系统凭据存储，之后运行无需再输入
+func Encrypt(configPath, passphrase string, useKeychain bool) (string, error) {
+	if passphrase == "" {
+		return "", config.ErrPassphraseRequired
+	}
+	if err := open(configPath); err != nil {
+		return "", err
+	}
+	config.SetPassphrase(passphrase)
+	if err := config.Save(); err != nil {
+		return "", err
+	}
+	if useKeychain {
+		if err := keychain.Set(KeychainService, config.ConfigPath, passphrase); err != nil {
+			return config.File(), fmt.Errorf("config encrypted, but failed to store passphrase in keychain: %w", err)
+		}
+	} else {
+		// 更换口令时旧口令已失效，尽力删除即可
+		keychain.Delete(KeychainService, config.ConfigPath)
+	}
+	return config.File(), nil
+}
+
+// Decrypt 将配置文件恢复为明文，并删除系统凭据存储中的口令
+func Decrypt(configPath string) (string, error) {
+	if err := open(configPath); err != nil {
+		return "", err
+	}
+	config.SetPassphrase("")
+	if err := config.Save(); err != nil {
+		return "", err
+	}
+	if err := keychain.Delete(KeychainService, config.ConfigPath); err != nil && err != keychain.ErrUnsupported {
+		return config.File(), fmt.Errorf("config decrypted, but failed to remove passphrase from keychain: %w", err)
+	}
+	return config.File(), nil
+}
@@ -0,0 +1,187 @@
+package conf
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/viper"
+
+	"github.com/aspnmy/chatlog/pkg/config"
+)
+
+// EnvFlagPrefix 命令行参数对应的环境变量前缀，见 FlagEnv
+const EnvFlagPrefix = "CHATLOG_"
+
+// FlagEnv 返回命令行参数对应的环境变量，如 --work-dir 对应 CHATLOG_WORK_DIR
+func FlagEnv(name string) string {
+	return EnvFlagPrefix + strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+}
+
+// FlagsFile 返回只保存参数值的 YAML 文件 $XDG_CONFIG_HOME/chatlog/config.yaml，
+// 未设置 XDG_CONFIG_HOME 时为 ~/.config/chatlog/config.yaml；文件中以参数名为键，如 work-dir: /path
+func FlagsFile() string {
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		dir = filepath.Join(home, ".config")
+	}
+	return filepath.Join(dir, ConfigName, "config.yaml")
+}
+
+// FlagDefaults 读取配置文件 flags 中保存的参数值与 FlagsFile 中的参数值，前者优先，都没有时返回空
+// 在加载配置之前调用，与 Service.Load 不同，不会创建配置文件，出错时返回错误而不是退出；
+// prompt 为 false 时不在终端中询问口令，配置文件已加密且环境变量与系统凭据存储中都没有口令时忽略该文件
+func FlagDefaults(configPath string, prompt bool) (map[string]string, error) {
+	flags, err := readFlagsFile(FlagsFile())
+	if err != nil {
+		return nil, err
+	}
+	raw, err := readRaw(configPath, prompt)
+	if err != nil || raw == nil {
+		return flags, err
+	}
+	entry, _ := raw["flags"].(map[string]interface{})
+	if flags == nil {
+		flags = make(map[string]string, len(entry))
+	}
+	maps.Copy(flags, (&Config{Flags: entry}).FlagValues())
+	return flags, nil
+}
+
+// accountFlags 账号保存的参数对应的命令行参数名
+var accountFlags = map[string]string{
+	"data_dir": "data-dir",
+	"work_dir": "work-dir",
+	"platform": "platform",
+	"version":  "version",
+	"data_key": "key",
+	"img_key":  "img-key",
+}
+
+// AccountFlags 返回配置文件中账号 account 保存的数据目录、工作目录、平台、版本与密钥，以命令行参数名为键，
+// 用于 --account 填充未指定的参数；账号不存在时返回空，读取配置文件的方式与 FlagDefaults 相同
+func AccountFlags(configPath, account string, prompt bool) (map[string]string, error) {
+	raw, err := readRaw(configPath, prompt)
+	if err != nil || raw == nil {
+		return nil, err
+	}
+	history, _ := raw["history"].([]interface{})
+	for _, h := range history {
+		p, _ := h.(map[string]interface{})
+		if p == nil || p["account"] != account {
+			continue
+		}
+		flags := make(map[string]string, len(accountFlags))
+		for key, name := range accountFlags {
+			if v := flagValue(p[key]); p[key] != nil && v != "" && v != "0" {
+				flags[name] = v
+			}
+		}
+		return flags, nil
+	}
+	return nil, nil
+}
+
+// readRaw 读取配置文件的原始内容，不会创建配置文件；文件不存在，
+// 或 prompt 为 false 时配置文件已加密且环境变量与系统凭据存储中都没有口令，返回空
+func readRaw(configPath string, prompt bool) (map[string]interface{}, error) {
+	if configPath == "" {
+		configPath = os.Getenv(EnvConfigDir)
+	}
+	if err := config.Init(ConfigName, ConfigType, configPath); err != nil {
+		return nil, err
+	}
+	b, err := os.ReadFile(config.File())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if !prompt && config.IsEncrypted(b) && !config.Encrypted() && storedPassphrase() == "" {
+		return nil, nil
+	}
+	return config.ReadFile(config.File())
+}
+
+// readFlagsFile 读取 YAML 文件中的参数值，文件不存在时返回空
+func readFlagsFile(file string) (map[string]string, error) {
+	if file == "" {
+		return nil, nil
+	}
+	if _, err := os.Stat(file); err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	v := viper.New()
+	v.SetConfigFile(file)
+	if err := v.ReadInConfig(); err != nil {
+		return nil, fmt.Errorf("read %s failed: %w", file, err)
+	}
+	return (&Config{Flags: v.AllSettings()}).FlagValues(), nil
+}
+
+// FlagValues 返回配置文件中保存的参数值
+func (c *Config) FlagValues() map[string]string {
+	flags := make(map[string]string, len(c.Flags))
+	for name, v := range c.Flags {
+		flags[name] = flagValue(v)
+	}
+	return flags
+}
+
+// flagValue 将配置文件中的值转换为命令行参数的写法，列表以逗号连接
+func flagValue(v interface{}) string {
+	if list, ok := v.([]interface{}); ok {
+		items := make([]string, 0, len(list))
+		for _, item := range list {
+			items = append(items, fmt.Sprint(item))
+		}
+		return strings.Join(items, ",")
+	}
+	return fmt.Sprint(v)
+}
+
+// SetFlag 保存参数值，之后运行的命令在未指定该参数时使用
+func (c *Config) SetFlag(name, value string) error {
+	flags := maps.Clone(c.Flags)
+	if flags == nil {
+		flags = make(map[string]interface{})
+	}
+	flags[name] = value
+	c.Flags = flags
+	return config.SetConfig("flags", flags)
+}
+
+// UnsetFlag 删除保存的参数值，没有保存时返回错误
+func (c *Config) UnsetFlag(name string) error {
+	if _, ok := c.Flags[name]; !ok {
+		return fmt.Errorf("flag %s is not set in the config file", name)
+	}
+	flags := maps.Clone(c.Flags)
+	delete(flags, name)
+	c.Flags = flags
+	return config.UnsetConfig("flags." + name)
+}
+
+// MaskFlag 隐藏密钥、令牌、口令等参数的值，用于展示，其他参数与 --force-key 等开关原样返回
+func MaskFlag(name, value string) string {
+	if _, err := strconv.ParseBool(value); err == nil {
+		return value
+	}
+	for _, secret := range []string{"key", "token", "password", "secret", "passphrase"} {
+		if strings.Contains(name, secret) {
+			return mask(value)
+		}
+	}
+	return value
+}
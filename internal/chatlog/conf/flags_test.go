@@ -0,0 +1,95 @@
+package conf
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/spf13/viper"
+
+	"github.com/aspnmy/chatlog/pkg/config"
+)
+
+func TestFlagDefaults(t *testing.T) {
+	t.Cleanup(viper.Reset)
+	if got := FlagEnv("work-dir"); got != "CHATLOG_WORK_DIR" {
+		t.Errorf("FlagEnv = %s", got)
+	}
+
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv(EnvPassphrase, "")
+	dir := t.TempDir()
+	if flags, err := FlagDefaults(dir, false); err != nil || len(flags) != 0 {
+		t.Fatalf("without config file: %v, %v", flags, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "chatlog.json")); err == nil {
+		t.Error("config file created")
+	}
+
+	content := `{"flags": {"work-dir": "/w", "strategies": ["weixin_dll", "base_pattern"], "debug": true, "workers": 2}}`
+	if err := os.WriteFile(filepath.Join(dir, "chatlog.json"), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	flags, err := FlagDefaults(dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"work-dir": "/w", "strategies": "weixin_dll,base_pattern", "debug": "true", "workers": "2"}
+	if !reflect.DeepEqual(flags, want) {
+		t.Errorf("flags = %v, want %v", flags, want)
+	}
+
+	// 设置与删除后重新读取配置文件
+	s, err := NewService(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.GetConfig().SetFlag("addr", "0.0.0.0:5030"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.GetConfig().UnsetFlag("work-dir"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.GetConfig().UnsetFlag("nope"); err == nil {
+		t.Error("unset flag removed")
+	}
+	flags, _ = FlagDefaults(dir, false)
+	if _, ok := flags["work-dir"]; ok || flags["addr"] != "0.0.0.0:5030" || flags["debug"] != "true" {
+		t.Errorf("flags = %v", flags)
+	}
+
+	// config.yaml 中的值优先级低于配置文件 flags 中的值
+	if err := os.MkdirAll(filepath.Dir(FlagsFile()), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(FlagsFile(), []byte("addr: 127.0.0.1:5031\nlog-level: debug\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	flags, err = FlagDefaults(dir, false)
+	if err != nil || flags["addr"] != "0.0.0.0:5030" || flags["log-level"] != "debug" {
+		t.Errorf("flags = %v, %v", flags, err)
+	}
+
+	// 配置文件已加密且没有保存的口令时，不询问口令，只使用 config.yaml
+	if _, err := Encrypt(dir, "secret", false); err != nil {
+		t.Fatal(err)
+	}
+	config.SetPassphrase("")
+	t.Cleanup(func() { config.SetPassphrase("") })
+	flags, err = FlagDefaults(dir, false)
+	if err != nil || len(flags) != 2 || flags["addr"] != "127.0.0.1:5031" {
+		t.Errorf("encrypted config: %v, %v", flags, err)
+	}
+	t.Setenv(EnvPassphrase, "secret")
+	if flags, _ = FlagDefaults(dir, false); flags["addr"] != "0.0.0.0:5030" {
+		t.Errorf("encrypted config with %s: %v", EnvPassphrase, flags)
+	}
+
+	if got := MaskFlag("key", "0123456789"); got != "0123********" {
+		t.Errorf("MaskFlag(key) = %s", got)
+	}
+	if got := MaskFlag("force-key", "true"); got != "true" {
+		t.Errorf("MaskFlag(force-key) = %s", got)
+	}
+}
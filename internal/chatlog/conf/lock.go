@@ -0,0 +1,80 @@
+package conf
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/aspnmy/chatlog/pkg/config"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// 锁定口令哈希的 scrypt 参数，每次校验约需数十毫秒，可以减缓暴力猜测
+const (
+	lockScryptN = 1 << 15
+	lockScryptR = 8
+	lockScryptP = 1
+)
+
+// LockConfig 锁定的会话，通过 HTTP 接口查看时需要在请求头中提供口令，MCP 不返回这些会话
+type LockConfig struct {
+	Talkers    []string `mapstructure:"talkers" json:"talkers"`       // 聊天对象 ID（wxid 或群聊 ID）
+	Passphrase string   `mapstructure:"passphrase" json:"passphrase"` // 口令的 scrypt 哈希，见 HashLockPassphrase
+}
+
+// Enabled 返回是否有锁定的会话
+func (l *LockConfig) Enabled() bool {
+	return l != nil && len(l.Talkers) > 0
+}
+
+// SetLock 保存锁定的会话，会话按 ID 排序去重
+func (c *Config) SetLock(lock *LockConfig) error {
+	slices.Sort(lock.Talkers)
+	lock.Talkers = slices.Compact(lock.Talkers)
+	c.Lock = lock
+	return config.SetConfig("lock", lock)
+}
+
+// HashLockPassphrase 返回保存在配置文件中的口令哈希，格式为 scrypt$N$r$p$salt$hash
+func HashLockPassphrase(passphrase string) (string, error) {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key, err := scrypt.Key([]byte(passphrase), salt, lockScryptN, lockScryptR, lockScryptP, 32)
+	if err != nil {
+		return "", err
+	}
+	enc := base64.RawStdEncoding
+	return fmt.Sprintf("scrypt$%d$%d$%d$%s$%s", lockScryptN, lockScryptR, lockScryptP, enc.EncodeToString(salt), enc.EncodeToString(key)), nil
+}
+
+// CheckLockPassphrase 校验口令与 HashLockPassphrase 生成的哈希是否匹配
+func CheckLockPassphrase(hash, passphrase string) bool {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[0] != "scrypt" {
+		return false
+	}
+	var n, r, p int
+	if _, err := fmt.Sscanf(parts[1]+" "+parts[2]+" "+parts[3], "%d %d %d", &n, &r, &p); err != nil {
+		return false
+	}
+	enc := base64.RawStdEncoding
+	salt, err := enc.DecodeString(parts[4])
+	if err != nil {
+		return false
+	}
+	want, err := enc.DecodeString(parts[5])
+	if err != nil {
+		return false
+	}
+	key, err := scrypt.Key([]byte(passphrase), salt, n, r, p, len(want))
+	if err != nil {
+		return false
+	}
+	return subtle.ConstantTimeCompare(key, want) == 1
+}
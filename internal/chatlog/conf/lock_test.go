@@ -0,0 +1,23 @@
+package conf
+
+import "testing"
+
+func TestLockPassphrase(t *testing.T) {
+	hash, err := HashLockPassphrase("correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !CheckLockPassphrase(hash, "correct horse") {
+		t.Error("correct passphrase rejected")
+	}
+	for _, p := range []string{"", "correct horse ", "Correct horse"} {
+		if CheckLockPassphrase(hash, p) {
+			t.Errorf("%q accepted", p)
+		}
+	}
+	for _, h := range []string{"", "correct horse", "scrypt$1$8$1$$", "bcrypt$32768$8$1$c2FsdA$a2V5"} {
+		if CheckLockPassphrase(h, "correct horse") {
+			t.Errorf("hash %q accepted", h)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package conf
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ExportProfile 命名的导出参数，chatlog export --profile <name> 使用，命令行中显式指定的参数优先
+// Extends 指定继承的 profile，未设置的字段沿用被继承 profile 的值
+type ExportProfile struct {
+	Extends string `mapstructure:"extends" json:"extends,omitempty"`
+
+	WorkDir  string `mapstructure:"work_dir" json:"work_dir,omitempty"`
+	Platform string `mapstructure:"platform" json:"platform,omitempty"`
+	Version  int    `mapstructure:"version" json:"version,omitempty"`
+
+	Format  string `mapstructure:"format" json:"format,omitempty"`
+	Talker  string `mapstructure:"talker" json:"talker,omitempty"`
+	Time    string `mapstructure:"time" json:"time,omitempty"`
+	Dest    string `mapstructure:"dest" json:"dest,omitempty"`
+	DataDir string `mapstructure:"data_dir" json:"data_dir,omitempty"`
+	ImgKey  string `mapstructure:"img_key" json:"img_key,omitempty"`
+	Lang    string `mapstructure:"lang" json:"lang,omitempty"`
+
+	NameTemplate string `mapstructure:"name_template" json:"name_template,omitempty"`
+	Topic        string `mapstructure:"topic" json:"topic,omitempty"`
+	Status       string `mapstructure:"status" json:"status,omitempty"`
+
+	VoiceFormat   string `mapstructure:"voice_format" json:"voice_format,omitempty"`
+	MP3SampleRate int    `mapstructure:"mp3_sample_rate" json:"mp3_sample_rate,omitempty"`
+	MP3Bitrate    int    `mapstructure:"mp3_bitrate" json:"mp3_bitrate,omitempty"`
+	MP3Channels   int    `mapstructure:"mp3_channels" json:"mp3_channels,omitempty"`
+
+	// 布尔值为 nil 时表示未设置，区别于显式设置为 false
+	NormalizeTime    *bool  `mapstructure:"normalize_time" json:"normalize_time,omitempty"`
+	EncryptPerTalker *bool  `mapstructure:"encrypt_per_talker" json:"encrypt_per_talker,omitempty"`
+	PasswordFile     string `mapstructure:"password_file" json:"password_file,omitempty"`
+	Notify           *bool  `mapstructure:"notify" json:"notify,omitempty"`
+	MP3VBR           *bool  `mapstructure:"mp3_vbr" json:"mp3_vbr,omitempty"`
+	Inline           *bool  `mapstructure:"inline" json:"inline,omitempty"`
+	Offline          *bool  `mapstructure:"offline" json:"offline,omitempty"`
+	Link             *bool  `mapstructure:"link" json:"link,omitempty"`
+}
+
+// ExportProfile 返回合并了继承链的 profile
+// 配置文件中的键不区分大小写（viper 读取时转为小写），profile 名称同样不区分大小写
+func (c *Config) ExportProfile(name string) (*ExportProfile, error) {
+	name = strings.ToLower(name)
+	var chain []string
+	p := &ExportProfile{}
+	for n := name; n != ""; {
+		for _, seen := range chain {
+			if seen == n {
+				return nil, fmt.Errorf("export profile %s: circular extends %s", name, strings.Join(append(chain, n), " -> "))
+			}
+		}
+		chain = append(chain, n)
+		parent, ok := c.ExportProfiles[n]
+		if !ok {
+			if n == name {
+				return nil, fmt.Errorf("export profile %s not found, available: %s", name, strings.Join(c.ExportProfileNames(), ", "))
+			}
+			return nil, fmt.Errorf("export profile %s extends unknown profile %s", chain[len(chain)-2], n)
+		}
+		p.inherit(&parent)
+		n = strings.ToLower(parent.Extends)
+	}
+	p.Extends = ""
+	return p, nil
+}
+
+// ExportProfileNames 返回已配置的 profile 名称
+func (c *Config) ExportProfileNames() []string {
+	names := make([]string, 0, len(c.ExportProfiles))
+	for name := range c.ExportProfiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// inherit 用 parent 的值填充 p 中未设置的字段
+func (p *ExportProfile) inherit(parent *ExportProfile) {
+	fill(&p.WorkDir, parent.WorkDir)
+	fill(&p.Platform, parent.Platform)
+	fill(&p.Version, parent.Version)
+	fill(&p.Format, parent.Format)
+	fill(&p.Talker, parent.Talker)
+	fill(&p.Time, parent.Time)
+	fill(&p.Dest, parent.Dest)
+	fill(&p.DataDir, parent.DataDir)
+	fill(&p.ImgKey, parent.ImgKey)
+	fill(&p.Lang, parent.Lang)
+	fill(&p.NameTemplate, parent.NameTemplate)
+	fill(&p.Topic, parent.Topic)
+	fill(&p.Status, parent.Status)
+	fill(&p.VoiceFormat, parent.VoiceFormat)
+	fill(&p.MP3SampleRate, parent.MP3SampleRate)
+	fill(&p.MP3Bitrate, parent.MP3Bitrate)
+	fill(&p.MP3Channels, parent.MP3Channels)
+	fill(&p.NormalizeTime, parent.NormalizeTime)
+	fill(&p.EncryptPerTalker, parent.EncryptPerTalker)
+	fill(&p.PasswordFile, parent.PasswordFile)
+	fill(&p.Notify, parent.Notify)
+	fill(&p.MP3VBR, parent.MP3VBR)
+	fill(&p.Inline, parent.Inline)
+	fill(&p.Offline, parent.Offline)
+	fill(&p.Link, parent.Link)
+}
+
+func fill[T comparable](dst *T, v T) {
+	var zero T
+	if *dst == zero {
+		*dst = v
+	}
+}
@@ -0,0 +1,32 @@
+package conf
+
+import "testing"
+
+func TestExportProfile(t *testing.T) {
+	yes, no := true, false
+	c := &Config{ExportProfiles: map[string]ExportProfile{
+		"base":   {WorkDir: "/work", Version: 4, Format: "txt", Dest: "/backup", Notify: &yes},
+		"weekly": {Extends: "Base", Time: "last-7d", Format: "json", Notify: &no},
+		"family": {Extends: "weekly", Talker: "家庭群"},
+		"loop1":  {Extends: "loop2"},
+		"loop2":  {Extends: "loop1"},
+		"broken": {Extends: "missing"},
+	}}
+
+	p, err := c.ExportProfile("Family")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.WorkDir != "/work" || p.Version != 4 || p.Format != "json" || p.Time != "last-7d" || p.Talker != "家庭群" || p.Dest != "/backup" {
+		t.Errorf("profile = %+v", p)
+	}
+	if p.Notify == nil || *p.Notify {
+		t.Errorf("notify = %v, want false from weekly", p.Notify)
+	}
+
+	for _, name := range []string{"loop1", "broken", "unknown"} {
+		if _, err := c.ExportProfile(name); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
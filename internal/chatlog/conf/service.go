@@ -57,6 +57,21 @@ func (s *Service) Load() error {
 	return nil
 }
 
+// Reload 重新读取配置文件，出错时保留原有配置
+// 与 Load 不同，配置有误时返回错误而不是退出，供常驻进程在运行中调用
+func (s *Service) Reload() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	conf := &Config{}
+	if err := config.Load(conf); err != nil {
+		return err
+	}
+	conf.ConfigDir = config.ConfigPath
+	s.config = conf
+	return nil
+}
+
 // GetConfig 获取配置副本
 func (s *Service) GetConfig() *Config {
 	s.mu.RLock()
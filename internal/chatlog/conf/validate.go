@@ -0,0 +1,385 @@
+package conf
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/aspnmy/chatlog/pkg/config"
+	"github.com/aspnmy/chatlog/pkg/hashtag"
+	"github.com/aspnmy/chatlog/pkg/search"
+)
+
+// 配置来源
+const (
+	SourceDefault = "default"
+	SourceFile    = "file"
+	SourceEnv     = "env"
+	SourceFlag    = "flag"
+)
+
+// 校验问题级别
+const (
+	LevelError   = "error"
+	LevelWarning = "warning"
+)
+
+// Setting 生效的配置项及其来源
+type Setting struct {
+	Key    string `json:"key"`
+	Value  string `json:"value"`
+	Source string `json:"source"`
+}
+
+// Issue 配置校验发现的问题
+type Issue struct {
+	Level   string `json:"level"`
+	Key     string `json:"key"`
+	Message string `json:"message"`
+}
+
+func (i Issue) String() string {
+	return fmt.Sprintf("[%s] %s: %s", i.Level, i.Key, i.Message)
+}
+
+// Report 配置校验结果
+type Report struct {
+	File     string    `json:"file"`
+	Settings []Setting `json:"settings"`
+	Issues   []Issue   `json:"issues"`
+}
+
+// HasError 返回是否存在错误级别的问题
+func (r *Report) HasError() bool {
+	for _, i := range r.Issues {
+		if i.Level == LevelError {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate 严格校验配置文件，不会像 Load 一样在配置有误时退出
+// 检查内容包括：JSON 格式、字段类型、未知字段（给出相近字段提示）以及各字段取值
+func Validate(configPath string) (*Report, error) {
+	dirSource := SourceDefault
+	if configPath == "" {
+		if configPath = os.Getenv(EnvConfigDir); configPath != "" {
+			dirSource = SourceEnv
+		}
+	} else {
+		dirSource = SourceFlag
+	}
+	if err := config.Init(ConfigName, ConfigType, configPath); err != nil {
+		return nil, err
+	}
+
+	file := filepath.Join(config.ConfigPath, ConfigName+"."+ConfigType)
+	report := &Report{File: file}
+	report.add(dirSource, "config_dir", config.ConfigPath)
+
+	raw, err := config.ReadFile(file)
+	if err != nil {
+		if os.IsNotExist(err) {
+			report.add(SourceDefault, "last_account", "")
+			report.add(SourceDefault, "history", "[]")
+			return report, nil
+		}
+		report.issue(LevelError, "config_dir", fmt.Sprintf("invalid config file %s: %v", file, err))
+		return report, nil
+	}
+
+	for _, k := range config.UnknownKeys(raw, Config{}) {
+		report.issue(LevelError, k.Path, k.String())
+	}
+
+	// 按 JSON 重新解码以发现类型错误，字段名与 mapstructure 标签一致
+	conf := &Config{}
+	b, _ := json.Marshal(raw)
+	if err := json.Unmarshal(b, conf); err != nil {
+		if e, ok := err.(*json.UnmarshalTypeError); ok {
+			report.issue(LevelError, e.Field, fmt.Sprintf("expected %s, got %s", e.Type, e.Value))
+		} else {
+			report.issue(LevelError, "config_dir", err.Error())
+		}
+		return report, nil
+	}
+
+	report.add(source(raw, "last_account"), "last_account", conf.LastAccount)
+	rawHistory, _ := raw["history"].([]interface{})
+
+	accounts := make(map[string]bool)
+	found := conf.LastAccount == ""
+	for i, h := range conf.History {
+		prefix := fmt.Sprintf("history[%d]", i)
+		entry := map[string]interface{}{}
+		if i < len(rawHistory) {
+			entry, _ = rawHistory[i].(map[string]interface{})
+		}
+		for _, kv := range [][2]string{
+			{"account", h.Account},
+			{"platform", h.Platform},
+			{"version", fmt.Sprint(h.Version)},
+			{"data_dir", h.DataDir},
+			{"data_key", mask(h.DataKey)},
+			{"img_key", mask(h.ImgKey)},
+			{"work_dir", h.WorkDir},
+			{"http_enabled", fmt.Sprint(h.HTTPEnabled)},
+			{"http_addr", h.HTTPAddr},
+		} {
+			report.add(source(entry, kv[0]), prefix+"."+kv[0], kv[1])
+		}
+
+		if h.Account == "" {
+			report.issue(LevelError, prefix+".account", "account is empty")
+		} else if accounts[h.Account] {
+			report.issue(LevelWarning, prefix+".account", fmt.Sprintf("duplicate account %s, only the last one takes effect", h.Account))
+		}
+		accounts[h.Account] = true
+		if h.Account == conf.LastAccount {
+			found = true
+		}
+
+		if h.Platform != "" && h.Platform != "windows" && h.Platform != "darwin" {
+			report.issue(LevelError, prefix+".platform", fmt.Sprintf("unsupported platform %q, expected windows or darwin", h.Platform))
+		}
+		if h.Version != 0 && h.Version != 3 && h.Version != 4 {
+			report.issue(LevelError, prefix+".version", fmt.Sprintf("unsupported version %d, expected 3 or 4", h.Version))
+		}
+		if err := checkHex(h.DataKey, 32); err != nil {
+			report.issue(LevelError, prefix+".data_key", err.Error())
+		}
+		if err := checkHex(h.ImgKey, 16); err != nil {
+			report.issue(LevelError, prefix+".img_key", err.Error())
+		}
+		if h.HTTPAddr != "" {
+			if _, _, err := net.SplitHostPort(h.HTTPAddr); err != nil {
+				report.issue(LevelError, prefix+".http_addr", err.Error())
+			}
+		}
+		// 归档模式不访问数据目录，卸载微信后目录不存在是正常的
+		if h.DataDir != "" && !conf.ArchiveOnly {
+			if _, err := os.Stat(h.DataDir); err != nil {
+				report.issue(LevelWarning, prefix+".data_dir", "data dir is not accessible")
+			}
+		}
+		if h.WorkDir != "" && h.WorkDir == h.DataDir {
+			report.issue(LevelError, prefix+".work_dir", "work dir must not be the same as data dir")
+		}
+	}
+	conf.ConfigDir = config.ConfigPath
+	report.add(source(raw, "synonym_file"), "synonym_file", conf.SynonymPath())
+	if _, err := os.Stat(conf.SynonymPath()); err != nil {
+		if conf.SynonymFile != "" {
+			report.issue(LevelWarning, "synonym_file", "synonym file is not accessible")
+		}
+	} else if _, err := search.ParseSynonyms(conf.SynonymPath()); err != nil {
+		report.issue(LevelError, "synonym_file", err.Error())
+	}
+
+	if conf.AdminToken != "" {
+		report.add(source(raw, "admin_token"), "admin_token", mask(conf.AdminToken))
+	} else if token := os.Getenv(EnvAdminToken); token != "" {
+		report.add(SourceEnv, "admin_token", mask(token))
+	}
+
+	if c := conf.SMTP; c != nil {
+		entry, _ := raw["smtp"].(map[string]interface{})
+		for _, kv := range [][2]string{
+			{"host", c.Host},
+			{"port", fmt.Sprint(c.Port)},
+			{"username", c.Username},
+			{"password", mask(c.Password)},
+			{"from", c.From},
+			{"to", strings.Join(c.To, ",")},
+			{"only_failures", fmt.Sprint(c.OnlyFailures)},
+		} {
+			report.add(source(entry, kv[0]), "smtp."+kv[0], kv[1])
+		}
+		if c.Host == "" {
+			report.issue(LevelError, "smtp.host", "smtp host is empty")
+		}
+		if c.Port < 0 || c.Port > 65535 {
+			report.issue(LevelError, "smtp.port", fmt.Sprintf("invalid port %d", c.Port))
+		}
+		if len(c.To) == 0 {
+			report.issue(LevelError, "smtp.to", "no recipient")
+		}
+		if c.From == "" {
+			report.issue(LevelError, "smtp.from", "sender is empty")
+		}
+		if c.Username != "" && c.Password == "" && os.Getenv(EnvSMTPPassword) == "" {
+			report.issue(LevelWarning, "smtp.password", fmt.Sprintf("password is empty, set it in the config file or %s", EnvSMTPPassword))
+		}
+	}
+
+	if c := conf.Digest; c != nil {
+		entry, _ := raw["digest"].(map[string]interface{})
+		for _, kv := range [][2]string{
+			{"enabled", fmt.Sprint(c.Enabled)},
+			{"weekday", c.Weekday},
+			{"at", c.At},
+			{"keywords", strings.Join(c.Keywords, ",")},
+			{"top", fmt.Sprint(c.Top)},
+		} {
+			report.add(source(entry, kv[0]), "digest."+kv[0], kv[1])
+		}
+		if _, _, err := c.Schedule(); err != nil {
+			report.issue(LevelError, "digest", err.Error())
+		}
+		if c.Enabled && !conf.SMTP.Enabled() {
+			report.issue(LevelWarning, "digest.enabled", "smtp is not configured, the digest will not be sent")
+		}
+	}
+
+	flags := conf.FlagValues()
+	for _, name := range slices.Sorted(maps.Keys(flags)) {
+		report.add(SourceFile, "flags."+name, MaskFlag(name, flags[name]))
+	}
+
+	for i := range conf.Webhooks {
+		c := &conf.Webhooks[i]
+		key := fmt.Sprintf("webhooks[%d]", i)
+		report.add(SourceFile, key, fmt.Sprintf("%s talkers=%s keywords=%s", c, strings.Join(c.Talkers, ","), strings.Join(c.Keywords, ",")))
+		if err := validateWebhook(c); err != nil {
+			report.issue(LevelError, key, err.Error())
+		}
+	}
+
+	if len(conf.Topics) > 0 {
+		entry, _ := raw["topics"].(map[string]interface{})
+		names := make([]string, 0, len(conf.Topics))
+		for name := range conf.Topics {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			tags := conf.Topics[name]
+			report.add(source(entry, name), "topics."+name, strings.Join(tags, ","))
+			for _, tag := range append([]string{name}, tags...) {
+				if t := hashtag.Normalize(tag); t == "" || !slices.Equal(hashtag.Extract("#"+t), []string{t}) {
+					report.issue(LevelWarning, "topics."+name, fmt.Sprintf("%q can never be matched, hashtags contain only letters, digits, _ and -", tag))
+				}
+			}
+		}
+	}
+
+	if c := conf.Dates; c != nil {
+		entry, _ := raw["dates"].(map[string]interface{})
+		for _, kv := range [][2]string{
+			{"format", c.Format},
+			{"locale", c.Locale},
+			{"week_start", c.WeekStart},
+			{"lunar", fmt.Sprint(c.Lunar)},
+		} {
+			report.add(source(entry, kv[0]), "dates."+kv[0], kv[1])
+		}
+		if _, err := c.Style(); err != nil {
+			report.issue(LevelError, "dates", err.Error())
+		}
+	}
+
+	if c := conf.Notion; c != nil {
+		entry, _ := raw["notion"].(map[string]interface{})
+		token := c.Token
+		if token == "" {
+			token = os.Getenv(EnvNotionToken)
+		}
+		report.add(source(entry, "token"), "notion.token", mask(token))
+		report.add(source(entry, "parent"), "notion.parent", c.Parent)
+		if c.Parent == "" {
+			report.issue(LevelError, "notion.parent", "parent page is empty")
+		}
+		if token == "" {
+			report.issue(LevelError, "notion.token", fmt.Sprintf("token is empty, set it in the config file or %s", EnvNotionToken))
+		}
+	}
+
+	if c := conf.Feishu; c != nil {
+		entry, _ := raw["feishu"].(map[string]interface{})
+		secret := c.AppSecret
+		if secret == "" {
+			secret = os.Getenv(EnvFeishuAppSecret)
+		}
+		report.add(source(entry, "app_id"), "feishu.app_id", c.AppID)
+		report.add(source(entry, "app_secret"), "feishu.app_secret", mask(secret))
+		report.add(source(entry, "folder"), "feishu.folder", c.Folder)
+		if c.AppID == "" {
+			report.issue(LevelError, "feishu.app_id", "app id is empty")
+		}
+		if secret == "" {
+			report.issue(LevelError, "feishu.app_secret", fmt.Sprintf("app secret is empty, set it in the config file or %s", EnvFeishuAppSecret))
+		}
+	}
+
+	for _, name := range conf.ExportProfileNames() {
+		if _, err := conf.ExportProfile(name); err != nil {
+			report.issue(LevelError, "export_profiles."+name, err.Error())
+		}
+	}
+
+	if !found {
+		report.issue(LevelWarning, "last_account", fmt.Sprintf("account %s not found in history", conf.LastAccount))
+	}
+
+	sort.SliceStable(report.Issues, func(i, j int) bool {
+		return report.Issues[i].Level == LevelError && report.Issues[j].Level != LevelError
+	})
+	return report, nil
+}
+
+// source 判断配置项来自配置文件还是默认值
+func source(raw map[string]interface{}, key string) string {
+	if _, ok := raw[key]; ok {
+		return SourceFile
+	}
+	return SourceDefault
+}
+
+// Add 添加一项生效配置，供命令行参数等外部来源使用
+func (r *Report) Add(source, key, value string) {
+	r.add(source, key, value)
+}
+
+func (r *Report) add(source, key, value string) {
+	r.Settings = append(r.Settings, Setting{Key: key, Value: value, Source: source})
+}
+
+// Issue 添加一个问题，供命令行参数等外部来源的校验使用
+func (r *Report) Issue(level, key, message string) {
+	r.issue(level, key, message)
+}
+
+func (r *Report) issue(level, key, message string) {
+	r.Issues = append(r.Issues, Issue{Level: level, Key: key, Message: message})
+}
+
+// checkHex 校验十六进制密钥的长度（字节数），空值视为未设置
+func checkHex(s string, size int) error {
+	if s == "" {
+		return nil
+	}
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return fmt.Errorf("key is not a valid hex string")
+	}
+	if len(b) != size {
+		return fmt.Errorf("key should be %d bytes, got %d", size, len(b))
+	}
+	return nil
+}
+
+// mask 隐藏密钥等敏感信息，仅保留前 4 个字符
+func mask(s string) string {
+	if len(s) <= 4 {
+		return strings.Repeat("*", len(s))
+	}
+	return s[:4] + strings.Repeat("*", 8)
+}
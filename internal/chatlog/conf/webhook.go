@@ -0,0 +1,57 @@
+package conf
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aspnmy/chatlog/pkg/webhook"
+)
+
+// DefaultWebhookBatch 每次请求最多包含的消息数
+const DefaultWebhookBatch = 20
+
+// WebhookConfig chatlog daemon 每次同步后推送新消息的 Webhook，只推送符合 talkers 与 keywords 的消息
+type WebhookConfig struct {
+	// Name 用于日志展示的名称，默认为地址中的主机名
+	Name string `mapstructure:"name" json:"name,omitempty"`
+	URL  string `mapstructure:"url" json:"url"`
+	// Headers 附加的请求头，如鉴权令牌
+	Headers map[string]string `mapstructure:"headers" json:"headers,omitempty"`
+	// Body text/template 格式的请求体模板，为空时以 JSON 发送全部字段，见 chatlog.WebhookPayload
+	Body string `mapstructure:"body" json:"body,omitempty"`
+
+	// Talkers 只推送这些聊天对象的消息，支持微信 ID、群聊 ID 与名称，为空时不限
+	Talkers []string `mapstructure:"talkers" json:"talkers,omitempty"`
+	// Keywords 只推送包含任一关键词的消息，不区分大小写，为空时不限
+	Keywords []string `mapstructure:"keywords" json:"keywords,omitempty"`
+	// Batch 每次请求最多包含的消息数，默认 DefaultWebhookBatch
+	Batch int `mapstructure:"batch" json:"batch,omitempty"`
+}
+
+// Hook 按配置创建 Webhook，地址或请求体模板无效时返回错误
+func (c *WebhookConfig) Hook() (*webhook.Hook, error) {
+	return webhook.New(c.URL, c.Headers, c.Body)
+}
+
+// String 返回用于日志展示的名称，不包含地址中可能带有的令牌
+func (c *WebhookConfig) String() string {
+	if c.Name != "" {
+		return c.Name
+	}
+	if h, err := c.Hook(); err == nil {
+		return strings.TrimPrefix(strings.TrimPrefix(h.String(), "https://"), "http://")
+	}
+	return "webhook"
+}
+
+// validateWebhook 返回配置中的问题，用于 chatlog config validate
+func validateWebhook(c *WebhookConfig) error {
+	if c.URL == "" {
+		return fmt.Errorf("url is empty")
+	}
+	if c.Batch < 0 {
+		return fmt.Errorf("invalid batch %d", c.Batch)
+	}
+	_, err := c.Hook()
+	return err
+}
@@ -1,11 +1,19 @@
 package ctx
 
 import (
+	"path/filepath"
+	"sort"
 	"sync"
 	"time"
 
+	"github.com/rs/zerolog/log"
+
 	"github.com/aspnmy/chatlog/internal/chatlog/conf"
+	"github.com/aspnmy/chatlog/internal/chatlog/snapshot"
 	"github.com/aspnmy/chatlog/internal/wechat"
+	"github.com/aspnmy/chatlog/internal/wechat/decrypt/common"
+	"github.com/aspnmy/chatlog/pkg/datefmt"
+	"github.com/aspnmy/chatlog/pkg/hashtag"
 	"github.com/aspnmy/chatlog/pkg/util"
 )
 
@@ -26,18 +34,52 @@ type Context struct {
 	DataKey     string
 	DataUsage   string
 	ImgKey      string
+	KeyTime     int64              // 获取密钥的时间
+	Cipher      *common.CipherInfo // 验证密钥时使用的加密参数
 
 	// 工作目录相关状态
 	WorkDir   string
 	WorkUsage string
 
+	// 同一账号旧版本的数据，见 chatlog migrate
+	Legacy *conf.LegacyConfig
+
+	// 搜索使用的同义词文件
+	SynonymFile string
+
+	// 通过 HTTP 接口生成的导出文件所在目录
+	ExportDir string
+
+	// 导出、统计与 Web 页面中日期的显示方式，见 conf.DatesConfig
+	Dates datefmt.Style
+
+	// 话题标签的约定，见 conf.Config.Topics
+	Topics *hashtag.Matcher
+
 	// HTTP服务相关状态
 	HTTPEnabled bool
 	HTTPAddr    string
 
+	// 管理接口的令牌，见 conf.Config.AdminToken
+	AdminToken string
+
+	// 锁定的会话与口令哈希，见 conf.LockConfig
+	LockedTalkers map[string]bool
+	LockHash      string
+
+	// ArchiveOnly 归档模式，只使用工作目录中的数据，不查找微信进程，
+	// DataDir 指向工作目录下 MediaDir 中的媒体文件副本，见 chatlog archive
+	ArchiveOnly bool
+
+	// 只读快照，见 chatlog server --serve-snapshot
+	Snapshot     string
+	SnapshotInfo *snapshot.Manifest
+
 	// 自动解密
 	AutoDecrypt bool
 	LastSession time.Time
+	LastSync    time.Time
+	synced      chan struct{} // 下次同步完成时关闭，见 SyncSignal
 
 	// 当前选中的微信实例
 	Current *wechat.Account
@@ -49,6 +91,17 @@ type Context struct {
 	WeChatInstances []*wechat.Account
 }
 
+// MediaDir 工作目录下保存媒体文件副本的目录，目录结构与微信数据目录相同
+const MediaDir = "media"
+
+// ArchiveMediaDir 返回工作目录中的媒体文件副本所在目录
+func ArchiveMediaDir(workDir string) string {
+	if workDir == "" {
+		return ""
+	}
+	return filepath.Join(workDir, MediaDir)
+}
+
 func New(conf *conf.Service) *Context {
 	ctx := &Context{
 		conf: conf,
@@ -62,10 +115,116 @@ func New(conf *conf.Service) *Context {
 func (c *Context) loadConfig() {
 	conf := c.conf.GetConfig()
 	c.History = conf.ParseHistory()
+	c.SynonymFile = conf.SynonymPath()
+	c.ExportDir = conf.ExportPath()
+	c.AdminToken = conf.GetAdminToken()
+	c.setDates(conf.Dates)
+	c.Topics = hashtag.NewMatcher(conf.Topics)
+	c.setLock(conf.Lock)
 	c.SwitchHistory(conf.LastAccount)
 	c.Refresh()
 }
 
+// Accounts 返回配置文件中保存的全部账号与当前账号，账号按名称排序，在锁内复制
+func (c *Context) Accounts() ([]conf.ProcessConfig, string) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	accounts := make([]conf.ProcessConfig, 0, len(c.History))
+	for _, h := range c.History {
+		accounts = append(accounts, h)
+	}
+	sort.Slice(accounts, func(i, j int) bool { return accounts[i].Account < accounts[j].Account })
+	return accounts, c.Account
+}
+
+// AccountConfig 返回配置文件中保存的账号 account 的配置
+func (c *Context) AccountConfig(account string) (conf.ProcessConfig, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	h, ok := c.History[account]
+	return h, ok
+}
+
+// SetAccountConfig 更新内存中账号的配置，配置文件由调用方写入
+func (c *Context) SetAccountConfig(p conf.ProcessConfig) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.History[p.Account] = p
+}
+
+// RemoveAccountConfig 从内存中删除账号的配置，配置文件由调用方写入
+func (c *Context) RemoveAccountConfig(account string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.History, account)
+}
+
+// Reload 重新读取配置文件中的账号历史、同义词文件、话题标签与管理令牌
+// 不改变当前账号，也不重启正在运行的服务
+func (c *Context) Reload() error {
+	if err := c.conf.Reload(); err != nil {
+		return err
+	}
+	conf := c.conf.GetConfig()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.History = conf.ParseHistory()
+	c.SynonymFile = conf.SynonymPath()
+	c.AdminToken = conf.GetAdminToken()
+	c.setDates(conf.Dates)
+	c.Topics = hashtag.NewMatcher(conf.Topics)
+	c.setLock(conf.Lock)
+	return nil
+}
+
+// setDates 设置日期的显示方式，配置有误时使用默认方式，错误由 chatlog config validate 报告
+func (c *Context) setDates(dates *conf.DatesConfig) {
+	style, err := dates.Style()
+	if err != nil {
+		log.Warn().Err(err).Msg("invalid dates config, using the default date format")
+		style = datefmt.Default
+	}
+	c.Dates = style
+}
+
+func (c *Context) setLock(lock *conf.LockConfig) {
+	c.LockedTalkers = nil
+	c.LockHash = ""
+	if !lock.Enabled() {
+		return
+	}
+	c.LockedTalkers = make(map[string]bool, len(lock.Talkers))
+	for _, talker := range lock.Talkers {
+		c.LockedTalkers[talker] = true
+	}
+	c.LockHash = lock.Passphrase
+}
+
+// GetAdminToken 返回管理接口的令牌，配置文件重新读取后可能变化
+func (c *Context) GetAdminToken() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.AdminToken
+}
+
+// Locked 返回聊天对象是否被锁定，talker 为聊天对象 ID
+func (c *Context) Locked(talker string) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.LockedTalkers[talker]
+}
+
+// LockedTalkerList 返回锁定的聊天对象 ID
+func (c *Context) LockedTalkerList() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	list := make([]string, 0, len(c.LockedTalkers))
+	for talker := range c.LockedTalkers {
+		list = append(list, talker)
+	}
+	return list
+}
+
 func (c *Context) SwitchHistory(account string) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
@@ -81,10 +240,13 @@ func (c *Context) SwitchHistory(account string) {
 		c.FullVersion = history.FullVersion
 		c.DataKey = history.DataKey
 		c.ImgKey = history.ImgKey
+		c.KeyTime = history.KeyTime
+		c.Cipher = history.Cipher
 		c.DataDir = history.DataDir
 		c.WorkDir = history.WorkDir
 		c.HTTPEnabled = history.HTTPEnabled
 		c.HTTPAddr = history.HTTPAddr
+		c.Legacy = history.Legacy
 	} else {
 		c.Account = ""
 		c.Platform = ""
@@ -92,10 +254,34 @@ func (c *Context) SwitchHistory(account string) {
 		c.FullVersion = ""
 		c.DataKey = ""
 		c.ImgKey = ""
+		c.KeyTime = 0
+		c.Cipher = nil
 		c.DataDir = ""
 		c.WorkDir = ""
 		c.HTTPEnabled = false
 		c.HTTPAddr = ""
+		c.Legacy = nil
+	}
+	c.useArchive()
+}
+
+// SetArchiveOnly 切换到归档模式，数据目录改为工作目录中的媒体文件副本
+func (c *Context) SetArchiveOnly() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.ArchiveOnly = true
+	c.Current = nil
+	c.PID = 0
+	c.ExePath = ""
+	c.Status = ""
+	c.WeChatInstances = nil
+	c.useArchive()
+}
+
+// useArchive 归档模式下将数据目录指向工作目录中的媒体文件副本
+func (c *Context) useArchive() {
+	if c.ArchiveOnly {
+		c.DataDir = ArchiveMediaDir(c.WorkDir)
 	}
 }
 
@@ -118,6 +304,8 @@ func (c *Context) Refresh() {
 		c.Status = c.Current.Status
 		if c.Current.Key != "" && c.Current.Key != c.DataKey {
 			c.DataKey = c.Current.Key
+			c.KeyTime = time.Now().Unix()
+			c.Cipher = c.Current.Cipher
 		}
 		if c.Current.ImgKey != "" && c.Current.ImgKey != c.ImgKey {
 			c.ImgKey = c.Current.ImgKey
@@ -126,6 +314,7 @@ func (c *Context) Refresh() {
 			c.DataDir = c.Current.DataDir
 		}
 	}
+	c.useArchive()
 	if c.DataUsage == "" && c.DataDir != "" {
 		go func() {
 			c.DataUsage = util.GetDirSize(c.DataDir)
@@ -168,6 +357,67 @@ func (c *Context) SetDataDir(dir string) {
 	c.Refresh()
 }
 
+// SwitchSnapshot 切换到 root 下的快照 info，工作目录改为该快照的目录，见 chatlog server --serve-snapshot
+func (c *Context) SwitchSnapshot(root string, info *snapshot.Manifest) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.Snapshot = root
+	c.SnapshotInfo = info
+	c.WorkDir = info.Dir(root)
+}
+
+// CurrentSnapshot 返回快照所在目录与正在使用的快照，未使用快照时 info 为 nil
+func (c *Context) CurrentSnapshot() (root string, info *snapshot.Manifest) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.Snapshot, c.SnapshotInfo
+}
+
+// SyncStatus 同步与密钥状态，见 Context.SyncStatus
+type SyncStatus struct {
+	Account     string
+	AutoDecrypt bool
+	HTTPEnabled bool
+	HTTPAddr    string
+	KeyTime     int64
+	LastSync    time.Time
+}
+
+// SyncStatus 返回同步与密钥状态的快照，供管理接口与托盘在其他 goroutine 中读取
+func (c *Context) SyncStatus() SyncStatus {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return SyncStatus{
+		Account:     c.Account,
+		AutoDecrypt: c.AutoDecrypt,
+		HTTPEnabled: c.HTTPEnabled,
+		HTTPAddr:    c.HTTPAddr,
+		KeyTime:     c.KeyTime,
+		LastSync:    c.LastSync,
+	}
+}
+
+// Synced 记录一次同步完成，唤醒通过 SyncSignal 等待新数据的请求
+func (c *Context) Synced() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.LastSync = time.Now()
+	if c.synced != nil {
+		close(c.synced)
+		c.synced = nil
+	}
+}
+
+// SyncSignal 返回下次同步完成时关闭的 channel，需在读取数据之前获取，避免错过期间完成的同步
+func (c *Context) SyncSignal() <-chan struct{} {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.synced == nil {
+		c.synced = make(chan struct{})
+	}
+	return c.synced
+}
+
 func (c *Context) SetAutoDecrypt(enabled bool) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
@@ -186,9 +436,16 @@ func (c *Context) UpdateConfig() {
 		DataDir:     c.DataDir,
 		DataKey:     c.DataKey,
 		ImgKey:      c.ImgKey,
+		KeyTime:     c.KeyTime,
+		Cipher:      c.Cipher,
 		WorkDir:     c.WorkDir,
 		HTTPEnabled: c.HTTPEnabled,
 		HTTPAddr:    c.HTTPAddr,
+		Legacy:      c.Legacy,
+	}
+	if c.ArchiveOnly {
+		// 保留原来的数据目录，重新安装微信后仍可使用
+		pconf.DataDir = c.History[c.Account].DataDir
 	}
 	conf := c.conf.GetConfig()
 	conf.UpdateHistory(c.Account, pconf)
@@ -0,0 +1,78 @@
+package chatlog
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// AutoSync 守护进程中持续同步的阶段，见 CommandDaemon
+const AutoSync = "sync"
+
+// DaemonOptions 守护进程参数，准备阶段与 chatlog auto 相同
+type DaemonOptions struct {
+	AutoOptions
+
+	// Interval 轮询数据目录的间隔，为 0 时监听文件变化，监听不可用时按 DefaultPollInterval 轮询
+	Interval time.Duration
+	// NoServer 只保持工作目录与搜索索引为最新，不启动 HTTP 服务
+	NoServer bool
+}
+
+// DefaultPollInterval 文件监控不可用时轮询数据目录的间隔
+var DefaultPollInterval = time.Minute
+
+// CommandDaemon 完成与 chatlog auto 相同的准备阶段后持续同步：数据库文件变化时增量解密，
+// 解密完成后搜索索引随之更新，新消息推送到配置的 Webhook，同时提供 HTTP 服务（NoServer 时不提供），
+// 直到服务退出或收到中断信号
+func (m *Manager) CommandDaemon(opts DaemonOptions, report func(AutoStage)) error {
+	if err := m.autoPrepare(opts.AutoOptions, report); err != nil {
+		return err
+	}
+
+	start := time.Now()
+	detail, err := m.startSync(opts.Interval)
+	if err != nil {
+		return &AutoError{Stage: AutoSync, Err: err, Hint: autoHints[AutoDecrypt]}
+	}
+	defer m.wechat.StopPolling()
+	defer m.wechat.StopAutoDecrypt()
+	n, err := m.startWebhooks()
+	if err != nil {
+		return &AutoError{Stage: AutoSync, Err: err, Hint: autoHints[AutoSync]}
+	}
+	defer m.stopWebhooks()
+	if n > 0 {
+		detail += fmt.Sprintf(", posting new messages to %d webhook(s)", n)
+	}
+	report(AutoStage{Name: AutoSync, Detail: detail, Elapsed: time.Since(start)})
+
+	if !opts.NoServer {
+		return m.autoServe(opts.Addr, "", report)
+	}
+	defer m.db.Stop()
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	<-sig
+	return nil
+}
+
+// startSync 监听数据目录中数据库文件的变化，interval 大于 0 或监听失败时改为轮询
+func (m *Manager) startSync(interval time.Duration) (string, error) {
+	fallback := ""
+	if interval <= 0 {
+		err := m.wechat.StartAutoDecrypt()
+		if err == nil {
+			return "watching " + m.ctx.DataDir, nil
+		}
+		m.wechat.StopAutoDecrypt()
+		interval = DefaultPollInterval
+		fallback = fmt.Sprintf(" (file watching unavailable: %v)", err)
+	}
+	if err := m.wechat.StartPolling(interval); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("polling %s every %s%s", m.ctx.DataDir, interval, fallback), nil
+}
@@ -0,0 +1,77 @@
+package database
+
+import (
+	"sort"
+	"time"
+
+	"github.com/aspnmy/chatlog/pkg/datefmt"
+)
+
+// CalendarCount 某一天、某一周或某个月的消息数量，周以第一天的日期表示
+// Label 为按配置的日期格式显示的日期，开启农历注记时包含农历月日与传统节日
+type CalendarCount struct {
+	Date  string `json:"date"`
+	Label string `json:"label,omitempty"`
+	Count int    `json:"count"`
+}
+
+// CalendarResp 会话按天、按周、按月的消息数量，均按日期升序排列，不包含没有消息的日期
+type CalendarResp struct {
+	Talker    string          `json:"talker"`
+	Total     int             `json:"total"`
+	WeekStart string          `json:"weekStart"` // 每周的第一天，如 Monday，见 conf.DatesConfig
+	Weekdays  []string        `json:"weekdays"`  // 从 WeekStart 开始的星期名称，用于日历表头
+	Days      []CalendarCount `json:"days"`
+	Weeks     []CalendarCount `json:"weeks"`
+	Months    []CalendarCount `json:"months"`
+}
+
+// GetCalendar 统计会话在时间范围内每天、每周与每月的消息数量，周的划分与日期的显示方式见 ctx.Context.Dates
+func (s *Service) GetCalendar(talker string, start, end time.Time) (*CalendarResp, error) {
+	days, err := s.db.Load().GetMessageCounts(talker, start, end)
+	if err != nil {
+		return nil, err
+	}
+	return calendar(talker, days, s.ctx.Dates), nil
+}
+
+// calendar 将每天的消息数量汇总为按天、按周、按月的统计，days 的键为 2006-01-02 格式的日期
+func calendar(talker string, days map[string]int, dates datefmt.Style) *CalendarResp {
+	resp := &CalendarResp{
+		Talker:    talker,
+		WeekStart: dates.WeekStart.String(),
+		Weekdays:  make([]string, 0, 7),
+		Days:      make([]CalendarCount, 0, len(days)),
+	}
+	for _, d := range dates.Weekdays() {
+		resp.Weekdays = append(resp.Weekdays, dates.WeekdayName(d))
+	}
+	weeks := make(map[string]int)
+	months := make(map[string]int)
+	for day, count := range days {
+		resp.Total += count
+		c := CalendarCount{Date: day, Count: count}
+		if t, err := time.ParseInLocation(time.DateOnly, day, time.Local); err == nil {
+			c.Label = dates.Date(t)
+			weeks[dates.WeekOf(t).Format(time.DateOnly)] += count
+		}
+		resp.Days = append(resp.Days, c)
+		if len(day) >= 7 {
+			months[day[:7]] += count
+		}
+	}
+	for week, count := range weeks {
+		c := CalendarCount{Date: week, Count: count}
+		if t, err := time.ParseInLocation(time.DateOnly, week, time.Local); err == nil {
+			c.Label = dates.Format(t)
+		}
+		resp.Weeks = append(resp.Weeks, c)
+	}
+	for month, count := range months {
+		resp.Months = append(resp.Months, CalendarCount{Date: month, Count: count})
+	}
+	sort.Slice(resp.Days, func(i, j int) bool { return resp.Days[i].Date < resp.Days[j].Date })
+	sort.Slice(resp.Weeks, func(i, j int) bool { return resp.Weeks[i].Date < resp.Weeks[j].Date })
+	sort.Slice(resp.Months, func(i, j int) bool { return resp.Months[i].Date < resp.Months[j].Date })
+	return resp
+}
@@ -0,0 +1,32 @@
+package database
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aspnmy/chatlog/pkg/datefmt"
+)
+
+func TestCalendarWeeks(t *testing.T) {
+	// 2024-02-10 为周六（春节），2024-02-11 为周日，2024-02-12 为周一
+	days := map[string]int{"2024-02-10": 1, "2024-02-11": 2, "2024-02-12": 4}
+
+	resp := calendar("a", days, datefmt.Default)
+	if resp.Total != 7 || len(resp.Days) != 3 || len(resp.Months) != 1 || resp.WeekStart != "Monday" {
+		t.Fatalf("calendar = %+v", resp)
+	}
+	want := []CalendarCount{{Date: "2024-02-05", Label: "2024-02-05", Count: 3}, {Date: "2024-02-12", Label: "2024-02-12", Count: 4}}
+	if len(resp.Weeks) != 2 || resp.Weeks[0] != want[0] || resp.Weeks[1] != want[1] {
+		t.Errorf("weeks starting monday = %+v, want %+v", resp.Weeks, want)
+	}
+
+	sunday := datefmt.Style{Layout: "2006年1月2日", WeekStart: time.Sunday, Lunar: true}
+	resp = calendar("a", days, sunday)
+	want = []CalendarCount{{Date: "2024-02-04", Label: "2024年2月4日", Count: 1}, {Date: "2024-02-11", Label: "2024年2月11日", Count: 6}}
+	if len(resp.Weeks) != 2 || resp.Weeks[0] != want[0] || resp.Weeks[1] != want[1] {
+		t.Errorf("weeks starting sunday = %+v, want %+v", resp.Weeks, want)
+	}
+	if got := resp.Days[0].Label; got != "2024年2月10日（正月初一 春节）" {
+		t.Errorf("day label = %q", got)
+	}
+}
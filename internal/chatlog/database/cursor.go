@@ -0,0 +1,103 @@
+package database
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/aspnmy/chatlog/internal/errors"
+	"github.com/aspnmy/chatlog/internal/model"
+)
+
+// MessagePage 按游标读取的一页消息
+type MessagePage struct {
+	Items []*model.Message `json:"items"`
+	// Cursor 最后一条消息的位置，没有消息时为请求的游标，下一次从这里继续读取
+	Cursor string `json:"cursor"`
+	// More 还有排在之后的消息，可以立即使用 Cursor 继续读取
+	More bool `json:"more"`
+}
+
+// newPage 由按全序排列、均在 after 之后的消息生成一页，超过 limit 时截断
+func newPage(messages []*model.Message, after model.Cursor, limit int) *MessagePage {
+	page := &MessagePage{Items: messages, Cursor: after.String()}
+	if page.Items == nil {
+		page.Items = []*model.Message{}
+	}
+	if limit > 0 && len(page.Items) > limit {
+		page.Items = page.Items[:limit]
+		page.More = true
+	}
+	if n := len(page.Items); n > 0 {
+		page.Cursor = model.CursorOf(page.Items[n-1]).String()
+	}
+	return page
+}
+
+// GetMessagesAfter 读取排在 after 之后的消息，按 (Seq, Talker) 的全序排列，最多 limit 条，见 model.Cursor
+// 先从游标所在的时间开始读取 limit+1 条，游标之前同一秒的消息较多时加倍重试
+func (s *Service) GetMessagesAfter(start, end time.Time, talker, sender, keyword string, after model.Cursor, limit int) (*MessagePage, error) {
+	if !after.IsZero() {
+		if t := time.Unix(after.Seq/1000, 0); t.After(start) {
+			start = t
+		}
+	}
+	if start.After(end) {
+		return newPage(nil, after, limit), nil
+	}
+
+	fetch := 0
+	if limit > 0 {
+		fetch = limit + 1
+	}
+	for {
+		messages, err := s.db.Load().GetMessages(start, end, talker, sender, keyword, fetch, 0)
+		if err != nil {
+			return nil, err
+		}
+		rest := model.MessagesAfter(messages, after)
+		if fetch == 0 || len(messages) < fetch || len(rest) > limit {
+			return newPage(rest, after, limit), nil
+		}
+		fetch *= 2
+	}
+}
+
+// Changes 返回所有会话中排在 after 之后的新消息，供长轮询等实时接口使用
+// 先通过会话列表找到最后一条消息不早于游标的会话，只读取这些会话，避免扫描全部会话
+// 时间晚于当前时间的异常消息不返回，否则游标会越过之后的正常消息；
+// 时间早于 after 的消息即使之后才同步进来也不返回，见 model.Cursor
+func (s *Service) Changes(after model.Cursor, limit int) (*MessagePage, error) {
+	since := time.Unix(after.Seq/1000, 0)
+	now := time.Now()
+
+	sessions, err := s.db.Load().GetSessions("", 0, 0)
+	if err != nil {
+		return nil, err
+	}
+	var talkers []string
+	for _, session := range sessions.Items {
+		if !session.NTime.Before(since) {
+			talkers = append(talkers, session.UserName)
+		}
+	}
+	if len(talkers) == 0 {
+		return newPage(nil, after, limit), nil
+	}
+
+	messages, err := s.db.Load().GetMessages(since, now, strings.Join(talkers, ","), "", "", 0, 0)
+	if err != nil {
+		// 游标之后还没有新的数据库文件
+		if errors.GetCode(err) == http.StatusNotFound {
+			return newPage(nil, after, limit), nil
+		}
+		return nil, err
+	}
+	var items []*model.Message
+	for _, m := range model.MessagesAfter(messages, after) {
+		if !m.Time.After(now) {
+			items = append(items, m)
+		}
+	}
+	return newPage(items, after, limit), nil
+}
@@ -0,0 +1,256 @@
+package database
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/aspnmy/chatlog/internal/model"
+)
+
+// DigestOptions 生成周报的参数
+type DigestOptions struct {
+	Keywords []string // 统计命中次数的关键词，不区分大小写
+	Top      int      // 每一项最多列出的会话或消息数，默认 DefaultDigestTop
+
+	// Hidden 不统计其中的聊天对象，用于去掉锁定的会话
+	Hidden func(talker string) bool
+}
+
+// Digest 一段时间内聊天记录的摘要：最活跃的会话、分享的文件与链接、关键词命中
+type Digest struct {
+	Start    time.Time       `json:"start"`
+	End      time.Time       `json:"end"`
+	Messages int             `json:"messages"` // 消息总数，不含系统消息
+	Talkers  int             `json:"talkers"`  // 有消息的会话数
+	Active   []DigestTalker  `json:"active"`   // 消息数最多的会话
+	Media    DigestMedia     `json:"media"`
+	Keywords []DigestKeyword `json:"keywords"`
+}
+
+// DigestTalker 会话在时间范围内的消息数与发言人数
+type DigestTalker struct {
+	Talker   string `json:"talker"`
+	Name     string `json:"name"`
+	Messages int    `json:"messages"`
+	Senders  int    `json:"senders"`
+}
+
+// DigestMedia 多媒体消息的数量，Notable 为最近分享的文件与链接
+type DigestMedia struct {
+	Images  int          `json:"images"`
+	Videos  int          `json:"videos"`
+	Voices  int          `json:"voices"`
+	Files   int          `json:"files"`
+	Links   int          `json:"links"`
+	Notable []DigestItem `json:"notable"`
+}
+
+// DigestKeyword 关键词的命中次数与最近命中的消息
+type DigestKeyword struct {
+	Keyword string       `json:"keyword"`
+	Count   int          `json:"count"`
+	Hits    []DigestItem `json:"hits"`
+}
+
+// DigestItem 周报中列出的一条消息
+type DigestItem struct {
+	Talker     string    `json:"talker"`
+	TalkerName string    `json:"talkerName"`
+	Sender     string    `json:"sender"`
+	Seq        int64     `json:"seq"`
+	Time       time.Time `json:"time"`
+	Text       string    `json:"text"`
+}
+
+const (
+	// DefaultDigestTop 未指定 Top 时每一项列出的条数
+	DefaultDigestTop = 5
+	// DigestTextLen 周报中每条消息摘要的最大字符数
+	DigestTextLen = 80
+)
+
+// GetDigest 统计时间范围内所有会话的消息，生成周报
+// 只读取最后一条消息不早于 start 的会话
+func (s *Service) GetDigest(start, end time.Time, opts DigestOptions) (*Digest, error) {
+	sessions, err := s.db.Load().GetSessions("", 0, 0)
+	if err != nil {
+		return nil, err
+	}
+	d := newDigester(start, end, opts)
+	for _, session := range sessions.Items {
+		if opts.Hidden != nil && opts.Hidden(session.UserName) {
+			continue
+		}
+		if !session.NTime.IsZero() && session.NTime.Before(start) {
+			continue
+		}
+		messages, err := s.db.Load().GetMessages(start, end, session.UserName, "", "", 0, 0)
+		if err != nil {
+			return nil, err
+		}
+		d.add(session.UserName, cmp.Or(session.NickName, session.UserName), messages)
+	}
+	return d.result(), nil
+}
+
+// digester 按会话累计周报的统计
+type digester struct {
+	digest   *Digest
+	opts     DigestOptions
+	keywords []string
+	notable  []DigestItem
+	hits     [][]DigestItem
+}
+
+func newDigester(start, end time.Time, opts DigestOptions) *digester {
+	d := &digester{
+		digest: &Digest{Start: start, End: end},
+		opts:   opts,
+	}
+	for _, k := range opts.Keywords {
+		if k = strings.TrimSpace(k); k != "" {
+			d.keywords = append(d.keywords, strings.ToLower(k))
+			d.digest.Keywords = append(d.digest.Keywords, DigestKeyword{Keyword: k})
+		}
+	}
+	d.hits = make([][]DigestItem, len(d.keywords))
+	return d
+}
+
+// add 统计一个会话的消息
+func (d *digester) add(talker, name string, messages []*model.Message) {
+	t := DigestTalker{Talker: talker, Name: name}
+	senders := make(map[string]struct{})
+	for _, m := range messages {
+		if m.Type == 10000 {
+			continue
+		}
+		t.Messages++
+		senders[m.Sender] = struct{}{}
+		if m.TalkerName != "" {
+			t.Name = m.TalkerName
+		}
+
+		media := &d.digest.Media
+		switch {
+		case m.Type == 3:
+			media.Images++
+		case m.Type == 34:
+			media.Voices++
+		case m.Type == 43:
+			media.Videos++
+		case m.Type == 49 && m.SubType == 6:
+			media.Files++
+			d.notable = append(d.notable, digestItem(m, t.Name))
+		case m.Type == 49 && m.SubType == 5:
+			media.Links++
+			d.notable = append(d.notable, digestItem(m, t.Name))
+		}
+
+		if m.Type != 1 || len(d.keywords) == 0 {
+			continue
+		}
+		content := strings.ToLower(m.Content)
+		for i, k := range d.keywords {
+			if n := strings.Count(content, k); n > 0 {
+				d.digest.Keywords[i].Count += n
+				d.hits[i] = append(d.hits[i], digestItem(m, t.Name))
+			}
+		}
+	}
+	if t.Messages == 0 {
+		return
+	}
+	t.Senders = len(senders)
+	d.digest.Messages += t.Messages
+	d.digest.Talkers++
+	d.digest.Active = append(d.digest.Active, t)
+}
+
+// result 排序并截取每一项的前 Top 条
+func (d *digester) result() *Digest {
+	top := d.opts.Top
+	if top <= 0 {
+		top = DefaultDigestTop
+	}
+	slices.SortFunc(d.digest.Active, func(a, b DigestTalker) int {
+		if a.Messages != b.Messages {
+			return b.Messages - a.Messages
+		}
+		return strings.Compare(a.Talker, b.Talker)
+	})
+	d.digest.Active = d.digest.Active[:min(top, len(d.digest.Active))]
+	d.digest.Media.Notable = latest(d.notable, top)
+	for i := range d.digest.Keywords {
+		d.digest.Keywords[i].Hits = latest(d.hits[i], top)
+	}
+	return d.digest
+}
+
+// latest 返回最近的 n 条消息，从新到旧排列
+func latest(items []DigestItem, n int) []DigestItem {
+	slices.SortStableFunc(items, func(a, b DigestItem) int {
+		return b.Time.Compare(a.Time)
+	})
+	return items[:min(n, len(items))]
+}
+
+func digestItem(m *model.Message, talkerName string) DigestItem {
+	text := m.Content
+	if m.Type == 49 {
+		title, _ := m.Contents["title"].(string)
+		text = title
+	}
+	text = strings.Join(strings.Fields(text), " ")
+	if r := []rune(text); len(r) > DigestTextLen {
+		text = string(r[:DigestTextLen]) + "…"
+	}
+	sender := cmp.Or(m.SenderName, m.Sender)
+	if m.IsSelf {
+		sender = "me"
+	}
+	return DigestItem{
+		Talker:     m.Talker,
+		TalkerName: talkerName,
+		Sender:     sender,
+		Seq:        m.Seq,
+		Time:       m.Time,
+		Text:       text,
+	}
+}
+
+// PlainText 返回周报的纯文本内容，用于邮件正文与命令行输出
+func (d *Digest) PlainText() string {
+	buf := strings.Builder{}
+	fmt.Fprintf(&buf, "Chat digest %s ~ %s\n\n", d.Start.Format(time.DateOnly), d.End.Format(time.DateOnly))
+	fmt.Fprintf(&buf, "%d messages in %d conversations\n", d.Messages, d.Talkers)
+
+	if len(d.Active) > 0 {
+		buf.WriteString("\nMost active conversations\n")
+		for i, t := range d.Active {
+			fmt.Fprintf(&buf, "  %d. %s  %d messages, %d senders\n", i+1, t.Name, t.Messages, t.Senders)
+		}
+	}
+
+	media := d.Media
+	fmt.Fprintf(&buf, "\nMedia: %d images, %d videos, %d voice messages, %d files, %d links\n",
+		media.Images, media.Videos, media.Voices, media.Files, media.Links)
+	for _, item := range media.Notable {
+		buf.WriteString(item.line())
+	}
+
+	for _, k := range d.Keywords {
+		fmt.Fprintf(&buf, "\nKeyword %q: %d hits\n", k.Keyword, k.Count)
+		for _, item := range k.Hits {
+			buf.WriteString(item.line())
+		}
+	}
+	return buf.String()
+}
+
+func (i DigestItem) line() string {
+	return fmt.Sprintf("  - [%s] %s %s: %s\n", i.Time.Format("01-02 15:04"), i.TalkerName, i.Sender, i.Text)
+}
@@ -0,0 +1,39 @@
+package database
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aspnmy/chatlog/internal/model"
+)
+
+func TestDigester(t *testing.T) {
+	base := time.Date(2024, 1, 2, 10, 0, 0, 0, time.Local)
+	d := newDigester(base, base.AddDate(0, 0, 7), DigestOptions{Keywords: []string{"Release", " "}, Top: 1})
+	d.add("room@chatroom", "工作群", []*model.Message{
+		{Talker: "room@chatroom", Sender: "a", Type: 1, Content: "release today, RELEASE notes", Time: base},
+		{Talker: "room@chatroom", Sender: "b", Type: 3, Time: base.Add(time.Minute)},
+		{Talker: "room@chatroom", Sender: "b", Type: 49, SubType: 6, Contents: map[string]interface{}{"title": "plan.pdf"}, Time: base.Add(2 * time.Minute)},
+		{Talker: "room@chatroom", Sender: "系统消息", Type: 10000, Content: "release"},
+	})
+	d.add("bob", "Bob", []*model.Message{
+		{Talker: "bob", Sender: "bob", Type: 1, Content: "release?", Time: base.Add(time.Hour)},
+		{Talker: "bob", Sender: "bob", Type: 49, SubType: 5, Contents: map[string]interface{}{"title": "news"}, Time: base.Add(2 * time.Hour)},
+	})
+	d.add("empty", "Empty", nil)
+
+	got := d.result()
+	if got.Messages != 5 || got.Talkers != 2 {
+		t.Fatalf("messages = %d, talkers = %d", got.Messages, got.Talkers)
+	}
+	if len(got.Active) != 1 || got.Active[0].Talker != "room@chatroom" || got.Active[0].Senders != 2 {
+		t.Errorf("active = %+v", got.Active)
+	}
+	media := got.Media
+	if media.Images != 1 || media.Files != 1 || media.Links != 1 || len(media.Notable) != 1 || media.Notable[0].Text != "news" {
+		t.Errorf("media = %+v", media)
+	}
+	if len(got.Keywords) != 1 || got.Keywords[0].Count != 3 || len(got.Keywords[0].Hits) != 1 || got.Keywords[0].Hits[0].Talker != "bob" {
+		t.Errorf("keywords = %+v", got.Keywords)
+	}
+}
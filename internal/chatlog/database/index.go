@@ -0,0 +1,331 @@
+package database
+
+import (
+	"context"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/aspnmy/chatlog/internal/model"
+	"github.com/aspnmy/chatlog/pkg/search"
+	"github.com/aspnmy/chatlog/pkg/throttle"
+	"github.com/aspnmy/chatlog/pkg/trace"
+)
+
+// IndexBatchSize 每个事务写入索引的消息数
+var IndexBatchSize = 5000
+
+// IndexResult 索引构建结果
+type IndexResult struct {
+	Talkers  int           `json:"talkers"`
+	Messages int           `json:"messages"`
+	Resumed  int           `json:"resumed"`
+	Failed   []string      `json:"failed"`
+	Info     *search.Info  `json:"info"`
+	Duration time.Duration `json:"duration"`
+}
+
+// IndexPath 返回搜索索引文件路径
+func IndexPath(workDir string) string {
+	return filepath.Join(workDir, "chatlog", "search.db")
+}
+
+// openIndex 打开已有的搜索索引，索引不存在时搜索退回逐条匹配
+func (s *Service) openIndex() {
+	index, err := search.Open(IndexPath(s.ctx.WorkDir))
+	if err != nil {
+		if err != search.ErrNotBuilt {
+			log.Err(err).Msg("failed to open search index")
+		}
+		return
+	}
+	s.index.Store(index)
+}
+
+func (s *Service) closeIndex() {
+	if index := s.index.Swap(nil); index != nil {
+		index.Close()
+	}
+}
+
+// watchIndex 每次同步完成后将新消息写入搜索索引，直到 stop 关闭，索引未建立时不做任何事
+func (s *Service) watchIndex(stop <-chan struct{}) {
+	for {
+		synced := s.ctx.SyncSignal()
+		select {
+		case <-stop:
+			return
+		case <-synced:
+		}
+		if s.index.Load() == nil {
+			continue
+		}
+		if result, err := s.UpdateIndex(); err != nil {
+			log.Debug().Err(err).Msg("failed to update search index")
+		} else {
+			log.Debug().Msgf("search index updated, %d messages of %d talkers in %s", result.Messages, result.Talkers, result.Duration)
+		}
+	}
+}
+
+func (s *Service) startIndexWatch() {
+	s.indexStop = make(chan struct{})
+	go s.watchIndex(s.indexStop)
+}
+
+func (s *Service) stopIndexWatch() {
+	if s.indexStop != nil {
+		close(s.indexStop)
+		s.indexStop = nil
+	}
+}
+
+// IndexInfo 返回搜索索引概况，索引未建立时返回 search.ErrNotBuilt
+func (s *Service) IndexInfo() (*search.Info, error) {
+	index := s.index.Load()
+	if index == nil {
+		return nil, search.ErrNotBuilt
+	}
+	return index.Info()
+}
+
+// RebuildIndex 使用指定的分词器重新建立全部会话的搜索索引
+// 按 --workers 并发读取会话并分词，由单个写入者分批提交；每批提交后记录已完成的会话，
+// 中断后再次执行时，若分词参数相同则跳过已完成的会话继续建立，restart 为 true 时总是从头开始；
+// 有会话失败时返回的结果中 Failed 不为空，索引仍未建立完成，再次执行时只重试失败的会话
+func (s *Service) RebuildIndex(opts search.Options, restart bool) (*IndexResult, error) {
+	begin := time.Now()
+
+	ctx, span := trace.Start(context.Background(), "index.rebuild")
+	span.SetAttr("tokenizer", opts.Tokenizer).SetAttr("workers", throttle.Workers())
+	defer span.End()
+
+	s.closeIndex()
+	path := IndexPath(s.ctx.WorkDir)
+	var index *search.Index
+	var err error
+	if !restart {
+		if index, err = search.Resume(path, opts); err != nil && err != search.ErrNotBuilt {
+			return nil, err
+		}
+	}
+	if index == nil {
+		if index, err = search.Create(path, opts); err != nil {
+			return nil, err
+		}
+	}
+	done, err := index.Checkpoints()
+	if err != nil {
+		index.Close()
+		return nil, err
+	}
+
+	sessions, err := s.db.Load().GetSessions("", 0, 0)
+	if err != nil {
+		index.Close()
+		return nil, err
+	}
+
+	result := &IndexResult{}
+	talkers := make([]string, 0, len(sessions.Items))
+	for _, session := range sessions.Items {
+		if done[session.UserName] {
+			result.Resumed++
+			continue
+		}
+		talkers = append(talkers, session.UserName)
+	}
+	if result.Resumed > 0 {
+		log.Info().Msgf("resume index build, %d talkers already indexed", result.Resumed)
+	}
+
+	if err := s.indexTalkers(ctx, index, talkers, nil, result); err != nil {
+		index.Close()
+		return nil, err
+	}
+	sort.Strings(result.Failed)
+	result.Duration = time.Since(begin)
+	span.SetAttr("talkers", result.Talkers).SetAttr("messages", result.Messages)
+
+	// 有会话失败时不标记建立完成，失败的会话没有记录为已完成，再次执行时继续建立并重试这些会话；
+	// 标记完成后 UpdateIndex 只读取上次更新之后的消息，这些会话之前的消息不会再写入索引
+	if len(result.Failed) > 0 {
+		result.Info, err = index.Info()
+		index.Close()
+		if err != nil {
+			return nil, err
+		}
+		return result, nil
+	}
+
+	if err := index.Finish(); err != nil {
+		index.Close()
+		return nil, err
+	}
+	s.index.Store(index)
+	if result.Info, err = index.Info(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// UpdateIndex 将上次建立或更新索引后的新消息写入已有的索引，分词参数不变
+// 每个会话从索引中最新一条消息的时间开始读取，已写入的消息会被跳过；
+// 索引中没有消息的会话从上次更新索引的时间开始读取
+func (s *Service) UpdateIndex() (*IndexResult, error) {
+	index := s.index.Load()
+	if index == nil {
+		return nil, search.ErrNotBuilt
+	}
+	begin := time.Now()
+
+	ctx, span := trace.Start(context.Background(), "index.update")
+	span.SetAttr("workers", throttle.Workers())
+	defer span.End()
+
+	latest, err := index.Latest()
+	if err != nil {
+		return nil, err
+	}
+	info, err := index.Info()
+	if err != nil {
+		return nil, err
+	}
+	sessions, err := s.db.Load().GetSessions("", 0, 0)
+	if err != nil {
+		return nil, err
+	}
+	talkers := make([]string, 0, len(sessions.Items))
+	for _, session := range sessions.Items {
+		talkers = append(talkers, session.UserName)
+		if _, ok := latest[session.UserName]; !ok && !info.UpdatedAt.IsZero() {
+			latest[session.UserName] = info.UpdatedAt
+		}
+	}
+
+	result := &IndexResult{}
+	if err := s.indexTalkers(ctx, index, talkers, latest, result); err != nil {
+		return nil, err
+	}
+	if err := index.Finish(); err != nil {
+		return nil, err
+	}
+	if result.Info, err = index.Info(); err != nil {
+		return nil, err
+	}
+	sort.Strings(result.Failed)
+	result.Duration = time.Since(begin)
+	span.SetAttr("talkers", result.Talkers).SetAttr("messages", result.Messages)
+	return result, nil
+}
+
+// indexTalkers 按 --workers 并发读取会话并分词，由单个写入者分批提交
+// since 中记录了时间的会话只读取该时间之后的消息，其余会话读取全部消息
+func (s *Service) indexTalkers(ctx context.Context, index *search.Index, talkers []string, since map[string]time.Time, result *IndexResult) error {
+	// 单个写入者串行提交，读取与分词并发进行
+	batches := make(chan *search.Batch, throttle.Workers())
+	committed := make(chan error, 1)
+	go func() {
+		var err error
+		for b := range batches {
+			if err != nil {
+				continue
+			}
+			if err = index.Commit(b); err == nil {
+				result.Messages += b.Len()
+			}
+		}
+		committed <- err
+	}()
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, throttle.Workers())
+	end := time.Now().AddDate(1, 0, 0)
+	for _, talker := range talkers {
+		start, ok := since[talker]
+		if !ok {
+			start = time.Unix(0, 0)
+		}
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(talker string) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			err := s.indexTalker(ctx, index, talker, start, end, batches)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				log.Err(err).Msgf("index %s failed", talker)
+				result.Failed = append(result.Failed, talker)
+				return
+			}
+			result.Talkers++
+		}(talker)
+	}
+	wg.Wait()
+	close(batches)
+	return <-committed
+}
+
+// indexTalker 读取单个会话的消息并分批发送给写入者，最后一批会标记该会话已完成
+func (s *Service) indexTalker(ctx context.Context, index *search.Index, talker string, start, end time.Time, batches chan<- *search.Batch) (err error) {
+	_, span := trace.Start(ctx, "index.talker")
+	span.SetAttr("talker", talker)
+	n := 0
+	defer func() {
+		span.SetAttr("messages", n).SetError(err).End()
+	}()
+
+	messages, err := s.db.Load().GetMessages(start, end, talker, "", "", 0, 0)
+	if err != nil {
+		return err
+	}
+
+	docs := make([]search.Doc, 0, min(len(messages), IndexBatchSize))
+	for _, m := range messages {
+		content, ok := indexContent(m)
+		if !ok {
+			continue
+		}
+		docs = append(docs, search.Doc{
+			Talker:     m.Talker,
+			TalkerName: m.TalkerName,
+			Seq:        m.Seq,
+			Time:       m.Time,
+			Sender:     m.Sender,
+			SenderName: m.SenderName,
+			IsSelf:     m.IsSelf,
+			IsChatRoom: m.IsChatRoom,
+			Type:       m.Type,
+			SubType:    m.SubType,
+			Content:    content,
+		})
+		if len(docs) >= IndexBatchSize {
+			batches <- index.NewBatch(docs)
+			n += len(docs)
+			docs = make([]search.Doc, 0, IndexBatchSize)
+		}
+	}
+	batches <- index.NewBatch(docs, talker)
+	n += len(docs)
+	return nil
+}
+
+// indexContent 返回需要建立索引的文本，仅索引文字、分享与系统消息
+func indexContent(m *model.Message) (string, bool) {
+	switch m.Type {
+	case 1, 49, 10000:
+		m.SetContent("host", "")
+		content := m.PlainTextContent()
+		return content, content != ""
+	default:
+		return "", false
+	}
+}
@@ -0,0 +1,132 @@
+package database
+
+import (
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/aspnmy/chatlog/internal/errors"
+	"github.com/aspnmy/chatlog/internal/model"
+)
+
+// 群聊排行榜的统计指标
+const (
+	LeaderboardMessages = "messages" // 消息数，不含系统消息
+	LeaderboardImages   = "images"   // 图片数
+	LeaderboardLength   = "length"   // 文字消息的总字数
+)
+
+// LeaderboardMetrics 支持的统计指标
+var LeaderboardMetrics = []string{LeaderboardMessages, LeaderboardImages, LeaderboardLength}
+
+// LeaderboardEntry 排行榜中的一名群成员，Value 相同的成员名次相同
+type LeaderboardEntry struct {
+	Rank       int    `json:"rank"`
+	Sender     string `json:"sender"`
+	SenderName string `json:"senderName"`
+	Value      int    `json:"value"`
+	Messages   int    `json:"messages"`
+}
+
+// LeaderboardResp 群聊在时间范围内按指标排列的成员
+type LeaderboardResp struct {
+	Talker     string             `json:"talker"`
+	TalkerName string             `json:"talkerName"`
+	Metric     string             `json:"metric"`
+	Start      time.Time          `json:"start"`
+	End        time.Time          `json:"end"`
+	Total      int                `json:"total"`   // 所有成员的指标之和
+	Senders    int                `json:"senders"` // 指标不为 0 的成员数
+	Items      []LeaderboardEntry `json:"items"`
+}
+
+// GetLeaderboard 统计群聊在时间范围内每个成员的 metric 指标并按从高到低排列，limit 大于 0 时只返回前 limit 名
+// talker 支持群聊 ID 与名称，不是群聊时返回 ChatRoomNotFound
+func (s *Service) GetLeaderboard(talker, metric string, start, end time.Time, limit int) (*LeaderboardResp, error) {
+	if !slices.Contains(LeaderboardMetrics, metric) {
+		return nil, errors.InvalidArg("metric")
+	}
+	talkers := s.ResolveTalkers(talker)
+	if len(talkers) != 1 || !strings.HasSuffix(talkers[0], "@chatroom") {
+		return nil, errors.ChatRoomNotFound(talker)
+	}
+	messages, err := s.db.Load().GetMessages(start, end, talkers[0], "", "", 0, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &LeaderboardResp{
+		Talker: talkers[0],
+		Metric: metric,
+		Start:  start,
+		End:    end,
+		Items:  rankLeaderboard(messages, metric),
+	}
+	if len(messages) > 0 {
+		resp.TalkerName = messages[0].TalkerName
+	}
+	for _, e := range resp.Items {
+		resp.Total += e.Value
+	}
+	resp.Senders = len(resp.Items)
+	if limit > 0 && len(resp.Items) > limit {
+		resp.Items = resp.Items[:limit]
+	}
+	return resp, nil
+}
+
+// rankLeaderboard 按发送人统计 metric 指标，去掉指标为 0 的成员后从高到低排列，指标相同时按消息数、发送人排列
+func rankLeaderboard(messages []*model.Message, metric string) []LeaderboardEntry {
+	bySender := make(map[string]*LeaderboardEntry)
+	for _, m := range messages {
+		if m.Type == 10000 || m.Sender == "" {
+			continue
+		}
+		e, ok := bySender[m.Sender]
+		if !ok {
+			e = &LeaderboardEntry{Sender: m.Sender}
+			bySender[m.Sender] = e
+		}
+		if m.SenderName != "" {
+			e.SenderName = m.SenderName
+		}
+		e.Messages++
+		switch metric {
+		case LeaderboardMessages:
+			e.Value++
+		case LeaderboardImages:
+			if m.Type == 3 {
+				e.Value++
+			}
+		case LeaderboardLength:
+			if m.Type == 1 {
+				e.Value += utf8.RuneCountInString(strings.TrimSpace(m.Content))
+			}
+		}
+	}
+
+	items := make([]LeaderboardEntry, 0, len(bySender))
+	for _, e := range bySender {
+		if e.Value > 0 {
+			items = append(items, *e)
+		}
+	}
+	slices.SortFunc(items, func(a, b LeaderboardEntry) int {
+		if a.Value != b.Value {
+			return b.Value - a.Value
+		}
+		if a.Messages != b.Messages {
+			return b.Messages - a.Messages
+		}
+		return strings.Compare(a.Sender, b.Sender)
+	})
+	for i := range items {
+		if i > 0 && items[i].Value == items[i-1].Value {
+			items[i].Rank = items[i-1].Rank
+		} else {
+			items[i].Rank = i + 1
+		}
+	}
+	return items
+}
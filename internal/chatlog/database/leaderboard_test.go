@@ -0,0 +1,36 @@
+package database
+
+import (
+	"testing"
+
+	"github.com/aspnmy/chatlog/internal/model"
+)
+
+func TestRankLeaderboard(t *testing.T) {
+	messages := []*model.Message{
+		{Sender: "a", SenderName: "A", Type: 1, Content: "你好啊"},
+		{Sender: "a", Type: 3},
+		{Sender: "b", SenderName: "B", Type: 1, Content: "hi"},
+		{Sender: "b", Type: 1, Content: " x "},
+		{Sender: "c", SenderName: "C", Type: 3},
+		{Sender: "系统消息", Type: 10000, Content: "c 加入了群聊"},
+	}
+
+	msgs := rankLeaderboard(messages, LeaderboardMessages)
+	if len(msgs) != 3 || msgs[0].Rank != 1 || msgs[1].Rank != 1 || msgs[2].Sender != "c" || msgs[2].Rank != 3 {
+		t.Fatalf("messages leaderboard = %+v", msgs)
+	}
+	if msgs[0].SenderName != "A" {
+		t.Errorf("sender name = %q", msgs[0].SenderName)
+	}
+
+	images := rankLeaderboard(messages, LeaderboardImages)
+	if len(images) != 2 || images[0].Sender != "a" || images[1].Sender != "c" || images[1].Rank != 1 {
+		t.Fatalf("images leaderboard = %+v", images)
+	}
+
+	length := rankLeaderboard(messages, LeaderboardLength)
+	if len(length) != 2 || length[0].Sender != "a" || length[0].Value != 3 || length[1].Value != 3 || length[1].Rank != 1 {
+		t.Fatalf("length leaderboard = %+v", length)
+	}
+}
@@ -0,0 +1,362 @@
+package database
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/aspnmy/chatlog/internal/model"
+	"github.com/aspnmy/chatlog/pkg/util"
+)
+
+// MediaRecheckInterval 定期重新检查缺失媒体队列的间隔，自动解密同步新数据后也会立即检查
+var MediaRecheckInterval = 10 * time.Minute
+
+// MissingMediaPath 返回缺失媒体队列的文件路径，与搜索索引一样保存在工作目录中
+func MissingMediaPath(workDir string) string {
+	return filepath.Join(workDir, "chatlog", "media_missing.json")
+}
+
+// MissingMedia 本地不存在的图片、视频或文件，通常是未下载或已被微信清理
+// 在微信中打开会话并翻到这些消息后，微信会重新下载，之后重新检查时从队列中移除
+type MissingMedia struct {
+	Talker     string    `json:"talker"`
+	TalkerName string    `json:"talkerName,omitempty"`
+	Seq        int64     `json:"seq"`
+	Time       time.Time `json:"time"`
+	Type       string    `json:"type"` // image、video 或 file
+	Sender     string    `json:"sender"`
+	SenderName string    `json:"senderName,omitempty"`
+	Keys       []string  `json:"keys"` // 媒体索引，按优先级排列，规则同 /image、/video、/file
+
+	FirstSeen time.Time `json:"firstSeen"`
+	LastCheck time.Time `json:"lastCheck"`
+	Checks    int       `json:"checks"`
+}
+
+func (m *MissingMedia) id() string {
+	return fmt.Sprintf("%s/%d", m.Talker, m.Seq)
+}
+
+// MissingMediaReport 时间范围内缺失媒体的消息，按会话、时间排列
+type MissingMediaReport struct {
+	Checked  int             `json:"checked"` // 检查的媒体消息数
+	Missing  []*MissingMedia `json:"missing"`
+	ByTalker map[string]int  `json:"byTalker"`
+	ByType   map[string]int  `json:"byType"`
+}
+
+// MissingMediaQueue 等待重新检查的缺失媒体
+type MissingMediaQueue struct {
+	Items     []*MissingMedia `json:"items"`
+	Recovered int             `json:"recovered"` // 重新检查时已找到的数量
+	LastCheck time.Time       `json:"lastCheck"`
+}
+
+// mediaQueue 缺失媒体队列，第一次使用时从工作目录读取
+type mediaQueue struct {
+	mu     sync.Mutex
+	loaded bool
+	items  map[string]*MissingMedia
+	queue  MissingMediaQueue
+	stop   chan struct{}
+}
+
+// FindMissingMedia 检查会话在时间范围内的图片、视频与文件消息，返回本地不存在媒体文件的消息，
+// 并将其加入缺失媒体队列，talker 为空时检查所有会话，types 为空时检查所有媒体类型，hidden 不为 nil 时跳过其中的会话
+func (s *Service) FindMissingMedia(talker string, types []string, start, end time.Time, hidden func(string) bool) (*MissingMediaReport, error) {
+	var talkers []string
+	if talker != "" {
+		talkers = s.ResolveTalkers(talker)
+	} else {
+		sessions, err := s.db.Load().GetSessions("", 0, 0)
+		if err != nil {
+			return nil, err
+		}
+		for _, session := range sessions.Items {
+			talkers = append(talkers, session.UserName)
+		}
+	}
+
+	report := &MissingMediaReport{
+		Missing:  []*MissingMedia{},
+		ByTalker: make(map[string]int),
+		ByType:   make(map[string]int),
+	}
+	now := time.Now()
+	for _, t := range talkers {
+		if hidden != nil && hidden(t) {
+			continue
+		}
+		messages, err := s.db.Load().GetMessages(start, end, t, "", "", 0, 0)
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range messages {
+			_type, keys := MediaKeys(m)
+			if _type == "" || len(types) > 0 && !slices.Contains(types, _type) {
+				continue
+			}
+			report.Checked++
+			if s.ResolveMedia(_type, keys) != "" {
+				continue
+			}
+			report.Missing = append(report.Missing, &MissingMedia{
+				Talker:     m.Talker,
+				TalkerName: m.TalkerName,
+				Seq:        m.Seq,
+				Time:       m.Time,
+				Type:       _type,
+				Sender:     m.Sender,
+				SenderName: m.SenderName,
+				Keys:       keys,
+				FirstSeen:  now,
+				LastCheck:  now,
+				Checks:     1,
+			})
+			report.ByTalker[m.Talker]++
+			report.ByType[_type]++
+		}
+	}
+
+	if err := s.enqueueMissing(report.Missing); err != nil {
+		log.Err(err).Msg("failed to save missing media queue")
+	}
+	return report, nil
+}
+
+// MediaKeys 返回消息的媒体类型与按优先级排列的媒体索引，不是图片、视频或文件消息时类型为空
+func MediaKeys(m *model.Message) (string, []string) {
+	var _type string
+	var names []string
+	switch {
+	case m.Type == 3:
+		_type, names = "image", []string{"md5", "imgfile", "thumb"}
+	case m.Type == 43:
+		_type, names = "video", []string{"md5", "rawmd5", "videofile", "thumb"}
+	case m.Type == 49 && m.SubType == 6:
+		_type, names = "file", []string{"md5"}
+	default:
+		return "", nil
+	}
+	keys := make([]string, 0, len(names))
+	for _, name := range names {
+		if v, ok := m.Contents[name].(string); ok && v != "" {
+			keys = append(keys, v)
+		}
+	}
+	if len(keys) == 0 {
+		return "", nil
+	}
+	return _type, keys
+}
+
+// ResolveMedia 返回第一个存在的媒体文件的绝对路径，都不存在时返回空，
+// 32 位的索引为 md5，通过数据库查找文件路径，其他索引为数据目录中的相对路径，规则与 HTTP 服务的 /image、/video 相同
+func (s *Service) ResolveMedia(_type string, keys []string) string {
+	path, _ := s.LocateMedia(_type, keys)
+	return path
+}
+
+// LocateMedia 与 ResolveMedia 相同，找不到时返回原因：未指定数据目录、hardlink 数据库中没有 md5 的记录，
+// 或记录指向的文件已不在数据目录中（已被微信清理或未下载），多个索引都找不到时返回最具体的原因
+func (s *Service) LocateMedia(_type string, keys []string) (string, error) {
+	if s.ctx.DataDir == "" {
+		return "", fmt.Errorf("data dir not set")
+	}
+	if len(keys) == 0 {
+		return "", fmt.Errorf("no media reference in message")
+	}
+	var reason error
+	for _, k := range keys {
+		rel := k
+		if len(k) == 32 {
+			media, err := s.db.Load().GetMedia(_type, k)
+			if err != nil {
+				if reason == nil {
+					reason = fmt.Errorf("%s not found in hardlink database", k)
+				}
+				continue
+			}
+			rel = media.Path
+		}
+		abs := filepath.Join(s.ctx.DataDir, rel)
+		if info, err := os.Stat(abs); err == nil && !info.IsDir() {
+			return abs, nil
+		}
+		reason = fmt.Errorf("file %s not found in data dir", filepath.ToSlash(rel))
+	}
+	return "", reason
+}
+
+// MissingMediaQueue 返回等待重新检查的缺失媒体，按会话、时间排列
+func (s *Service) MissingMediaQueue() (*MissingMediaQueue, error) {
+	q := &s.media
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if err := s.loadQueue(); err != nil {
+		return nil, err
+	}
+	return &MissingMediaQueue{
+		Items:     sortedMissing(q.items),
+		Recovered: q.queue.Recovered,
+		LastCheck: q.queue.LastCheck,
+	}, nil
+}
+
+// RecheckMissingMedia 重新检查队列中的缺失媒体，已找到的从队列中移除，返回本次找到的数量
+func (s *Service) RecheckMissingMedia() (int, error) {
+	q := &s.media
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if err := s.loadQueue(); err != nil {
+		return 0, err
+	}
+	if len(q.items) == 0 {
+		return 0, nil
+	}
+	now := time.Now()
+	recovered := 0
+	for id, item := range q.items {
+		if s.ResolveMedia(item.Type, item.Keys) != "" {
+			delete(q.items, id)
+			recovered++
+			continue
+		}
+		item.LastCheck = now
+		item.Checks++
+	}
+	q.queue.Recovered += recovered
+	q.queue.LastCheck = now
+	if recovered > 0 {
+		log.Info().Msgf("recovered %d missing media, %d remaining", recovered, len(q.items))
+	}
+	return recovered, s.saveQueue()
+}
+
+// enqueueMissing 将缺失媒体加入队列，已在队列中的保留原来的 FirstSeen
+func (s *Service) enqueueMissing(items []*MissingMedia) error {
+	if len(items) == 0 {
+		return nil
+	}
+	q := &s.media
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if err := s.loadQueue(); err != nil {
+		return err
+	}
+	for _, item := range items {
+		if old, ok := q.items[item.id()]; ok {
+			item.FirstSeen = old.FirstSeen
+			item.Checks = old.Checks + 1
+		}
+		copied := *item
+		q.items[item.id()] = &copied
+	}
+	return s.saveQueue()
+}
+
+// loadQueue 读取工作目录中的队列文件，文件不存在时为空队列，调用方需持有 q.mu
+func (s *Service) loadQueue() error {
+	q := &s.media
+	if q.loaded {
+		return nil
+	}
+	q.items = make(map[string]*MissingMedia)
+	b, err := os.ReadFile(MissingMediaPath(s.ctx.WorkDir))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if len(b) > 0 {
+		if err := json.Unmarshal(b, &q.queue); err != nil {
+			return err
+		}
+		for _, item := range q.queue.Items {
+			q.items[item.id()] = item
+		}
+		q.queue.Items = nil
+	}
+	q.loaded = true
+	return nil
+}
+
+// saveQueue 保存队列，调用方需持有 q.mu
+func (s *Service) saveQueue() error {
+	q := &s.media
+	path := MissingMediaPath(s.ctx.WorkDir)
+	if err := util.PrepareDir(filepath.Dir(path)); err != nil {
+		return err
+	}
+	queue := q.queue
+	queue.Items = sortedMissing(q.items)
+	b, err := json.MarshalIndent(queue, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, b, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// watchMissingMedia 每次同步完成后以及每隔 MediaRecheckInterval 重新检查缺失媒体队列，直到 stop 关闭
+func (s *Service) watchMissingMedia(stop <-chan struct{}) {
+	for {
+		synced := s.ctx.SyncSignal()
+		timer := time.NewTimer(MediaRecheckInterval)
+		select {
+		case <-stop:
+			timer.Stop()
+			return
+		case <-synced:
+			timer.Stop()
+		case <-timer.C:
+		}
+		if _, err := s.RecheckMissingMedia(); err != nil {
+			log.Debug().Err(err).Msg("failed to recheck missing media")
+		}
+	}
+}
+
+func (s *Service) startMediaQueue() {
+	q := &s.media
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.loaded = false
+	q.items = nil
+	q.queue = MissingMediaQueue{}
+	q.stop = make(chan struct{})
+	go s.watchMissingMedia(q.stop)
+}
+
+func (s *Service) stopMediaQueue() {
+	q := &s.media
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.stop != nil {
+		close(q.stop)
+		q.stop = nil
+	}
+}
+
+func sortedMissing(items map[string]*MissingMedia) []*MissingMedia {
+	list := make([]*MissingMedia, 0, len(items))
+	for _, item := range items {
+		list = append(list, item)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Talker != list[j].Talker {
+			return list[i].Talker < list[j].Talker
+		}
+		return list[i].Seq < list[j].Seq
+	})
+	return list
+}
@@ -0,0 +1,49 @@
+package database
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aspnmy/chatlog/internal/chatlog/ctx"
+)
+
+func TestMissingMediaQueue(t *testing.T) {
+	dataDir, workDir := t.TempDir(), t.TempDir()
+	s := NewService(&ctx.Context{DataDir: dataDir, WorkDir: workDir})
+
+	items := []*MissingMedia{
+		{Talker: "wxid_a", Seq: 1, Type: "image", Keys: []string{"msg/attach/a/Img/1.dat"}},
+		{Talker: "wxid_a", Seq: 2, Type: "video", Keys: []string{"msg/video/2.mp4"}},
+	}
+	if err := s.enqueueMissing(items); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.enqueueMissing(items[:1]); err != nil {
+		t.Fatal(err)
+	}
+
+	// 微信重新下载了其中一个文件
+	path := filepath.Join(dataDir, "msg/video/2.mp4")
+	os.MkdirAll(filepath.Dir(path), 0755)
+	if err := os.WriteFile(path, []byte("video"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	recovered, err := s.RecheckMissingMedia()
+	if err != nil || recovered != 1 {
+		t.Fatalf("recovered = %d, err = %v", recovered, err)
+	}
+
+	// 重新打开后从工作目录读取队列
+	s = NewService(&ctx.Context{DataDir: dataDir, WorkDir: workDir})
+	queue, err := s.MissingMediaQueue()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(queue.Items) != 1 || queue.Items[0].Seq != 1 || queue.Recovered != 1 {
+		t.Fatalf("queue = %+v", queue)
+	}
+	if queue.Items[0].Checks != 2 {
+		t.Errorf("checks = %d", queue.Items[0].Checks)
+	}
+}
@@ -0,0 +1,67 @@
+package database
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/aspnmy/chatlog/internal/model"
+)
+
+// ResolveQuotes 为引用回复找到被引用的原消息，设置 ParentID，并以原消息的发送人、时间与内容替换 Body.Quote 中保存的副本
+// 原消息先在 messages 中查找，再按副本中的时间查询数据库；找不到时（已删除、已清除或不在数据中）保留副本并标记 Missing
+func (s *Service) ResolveQuotes(messages []*model.Message) {
+	resolveQuotes(messages, func(talker string, t time.Time) []*model.Message {
+		found, err := s.db.Load().GetMessages(t, t.Add(time.Second), talker, "", "", 0, 0)
+		if err != nil {
+			return nil
+		}
+		return found
+	})
+}
+
+// resolveQuotes 按服务器 ID 匹配原消息，lookup 返回聊天对象在某一秒附近的消息，每个时间只查询一次
+func resolveQuotes(messages []*model.Message, lookup func(talker string, t time.Time) []*model.Message) {
+	known := make(map[string]*model.Message)
+	add := func(m *model.Message) {
+		if m.ServerID != 0 {
+			known[m.Talker+"\x00"+strconv.FormatInt(m.ServerID, 10)] = m
+			// 服务器 ID 按无符号数写入 XML，数据库中可能以负数保存
+			known[m.Talker+"\x00"+strconv.FormatUint(uint64(m.ServerID), 10)] = m
+		}
+	}
+	for _, m := range messages {
+		add(m)
+	}
+
+	looked := make(map[string]bool)
+	for _, m := range messages {
+		if m.Body == nil || m.Body.Quote == nil || m.Body.Quote.ServerID == "" {
+			continue
+		}
+		q := m.Body.Quote
+		orig, ok := known[m.Talker+"\x00"+q.ServerID]
+		if !ok && !q.Time.IsZero() {
+			key := m.Talker + "\x00" + strconv.FormatInt(q.Time.Unix(), 10)
+			if !looked[key] {
+				looked[key] = true
+				for _, found := range lookup(m.Talker, q.Time) {
+					add(found)
+				}
+			}
+			orig, ok = known[m.Talker+"\x00"+q.ServerID]
+		}
+		if !ok || orig == m {
+			q.Missing = true
+			continue
+		}
+		m.ParentID = orig.Seq
+		q.Seq = orig.Seq
+		q.Missing = false
+		q.Sender, q.SenderName, q.Time = orig.Sender, orig.SenderName, orig.Time
+		if orig.Body != nil {
+			q.Body = orig.Body
+		} else {
+			q.Body = orig.Decode()
+		}
+	}
+}
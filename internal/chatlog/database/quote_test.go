@@ -0,0 +1,45 @@
+package database
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aspnmy/chatlog/internal/model"
+)
+
+func TestResolveQuotes(t *testing.T) {
+	at := time.Date(2024, 3, 1, 9, 0, 0, 0, time.Local)
+	quote := func(serverID string, t time.Time) *model.Message {
+		return &model.Message{Talker: "a@chatroom", Type: 49, SubType: 57, Content: "收到", Body: &model.Body{Kind: model.KindQuote,
+			Quote: &model.QuoteBody{ServerID: serverID, Sender: "wxid_a", Time: t, Body: &model.Body{Kind: model.KindText, Text: "副本"}}}}
+	}
+	inBatch := &model.Message{Talker: "a@chatroom", Seq: 1001, ServerID: 11, Sender: "wxid_b", SenderName: "小明", Time: at, Type: 1, Content: "原文"}
+	inDB := &model.Message{Talker: "a@chatroom", Seq: 900, ServerID: -2, Time: at.Add(-time.Hour), Type: 3}
+	messages := []*model.Message{
+		inBatch,
+		quote("11", at),
+		quote("18446744073709551614", at.Add(-time.Hour)), // 数据库中以负数保存的服务器 ID
+		quote("99", at.Add(-2*time.Hour)),
+	}
+	lookups := 0
+	resolveQuotes(messages, func(talker string, t time.Time) []*model.Message {
+		lookups++
+		if t.Equal(inDB.Time) {
+			return []*model.Message{inDB}
+		}
+		return nil
+	})
+
+	if q := messages[1].Body.Quote; messages[1].ParentID != 1001 || q.Seq != 1001 || q.SenderName != "小明" || q.Body.Text != "原文" || q.Missing {
+		t.Errorf("quote in batch = %d %+v", messages[1].ParentID, q)
+	}
+	if q := messages[2].Body.Quote; messages[2].ParentID != 900 || q.Body.Kind != model.KindImage {
+		t.Errorf("quote in db = %d %+v", messages[2].ParentID, q)
+	}
+	if q := messages[3].Body.Quote; messages[3].ParentID != 0 || !q.Missing || q.Body.Text != "副本" {
+		t.Errorf("missing quote = %d %+v", messages[3].ParentID, q)
+	}
+	if lookups != 2 {
+		t.Errorf("lookups = %d, want 2", lookups)
+	}
+}
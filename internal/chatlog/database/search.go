@@ -0,0 +1,330 @@
+package database
+
+import (
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aspnmy/chatlog/internal/errors"
+	"github.com/aspnmy/chatlog/internal/model"
+	"github.com/aspnmy/chatlog/internal/wechatdb"
+	"github.com/aspnmy/chatlog/pkg/search"
+	"github.com/aspnmy/chatlog/pkg/util"
+)
+
+// SearchReq 搜索条件
+type SearchReq struct {
+	Start      time.Time
+	End        time.Time
+	Talker     string
+	Sender     string
+	Keyword    string  // 关键词，已建立索引时为空白分隔的多个词，否则为正则表达式
+	Types      []int64 // 消息类型过滤，为空时不过滤
+	SnippetLen int     // 摘要最大字符数
+	Limit      int
+	Offset     int
+
+	// Hidden 不返回其中的聊天对象的消息，也不计入分面统计，用于隐藏锁定的会话
+	Hidden func(talker string) bool
+}
+
+// SearchHit 搜索命中的消息，Snippet 中的高亮区间以字符为单位
+type SearchHit struct {
+	Seq        int64          `json:"seq"`
+	Time       time.Time      `json:"time"`
+	Talker     string         `json:"talker"`
+	TalkerName string         `json:"talkerName"`
+	IsChatRoom bool           `json:"isChatRoom"`
+	Sender     string         `json:"sender"`
+	SenderName string         `json:"senderName"`
+	IsSelf     bool           `json:"isSelf"`
+	Type       int64          `json:"type"`
+	SubType    int64          `json:"subType"`
+	Snippet    search.Snippet `json:"snippet"`
+}
+
+// FacetCount 分面统计项
+type FacetCount struct {
+	Value string `json:"value"`
+	Name  string `json:"name,omitempty"`
+	Count int    `json:"count"`
+}
+
+// Facets 全部命中结果（分页前）按聊天对象、年份、消息类型的统计
+type Facets struct {
+	Talkers []FacetCount `json:"talkers"`
+	Years   []FacetCount `json:"years"`
+	Types   []FacetCount `json:"types"`
+}
+
+type SearchResp struct {
+	Total  int          `json:"total"`
+	Facets *Facets      `json:"facets"`
+	Items  []*SearchHit `json:"items"`
+}
+
+// Search 按关键词搜索消息，返回带高亮区间的摘要及分面统计
+// 已建立索引时通过索引查询，关键词中以空白分隔的多个词需同时出现，每个词按同义词文件扩展；
+// 未建立索引时逐条匹配指定会话的消息，关键词为正则表达式
+func (s *Service) Search(req SearchReq) (*SearchResp, error) {
+	if req.Keyword == "" {
+		return nil, errors.InvalidArg("keyword")
+	}
+
+	if index := s.index.Load(); index != nil {
+		return s.searchIndex(index, req)
+	}
+
+	// 逐条匹配时分面需要基于全部命中结果统计，因此先取全量再分页
+	matches, err := s.searchMessages(req)
+	if err != nil {
+		return nil, err
+	}
+	if req.Hidden != nil {
+		matches = slices.DeleteFunc(matches, func(m *match) bool {
+			return req.Hidden(m.hit.Talker)
+		})
+	}
+
+	resp := &SearchResp{
+		Total:  len(matches),
+		Facets: facetsOf(matches),
+	}
+
+	start := min(req.Offset, len(matches))
+	end := len(matches)
+	if req.Limit > 0 {
+		end = min(start+req.Limit, end)
+	}
+	resp.Items = make([]*SearchHit, 0, end-start)
+	for _, m := range matches[start:end] {
+		m.hit.Snippet = search.MakeSnippet(m.content, m.highlight(m.content), req.SnippetLen)
+		resp.Items = append(resp.Items, m.hit)
+	}
+	return resp, nil
+}
+
+// match 命中的消息，摘要在分页后再生成
+type match struct {
+	hit       *SearchHit
+	content   string
+	highlight func(content string) []search.Range
+}
+
+// searchIndex 通过索引搜索，分面统计与分页均在索引中完成，不读取全部命中结果
+func (s *Service) searchIndex(index *search.Index, req SearchReq) (*SearchResp, error) {
+	db := s.db.Load()
+	purged, err := s.purgedDocs(db, index)
+	if err != nil {
+		return nil, err
+	}
+	talker, sender := db.ParseTalkerAndSender(req.Talker, req.Sender)
+	q := search.Query{
+		Text:       req.Keyword,
+		Synonyms:   s.synonyms,
+		Talkers:    util.Str2List(talker, ","),
+		Senders:    util.Str2List(sender, ","),
+		Start:      req.Start,
+		End:        req.End,
+		Types:      req.Types,
+		ExcludeIDs: purged,
+	}
+
+	// 先按聊天对象统计，隐藏的聊天对象再作为排除条件用于其余统计和分页
+	buckets, err := index.Facets(q, search.FacetTalker)
+	if err != nil {
+		return nil, errors.QueryFailed("search index", err)
+	}
+	talkers := facetMap(buckets)
+	for _, b := range buckets {
+		if req.Hidden != nil && req.Hidden(b.Value) {
+			q.ExcludeTalkers = append(q.ExcludeTalkers, b.Value)
+			delete(talkers, b.Value)
+		}
+	}
+	resp := &SearchResp{Facets: &Facets{Talkers: sortFacets(talkers, false)}}
+	for _, fc := range talkers {
+		resp.Total += fc.Count
+	}
+
+	years, err := index.Facets(q, search.FacetYear)
+	if err != nil {
+		return nil, errors.QueryFailed("search index", err)
+	}
+	types, err := index.Facets(q, search.FacetType)
+	if err != nil {
+		return nil, errors.QueryFailed("search index", err)
+	}
+	resp.Facets.Years = sortFacets(facetMap(years), true)
+	resp.Facets.Types = sortFacets(facetMap(types), false)
+
+	q.Limit, q.Offset = req.Limit, req.Offset
+	docs, err := index.Search(q)
+	if err != nil {
+		return nil, errors.QueryFailed("search index", err)
+	}
+
+	// 高亮时同义词一并标出
+	var terms []string
+	for _, w := range strings.Fields(req.Keyword) {
+		terms = append(terms, s.synonyms.Expand(w)...)
+	}
+	resp.Items = make([]*SearchHit, 0, len(docs))
+	for _, d := range docs {
+		resp.Items = append(resp.Items, &SearchHit{
+			Seq:        d.Seq,
+			Time:       d.Time,
+			Talker:     d.Talker,
+			TalkerName: d.TalkerName,
+			IsChatRoom: d.IsChatRoom,
+			Sender:     d.Sender,
+			SenderName: d.SenderName,
+			IsSelf:     d.IsSelf,
+			Type:       d.Type,
+			SubType:    d.SubType,
+			Snippet:    search.MakeSnippet(d.Content, search.MatchTerms(d.Content, terms), req.SnippetLen),
+		})
+	}
+	return resp, nil
+}
+
+// purgedDocs 索引中的文档编号，对应建立索引之后才通过 chatlog purge --tombstone 清除的消息
+type purgedDocs struct {
+	db    *wechatdb.DB
+	index *search.Index
+	ids   []int64
+}
+
+// purgedDocs 返回索引中已清除消息的文档编号，清除记录在数据库打开后不再变化，
+// 因此按数据库与索引缓存；索引中没有消息内容，与清除记录的会话和时间相同的文档重新从数据库读取，读取不到的即已清除
+func (s *Service) purgedDocs(db *wechatdb.DB, index *search.Index) ([]int64, error) {
+	if p := s.purged.Load(); p != nil && p.db == db && p.index == index {
+		return p.ids, nil
+	}
+
+	var ids []int64
+	if tombstones := db.Tombstones(); tombstones != nil && tombstones.Len() > 0 {
+		var docs []search.Doc
+		for talker, times := range tombstones.Times() {
+			for ts := range times {
+				found, err := index.DocsAt(talker, time.Unix(ts, 0))
+				if err != nil {
+					return nil, errors.QueryFailed("check purged messages", err)
+				}
+				docs = append(docs, found...)
+			}
+		}
+		if len(docs) > 0 {
+			msgIDs := make([]model.MessageID, len(docs))
+			for i, d := range docs {
+				msgIDs[i] = model.MessageID{Talker: d.Talker, Seq: d.Seq}
+			}
+			_, missing, err := db.GetMessagesByID(msgIDs)
+			if err != nil {
+				return nil, errors.QueryFailed("check purged messages", err)
+			}
+			purged := make(map[model.MessageID]bool, len(missing))
+			for _, id := range missing {
+				purged[id] = true
+			}
+			for _, d := range docs {
+				if purged[model.MessageID{Talker: d.Talker, Seq: d.Seq}] {
+					ids = append(ids, d.ID)
+				}
+			}
+		}
+	}
+	s.purged.Store(&purgedDocs{db: db, index: index, ids: ids})
+	return ids, nil
+}
+
+func (s *Service) searchMessages(req SearchReq) ([]*match, error) {
+	re, err := regexp.Compile(req.Keyword)
+	if err != nil {
+		return nil, errors.QueryFailed("invalid regex pattern", err)
+	}
+
+	messages, err := s.db.Load().GetMessages(req.Start, req.End, req.Talker, req.Sender, req.Keyword, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	highlight := func(content string) []search.Range {
+		return search.Match(content, re)
+	}
+	matches := make([]*match, 0, len(messages))
+	for _, m := range messages {
+		if len(req.Types) > 0 && !slices.Contains(req.Types, m.Type) {
+			continue
+		}
+		matches = append(matches, &match{
+			hit: &SearchHit{
+				Seq:        m.Seq,
+				Time:       m.Time,
+				Talker:     m.Talker,
+				TalkerName: m.TalkerName,
+				IsChatRoom: m.IsChatRoom,
+				Sender:     m.Sender,
+				SenderName: m.SenderName,
+				IsSelf:     m.IsSelf,
+				Type:       m.Type,
+				SubType:    m.SubType,
+			},
+			content:   m.PlainTextContent(),
+			highlight: highlight,
+		})
+	}
+	return matches, nil
+}
+
+// facetMap 将索引中的分面统计转换为 sortFacets 的输入
+func facetMap(buckets []search.Bucket) map[string]*FacetCount {
+	m := make(map[string]*FacetCount, len(buckets))
+	for _, b := range buckets {
+		m[b.Value] = &FacetCount{Value: b.Value, Name: b.Name, Count: b.Count}
+	}
+	return m
+}
+
+func facetsOf(matches []*match) *Facets {
+	talkers := make(map[string]*FacetCount)
+	years := make(map[string]*FacetCount)
+	types := make(map[string]*FacetCount)
+
+	inc := func(m map[string]*FacetCount, value, name string) {
+		fc, ok := m[value]
+		if !ok {
+			fc = &FacetCount{Value: value, Name: name}
+			m[value] = fc
+		}
+		fc.Count++
+	}
+	for _, m := range matches {
+		inc(talkers, m.hit.Talker, m.hit.TalkerName)
+		inc(years, strconv.Itoa(m.hit.Time.Year()), "")
+		inc(types, strconv.FormatInt(m.hit.Type, 10), "")
+	}
+	return &Facets{
+		Talkers: sortFacets(talkers, false),
+		Years:   sortFacets(years, true),
+		Types:   sortFacets(types, false),
+	}
+}
+
+// sortFacets 默认按数量降序排列，byValue 时按取值升序排列
+func sortFacets(m map[string]*FacetCount, byValue bool) []FacetCount {
+	list := make([]FacetCount, 0, len(m))
+	for _, fc := range m {
+		list = append(list, *fc)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if !byValue && list[i].Count != list[j].Count {
+			return list[i].Count > list[j].Count
+		}
+		return list[i].Value < list[j].Value
+	})
+	return list
+}
@@ -1,16 +1,41 @@
 package database
 
 import (
+	"sync/atomic"
 	"time"
 
 	"github.com/aspnmy/chatlog/internal/chatlog/ctx"
 	"github.com/aspnmy/chatlog/internal/model"
 	"github.com/aspnmy/chatlog/internal/wechatdb"
+	"github.com/aspnmy/chatlog/pkg/search"
+	"github.com/aspnmy/chatlog/pkg/util"
 )
 
+// ReloadCloseDelay 是 Reload 后关闭旧连接前的等待时间
+const ReloadCloseDelay = 30 * time.Second
+
 type Service struct {
 	ctx *ctx.Context
-	db  *wechatdb.DB
+
+	// Reload 切换快照时替换，处理中的请求可能仍在使用旧值
+	db       atomic.Pointer[wechatdb.DB]
+	index    atomic.Pointer[search.Index]
+	synonyms *search.Synonyms
+
+	// 索引中已清除的消息，见 purgedDocs
+	purged atomic.Pointer[purgedDocs]
+
+	// 同步后增量更新索引，见 watchIndex
+	indexStop chan struct{}
+
+	// 译文，见 Translate
+	translations translations
+
+	// 缺失媒体队列，见 FindMissingMedia
+	media mediaQueue
+
+	// 自定义表情缓存，见 Sticker
+	stickers stickers
 }
 
 func NewService(ctx *ctx.Context) *Service {
@@ -20,49 +45,105 @@ func NewService(ctx *ctx.Context) *Service {
 }
 
 func (s *Service) Start() error {
-	db, err := wechatdb.New(s.ctx.WorkDir, s.ctx.Platform, s.ctx.Version)
+	db, err := s.open()
 	if err != nil {
 		return err
 	}
-	s.db = db
+	s.db.Store(db)
+	s.synonyms = search.NewSynonyms(s.ctx.SynonymFile)
+	s.openIndex()
+	s.startIndexWatch()
+	s.startMediaQueue()
+	return nil
+}
+
+func (s *Service) open() (*wechatdb.DB, error) {
+	var legacy []wechatdb.Source
+	if l := s.ctx.Legacy; l != nil && l.WorkDir != "" {
+		legacy = append(legacy, wechatdb.Source{Path: l.WorkDir, Platform: l.Platform, Version: l.Version})
+	}
+	return wechatdb.New(s.ctx.WorkDir, s.ctx.Platform, s.ctx.Version, legacy...)
+}
+
+// Reload 重新打开工作目录中的数据，用于切换到新的快照，
+// 新数据打开成功后才替换，旧连接延迟关闭以便处理中的请求完成
+func (s *Service) Reload() error {
+	db, err := s.open()
+	if err != nil {
+		return err
+	}
+	old := s.db.Swap(db)
+	oldIndex := s.index.Swap(nil)
+	s.openIndex()
+	s.stopIndexWatch()
+	s.startIndexWatch()
+	s.stopMediaQueue()
+	s.startMediaQueue()
+
+	go func() {
+		time.Sleep(ReloadCloseDelay)
+		if oldIndex != nil {
+			oldIndex.Close()
+		}
+		if old != nil {
+			old.Close()
+		}
+	}()
 	return nil
 }
 
 func (s *Service) Stop() error {
-	if s.db != nil {
-		s.db.Close()
+	s.stopMediaQueue()
+	s.stopIndexWatch()
+	s.closeIndex()
+	s.closeTranslations()
+	if db := s.db.Swap(nil); db != nil {
+		db.Close()
 	}
-	s.db = nil
 	return nil
 }
 
 func (s *Service) GetDB() *wechatdb.DB {
-	return s.db
+	return s.db.Load()
 }
 
 func (s *Service) GetMessages(start, end time.Time, talker string, sender string, keyword string, limit, offset int) ([]*model.Message, error) {
-	return s.db.GetMessages(start, end, talker, sender, keyword, limit, offset)
+	return s.db.Load().GetMessages(start, end, talker, sender, keyword, limit, offset)
+}
+
+// ResolveTalkers 将以英文逗号分隔的聊天对象（ID、备注名或昵称）解析为聊天对象 ID
+func (s *Service) ResolveTalkers(talker string) []string {
+	talker, _ = s.db.Load().ParseTalkerAndSender(talker, "")
+	return util.Str2List(talker, ",")
+}
+
+func (s *Service) GetMessageContext(talker string, seq int64, before, after int) ([]*model.Message, error) {
+	return s.db.Load().GetMessageContext(talker, seq, before, after)
+}
+
+func (s *Service) GetMessagesByID(ids []model.MessageID) ([]*model.Message, []model.MessageID, error) {
+	return s.db.Load().GetMessagesByID(ids)
 }
 
 func (s *Service) GetContacts(key string, limit, offset int) (*wechatdb.GetContactsResp, error) {
-	return s.db.GetContacts(key, limit, offset)
+	return s.db.Load().GetContacts(key, limit, offset)
 }
 
 func (s *Service) GetChatRooms(key string, limit, offset int) (*wechatdb.GetChatRoomsResp, error) {
-	return s.db.GetChatRooms(key, limit, offset)
+	return s.db.Load().GetChatRooms(key, limit, offset)
 }
 
 // GetSession retrieves session information
 func (s *Service) GetSessions(key string, limit, offset int) (*wechatdb.GetSessionsResp, error) {
-	return s.db.GetSessions(key, limit, offset)
+	return s.db.Load().GetSessions(key, limit, offset)
 }
 
 func (s *Service) GetMedia(_type string, key string) (*model.Media, error) {
-	return s.db.GetMedia(_type, key)
+	return s.db.Load().GetMedia(_type, key)
 }
 
 // Close closes the database connection
 func (s *Service) Close() {
 	// Add cleanup code if needed
-	s.db.Close()
+	s.db.Load().Close()
 }
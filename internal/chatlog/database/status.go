@@ -0,0 +1,36 @@
+package database
+
+import (
+	"slices"
+	"time"
+
+	"github.com/aspnmy/chatlog/internal/errors"
+	"github.com/aspnmy/chatlog/internal/model"
+)
+
+// GetStatusMessages 返回发送状态为 status 的消息，如 model.StatusFailed 用于检查没有发出的消息
+// hidden 中的聊天对象的消息不返回，limit 与 offset 在筛选后应用
+func (s *Service) GetStatusMessages(start, end time.Time, talker, sender, keyword, status string, hidden func(talker string) bool, limit, offset int) ([]*model.Message, error) {
+	if !model.ValidStatus(status) {
+		return nil, errors.InvalidArg("status")
+	}
+	messages, err := s.db.Load().GetMessages(start, end, talker, sender, keyword, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+	messages = FilterStatus(messages, status, hidden)
+
+	offset = min(max(offset, 0), len(messages))
+	messages = messages[offset:]
+	if limit > 0 && limit < len(messages) {
+		messages = messages[:limit]
+	}
+	return messages, nil
+}
+
+// FilterStatus 只保留发送状态为 status 且不在 hidden 中的消息，会修改 messages
+func FilterStatus(messages []*model.Message, status string, hidden func(talker string) bool) []*model.Message {
+	return slices.DeleteFunc(messages, func(m *model.Message) bool {
+		return m.Status != status || (hidden != nil && hidden(m.Talker))
+	})
+}
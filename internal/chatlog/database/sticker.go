@@ -0,0 +1,42 @@
+package database
+
+import (
+	"context"
+	"path/filepath"
+	"sync"
+
+	"github.com/aspnmy/chatlog/internal/errors"
+	"github.com/aspnmy/chatlog/internal/model"
+	"github.com/aspnmy/chatlog/internal/wechat/media"
+)
+
+// StickerPath 返回自定义表情的缓存目录，与搜索索引一样保存在工作目录中，重新解密不会覆盖
+func StickerPath(workDir string) string {
+	return filepath.Join(workDir, "chatlog", "stickers")
+}
+
+// stickers 自定义表情缓存，在第一次使用时创建
+type stickers struct {
+	once  sync.Once
+	cache *media.StickerCache
+}
+
+// Sticker 返回动画表情消息的表情文件路径，未缓存时从消息中的地址下载并保存到 StickerPath
+// offline 为 true 或下载失败时只使用已缓存的表情，找不到时返回 ErrMediaNotFound 等错误，调用方显示为 [动画表情]
+func (s *Service) Sticker(ctx context.Context, m *model.Message, offline bool) (string, error) {
+	if m.Type != 47 {
+		return "", errors.ErrMediaNotFound
+	}
+	s.stickers.once.Do(func() {
+		s.stickers.cache = media.NewStickerCache(StickerPath(s.ctx.WorkDir))
+	})
+	sticker := media.Sticker{}
+	sticker.MD5, _ = m.Contents["md5"].(string)
+	sticker.URL, _ = m.Contents["url"].(string)
+	sticker.EncryptURL, _ = m.Contents["encrypturl"].(string)
+	sticker.AESKey, _ = m.Contents["aeskey"].(string)
+	if sticker.MD5 == "" {
+		return "", errors.ErrMediaNotFound
+	}
+	return s.stickers.cache.Get(ctx, sticker, offline)
+}
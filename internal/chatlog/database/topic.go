@@ -0,0 +1,100 @@
+package database
+
+import (
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/aspnmy/chatlog/internal/errors"
+	"github.com/aspnmy/chatlog/internal/model"
+	"github.com/aspnmy/chatlog/pkg/hashtag"
+)
+
+// TopicCount 话题在时间范围内的消息数
+type TopicCount struct {
+	Topic string    `json:"topic"`
+	Tags  []string  `json:"tags"`
+	Count int       `json:"count"`
+	Last  time.Time `json:"last"` // 最近一条消息的时间
+}
+
+// topics 返回配置的话题约定，未加载配置时没有约定，标签自成话题
+func (s *Service) topics() *hashtag.Matcher {
+	if s.ctx.Topics == nil {
+		return hashtag.NewMatcher(nil)
+	}
+	return s.ctx.Topics
+}
+
+// TopicText 返回消息中用于识别话题标签的文字，只有文字消息与引用回复中会有人工输入的标签
+func TopicText(m *model.Message) string {
+	switch {
+	case m.Type == 1, m.Type == 49 && m.SubType == 57:
+		return m.Content
+	default:
+		return ""
+	}
+}
+
+// GetTopicMessages 返回带有话题任一标签的消息，话题未在配置中约定时按同名标签查找
+// hidden 中的聊天对象的消息不返回，limit 与 offset 在筛选后应用
+func (s *Service) GetTopicMessages(start, end time.Time, talker, sender, topic string, hidden func(talker string) bool, limit, offset int) ([]*model.Message, error) {
+	if hashtag.Normalize(topic) == "" {
+		return nil, errors.InvalidArg("topic")
+	}
+	topics := s.topics()
+	messages, err := s.db.Load().GetMessages(start, end, talker, sender, topics.Pattern(topic), 0, 0)
+	if err != nil {
+		return nil, err
+	}
+	messages = slices.DeleteFunc(messages, func(m *model.Message) bool {
+		return (hidden != nil && hidden(m.Talker)) || !topics.Match(TopicText(m), topic)
+	})
+
+	offset = min(max(offset, 0), len(messages))
+	messages = messages[offset:]
+	if limit > 0 && limit < len(messages) {
+		messages = messages[:limit]
+	}
+	return messages, nil
+}
+
+// GetTopics 统计时间范围内各话题的消息数，按消息数降序排列，hidden 中的聊天对象不计入
+func (s *Service) GetTopics(start, end time.Time, talker string, hidden func(talker string) bool) ([]TopicCount, error) {
+	messages, err := s.db.Load().GetMessages(start, end, talker, "", "[#＃]", 0, 0)
+	if err != nil {
+		return nil, err
+	}
+	return countTopics(s.topics(), messages, hidden), nil
+}
+
+func countTopics(topics *hashtag.Matcher, messages []*model.Message, hidden func(talker string) bool) []TopicCount {
+	counts := make(map[string]*TopicCount)
+	for _, m := range messages {
+		if hidden != nil && hidden(m.Talker) {
+			continue
+		}
+		for _, topic := range topics.Topics(TopicText(m)) {
+			tc, ok := counts[topic]
+			if !ok {
+				tc = &TopicCount{Topic: topic, Tags: topics.Tags(topic)}
+				counts[topic] = tc
+			}
+			tc.Count++
+			if m.Time.After(tc.Last) {
+				tc.Last = m.Time
+			}
+		}
+	}
+	list := make([]TopicCount, 0, len(counts))
+	for _, tc := range counts {
+		list = append(list, *tc)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Count != list[j].Count {
+			return list[i].Count > list[j].Count
+		}
+		return list[i].Topic < list[j].Topic
+	})
+	return list
+}
@@ -0,0 +1,31 @@
+package database
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aspnmy/chatlog/internal/model"
+	"github.com/aspnmy/chatlog/pkg/hashtag"
+)
+
+func TestCountTopics(t *testing.T) {
+	day := time.Date(2024, 3, 1, 9, 0, 0, 0, time.Local)
+	messages := []*model.Message{
+		{Talker: "a@chatroom", Type: 1, Time: day, Content: "周五上线 #决定"},
+		{Talker: "a@chatroom", Type: 1, Time: day.Add(time.Hour), Content: "#decision 用 sqlite #待办"},
+		{Talker: "a@chatroom", Type: 49, SubType: 57, Time: day.Add(2 * time.Hour), Content: "收到 #待办"},
+		{Talker: "a@chatroom", Type: 49, SubType: 5, Time: day, Content: "#待办 分享的标题"},
+		{Talker: "locked", Type: 1, Time: day, Content: "#决定"},
+	}
+	topics := hashtag.NewMatcher(map[string][]string{"decision": {"决定"}})
+	got := countTopics(topics, messages, func(talker string) bool { return talker == "locked" })
+	if len(got) != 2 {
+		t.Fatalf("countTopics = %+v", got)
+	}
+	if got[0].Topic != "decision" || got[0].Count != 2 || !got[0].Last.Equal(day.Add(time.Hour)) || len(got[0].Tags) != 2 {
+		t.Errorf("decision = %+v", got[0])
+	}
+	if got[1].Topic != "待办" || got[1].Count != 2 {
+		t.Errorf("待办 = %+v", got[1])
+	}
+}
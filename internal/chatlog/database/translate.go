@@ -0,0 +1,192 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/aspnmy/chatlog/internal/errors"
+	"github.com/aspnmy/chatlog/internal/model"
+	"github.com/aspnmy/chatlog/pkg/translate"
+	"github.com/aspnmy/chatlog/pkg/util"
+)
+
+// TranslationPath 返回译文文件路径，与搜索索引一样保存在工作目录中，重新解密不会覆盖
+func TranslationPath(workDir string) string {
+	return filepath.Join(workDir, "chatlog", "translations.db")
+}
+
+// translations 译文文件与翻译服务，译文文件在第一次使用时打开
+type translations struct {
+	mu         sync.Mutex
+	store      *translate.Store
+	translator translate.Translator
+	onDemand   bool
+}
+
+// SetTranslator 设置翻译服务，onDemand 为 true 时查询也会翻译尚未翻译的消息
+func (s *Service) SetTranslator(t translate.Translator, onDemand bool) {
+	s.translations.mu.Lock()
+	defer s.translations.mu.Unlock()
+	s.translations.translator = t
+	s.translations.onDemand = onDemand
+}
+
+// Translator 返回配置的翻译服务，未配置时返回 nil
+func (s *Service) Translator() translate.Translator {
+	s.translations.mu.Lock()
+	defer s.translations.mu.Unlock()
+	return s.translations.translator
+}
+
+func (s *Service) translationStore() (*translate.Store, error) {
+	t := &s.translations
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.store == nil {
+		store, err := translate.OpenStore(TranslationPath(s.ctx.WorkDir))
+		if err != nil {
+			return nil, err
+		}
+		t.store = store
+	}
+	return t.store, nil
+}
+
+func (s *Service) closeTranslations() {
+	t := &s.translations
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.store != nil {
+		t.store.Close()
+	}
+	t.store = nil
+}
+
+// Translate 将 messages 中文字消息的 lang 语言译文填入 Translation
+// fill 为 true 时由翻译服务翻译尚未翻译的消息并保存，否则只使用已保存的译文（配置了 on_demand 时除外）
+// 返回仍然没有译文的文字消息数
+func (s *Service) Translate(ctx context.Context, messages []*model.Message, lang string, fill bool) (int, error) {
+	if lang == "" {
+		return 0, nil
+	}
+	var texts []string
+	for _, m := range messages {
+		if text, ok := translatable(m); ok {
+			texts = append(texts, text)
+		}
+	}
+	if len(texts) == 0 {
+		return 0, nil
+	}
+
+	store, err := s.translationStore()
+	if err != nil {
+		return 0, err
+	}
+	s.translations.mu.Lock()
+	tr := s.translations.translator
+	if !fill && !s.translations.onDemand {
+		tr = nil
+	}
+	s.translations.mu.Unlock()
+
+	// 翻译中途失败时仍填入已有的译文
+	result, err := translate.Fill(ctx, store, tr, lang, texts)
+	missing := 0
+	for _, m := range messages {
+		text, ok := translatable(m)
+		if !ok {
+			continue
+		}
+		if t, ok := result[text]; ok {
+			m.Translation = t
+		} else {
+			missing++
+		}
+	}
+	return missing, err
+}
+
+// translatable 返回需要翻译的文本，仅翻译文字消息
+func translatable(m *model.Message) (string, bool) {
+	if m.Type != 1 {
+		return "", false
+	}
+	text := strings.TrimSpace(m.Content)
+	return text, text != ""
+}
+
+// TranslateResult 批量翻译结果
+type TranslateResult struct {
+	Lang     string        `json:"lang"`
+	Talkers  int           `json:"talkers"`
+	Messages int           `json:"messages"` // 有译文的文字消息数，包括之前已翻译的
+	Missing  int           `json:"missing"`  // 翻译失败的文字消息数
+	Failed   []string      `json:"failed,omitempty"`
+	Duration time.Duration `json:"duration"`
+}
+
+// TranslateAll 翻译会话中尚未翻译的文字消息并保存，供查询与导出使用
+// talker 为空时翻译所有会话，多个以英文逗号分隔；timeRange 格式同 /api/v1/chatlog 的 time 参数，为空时翻译全部
+// 在线翻译服务有频率限制，会话依次翻译，中断后再次执行时已保存的译文不会重复翻译
+func (s *Service) TranslateAll(ctx context.Context, talker, timeRange, lang string) (*TranslateResult, error) {
+	begin := time.Now()
+	if lang == "" {
+		return nil, errors.InvalidArg("lang")
+	}
+	if s.Translator() == nil {
+		return nil, fmt.Errorf("translate provider is not configured")
+	}
+	if timeRange == "" {
+		timeRange = "2000-01-01~" + time.Now().Format("2006-01-02")
+	}
+	start, end, ok := util.TimeRangeOf(timeRange)
+	if !ok {
+		return nil, errors.InvalidArg("time")
+	}
+
+	talkers := util.Str2List(talker, ",")
+	if len(talkers) == 0 {
+		sessions, err := s.db.Load().GetSessions("", 0, 0)
+		if err != nil {
+			return nil, err
+		}
+		for _, session := range sessions.Items {
+			talkers = append(talkers, session.UserName)
+		}
+	}
+
+	result := &TranslateResult{Lang: lang}
+	for _, talker := range talkers {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+		messages, err := s.db.Load().GetMessages(start, end, talker, "", "", 0, 0)
+		if err == nil {
+			var missing int
+			missing, err = s.Translate(ctx, messages, lang, true)
+			result.Missing += missing
+			for _, m := range messages {
+				if m.Translation != "" {
+					result.Messages++
+				}
+			}
+		}
+		if err != nil {
+			log.Err(err).Msgf("translate %s failed", talker)
+			result.Failed = append(result.Failed, talker)
+			continue
+		}
+		result.Talkers++
+	}
+	sort.Strings(result.Failed)
+	result.Duration = time.Since(begin)
+	return result, nil
+}
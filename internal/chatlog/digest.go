@@ -0,0 +1,192 @@
+package chatlog
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/aspnmy/chatlog/internal/chatlog/conf"
+	"github.com/aspnmy/chatlog/internal/chatlog/database"
+	"github.com/aspnmy/chatlog/pkg/util"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// DigestCheckInterval 服务运行期间检查是否需要发送周报的间隔
+	DigestCheckInterval = time.Minute
+	// DigestRetryInterval 周报发送失败后重试的间隔
+	DigestRetryInterval = time.Hour
+	// DigestStateFile 工作目录下记录上次发送周报时间的文件
+	DigestStateFile = "digest.json"
+)
+
+// DigestStatePath 返回记录上次发送周报时间的文件路径
+func DigestStatePath(workDir string) string {
+	return filepath.Join(workDir, "chatlog", DigestStateFile)
+}
+
+type digestState struct {
+	LastSent time.Time `json:"last_sent"`
+}
+
+// EnableDigest 启动服务时发送周报，即使配置文件中没有开启 digest.enabled
+func (m *Manager) EnableDigest() {
+	m.digestForced = true
+}
+
+// startDigest 开启了周报且配置了 smtp 时，在后台按 digest 配置的时间每周发送一次
+func (m *Manager) startDigest() {
+	c := m.conf.GetConfig().Digest
+	if !m.digestForced && (c == nil || !c.Enabled) {
+		return
+	}
+	if c == nil {
+		c = &conf.DigestConfig{}
+	}
+	weekday, at, err := c.Schedule()
+	if err != nil {
+		log.Err(err).Msg("invalid digest config, weekly digest disabled")
+		return
+	}
+	if !m.conf.GetConfig().SMTP.Enabled() {
+		log.Warn().Msg("smtp is not configured, weekly digest disabled")
+		return
+	}
+	m.stopDigest()
+	m.digestStop = make(chan struct{})
+	go m.digestLoop(m.digestStop, *c, weekday, at)
+}
+
+func (m *Manager) stopDigest() {
+	if m.digestStop != nil {
+		close(m.digestStop)
+		m.digestStop = nil
+	}
+}
+
+func (m *Manager) digestLoop(stop <-chan struct{}, c conf.DigestConfig, weekday time.Weekday, at time.Duration) {
+	ticker := time.NewTicker(DigestCheckInterval)
+	defer ticker.Stop()
+	var retry time.Time
+	for {
+		if now := time.Now(); now.After(retry) {
+			if err := m.checkDigest(now, c, weekday, at); err != nil {
+				log.Err(err).Msg("failed to send weekly digest")
+				retry = now.Add(DigestRetryInterval)
+			}
+		}
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// checkDigest 上次发送早于最近一个发送时间时，发送该时间之前 7 天的周报
+// 第一次开启时只记录当前时间，从下一个发送时间开始发送
+func (m *Manager) checkDigest(now time.Time, c conf.DigestConfig, weekday time.Weekday, at time.Duration) error {
+	path := DigestStatePath(m.ctx.WorkDir)
+	var state digestState
+	if data, err := os.ReadFile(path); err == nil {
+		if err := json.Unmarshal(data, &state); err != nil {
+			log.Debug().Err(err).Msg("failed to parse digest state")
+		}
+	}
+	due := lastDigestTime(now, weekday, at)
+	if !state.LastSent.IsZero() && !state.LastSent.Before(due) {
+		return nil
+	}
+	if !state.LastSent.IsZero() {
+		if err := m.sendDigest(due.AddDate(0, 0, -7), due, c); err != nil {
+			return err
+		}
+		log.Info().Msgf("weekly digest sent to %v", m.conf.GetConfig().SMTP.To)
+	}
+	return writeDigestState(path, digestState{LastSent: now})
+}
+
+// lastDigestTime 返回不晚于 now 的最近一个发送时间
+func lastDigestTime(now time.Time, weekday time.Weekday, at time.Duration) time.Time {
+	days := (int(now.Weekday()) - int(weekday) + 7) % 7
+	day := now.AddDate(0, 0, -days)
+	due := time.Date(day.Year(), day.Month(), day.Day(), int(at/time.Hour), int(at%time.Hour/time.Minute), 0, 0, now.Location())
+	if due.After(now) {
+		due = due.AddDate(0, 0, -7)
+	}
+	return due
+}
+
+func writeDigestState(path string, state digestState) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	if err := util.PrepareDir(filepath.Dir(path)); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0600)
+}
+
+// digest 按 digest 配置生成周报，不包含锁定的会话
+func (m *Manager) digest(start, end time.Time, c conf.DigestConfig) (*database.Digest, error) {
+	return m.db.GetDigest(start, end, database.DigestOptions{
+		Keywords: c.Keywords,
+		Top:      c.Top,
+		Hidden:   m.ctx.Locked,
+	})
+}
+
+func (m *Manager) sendDigest(start, end time.Time, c conf.DigestConfig) error {
+	d, err := m.digest(start, end, c)
+	if err != nil {
+		return err
+	}
+	return m.sendMail(digestSubject(d), d.PlainText())
+}
+
+func digestSubject(d *database.Digest) string {
+	return fmt.Sprintf("[chatlog] digest %s ~ %s: %d messages", d.Start.Format(time.DateOnly), d.End.Format(time.DateOnly), d.Messages)
+}
+
+// CommandDigest 生成时间范围内的周报，timeRange 为空时为最近 7 天，send 为 true 时按 smtp 配置发送邮件
+// 关键词与条数使用配置文件中的 digest 设置
+func (m *Manager) CommandDigest(workDir string, platform string, version int, timeRange string, send bool) (*database.Digest, error) {
+	if workDir == "" {
+		return nil, fmt.Errorf("workDir is required")
+	}
+	if timeRange == "" {
+		timeRange = "last-7d"
+	}
+	start, end, ok := util.TimeRangeOf(timeRange)
+	if !ok {
+		return nil, fmt.Errorf("invalid time range %q", timeRange)
+	}
+
+	m.ctx.WorkDir = workDir
+	m.ctx.Platform = platform
+	m.ctx.Version = version
+
+	if err := m.db.Start(); err != nil {
+		return nil, err
+	}
+	defer m.db.Stop()
+
+	var c conf.DigestConfig
+	if m.conf.GetConfig().Digest != nil {
+		c = *m.conf.GetConfig().Digest
+	}
+	d, err := m.digest(start, end, c)
+	if err != nil {
+		return nil, err
+	}
+	if send {
+		if err := m.sendMail(digestSubject(d), d.PlainText()); err != nil {
+			return d, err
+		}
+	}
+	return d, nil
+}
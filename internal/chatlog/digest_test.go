@@ -0,0 +1,25 @@
+package chatlog
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLastDigestTime(t *testing.T) {
+	at := 9*time.Hour + 30*time.Minute
+	// 2024-01-03 是周三
+	tests := []struct {
+		now  time.Time
+		want time.Time
+	}{
+		{time.Date(2024, 1, 3, 12, 0, 0, 0, time.Local), time.Date(2024, 1, 1, 9, 30, 0, 0, time.Local)},
+		{time.Date(2024, 1, 1, 9, 30, 0, 0, time.Local), time.Date(2024, 1, 1, 9, 30, 0, 0, time.Local)},
+		{time.Date(2024, 1, 1, 9, 29, 0, 0, time.Local), time.Date(2023, 12, 25, 9, 30, 0, 0, time.Local)},
+		{time.Date(2024, 1, 7, 23, 0, 0, 0, time.Local), time.Date(2024, 1, 1, 9, 30, 0, 0, time.Local)},
+	}
+	for _, tt := range tests {
+		if got := lastDigestTime(tt.now, time.Monday, at); !got.Equal(tt.want) {
+			t.Errorf("lastDigestTime(%v) = %v, want %v", tt.now, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package export
+
+import (
+	"context"
+	"encoding/csv"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/aspnmy/chatlog/internal/model"
+	"github.com/aspnmy/chatlog/pkg/destination"
+	"github.com/aspnmy/chatlog/pkg/throttle"
+)
+
+// 附件清单中每个附件的导出结果，见 writeAttachments
+const (
+	AttachmentCopied  = "copied"
+	AttachmentLinked  = "linked"
+	AttachmentMissing = "missing"
+)
+
+// writeAttachments 导出会话中的视频与文件原文件，默认按 年/年-月 目录存放，文件保留原文件名，并生成 manifest.csv
+// 附件通过 hardlink 数据库从 md5 找到数据目录中的文件，link 为 true 且导出到本地目录时创建硬链接，无法链接时复制
+// 找不到的附件（没有记录、已被微信清理或未下载）不会使导出失败，在 manifest.csv 中记为 missing 并给出原因
+func (s *Service) writeAttachments(ctx context.Context, dest destination.Destination, names *namer, talker string, messages []*model.Message, link bool) (*exportedFile, error) {
+	dir := sanitize(talker)
+	f := &exportedFile{name: path.Join(dir, "manifest.csv")}
+	tname := talkerName(talker, messages)
+	linker, _ := dest.(destination.Linker)
+
+	var rows [][]string
+	missing := 0
+	for _, m := range messages {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		var _type, title string
+		var keys []string
+		switch {
+		case m.Type == 43:
+			// 不使用缩略图代替视频，找不到原视频时记为缺失
+			_type, keys = "video", mediaKeys(m, "md5", "rawmd5", "videofile")
+		case m.Type == 49 && m.SubType == 6:
+			_type, keys = "file", mediaKeys(m, "md5")
+			title, _ = m.Contents["title"].(string)
+		default:
+			continue
+		}
+		sender := m.SenderName
+		if sender == "" {
+			sender = m.Sender
+		}
+		row := []string{m.Time.Format("2006-01-02 15:04:05"), sender, _type, strconv.FormatInt(m.Seq, 10), title}
+
+		src, err := s.db.LocateMedia(_type, keys)
+		if err != nil {
+			missing++
+			rows = append(rows, append(row, "", "", AttachmentMissing, err.Error()))
+			continue
+		}
+		ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(src), "."))
+		if ext == "" {
+			ext = "bin"
+		}
+		name := names.name(attachmentName, messageFields(talker, tname, m, _type, ext))
+		status := AttachmentLinked
+		if !link || linker == nil || linker.Link(name, src) != nil {
+			status = AttachmentCopied
+			var n int64
+			if name, n, err = copyMedia(dest, src, func(string) string { return name }); err != nil {
+				log.Debug().Err(err).Msgf("copy media %s failed", src)
+				missing++
+				rows = append(rows, append(row, "", "", AttachmentMissing, err.Error()))
+				continue
+			}
+			f.bytes += n
+		}
+		var size string
+		if info, err := os.Stat(src); err == nil {
+			size = strconv.FormatInt(info.Size(), 10)
+		}
+		rows = append(rows, append(row, relName(dir, name), size, status, ""))
+		f.messages++
+	}
+	if len(rows) == 0 {
+		return nil, nil
+	}
+	if missing > 0 {
+		log.Warn().Msgf("%s: %d of %d attachments not found, see manifest.csv", talker, missing, len(rows))
+	}
+
+	w, err := dest.Create(f.name)
+	if err != nil {
+		return nil, err
+	}
+	cw := &countWriter{w: throttle.Writer(w)}
+	cw.Write([]byte("\xef\xbb\xbf")) // BOM，便于 Excel 识别 UTF-8
+	csvw := csv.NewWriter(cw)
+	csvw.Write([]string{"time", "sender", "type", "msgid", "name", "file", "size", "status", "reason"})
+	csvw.WriteAll(rows)
+	if err := csvw.Error(); err != nil {
+		destination.Abort(w)
+		return nil, err
+	}
+	f.bytes += cw.n
+	return f, w.Close()
+}
@@ -0,0 +1,68 @@
+package export
+
+import (
+	"context"
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aspnmy/chatlog/internal/chatlog/ctx"
+	"github.com/aspnmy/chatlog/internal/chatlog/database"
+	"github.com/aspnmy/chatlog/internal/model"
+	"github.com/aspnmy/chatlog/pkg/destination"
+)
+
+func TestWriteAttachments(t *testing.T) {
+	dataDir := t.TempDir()
+	video := filepath.Join(dataDir, "msg/video/2024-01/v.mp4")
+	os.MkdirAll(filepath.Dir(video), 0755)
+	if err := os.WriteFile(video, []byte("video"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	at := time.Date(2024, 1, 5, 9, 0, 0, 0, time.Local)
+	messages := []*model.Message{
+		{Seq: 1, Type: 43, Talker: "wxid_a", Sender: "wxid_a", Time: at, Contents: map[string]interface{}{"videofile": "msg/video/2024-01/v.mp4"}},
+		{Seq: 2, Type: 49, SubType: 6, Talker: "wxid_a", Sender: "wxid_a", Time: at, Contents: map[string]interface{}{"title": "周报.pdf", "md5": "msg/file/2024-01/周报.pdf"}},
+		{Seq: 3, Type: 1, Talker: "wxid_a", Content: "hi", Time: at},
+	}
+
+	for _, link := range []bool{false, true} {
+		dir := t.TempDir()
+		dest, err := destination.NewLocal(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c := &ctx.Context{DataDir: dataDir}
+		s := &Service{ctx: c, db: database.NewService(c)}
+		f, err := s.writeAttachments(context.Background(), dest, newNamer(""), "wxid_a", messages, link)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if f.messages != 1 {
+			t.Errorf("exported %d attachments, want 1", f.messages)
+		}
+
+		b, err := os.ReadFile(filepath.Join(dir, "wxid_a", "manifest.csv"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		rows, err := csv.NewReader(strings.NewReader(strings.TrimPrefix(string(b), "\xef\xbb\xbf"))).ReadAll()
+		if err != nil || len(rows) != 3 {
+			t.Fatalf("manifest = %q, %v", b, err)
+		}
+		got := rows[1]
+		if got[5] != "2024/2024-01/20240105_090000_1.mp4" || got[6] != "5" || (got[7] != AttachmentCopied && got[7] != AttachmentLinked) || (!link && got[7] != AttachmentCopied) {
+			t.Errorf("video row = %q", got)
+		}
+		if data, _ := os.ReadFile(filepath.Join(dir, "wxid_a", got[5])); string(data) != "video" {
+			t.Errorf("exported video = %q", data)
+		}
+		// 找不到的文件不使导出失败，记录在清单中
+		if got := rows[2]; got[4] != "周报.pdf" || got[7] != AttachmentMissing || !strings.Contains(got[8], "not found") {
+			t.Errorf("file row = %q", got)
+		}
+	}
+}
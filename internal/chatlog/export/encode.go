@@ -0,0 +1,133 @@
+package export
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/aspnmy/chatlog/internal/model"
+)
+
+// csvHeader CSV 格式的列，与 JSON / JSONL 格式中消息的字段名相同
+// 列名与顺序保持稳定，新增的列只会追加在末尾
+var csvHeader = []string{
+	"seq", "time", "talker", "talkerName", "isChatRoom", "sender", "senderName", "isSelf",
+	"type", "subType", "content", "contents", "translation", "body", "status",
+}
+
+// encoder 将消息逐段写出为聊天记录文件，json 格式输出为一个数组，与一次编码全部消息的结果相同
+type encoder struct {
+	w          io.Writer
+	format     string
+	timeFormat string
+
+	json *json.Encoder
+	csv  *csv.Writer
+	n    int // 已写出的消息数
+	err  error
+}
+
+func newEncoder(w io.Writer, format string, timeFormat string) *encoder {
+	e := &encoder{w: w, format: format, timeFormat: timeFormat}
+	switch format {
+	case FormatJSONL:
+		e.json = json.NewEncoder(w)
+	case FormatCSV:
+		// BOM，便于 Excel 识别 UTF-8
+		_, e.err = io.WriteString(w, "\xef\xbb\xbf")
+		e.csv = csv.NewWriter(w)
+		e.csv.Write(csvHeader)
+	}
+	return e
+}
+
+// encode 写出一段消息
+func (e *encoder) encode(messages []*model.Message) error {
+	if e.err != nil {
+		return e.err
+	}
+	for _, m := range messages {
+		switch e.format {
+		case FormatJSON:
+			e.err = e.jsonElement(m)
+		case FormatJSONL:
+			e.err = e.json.Encode(m)
+		case FormatCSV:
+			e.err = e.csv.Write(csvRecord(m))
+		default:
+			_, e.err = io.WriteString(e.w, m.PlainText(false, e.timeFormat, "")+"\n")
+		}
+		if e.err != nil {
+			return e.err
+		}
+		e.n++
+	}
+	if e.csv != nil {
+		e.csv.Flush()
+		e.err = e.csv.Error()
+	}
+	return e.err
+}
+
+// jsonElement 写出 json 数组中的一个元素，缩进与 json.Encoder 的 SetIndent("", "  ") 相同
+func (e *encoder) jsonElement(m *model.Message) error {
+	data, err := json.MarshalIndent(m, "  ", "  ")
+	if err != nil {
+		return err
+	}
+	sep := ",\n  "
+	if e.n == 0 {
+		sep = "[\n  "
+	}
+	if _, err := io.WriteString(e.w, sep); err != nil {
+		return err
+	}
+	_, err = e.w.Write(data)
+	return err
+}
+
+// close 结束写出，json 格式写出数组的结尾
+func (e *encoder) close() error {
+	if e.err != nil || e.format != FormatJSON {
+		return e.err
+	}
+	end := "\n]\n"
+	if e.n == 0 {
+		end = "[]\n"
+	}
+	_, e.err = io.WriteString(e.w, end)
+	return e.err
+}
+
+// csvRecord 返回消息在 CSV 中的一行，时间与 JSON 相同为 RFC 3339 格式，contents 为媒体索引等信息的 JSON，body 为解码后消息内容的 JSON
+func csvRecord(m *model.Message) []string {
+	contents := ""
+	if len(m.Contents) > 0 {
+		data, _ := json.Marshal(m.Contents)
+		contents = string(data)
+	}
+	body := ""
+	if m.Body != nil {
+		data, _ := json.Marshal(m.Body)
+		body = string(data)
+	}
+	return []string{
+		strconv.FormatInt(m.Seq, 10),
+		m.Time.Format(time.RFC3339Nano),
+		m.Talker,
+		m.TalkerName,
+		strconv.FormatBool(m.IsChatRoom),
+		m.Sender,
+		m.SenderName,
+		strconv.FormatBool(m.IsSelf),
+		strconv.FormatInt(m.Type, 10),
+		strconv.FormatInt(m.SubType, 10),
+		m.Content,
+		contents,
+		m.Translation,
+		body,
+		m.Status,
+	}
+}
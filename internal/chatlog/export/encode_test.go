@@ -0,0 +1,117 @@
+package export
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aspnmy/chatlog/internal/model"
+)
+
+func testMessages() []*model.Message {
+	t0 := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	return []*model.Message{
+		{Seq: 1, Time: t0, Talker: "a", Sender: "b", Type: 1, Content: "hello, \"world\"\n<b>"},
+		{Seq: 2, Time: t0.Add(time.Minute), Talker: "a", Sender: "a", IsSelf: true, Type: 3, Contents: map[string]interface{}{"md5": "abc"}},
+		{Seq: 3, Time: t0.Add(time.Hour), Talker: "a", Sender: "b", Type: 1, Content: "bye"},
+	}
+}
+
+func TestEncoderJSON(t *testing.T) {
+	messages := testMessages()
+	var want bytes.Buffer
+	enc := json.NewEncoder(&want)
+	enc.SetIndent("", "  ")
+	enc.Encode(messages)
+
+	// 分段写出与一次编码全部消息的结果相同
+	var got bytes.Buffer
+	e := newEncoder(&got, FormatJSON, "")
+	e.encode(messages[:1])
+	e.encode(messages[1:])
+	if err := e.close(); err != nil {
+		t.Fatal(err)
+	}
+	if got.String() != want.String() {
+		t.Errorf("json = %s\nwant %s", got.String(), want.String())
+	}
+}
+
+// TestJSONLFields JSONL 与 CSV 中的字段名是稳定的，修改需要同时更新 README 中的说明
+func TestJSONLFields(t *testing.T) {
+	var buf bytes.Buffer
+	e := newEncoder(&buf, FormatJSONL, "")
+	e.encode(testMessages())
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("jsonl has %d lines", len(lines))
+	}
+	var record map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[1]), &record); err != nil {
+		t.Fatal(err)
+	}
+	for _, field := range []string{"seq", "time", "talker", "talkerName", "isChatRoom", "sender", "senderName", "isSelf", "type", "subType", "content", "contents"} {
+		if _, ok := record[field]; !ok {
+			t.Errorf("jsonl record has no field %s", field)
+		}
+	}
+	if !slices.Equal(csvHeader[:12], []string{"seq", "time", "talker", "talkerName", "isChatRoom", "sender", "senderName", "isSelf", "type", "subType", "content", "contents"}) {
+		t.Errorf("csv header changed: %v", csvHeader)
+	}
+}
+
+func TestEncoderCSV(t *testing.T) {
+	var buf bytes.Buffer
+	e := newEncoder(&buf, FormatCSV, "")
+	e.encode(testMessages()[:2])
+	e.encode(testMessages()[2:])
+	if err := e.close(); err != nil {
+		t.Fatal(err)
+	}
+	records, err := csv.NewReader(strings.NewReader(strings.TrimPrefix(buf.String(), "\xef\xbb\xbf"))).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 4 || !slices.Equal(records[0], csvHeader) {
+		t.Fatalf("records = %v", records)
+	}
+	if got := records[1][10]; got != "hello, \"world\"\n<b>" {
+		t.Errorf("content = %q", got)
+	}
+	if got := records[2][1] + " " + records[2][11]; got != `2024-01-02T03:05:05Z {"md5":"abc"}` {
+		t.Errorf("time and contents = %s", got)
+	}
+}
+
+func TestMonthWindows(t *testing.T) {
+	day := func(y int, m time.Month, d int) time.Time { return time.Date(y, m, d, 0, 0, 0, 0, time.Local) }
+	now := day(2024, 3, 10)
+
+	windows := monthWindows(day(2024, 1, 15), day(2024, 3, 5).Add(-time.Nanosecond), now)
+	if len(windows) != 3 || !windows[1][0].Equal(day(2024, 2, 1)) || !windows[1][1].Equal(day(2024, 3, 1).Add(-time.Nanosecond)) {
+		t.Errorf("windows = %v", windows)
+	}
+
+	// all 时间范围：微信发布之前与 now 之后各为一个窗口
+	start, end := time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(9999, 12, 31, 0, 0, 0, 0, time.UTC)
+	windows = monthWindows(start, end, now)
+	if n := len(windows); n != 2+(2024-2011)*12+3 {
+		t.Errorf("all has %d windows", n)
+	}
+	if !windows[0][0].Equal(start) || !windows[len(windows)-1][1].Equal(end) {
+		t.Errorf("windows do not cover the range: %v ~ %v", windows[0][0], windows[len(windows)-1][1])
+	}
+	for i := 1; i < len(windows); i++ {
+		if !windows[i][0].Equal(windows[i-1][1].Add(time.Nanosecond)) {
+			t.Errorf("gap between window %d and %d", i-1, i)
+		}
+	}
+
+	if windows := monthWindows(day(2000, 1, 1), day(2000, 6, 1), now); len(windows) != 1 {
+		t.Errorf("windows before wechat = %v", windows)
+	}
+}
@@ -0,0 +1,198 @@
+package export
+
+import (
+	"bytes"
+	"context"
+	"html/template"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/aspnmy/chatlog/internal/model"
+	"github.com/aspnmy/chatlog/pkg/destination"
+	"github.com/aspnmy/chatlog/pkg/throttle"
+	"github.com/aspnmy/chatlog/pkg/util/dat2img"
+)
+
+// galleryItem 相册中的一张图片或一段视频，File 为相对会话目录的路径
+type galleryItem struct {
+	File   string
+	Video  bool
+	Time   time.Time
+	Sender string
+}
+
+type galleryDay struct {
+	Date  string
+	Items []*galleryItem
+}
+
+// writeGallery 导出会话中的图片与视频原文件，默认按 年/年-月 目录存放，并生成按日期分组的 index.html
+func (s *Service) writeGallery(ctx context.Context, dest destination.Destination, names *namer, talker string, messages []*model.Message) (*exportedFile, error) {
+	dir := sanitize(talker)
+	f := &exportedFile{name: path.Join(dir, "index.html")}
+	tname := talkerName(talker, messages)
+
+	var days []*galleryDay
+	for _, m := range messages {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		var _type string
+		var keys []string
+		switch m.Type {
+		case 3:
+			_type, keys = "image", mediaKeys(m, "md5", "imgfile", "thumb")
+		case 43:
+			_type, keys = "video", mediaKeys(m, "md5", "rawmd5", "videofile", "thumb")
+		default:
+			continue
+		}
+
+		src := s.resolveMedia(_type, keys)
+		if src == "" {
+			log.Debug().Msgf("media of %s %d not found", talker, m.Seq)
+			continue
+		}
+		name, n, err := copyMedia(dest, src, func(ext string) string {
+			return names.name(galleryName, messageFields(talker, tname, m, _type, ext))
+		})
+		if err != nil {
+			log.Debug().Err(err).Msgf("copy media %s failed", src)
+			continue
+		}
+
+		date := m.Time.Format("2006-01-02")
+		if len(days) == 0 || days[len(days)-1].Date != date {
+			days = append(days, &galleryDay{Date: date})
+		}
+		sender := m.SenderName
+		if sender == "" {
+			sender = m.Sender
+		}
+		day := days[len(days)-1]
+		day.Items = append(day.Items, &galleryItem{File: relName(dir, name), Video: _type == "video", Time: m.Time, Sender: sender})
+		f.messages++
+		f.bytes += n
+	}
+	if f.messages == 0 {
+		return nil, nil
+	}
+
+	title := talker
+	if len(messages) > 0 && messages[0].TalkerName != "" {
+		title = messages[0].TalkerName
+	}
+	var buf bytes.Buffer
+	if err := galleryTemplate.Execute(&buf, map[string]interface{}{
+		"Title": title,
+		"Count": f.messages,
+		"Days":  days,
+	}); err != nil {
+		return nil, err
+	}
+	w, err := dest.Create(f.name)
+	if err != nil {
+		return nil, err
+	}
+	cw := &countWriter{w: throttle.Writer(w)}
+	if _, err := buf.WriteTo(cw); err != nil {
+		destination.Abort(w)
+		return nil, err
+	}
+	f.bytes += cw.n
+	return f, w.Close()
+}
+
+// mediaKeys 按优先级返回消息中的媒体索引，原图/原视频优先，缩略图最后
+func mediaKeys(m *model.Message, names ...string) []string {
+	keys := make([]string, 0, len(names))
+	for _, name := range names {
+		if v, ok := m.Contents[name].(string); ok && v != "" {
+			keys = append(keys, v)
+		}
+	}
+	return keys
+}
+
+// resolveMedia 返回第一个存在的媒体文件的绝对路径，规则与 HTTP 服务的 /image、/video 相同
+func (s *Service) resolveMedia(_type string, keys []string) string {
+	return s.db.ResolveMedia(_type, keys)
+}
+
+// copyMedia 将媒体文件复制到 name 返回的路径，.dat 图片解密后按实际格式保存，
+// name 的参数为不含点的扩展名，返回目标目录中的相对路径
+func copyMedia(dest destination.Destination, src string, name func(ext string) string) (string, int64, error) {
+	var r io.Reader
+	ext := strings.ToLower(filepath.Ext(src))
+	if ext == ".dat" {
+		data, imgExt, err := readMedia(src)
+		if err != nil {
+			return "", 0, err
+		}
+		r, ext = bytes.NewReader(data), "."+imgExt
+	} else {
+		file, err := os.Open(src)
+		if err != nil {
+			return "", 0, err
+		}
+		defer file.Close()
+		r = file
+	}
+
+	file := name(strings.TrimPrefix(ext, "."))
+	w, err := dest.Create(file)
+	if err != nil {
+		return "", 0, err
+	}
+	n, err := io.Copy(throttle.Writer(w), r)
+	if err != nil {
+		destination.Abort(w)
+		return "", n, err
+	}
+	return file, n, w.Close()
+}
+
+// readMedia 读取媒体文件，.dat 图片解密后返回实际格式，返回的扩展名不含点
+func readMedia(src string) ([]byte, string, error) {
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return nil, "", err
+	}
+	ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(src), "."))
+	if ext == "dat" {
+		return dat2img.Dat2Image(data)
+	}
+	return data, ext, nil
+}
+
+var galleryTemplate = template.Must(template.New("gallery").Parse(`<!DOCTYPE html>
+<html lang="zh-CN">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}}</title>
+<style>
+body { font-family: -apple-system, "PingFang SC", "Microsoft YaHei", sans-serif; margin: 0 auto; max-width: 1200px; padding: 16px; background: #f5f5f5; color: #333; }
+h1 { font-size: 20px; }
+h2 { font-size: 15px; margin: 24px 0 8px; color: #666; }
+.grid { display: grid; grid-template-columns: repeat(auto-fill, minmax(160px, 1fr)); gap: 8px; }
+.item { position: relative; aspect-ratio: 1; overflow: hidden; background: #ddd; border-radius: 4px; }
+.item img, .item video { width: 100%; height: 100%; object-fit: cover; display: block; }
+.item span { position: absolute; left: 0; right: 0; bottom: 0; padding: 2px 6px; font-size: 12px; color: #fff; background: rgba(0, 0, 0, .4); white-space: nowrap; overflow: hidden; text-overflow: ellipsis; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}（{{.Count}}）</h1>
+{{range .Days}}<h2>{{.Date}}</h2>
+<div class="grid">
+{{range .Items}}<div class="item">{{if .Video}}<video src="{{.File}}" controls preload="metadata"></video>{{else}}<a href="{{.File}}" target="_blank"><img src="{{.File}}" loading="lazy" alt=""></a>{{end}}<span>{{.Time.Format "15:04"}} {{.Sender}}</span></div>
+{{end}}</div>
+{{end}}</body>
+</html>
+`))
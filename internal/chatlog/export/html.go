@@ -0,0 +1,389 @@
+package export
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"html/template"
+	"mime"
+	"path"
+	"sort"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/aspnmy/chatlog/internal/model"
+	"github.com/aspnmy/chatlog/internal/wechat/media"
+	"github.com/aspnmy/chatlog/pkg/datefmt"
+	"github.com/aspnmy/chatlog/pkg/destination"
+	"github.com/aspnmy/chatlog/pkg/throttle"
+	"github.com/aspnmy/chatlog/pkg/util/silk"
+)
+
+// htmlMonth 一个月的聊天记录页面
+type htmlMonth struct {
+	Month    string // 2006-01
+	File     string // 相对会话目录的页面文件名
+	Messages []*htmlMessage
+}
+
+// htmlMessage 页面中的一条消息，Media 为相对会话目录的路径或内嵌的 data URI
+type htmlMessage struct {
+	Seq       int64
+	Time      time.Time
+	Date      string // 与上一条消息不在同一天时为日期，用于显示日期分隔
+	Sender    string
+	Self      bool
+	System    bool
+	Kind      string // image、video、voice、sticker、file、link，其他消息为空
+	Text      string
+	URL       string
+	Media     template.URL
+	Translate string
+	Quote     *htmlQuote // 引用回复中被引用的消息
+
+	msg *model.Message
+}
+
+// htmlQuote 引用回复中被引用的消息，Link 指向原消息所在月份页面中的位置，原消息不存在时为空
+type htmlQuote struct {
+	Sender  string
+	Time    string
+	Text    string
+	Link    string
+	Missing bool
+}
+
+func newHTMLQuote(q *model.QuoteBody) *htmlQuote {
+	hq := &htmlQuote{Sender: cmp.Or(q.SenderName, q.Sender), Missing: q.Missing}
+	if !q.Time.IsZero() {
+		hq.Time = q.Time.Format("2006-01-02 15:04")
+	}
+	if q.Body != nil {
+		hq.Text = q.Body.Summary()
+	}
+	if q.Seq != 0 {
+		hq.Link = fmt.Sprintf("%s.html#m%d", q.Time.Format("2006-01"), q.Seq)
+	}
+	return hq
+}
+
+// writeHTML 导出会话为可以直接用浏览器打开的 HTML 归档，按月分页：
+//
+//	<会话>/index.html          会话索引，链接到每个月的页面
+//	<会话>/2006-01.html        一个月的消息，以聊天气泡按时间排列
+//	<会话>/assets/2006-01/...  图片、视频、语音（mp3）、动画表情与文件，inline 为 true 时图片、动画表情与语音直接内嵌到页面中
+//
+// 图片、视频与文件需要指定数据目录，语音从数据库中读取，动画表情见 database.Service.Sticker，offline 为 true 时不下载
+func (s *Service) writeHTML(ctx context.Context, dest destination.Destination, names *namer, talker string, messages []*model.Message, inline, offline bool, mp3 silk.Options) (*exportedFile, error) {
+	dir := sanitize(talker)
+	f := &exportedFile{name: path.Join(dir, "index.html"), messages: len(messages)}
+	tname := talkerName(talker, messages)
+	title := talker
+	if messages[0].TalkerName != "" {
+		title = messages[0].TalkerName
+	}
+
+	months := htmlMonths(messages, s.ctx.Dates)
+	for _, month := range months {
+		for _, hm := range month.Messages {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			if hm.Kind == "" || hm.Kind == "link" {
+				continue
+			}
+			f.bytes += s.htmlMedia(ctx, dest, names, dir, talker, tname, hm, inline, offline, mp3)
+		}
+	}
+
+	for i, month := range months {
+		var prev, next *htmlMonth
+		if i > 0 {
+			prev = months[i-1]
+		}
+		if i < len(months)-1 {
+			next = months[i+1]
+		}
+		n, err := writeTemplate(dest, path.Join(dir, month.File), "month", map[string]interface{}{
+			"Title":    title,
+			"ChatRoom": messages[0].IsChatRoom,
+			"Month":    month,
+			"Prev":     prev,
+			"Next":     next,
+		})
+		f.bytes += n
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	n, err := writeTemplate(dest, f.name, "index", map[string]interface{}{
+		"Title":  title,
+		"Talker": talker,
+		"Count":  len(messages),
+		"Months": months,
+	})
+	f.bytes += n
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// htmlMonths 按月份归组消息，时间异常的消息可能乱序，按月份排序而不是按相邻消息切分
+func htmlMonths(messages []*model.Message, dates datefmt.Style) []*htmlMonth {
+	byMonth := make(map[string]*htmlMonth)
+	for _, m := range messages {
+		key := m.Time.Format("2006-01")
+		month, ok := byMonth[key]
+		if !ok {
+			month = &htmlMonth{Month: key, File: key + ".html"}
+			byMonth[key] = month
+		}
+		month.Messages = append(month.Messages, newHTMLMessage(m))
+	}
+	months := make([]*htmlMonth, 0, len(byMonth))
+	for _, month := range byMonth {
+		markDates(month.Messages, dates)
+		months = append(months, month)
+	}
+	sort.Slice(months, func(i, j int) bool {
+		return months[i].Month < months[j].Month
+	})
+	return months
+}
+
+// markDates 为与上一条消息不在同一天的消息设置按 dates 显示的日期，页面中显示为日期分隔
+func markDates(messages []*htmlMessage, dates datefmt.Style) {
+	lastDate := ""
+	for _, hm := range messages {
+		if date := hm.Time.Format("2006-01-02"); date != lastDate {
+			hm.Date = dates.Date(hm.Time)
+			lastDate = date
+		}
+	}
+}
+
+func newHTMLMessage(m *model.Message) *htmlMessage {
+	sender := m.SenderName
+	if sender == "" {
+		sender = m.Sender
+	}
+	hm := &htmlMessage{
+		Seq:       m.Seq,
+		Time:      m.Time,
+		Sender:    sender,
+		Self:      m.IsSelf,
+		System:    m.Type == 10000,
+		Translate: m.Translation,
+		msg:       m,
+	}
+	title, _ := m.Contents["title"].(string)
+	switch {
+	case m.Type == 3:
+		hm.Kind, hm.Text = "image", "[图片]"
+	case m.Type == 43:
+		hm.Kind, hm.Text = "video", "[视频]"
+	case m.Type == 34:
+		hm.Kind, hm.Text = "voice", "[语音]"
+	case m.Type == 47:
+		hm.Kind, hm.Text = "sticker", "[动画表情]"
+	case m.Type == 49 && m.SubType == 6:
+		hm.Kind, hm.Text = "file", title
+	case m.Type == 49 && m.SubType == 5:
+		hm.Kind, hm.Text = "link", title
+		hm.URL, _ = m.Contents["url"].(string)
+	case m.Body != nil && m.Body.Quote != nil:
+		hm.Text, hm.Quote = m.Content, newHTMLQuote(m.Body.Quote)
+	default:
+		hm.Text = m.PlainTextContent()
+	}
+	return hm
+}
+
+// htmlMedia 导出消息中的多媒体文件并设置 hm.Media，返回写入的字节数，找不到文件时不设置
+// 无法转码为 mp3 的语音保存原始数据，作为文件提供下载；下载不到的动画表情显示为 [动画表情]
+func (s *Service) htmlMedia(ctx context.Context, dest destination.Destination, names *namer, dir, talker, tname string, hm *htmlMessage, inline, offline bool, mp3 silk.Options) int64 {
+	m, kind := hm.msg, hm.Kind
+	var data []byte
+	var ext string
+	switch kind {
+	case "voice":
+		key, _ := m.Contents["voice"].(string)
+		if key == "" {
+			return 0
+		}
+		voice, err := s.db.GetMedia("voice", key)
+		if err != nil {
+			log.Debug().Err(err).Msgf("voice of %s %d not found", talker, m.Seq)
+			return 0
+		}
+		data, ext, err = media.ConvertVoiceWithOptions(voice.Data, media.VoiceMP3, mp3)
+		if err != nil {
+			log.Debug().Err(err).Msgf("convert voice %s failed", key)
+			data, ext = voice.Data, media.VoiceExt(voice.Data)
+		}
+		if ext != media.VoiceMP3 {
+			hm.Kind, hm.Text = "file", "[语音] "+key+"."+ext
+		}
+	default:
+		var keys []string
+		src := ""
+		switch kind {
+		case "sticker":
+			var err error
+			if src, err = s.db.Sticker(ctx, m, offline); err != nil {
+				log.Debug().Err(err).Msgf("sticker of %s %d not found", talker, m.Seq)
+				return 0
+			}
+		case "image":
+			keys = mediaKeys(m, "md5", "imgfile", "thumb")
+		case "video":
+			keys = mediaKeys(m, "md5", "rawmd5", "videofile", "thumb")
+		case "file":
+			keys = mediaKeys(m, "md5")
+		}
+		if src == "" {
+			src = s.resolveMedia(kind, keys)
+		}
+		if src == "" {
+			return 0
+		}
+		if inline && (kind == "image" || kind == "sticker") {
+			var err error
+			if data, ext, err = readMedia(src); err != nil {
+				log.Debug().Err(err).Msgf("read media %s failed", src)
+				return 0
+			}
+			break
+		}
+		name, n, err := copyMedia(dest, src, func(ext string) string {
+			return names.name(htmlName, messageFields(talker, tname, m, kind, ext))
+		})
+		if err != nil {
+			log.Debug().Err(err).Msgf("copy media %s failed", src)
+			return 0
+		}
+		hm.Media = template.URL(relName(dir, name))
+		return n
+	}
+
+	if inline && hm.Kind != "file" {
+		hm.Media = dataURI(data, ext)
+		return 0
+	}
+	name := names.name(htmlName, messageFields(talker, tname, m, kind, ext))
+	n, err := writeFile(dest, name, data)
+	if err != nil {
+		log.Debug().Err(err).Msgf("write media %s failed", name)
+		return 0
+	}
+	hm.Media = template.URL(relName(dir, name))
+	return n
+}
+
+// dataURI 返回内嵌到页面中的 data URI
+func dataURI(data []byte, ext string) template.URL {
+	typ := mime.TypeByExtension("." + ext)
+	if ext == media.VoiceMP3 {
+		typ = "audio/mpeg"
+	}
+	if typ == "" {
+		typ = "application/octet-stream"
+	}
+	return template.URL("data:" + typ + ";base64," + base64.StdEncoding.EncodeToString(data))
+}
+
+func writeTemplate(dest destination.Destination, name string, tmpl string, data interface{}) (int64, error) {
+	var buf bytes.Buffer
+	if err := htmlTemplate.ExecuteTemplate(&buf, tmpl, data); err != nil {
+		return 0, err
+	}
+	w, err := dest.Create(name)
+	if err != nil {
+		return 0, err
+	}
+	cw := &countWriter{w: throttle.Writer(w)}
+	if _, err := buf.WriteTo(cw); err != nil {
+		destination.Abort(w)
+		return cw.n, err
+	}
+	return cw.n, w.Close()
+}
+
+var htmlTemplate = template.Must(template.New("html").Parse(`{{define "style"}}<style>
+body { font-family: -apple-system, "PingFang SC", "Microsoft YaHei", sans-serif; margin: 0 auto; max-width: 800px; padding: 16px; background: #ededed; color: #333; }
+h1 { font-size: 20px; }
+a { color: #576b95; }
+nav { display: flex; justify-content: space-between; margin: 12px 0; font-size: 14px; }
+.months { list-style: none; padding: 0; }
+.months li { padding: 6px 0; border-bottom: 1px solid #ddd; }
+.months span { color: #999; margin-left: 8px; }
+.date { text-align: center; margin: 16px 0 8px; font-size: 12px; color: #999; }
+.system { text-align: center; margin: 8px 0; font-size: 12px; color: #999; }
+.msg { display: flex; flex-direction: column; align-items: flex-start; margin: 8px 0; }
+.msg.self { align-items: flex-end; }
+.meta { font-size: 12px; color: #999; margin: 0 4px 2px; }
+.bubble { max-width: 70%; padding: 8px 12px; border-radius: 6px; background: #fff; white-space: pre-wrap; word-break: break-word; }
+.self .bubble { background: #95ec69; }
+.bubble img, .bubble video { max-width: 100%; max-height: 360px; display: block; border-radius: 4px; }
+.bubble audio { display: block; }
+.bubble img.sticker { max-width: 160px; max-height: 160px; }
+.translate { margin-top: 6px; padding-top: 6px; border-top: 1px solid rgba(0, 0, 0, .1); color: #666; }
+.quote { margin: 0 0 6px; padding: 4px 8px; border-left: 3px solid rgba(0, 0, 0, .2); background: rgba(0, 0, 0, .05); font-size: 13px; color: #666; }
+.quote a { color: inherit; }
+.quote.missing { font-style: italic; }
+</style>{{end}}
+
+{{define "index"}}<!DOCTYPE html>
+<html lang="zh-CN">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}}</title>
+{{template "style"}}
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<p>{{.Talker}}，共 {{.Count}} 条消息</p>
+<ul class="months">
+{{range .Months}}<li><a href="{{.File}}">{{.Month}}</a><span>{{len .Messages}} 条</span></li>
+{{end}}</ul>
+</body>
+</html>
+{{end}}
+
+{{define "nav"}}<nav><span>{{with .Prev}}<a href="{{.File}}">&larr; {{.Month}}</a>{{end}}</span><a href="index.html">目录</a><span>{{with .Next}}<a href="{{.File}}">{{.Month}} &rarr;</a>{{end}}</span></nav>{{end}}
+
+{{define "month"}}<!DOCTYPE html>
+<html lang="zh-CN">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}} {{.Month.Month}}</title>
+{{template "style"}}
+</head>
+<body>
+<h1>{{.Title}} {{.Month.Month}}</h1>
+{{template "nav" .}}
+{{range .Month.Messages}}{{if .Date}}<div class="date">{{.Date}}</div>
+{{end}}{{if .System}}<div class="system">{{.Text}}</div>
+{{else}}<div class="msg{{if .Self}} self{{end}}" id="m{{.Seq}}"><div class="meta">{{if not .Self}}{{if $.ChatRoom}}{{.Sender}} {{end}}{{end}}{{.Time.Format "15:04:05"}}</div><div class="bubble">
+{{- with .Quote}}<blockquote class="quote{{if .Missing}} missing{{end}}">{{if .Link}}<a href="{{.Link}}">{{.Sender}} {{.Time}}</a>{{else}}{{.Sender}} {{.Time}}{{end}}
+{{.Text}}{{if .Missing}}（原消息已不存在）{{end}}</blockquote>{{end}}
+{{- if and (eq .Kind "image") .Media}}<a href="{{.Media}}" target="_blank"><img src="{{.Media}}" loading="lazy" alt="图片"></a>
+{{- else if and (eq .Kind "sticker") .Media}}<img class="sticker" src="{{.Media}}" loading="lazy" alt="动画表情">
+{{- else if and (eq .Kind "video") .Media}}<video src="{{.Media}}" controls preload="metadata"></video>
+{{- else if and (eq .Kind "voice") .Media}}<audio src="{{.Media}}" controls preload="none"></audio>
+{{- else if and (eq .Kind "file") .Media}}[文件] <a href="{{.Media}}" download>{{.Text}}</a>
+{{- else if eq .Kind "file"}}[文件] {{.Text}}
+{{- else if and (eq .Kind "link") .URL}}<a href="{{.URL}}" target="_blank" rel="noopener noreferrer">{{or .Text .URL}}</a>
+{{- else}}{{.Text}}{{end}}
+{{- if .Translate}}<div class="translate">{{.Translate}}</div>{{end}}</div></div>
+{{end}}{{end}}{{template "nav" .}}
+</body>
+</html>
+{{end}}`))
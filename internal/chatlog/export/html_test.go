@@ -0,0 +1,66 @@
+package export
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aspnmy/chatlog/internal/model"
+	"github.com/aspnmy/chatlog/pkg/datefmt"
+)
+
+func TestHTMLMonths(t *testing.T) {
+	at := func(month time.Month, day, hour int) time.Time {
+		return time.Date(2024, month, day, hour, 0, 0, 0, time.Local)
+	}
+	messages := []*model.Message{
+		{Type: 1, Content: "a", Time: at(1, 31, 9)},
+		{Type: 1, Content: "b", Time: at(2, 1, 9)},
+		{Type: 1, Content: "c", Time: at(2, 1, 10)},
+		{Type: 1, Content: "d", Time: at(1, 31, 11)}, // 时间异常导致乱序的消息归入所在月份
+		{Type: 1, Content: "e", Time: at(2, 3, 8)},
+	}
+	months := htmlMonths(messages, datefmt.Default)
+	if len(months) != 2 || months[0].Month != "2024-01" || months[1].File != "2024-02.html" {
+		t.Fatalf("months = %+v", months)
+	}
+	if n := len(months[0].Messages); n != 2 {
+		t.Errorf("2024-01 has %d messages, want 2", n)
+	}
+	var dates []string
+	for _, hm := range months[1].Messages {
+		dates = append(dates, hm.Date)
+	}
+	if got := strings.Join(dates, ","); got != "2024-02-01,,2024-02-03" {
+		t.Errorf("dates = %s", got)
+	}
+}
+
+func TestHTMLTemplate(t *testing.T) {
+	m := &model.Message{Type: 1, Content: "<script>alert(1)</script>", Sender: "bob", Time: time.Date(2024, 1, 2, 3, 4, 5, 0, time.Local)}
+	link := &model.Message{Type: 49, SubType: 5, Seq: 7, Time: m.Time, IsSelf: true,
+		Contents: map[string]interface{}{"title": "文章", "url": "javascript:alert(1)"}}
+	reply := &model.Message{Type: 49, SubType: 57, Time: m.Time, Content: "收到", Body: &model.Body{Kind: model.KindQuote, Text: "收到",
+		Quote: &model.QuoteBody{Seq: 7, SenderName: "bob", Time: m.Time, Body: &model.Body{Kind: model.KindImage}}}}
+	months := htmlMonths([]*model.Message{m, link, reply}, datefmt.Default)
+
+	var buf bytes.Buffer
+	if err := htmlTemplate.ExecuteTemplate(&buf, "month", map[string]interface{}{
+		"Title":    "群",
+		"ChatRoom": true,
+		"Month":    months[0],
+	}); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, want := range []string{"&lt;script&gt;", `<div class="msg self" id="m7">`, "bob 03:04:05", "#ZgotmplZ",
+		`<a href="2024-01.html#m7">bob 2024-01-02 03:04</a>`, "[图片]</blockquote>收到"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("page does not contain %q", want)
+		}
+	}
+	if strings.Contains(out, "<script>") {
+		t.Error("message content is not escaped")
+	}
+}
@@ -0,0 +1,224 @@
+package export
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/aspnmy/chatlog/internal/model"
+)
+
+// 各导出格式的默认文件名模板，与 Options.NameTemplate 的语法相同
+const (
+	transcriptName = "{talker}.{ext}"
+	galleryName    = "{talker}/{year}/{month}/{datetime}_{msgid}.{ext}"
+	obsidianName   = obsidianAssets + "/{name}/{datetime}_{msgid}.{ext}"
+	voiceName      = "{talker}/{year}/{month}/{datetime}_{key}_{sender}.{ext}"
+	htmlName       = "{talker}/assets/{month}/{datetime}_{msgid}.{ext}"
+	attachmentName = "{talker}/{year}/{month}/{datetime}_{file}.{ext}"
+)
+
+var namePlaceholder = regexp.MustCompile(`\{[^{}]*\}`)
+
+// namePlaceholders 文件名模板中的占位符：
+//
+//	{talker}    会话 ID
+//	{name}      会话名称，没有时为会话 ID
+//	{sender}    发送人名称，没有时为发送人 ID
+//	{date}      消息日期 2006-01-02
+//	{time}      消息时间 150405
+//	{datetime}  20060102_150405
+//	{year}      2006
+//	{month}     2006-01
+//	{msgid}     消息序号，同一会话内唯一
+//	{key}       媒体索引（如语音 ID），没有时同 {msgid}
+//	{file}      文件消息的原文件名（不含扩展名），其他消息同 {msgid}
+//	{type}      image、video、voice、sticker、file，聊天记录文件为 chat
+//	{ext}       扩展名（不含点）
+//
+// 文字记录文件的消息字段取自会话中第一条导出的消息
+var namePlaceholders = []string{"talker", "name", "sender", "date", "time", "datetime", "year", "month", "msgid", "key", "file", "type", "ext"}
+
+// ValidateNameTemplate 检查文件名模板，模板为目标目录中的相对路径，使用 / 分隔目录，
+// 只能使用已知的占位符且必须包含 {ext}，不能是绝对路径或包含 ..
+func ValidateNameTemplate(tmpl string) error {
+	if tmpl == "" {
+		return nil
+	}
+	if strings.HasPrefix(tmpl, "/") || strings.Contains(tmpl, "\\") {
+		return fmt.Errorf("name template must be a relative path separated by /")
+	}
+	for _, seg := range strings.Split(tmpl, "/") {
+		if seg == ".." {
+			return fmt.Errorf("name template must not contain ..")
+		}
+	}
+	if strings.Count(tmpl, "{") != strings.Count(tmpl, "}") {
+		return fmt.Errorf("name template has unbalanced braces")
+	}
+	hasExt := false
+	for _, p := range namePlaceholder.FindAllString(tmpl, -1) {
+		key := p[1 : len(p)-1]
+		if !slices.Contains(namePlaceholders, key) {
+			return fmt.Errorf("unknown placeholder %s in name template, available: {%s}", p, strings.Join(namePlaceholders, "}, {"))
+		}
+		hasExt = hasExt || key == "ext"
+	}
+	if !hasExt {
+		return fmt.Errorf("name template must contain {ext}")
+	}
+	return nil
+}
+
+// namer 按模板生成导出文件名，同一次导出中重名的文件在扩展名前加 _1、_2 等序号
+// 一次导出中的所有会话共用一个 namer，并发导出时同样安全
+type namer struct {
+	tmpl string // 用户指定的模板，为空时使用各格式的默认模板
+
+	mu   sync.Mutex
+	used map[string]struct{}
+}
+
+func newNamer(tmpl string) *namer {
+	return &namer{tmpl: tmpl, used: make(map[string]struct{})}
+}
+
+// nameFields 生成文件名的字段
+type nameFields struct {
+	Talker string
+	Name   string
+	Sender string
+	Time   time.Time
+	MsgID  int64
+	Key    string
+	File   string
+	Type   string
+	Ext    string
+}
+
+// messageFields 返回消息的文件名字段，name 为会话名称
+func messageFields(talker, name string, m *model.Message, _type, ext string) nameFields {
+	sender := m.SenderName
+	if sender == "" {
+		sender = m.Sender
+	}
+	var file string
+	if m.Type == 49 && m.SubType == 6 {
+		title, _ := m.Contents["title"].(string)
+		file = strings.TrimSuffix(title, path.Ext(title))
+	}
+	return nameFields{
+		Talker: talker,
+		Name:   name,
+		Sender: sender,
+		Time:   m.Time,
+		MsgID:  m.Seq,
+		File:   file,
+		Type:   _type,
+		Ext:    ext,
+	}
+}
+
+// name 按用户模板（未指定时为 def）生成文件名，返回目标目录中的相对路径，不与本次导出的其他文件重名
+func (n *namer) name(def string, f nameFields) string {
+	return n.unique(renderName(n.template(def), f))
+}
+
+// template 返回用户指定的模板，未指定时返回 def
+func (n *namer) template(def string) string {
+	if n.tmpl == "" {
+		return def
+	}
+	return n.tmpl
+}
+
+// unique 返回本次导出中未使用的文件名，比较时不区分大小写，避免在 Windows 与 macOS 上互相覆盖
+func (n *namer) unique(name string) string {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	ext := path.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	for i := 1; ; i++ {
+		key := strings.ToLower(name)
+		if _, ok := n.used[key]; !ok {
+			n.used[key] = struct{}{}
+			return name
+		}
+		name = base + "_" + strconv.Itoa(i) + ext
+	}
+}
+
+// renderName 替换模板中的占位符，每个值都去掉路径分隔符等文件名中不允许的字符
+func renderName(tmpl string, f nameFields) string {
+	key := f.Key
+	if key == "" {
+		key = strconv.FormatInt(f.MsgID, 10)
+	}
+	name := f.Name
+	if name == "" {
+		name = f.Talker
+	}
+	file := f.File
+	if file == "" {
+		file = strconv.FormatInt(f.MsgID, 10)
+	}
+	values := map[string]string{
+		"talker":   f.Talker,
+		"name":     name,
+		"sender":   f.Sender,
+		"date":     f.Time.Format("2006-01-02"),
+		"time":     f.Time.Format("150405"),
+		"datetime": f.Time.Format("20060102_150405"),
+		"year":     f.Time.Format("2006"),
+		"month":    f.Time.Format("2006-01"),
+		"msgid":    strconv.FormatInt(f.MsgID, 10),
+		"key":      key,
+		"file":     file,
+		"type":     f.Type,
+		"ext":      f.Ext,
+	}
+	out := namePlaceholder.ReplaceAllStringFunc(tmpl, func(p string) string {
+		return nameValue(values[p[1:len(p)-1]])
+	})
+	return strings.TrimPrefix(path.Clean("/"+out), "/")
+}
+
+// nameValue 将占位符的值转为可以作为单个路径片段的字符串
+func nameValue(v string) string {
+	v = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return '_'
+		}
+		return r
+	}, sanitize(v))
+	if v == "." || v == ".." {
+		return "_"
+	}
+	return v
+}
+
+// relName 返回 name 相对 dir 的路径，两者都是目标目录中以 / 分隔的相对路径
+func relName(dir, name string) string {
+	if strings.HasPrefix(name, dir+"/") {
+		return name[len(dir)+1:]
+	}
+	return strings.Repeat("../", strings.Count(dir, "/")+1) + name
+}
+
+// talkerName 返回会话名称对应的文件名，用于 {name} 占位符与 Obsidian 的笔记名，没有名称时为会话 ID
+func talkerName(talker string, messages []*model.Message) string {
+	title := talker
+	if len(messages) > 0 && messages[0].TalkerName != "" {
+		title = messages[0].TalkerName
+	}
+	if name := noteName(title); name != "" {
+		return name
+	}
+	return sanitize(talker)
+}
@@ -0,0 +1,60 @@
+package export
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRenderName(t *testing.T) {
+	f := nameFields{
+		Talker: "123@chatroom",
+		Name:   "家庭群",
+		Sender: "a/b:c",
+		Time:   time.Date(2024, 5, 6, 7, 8, 9, 0, time.Local),
+		MsgID:  42,
+		Type:   "image",
+		Ext:    "jpg",
+	}
+	for tmpl, want := range map[string]string{
+		galleryName:                            "123@chatroom/2024/2024-05/20240506_070809_42.jpg",
+		voiceName:                              "123@chatroom/2024/2024-05/20240506_070809_42_a_b_c.jpg",
+		"{talker}/{date}/{msgid}_{type}.{ext}": "123@chatroom/2024-05-06/42_image.jpg",
+		"{name}//{sender}.{ext}":               "家庭群/a_b_c.jpg",
+	} {
+		if got := renderName(tmpl, f); got != want {
+			t.Errorf("renderName(%q) = %q, want %q", tmpl, got, want)
+		}
+	}
+}
+
+func TestNamerUnique(t *testing.T) {
+	n := newNamer("{talker}/{datetime}.{ext}")
+	f := nameFields{Talker: "x", Time: time.Date(2024, 1, 1, 0, 0, 0, 0, time.Local), Ext: "jpg"}
+	want := []string{"x/20240101_000000.jpg", "x/20240101_000000_1.jpg", "x/20240101_000000_2.jpg"}
+	for _, w := range want {
+		if got := n.name(galleryName, f); got != w {
+			t.Errorf("name = %q, want %q", got, w)
+		}
+	}
+	// 只有大小写不同的文件名在 Windows 与 macOS 上是同一个文件
+	f.Talker = "X"
+	if got := n.name(galleryName, f); got != "X/20240101_000000_3.jpg" {
+		t.Errorf("name = %q", got)
+	}
+}
+
+func TestValidateNameTemplate(t *testing.T) {
+	for tmpl, ok := range map[string]bool{
+		"":                                     true,
+		"{talker}/{date}/{msgid}_{type}.{ext}": true,
+		"{talker}/{msgid}":                     false,
+		"../{msgid}.{ext}":                     false,
+		"/tmp/{msgid}.{ext}":                   false,
+		"{talker}/{unknown}.{ext}":             false,
+		"{talker/{msgid}.{ext}":                false,
+	} {
+		if err := ValidateNameTemplate(tmpl); (err == nil) != ok {
+			t.Errorf("ValidateNameTemplate(%q) = %v", tmpl, err)
+		}
+	}
+}
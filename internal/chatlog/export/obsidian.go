@@ -0,0 +1,251 @@
+package export
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/aspnmy/chatlog/internal/model"
+	"github.com/aspnmy/chatlog/pkg/destination"
+	"github.com/aspnmy/chatlog/pkg/lunar"
+	"github.com/aspnmy/chatlog/pkg/throttle"
+)
+
+// obsidianAssets 附件目录，每个会话一个子目录
+const obsidianAssets = "assets"
+
+// writeObsidian 按 Obsidian 笔记库的约定导出会话，monthly 为 false 时每天一篇笔记，为 true 时每月一篇：
+//
+//	<会话>.md                    会话索引，链接到每一篇笔记
+//	<会话>/2006-01-02.md         每天（或每月 2006-01.md）一篇笔记，带 frontmatter，发送人为 [[双链]]
+//	assets/<会话>/...            图片、视频与文件附件，指定了数据目录时才导出；动画表情从缓存复制，offline 为 false 时缓存中没有的会下载
+//
+// 同样的目录结构也可以直接作为 Logseq 的页面导入
+func (s *Service) writeObsidian(ctx context.Context, dest destination.Destination, names *namer, talker string, messages []*model.Message, monthly, offline bool) (*exportedFile, error) {
+	title := talker
+	if messages[0].TalkerName != "" {
+		title = messages[0].TalkerName
+	}
+	note := talkerName(talker, messages)
+	f := &exportedFile{name: note + ".md", messages: len(messages)}
+	layout := "2006-01-02"
+	if monthly {
+		layout = "2006-01"
+	}
+
+	// 时间异常的消息可能乱序，按日期归组而不是按相邻消息切分
+	periods := make(map[string][]*model.Message)
+	for _, m := range messages {
+		key := m.Time.Format(layout)
+		periods[key] = append(periods[key], m)
+	}
+	keys := make([]string, 0, len(periods))
+	for key := range periods {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		ms := periods[key]
+		var sb strings.Builder
+		fmt.Fprintf(&sb, "---\ntitle: %s\n", yamlString(title+" "+key))
+		if monthly {
+			first, last := dateRange(ms)
+			fmt.Fprintf(&sb, "month: %s\nstart: %s\nend: %s\n", key, first, last)
+		} else {
+			fmt.Fprintf(&sb, "date: %s\n", key)
+			if note := lunar.Annotate(ms[0].Time); s.ctx.Dates.Lunar && note != "" {
+				fmt.Fprintf(&sb, "lunar: %s\n", yamlString(note))
+			}
+		}
+		fmt.Fprintf(&sb, "talker: %s\nparticipants:\n", yamlString(talker))
+		for _, p := range participants(ms) {
+			fmt.Fprintf(&sb, "  - %s\n", yamlString("[["+p+"]]"))
+		}
+		fmt.Fprintf(&sb, "messages: %d\ntags:\n  - wechat\n---\n\n", len(ms))
+		heading := key
+		if !monthly {
+			heading = s.ctx.Dates.Date(ms[0].Time)
+		}
+		fmt.Fprintf(&sb, "# [[%s]] %s\n", note, heading)
+		lastDate := ""
+		if !monthly {
+			sb.WriteString("\n")
+		}
+		for _, m := range ms {
+			// 每月的笔记中按日期分节
+			if date := m.Time.Format("2006-01-02"); monthly && date != lastDate {
+				fmt.Fprintf(&sb, "\n## %s\n\n", s.ctx.Dates.Date(m.Time))
+				lastDate = date
+			}
+			line, n := s.obsidianMessage(ctx, dest, names, talker, note, m, offline)
+			sb.WriteString(line)
+			f.bytes += n
+		}
+		n, err := writeNote(dest, path.Join(note, key+".md"), sb.String())
+		f.bytes += n
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	first, last := dateRange(messages)
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "---\ntitle: %s\ntalker: %s\nchatroom: %t\nfirst: %s\nlast: %s\nmessages: %d\ntags:\n  - wechat\n---\n\n", yamlString(title), yamlString(talker), messages[0].IsChatRoom, first, last, len(messages))
+	fmt.Fprintf(&sb, "# %s\n\n", title)
+	for _, key := range keys {
+		fmt.Fprintf(&sb, "- [[%s/%s|%s]] (%d)\n", note, key, key, len(periods[key]))
+	}
+	n, err := writeNote(dest, f.name, sb.String())
+	f.bytes += n
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// dateRange 返回消息中最早与最晚的日期，时间异常的消息可能乱序
+func dateRange(messages []*model.Message) (string, string) {
+	first, last := messages[0].Time, messages[0].Time
+	for _, m := range messages[1:] {
+		if m.Time.Before(first) {
+			first = m.Time
+		}
+		if m.Time.After(last) {
+			last = m.Time
+		}
+	}
+	return first.Format("2006-01-02"), last.Format("2006-01-02")
+}
+
+// participants 返回发送过消息的人的笔记名，按第一次发言的顺序，不包含系统消息
+func participants(messages []*model.Message) []string {
+	var names []string
+	seen := make(map[string]bool)
+	for _, m := range messages {
+		if m.Type == 10000 {
+			continue
+		}
+		name := noteName(senderName(m))
+		if name != "" && !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// senderName 返回笔记中显示的发送人，自己发送的消息为“我”
+func senderName(m *model.Message) string {
+	if m.IsSelf {
+		return "我"
+	}
+	if m.SenderName != "" {
+		return m.SenderName
+	}
+	return m.Sender
+}
+
+// obsidianMessage 将消息写为列表项，多行内容缩进到同一列表项中，同时返回复制的附件大小
+func (s *Service) obsidianMessage(ctx context.Context, dest destination.Destination, names *namer, talker, note string, m *model.Message, offline bool) (string, int64) {
+	var content string
+	var n int64
+	switch {
+	case m.Type == 3:
+		content, n = s.obsidianMedia(dest, names, talker, note, m, "image", "[图片]", mediaKeys(m, "md5", "imgfile", "thumb"))
+	case m.Type == 43:
+		content, n = s.obsidianMedia(dest, names, talker, note, m, "video", "[视频]", mediaKeys(m, "md5", "rawmd5", "videofile", "thumb"))
+	case m.Type == 47:
+		content, n = s.obsidianSticker(ctx, dest, names, talker, note, m, offline)
+	case m.Type == 49 && m.SubType == 6:
+		content, n = s.obsidianMedia(dest, names, talker, note, m, "file", textContent(m), mediaKeys(m, "md5"))
+	default:
+		content = textContent(m)
+	}
+	content = strings.ReplaceAll(strings.TrimRight(content, "\n"), "\n", "\n  ")
+	return fmt.Sprintf("- %s [[%s]]: %s\n", m.Time.Format("15:04:05"), noteName(senderName(m)), content), n
+}
+
+// obsidianMedia 复制媒体文件到附件目录并返回链接，图片与视频嵌入显示，文件为以文件名显示的双链，
+// 未指定数据目录或找不到文件时返回 placeholder
+func (s *Service) obsidianMedia(dest destination.Destination, names *namer, talker, note string, m *model.Message, _type string, placeholder string, keys []string) (string, int64) {
+	if s.ctx.DataDir == "" {
+		return placeholder, 0
+	}
+	src := s.resolveMedia(_type, keys)
+	if src == "" {
+		return placeholder, 0
+	}
+	name, n, err := copyMedia(dest, src, func(ext string) string {
+		return names.name(obsidianName, messageFields(talker, note, m, _type, ext))
+	})
+	if err != nil {
+		log.Debug().Err(err).Msgf("copy media %s failed", src)
+		return placeholder, 0
+	}
+	if _type == "file" {
+		title, _ := m.Contents["title"].(string)
+		if title = noteName(title); title == "" {
+			title = path.Base(name)
+		}
+		return fmt.Sprintf("[文件] [[%s|%s]]", name, title), n
+	}
+	return fmt.Sprintf("![[%s]]", name), n
+}
+
+// obsidianSticker 复制动画表情到附件目录并嵌入显示，下载不到时返回 [动画表情]
+func (s *Service) obsidianSticker(ctx context.Context, dest destination.Destination, names *namer, talker, note string, m *model.Message, offline bool) (string, int64) {
+	src, err := s.db.Sticker(ctx, m, offline)
+	if err != nil {
+		log.Debug().Err(err).Msgf("sticker of %s %d not found", talker, m.Seq)
+		return "[动画表情]", 0
+	}
+	name, n, err := copyMedia(dest, src, func(ext string) string {
+		return names.name(obsidianName, messageFields(talker, note, m, "sticker", ext))
+	})
+	if err != nil {
+		log.Debug().Err(err).Msgf("copy media %s failed", src)
+		return "[动画表情]", 0
+	}
+	return fmt.Sprintf("![[%s]]", name), n
+}
+
+func writeNote(dest destination.Destination, name string, content string) (int64, error) {
+	w, err := dest.Create(name)
+	if err != nil {
+		return 0, err
+	}
+	cw := &countWriter{w: throttle.Writer(w)}
+	if _, err := io.WriteString(cw, content); err != nil {
+		destination.Abort(w)
+		return cw.n, err
+	}
+	return cw.n, w.Close()
+}
+
+// noteName 返回可以用作笔记名与双链的名称，去掉文件名与 [[]] 链接中不允许的字符
+func noteName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		switch r {
+		case '[', ']', '#', '^', '|':
+			return '_'
+		}
+		return r
+	}, sanitize(name))
+	return strings.TrimSpace(name)
+}
+
+// yamlString 将字符串转为 YAML 双引号字符串，JSON 字符串是合法的 YAML
+func yamlString(s string) string {
+	b, _ := json.Marshal(s)
+	return string(b)
+}
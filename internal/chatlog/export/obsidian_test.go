@@ -0,0 +1,69 @@
+package export
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aspnmy/chatlog/internal/chatlog/ctx"
+	"github.com/aspnmy/chatlog/internal/model"
+	"github.com/aspnmy/chatlog/pkg/destination"
+)
+
+func TestWriteObsidianMonthly(t *testing.T) {
+	at := func(month time.Month, day, hour int) time.Time {
+		return time.Date(2024, month, day, hour, 0, 0, 0, time.Local)
+	}
+	room := func(m *model.Message) *model.Message {
+		m.Talker, m.TalkerName, m.IsChatRoom = "123@chatroom", "家庭群", true
+		return m
+	}
+	messages := []*model.Message{
+		room(&model.Message{Type: 1, Content: "a", Sender: "wxid_a", SenderName: "Alice", Time: at(1, 5, 9)}),
+		room(&model.Message{Type: 1, Content: "b\nc", IsSelf: true, Time: at(1, 20, 9)}),
+		room(&model.Message{Type: 10000, Content: "系统消息", Time: at(1, 20, 10)}),
+		room(&model.Message{Type: 1, Content: "d", Sender: "wxid_b", SenderName: "Bob|B", Time: at(2, 1, 9)}),
+	}
+
+	dir := t.TempDir()
+	dest, err := destination.NewLocal(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &Service{ctx: &ctx.Context{}}
+	f, err := s.writeObsidian(context.Background(), dest, newNamer(""), "123@chatroom", messages, true, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.name != "家庭群.md" || f.messages != 4 {
+		t.Errorf("file = %+v", f)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "家庭群", "2024-01.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	note := string(data)
+	for _, want := range []string{
+		"month: 2024-01\nstart: 2024-01-05\nend: 2024-01-20\n",
+		"participants:\n  - \"[[Alice]]\"\n  - \"[[我]]\"\nmessages: 3\n",
+		"\n## 2024-01-20\n\n- 09:00:00 [[我]]: b\n  c\n",
+	} {
+		if !strings.Contains(note, want) {
+			t.Errorf("note does not contain %q:\n%s", want, note)
+		}
+	}
+	index, err := os.ReadFile(filepath.Join(dir, "家庭群.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(index), "- [[家庭群/2024-02|2024-02]] (1)\n") {
+		t.Errorf("index:\n%s", index)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "家庭群", "2024-02.md")); !strings.Contains(string(data), "[[Bob_B]]") {
+		t.Errorf("sender link is not escaped:\n%s", data)
+	}
+}
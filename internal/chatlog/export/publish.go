@@ -0,0 +1,122 @@
+package export
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/aspnmy/chatlog/internal/errors"
+	"github.com/aspnmy/chatlog/internal/model"
+	"github.com/aspnmy/chatlog/pkg/datefmt"
+	"github.com/aspnmy/chatlog/pkg/publish"
+	"github.com/aspnmy/chatlog/pkg/util"
+)
+
+// Publish 将会话推送为在线文档，每个会话一篇，按日期分节
+// 使用 opts 中的 Talker、Time、NormalizeTime 与 Lang，Result.Files 为文档链接
+// 在线文档接口有频率限制，会话依次推送
+func (s *Service) Publish(ctx context.Context, pub publish.Publisher, opts Options) (*Result, error) {
+	begin := time.Now()
+
+	timeRange := opts.Time
+	if timeRange == "" {
+		timeRange = "2000-01-01~" + time.Now().Format("2006-01-02")
+	}
+	start, end, ok := util.TimeRangeOf(timeRange)
+	if !ok {
+		return nil, errors.InvalidArg("time")
+	}
+
+	talkers, err := s.talkers(opts.Talker, opts.ExcludeTalkers)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Result{Dest: pub.String()}
+	for _, talker := range talkers {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+		messages, err := s.db.GetMessages(start, end, talker, "", "", 0, 0)
+		if err != nil {
+			log.Err(err).Msgf("push %s failed", talker)
+			result.Failed = append(result.Failed, talker)
+			continue
+		}
+		if len(messages) == 0 {
+			continue
+		}
+		for _, m := range messages {
+			if m.TimeAnomaly != "" {
+				result.TimeAnomalies++
+			}
+		}
+		if opts.NormalizeTime {
+			model.NormalizeTimes(messages)
+		}
+		s.translate(ctx, talker, messages, opts.Lang)
+
+		url, err := pub.Publish(ctx, document(talker, opts.Time, messages, s.ctx.Dates))
+		if err != nil {
+			log.Err(err).Msgf("push %s failed", talker)
+			result.Failed = append(result.Failed, talker)
+			continue
+		}
+		log.Info().Msgf("pushed %s: %s", talker, url)
+		result.Files = append(result.Files, url)
+		result.Messages += len(messages)
+	}
+	result.Duration = time.Since(begin)
+	return result, nil
+}
+
+// document 将会话转为文档，标题为会话名与时间范围，每天一个按 dates 显示的日期标题，每条消息一段
+func document(talker, timeRange string, messages []*model.Message, dates datefmt.Style) *publish.Document {
+	title := talker
+	if messages[0].TalkerName != "" {
+		title = messages[0].TalkerName
+	}
+	if timeRange != "" {
+		title += " " + timeRange
+	}
+
+	doc := &publish.Document{Title: title}
+	date := ""
+	for _, m := range messages {
+		if d := m.Time.Format("2006-01-02"); d != date {
+			date = d
+			doc.Blocks = append(doc.Blocks, publish.Block{Kind: publish.Heading, Text: dates.Date(m.Time)})
+		}
+		sender := m.SenderName
+		if sender == "" {
+			sender = m.Sender
+		}
+		if m.IsSelf {
+			sender = "我"
+		}
+		doc.Blocks = append(doc.Blocks, publish.Block{
+			Kind: publish.Paragraph,
+			Text: fmt.Sprintf("%s %s: %s", m.Time.Format("15:04:05"), sender, textContent(m)),
+		})
+	}
+	return doc
+}
+
+// textContent 返回消息的文本内容，图片、视频与语音在文档中无法访问本地服务，只显示类型
+func textContent(m *model.Message) string {
+	switch m.Type {
+	case 3:
+		return "[图片]"
+	case 43:
+		return "[视频]"
+	case 34:
+		return "[语音]"
+	default:
+		if m.Translation != "" {
+			return m.PlainTextContent() + "\n" + m.Translation
+		}
+		return m.PlainTextContent()
+	}
+}
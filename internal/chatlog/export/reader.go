@@ -0,0 +1,179 @@
+package export
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/aspnmy/chatlog/internal/chatlog/database"
+	"github.com/aspnmy/chatlog/internal/errors"
+	"github.com/aspnmy/chatlog/internal/model"
+	"github.com/aspnmy/chatlog/pkg/hashtag"
+)
+
+// messageReader 按月逐段读取会话的消息，导出时只在内存中保留一个月的消息，
+// 数百万条消息的会话也可以逐段写出
+type messageReader struct {
+	s       *Service
+	ctx     context.Context
+	talker  string
+	after   model.Cursor
+	opts    Options
+	windows [][2]time.Time
+
+	messages  int
+	anomalies int
+	last      model.Cursor // 最后一条消息的位置，修正时间前记录，游标使用原始的 seq
+}
+
+func (s *Service) newMessageReader(ctx context.Context, talker string, start, end time.Time, after model.Cursor, opts Options) *messageReader {
+	return &messageReader{
+		s:       s,
+		ctx:     ctx,
+		talker:  talker,
+		after:   after,
+		opts:    opts,
+		windows: monthWindows(start, end, time.Now()),
+	}
+}
+
+// next 返回下一段非空的消息，读取完所有消息后返回 nil
+func (r *messageReader) next() ([]*model.Message, error) {
+	for len(r.windows) > 0 {
+		w := r.windows[0]
+		r.windows = r.windows[1:]
+		messages, err := r.read(w[0], w[1])
+		if err != nil {
+			return nil, err
+		}
+		if len(messages) > 0 {
+			return messages, nil
+		}
+	}
+	return nil, nil
+}
+
+// all 读取全部消息，用于需要按日期或月份归组的格式
+func (r *messageReader) all() ([]*model.Message, error) {
+	var all []*model.Message
+	for {
+		messages, err := r.next()
+		if err != nil || messages == nil {
+			return all, err
+		}
+		all = append(all, messages...)
+	}
+}
+
+// read 读取时间窗口内排在 after 之后的消息，修正时间并填入译文
+func (r *messageReader) read(start, end time.Time) ([]*model.Message, error) {
+	if err := r.ctx.Err(); err != nil {
+		return nil, err
+	}
+	var keyword string
+	if r.opts.Topic != "" {
+		keyword = r.s.topics().Pattern(r.opts.Topic)
+	}
+	messages, err := r.s.db.GetMessages(start, end, r.talker, "", keyword, 0, 0)
+	if err != nil {
+		// 时间窗口内没有数据库文件
+		if errors.GetCode(err) == http.StatusNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	messages = model.MessagesAfter(messages, r.after)
+	if len(r.opts.ExcludeTalkers) > 0 {
+		messages = slices.DeleteFunc(messages, func(m *model.Message) bool {
+			return slices.Contains(r.opts.ExcludeTalkers, m.Talker)
+		})
+	}
+	if r.opts.Topic != "" {
+		topics := r.s.topics()
+		messages = slices.DeleteFunc(messages, func(m *model.Message) bool {
+			return !topics.Match(database.TopicText(m), r.opts.Topic)
+		})
+	}
+	if r.opts.Status != "" {
+		messages = database.FilterStatus(messages, r.opts.Status, nil)
+	}
+	if len(messages) == 0 {
+		return nil, nil
+	}
+	r.messages += len(messages)
+	r.last = model.CursorOf(messages[len(messages)-1])
+
+	anomalies := 0
+	for _, m := range messages {
+		if m.TimeAnomaly != "" {
+			anomalies++
+		}
+	}
+	if anomalies > 0 {
+		r.anomalies += anomalies
+		if r.opts.NormalizeTime {
+			model.NormalizeTimes(messages)
+		}
+	}
+
+	r.s.db.ResolveQuotes(messages)
+	r.s.translate(r.ctx, r.talker, messages, r.opts.Lang)
+	return messages, nil
+}
+
+// topics 返回配置的话题约定
+func (s *Service) topics() *hashtag.Matcher {
+	if s.ctx.Topics == nil {
+		return hashtag.NewMatcher(nil)
+	}
+	return s.ctx.Topics
+}
+
+// warn 读取结束后提示时间异常的消息数
+func (r *messageReader) warn() {
+	if r.anomalies > 0 {
+		log.Warn().Msgf("%s has %d messages with abnormal time", r.talker, r.anomalies)
+	}
+}
+
+// wechatEpoch 微信发布的时间，之前不会有消息
+var wechatEpoch = time.Date(2011, 1, 1, 0, 0, 0, 0, time.Local)
+
+// monthWindows 将时间范围按自然月切分为首尾相接的窗口，每个窗口包含起止时间
+// 早于微信发布与晚于 now 的部分（如 all 时间范围）不会有太多消息，各作为一个窗口
+func monthWindows(start, end, now time.Time) [][2]time.Time {
+	var windows [][2]time.Time
+	add := func(s, e time.Time) {
+		if !s.After(e) {
+			windows = append(windows, [2]time.Time{s, e})
+		}
+	}
+	lo, hi := start, end
+	if lo.Before(wechatEpoch) {
+		lo = wechatEpoch
+		if lo.After(end) {
+			lo = end.Add(time.Nanosecond)
+		}
+		add(start, lo.Add(-time.Nanosecond))
+	}
+	if hi.After(now) {
+		hi = now
+	}
+	for s := lo; !s.After(hi); {
+		next := time.Date(s.Year(), s.Month()+1, 1, 0, 0, 0, 0, s.Location())
+		e := next.Add(-time.Nanosecond)
+		if e.After(hi) {
+			e = hi
+		}
+		add(s, e)
+		s = next
+	}
+	if hi.Before(lo) {
+		hi = lo.Add(-time.Nanosecond)
+	}
+	add(hi.Add(time.Nanosecond), end)
+	return windows
+}
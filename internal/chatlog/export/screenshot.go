@@ -0,0 +1,227 @@
+package export
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"html/template"
+	"io"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/aspnmy/chatlog/internal/errors"
+	"github.com/aspnmy/chatlog/internal/model"
+	"github.com/aspnmy/chatlog/pkg/datefmt"
+	"github.com/aspnmy/chatlog/pkg/htmlshot"
+	"github.com/aspnmy/chatlog/pkg/util"
+)
+
+const (
+	// DefaultScreenshotWidth 截图的默认宽度，与手机屏幕相近
+	DefaultScreenshotWidth = 480
+	// DefaultScreenshotHeight 截图的默认高度，超出的内容会被截断
+	DefaultScreenshotHeight = 1600
+	// DefaultScreenshotLimit 截图默认最多包含的消息数
+	DefaultScreenshotLimit = 50
+)
+
+// ScreenshotOptions 会话截图的参数
+type ScreenshotOptions struct {
+	Talker         string
+	Time           string
+	Limit          int // 最多渲染的消息数，从时间范围的开头算起
+	Width          int // 视口宽度，像素
+	Height         int // 视口高度，像素
+	ExcludeTalkers []string
+}
+
+// Screenshot 将会话在时间范围内的消息按 HTML 导出的页面渲染为 PNG，用于在问题反馈中附上页面布局
+// 文字替换为占位字符，截图中不包含聊天内容，返回渲染的消息数
+func (s *Service) Screenshot(w io.Writer, opts ScreenshotOptions) (int, error) {
+	if opts.Talker == "" || strings.Contains(opts.Talker, ",") {
+		return 0, errors.InvalidArg("talker")
+	}
+	talkers, err := s.talkers(opts.Talker, opts.ExcludeTalkers)
+	if err != nil {
+		return 0, err
+	}
+	if len(talkers) == 0 {
+		return 0, errors.InvalidArg("talker")
+	}
+	timeRange := opts.Time
+	if timeRange == "" {
+		timeRange = "2000-01-01~" + time.Now().Format("2006-01-02")
+	}
+	start, end, ok := util.TimeRangeOf(timeRange)
+	if !ok {
+		return 0, errors.InvalidArg("time")
+	}
+	if opts.Limit <= 0 {
+		opts.Limit = DefaultScreenshotLimit
+	}
+
+	r := s.newMessageReader(context.Background(), talkers[0], start, end, model.Cursor{}, Options{})
+	var messages []*model.Message
+	for len(messages) < opts.Limit {
+		chunk, err := r.next()
+		if err != nil {
+			return 0, err
+		}
+		if chunk == nil {
+			break
+		}
+		messages = append(messages, chunk...)
+	}
+	if len(messages) == 0 {
+		return 0, errors.TimeRangeNotFound(start, end)
+	}
+	messages = messages[:min(len(messages), opts.Limit)]
+	return len(messages), renderScreenshot(w, messages, opts, true)
+}
+
+// RenderScreenshot 用 HTML 导出的月份页面模板将消息渲染为一页，再由本机的浏览器以 opts.Width x opts.Height 的视口渲染为 PNG
+// 不读取多媒体文件，图片、视频与语音显示为占位框
+func RenderScreenshot(w io.Writer, messages []*model.Message, opts ScreenshotOptions) error {
+	return renderScreenshot(w, messages, opts, false)
+}
+
+func renderScreenshot(w io.Writer, messages []*model.Message, opts ScreenshotOptions, mask bool) error {
+	if len(messages) == 0 {
+		return errors.InvalidArg("messages")
+	}
+	if opts.Width <= 0 {
+		opts.Width = DefaultScreenshotWidth
+	}
+	if opts.Height <= 0 {
+		opts.Height = DefaultScreenshotHeight
+	}
+	var buf bytes.Buffer
+	if err := htmlTemplate.ExecuteTemplate(&buf, "month", screenshotPage(messages, mask)); err != nil {
+		return err
+	}
+	return htmlshot.RenderPNG(w, &buf, opts.Width, opts.Height)
+}
+
+// screenshotMedia 图片、视频与语音的占位图
+const screenshotMedia = template.URL(`data:image/svg+xml,%3Csvg xmlns='http://www.w3.org/2000/svg' width='240' height='160'%3E%3Crect width='100%25' height='100%25' fill='%23ccc'/%3E%3C/svg%3E`)
+
+// screenshotPage 返回月份页面模板的参数，所有消息放在同一页中，不显示前后翻页
+// mask 为 true 时将标题、发送者与消息文字替换为占位字符
+func screenshotPage(messages []*model.Message, mask bool) map[string]interface{} {
+	first, last := messages[0].Time, messages[len(messages)-1].Time
+	label := first.Format("2006-01-02")
+	if d := last.Format("2006-01-02"); d != label {
+		label += " ~ " + d
+	}
+	page := &htmlMonth{Month: label}
+	for _, m := range messages {
+		hm := newHTMLMessage(m)
+		switch hm.Kind {
+		case "image", "video", "voice":
+			hm.Media = screenshotMedia
+		}
+		if mask {
+			maskHTMLMessage(hm)
+		}
+		page.Messages = append(page.Messages, hm)
+	}
+	markDates(page.Messages, datefmt.Default)
+
+	title := messages[0].Talker
+	if messages[0].TalkerName != "" {
+		title = messages[0].TalkerName
+	}
+	if mask {
+		title = maskText(title)
+	}
+	return map[string]interface{}{
+		"Title":    title,
+		"ChatRoom": messages[0].IsChatRoom,
+		"Month":    page,
+	}
+}
+
+// maskHTMLMessage 将消息中会显示的文字替换为占位字符，链接地址不显示在页面中，直接清除
+func maskHTMLMessage(hm *htmlMessage) {
+	hm.Sender = maskText(hm.Sender)
+	hm.Text = maskText(hm.Text)
+	hm.Translate = maskText(hm.Translate)
+	if hm.URL != "" {
+		hm.URL = "#"
+	}
+	if q := hm.Quote; q != nil {
+		q.Sender = maskText(q.Sender)
+		q.Text = maskText(q.Text)
+	}
+}
+
+// maskText 保留空白与换行，ASCII 字符替换为 x，其他字符替换为全角的 口，使替换后的文字宽度与原文相近
+func maskText(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case unicode.IsSpace(r):
+			return r
+		case r < unicode.MaxASCII:
+			return 'x'
+		default:
+			return '口'
+		}
+	}, s)
+}
+
+// SyntheticMessages 生成 n 条内容固定的群聊消息，包含长文本、多媒体、链接、系统消息、译文与跨天的消息，
+// 用于没有真实数据时复现页面布局
+func SyntheticMessages(n int) []*model.Message {
+	start := time.Date(2024, 3, 1, 9, 0, 0, 0, time.FixedZone("CST", 8*3600))
+	senders := []struct{ id, name string }{
+		{"wxid_alice", "Alice"},
+		{"wxid_bob", "鲍勃"},
+		{"wxid_self", "我"},
+	}
+	texts := []string{
+		"早上好",
+		"今天下午三点在会议室讨论下个版本的发布计划，记得带上电脑。",
+		"OK",
+		"The quick brown fox jumps over the lazy dog, and then keeps running until the line wraps inside the bubble.",
+		"第一行\n第二行\n\n空行之后的第四行",
+	}
+	messages := make([]*model.Message, 0, n)
+	for i := 0; i < n; i++ {
+		sender := senders[i%len(senders)]
+		m := &model.Message{
+			Seq:        int64(i + 1),
+			Time:       start.Add(time.Duration(i) * 37 * time.Minute),
+			Talker:     "12345678@chatroom",
+			TalkerName: "测试群",
+			IsChatRoom: true,
+			Sender:     sender.id,
+			SenderName: sender.name,
+			IsSelf:     sender.id == "wxid_self",
+			Type:       1,
+			Content:    texts[i%len(texts)],
+		}
+		switch i % 11 {
+		case 3:
+			m.Type = 3
+			m.Content = ""
+		case 5:
+			m.Type, m.SubType = 49, 5
+			m.Contents = map[string]interface{}{"title": "一篇分享的文章标题", "url": "https://example.com/article"}
+		case 7:
+			m.Type = 34
+			m.Content = ""
+		case 8:
+			m.Type = 10000
+			m.Content = fmt.Sprintf("\"%s\" 邀请 \"新成员\" 加入了群聊", sender.name)
+		case 9:
+			m.Translation = "This message has a translation shown below the original text."
+		case 10:
+			m.Type = 43
+			m.Content = ""
+		}
+		m.Body = m.Decode()
+		messages = append(messages, m)
+	}
+	return messages
+}
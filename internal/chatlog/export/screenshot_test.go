@@ -0,0 +1,106 @@
+package export
+
+import (
+	"bytes"
+	"flag"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aspnmy/chatlog/pkg/htmlshot"
+)
+
+var update = flag.Bool("update", false, "record reference screenshots in testdata/screenshots")
+
+// TestScreenshotGolden 比较合成会话的截图与本机记录的参考图片，找不到浏览器时跳过
+// 截图随浏览器版本与系统字体变化，参考图片不提交到仓库：修改页面模板或样式前执行
+// go test ./internal/chatlog/export -run TestScreenshotGolden -update 记录参考图片，修改后再运行测试对比
+func TestScreenshotGolden(t *testing.T) {
+	if _, err := htmlshot.Browser(); err != nil {
+		t.Skip(err)
+	}
+	for _, tc := range []struct {
+		name     string
+		messages int
+		opts     ScreenshotOptions
+	}{
+		{"synthetic_480", 24, ScreenshotOptions{Width: 480, Height: 1600}},
+		{"synthetic_800", 12, ScreenshotOptions{Width: 800, Height: 1000}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := RenderScreenshot(&buf, SyntheticMessages(tc.messages), tc.opts); err != nil {
+				t.Fatal(err)
+			}
+			golden := filepath.Join("testdata", "screenshots", tc.name+".png")
+			if *update {
+				if err := os.MkdirAll(filepath.Dir(golden), 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(golden, buf.Bytes(), 0644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+
+			want, err := readPNG(golden)
+			if os.IsNotExist(err) {
+				t.Skipf("%s not recorded, run with -update first", golden)
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := png.Decode(&buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Bounds() != want.Bounds() {
+				t.Fatalf("size = %v, want %v", got.Bounds().Size(), want.Bounds().Size())
+			}
+			diff, first := 0, image.Point{-1, -1}
+			b := got.Bounds()
+			for y := b.Min.Y; y < b.Max.Y; y++ {
+				for x := b.Min.X; x < b.Max.X; x++ {
+					if got.At(x, y) != want.At(x, y) {
+						if diff == 0 {
+							first = image.Pt(x, y)
+						}
+						diff++
+					}
+				}
+			}
+			if diff > 0 {
+				t.Errorf("%d pixels differ from %s, first at %v", diff, golden, first)
+			}
+		})
+	}
+}
+
+func TestScreenshotMask(t *testing.T) {
+	messages := SyntheticMessages(11)
+	var buf bytes.Buffer
+	if err := htmlTemplate.ExecuteTemplate(&buf, "month", screenshotPage(messages, true)); err != nil {
+		t.Fatal(err)
+	}
+	page := buf.String()
+	for _, s := range []string{"测试群", "Alice", "鲍勃", "会议室", "quick brown", "分享的文章", "example.com", "translation"} {
+		if strings.Contains(page, s) {
+			t.Errorf("page contains %q", s)
+		}
+	}
+	if got := maskText("OK 好的\n"); got != "xx 口口\n" {
+		t.Errorf("maskText = %q", got)
+	}
+}
+
+func readPNG(name string) (image.Image, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return png.Decode(f)
+}
@@ -0,0 +1,470 @@
+package export
+
+import (
+	"context"
+	"crypto/rand"
+	"io"
+	"path"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/aspnmy/chatlog/internal/chatlog/ctx"
+	"github.com/aspnmy/chatlog/internal/chatlog/database"
+	"github.com/aspnmy/chatlog/internal/errors"
+	"github.com/aspnmy/chatlog/internal/model"
+	"github.com/aspnmy/chatlog/internal/wechat/media"
+	"github.com/aspnmy/chatlog/pkg/destination"
+	"github.com/aspnmy/chatlog/pkg/hashtag"
+	"github.com/aspnmy/chatlog/pkg/throttle"
+	"github.com/aspnmy/chatlog/pkg/trace"
+	"github.com/aspnmy/chatlog/pkg/util"
+	"github.com/aspnmy/chatlog/pkg/util/silk"
+	"github.com/aspnmy/chatlog/pkg/zipaes"
+)
+
+const (
+	FormatText    = "txt"
+	FormatJSON    = "json"
+	FormatGallery = "gallery"
+
+	// FormatJSONL 每行一条消息的 JSON，可以通过 chatlog import 重新导入工作目录
+	FormatJSONL = "jsonl"
+	// FormatCSV 每行一条消息的 CSV，列名与 JSON 的字段名相同，见 csvHeader
+	FormatCSV = "csv"
+
+	// FormatObsidian Obsidian 笔记库，每个会话每天一篇 Markdown 笔记
+	FormatObsidian = "obsidian"
+	// FormatMarkdown Markdown 笔记库，每个会话每月一篇笔记，frontmatter 中包含参与者与日期范围，
+	// 目录结构与 FormatObsidian 相同，可以直接放入 Obsidian 或 Logseq
+	FormatMarkdown = "markdown"
+
+	// FormatVoice 会话中的语音消息，按 Options.VoiceFormat 转码后每条一个文件
+	FormatVoice = "voice"
+
+	// FormatHTML 可以直接用浏览器打开的 HTML 归档，每个会话按月分页，附带图片、视频、语音与文件
+	FormatHTML = "html"
+
+	// FormatAttachments 会话中的视频与文件原文件，通过 hardlink 数据库找到数据目录中的文件，附带 manifest.csv 清单
+	FormatAttachments = "attachments"
+)
+
+// Options 导出参数
+type Options struct {
+	Talker string // 聊天对象，多个以英文逗号分隔，为空时导出所有会话
+	Time   string // 时间范围，格式同 /api/v1/chatlog 的 time 参数，为空时导出全部
+	Format string // 导出格式
+	Dest   string // 导出目标，见 destination.New
+
+	// DataDir 微信数据目录，导出相册时从中读取原始图片与视频
+	DataDir string
+	// ImgKey 微信 4.0 图片密钥，导出相册时用于解密图片
+	ImgKey string
+
+	// NormalizeTime 将设备时钟错误导致时间异常的消息修正为前一条正常消息的时间
+	NormalizeTime bool
+
+	// EncryptPerTalker 每个会话单独打包为 AES-256 加密的 zip 文件，密码互不相同
+	EncryptPerTalker bool
+	// Passwords 指定会话的密码，未指定的会话随机生成
+	Passwords map[string]string
+
+	// Notify 导出结束后按 smtp 配置发送摘要邮件
+	Notify bool
+
+	// After 只导出排在该游标之后的消息，用于增量导出，游标为上一次导出结果中的 Cursor
+	After string
+
+	// Lang 同时导出文字消息的译文（如 en），尚未翻译的消息由配置的翻译服务翻译
+	Lang string
+
+	// ExcludeTalkers 不导出的会话 ID，用于跳过锁定的会话
+	ExcludeTalkers []string
+
+	// VoiceFormat 导出语音时的音频格式，见 media.VoiceFormats，默认为 mp3
+	VoiceFormat string
+	// MP3 语音导出为 mp3 时的编码参数，未设置的参数使用 silk.DefaultOptions
+	MP3 silk.Options
+
+	// Inline 导出 HTML 时将图片与语音以 data URI 内嵌到页面中，不单独保存文件
+	Inline bool
+
+	// Link 导出附件到本地目录时创建硬链接而不是复制，不占用额外空间，无法链接（如不在同一磁盘）时仍然复制
+	Link bool
+
+	// Offline 导出 HTML 与 Markdown 时不下载自定义表情，只使用工作目录中已缓存的表情，其余显示为 [动画表情]
+	Offline bool
+
+	// Topic 只导出带有该话题标签的消息，为话题名称或标签，话题与标签的约定见配置文件中的 topics
+	Topic string
+
+	// Status 只导出发送状态为 Status 的消息，如 failed 用于检查没有发出的消息，见 model.StatusSending 等
+	Status string
+
+	// NameTemplate 导出文件名模板，如 {talker}/{date}/{msgid}_{type}.{ext}，占位符见 namePlaceholders
+	// 用于媒体文件与聊天记录文件，为空时使用各格式的默认命名，同一次导出中重名的文件自动加序号
+	NameTemplate string
+}
+
+// Result 导出结果
+type Result struct {
+	Dest     string        `json:"dest"`
+	Files    []string      `json:"files"`
+	Messages int           `json:"messages"`
+	Bytes    int64         `json:"bytes"`
+	Failed   []string      `json:"failed,omitempty"`
+	Duration time.Duration `json:"duration"`
+
+	// TimeAnomalies 时间异常（未来时间或顺序错乱）的消息数
+	TimeAnomalies int `json:"timeAnomalies"`
+
+	// Passwords 加密导出时每个会话使用的密码
+	Passwords map[string]string `json:"-"`
+
+	// Cursor 已导出的最后一条消息的位置，下次通过 Options.After 从这里继续增量导出
+	// 有会话导出失败时不前进，保持为 Options.After，避免失败会话的消息被跳过
+	Cursor string `json:"cursor,omitempty"`
+}
+
+type Service struct {
+	ctx *ctx.Context
+	db  *database.Service
+}
+
+func NewService(ctx *ctx.Context, db *database.Service) *Service {
+	return &Service{
+		ctx: ctx,
+		db:  db,
+	}
+}
+
+// Export 按会话导出聊天记录，每个会话一个文件
+func (s *Service) Export(opts Options) (*Result, error) {
+	begin := time.Now()
+
+	if opts.Format == "" {
+		opts.Format = FormatText
+	}
+	opts.Format = strings.ToLower(opts.Format)
+	switch opts.Format {
+	case FormatText, FormatJSON, FormatJSONL, FormatCSV:
+	case FormatGallery, FormatAttachments:
+		if opts.EncryptPerTalker {
+			return nil, errors.InvalidArg("encrypt-per-talker")
+		}
+		if s.ctx.DataDir == "" {
+			return nil, errors.InvalidArg("data-dir")
+		}
+	case FormatObsidian, FormatMarkdown:
+		if opts.EncryptPerTalker {
+			return nil, errors.InvalidArg("encrypt-per-talker")
+		}
+	case FormatHTML:
+		if opts.EncryptPerTalker {
+			return nil, errors.InvalidArg("encrypt-per-talker")
+		}
+		if err := opts.MP3.Validate(); err != nil {
+			return nil, errors.InvalidArg("mp3")
+		}
+	case FormatVoice:
+		if opts.EncryptPerTalker {
+			return nil, errors.InvalidArg("encrypt-per-talker")
+		}
+		if opts.VoiceFormat == "" {
+			opts.VoiceFormat = media.VoiceMP3
+		}
+		opts.VoiceFormat = strings.ToLower(opts.VoiceFormat)
+		if !slices.Contains(media.VoiceFormats, opts.VoiceFormat) {
+			return nil, errors.InvalidArg("voice-format")
+		}
+		if err := opts.MP3.Validate(); err != nil {
+			return nil, errors.InvalidArg("mp3")
+		}
+	default:
+		return nil, errors.InvalidArg("format")
+	}
+	if opts.Topic != "" && hashtag.Normalize(opts.Topic) == "" {
+		return nil, errors.InvalidArg("topic")
+	}
+	if opts.Status != "" && !model.ValidStatus(opts.Status) {
+		return nil, errors.InvalidArg("status")
+	}
+	if err := ValidateNameTemplate(opts.NameTemplate); err != nil {
+		return nil, errors.InvalidArg("name-template")
+	}
+
+	timeRange := opts.Time
+	if timeRange == "" {
+		timeRange = "2000-01-01~" + time.Now().Format("2006-01-02")
+	}
+	start, end, ok := util.TimeRangeOf(timeRange)
+	if !ok {
+		return nil, errors.InvalidArg("time")
+	}
+
+	after, err := model.ParseCursor(opts.After)
+	if err != nil {
+		return nil, errors.InvalidArg("after")
+	}
+	if !after.IsZero() {
+		if t := time.Unix(after.Seq/1000, 0); t.After(start) {
+			start = t
+		}
+	}
+
+	talkers, err := s.talkers(opts.Talker, opts.ExcludeTalkers)
+	if err != nil {
+		return nil, err
+	}
+
+	dest, err := destination.New(opts.Dest)
+	if err != nil {
+		return nil, err
+	}
+	defer dest.Close()
+
+	result := &Result{Dest: dest.String()}
+	names := newNamer(opts.NameTemplate)
+	last := after
+	timeFormat := s.ctx.Dates.DateTime(util.PerfectTimeFormat(start, end))
+
+	traceCtx, span := trace.Start(context.Background(), "export")
+	span.SetAttr("talkers", len(talkers)).SetAttr("format", opts.Format).SetAttr("dest", result.Dest)
+	defer span.End()
+
+	// 按 --workers 并发导出多个会话
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, throttle.Workers())
+	for _, talker := range talkers {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(talker string) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			f, err := s.exportTalker(traceCtx, dest, names, talker, start, end, timeFormat, after, opts)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				log.Err(err).Msgf("export %s failed", talker)
+				result.Failed = append(result.Failed, talker)
+				return
+			}
+			if f == nil {
+				return
+			}
+			result.Files = append(result.Files, f.name)
+			if f.last.Compare(last) > 0 {
+				last = f.last
+			}
+			result.Messages += f.messages
+			result.TimeAnomalies += f.anomalies
+			result.Bytes += f.bytes
+			if f.password != "" {
+				if result.Passwords == nil {
+					result.Passwords = make(map[string]string)
+				}
+				result.Passwords[talker] = f.password
+			}
+		}(talker)
+	}
+	wg.Wait()
+	sort.Strings(result.Files)
+	sort.Strings(result.Failed)
+	result.Cursor = last.String()
+	if len(result.Failed) > 0 {
+		result.Cursor = after.String()
+	}
+	result.Duration = time.Since(begin)
+
+	return result, nil
+}
+
+type exportedFile struct {
+	name      string
+	messages  int
+	anomalies int
+	bytes     int64
+	password  string
+	last      model.Cursor // 最后一条消息的位置
+}
+
+// exportTalker 导出单个会话排在 after 之后的消息，会话在时间范围内没有消息时返回 nil
+// 聊天记录文件逐月读取并写出，其他格式需要按日期或月份归组，一次读取全部消息
+func (s *Service) exportTalker(ctx context.Context, dest destination.Destination, names *namer, talker string, start, end time.Time, timeFormat string, after model.Cursor, opts Options) (f *exportedFile, err error) {
+	ctx, span := trace.Start(ctx, "export.talker")
+	span.SetAttr("talker", talker)
+	r := s.newMessageReader(ctx, talker, start, end, after, opts)
+	defer func() {
+		r.warn()
+		if f != nil {
+			f.last = r.last
+			f.anomalies = r.anomalies
+			span.SetAttr("messages", f.messages).SetAttr("bytes", f.bytes)
+		}
+		span.SetError(err).End()
+	}()
+
+	switch opts.Format {
+	case FormatGallery, FormatObsidian, FormatMarkdown, FormatVoice, FormatHTML, FormatAttachments:
+		messages, err := r.all()
+		if err != nil || len(messages) == 0 {
+			return nil, err
+		}
+		switch opts.Format {
+		case FormatGallery:
+			return s.writeGallery(ctx, dest, names, talker, messages)
+		case FormatObsidian, FormatMarkdown:
+			return s.writeObsidian(ctx, dest, names, talker, messages, opts.Format == FormatMarkdown, opts.Offline)
+		case FormatAttachments:
+			return s.writeAttachments(ctx, dest, names, talker, messages, opts.Link)
+		case FormatVoice:
+			return s.writeVoice(ctx, dest, names, talker, messages, opts.VoiceFormat, opts.MP3)
+		default:
+			return s.writeHTML(ctx, dest, names, talker, messages, opts.Inline, opts.Offline, opts.MP3)
+		}
+	}
+
+	first, err := r.next()
+	if err != nil || first == nil {
+		return nil, err
+	}
+	// 第一段已经读取，其余的在写出时逐段读取
+	write := func(w io.Writer) error {
+		enc := newEncoder(w, opts.Format, timeFormat)
+		for messages := first; messages != nil; {
+			if err := enc.encode(messages); err != nil {
+				return err
+			}
+			if messages, err = r.next(); err != nil {
+				return err
+			}
+		}
+		return enc.close()
+	}
+
+	fields := messageFields(talker, talkerName(talker, first), first[0], "chat", opts.Format)
+	f = &exportedFile{}
+	if opts.EncryptPerTalker {
+		f.password = opts.Passwords[talker]
+		if f.password == "" {
+			f.password = rand.Text()
+		}
+		entry := path.Base(renderName(names.template(transcriptName), fields))
+		fields.Ext = "zip"
+		f.name = names.name(transcriptName, fields)
+		f.bytes, err = s.writeEncrypted(dest, f.name, entry, f.password, write)
+	} else {
+		f.name = names.name(transcriptName, fields)
+		f.bytes, err = s.write(dest, f.name, write)
+	}
+	if err != nil {
+		return nil, err
+	}
+	f.messages = r.messages
+	return f, nil
+}
+
+// translate 填入消息的 lang 语言译文，翻译失败时只记录日志，仍导出原文
+func (s *Service) translate(ctx context.Context, talker string, messages []*model.Message, lang string) {
+	missing, err := s.db.Translate(ctx, messages, lang, true)
+	if err != nil {
+		log.Err(err).Msgf("translate %s failed", talker)
+	}
+	if missing > 0 {
+		log.Warn().Msgf("%s has %d messages without %s translation", talker, missing, lang)
+	}
+}
+
+// talkers 解析需要导出的会话列表
+// talkers 返回要导出的会话，talker 为空时导出所有会话，exclude 中的会话不导出
+func (s *Service) talkers(talker string, exclude []string) ([]string, error) {
+	var talkers []string
+	if talker != "" {
+		talkers = util.Str2List(talker, ",")
+	} else {
+		sessions, err := s.db.GetSessions("", 0, 0)
+		if err != nil {
+			return nil, err
+		}
+		talkers = make([]string, 0, len(sessions.Items))
+		for _, session := range sessions.Items {
+			talkers = append(talkers, session.UserName)
+		}
+	}
+	return slices.DeleteFunc(talkers, func(t string) bool {
+		return slices.Contains(exclude, t)
+	}), nil
+}
+
+// write 将 encode 写出的内容写入 dest 中的 name，返回写入的字节数
+func (s *Service) write(dest destination.Destination, name string, encode func(w io.Writer) error) (int64, error) {
+	w, err := dest.Create(name)
+	if err != nil {
+		return 0, err
+	}
+	cw := &countWriter{w: throttle.Writer(w)}
+	if err := encode(cw); err != nil {
+		destination.Abort(w)
+		return cw.n, err
+	}
+	return cw.n, w.Close()
+}
+
+// writeEncrypted 将会话写入单独加密的 zip 文件，返回的字节数为 zip 文件大小
+func (s *Service) writeEncrypted(dest destination.Destination, name string, entry string, password string, encode func(w io.Writer) error) (int64, error) {
+	w, err := dest.Create(name)
+	if err != nil {
+		return 0, err
+	}
+	cw := &countWriter{w: throttle.Writer(w)}
+	zw := zipaes.NewWriter(cw, password)
+
+	err = func() error {
+		ew, err := zw.Create(entry, time.Now())
+		if err != nil {
+			return err
+		}
+		if err := encode(ew); err != nil {
+			return err
+		}
+		if err := ew.Close(); err != nil {
+			return err
+		}
+		return zw.Close()
+	}()
+	if err != nil {
+		destination.Abort(w)
+		return cw.n, err
+	}
+	return cw.n, w.Close()
+}
+
+// sanitize 替换文件名中不允许出现的字符
+func sanitize(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case '/', '\\', ':', '*', '?', '"', '<', '>', '|':
+			return '_'
+		}
+		return r
+	}, name)
+}
+
+type countWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,51 @@
+package export
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aspnmy/chatlog/pkg/util"
+)
+
+// Summary 生成导出结果的摘要，用于邮件通知，err 为导出失败的原因
+func Summary(opts Options, result *Result, err error) (subject string, body string) {
+	status := "completed"
+	switch {
+	case err != nil:
+		status = "failed"
+	case len(result.Failed) > 0:
+		status = "completed with failures"
+	}
+	subject = fmt.Sprintf("[chatlog] export %s: %s", status, opts.Dest)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Export %s at %s\n\n", status, time.Now().Format(time.DateTime))
+	fmt.Fprintf(&b, "Destination: %s\n", opts.Dest)
+	fmt.Fprintf(&b, "Format:      %s\n", opts.Format)
+	if opts.Talker != "" {
+		fmt.Fprintf(&b, "Talker:      %s\n", opts.Talker)
+	}
+	if opts.Time != "" {
+		fmt.Fprintf(&b, "Time:        %s\n", opts.Time)
+	}
+	if err != nil {
+		fmt.Fprintf(&b, "\nError: %v\n", err)
+		return subject, b.String()
+	}
+
+	fmt.Fprintf(&b, "Messages:    %d\n", result.Messages)
+	fmt.Fprintf(&b, "Files:       %d\n", len(result.Files))
+	fmt.Fprintf(&b, "Size:        %s\n", util.ByteCountSI(result.Bytes))
+	fmt.Fprintf(&b, "Duration:    %s\n", result.Duration.Round(time.Second))
+	if result.TimeAnomalies > 0 {
+		fmt.Fprintf(&b, "Abnormal time: %d messages\n", result.TimeAnomalies)
+	}
+	if len(result.Failed) > 0 {
+		fmt.Fprintf(&b, "\nFailed (%d):\n", len(result.Failed))
+		for _, f := range result.Failed {
+			fmt.Fprintf(&b, "  %s\n", f)
+		}
+	}
+	return subject, b.String()
+}
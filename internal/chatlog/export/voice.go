@@ -0,0 +1,105 @@
+package export
+
+import (
+	"context"
+	"encoding/csv"
+	"path"
+	"strconv"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/aspnmy/chatlog/internal/model"
+	"github.com/aspnmy/chatlog/internal/wechat/media"
+	"github.com/aspnmy/chatlog/pkg/destination"
+	"github.com/aspnmy/chatlog/pkg/throttle"
+	"github.com/aspnmy/chatlog/pkg/util/silk"
+)
+
+// writeVoice 从数据库中读取会话的语音消息，按 format 转码（mp3 按 mp3 参数编码）后按 年/年-月 目录存放，并生成 index.csv
+// 默认文件名为 时间_语音ID_发送人，无法转码时保存原始的 silk/amr 数据，index.csv 的 converted 列表示文件是否为所选的格式
+func (s *Service) writeVoice(ctx context.Context, dest destination.Destination, names *namer, talker string, messages []*model.Message, format string, mp3 silk.Options) (*exportedFile, error) {
+	dir := sanitize(talker)
+	f := &exportedFile{name: path.Join(dir, "index.csv")}
+	tname := talkerName(talker, messages)
+
+	var rows [][]string
+	fallback := 0
+	for _, m := range messages {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if m.Type != 34 {
+			continue
+		}
+		key, _ := m.Contents["voice"].(string)
+		if key == "" {
+			continue
+		}
+		voice, err := s.db.GetMedia("voice", key)
+		if err != nil {
+			log.Debug().Err(err).Msgf("voice of %s %d not found", talker, m.Seq)
+			continue
+		}
+		data, ext, err := media.ConvertVoiceWithOptions(voice.Data, format, mp3)
+		if err != nil {
+			log.Debug().Err(err).Msgf("convert voice %s failed", key)
+			data, ext = voice.Data, media.VoiceExt(voice.Data)
+		}
+		converted := ext == format
+		if !converted {
+			fallback++
+		}
+
+		sender := m.SenderName
+		if sender == "" {
+			sender = m.Sender
+		}
+		fields := messageFields(talker, tname, m, "voice", ext)
+		fields.Key = key
+		name := names.name(voiceName, fields)
+		n, err := writeFile(dest, name, data)
+		if err != nil {
+			log.Debug().Err(err).Msgf("write voice %s failed", name)
+			continue
+		}
+		rows = append(rows, []string{m.Time.Format("2006-01-02 15:04:05"), sender, key, relName(dir, name), strconv.FormatBool(converted)})
+		f.messages++
+		f.bytes += n
+	}
+	if fallback > 0 {
+		log.Warn().Msgf("%s: %d voices saved without %s conversion, see converted in index.csv", talker, fallback, format)
+	}
+	if f.messages == 0 {
+		return nil, nil
+	}
+
+	w, err := dest.Create(f.name)
+	if err != nil {
+		return nil, err
+	}
+	cw := &countWriter{w: throttle.Writer(w)}
+	cw.Write([]byte("\xef\xbb\xbf")) // BOM，便于 Excel 识别 UTF-8
+	csvw := csv.NewWriter(cw)
+	csvw.Write([]string{"time", "sender", "id", "file", "converted"})
+	csvw.WriteAll(rows)
+	if err := csvw.Error(); err != nil {
+		destination.Abort(w)
+		return nil, err
+	}
+	f.bytes += cw.n
+	return f, w.Close()
+}
+
+// writeFile 将 data 写入 dest 中的 name，返回写入的字节数
+func writeFile(dest destination.Destination, name string, data []byte) (int64, error) {
+	w, err := dest.Create(name)
+	if err != nil {
+		return 0, err
+	}
+	n, err := throttle.Writer(w).Write(data)
+	if err != nil {
+		destination.Abort(w)
+		return int64(n), err
+	}
+	return int64(n), w.Close()
+}
@@ -0,0 +1,124 @@
+package http
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/aspnmy/chatlog/internal/chatlog/conf"
+	"github.com/aspnmy/chatlog/internal/errors"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Admin 由 Manager 实现，供 /api/v1/admin 下的管理接口控制常驻进程
+type Admin interface {
+	// PauseSync 暂停自动解密
+	PauseSync() error
+	// ResumeSync 恢复自动解密
+	ResumeSync() error
+	// SyncNow 立即解密一次全部数据库
+	SyncNow() error
+	// RotateKey 重新从微信进程提取密钥
+	RotateKey() error
+	// ReloadConfig 重新读取配置文件
+	ReloadConfig() error
+	// SwitchAccount 切换到配置文件中保存的另一个账号，HTTP 服务保持运行
+	SwitchAccount(account string) error
+}
+
+// SetAdmin 设置管理接口执行操作的对象，未设置时管理接口不可用
+func (s *Service) SetAdmin(admin Admin) {
+	s.admin = admin
+}
+
+// adminAuth 校验 Authorization: Bearer <token>，未配置令牌时管理接口不可用
+func (s *Service) adminAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := s.ctx.GetAdminToken()
+		if token == "" || s.admin == nil {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "admin api is disabled, set admin_token in the config file or " + conf.EnvAdminToken})
+			return
+		}
+		got, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+			c.Header("WWW-Authenticate", `Bearer realm="chatlog"`)
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid admin token"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// adminAction 执行一个管理操作并返回执行后的状态，同一时间只允许一个操作
+func (s *Service) adminAction(action func(Admin) error) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !s.adminMu.TryLock() {
+			c.JSON(http.StatusConflict, gin.H{"error": "another admin action is running"})
+			return
+		}
+		defer s.adminMu.Unlock()
+
+		if err := action(s.admin); err != nil {
+			errors.Err(c, err)
+			return
+		}
+		s.AdminStatus(c)
+	}
+}
+
+// AdminStatus 返回同步与密钥状态
+func (s *Service) AdminStatus(c *gin.Context) {
+	status := s.ctx.SyncStatus()
+	resp := gin.H{
+		"account":      status.Account,
+		"auto_decrypt": status.AutoDecrypt,
+		"http_addr":    status.HTTPAddr,
+		"key_time":     status.KeyTime,
+	}
+	if !status.LastSync.IsZero() {
+		resp["last_sync"] = status.LastSync
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// AdminAccount 配置文件中保存的一个账号，不包含密钥
+type AdminAccount struct {
+	Account     string `json:"account"`
+	Platform    string `json:"platform"`
+	Version     int    `json:"version"`
+	FullVersion string `json:"full_version,omitempty"`
+	DataDir     string `json:"data_dir"`
+	WorkDir     string `json:"work_dir"`
+	HasKey      bool   `json:"has_key"`
+	Current     bool   `json:"current"`
+}
+
+// AdminAccounts 返回配置文件中保存的全部账号，按账号排序
+func (s *Service) AdminAccounts(c *gin.Context) {
+	history, current := s.ctx.Accounts()
+	accounts := make([]AdminAccount, 0, len(history))
+	for _, h := range history {
+		accounts = append(accounts, AdminAccount{
+			Account:     h.Account,
+			Platform:    h.Platform,
+			Version:     h.Version,
+			FullVersion: h.FullVersion,
+			DataDir:     h.DataDir,
+			WorkDir:     h.WorkDir,
+			HasKey:      h.DataKey != "",
+			Current:     h.Account == current,
+		})
+	}
+	c.JSON(http.StatusOK, gin.H{"current": current, "items": accounts})
+}
+
+// AdminSwitchAccount 切换到 account 参数指定的账号并返回切换后的状态
+func (s *Service) AdminSwitchAccount(c *gin.Context) {
+	account := c.Query("account")
+	if account == "" {
+		errors.Err(c, errors.InvalidArg("account"))
+		return
+	}
+	s.adminAction(func(a Admin) error { return a.SwitchAccount(account) })(c)
+}
@@ -0,0 +1,128 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/aspnmy/chatlog/internal/chatlog/conf"
+	"github.com/aspnmy/chatlog/internal/chatlog/ctx"
+)
+
+type fakeAdmin struct {
+	paused  bool
+	account string
+}
+
+func (a *fakeAdmin) PauseSync() error    { a.paused = true; return nil }
+func (a *fakeAdmin) ResumeSync() error   { a.paused = false; return nil }
+func (a *fakeAdmin) SyncNow() error      { return nil }
+func (a *fakeAdmin) RotateKey() error    { return nil }
+func (a *fakeAdmin) ReloadConfig() error { return nil }
+func (a *fakeAdmin) SwitchAccount(account string) error {
+	a.account = account
+	return nil
+}
+
+func TestAdminAuth(t *testing.T) {
+	c := &ctx.Context{}
+	s := NewService(c, nil, nil)
+	admin := &fakeAdmin{}
+	s.SetAdmin(admin)
+
+	do := func(token string) int {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/admin/sync/pause", nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		s.GetRouter().ServeHTTP(w, req)
+		return w.Code
+	}
+
+	// 未配置令牌时管理接口不可用
+	if code := do("secret"); code != http.StatusForbidden {
+		t.Fatalf("got %d without admin token, want 403", code)
+	}
+
+	c.AdminToken = "secret"
+	if code := do(""); code != http.StatusUnauthorized {
+		t.Fatalf("got %d without authorization, want 401", code)
+	}
+	if code := do("wrong"); code != http.StatusUnauthorized {
+		t.Fatalf("got %d with wrong token, want 401", code)
+	}
+	if admin.paused {
+		t.Fatal("action executed without authorization")
+	}
+	if code := do("secret"); code != http.StatusOK || !admin.paused {
+		t.Fatalf("got %d, paused %v, want 200 and paused", code, admin.paused)
+	}
+}
+
+func TestAdminSwitchAccount(t *testing.T) {
+	c := &ctx.Context{AdminToken: "secret", Account: "wxid_a", History: map[string]conf.ProcessConfig{
+		"wxid_a": {Account: "wxid_a", DataKey: "key"},
+		"wxid_b": {Account: "wxid_b"},
+	}}
+	s := NewService(c, nil, nil)
+	admin := &fakeAdmin{}
+	s.SetAdmin(admin)
+
+	do := func(method, target string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, nil)
+		req.Header.Set("Authorization", "Bearer secret")
+		w := httptest.NewRecorder()
+		s.GetRouter().ServeHTTP(w, req)
+		return w
+	}
+
+	w := do(http.MethodGet, "/api/v1/admin/accounts")
+	var resp struct {
+		Current string         `json:"current"`
+		Items   []AdminAccount `json:"items"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("got %d %s", w.Code, w.Body)
+	}
+	if resp.Current != "wxid_a" || len(resp.Items) != 2 || !resp.Items[0].Current || !resp.Items[0].HasKey || resp.Items[1].Account != "wxid_b" {
+		t.Errorf("accounts = %+v", resp)
+	}
+	if strings.Contains(w.Body.String(), `"key"`) {
+		t.Errorf("key exposed: %s", w.Body)
+	}
+
+	if w := do(http.MethodPost, "/api/v1/admin/accounts/switch"); w.Code != http.StatusBadRequest {
+		t.Errorf("got %d without account, want 400", w.Code)
+	}
+	if w := do(http.MethodPost, "/api/v1/admin/accounts/switch?account=wxid_b"); w.Code != http.StatusOK || admin.account != "wxid_b" {
+		t.Errorf("got %d, switched to %q", w.Code, admin.account)
+	}
+}
+
+func TestAdminStatusWhileSyncing(t *testing.T) {
+	c := &ctx.Context{AdminToken: "secret", Account: "wxid_a"}
+	s := NewService(c, nil, nil)
+	s.SetAdmin(&fakeAdmin{})
+
+	// 同步完成与读取状态并发进行，使用 -race 运行时检查数据竞争
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			c.Synced()
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/admin/status", nil)
+		req.Header.Set("Authorization", "Bearer secret")
+		w := httptest.NewRecorder()
+		s.GetRouter().ServeHTTP(w, req)
+		if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"account":"wxid_a"`) {
+			t.Fatalf("status = %d, body = %s", w.Code, w.Body)
+		}
+	}
+	<-done
+}
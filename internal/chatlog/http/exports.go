@@ -0,0 +1,447 @@
+package http
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aspnmy/chatlog/internal/chatlog/export"
+	"github.com/aspnmy/chatlog/internal/errors"
+	"github.com/aspnmy/chatlog/pkg/throttle"
+	"github.com/aspnmy/chatlog/pkg/util/silk"
+	"github.com/aspnmy/chatlog/pkg/zipstream"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+// Exporter 由 export.Service 实现，供 /api/v1/exports 在服务端生成导出文件
+type Exporter interface {
+	Export(opts export.Options) (*export.Result, error)
+}
+
+// SetExporter 设置导出接口使用的导出服务，未设置时导出接口不可用
+func (s *Service) SetExporter(exporter Exporter) {
+	s.exporter = exporter
+}
+
+// 导出任务状态
+const (
+	ExportPending = "pending"
+	ExportRunning = "running"
+	ExportDone    = "done"
+	ExportFailed  = "failed"
+)
+
+var exportIDPattern = regexp.MustCompile(`^[0-9a-f]{16}$`)
+
+// exportRequest 创建导出任务的参数，含义同 chatlog export 的同名参数
+type exportRequest struct {
+	Talker        string `json:"talker"`
+	Time          string `json:"time"`
+	Format        string `json:"format"`
+	After         string `json:"after,omitempty"`
+	NormalizeTime bool   `json:"normalize_time,omitempty"`
+	Lang          string `json:"lang,omitempty"`
+	VoiceFormat   string `json:"voice_format,omitempty"`
+	MP3SampleRate int    `json:"mp3_sample_rate,omitempty"`
+	MP3Bitrate    int    `json:"mp3_bitrate,omitempty"`
+	MP3Channels   int    `json:"mp3_channels,omitempty"`
+	MP3VBR        bool   `json:"mp3_vbr,omitempty"`
+	NameTemplate  string `json:"name_template,omitempty"`
+	Inline        bool   `json:"inline,omitempty"`
+	Offline       bool   `json:"offline,omitempty"`
+	Link          bool   `json:"link,omitempty"`
+	Topic         string `json:"topic,omitempty"`
+	Status        string `json:"status,omitempty"`
+}
+
+// exportJob 一个导出任务，导出文件保存在 <ExportDir>/<id>，结束后任务信息保存在 <ExportDir>/<id>.json
+type exportJob struct {
+	ID       string         `json:"id"`
+	Status   string         `json:"status"`
+	Request  exportRequest  `json:"request"`
+	Created  time.Time      `json:"created"`
+	Finished time.Time      `json:"finished,omitzero"`
+	Result   *export.Result `json:"result,omitempty"`
+	Error    string         `json:"error,omitempty"`
+
+	// Locked 创建时提供了口令，导出内容可能包含锁定的会话，查看与下载同样需要口令
+	Locked bool `json:"locked,omitempty"`
+
+	// 已知的压缩包大小，ETag 变化后失效
+	zipETag string
+	zipSize int64
+}
+
+// exportJobs 导出任务，同一时间只运行一个导出，其余任务排队
+type exportJobs struct {
+	mu   sync.Mutex
+	run  sync.Mutex
+	jobs map[string]*exportJob
+}
+
+func (e *exportJobs) get(dir, id string) (exportJob, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if job, ok := e.jobs[id]; ok {
+		return *job, true
+	}
+	// 服务重启前完成的任务
+	b, err := os.ReadFile(filepath.Join(dir, id+".json"))
+	if err != nil {
+		return exportJob{}, false
+	}
+	var job exportJob
+	if err := json.Unmarshal(b, &job); err != nil || job.ID != id {
+		return exportJob{}, false
+	}
+	if e.jobs == nil {
+		e.jobs = make(map[string]*exportJob)
+	}
+	e.jobs[id] = &job
+	return job, true
+}
+
+func (e *exportJobs) update(id string, fn func(job *exportJob)) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if job, ok := e.jobs[id]; ok {
+		fn(job)
+	}
+}
+
+// CreateExport 创建导出任务，立即返回任务信息，导出完成后通过 download 下载
+func (s *Service) CreateExport(c *gin.Context) {
+	if s.exporter == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "export is not available"})
+		return
+	}
+	var req exportRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		errors.Err(c, errors.InvalidArg("body"))
+		return
+	}
+	req.Format = strings.ToLower(req.Format)
+	switch req.Format {
+	case "":
+		req.Format = export.FormatJSON
+	case export.FormatText, export.FormatJSON, export.FormatJSONL, export.FormatCSV, export.FormatGallery, export.FormatObsidian, export.FormatMarkdown, export.FormatVoice, export.FormatHTML, export.FormatAttachments:
+	default:
+		errors.Err(c, errors.InvalidArg("format"))
+		return
+	}
+	if err := export.ValidateNameTemplate(req.NameTemplate); err != nil {
+		errors.Err(c, errors.InvalidArg("name_template"))
+		return
+	}
+
+	if !s.checkTalkers(c, req.Talker) {
+		return
+	}
+	// 未提供口令时跳过锁定的会话
+	var exclude []string
+	locked := len(s.ctx.LockedTalkerList()) > 0
+	if locked && !s.unlocked(c) {
+		exclude, locked = s.ctx.LockedTalkerList(), false
+	}
+
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		errors.Err(c, err)
+		return
+	}
+	job := &exportJob{
+		ID:      hex.EncodeToString(b),
+		Status:  ExportPending,
+		Request: req,
+		Created: time.Now(),
+		Locked:  locked,
+	}
+	dir := s.ctx.ExportDir
+	s.exports.mu.Lock()
+	if s.exports.jobs == nil {
+		s.exports.jobs = make(map[string]*exportJob)
+	}
+	s.exports.jobs[job.ID] = job
+	resp := *job
+	s.exports.mu.Unlock()
+
+	go s.runExport(dir, job.ID, req, exclude)
+
+	c.JSON(http.StatusAccepted, resp)
+}
+
+func (s *Service) runExport(dir, id string, req exportRequest, exclude []string) {
+	s.exports.run.Lock()
+	defer s.exports.run.Unlock()
+	s.exports.update(id, func(job *exportJob) { job.Status = ExportRunning })
+
+	result, err := s.exporter.Export(export.Options{
+		Talker:        req.Talker,
+		Time:          req.Time,
+		Format:        req.Format,
+		Dest:          filepath.Join(dir, id),
+		After:         req.After,
+		NormalizeTime: req.NormalizeTime,
+		Lang:          req.Lang,
+		VoiceFormat:   req.VoiceFormat,
+		MP3: silk.Options{
+			SampleRate: req.MP3SampleRate,
+			Bitrate:    req.MP3Bitrate,
+			Channels:   req.MP3Channels,
+			VBR:        req.MP3VBR,
+		},
+		NameTemplate:   req.NameTemplate,
+		Inline:         req.Inline,
+		Offline:        req.Offline,
+		Link:           req.Link,
+		Topic:          req.Topic,
+		Status:         req.Status,
+		ExcludeTalkers: exclude,
+	})
+
+	var done exportJob
+	s.exports.update(id, func(job *exportJob) {
+		job.Finished = time.Now()
+		if err != nil {
+			job.Status, job.Error = ExportFailed, err.Error()
+		} else {
+			job.Status, job.Result = ExportDone, result
+		}
+		done = *job
+	})
+	if err != nil {
+		log.Err(err).Msgf("export %s failed", id)
+	}
+	if err := saveExportJob(dir, done); err != nil {
+		log.Err(err).Msgf("failed to save export %s", id)
+	}
+}
+
+// saveExportJob 保存结束的任务，服务重启后仍可下载
+func saveExportJob(dir string, job exportJob) error {
+	b, err := json.MarshalIndent(job, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, job.ID+".json"), b, 0644)
+}
+
+// ListExports 返回全部导出任务，新任务在前
+func (s *Service) ListExports(c *gin.Context) {
+	dir := s.ctx.ExportDir
+	ids := make(map[string]bool)
+	s.exports.mu.Lock()
+	for id := range s.exports.jobs {
+		ids[id] = true
+	}
+	s.exports.mu.Unlock()
+	if entries, err := os.ReadDir(dir); err == nil {
+		for _, e := range entries {
+			if id, ok := strings.CutSuffix(e.Name(), ".json"); ok && exportIDPattern.MatchString(id) {
+				ids[id] = true
+			}
+		}
+	}
+
+	unlocked := s.unlocked(c)
+	items := make([]exportJob, 0, len(ids))
+	for id := range ids {
+		if job, ok := s.exports.get(dir, id); ok && (unlocked || !job.Locked) {
+			items = append(items, job)
+		}
+	}
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].Created.After(items[j].Created)
+	})
+	c.JSON(http.StatusOK, gin.H{"items": items})
+}
+
+// exportJobOf 返回路径参数 id 对应的任务，找不到时返回 404，锁定的任务未提供口令时返回 403
+func (s *Service) exportJobOf(c *gin.Context) (exportJob, bool) {
+	id := c.Param("id")
+	if exportIDPattern.MatchString(id) {
+		if job, ok := s.exports.get(s.ctx.ExportDir, id); ok {
+			if job.Locked && !s.unlocked(c) {
+				errors.Err(c, errors.ErrTalkerLocked)
+				return exportJob{}, false
+			}
+			return job, true
+		}
+	}
+	errors.Err(c, errors.New(nil, http.StatusNotFound, "export not found"))
+	return exportJob{}, false
+}
+
+// GetExport 返回导出任务的状态
+func (s *Service) GetExport(c *gin.Context) {
+	if job, ok := s.exportJobOf(c); ok {
+		c.JSON(http.StatusOK, job)
+	}
+}
+
+// DeleteExport 删除已结束的导出任务及其文件
+func (s *Service) DeleteExport(c *gin.Context) {
+	job, ok := s.exportJobOf(c)
+	if !ok {
+		return
+	}
+	if job.Status == ExportPending || job.Status == ExportRunning {
+		c.JSON(http.StatusConflict, gin.H{"error": "export is running"})
+		return
+	}
+	dir := s.ctx.ExportDir
+	if err := os.RemoveAll(filepath.Join(dir, job.ID)); err != nil {
+		errors.Err(c, err)
+		return
+	}
+	os.Remove(filepath.Join(dir, job.ID+".json"))
+	s.exports.mu.Lock()
+	delete(s.exports.jobs, job.ID)
+	s.exports.mu.Unlock()
+	c.Status(http.StatusNoContent)
+}
+
+// DownloadExport 将导出文件边压缩边以 zip 输出，支持 Range 断点续传
+// 压缩包内容由文件列表唯一确定，续传时重新生成并跳过已下载的部分，ETag 变化时 If-Range 不匹配，返回完整内容
+func (s *Service) DownloadExport(c *gin.Context) {
+	job, ok := s.exportJobOf(c)
+	if !ok {
+		return
+	}
+	if job.Status != ExportDone {
+		c.JSON(http.StatusConflict, gin.H{"error": "export is " + job.Status})
+		return
+	}
+	archive, err := zipstream.New(filepath.Join(s.ctx.ExportDir, job.ID))
+	if err != nil {
+		errors.Err(c, err)
+		return
+	}
+	etag := archive.ETag()
+
+	c.Header("ETag", etag)
+	c.Header("Accept-Ranges", "bytes")
+	c.Header("Content-Type", "application/zip")
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="chatlog-export-%s.zip"`, job.ID))
+
+	size := int64(-1)
+	if job.zipETag == etag {
+		size = job.zipSize
+	}
+	knownSize := func() (int64, error) {
+		if size >= 0 {
+			return size, nil
+		}
+		n, err := archive.Size()
+		if err != nil {
+			return 0, err
+		}
+		s.setExportSize(job.ID, etag, n)
+		return n, nil
+	}
+
+	rng := c.GetHeader("Range")
+	if ifRange := c.GetHeader("If-Range"); ifRange != "" && ifRange != etag {
+		rng = ""
+	}
+	start, end, ok := parseRange(rng)
+	// HEAD 与续传需要完整大小
+	if ok || c.Request.Method == http.MethodHead {
+		if size, err = knownSize(); err != nil {
+			errors.Err(c, err)
+			return
+		}
+	}
+	if !ok {
+		if size >= 0 {
+			c.Header("Content-Length", strconv.FormatInt(size, 10))
+		}
+		c.Status(http.StatusOK)
+		if c.Request.Method == http.MethodHead {
+			return
+		}
+		n, err := archive.WriteTo(throttle.Writer(c.Writer))
+		if err != nil {
+			log.Debug().Err(err).Msgf("download export %s interrupted", job.ID)
+			return
+		}
+		s.setExportSize(job.ID, etag, n)
+		return
+	}
+
+	// bytes=-N 表示最后 N 个字节
+	if start < 0 {
+		start, end = max(size+start, 0), size-1
+	}
+	if end < 0 || end >= size {
+		end = size - 1
+	}
+	if start >= size || start > end {
+		c.Header("Content-Range", fmt.Sprintf("bytes */%d", size))
+		c.Status(http.StatusRequestedRangeNotSatisfiable)
+		return
+	}
+	c.Header("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, size))
+	c.Header("Content-Length", strconv.FormatInt(end-start+1, 10))
+	c.Status(http.StatusPartialContent)
+	if c.Request.Method == http.MethodHead {
+		return
+	}
+	w := &zipstream.RangeWriter{W: throttle.Writer(c.Writer), Offset: start, Length: end - start + 1}
+	if _, err := archive.WriteTo(w); err != nil && err != zipstream.ErrRangeDone {
+		log.Debug().Err(err).Msgf("download export %s interrupted", job.ID)
+	}
+}
+
+func (s *Service) setExportSize(id, etag string, size int64) {
+	s.exports.update(id, func(job *exportJob) {
+		job.zipETag, job.zipSize = etag, size
+	})
+}
+
+// parseRange 解析单个字节范围，bytes=N-、bytes=N-M 与 bytes=-N，
+// 后者返回 start 为 -N；end 为 -1 表示到末尾，多个范围不支持，按完整请求处理
+func parseRange(header string) (start, end int64, ok bool) {
+	spec, found := strings.CutPrefix(header, "bytes=")
+	if !found || strings.Contains(spec, ",") {
+		return 0, 0, false
+	}
+	from, to, found := strings.Cut(strings.TrimSpace(spec), "-")
+	if !found {
+		return 0, 0, false
+	}
+	if from == "" {
+		n, err := strconv.ParseInt(to, 10, 64)
+		if err != nil || n <= 0 {
+			return 0, 0, false
+		}
+		return -n, -1, true
+	}
+	start, err := strconv.ParseInt(from, 10, 64)
+	if err != nil || start < 0 {
+		return 0, 0, false
+	}
+	if to == "" {
+		return start, -1, true
+	}
+	end, err = strconv.ParseInt(to, 10, 64)
+	if err != nil || end < start {
+		return 0, 0, false
+	}
+	return start, end, true
+}
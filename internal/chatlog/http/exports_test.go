@@ -0,0 +1,103 @@
+package http
+
+import (
+	"archive/zip"
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aspnmy/chatlog/internal/chatlog/ctx"
+)
+
+func TestParseRange(t *testing.T) {
+	for header, want := range map[string][3]int64{
+		"bytes=0-":     {0, -1, 1},
+		"bytes=10-19":  {10, 19, 1},
+		"bytes=-5":     {-5, -1, 1},
+		"bytes=5-1":    {0, 0, 0},
+		"bytes=0-1,3-": {0, 0, 0},
+		"items=0-":     {0, 0, 0},
+	} {
+		start, end, ok := parseRange(header)
+		got := [3]int64{start, end, 0}
+		if ok {
+			got[2] = 1
+		}
+		if got != want {
+			t.Errorf("parseRange(%q) = %v, want %v", header, got, want)
+		}
+	}
+}
+
+func TestDownloadExport(t *testing.T) {
+	dir := t.TempDir()
+	id := "0123456789abcdef"
+	files := map[string]string{
+		"a.txt":       strings.Repeat("hello chatlog\n", 2000),
+		"sub/b.json":  `{"items":[]}`,
+		"img/pic.jpg": strings.Repeat("\xff", 5000),
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, id, name)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := saveExportJob(dir, exportJob{ID: id, Status: ExportDone, Created: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+
+	s := NewService(&ctx.Context{ExportDir: dir}, nil, nil)
+	get := func(header map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/exports/"+id+"/download", nil)
+		for k, v := range header {
+			req.Header.Set(k, v)
+		}
+		w := httptest.NewRecorder()
+		s.GetRouter().ServeHTTP(w, req)
+		return w
+	}
+
+	full := get(nil)
+	if full.Code != http.StatusOK {
+		t.Fatalf("status = %d", full.Code)
+	}
+	body := full.Body.Bytes()
+	zr, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(zr.File) != len(files) {
+		t.Errorf("zip has %d files, want %d", len(zr.File), len(files))
+	}
+
+	// 续传的部分与完整下载的对应部分相同
+	etag := full.Header().Get("ETag")
+	part := get(map[string]string{"Range": "bytes=100-", "If-Range": etag})
+	if part.Code != http.StatusPartialContent {
+		t.Fatalf("range status = %d", part.Code)
+	}
+	if !bytes.Equal(part.Body.Bytes(), body[100:]) {
+		t.Error("resumed bytes differ from the full download")
+	}
+	if got := part.Header().Get("Content-Range"); got == "" || !strings.HasSuffix(got, "/"+strconv.Itoa(len(body))) {
+		t.Errorf("Content-Range = %q", got)
+	}
+
+	if w := get(map[string]string{"Range": "bytes=100-", "If-Range": `"stale"`}); w.Code != http.StatusOK {
+		t.Errorf("stale If-Range status = %d", w.Code)
+	}
+	if w := get(map[string]string{"Range": "bytes=99999999-"}); w.Code != http.StatusRequestedRangeNotSatisfiable {
+		t.Errorf("unsatisfiable range status = %d", w.Code)
+	}
+	if w := get(map[string]string{"Range": "bytes=-10"}); !bytes.Equal(w.Body.Bytes(), body[len(body)-10:]) {
+		t.Error("suffix range mismatch")
+	}
+}
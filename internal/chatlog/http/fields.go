@@ -0,0 +1,94 @@
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/aspnmy/chatlog/internal/errors"
+	"github.com/aspnmy/chatlog/pkg/util"
+
+	"github.com/gin-gonic/gin"
+)
+
+// fieldAliases 字段别名，便于按通用的名称选择消息字段
+var fieldAliases = map[string]string{
+	"id":         "seq",
+	"createtime": "time",
+}
+
+// formatOf 返回响应格式，指定了 fields 但未指定 format 时使用 json
+func formatOf(format, fields string) string {
+	if format == "" && fields != "" {
+		return "json"
+	}
+	return strings.ToLower(format)
+}
+
+// writeJSON 输出 JSON 响应，fields 不为空时只保留其中的字段（以英文逗号分隔，不区分大小写）
+// 带 items 的列表响应裁剪每一项，total 等其他字段保持不变
+func writeJSON(c *gin.Context, v any, fields string) {
+	if fields == "" {
+		c.JSON(http.StatusOK, v)
+		return
+	}
+	out, err := selectFields(v, util.Str2List(fields, ","))
+	if err != nil {
+		errors.Err(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, out)
+}
+
+// selectFields 将 v 序列化后只保留 fields 中的字段，未知字段忽略
+func selectFields(v any, fields []string) (any, error) {
+	keep := make(map[string]bool, len(fields))
+	for _, f := range fields {
+		f = strings.ToLower(strings.TrimSpace(f))
+		if alias, ok := fieldAliases[f]; ok {
+			f = alias
+		}
+		keep[f] = true
+	}
+
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	// 使用 json.Number 保留 seq 等 int64 的精度
+	var data any
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	if err := dec.Decode(&data); err != nil {
+		return nil, err
+	}
+
+	switch d := data.(type) {
+	case []any:
+		pruneAll(d, keep)
+	case map[string]any:
+		if items, ok := d["items"].([]any); ok {
+			pruneAll(items, keep)
+		} else {
+			prune(d, keep)
+		}
+	}
+	return data, nil
+}
+
+func pruneAll(items []any, keep map[string]bool) {
+	for _, item := range items {
+		if obj, ok := item.(map[string]any); ok {
+			prune(obj, keep)
+		}
+	}
+}
+
+func prune(obj map[string]any, keep map[string]bool) {
+	for k := range obj {
+		if !keep[strings.ToLower(k)] {
+			delete(obj, k)
+		}
+	}
+}
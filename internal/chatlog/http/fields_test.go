@@ -0,0 +1,34 @@
+package http
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/aspnmy/chatlog/internal/model"
+	"github.com/aspnmy/chatlog/internal/wechatdb"
+)
+
+func TestSelectFields(t *testing.T) {
+	messages := []*model.Message{
+		{Seq: 1700000000123, Time: time.Unix(1700000000, 0).UTC(), Talker: "wxid_a", Sender: "wxid_b", Content: "hello", Type: 1},
+	}
+	out, err := selectFields(messages, []string{"id", "Talker", "content", "createTime", "unknown"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := json.Marshal(out)
+	if want := `[{"content":"hello","seq":1700000000123,"talker":"wxid_a","time":"2023-11-14T22:13:20Z"}]`; string(b) != want {
+		t.Fatalf("got %s, want %s", b, want)
+	}
+
+	contacts := &wechatdb.GetContactsResp{Items: []*model.Contact{{UserName: "wxid_a", NickName: "A", Remark: "a"}}}
+	out, err = selectFields(contacts, []string{"userName", "nickName"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ = json.Marshal(out)
+	if want := `{"items":[{"nickName":"A","userName":"wxid_a"}]}`; string(b) != want {
+		t.Fatalf("got %s, want %s", b, want)
+	}
+}
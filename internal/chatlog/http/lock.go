@@ -0,0 +1,79 @@
+package http
+
+import (
+	"crypto/sha256"
+	"slices"
+
+	"github.com/aspnmy/chatlog/internal/chatlog/conf"
+	"github.com/aspnmy/chatlog/internal/errors"
+	"github.com/aspnmy/chatlog/internal/model"
+
+	"github.com/gin-gonic/gin"
+)
+
+// LockHeader 查看锁定的会话时在请求头中提供的口令
+const LockHeader = "X-Chatlog-Passphrase"
+
+// unlocked 返回请求是否可以查看锁定的会话：未锁定任何会话，或请求头中的口令正确
+// 校验过的口令按哈希缓存，避免每个请求都计算 scrypt；错误的口令不缓存，每次都需要完整计算
+func (s *Service) unlocked(c *gin.Context) bool {
+	if len(s.ctx.LockedTalkerList()) == 0 {
+		return true
+	}
+	hash := s.ctx.LockHash
+	passphrase := c.GetHeader(LockHeader)
+	if passphrase == "" || hash == "" {
+		return false
+	}
+	sum := sha256.Sum256([]byte(hash + "\x00" + passphrase))
+
+	s.lockMu.Lock()
+	ok := s.unlockedSums[sum]
+	s.lockMu.Unlock()
+	if ok {
+		return true
+	}
+	if !conf.CheckLockPassphrase(hash, passphrase) {
+		return false
+	}
+	s.lockMu.Lock()
+	if s.unlockedSums == nil {
+		s.unlockedSums = make(map[[32]byte]bool)
+	}
+	s.unlockedSums[sum] = true
+	s.lockMu.Unlock()
+	return true
+}
+
+// checkTalkers 请求指定了锁定的会话且未提供正确的口令时返回 403，talker 可以是 ID、备注名或昵称，多个以英文逗号分隔
+func (s *Service) checkTalkers(c *gin.Context, talker string) bool {
+	if talker == "" || s.unlocked(c) {
+		return true
+	}
+	for _, t := range s.db.ResolveTalkers(talker) {
+		if s.ctx.Locked(t) {
+			errors.Err(c, errors.ErrTalkerLocked)
+			return false
+		}
+	}
+	return true
+}
+
+// hidden 返回请求中需要隐藏的会话，可以查看锁定的会话时返回 nil
+func (s *Service) hidden(c *gin.Context) func(talker string) bool {
+	if s.unlocked(c) {
+		return nil
+	}
+	return s.ctx.Locked
+}
+
+// hideLocked 去掉锁定会话中的消息，用于未指定会话的查询
+func (s *Service) hideLocked(c *gin.Context, messages []*model.Message) []*model.Message {
+	hidden := s.hidden(c)
+	if hidden == nil {
+		return messages
+	}
+	return slices.DeleteFunc(messages, func(m *model.Message) bool {
+		return hidden(m.Talker)
+	})
+}
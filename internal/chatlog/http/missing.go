@@ -0,0 +1,72 @@
+package http
+
+import (
+	"cmp"
+	"net/http"
+	"slices"
+
+	"github.com/aspnmy/chatlog/internal/chatlog/database"
+	"github.com/aspnmy/chatlog/internal/errors"
+	"github.com/aspnmy/chatlog/pkg/util"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetMissingMedia 检查图片、视频与文件消息的媒体文件是否存在，返回缺失的消息并加入重新检查的队列
+// talker 为空时检查所有会话，time 默认为全部时间，type 为 image、video、file，多个以英文逗号分隔，为空时检查全部
+func (s *Service) GetMissingMedia(c *gin.Context) {
+	q := struct {
+		Talker string `form:"talker"`
+		Time   string `form:"time"`
+		Type   string `form:"type"`
+	}{}
+	if err := c.BindQuery(&q); err != nil {
+		errors.Err(c, err)
+		return
+	}
+	start, end, ok := util.TimeRangeOf(cmp.Or(q.Time, "all"))
+	if !ok {
+		errors.Err(c, errors.InvalidArg("time"))
+		return
+	}
+	if !s.checkTalkers(c, q.Talker) {
+		return
+	}
+
+	report, err := s.db.FindMissingMedia(q.Talker, util.Str2List(q.Type, ","), start, end, s.hidden(c))
+	if err != nil {
+		errors.Err(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+// GetMissingMediaQueue 返回等待重新检查的缺失媒体
+func (s *Service) GetMissingMediaQueue(c *gin.Context) {
+	queue, err := s.db.MissingMediaQueue()
+	if err != nil {
+		errors.Err(c, err)
+		return
+	}
+	if hidden := s.hidden(c); hidden != nil {
+		queue.Items = slices.DeleteFunc(queue.Items, func(m *database.MissingMedia) bool {
+			return hidden(m.Talker)
+		})
+	}
+	c.JSON(http.StatusOK, queue)
+}
+
+// RecheckMissingMedia 立即重新检查缺失媒体队列，返回本次找到的数量与仍然缺失的数量
+func (s *Service) RecheckMissingMedia(c *gin.Context) {
+	recovered, err := s.db.RecheckMissingMedia()
+	if err != nil {
+		errors.Err(c, err)
+		return
+	}
+	queue, err := s.db.MissingMediaQueue()
+	if err != nil {
+		errors.Err(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"recovered": recovered, "remaining": len(queue.Items)})
+}
@@ -1,20 +1,27 @@
 package http
 
 import (
+	"cmp"
 	"embed"
 	"fmt"
 	"io/fs"
 	"net/http"
 	"os"
 	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
 
+	"github.com/aspnmy/chatlog/internal/chatlog/database"
 	"github.com/aspnmy/chatlog/internal/errors"
+	"github.com/aspnmy/chatlog/internal/model"
 	"github.com/aspnmy/chatlog/pkg/util"
 	"github.com/aspnmy/chatlog/pkg/util/dat2img"
 	"github.com/aspnmy/chatlog/pkg/util/silk"
 
 	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
 )
 
 // EFS holds embedded file system data for static assets.
@@ -38,6 +45,8 @@ func (s *Service) initRouter() {
 	router.GET("/voice/*key", s.GetVoice)
 	router.GET("/data/*path", s.GetMediaData)
 
+	router.GET("/healthz", s.Healthz)
+
 	// MCP Server
 	{
 		router.GET("/sse", s.mcp.HandleSSE)
@@ -51,9 +60,44 @@ func (s *Service) initRouter() {
 	api := router.Group("/api/v1")
 	{
 		api.GET("/chatlog", s.GetChatlog)
+		api.GET("/search", s.Search)
+		api.POST("/messages/batch", s.GetMessagesBatch)
+		api.GET("/messages/poll", s.PollMessages)
+		api.GET("/messages/:id/context", s.GetMessageContext)
+		api.GET("/talker/:id/calendar", s.GetTalkerCalendar)
 		api.GET("/contact", s.GetContacts)
 		api.GET("/chatroom", s.GetChatRooms)
+		api.GET("/chatroom/:id/leaderboard", s.GetChatRoomLeaderboard)
+		api.GET("/topics", s.GetTopics)
+		api.GET("/topics/:name", s.GetTopicMessages)
 		api.GET("/session", s.GetSessions)
+		api.GET("/contacts", s.GetContacts)
+		api.GET("/chatrooms", s.GetChatRooms)
+		api.GET("/sessions", s.GetSessions)
+		api.GET("/media/:id", s.GetMediaByID)
+		api.GET("/media/missing", s.GetMissingMedia)
+		api.GET("/media/missing/queue", s.GetMissingMediaQueue)
+		api.POST("/media/missing/recheck", s.RecheckMissingMedia)
+
+		api.POST("/exports", s.CreateExport)
+		api.GET("/exports", s.ListExports)
+		api.GET("/exports/:id", s.GetExport)
+		api.DELETE("/exports/:id", s.DeleteExport)
+		api.GET("/exports/:id/download", s.DownloadExport)
+		api.HEAD("/exports/:id/download", s.DownloadExport)
+	}
+
+	// 管理接口，需要 Authorization: Bearer <admin_token>
+	admin := router.Group("/api/v1/admin", s.adminAuth())
+	{
+		admin.GET("/status", s.AdminStatus)
+		admin.POST("/sync", s.adminAction(Admin.SyncNow))
+		admin.POST("/sync/pause", s.adminAction(Admin.PauseSync))
+		admin.POST("/sync/resume", s.adminAction(Admin.ResumeSync))
+		admin.POST("/key/rotate", s.adminAction(Admin.RotateKey))
+		admin.POST("/config/reload", s.adminAction(Admin.ReloadConfig))
+		admin.GET("/accounts", s.AdminAccounts)
+		admin.POST("/accounts/switch", s.AdminSwitchAccount)
 	}
 
 	router.NoRoute(s.NoRoute)
@@ -76,12 +120,17 @@ func (s *Service) GetChatlog(c *gin.Context) {
 
 	q := struct {
 		Time    string `form:"time"`
+		Start   string `form:"start"`
+		End     string `form:"end"`
 		Talker  string `form:"talker"`
 		Sender  string `form:"sender"`
 		Keyword string `form:"keyword"`
 		Limit   int    `form:"limit"`
 		Offset  int    `form:"offset"`
 		Format  string `form:"format"`
+		Fields  string `form:"fields"`
+		Lang    string `form:"lang"`
+		Status  string `form:"status"`
 	}{}
 
 	if err := c.BindQuery(&q); err != nil {
@@ -89,10 +138,10 @@ func (s *Service) GetChatlog(c *gin.Context) {
 		return
 	}
 
-	var err error
-	start, end, ok := util.TimeRangeOf(q.Time)
-	if !ok {
-		errors.Err(c, errors.InvalidArg("time"))
+	start, end, err := timeRangeOf(q.Time, q.Start, q.End)
+	if err != nil {
+		errors.Err(c, err)
+		return
 	}
 	if q.Limit < 0 {
 		q.Limit = 0
@@ -102,17 +151,62 @@ func (s *Service) GetChatlog(c *gin.Context) {
 		q.Offset = 0
 	}
 
-	messages, err := s.db.GetMessages(start, end, q.Talker, q.Sender, q.Keyword, q.Limit, q.Offset)
-	if err != nil {
-		errors.Err(c, err)
+	if !s.checkTalkers(c, q.Talker) {
 		return
 	}
 
-	switch strings.ToLower(q.Format) {
+	if q.Status != "" && !model.ValidStatus(q.Status) {
+		errors.Err(c, errors.InvalidArg("status"))
+		return
+	}
+
+	// 指定 cursor 参数时按游标分页（为空表示从头开始），忽略 offset，见 model.Cursor
+	var page *database.MessagePage
+	var messages []*model.Message
+	if cursor, ok := c.GetQuery("cursor"); ok {
+		after, err := model.ParseCursor(cursor)
+		if err != nil {
+			errors.Err(c, errors.InvalidArg("cursor"))
+			return
+		}
+		page, err = s.db.GetMessagesAfter(start, end, q.Talker, q.Sender, q.Keyword, after, q.Limit)
+		if err != nil {
+			errors.Err(c, err)
+			return
+		}
+		page.Items = s.hideLocked(c, page.Items)
+		// 按状态筛选时游标仍指向本页最后读取的消息，筛选后本页可能少于 limit 条
+		if q.Status != "" {
+			page.Items = database.FilterStatus(page.Items, q.Status, nil)
+		}
+		messages = page.Items
+		c.Header(CursorHeader, page.Cursor)
+	} else if q.Status != "" {
+		messages, err = s.db.GetStatusMessages(start, end, q.Talker, q.Sender, q.Keyword, q.Status, s.hidden(c), q.Limit, q.Offset)
+		if err != nil {
+			errors.Err(c, err)
+			return
+		}
+	} else {
+		messages, err = s.db.GetMessages(start, end, q.Talker, q.Sender, q.Keyword, q.Limit, q.Offset)
+		if err != nil {
+			errors.Err(c, err)
+			return
+		}
+		messages = s.hideLocked(c, messages)
+	}
+	s.db.ResolveQuotes(messages)
+	s.translate(c, messages, q.Lang)
+
+	switch formatOf(q.Format, q.Fields) {
 	case "csv":
 	case "json":
 		// json
-		c.JSON(http.StatusOK, messages)
+		if page != nil {
+			writeJSON(c, page, q.Fields)
+			return
+		}
+		writeJSON(c, messages, q.Fields)
 	default:
 		// plain text
 		c.Writer.Header().Set("Content-Type", "text/plain; charset=utf-8")
@@ -128,6 +222,469 @@ func (s *Service) GetChatlog(c *gin.Context) {
 	}
 }
 
+// timeRangeOf 返回查询的时间范围，指定 start 或 end 时从 start 的开始时间到 end 的结束时间，
+// 未指定的一端不限，否则使用 time；time 不能与 start、end 同时使用
+func timeRangeOf(timeRange, startStr, endStr string) (start, end time.Time, err error) {
+	if startStr == "" && endStr == "" {
+		start, end, ok := util.TimeRangeOf(timeRange)
+		if !ok {
+			return start, end, errors.InvalidArg("time")
+		}
+		return start, end, nil
+	}
+	if timeRange != "" {
+		return start, end, errors.Newf(nil, http.StatusBadRequest, "time cannot be used with start or end")
+	}
+
+	start, end, _ = util.TimeRangeOf("all")
+	if startStr != "" {
+		var ok bool
+		if start, _, ok = util.TimeRangeOf(startStr); !ok {
+			return start, end, errors.InvalidArg("start")
+		}
+	}
+	if endStr != "" {
+		var ok bool
+		if _, end, ok = util.TimeRangeOf(endStr); !ok {
+			return start, end, errors.InvalidArg("end")
+		}
+	}
+	if start.After(end) {
+		return start, end, errors.Newf(nil, http.StatusBadRequest, "start %s is after end %s", startStr, endStr)
+	}
+	return start, end, nil
+}
+
+// Search 搜索消息，返回带高亮区间的摘要
+func (s *Service) Search(c *gin.Context) {
+
+	q := struct {
+		Keyword string `form:"keyword"`
+		Time    string `form:"time"`
+		Talker  string `form:"talker"`
+		Sender  string `form:"sender"`
+		Type    string `form:"type"`
+		Snippet int    `form:"snippet"`
+		Limit   int    `form:"limit"`
+		Offset  int    `form:"offset"`
+		Format  string `form:"format"`
+		Fields  string `form:"fields"`
+	}{}
+
+	if err := c.BindQuery(&q); err != nil {
+		errors.Err(c, err)
+		return
+	}
+
+	// 未指定时间范围时搜索全部消息
+	start, end, ok := util.TimeRangeOf(cmp.Or(q.Time, "all"))
+	if !ok {
+		errors.Err(c, errors.InvalidArg("time"))
+		return
+	}
+	if q.Limit <= 0 {
+		q.Limit = DefaultSearchLimit
+	}
+	if q.Offset < 0 {
+		q.Offset = 0
+	}
+
+	if !s.checkTalkers(c, q.Talker) {
+		return
+	}
+
+	req := database.SearchReq{
+		Start:      start,
+		End:        end,
+		Talker:     q.Talker,
+		Sender:     q.Sender,
+		Keyword:    q.Keyword,
+		SnippetLen: q.Snippet,
+		Limit:      q.Limit,
+		Offset:     q.Offset,
+		Hidden:     s.hidden(c),
+	}
+	for _, t := range util.Str2List(q.Type, ",") {
+		v, err := strconv.ParseInt(t, 10, 64)
+		if err != nil {
+			errors.Err(c, errors.InvalidArg("type"))
+			return
+		}
+		req.Types = append(req.Types, v)
+	}
+
+	resp, err := s.db.Search(req)
+	if err != nil {
+		errors.Err(c, err)
+		return
+	}
+
+	switch formatOf(q.Format, q.Fields) {
+	case "json":
+		writeJSON(c, resp, q.Fields)
+	default:
+		c.Writer.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		c.Writer.Header().Set("Cache-Control", "no-cache")
+		timeFormat := util.PerfectTimeFormat(start, end)
+		for _, hit := range resp.Items {
+			c.Writer.WriteString(fmt.Sprintf("[%d] %s %s %s\n%s\n\n",
+				hit.Seq, hit.Time.Format(timeFormat), cmp.Or(hit.TalkerName, hit.Talker), cmp.Or(hit.SenderName, hit.Sender), hit.Snippet.Mark("**", "**")))
+		}
+	}
+}
+
+// GetMessageContext 获取消息前后的上下文，id 为消息的 seq
+func (s *Service) GetMessageContext(c *gin.Context) {
+
+	q := struct {
+		Talker string `form:"talker"`
+		Before *int   `form:"before"`
+		After  *int   `form:"after"`
+		Format string `form:"format"`
+		Fields string `form:"fields"`
+		Lang   string `form:"lang"`
+	}{}
+
+	if err := c.BindQuery(&q); err != nil {
+		errors.Err(c, err)
+		return
+	}
+
+	seq, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		errors.Err(c, errors.InvalidArg("id"))
+		return
+	}
+	if q.Talker == "" {
+		errors.Err(c, errors.ErrTalkerEmpty)
+		return
+	}
+	if !s.checkTalkers(c, q.Talker) {
+		return
+	}
+	before, after := DefaultContextSize, DefaultContextSize
+	if q.Before != nil {
+		before = min(max(*q.Before, 0), MaxContextSize)
+	}
+	if q.After != nil {
+		after = min(max(*q.After, 0), MaxContextSize)
+	}
+
+	messages, err := s.db.GetMessageContext(q.Talker, seq, before, after)
+	if err != nil {
+		errors.Err(c, err)
+		return
+	}
+	s.translate(c, messages, q.Lang)
+
+	switch formatOf(q.Format, q.Fields) {
+	case "json":
+		writeJSON(c, messages, q.Fields)
+	default:
+		c.Writer.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		c.Writer.Header().Set("Cache-Control", "no-cache")
+		for _, m := range messages {
+			c.Writer.WriteString(m.PlainText(false, "", c.Request.Host))
+			c.Writer.WriteString("\n")
+		}
+	}
+}
+
+// GetMessagesBatch 按 ID 批量获取消息，供语义搜索、书签等功能一次取回多条消息
+func (s *Service) GetMessagesBatch(c *gin.Context) {
+
+	q := struct {
+		Fields string `form:"fields"`
+		Lang   string `form:"lang"`
+	}{}
+	if err := c.BindQuery(&q); err != nil {
+		errors.Err(c, err)
+		return
+	}
+
+	var req struct {
+		IDs []model.MessageID `json:"ids"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		errors.Err(c, errors.InvalidArg("ids"))
+		return
+	}
+	if len(req.IDs) > MaxBatchSize {
+		errors.Err(c, errors.Newf(nil, http.StatusBadRequest, "too many ids, at most %d", MaxBatchSize))
+		return
+	}
+	ids := make([]model.MessageID, 0, len(req.IDs))
+	seen := make(map[model.MessageID]bool, len(req.IDs))
+	for _, id := range req.IDs {
+		if id.Talker == "" {
+			errors.Err(c, errors.ErrTalkerEmpty)
+			return
+		}
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	talkers := make([]string, 0, len(ids))
+	for _, id := range ids {
+		talkers = append(talkers, id.Talker)
+	}
+	if !s.checkTalkers(c, strings.Join(talkers, ",")) {
+		return
+	}
+
+	messages, missing, err := s.db.GetMessagesByID(ids)
+	if err != nil {
+		errors.Err(c, err)
+		return
+	}
+	if missing == nil {
+		missing = []model.MessageID{}
+	}
+	s.translate(c, messages, q.Lang)
+	writeJSON(c, gin.H{"items": messages, "missing": missing}, q.Fields)
+}
+
+// PollMessages 长轮询获取新消息，用于代理拦截了 SSE 与 WebSocket 的环境
+// 有新消息时立即返回，否则等待同步完成或 wait 超时后返回空列表；未指定 since 时从当前时间开始
+func (s *Service) PollMessages(c *gin.Context) {
+
+	q := struct {
+		Since  string `form:"since"`
+		Wait   string `form:"wait"`
+		Limit  int    `form:"limit"`
+		Fields string `form:"fields"`
+	}{}
+	if err := c.BindQuery(&q); err != nil {
+		errors.Err(c, err)
+		return
+	}
+
+	cursor := model.CursorAt(time.Now())
+	if q.Since != "" {
+		v, err := model.ParseCursor(q.Since)
+		if err != nil {
+			errors.Err(c, errors.InvalidArg("since"))
+			return
+		}
+		cursor = v
+	}
+	wait := DefaultPollWait
+	if q.Wait != "" {
+		d, err := parseWait(q.Wait)
+		if err != nil {
+			errors.Err(c, errors.InvalidArg("wait"))
+			return
+		}
+		wait = min(d, MaxPollWait)
+	}
+	if q.Limit <= 0 {
+		q.Limit = DefaultPollLimit
+	}
+
+	deadline := time.NewTimer(wait)
+	defer deadline.Stop()
+	// 数据可能由其他进程解密写入，没有同步通知时也定期检查
+	ticker := time.NewTicker(PollInterval)
+	defer ticker.Stop()
+	for {
+		synced := s.ctx.SyncSignal()
+		resp, err := s.db.Changes(cursor, q.Limit)
+		if err != nil {
+			errors.Err(c, err)
+			return
+		}
+		resp.Items = s.hideLocked(c, resp.Items)
+		if len(resp.Items) > 0 || wait <= 0 {
+			writeJSON(c, resp, q.Fields)
+			return
+		}
+		select {
+		case <-synced:
+		case <-ticker.C:
+		case <-deadline.C:
+			writeJSON(c, resp, q.Fields)
+			return
+		case <-c.Request.Context().Done():
+			return
+		}
+	}
+}
+
+// translate 在指定 lang 参数时填入消息的译文，并在响应头中返回仍没有译文的文字消息数
+// 翻译失败不影响返回原文
+func (s *Service) translate(c *gin.Context, messages []*model.Message, lang string) {
+	if lang == "" {
+		return
+	}
+	missing, err := s.db.Translate(c.Request.Context(), messages, lang, false)
+	if err != nil {
+		log.Err(err).Msgf("failed to translate messages to %s", lang)
+	}
+	c.Header(UntranslatedHeader, strconv.Itoa(missing))
+}
+
+// parseWait 解析等待时间，支持 30s、1m 等格式，不带单位时按秒计算
+func parseWait(s string) (time.Duration, error) {
+	if n, err := strconv.Atoi(s); err == nil {
+		s = strconv.Itoa(n) + "s"
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, errors.InvalidArg("wait")
+	}
+	return d, nil
+}
+
+// GetTalkerCalendar 获取会话每天、每周、每月的消息数量，用于日历热力图
+func (s *Service) GetTalkerCalendar(c *gin.Context) {
+
+	q := struct {
+		Time string `form:"time"`
+	}{}
+
+	if err := c.BindQuery(&q); err != nil {
+		errors.Err(c, err)
+		return
+	}
+
+	talker := c.Param("id")
+	if talker == "" {
+		errors.Err(c, errors.ErrTalkerEmpty)
+		return
+	}
+	start, end, ok := util.TimeRangeOf(cmp.Or(q.Time, "all"))
+	if !ok {
+		errors.Err(c, errors.InvalidArg("time"))
+		return
+	}
+
+	if !s.checkTalkers(c, talker) {
+		return
+	}
+
+	resp, err := s.db.GetCalendar(talker, start, end)
+	if err != nil {
+		errors.Err(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// GetChatRoomLeaderboard 获取群聊成员按消息数、图片数或字数的排行榜
+// range 为时间范围，格式同 /api/v1/chatlog 的 time 参数，也可以使用 time，默认为全部
+func (s *Service) GetChatRoomLeaderboard(c *gin.Context) {
+
+	q := struct {
+		Metric string `form:"metric"`
+		Range  string `form:"range"`
+		Time   string `form:"time"`
+		Limit  int    `form:"limit"`
+	}{}
+
+	if err := c.BindQuery(&q); err != nil {
+		errors.Err(c, err)
+		return
+	}
+
+	talker := c.Param("id")
+	start, end, ok := util.TimeRangeOf(cmp.Or(q.Range, q.Time, "all"))
+	if !ok {
+		errors.Err(c, errors.InvalidArg("range"))
+		return
+	}
+
+	if !s.checkTalkers(c, talker) {
+		return
+	}
+
+	resp, err := s.db.GetLeaderboard(talker, cmp.Or(q.Metric, database.LeaderboardMessages), start, end, cmp.Or(q.Limit, 20))
+	if err != nil {
+		errors.Err(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// GetTopics 统计时间范围内各话题标签的消息数，话题与标签的约定见配置文件中的 topics
+func (s *Service) GetTopics(c *gin.Context) {
+
+	q := struct {
+		Time   string `form:"time"`
+		Talker string `form:"talker"`
+	}{}
+
+	if err := c.BindQuery(&q); err != nil {
+		errors.Err(c, err)
+		return
+	}
+
+	start, end, ok := util.TimeRangeOf(cmp.Or(q.Time, "all"))
+	if !ok {
+		errors.Err(c, errors.InvalidArg("time"))
+		return
+	}
+
+	if !s.checkTalkers(c, q.Talker) {
+		return
+	}
+
+	resp, err := s.db.GetTopics(start, end, q.Talker, s.hidden(c))
+	if err != nil {
+		errors.Err(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// GetTopicMessages 获取带有话题任一标签的消息，name 为话题名称或标签（可省略 #）
+func (s *Service) GetTopicMessages(c *gin.Context) {
+
+	q := struct {
+		Time   string `form:"time"`
+		Talker string `form:"talker"`
+		Sender string `form:"sender"`
+		Limit  int    `form:"limit"`
+		Offset int    `form:"offset"`
+		Format string `form:"format"`
+		Fields string `form:"fields"`
+	}{}
+
+	if err := c.BindQuery(&q); err != nil {
+		errors.Err(c, err)
+		return
+	}
+
+	start, end, ok := util.TimeRangeOf(cmp.Or(q.Time, "all"))
+	if !ok {
+		errors.Err(c, errors.InvalidArg("time"))
+		return
+	}
+
+	if !s.checkTalkers(c, q.Talker) {
+		return
+	}
+
+	messages, err := s.db.GetTopicMessages(start, end, q.Talker, q.Sender, c.Param("name"), s.hidden(c), q.Limit, q.Offset)
+	if err != nil {
+		errors.Err(c, err)
+		return
+	}
+
+	switch formatOf(q.Format, q.Fields) {
+	case "json":
+		writeJSON(c, messages, q.Fields)
+	default:
+		c.Writer.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		c.Writer.Header().Set("Cache-Control", "no-cache")
+		timeFormat := util.PerfectTimeFormat(start, end)
+		for _, m := range messages {
+			c.Writer.WriteString(m.PlainText(true, timeFormat, c.Request.Host))
+			c.Writer.WriteString("\n")
+		}
+	}
+}
+
 func (s *Service) GetContacts(c *gin.Context) {
 
 	q := struct {
@@ -135,6 +692,7 @@ func (s *Service) GetContacts(c *gin.Context) {
 		Limit   int    `form:"limit"`
 		Offset  int    `form:"offset"`
 		Format  string `form:"format"`
+		Fields  string `form:"fields"`
 	}{}
 
 	if err := c.BindQuery(&q); err != nil {
@@ -148,11 +706,11 @@ func (s *Service) GetContacts(c *gin.Context) {
 		return
 	}
 
-	format := strings.ToLower(q.Format)
+	format := formatOf(q.Format, q.Fields)
 	switch format {
 	case "json":
 		// json
-		c.JSON(http.StatusOK, list)
+		writeJSON(c, list, q.Fields)
 	default:
 		// csv
 		if format == "csv" {
@@ -165,9 +723,9 @@ func (s *Service) GetContacts(c *gin.Context) {
 		c.Writer.Header().Set("Connection", "keep-alive")
 		c.Writer.Flush()
 
-		c.Writer.WriteString("UserName,Alias,Remark,NickName\n")
+		c.Writer.WriteString("UserName,Alias,Remark,NickName,Avatar\n")
 		for _, contact := range list.Items {
-			c.Writer.WriteString(fmt.Sprintf("%s,%s,%s,%s\n", contact.UserName, contact.Alias, contact.Remark, contact.NickName))
+			c.Writer.WriteString(fmt.Sprintf("%s,%s,%s,%s,%s\n", contact.UserName, contact.Alias, contact.Remark, contact.NickName, contact.Avatar))
 		}
 		c.Writer.Flush()
 	}
@@ -180,6 +738,7 @@ func (s *Service) GetChatRooms(c *gin.Context) {
 		Limit   int    `form:"limit"`
 		Offset  int    `form:"offset"`
 		Format  string `form:"format"`
+		Fields  string `form:"fields"`
 	}{}
 
 	if err := c.BindQuery(&q); err != nil {
@@ -192,11 +751,11 @@ func (s *Service) GetChatRooms(c *gin.Context) {
 		errors.Err(c, err)
 		return
 	}
-	format := strings.ToLower(q.Format)
+	format := formatOf(q.Format, q.Fields)
 	switch format {
 	case "json":
 		// json
-		c.JSON(http.StatusOK, list)
+		writeJSON(c, list, q.Fields)
 	default:
 		// csv
 		if format == "csv" {
@@ -224,6 +783,7 @@ func (s *Service) GetSessions(c *gin.Context) {
 		Limit   int    `form:"limit"`
 		Offset  int    `form:"offset"`
 		Format  string `form:"format"`
+		Fields  string `form:"fields"`
 	}{}
 
 	if err := c.BindQuery(&q); err != nil {
@@ -236,7 +796,12 @@ func (s *Service) GetSessions(c *gin.Context) {
 		errors.Err(c, err)
 		return
 	}
-	format := strings.ToLower(q.Format)
+	if hidden := s.hidden(c); hidden != nil {
+		sessions.Items = slices.DeleteFunc(sessions.Items, func(session *model.Session) bool {
+			return hidden(session.UserName)
+		})
+	}
+	format := formatOf(q.Format, q.Fields)
 	switch format {
 	case "csv":
 		c.Writer.Header().Set("Content-Type", "text/csv; charset=utf-8")
@@ -251,7 +816,7 @@ func (s *Service) GetSessions(c *gin.Context) {
 		c.Writer.Flush()
 	case "json":
 		// json
-		c.JSON(http.StatusOK, sessions)
+		writeJSON(c, sessions, q.Fields)
 	default:
 		c.Writer.Header().Set("Content-Type", "text/plain; charset=utf-8")
 		c.Writer.Header().Set("Cache-Control", "no-cache")
@@ -328,6 +893,43 @@ func (s *Service) GetMedia(c *gin.Context, _type string) {
 	}
 }
 
+// mediaTypes /api/v1/media/:id 未指定 type 时依次查找的媒体类型
+var mediaTypes = []string{"image", "video", "file", "voice"}
+
+// GetMediaByID 按数据库中的媒体 ID 返回媒体内容，type 参数指定类型，未指定时依次在 mediaTypes 中查找
+// 返回方式与 /image、/voice 等相同：语音直接返回，其他类型跳转到 /data，info 参数不为空时返回媒体信息
+func (s *Service) GetMediaByID(c *gin.Context) {
+	id := c.Param("id")
+	types := mediaTypes
+	if t := c.Query("type"); t != "" {
+		if !slices.Contains(mediaTypes, t) {
+			errors.Err(c, errors.InvalidArg("type"))
+			return
+		}
+		types = []string{t}
+	}
+
+	var _err error
+	for _, t := range types {
+		media, err := s.db.GetMedia(t, id)
+		if err != nil {
+			_err = err
+			continue
+		}
+		if c.Query("info") != "" {
+			c.JSON(http.StatusOK, media)
+			return
+		}
+		if media.Type == "voice" {
+			s.HandleVoice(c, media.Data)
+			return
+		}
+		c.Redirect(http.StatusFound, "/data/"+media.Path)
+		return
+	}
+	errors.Err(c, _err)
+}
+
 func (s *Service) GetMediaData(c *gin.Context) {
 	relativePath := filepath.Clean(c.Param("path"))
 
@@ -389,3 +991,12 @@ func (s *Service) HandleVoice(c *gin.Context, data []byte) {
 	}
 	c.Data(http.StatusOK, "audio/mp3", out)
 }
+
+// Healthz 返回服务状态，只读快照模式下同时返回当前快照的版本
+func (s *Service) Healthz(c *gin.Context) {
+	resp := gin.H{"status": "ok"}
+	if _, info := s.ctx.CurrentSnapshot(); info != nil {
+		resp["snapshot"] = info
+	}
+	c.JSON(http.StatusOK, resp)
+}
@@ -0,0 +1,96 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/aspnmy/chatlog/internal/chatlog/conf"
+	"github.com/aspnmy/chatlog/internal/chatlog/ctx"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestParseWait(t *testing.T) {
+	for s, want := range map[string]time.Duration{"30": 30 * time.Second, "1m": time.Minute, "0": 0} {
+		if d, err := parseWait(s); err != nil || d != want {
+			t.Errorf("parseWait(%q) = %v, %v", s, d, err)
+		}
+	}
+	for _, s := range []string{"-1s", "abc"} {
+		if _, err := parseWait(s); err == nil {
+			t.Errorf("parseWait(%q) succeeded", s)
+		}
+	}
+}
+
+func TestPollMessagesArgs(t *testing.T) {
+	s := NewService(&ctx.Context{}, nil, nil)
+	for _, query := range []string{"since=abc", "wait=forever"} {
+		w := httptest.NewRecorder()
+		s.GetRouter().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/messages/poll?"+query, nil))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d", query, w.Code)
+		}
+	}
+}
+
+func TestUnlocked(t *testing.T) {
+	hash, err := conf.HashLockPassphrase("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewService(&ctx.Context{}, nil, nil)
+	request := func(passphrase string) *gin.Context {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest(http.MethodGet, "/api/v1/chatlog", nil)
+		if passphrase != "" {
+			c.Request.Header.Set(LockHeader, passphrase)
+		}
+		return c
+	}
+	if !s.unlocked(request("")) {
+		t.Error("locked without locked talkers")
+	}
+
+	s.ctx.LockedTalkers = map[string]bool{"wxid_a": true}
+	s.ctx.LockHash = hash
+	for passphrase, want := range map[string]bool{"": false, "wrong": false, "secret": true} {
+		// 第二次使用缓存的结果
+		for range 2 {
+			if got := s.unlocked(request(passphrase)); got != want {
+				t.Errorf("unlocked(%q) = %v", passphrase, got)
+			}
+		}
+	}
+	if hidden := s.hidden(request("")); hidden == nil || !hidden("wxid_a") || hidden("wxid_b") {
+		t.Error("locked talker not hidden")
+	}
+}
+
+func TestChatlogTimeRange(t *testing.T) {
+	start, end, err := timeRangeOf("", "2024-01-01", "2024-01-31")
+	if err != nil || !start.Equal(time.Date(2024, 1, 1, 0, 0, 0, 0, time.Local)) ||
+		!end.Equal(time.Date(2024, 1, 31, 23, 59, 59, 999999999, time.Local)) {
+		t.Errorf("timeRangeOf(start, end) = %s, %s, %v", start, end, err)
+	}
+	if start, end, err := timeRangeOf("", "2024-01-01", ""); err != nil || start.Year() != 2024 || end.Year() != 9999 {
+		t.Errorf("timeRangeOf(start) = %s, %s, %v", start, end, err)
+	}
+
+	s := NewService(&ctx.Context{}, nil, nil)
+	for _, query := range []string{
+		"start=2024-02-01&end=2024-01-01",
+		"start=abc",
+		"end=abc",
+		"time=2024&start=2024-01-01",
+		"talker=wxid_a",
+	} {
+		w := httptest.NewRecorder()
+		s.GetRouter().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/chatlog?"+query, nil))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d", query, w.Code)
+		}
+	}
+}
@@ -3,12 +3,14 @@ package http
 import (
 	"context"
 	"net/http"
+	"sync"
 	"time"
 
 	"github.com/aspnmy/chatlog/internal/chatlog/ctx"
 	"github.com/aspnmy/chatlog/internal/chatlog/database"
 	"github.com/aspnmy/chatlog/internal/chatlog/mcp"
 	"github.com/aspnmy/chatlog/internal/errors"
+	"github.com/aspnmy/chatlog/pkg/trace"
 
 	"github.com/gin-gonic/gin"
 	"github.com/rs/zerolog/log"
@@ -16,6 +18,28 @@ import (
 
 const (
 	DefalutHTTPAddr = "127.0.0.1:5030"
+
+	// 消息上下文接口的默认与最大条数
+	DefaultContextSize = 20
+	MaxContextSize     = 500
+
+	// 搜索接口未指定 limit 时返回的条数
+	DefaultSearchLimit = 100
+
+	// 批量获取消息接口一次最多的消息数
+	MaxBatchSize = 500
+
+	// CursorHeader 按游标分页时在响应头中返回下一次读取使用的游标，纯文本格式也可以获取
+	CursorHeader = "X-Chatlog-Cursor"
+
+	// UntranslatedHeader 指定 lang 参数时在响应头中返回还没有译文的文字消息数
+	UntranslatedHeader = "X-Chatlog-Untranslated"
+
+	// 长轮询接口的默认与最长等待时间、检查新数据的间隔及每次返回的消息数
+	DefaultPollWait  = 30 * time.Second
+	MaxPollWait      = 120 * time.Second
+	PollInterval     = 5 * time.Second
+	DefaultPollLimit = 500
 )
 
 type Service struct {
@@ -23,6 +47,18 @@ type Service struct {
 	db  *database.Service
 	mcp *mcp.Service
 
+	// 管理接口，见 SetAdmin
+	admin   Admin
+	adminMu sync.Mutex
+
+	// 校验通过的锁定口令，见 unlocked
+	unlockedSums map[[32]byte]bool
+	lockMu       sync.Mutex
+
+	// 导出接口，见 SetExporter
+	exporter Exporter
+	exports  exportJobs
+
 	router *gin.Engine
 	server *http.Server
 }
@@ -41,6 +77,7 @@ func NewService(ctx *ctx.Context, db *database.Service, mcp *mcp.Service) *Servi
 		errors.RecoveryMiddleware(),
 		errors.ErrorHandlerMiddleware(),
 		gin.LoggerWithWriter(log.Logger),
+		traceMiddleware(),
 	)
 
 	s := &Service{
@@ -114,3 +151,23 @@ func (s *Service) Stop() error {
 func (s *Service) GetRouter() *gin.Engine {
 	return s.router
 }
+
+// traceMiddleware 为每个请求记录一个 span，未开启 trace 时直接跳过
+func traceMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !trace.Enabled() {
+			c.Next()
+			return
+		}
+		ctx, span := trace.Start(c.Request.Context(), "http "+c.Request.Method+" "+c.FullPath())
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+		span.SetAttr("http.method", c.Request.Method).
+			SetAttr("http.target", c.Request.URL.Path).
+			SetAttr("http.status_code", c.Writer.Status())
+		if len(c.Errors) > 0 {
+			span.SetError(c.Errors.Last())
+		}
+		span.End()
+	}
+}
@@ -0,0 +1,64 @@
+package chatlog
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/aspnmy/chatlog/internal/errors"
+	"github.com/aspnmy/chatlog/internal/wechatdb/datasource/imported"
+)
+
+// jsonlMessageSize 估算导入消息数时每条消息在 JSONL 中的平均字节数
+const jsonlMessageSize = 256
+
+// CommandImport 将 JSONL 格式导出的消息导入工作目录，之后查询、搜索与导出时与微信数据合并
+// 文件中每行一条消息，格式与 chatlog export -f jsonl 相同；同一会话中 seq 相同的消息覆盖之前导入的
+// dedup 为 true 时跳过与已导入消息内容相同的消息，用于合并多台设备导出的同一会话，见 imported.DataSource.EnableDedup
+func (m *Manager) CommandImport(workDir string, files []string, dedup bool) (*imported.ImportResult, error) {
+	if workDir == "" {
+		workDir = m.ctx.WorkDir
+	}
+	if workDir == "" {
+		return nil, fmt.Errorf("workDir is required")
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("jsonl file is required")
+	}
+	if err := os.MkdirAll(workDir, 0755); err != nil {
+		return nil, err
+	}
+
+	ds, err := imported.Open(workDir)
+	if err != nil {
+		return nil, err
+	}
+	defer ds.Close()
+
+	if dedup {
+		var size int64
+		for _, file := range files {
+			if info, err := os.Stat(file); err == nil {
+				size += info.Size()
+			}
+		}
+		if err := ds.EnableDedup(context.Background(), int(size/jsonlMessageSize)); err != nil {
+			return nil, err
+		}
+	}
+
+	result := &imported.ImportResult{}
+	for _, file := range files {
+		f, err := os.Open(file)
+		if err != nil {
+			return result, errors.OpenFileFailed(file, err)
+		}
+		err = ds.Import(context.Background(), file, f, result)
+		f.Close()
+		if err != nil {
+			return result, fmt.Errorf("import %s: %w", file, err)
+		}
+	}
+	// 布隆过滤器在关闭时写回，失败时下次去重导入会重建
+	return result, ds.Close()
+}
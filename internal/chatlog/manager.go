@@ -1,18 +1,34 @@
 package chatlog
 
 import (
+	"cmp"
 	"context"
 	"fmt"
+	"os"
 	"path/filepath"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/aspnmy/chatlog/internal/chatlog/conf"
 	"github.com/aspnmy/chatlog/internal/chatlog/ctx"
 	"github.com/aspnmy/chatlog/internal/chatlog/database"
+	"github.com/aspnmy/chatlog/internal/chatlog/export"
 	"github.com/aspnmy/chatlog/internal/chatlog/http"
 	"github.com/aspnmy/chatlog/internal/chatlog/mcp"
+	"github.com/aspnmy/chatlog/internal/chatlog/snapshot"
 	"github.com/aspnmy/chatlog/internal/chatlog/wechat"
+	"github.com/aspnmy/chatlog/internal/errors"
 	iwechat "github.com/aspnmy/chatlog/internal/wechat"
+	"github.com/aspnmy/chatlog/internal/wechat/decrypt"
+	"github.com/aspnmy/chatlog/internal/wechat/key"
+	"github.com/aspnmy/chatlog/internal/wechat/key/windows"
+	"github.com/aspnmy/chatlog/internal/wechat/model"
+	"github.com/aspnmy/chatlog/pkg/keybag"
+	"github.com/aspnmy/chatlog/pkg/keystore"
+	"github.com/aspnmy/chatlog/pkg/mail"
+	"github.com/aspnmy/chatlog/pkg/memscan"
+	"github.com/aspnmy/chatlog/pkg/search"
 	"github.com/aspnmy/chatlog/pkg/util"
 	"github.com/aspnmy/chatlog/pkg/util/dat2img"
 	"github.com/rs/zerolog/log"
@@ -28,9 +44,17 @@ type Manager struct {
 	http   *http.Service
 	mcp    *mcp.Service
 	wechat *wechat.Service
+	export *export.Service
 
 	// Terminal UI
 	app *App
+
+	// 周报，见 EnableDigest
+	digestForced bool
+	digestStop   chan struct{}
+
+	// chatlog daemon 推送新消息的 Webhook，见 startWebhooks
+	webhookStop chan struct{}
 }
 
 func New(configPath string) (*Manager, error) {
@@ -41,8 +65,20 @@ func New(configPath string) (*Manager, error) {
 		return nil, err
 	}
 
+	// 记录密钥搜索策略的命中次数，下次提取时优先执行
+	key.StatsFile = conf.GetConfig().KeyStatsPath()
+
+	// 加密缓存提取到的密钥，下次运行验证通过后直接使用
+	if !conf.GetConfig().NoKeyCache {
+		iwechat.KeyStore = keystore.Open(conf.GetConfig().KeyStorePath())
+	}
+	wechat.IncrementalDecrypt = conf.GetConfig().IncrementalDecrypt
+
 	// 创建应用上下文
 	ctx := ctx.New(conf)
+	if archiveBuild || ArchiveOnly || conf.GetConfig().ArchiveOnly {
+		ctx.SetArchiveOnly()
+	}
 
 	wechat := wechat.NewService(ctx)
 
@@ -52,21 +88,41 @@ func New(configPath string) (*Manager, error) {
 
 	http := http.NewService(ctx, db, mcp)
 
-	return &Manager{
+	export := export.NewService(ctx, db)
+
+	m := &Manager{
 		conf:   conf,
 		ctx:    ctx,
 		db:     db,
 		mcp:    mcp,
 		http:   http,
 		wechat: wechat,
-	}, nil
+		export: export,
+	}
+	http.SetAdmin(m)
+	http.SetExporter(export)
+	m.setTranslator()
+	if err := m.useAccount(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// setTranslator 按 translate 配置设置翻译服务，配置有误时只记录日志，已保存的译文仍可使用
+func (m *Manager) setTranslator() {
+	c := m.conf.GetConfig()
+	t, err := c.Translator()
+	if err != nil {
+		log.Err(err).Msg("failed to create translator")
+	}
+	m.db.SetTranslator(t, t != nil && c.Translate.OnDemand)
 }
 
 func (m *Manager) Run() error {
 
 	m.ctx.WeChatInstances = m.wechat.GetWeChatInstances()
-	if len(m.ctx.WeChatInstances) >= 1 {
-		m.ctx.SwitchCurrent(m.ctx.WeChatInstances[0])
+	if ins := accountInstance(m.ctx.WeChatInstances, Account); ins != nil {
+		m.ctx.SwitchCurrent(ins)
 	}
 
 	if m.ctx.HTTPEnabled {
@@ -132,6 +188,8 @@ func (m *Manager) StartService() error {
 		go dat2img.ScanAndSetXorKey(m.ctx.DataDir)
 	}
 
+	m.startDigest()
+
 	// 更新状态
 	m.ctx.SetHTTPEnabled(true)
 
@@ -153,6 +211,8 @@ func (m *Manager) stopService() error {
 	// 按依赖的反序停止服务
 	var errs []error
 
+	m.stopDigest()
+
 	if err := m.http.Stop(); err != nil {
 		errs = append(errs, err)
 	}
@@ -263,18 +323,26 @@ func (m *Manager) RefreshSession() error {
 	return nil
 }
 
-func (m *Manager) CommandKey(pid int) (string, error) {
+// CommandKey 提取微信密钥，progress 不为 nil 时报告内存扫描进度
+func (m *Manager) CommandKey(pid int, progress memscan.ProgressFunc) (string, error) {
+	if m.ctx.ArchiveOnly {
+		return "", errors.ErrArchiveOnly
+	}
 	instances := m.wechat.GetWeChatInstances()
 	if len(instances) == 0 {
-		return "", fmt.Errorf("wechat process not found")
+		return "", fmt.Errorf("wechat process not found, run \"chatlog doctor\" to see why")
 	}
+	c := key.WithProgress(context.Background(), progress)
 	if len(instances) == 1 {
-		key, _, err := instances[0].GetKey(context.Background())
+		key, _, err := instances[0].GetKey(c)
 		if err != nil {
 			return "", err
 		}
 		return key, nil
 	}
+	if ins := accountInstance(instances, Account); pid == 0 && Account != "" && ins != nil {
+		pid = int(ins.PID)
+	}
 	if pid == 0 {
 		str := "Select a process:\n"
 		for _, ins := range instances {
@@ -284,7 +352,7 @@ func (m *Manager) CommandKey(pid int) (string, error) {
 	}
 	for _, ins := range instances {
 		if ins.PID == uint32(pid) {
-			key, _, err := ins.GetKey(context.Background())
+			key, _, err := ins.GetKey(c)
 			if err != nil {
 				return "", err
 			}
@@ -294,7 +362,81 @@ func (m *Manager) CommandKey(pid int) (string, error) {
 	return "", fmt.Errorf("wechat process not found")
 }
 
+// CommandDoctor 诊断提取密钥的环境，说明无法提取的原因，如权限不足、微信运行在沙盒或虚拟机中
+func (m *Manager) CommandDoctor() []iwechat.Finding {
+	return iwechat.Diagnose()
+}
+
+// CommandKeyRegions 返回提取密钥时会扫描的内存区域，存在多个微信进程时需要指定 pid
+func (m *Manager) CommandKeyRegions(pid int) (*iwechat.Account, []model.MemoryRegion, error) {
+	if m.ctx.ArchiveOnly {
+		return nil, nil, errors.ErrArchiveOnly
+	}
+	instances := m.wechat.GetWeChatInstances()
+	if len(instances) == 0 {
+		return nil, nil, fmt.Errorf("wechat process not found")
+	}
+	var ins *iwechat.Account
+	switch {
+	case pid != 0:
+		for _, i := range instances {
+			if i.PID == uint32(pid) {
+				ins = i
+			}
+		}
+		if ins == nil {
+			return nil, nil, fmt.Errorf("wechat process %d not found", pid)
+		}
+	case len(instances) == 1:
+		ins = instances[0]
+	default:
+		return nil, nil, fmt.Errorf("found %d wechat processes, use --pid to select one", len(instances))
+	}
+	regions, err := ins.Regions()
+	if err != nil {
+		return nil, nil, err
+	}
+	return ins, regions, nil
+}
+
+// CommandKeyBench 在 4.x 进程的内存转储 dump 上依次执行每个已注册的搜索策略，
+// 使用数据目录 dataDir 或加密数据库文件 dbFile 验证候选密钥，pointer32 表示转储来自32位进程
+func (m *Manager) CommandKeyBench(dump string, dataDir string, dbFile string, pointer32 bool) ([]windows.BenchResult, error) {
+	var validator *decrypt.Validator
+	var err error
+	switch {
+	case dbFile != "":
+		validator, err = decrypt.NewValidatorFromDB("windows", 4, dbFile)
+	case dataDir != "":
+		validator, err = decrypt.NewValidator("windows", 4, dataDir)
+	default:
+		return nil, fmt.Errorf("data dir or db file is required to validate keys")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	memory, err := os.ReadFile(dump)
+	if err != nil {
+		return nil, err
+	}
+	ptr := windows.PointerSize64
+	if pointer32 {
+		ptr = windows.PointerSize32
+	}
+	return windows.Bench(context.Background(), memory, validator, ptr), nil
+}
+
+// CommandKeyClearCache 删除本机的密钥缓存，返回缓存文件路径
+func (m *Manager) CommandKeyClearCache() (string, error) {
+	path := m.conf.GetConfig().KeyStorePath()
+	return path, keystore.Open(path).Clear()
+}
+
 func (m *Manager) CommandDecrypt(dataDir string, workDir string, key string, platform string, version int) error {
+	if m.ctx.ArchiveOnly {
+		return errors.ErrArchiveOnly
+	}
 	if dataDir == "" {
 		return fmt.Errorf("dataDir is required")
 	}
@@ -309,6 +451,15 @@ func (m *Manager) CommandDecrypt(dataDir string, workDir string, key string, pla
 	m.ctx.DataKey = key
 	m.ctx.Platform = platform
 	m.ctx.Version = version
+
+	// 仅使用同一数据目录与密钥记录的加密参数
+	m.ctx.Cipher = nil
+	for _, h := range m.ctx.History {
+		if h.DataKey == key && filepath.Clean(h.DataDir) == filepath.Clean(dataDir) && h.Version == version {
+			m.ctx.Cipher = h.Cipher
+			break
+		}
+	}
 	if err := m.wechat.DecryptDBFiles(); err != nil {
 		return err
 	}
@@ -339,6 +490,9 @@ func (m *Manager) CommandHTTPServer(addr string, dataDir string, workDir string,
 	m.ctx.WorkDir = workDir
 	m.ctx.Platform = platform
 	m.ctx.Version = version
+	if m.ctx.ArchiveOnly {
+		m.ctx.DataDir = ctx.ArchiveMediaDir(workDir)
+	}
 
 	// 如果是 4.0 版本，更新下 xorkey
 	if m.ctx.Version == 4 && m.ctx.DataDir != "" {
@@ -354,5 +508,567 @@ func (m *Manager) CommandHTTPServer(addr string, dataDir string, workDir string,
 		return err
 	}
 
+	m.startDigest()
+
+	return m.http.ListenAndServe()
+}
+
+// CommandMCPStdio 通过标准输入输出提供 MCP 服务，供以子进程方式启动 MCP 服务器的客户端使用，
+// 客户端关闭标准输入时返回，标准输出只用于 MCP 消息
+func (m *Manager) CommandMCPStdio(dataDir string, workDir string, platform string, version int) error {
+	if workDir == "" {
+		return fmt.Errorf("workDir is required")
+	}
+
+	if platform == "" {
+		return fmt.Errorf("platform is required")
+	}
+
+	if version == 0 {
+		return fmt.Errorf("version is required")
+	}
+
+	m.ctx.DataDir = dataDir
+	m.ctx.WorkDir = workDir
+	m.ctx.Platform = platform
+	m.ctx.Version = version
+	if m.ctx.ArchiveOnly {
+		m.ctx.DataDir = ctx.ArchiveMediaDir(workDir)
+	}
+
+	if err := m.db.Start(); err != nil {
+		return err
+	}
+	defer m.db.Stop()
+
+	if err := m.mcp.Start(); err != nil {
+		return err
+	}
+
+	return m.mcp.ServeStdio(os.Stdin, os.Stdout)
+}
+
+// SnapshotPollInterval 是只读快照模式下检查新快照的间隔
+const SnapshotPollInterval = 30 * time.Second
+
+// CommandServeSnapshot 以只读方式从快照目录提供 HTTP 服务，
+// 桌面端生成新快照后自动切换，快照版本可通过 /healthz 查看
+func (m *Manager) CommandServeSnapshot(addr string, path string) error {
+	if addr == "" {
+		addr = "127.0.0.1:5030"
+	}
+
+	info, err := snapshot.Read(path)
+	if err != nil {
+		return err
+	}
+
+	m.ctx.HTTPAddr = addr
+	m.ctx.SwitchSnapshot(path, info)
+	m.ctx.Platform = info.Platform
+	m.ctx.Version = info.WeChat
+	m.ctx.Legacy = nil
+
+	if err := m.db.Start(); err != nil {
+		return err
+	}
+	if err := snapshot.MarkServing(path, info.Version); err != nil {
+		log.Warn().Err(err).Msg("failed to mark the snapshot as serving, it may be pruned while in use")
+	}
+
+	if err := m.mcp.Start(); err != nil {
+		return err
+	}
+
+	go m.watchSnapshot()
+
 	return m.http.ListenAndServe()
 }
+
+// watchSnapshot 定期检查快照清单，版本变化时切换到新快照
+// 每次检查时更新正在使用的快照的服务标记，旧快照的连接关闭后才删除其标记，见 snapshot.MarkServing
+func (m *Manager) watchSnapshot() {
+	ticker := time.NewTicker(SnapshotPollInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		root, current := m.ctx.CurrentSnapshot()
+		if err := snapshot.MarkServing(root, current.Version); err != nil {
+			log.Debug().Err(err).Msg("failed to mark the snapshot as serving")
+		}
+		info, err := snapshot.Read(root)
+		if err != nil {
+			log.Debug().Err(err).Msg("failed to read snapshot manifest")
+			continue
+		}
+		if info.Version == current.Version {
+			continue
+		}
+		if err := snapshot.MarkServing(root, info.Version); err != nil {
+			log.Debug().Err(err).Msg("failed to mark the snapshot as serving")
+		}
+		m.ctx.SwitchSnapshot(root, info)
+		if err := m.db.Reload(); err != nil {
+			m.ctx.SwitchSnapshot(root, current)
+			snapshot.UnmarkServing(root, info.Version)
+			log.Err(err).Msgf("failed to switch to snapshot %s", info.Version)
+			continue
+		}
+		time.AfterFunc(database.ReloadCloseDelay, func() { snapshot.UnmarkServing(root, current.Version) })
+		log.Info().Msgf("switched to snapshot %s", info.Version)
+	}
+}
+
+// CommandSnapshot 将工作目录复制为 dest 下的新快照，供 CommandServeSnapshot 使用，
+// notify 为 true 时按 smtp 配置发送摘要邮件
+func (m *Manager) CommandSnapshot(workDir string, dest string, platform string, version int, keep int, notify bool) (*snapshot.Manifest, error) {
+	if workDir == "" {
+		return nil, fmt.Errorf("workDir is required")
+	}
+	if dest == "" {
+		return nil, fmt.Errorf("dest is required")
+	}
+
+	info := snapshot.Manifest{
+		Platform: platform,
+		WeChat:   version,
+	}
+	if h, ok := m.ctx.History[m.ctx.Account]; ok && filepath.Clean(h.WorkDir) == filepath.Clean(workDir) {
+		info.Account = h.Account
+	}
+
+	begin := time.Now()
+	manifest, err := snapshot.Create(workDir, dest, info, keep)
+	if notify {
+		status, detail := "completed", ""
+		if err != nil {
+			status, detail = "failed", fmt.Sprintf("Error: %v\n", err)
+		} else {
+			detail = fmt.Sprintf("Version:  %s\nSize:     %s\nDuration: %s\n", manifest.Version, util.GetDirSize(manifest.Dir(dest)), time.Since(begin).Round(time.Second))
+		}
+		subject := fmt.Sprintf("[chatlog] snapshot %s: %s", status, dest)
+		body := fmt.Sprintf("Snapshot %s at %s\n\nWork dir: %s\nDest:     %s\n%s", status, time.Now().Format(time.DateTime), workDir, dest, detail)
+		m.notify(subject, body, err != nil)
+	}
+	return manifest, err
+}
+
+// ExportProfile 返回配置文件中合并了继承链的导出 profile
+func (m *Manager) ExportProfile(name string) (*conf.ExportProfile, error) {
+	return m.conf.GetConfig().ExportProfile(name)
+}
+
+func (m *Manager) CommandExport(workDir string, platform string, version int, opts export.Options) (*export.Result, error) {
+
+	if workDir == "" {
+		return nil, fmt.Errorf("workDir is required")
+	}
+
+	if opts.Dest == "" {
+		return nil, fmt.Errorf("dest is required")
+	}
+
+	m.ctx.WorkDir = workDir
+	m.ctx.Platform = platform
+	m.ctx.Version = version
+	if opts.DataDir != "" {
+		m.ctx.DataDir = opts.DataDir
+	}
+	if opts.ImgKey != "" {
+		m.ctx.ImgKey = opts.ImgKey
+	}
+	if m.ctx.ArchiveOnly {
+		m.ctx.DataDir = ctx.ArchiveMediaDir(workDir)
+	}
+
+	// 导出相册、笔记库附件或 HTML 中的图片需要解密，4.0 版本先设置图片密钥
+	if (opts.Format == export.FormatGallery || opts.Format == export.FormatObsidian || opts.Format == export.FormatMarkdown || opts.Format == export.FormatHTML) && m.ctx.Version == 4 && m.ctx.DataDir != "" {
+		dat2img.SetAesKey(m.ctx.ImgKey)
+		dat2img.ScanAndSetXorKey(m.ctx.DataDir)
+	}
+
+	if err := m.db.Start(); err != nil {
+		if opts.Notify {
+			subject, body := export.Summary(opts, nil, err)
+			m.notify(subject, body, true)
+		}
+		return nil, err
+	}
+	defer m.db.Stop()
+
+	result, err := m.export.Export(opts)
+	if opts.Notify {
+		subject, body := export.Summary(opts, result, err)
+		m.notify(subject, body, err != nil || len(result.Failed) > 0)
+	}
+	return result, err
+}
+
+// CommandPush 将会话推送到 Notion 或飞书云文档，target 为 conf.PublishNotion 或 conf.PublishFeishu，
+// 令牌等参数从配置文件读取
+func (m *Manager) CommandPush(workDir string, platform string, version int, target string, opts export.Options) (*export.Result, error) {
+	if workDir == "" {
+		return nil, fmt.Errorf("workDir is required")
+	}
+
+	pub, err := m.conf.GetConfig().Publisher(target)
+	if err != nil {
+		return nil, err
+	}
+
+	m.ctx.WorkDir = workDir
+	m.ctx.Platform = platform
+	m.ctx.Version = version
+
+	if err := m.db.Start(); err != nil {
+		return nil, err
+	}
+	defer m.db.Stop()
+
+	return m.export.Publish(context.Background(), pub, opts)
+}
+
+// notify 按 smtp 配置发送摘要邮件，配置了 only_failures 时仅在 failed 为 true 时发送
+func (m *Manager) notify(subject, body string, failed bool) {
+	c := m.conf.GetConfig().SMTP
+	if !c.Enabled() || (c.OnlyFailures && !failed) {
+		return
+	}
+	if err := m.sendMail(subject, body); err != nil {
+		log.Err(err).Msg("failed to send notification email")
+		return
+	}
+	log.Debug().Msgf("notification sent to %v", c.To)
+}
+
+// sendMail 按 smtp 配置发送邮件
+func (m *Manager) sendMail(subject, body string) error {
+	c := m.conf.GetConfig().SMTP
+	if !c.Enabled() {
+		return fmt.Errorf("smtp is not configured")
+	}
+	msg := &mail.Message{
+		From:    cmp.Or(c.From, c.Username),
+		To:      c.To,
+		Subject: subject,
+		Body:    body,
+	}
+	password := cmp.Or(c.Password, os.Getenv(conf.EnvSMTPPassword))
+	return mail.Send(c.Host, cmp.Or(c.Port, conf.DefaultSMTPPort), c.Username, password, msg)
+}
+
+func (m *Manager) CommandIndexRebuild(workDir string, platform string, version int, opts search.Options, restart bool) (*database.IndexResult, error) {
+
+	if workDir == "" {
+		return nil, fmt.Errorf("workDir is required")
+	}
+
+	m.ctx.WorkDir = workDir
+	m.ctx.Platform = platform
+	m.ctx.Version = version
+
+	if err := m.db.Start(); err != nil {
+		return nil, err
+	}
+	defer m.db.Stop()
+
+	return m.db.RebuildIndex(opts, restart)
+}
+
+// CommandIndexUpdate 将新消息写入已建立的搜索索引，索引未建立时返回 search.ErrNotBuilt
+func (m *Manager) CommandIndexUpdate(workDir string, platform string, version int) (*database.IndexResult, error) {
+
+	if workDir == "" {
+		return nil, fmt.Errorf("workDir is required")
+	}
+
+	m.ctx.WorkDir = workDir
+	m.ctx.Platform = platform
+	m.ctx.Version = version
+
+	if err := m.db.Start(); err != nil {
+		return nil, err
+	}
+	defer m.db.Stop()
+
+	return m.db.UpdateIndex()
+}
+
+// CommandSearch 在命令行中搜索消息，已建立索引时通过索引查询，否则逐条匹配；
+// since 为时间范围表达式，搜索其开始时间之后的消息，为空时搜索全部消息
+func (m *Manager) CommandSearch(workDir string, platform string, version int, keyword, talker, sender, since string, limit int) (*database.SearchResp, error) {
+
+	if workDir == "" {
+		return nil, fmt.Errorf("workDir is required")
+	}
+
+	req := database.SearchReq{
+		Talker:  talker,
+		Sender:  sender,
+		Keyword: keyword,
+		Limit:   limit,
+		Hidden:  m.ctx.Locked,
+	}
+	if since != "" {
+		start, _, ok := util.TimeRangeOf(since)
+		if !ok {
+			return nil, fmt.Errorf("invalid since %q", since)
+		}
+		req.Start = start
+	}
+
+	m.ctx.WorkDir = workDir
+	m.ctx.Platform = platform
+	m.ctx.Version = version
+
+	if err := m.db.Start(); err != nil {
+		return nil, err
+	}
+	defer m.db.Stop()
+
+	return m.db.Search(req)
+}
+
+func (m *Manager) CommandTranslate(workDir string, platform string, version int, talker, timeRange, lang string) (*database.TranslateResult, error) {
+
+	if workDir == "" {
+		return nil, fmt.Errorf("workDir is required")
+	}
+
+	m.ctx.WorkDir = workDir
+	m.ctx.Platform = platform
+	m.ctx.Version = version
+
+	if lang == "" && m.conf.GetConfig().Translate != nil {
+		lang = m.conf.GetConfig().Translate.Lang
+	}
+
+	if err := m.db.Start(); err != nil {
+		return nil, err
+	}
+	defer m.db.Stop()
+
+	return m.db.TranslateAll(context.Background(), talker, timeRange, lang)
+}
+
+// LockConfig 返回配置文件中锁定的会话，返回的是副本，修改后通过 CommandLock 保存
+func (m *Manager) LockConfig() *conf.LockConfig {
+	lock := &conf.LockConfig{}
+	if old := m.conf.GetConfig().Lock; old != nil {
+		lock.Talkers = slices.Clone(old.Talkers)
+		lock.Passphrase = old.Passphrase
+	}
+	return lock
+}
+
+// CommandLock 锁定 add 中的会话并解除 remove 中的会话，passphrase 不为空时设置新的口令，返回修改后的配置
+// 锁定会话时必须已设置口令
+func (m *Manager) CommandLock(add, remove []string, passphrase string) (*conf.LockConfig, error) {
+	lock := m.LockConfig()
+	lock.Talkers = append(lock.Talkers, add...)
+	lock.Talkers = slices.DeleteFunc(lock.Talkers, func(t string) bool {
+		return slices.Contains(remove, t)
+	})
+	if passphrase != "" {
+		hash, err := conf.HashLockPassphrase(passphrase)
+		if err != nil {
+			return nil, err
+		}
+		lock.Passphrase = hash
+	}
+	if lock.Enabled() && lock.Passphrase == "" {
+		return nil, fmt.Errorf("lock passphrase is not set")
+	}
+	if err := m.conf.GetConfig().SetLock(lock); err != nil {
+		return nil, err
+	}
+	return lock, nil
+}
+
+// MigrateOptions 是 CommandMigrate 的参数
+type MigrateOptions struct {
+	Roots   []string // 查找数据目录的上级目录，为空时使用系统默认位置
+	Account string   // 账号，为空时使用当前账号
+
+	// 3.x 的数据目录与解密后的工作目录，用于无法自动识别的情况
+	LegacyDir     string
+	LegacyWorkDir string
+
+	Link bool // 将 3.x 的数据关联到 4.x 账号
+}
+
+// MigrateResult 是 CommandMigrate 的结果
+type MigrateResult struct {
+	Account string
+	Dirs    []iwechat.DataDir
+	Linked  *conf.LegacyConfig
+}
+
+// CommandMigrate 查找 3.x 与 4.x 的数据目录并识别当前账号，
+// Link 为 true 时将 3.x 的数据关联到 4.x 账号，之后两者合并为一条时间线
+func (m *Manager) CommandMigrate(opts MigrateOptions) (*MigrateResult, error) {
+	if m.ctx.ArchiveOnly {
+		return nil, errors.ErrArchiveOnly
+	}
+	roots := opts.Roots
+	if len(roots) == 0 {
+		roots = iwechat.DataRoots()
+	}
+	result := &MigrateResult{Dirs: iwechat.LocateDataDirs(roots)}
+
+	result.Account = opts.Account
+	if result.Account == "" && m.ctx.Account != "" {
+		result.Account = iwechat.NormalizeAccount(m.ctx.Account, m.ctx.Version)
+	}
+	if result.Account == "" {
+		accounts := make(map[string]bool)
+		for _, d := range result.Dirs {
+			accounts[d.Account] = true
+		}
+		if len(accounts) == 1 {
+			result.Account = result.Dirs[0].Account
+		}
+	}
+	if !opts.Link {
+		return result, nil
+	}
+	if result.Account == "" {
+		return nil, fmt.Errorf("account is required, found %d accounts", len(result.Dirs))
+	}
+
+	var v3, v4 *iwechat.DataDir
+	for i, d := range result.Dirs {
+		if d.Account != result.Account {
+			continue
+		}
+		switch d.Version {
+		case 3:
+			v3 = &result.Dirs[i]
+		case 4:
+			v4 = &result.Dirs[i]
+		}
+	}
+	if v4 == nil {
+		return nil, fmt.Errorf("4.x data dir of %s not found", result.Account)
+	}
+	if opts.LegacyDir != "" {
+		v3 = &iwechat.DataDir{Dir: opts.LegacyDir, Platform: v4.Platform, Version: 3}
+	}
+	if v3 == nil {
+		return nil, fmt.Errorf("3.x data dir of %s not found, use --legacy-dir to specify it", result.Account)
+	}
+
+	current, ok := m.ctx.History[v4.Name]
+	if !ok || current.WorkDir == "" {
+		return nil, fmt.Errorf("4.x data of %s has not been decrypted yet", v4.Name)
+	}
+	var legacy *conf.LegacyConfig
+	if opts.LegacyWorkDir != "" {
+		legacy = &conf.LegacyConfig{
+			Platform: v3.Platform,
+			Version:  3,
+			DataDir:  v3.Dir,
+			WorkDir:  opts.LegacyWorkDir,
+		}
+	}
+	for _, h := range m.ctx.History {
+		if legacy != nil {
+			break
+		}
+		// 通过 3.x 数据目录找到解密时记录的工作目录
+		if h.Version == 3 && h.WorkDir != "" && filepath.Clean(h.DataDir) == filepath.Clean(v3.Dir) {
+			legacy = &conf.LegacyConfig{
+				Platform: h.Platform,
+				Version:  h.Version,
+				DataDir:  h.DataDir,
+				WorkDir:  h.WorkDir,
+			}
+			break
+		}
+	}
+	if legacy == nil {
+		return nil, fmt.Errorf("3.x data in %s has not been decrypted yet, run chatlog decrypt first or use --legacy-work-dir", v3.Dir)
+	}
+
+	m.ctx.SwitchHistory(v4.Name)
+	m.ctx.Legacy = legacy
+	m.ctx.UpdateConfig()
+	result.Linked = legacy
+
+	return result, nil
+}
+
+// CommandKeyExport 将已保存的密钥导出为 keybag，account 为空时导出全部账号
+func (m *Manager) CommandKeyExport(account string) (*keybag.Keybag, error) {
+	b := keybag.New()
+	for _, h := range m.conf.GetConfig().History {
+		if h.DataKey == "" || (account != "" && h.Account != account) {
+			continue
+		}
+		k := keybag.Key{
+			Account:     h.Account,
+			Platform:    h.Platform,
+			Version:     h.Version,
+			FullVersion: h.FullVersion,
+			DataDir:     h.DataDir,
+			DataKey:     h.DataKey,
+			ImgKey:      h.ImgKey,
+		}
+		if h.KeyTime > 0 {
+			t := time.Unix(h.KeyTime, 0)
+			k.ExtractedAt = &t
+		}
+		b.Keys = append(b.Keys, k)
+	}
+	if len(b.Keys) == 0 {
+		if account != "" {
+			return nil, fmt.Errorf("no key saved for account %s", account)
+		}
+		return nil, fmt.Errorf("no key saved")
+	}
+	return b, nil
+}
+
+// KeyImportResult 是 CommandKeyImport 的结果
+type KeyImportResult struct {
+	Imported []string
+	Skipped  []string // 已保存不同的密钥且未指定覆盖
+}
+
+// CommandKeyImport 将 keybag 中的密钥保存到对应账号，
+// 账号已保存不同的密钥时，仅在 overwrite 为 true 时覆盖
+func (m *Manager) CommandKeyImport(b *keybag.Keybag, overwrite bool) (*KeyImportResult, error) {
+	history := m.conf.GetConfig().ParseHistory()
+	result := &KeyImportResult{}
+	confs := make([]conf.ProcessConfig, 0, len(b.Keys))
+	for _, k := range b.Keys {
+		h, ok := history[k.Account]
+		if !ok {
+			h = conf.ProcessConfig{Type: "wechat", Account: k.Account}
+		}
+		if h.DataKey != "" && h.DataKey != k.DataKey && !overwrite {
+			result.Skipped = append(result.Skipped, k.Account)
+			continue
+		}
+		h.DataKey = k.DataKey
+		h.ImgKey = cmp.Or(k.ImgKey, h.ImgKey)
+		h.Platform = cmp.Or(h.Platform, k.Platform)
+		h.Version = cmp.Or(h.Version, k.Version)
+		h.FullVersion = cmp.Or(h.FullVersion, k.FullVersion)
+		h.DataDir = cmp.Or(h.DataDir, k.DataDir)
+		h.KeyTime = time.Now().Unix()
+		if k.ExtractedAt != nil {
+			h.KeyTime = k.ExtractedAt.Unix()
+		}
+		confs = append(confs, h)
+		history[k.Account] = h
+		result.Imported = append(result.Imported, k.Account)
+	}
+	if len(confs) == 0 {
+		return result, nil
+	}
+	if err := m.conf.GetConfig().MergeHistory(confs); err != nil {
+		return nil, err
+	}
+	m.ctx.History = history
+	return result, nil
+}
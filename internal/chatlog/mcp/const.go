@@ -185,6 +185,81 @@ var (
 		},
 	}
 
+	ToolQueryMessages = mcp.Tool{
+		Name: "query_messages",
+		Description: `按关键词搜索聊天记录，返回命中消息的序号、时间、会话、发送者和带 ** 高亮的摘要。
+不指定 talker 时在所有会话中搜索（需要先执行 chatlog index rebuild 建立全文索引），未建立索引时必须指定 talker，keyword 为正则表达式。
+适合在不确定对话方或时间范围时定位消息，找到消息后应使用 chatlog 工具按时间点查询前后的完整对话。
+结果较多时使用 limit 与 offset 分页，返回内容的第一行为命中总数。`,
+		InputSchema: mcp.ToolSchema{
+			Type: "object",
+			Properties: mcp.M{
+				"keyword": mcp.M{
+					"type":        "string",
+					"description": "要搜索的关键词，已建立索引时多个词用空格分隔且需同时出现",
+				},
+				"time": mcp.M{
+					"type":        "string",
+					"description": "可选，时间点或时间范围，格式同 chatlog 工具，如 \"2023-04-01~2023-04-18\"、\"last-7d\"，省略时为全部时间",
+				},
+				"talker": mcp.M{
+					"type":        "string",
+					"description": "可选，对话方（联系人或群聊）的ID、昵称或备注名，多个用\",\"分隔",
+				},
+				"sender": mcp.M{
+					"type":        "string",
+					"description": "可选，发送者的ID、昵称或备注名，多个用\",\"分隔",
+				},
+				"limit": mcp.M{
+					"type":        "integer",
+					"description": "最多返回的消息数，默认 50",
+				},
+				"offset": mcp.M{
+					"type":        "integer",
+					"description": "跳过的消息数",
+				},
+			},
+			Required: []string{"keyword"},
+		},
+	}
+
+	ToolListContacts = mcp.Tool{
+		Name:        "list_contacts",
+		Description: "分页列出用户的联系人，返回 UserName,Alias,Remark,NickName 格式的列表。keyword 为空时列出全部联系人，可以用 limit 与 offset 分页。",
+		InputSchema: mcp.ToolSchema{
+			Type: "object",
+			Properties: mcp.M{
+				"keyword": mcp.M{
+					"type":        "string",
+					"description": "可选，按姓名、备注名或ID过滤",
+				},
+				"limit": mcp.M{
+					"type":        "integer",
+					"description": "最多返回的联系人数",
+				},
+				"offset": mcp.M{
+					"type":        "integer",
+					"description": "跳过的联系人数",
+				},
+			},
+		},
+	}
+
+	ToolChatRoomMembers = mcp.Tool{
+		Name:        "get_chatroom_members",
+		Description: "获取群聊的成员列表，返回 UserName,DisplayName 格式的列表，DisplayName 为成员的群昵称。当用户询问某个群里有哪些人、某人在群里的昵称时使用此工具。",
+		InputSchema: mcp.ToolSchema{
+			Type: "object",
+			Properties: mcp.M{
+				"chatroom": mcp.M{
+					"type":        "string",
+					"description": "群聊的ID或名称，需要能唯一确定一个群聊",
+				},
+			},
+			Required: []string{"chatroom"},
+		},
+	}
+
 	ResourceRecentChat = mcp.Resource{
 		Name:        "最近会话",
 		URI:         "session://recent",
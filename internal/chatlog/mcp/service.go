@@ -2,26 +2,34 @@ package mcp
 
 import (
 	"bytes"
+	"cmp"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"net/url"
+	"slices"
 	"strings"
 	"time"
 
 	"github.com/aspnmy/chatlog/internal/chatlog/ctx"
 	"github.com/aspnmy/chatlog/internal/chatlog/database"
 	"github.com/aspnmy/chatlog/internal/mcp"
+	"github.com/aspnmy/chatlog/internal/model"
 	"github.com/aspnmy/chatlog/pkg/util"
 
 	"github.com/gin-gonic/gin"
 )
 
+// DefaultQueryLimit query_messages 未指定 limit 时最多返回的消息数
+const DefaultQueryLimit = 50
+
 type Service struct {
 	ctx *ctx.Context
 	db  *database.Service
 
-	mcp *mcp.MCP
+	mcp  *mcp.MCP
+	done chan struct{}
 }
 
 func NewService(ctx *ctx.Context, db *database.Service) *Service {
@@ -39,6 +47,7 @@ func (s *Service) GetMCP() *mcp.MCP {
 // Start 启动MCP服务
 func (s *Service) Start() error {
 	s.mcp = mcp.NewMCP()
+	s.done = make(chan struct{})
 	go s.worker()
 	return nil
 }
@@ -53,6 +62,7 @@ func (s *Service) Stop() error {
 
 // worker 处理MCP请求
 func (s *Service) worker() {
+	defer close(s.done)
 	for {
 		select {
 		case p, ok := <-s.mcp.ProcessChan:
@@ -72,6 +82,14 @@ func (s *Service) HandleMessages(c *gin.Context) {
 	s.mcp.HandleMessages(c)
 }
 
+// ServeStdio 通过标准输入输出提供 MCP 服务，客户端关闭标准输入后处理完已收到的请求再返回
+func (s *Service) ServeStdio(r io.Reader, w io.Writer) error {
+	err := s.mcp.ServeStdio(r, w)
+	s.Stop()
+	<-s.done
+	return err
+}
+
 // processMCP 处理MCP请求
 func (s *Service) processMCP(session *mcp.Session, req *mcp.Request) {
 	var err error
@@ -85,6 +103,9 @@ func (s *Service) processMCP(session *mcp.Session, req *mcp.Request) {
 			ToolRecentChat,
 			ToolChatLog,
 			ToolCurrentTime,
+			ToolQueryMessages,
+			ToolListContacts,
+			ToolChatRoomMembers,
 		}})
 	case mcp.MethodToolsCall:
 		err = s.toolsCall(session, req)
@@ -131,7 +152,7 @@ func (s *Service) toolsCall(session *mcp.Session, req *mcp.Request) error {
 
 	buf := &bytes.Buffer{}
 	switch callReq.Name {
-	case "query_contact":
+	case "query_contact", "list_contacts":
 		keyword := ""
 		if v, ok := callReq.Arguments["keyword"]; ok {
 			keyword = v.(string)
@@ -172,6 +193,9 @@ func (s *Service) toolsCall(session *mcp.Session, req *mcp.Request) error {
 		if err != nil {
 			return fmt.Errorf("无法获取会话列表: %v", err)
 		}
+		data.Items = slices.DeleteFunc(data.Items, func(session *model.Session) bool {
+			return s.ctx.Locked(session.UserName)
+		})
 		for _, session := range data.Items {
 			buf.WriteString(session.PlainText(120))
 			buf.WriteString("\n")
@@ -206,6 +230,7 @@ func (s *Service) toolsCall(session *mcp.Session, req *mcp.Request) error {
 		if err != nil {
 			return fmt.Errorf("无法获取聊天记录: %v", err)
 		}
+		messages = s.hideLocked(messages)
 		if len(messages) == 0 {
 			buf.WriteString("未找到符合查询条件的聊天记录")
 		}
@@ -213,6 +238,67 @@ func (s *Service) toolsCall(session *mcp.Session, req *mcp.Request) error {
 			buf.WriteString(m.PlainText(strings.Contains(talker, ","), util.PerfectTimeFormat(start, end), ""))
 			buf.WriteString("\n")
 		}
+	case "query_messages":
+		if callReq.Arguments == nil {
+			return mcp.ErrInvalidParams
+		}
+		args := make(map[string]string)
+		for _, k := range []string{"keyword", "time", "talker", "sender"} {
+			if v, ok := callReq.Arguments[k].(string); ok {
+				args[k] = v
+			}
+		}
+		// 未指定时间范围时搜索全部消息
+		start, end, ok := util.TimeRangeOf(cmp.Or(args["time"], "all"))
+		if !ok {
+			return fmt.Errorf("无法解析时间范围")
+		}
+		if args["talker"] == "" {
+			if _, err := s.db.IndexInfo(); err != nil {
+				return fmt.Errorf("未建立全文索引，请指定 talker 或先执行 chatlog index rebuild")
+			}
+		}
+		limit := util.MustAnyToInt(callReq.Arguments["limit"])
+		if limit <= 0 {
+			limit = DefaultQueryLimit
+		}
+		offset := max(util.MustAnyToInt(callReq.Arguments["offset"]), 0)
+		resp, err := s.db.Search(database.SearchReq{
+			Start:   start,
+			End:     end,
+			Talker:  args["talker"],
+			Sender:  args["sender"],
+			Keyword: args["keyword"],
+			Limit:   limit,
+			Offset:  offset,
+			Hidden:  s.ctx.Locked,
+		})
+		if err != nil {
+			return fmt.Errorf("无法搜索聊天记录: %v", err)
+		}
+		if len(resp.Items) == 0 {
+			buf.WriteString("未找到符合查询条件的聊天记录")
+		} else {
+			buf.WriteString(fmt.Sprintf("共 %d 条，以下为第 %d 至 %d 条\n\n", resp.Total, offset+1, offset+len(resp.Items)))
+		}
+		timeFormat := util.PerfectTimeFormat(start, end)
+		for _, hit := range resp.Items {
+			buf.WriteString(fmt.Sprintf("[%d] %s %s(%s) %s(%s)\n%s\n\n",
+				hit.Seq, hit.Time.Format(timeFormat), cmp.Or(hit.TalkerName, hit.Talker), hit.Talker, cmp.Or(hit.SenderName, hit.Sender), hit.Sender, hit.Snippet.Mark("**", "**")))
+		}
+	case "get_chatroom_members":
+		chatRoom := ""
+		if v, ok := callReq.Arguments["chatroom"]; ok {
+			chatRoom = v.(string)
+		}
+		room, err := s.chatRoom(chatRoom)
+		if err != nil {
+			return err
+		}
+		buf.WriteString("UserName,DisplayName\n")
+		for _, user := range room.Users {
+			buf.WriteString(fmt.Sprintf("%s,%s\n", user.UserName, user.DisplayName))
+		}
 	case "current_time":
 		buf.WriteString(time.Now().Local().Format(time.RFC3339))
 	default:
@@ -228,6 +314,31 @@ func (s *Service) toolsCall(session *mcp.Session, req *mcp.Request) error {
 	return session.WriteResponse(req, resp)
 }
 
+// chatRoom 按群聊 ID 或名称查找唯一的群聊，锁定的群聊视为不存在
+func (s *Service) chatRoom(key string) (*model.ChatRoom, error) {
+	talkers := s.db.ResolveTalkers(key)
+	if key == "" || len(talkers) != 1 || !strings.HasSuffix(talkers[0], "@chatroom") || s.ctx.Locked(talkers[0]) {
+		return nil, fmt.Errorf("未找到群聊: %s", key)
+	}
+	list, err := s.db.GetChatRooms(talkers[0], 0, 0)
+	if err != nil {
+		return nil, fmt.Errorf("无法获取群聊信息: %v", err)
+	}
+	for _, room := range list.Items {
+		if room.Name == talkers[0] {
+			return room, nil
+		}
+	}
+	return nil, fmt.Errorf("未找到群聊: %s", key)
+}
+
+// hideLocked 去掉锁定会话中的消息，MCP 无法提供口令，始终不返回锁定的会话
+func (s *Service) hideLocked(messages []*model.Message) []*model.Message {
+	return slices.DeleteFunc(messages, func(m *model.Message) bool {
+		return s.ctx.Locked(m.Talker)
+	})
+}
+
 // resourcesRead 处理资源读取
 func (s *Service) resourcesRead(session *mcp.Session, req *mcp.Request) error {
 	readReq, err := parseParams[mcp.ResourcesReadRequest](req.Params)
@@ -265,6 +376,9 @@ func (s *Service) resourcesRead(session *mcp.Session, req *mcp.Request) error {
 		if err != nil {
 			return fmt.Errorf("无法获取会话列表: %v", err)
 		}
+		data.Items = slices.DeleteFunc(data.Items, func(session *model.Session) bool {
+			return s.ctx.Locked(session.UserName)
+		})
 		for _, session := range data.Items {
 			buf.WriteString(session.PlainText(120))
 			buf.WriteString("\n")
@@ -280,6 +394,7 @@ func (s *Service) resourcesRead(session *mcp.Session, req *mcp.Request) error {
 		if err != nil {
 			return fmt.Errorf("无法获取聊天记录: %v", err)
 		}
+		messages = s.hideLocked(messages)
 		if len(messages) == 0 {
 			buf.WriteString("未找到符合查询条件的聊天记录")
 		}
@@ -0,0 +1,185 @@
+package chatlog
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/aspnmy/chatlog/internal/chatlog/export"
+	"github.com/aspnmy/chatlog/internal/ui/browser"
+	"github.com/aspnmy/chatlog/internal/ui/form"
+	"github.com/aspnmy/chatlog/internal/ui/palette"
+
+	"github.com/rs/zerolog/log"
+)
+
+// showPalette 打开命令面板，包含主菜单中的命令与导出，浏览聊天记录时还包含会话与时间范围
+// HTTP 服务运行时异步加载会话列表，选择会话后在浏览器中打开
+func (a *App) showPalette() {
+	items := a.paletteItems()
+	b := a.browser
+	if b != nil {
+		items = append(items, b.Commands()...)
+	} else {
+		items = append(items, palette.Item{
+			Name:        "跳转到会话...",
+			Description: "打开浏览器并按名称过滤会话",
+			Action: func() {
+				if b := a.openBrowser(); b != nil {
+					b.FilterSessions()
+				}
+			},
+		})
+	}
+
+	p := palette.Show(a.Application, a.mainPages, items)
+	if p == nil || b != nil || !a.ctx.HTTPEnabled {
+		return
+	}
+	go func() {
+		resp, err := a.m.db.GetSessions("", 0, 0)
+		if err != nil {
+			log.Debug().Err(err).Msg("failed to load sessions for palette")
+			return
+		}
+		sessions := make([]palette.Item, 0, len(resp.Items))
+		for _, s := range resp.Items {
+			if a.ctx.Locked(s.UserName) {
+				continue
+			}
+			talker := s.UserName
+			name := s.NickName
+			if name == "" {
+				name = talker
+			}
+			sessions = append(sessions, palette.Item{
+				Name:        "会话: " + name,
+				Description: talker,
+				Action: func() {
+					if b := a.openBrowser(); b != nil {
+						b.Open(talker)
+					}
+				},
+			})
+		}
+		a.QueueUpdateDraw(func() {
+			p.AddItems(sessions...)
+		})
+	}()
+}
+
+// paletteItems 将主菜单中的命令转换为命令面板中的命令，名称随状态变化（如开启/停止自动解密）
+func (a *App) paletteItems() []palette.Item {
+	menuItems := a.menu.GetItems()
+	items := make([]palette.Item, 0, len(menuItems)+1)
+	for _, i := range menuItems {
+		if i.Hidden || i.Selected == nil {
+			continue
+		}
+		items = append(items, palette.Item{
+			Name:        i.Name,
+			Description: i.Description,
+			Action: func() {
+				a.closeBrowser()
+				i.Selected(i)
+			},
+		})
+	}
+	items = append(items, palette.Item{
+		Name:        "导出聊天记录...",
+		Description: "导出会话到本地目录",
+		Action:      a.exportSelected,
+	})
+	return items
+}
+
+// openBrowser 打开聊天记录浏览器，已经打开时直接返回，打开数据库失败时返回 nil
+func (a *App) openBrowser() *browser.Browser {
+	if a.browser == nil {
+		a.browseSelected(nil)
+	}
+	return a.browser
+}
+
+// closeBrowser 关闭聊天记录浏览器，用于从命令面板执行主菜单中的命令
+func (a *App) closeBrowser() {
+	if a.browser != nil {
+		a.browser.Quit()
+	}
+}
+
+// exportSelected 打开导出表单，默认导出浏览器中当前会话与时间范围内的消息
+func (a *App) exportSelected() {
+	opts := export.Options{
+		Format: export.FormatText,
+		Dest:   a.ctx.ExportDir,
+	}
+	if a.browser != nil {
+		opts.Talker = a.browser.Session()
+		opts.Time = a.browser.Range()
+	}
+
+	formView := form.NewForm("导出聊天记录")
+	formView.AddInputField("会话", opts.Talker, 0, nil, func(text string) {
+		opts.Talker = strings.TrimSpace(text)
+	})
+	formView.AddInputField("时间范围", opts.Time, 0, nil, func(text string) {
+		opts.Time = strings.TrimSpace(text)
+	})
+	formView.AddInputField("格式", opts.Format, 0, nil, func(text string) {
+		opts.Format = strings.TrimSpace(text)
+	})
+	formView.AddInputField("目标目录", opts.Dest, 0, nil, func(text string) {
+		opts.Dest = strings.TrimSpace(text)
+	})
+	formView.AddButton("导出", func() {
+		a.mainPages.RemovePage("submenu2")
+		a.export(opts)
+	})
+	formView.AddButton("取消", func() {
+		a.mainPages.RemovePage("submenu2")
+	})
+
+	a.mainPages.AddPage("submenu2", formView, true, true)
+	a.SetFocus(formView)
+}
+
+// export 在后台导出聊天记录，数据库未打开时临时打开，导出结束后关闭
+func (a *App) export(opts export.Options) {
+	if opts.Dest == "" {
+		a.showError(fmt.Errorf("目标目录不能为空"))
+		return
+	}
+	if !slices.Contains([]string{export.FormatText, export.FormatJSON, export.FormatJSONL, export.FormatCSV, export.FormatHTML}, opts.Format) {
+		a.showError(fmt.Errorf("命令面板只支持导出 txt、json、jsonl、csv、html 格式，其他格式请使用 chatlog export"))
+		return
+	}
+	opts.ExcludeTalkers = a.ctx.LockedTalkerList()
+
+	started := a.browser == nil && !a.ctx.HTTPEnabled
+	if started {
+		if err := a.m.db.Start(); err != nil {
+			a.showError(fmt.Errorf("打开数据库失败: %w", err))
+			return
+		}
+	}
+
+	a.showModal("导出中...", nil, nil)
+	go func() {
+		result, err := a.m.export.Export(opts)
+		if started {
+			a.m.db.Stop()
+		}
+		a.QueueUpdateDraw(func() {
+			a.mainPages.RemovePage("modal")
+			switch {
+			case err != nil:
+				a.showError(fmt.Errorf("导出失败: %w", err))
+			case len(result.Failed) > 0:
+				a.showInfo(fmt.Sprintf("导出 %d 条消息到 %s，%d 个会话失败", result.Messages, result.Dest, len(result.Failed)))
+			default:
+				a.showInfo(fmt.Sprintf("导出 %d 条消息到 %s", result.Messages, result.Dest))
+			}
+		})
+	}()
+}
@@ -0,0 +1,92 @@
+package chatlog
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/aspnmy/chatlog/pkg/util"
+)
+
+// PurgeOptions 是 CommandPurge 的参数
+type PurgeOptions struct {
+	WorkDir  string
+	Platform string
+	Version  int
+
+	Talker  string // 会话，必填，支持名称
+	Time    string // 时间范围，为空时不限
+	Sender  string
+	Keyword string
+
+	Tombstone bool // 为匹配的消息写入清除记录，否则只统计
+	Restore   bool // 删除会话在时间范围内的清除记录，恢复显示
+}
+
+// PurgeResult 是 CommandPurge 的结果
+type PurgeResult struct {
+	Matched  int // 匹配的消息数
+	Added    int // 新增的清除记录数
+	Removed  int // 删除的清除记录数
+	Total    int // 操作后的清除记录总数
+	Modified bool
+}
+
+// CommandPurge 清除会话中的消息
+// chatlog 不修改微信的数据，清除通过工作目录中的清除记录实现：被记录的消息在查询、
+// 搜索与导出中不再出现，重新解密或合并 3.x 的数据后也不会重新出现
+func (m *Manager) CommandPurge(opts PurgeOptions) (*PurgeResult, error) {
+	if opts.WorkDir == "" {
+		return nil, fmt.Errorf("workDir is required")
+	}
+	if opts.Talker == "" && !opts.Restore {
+		return nil, fmt.Errorf("talker is required")
+	}
+	if opts.Tombstone && opts.Restore {
+		return nil, fmt.Errorf("tombstone and restore can not be used together")
+	}
+
+	timeRange := opts.Time
+	if timeRange == "" {
+		timeRange = "2000-01-01~" + time.Now().Format("2006-01-02")
+	}
+	start, end, ok := util.TimeRangeOf(timeRange)
+	if !ok {
+		return nil, fmt.Errorf("invalid time range: %s", opts.Time)
+	}
+
+	m.ctx.WorkDir = opts.WorkDir
+	m.ctx.Platform = opts.Platform
+	m.ctx.Version = opts.Version
+
+	if err := m.db.Start(); err != nil {
+		return nil, err
+	}
+	defer m.db.Stop()
+
+	db := m.db.GetDB()
+	tombstones := db.Tombstones()
+	result := &PurgeResult{}
+
+	if opts.Restore {
+		talker, _ := db.ParseTalkerAndSender(opts.Talker, "")
+		result.Removed = tombstones.Remove(talker, start, end)
+	} else {
+		messages, err := db.GetMessages(start, end, opts.Talker, opts.Sender, opts.Keyword, 0, 0)
+		if err != nil {
+			return nil, err
+		}
+		result.Matched = len(messages)
+		if opts.Tombstone {
+			result.Added = tombstones.Add(messages)
+		}
+	}
+
+	if result.Added > 0 || result.Removed > 0 {
+		if err := tombstones.Save(); err != nil {
+			return nil, err
+		}
+		result.Modified = true
+	}
+	result.Total = tombstones.Len()
+	return result, nil
+}
@@ -0,0 +1,56 @@
+package chatlog
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/aspnmy/chatlog/internal/chatlog/export"
+)
+
+// CommandScreenshot 将会话按 HTML 导出的页面渲染为 PNG 截图保存到 out，返回渲染的消息数
+// synthetic 大于 0 时渲染内容固定的合成消息，不需要工作目录，用于复现与会话内容无关的布局问题
+func (m *Manager) CommandScreenshot(workDir string, platform string, version int, out string, synthetic int, opts export.ScreenshotOptions) (int, error) {
+	if out == "" {
+		return 0, fmt.Errorf("output file is required")
+	}
+	if synthetic <= 0 {
+		if workDir == "" {
+			return 0, fmt.Errorf("workDir is required")
+		}
+		if opts.Talker == "" {
+			return 0, fmt.Errorf("talker is required")
+		}
+	}
+
+	f, err := os.Create(out)
+	if err != nil {
+		return 0, err
+	}
+	n, err := m.screenshot(f, workDir, platform, version, synthetic, opts)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(out)
+		return 0, err
+	}
+	return n, nil
+}
+
+func (m *Manager) screenshot(f *os.File, workDir string, platform string, version int, synthetic int, opts export.ScreenshotOptions) (int, error) {
+	if synthetic > 0 {
+		return synthetic, export.RenderScreenshot(f, export.SyntheticMessages(synthetic), opts)
+	}
+
+	m.ctx.WorkDir = workDir
+	m.ctx.Platform = platform
+	m.ctx.Version = version
+	if err := m.db.Start(); err != nil {
+		return 0, err
+	}
+	defer m.db.Stop()
+
+	// 截图用于附在问题反馈中，不包含锁定的会话
+	opts.ExcludeTalkers = m.ctx.LockedTalkerList()
+	return m.export.Screenshot(f, opts)
+}
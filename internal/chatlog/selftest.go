@@ -0,0 +1,302 @@
+package chatlog
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aspnmy/chatlog/internal/wechat/decrypt"
+	"github.com/aspnmy/chatlog/internal/wechat/decrypt/common"
+	"github.com/aspnmy/chatlog/pkg/util/silk"
+)
+
+// 自检步骤的结果
+const (
+	SelftestPass = "pass"
+	SelftestFail = "fail"
+	SelftestSkip = "skip" // 前一步失败或缺少条件，未执行
+)
+
+// SelftestStep 自检中一个步骤的结果，Detail 中不包含密钥
+type SelftestStep struct {
+	Name    string        `json:"name"`
+	Result  string        `json:"result"`
+	Detail  string        `json:"detail,omitempty"`
+	Elapsed time.Duration `json:"elapsed"`
+}
+
+// selftestMaxTries 查找可解密的小数据库时最多尝试的文件数
+const selftestMaxTries = 10
+
+// skipStep 表示步骤未执行，原因作为 Detail
+type skipStep string
+
+func (s skipStep) Error() string { return string(s) }
+
+// selftest 记录自检的环境与各步骤的结果，后面的步骤使用前面步骤得到的数据目录、密钥与解密结果
+type selftest struct {
+	steps []SelftestStep
+
+	dataDir  string
+	workDir  string
+	key      string
+	platform string
+	version  int
+	cipher   *common.CipherInfo
+
+	keyOK bool
+	tmp   string // 解密到临时目录的数据库
+}
+
+func (t *selftest) run(name string, f func() (string, error)) bool {
+	start := time.Now()
+	detail, err := f()
+	step := SelftestStep{Name: name, Result: SelftestPass, Detail: detail, Elapsed: time.Since(start)}
+	if err != nil {
+		step.Result, step.Detail = SelftestFail, err.Error()
+		if _, ok := err.(skipStep); ok {
+			step.Result = SelftestSkip
+		}
+	}
+	t.steps = append(t.steps, step)
+	return step.Result == SelftestPass
+}
+
+// CommandSelftest 依次检查微信进程、密钥、解密、查询与语音转换，返回各步骤的结果，用于附在问题反馈中
+// 参数为空时使用检测到的微信进程与配置文件中该数据目录的记录，version 为 0 时自动判断；结果中不包含密钥
+func (m *Manager) CommandSelftest(dataDir, workDir, key, platform string, version int) []SelftestStep {
+	t := &selftest{dataDir: dataDir, workDir: workDir, key: key, platform: platform, version: version}
+
+	t.run("process", func() (string, error) {
+		if m.ctx.ArchiveOnly {
+			return "", skipStep("archive only build")
+		}
+		instances := m.wechat.GetWeChatInstances()
+		if len(instances) == 0 {
+			return "", fmt.Errorf("wechat process not found, run \"chatlog doctor\" to see why")
+		}
+		ins := instances[0]
+		if t.dataDir == "" {
+			t.dataDir = ins.DataDir
+		}
+		if filepath.Clean(t.dataDir) == filepath.Clean(ins.DataDir) {
+			t.platform = cmp.Or(t.platform, ins.Platform)
+			if t.version == 0 {
+				t.version = ins.Version
+			}
+			t.key = cmp.Or(t.key, ins.Key)
+			if t.cipher == nil {
+				t.cipher = ins.Cipher
+			}
+		}
+		return fmt.Sprintf("%d process(es), pid %d, version %s", len(instances), ins.PID, ins.FullVersion), nil
+	})
+	t.resolve(m)
+	defer func() {
+		if t.tmp != "" {
+			os.Remove(t.tmp)
+		}
+	}()
+
+	t.keyOK = t.run("key", t.checkKey)
+	t.run("decrypt", t.decryptSmallest)
+	t.run("query", t.query)
+	t.run("voice", t.convertVoice)
+	return t.steps
+}
+
+// resolve 使用配置文件中的记录补全数据目录、工作目录、密钥与版本
+func (t *selftest) resolve(m *Manager) {
+	t.dataDir = cmp.Or(t.dataDir, m.ctx.DataDir)
+	for _, h := range m.ctx.History {
+		if t.dataDir == "" || filepath.Clean(h.DataDir) != filepath.Clean(t.dataDir) {
+			continue
+		}
+		t.key = cmp.Or(t.key, h.DataKey)
+		t.workDir = cmp.Or(t.workDir, h.WorkDir)
+		t.platform = cmp.Or(t.platform, h.Platform)
+		if t.version == 0 {
+			t.version = h.Version
+		}
+		if t.cipher == nil && h.DataKey == t.key {
+			t.cipher = h.Cipher
+		}
+	}
+	if filepath.Clean(t.dataDir) == filepath.Clean(m.ctx.DataDir) {
+		t.key = cmp.Or(t.key, m.ctx.DataKey)
+		t.workDir = cmp.Or(t.workDir, m.ctx.WorkDir)
+	}
+}
+
+func (t *selftest) checkKey() (string, error) {
+	if t.dataDir == "" {
+		return "", fmt.Errorf("data dir not found, use --data-dir")
+	}
+	if t.platform == "" || t.version == 0 {
+		return "", fmt.Errorf("unknown wechat version of %s, use --platform and --version", t.dataDir)
+	}
+	if t.key == "" {
+		return "", fmt.Errorf("no key for %s, run \"chatlog key\" or use --key", t.dataDir)
+	}
+	b, err := hex.DecodeString(t.key)
+	if err != nil {
+		return "", fmt.Errorf("key is not a hex string")
+	}
+	validator, err := decrypt.NewValidatorWithCipher(t.platform, t.version, t.dataDir, t.cipher)
+	if err != nil {
+		return "", err
+	}
+	if !validator.Validate(b) {
+		return "", fmt.Errorf("key does not match %s (%s %d)", t.dataDir, t.platform, t.version)
+	}
+	return fmt.Sprintf("%d byte key matches %s (%s %d)", len(b), t.dataDir, t.platform, t.version), nil
+}
+
+// decryptSmallest 将数据目录中最小的加密数据库解密到临时文件
+func (t *selftest) decryptSmallest() (string, error) {
+	if !t.keyOK {
+		return "", skipStep("no valid key")
+	}
+	d, err := decrypt.NewDecryptorWithCipher(t.platform, t.version, t.cipher)
+	if err != nil {
+		return "", err
+	}
+	key, _ := hex.DecodeString(t.key)
+	files := smallDBFiles(t.dataDir, t.platform, t.version, int64(d.GetPageSize()))
+	for i, file := range files {
+		if i == selftestMaxTries {
+			break
+		}
+		// 跳过未加密或使用其他密钥的数据库
+		db, err := common.OpenDBFile(file.path, d.GetPageSize())
+		if err != nil || !d.Validate(db.FirstPage, key) {
+			continue
+		}
+		f, err := os.CreateTemp("", "chatlog-selftest-*.db")
+		if err != nil {
+			return "", err
+		}
+		t.tmp = f.Name()
+		err = d.Decrypt(context.Background(), file.path, t.key, f)
+		f.Close()
+		if err != nil {
+			return "", fmt.Errorf("decrypt %s: %w", file.path, err)
+		}
+		rel, _ := filepath.Rel(t.dataDir, file.path)
+		return fmt.Sprintf("%s (%d bytes) decrypted to a temp file", rel, file.size), nil
+	}
+	return "", fmt.Errorf("no encrypted database in %s can be decrypted with the key", t.dataDir)
+}
+
+type dbFile struct {
+	path string
+	size int64
+}
+
+// smallDBFiles 返回数据库目录中的数据库文件，按大小升序，不含全文索引与小于一页的文件
+func smallDBFiles(dataDir, platform string, version int, pageSize int64) []dbFile {
+	// 数据库所在的目录，如 3.x 的 Msg、4.x 的 db_storage
+	roots := make(map[string]bool)
+	for _, file := range decrypt.GetCandidateDBFiles(platform, version) {
+		parts := strings.FieldsFunc(file, func(r rune) bool { return r == '/' || r == '\\' })
+		if len(parts) > 1 {
+			roots[parts[0]] = true
+		}
+	}
+	var files []dbFile
+	for root := range roots {
+		filepath.WalkDir(filepath.Join(dataDir, root), func(path string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() || !strings.HasSuffix(d.Name(), ".db") || strings.Contains(strings.ToLower(path), "fts") {
+				return nil
+			}
+			if info, err := d.Info(); err == nil && info.Size() >= pageSize {
+				files = append(files, dbFile{path: path, size: info.Size()})
+			}
+			return nil
+		})
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].size < files[j].size })
+	return files
+}
+
+func (t *selftest) query() (string, error) {
+	if t.tmp == "" {
+		return "", skipStep("no decrypted database")
+	}
+	db, err := sql.Open("sqlite3", "file:"+t.tmp+"?mode=ro")
+	if err != nil {
+		return "", err
+	}
+	defer db.Close()
+	var tables int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table'`).Scan(&tables); err != nil {
+		return "", err
+	}
+	var check string
+	if err := db.QueryRow(`PRAGMA quick_check`).Scan(&check); err != nil {
+		return "", err
+	}
+	if check != "ok" {
+		return "", fmt.Errorf("integrity check failed: %s", check)
+	}
+	return fmt.Sprintf("%d tables, integrity ok", tables), nil
+}
+
+// voiceSource 已解密的工作目录中语音所在的数据库与读取一条语音的查询，与各版本数据源的 GetVoice 相同
+type voiceSource struct {
+	pattern *regexp.Regexp
+	query   string
+}
+
+func voiceSourceOf(platform string, version int) *voiceSource {
+	switch {
+	case platform == "windows" && version == 3:
+		return &voiceSource{regexp.MustCompile(`^MediaMSG([0-9])?\.db$`), `SELECT Buf FROM Media WHERE length(Buf) > 0 LIMIT 1`}
+	case version == 4:
+		return &voiceSource{regexp.MustCompile(`^media_([0-9]?[0-9])?\.db$`), `SELECT voice_data FROM VoiceInfo WHERE length(voice_data) > 0 LIMIT 1`}
+	}
+	return nil
+}
+
+// convertVoice 从工作目录中读取一条语音并转换为 mp3
+func (t *selftest) convertVoice() (string, error) {
+	src := voiceSourceOf(t.platform, t.version)
+	switch {
+	case src == nil:
+		return "", skipStep(fmt.Sprintf("voices of %s %d are not stored in databases", t.platform, t.version))
+	case t.workDir == "":
+		return "", skipStep("work dir not found, decrypt the data first or use --work-dir")
+	}
+	var data []byte
+	filepath.WalkDir(t.workDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || !src.pattern.MatchString(d.Name()) {
+			return nil
+		}
+		db, err := sql.Open("sqlite3", "file:"+path+"?mode=ro")
+		if err != nil {
+			return nil
+		}
+		defer db.Close()
+		if db.QueryRow(src.query).Scan(&data) == nil && len(data) > 0 {
+			return fs.SkipAll
+		}
+		return nil
+	})
+	if len(data) == 0 {
+		return "", skipStep(fmt.Sprintf("no voice found in %s", t.workDir))
+	}
+	out, err := silk.Silk2MP3(data)
+	if err != nil {
+		return "", fmt.Errorf("convert voice: %w", err)
+	}
+	return fmt.Sprintf("%d bytes of silk converted to %d bytes of mp3", len(data), len(out)), nil
+}
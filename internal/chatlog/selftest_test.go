@@ -0,0 +1,33 @@
+package chatlog
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSmallDBFiles(t *testing.T) {
+	dir := t.TempDir()
+	for name, size := range map[string]int{
+		"db_storage/message/message_0.db":     8192,
+		"db_storage/contact/contact.db":       4096,
+		"db_storage/message/message_fts.db":   4096,
+		"db_storage/session/session.db":       100,
+		"db_storage/head_image/head_image.db": 6000,
+		"msg/attach/a.db":                     4096,
+	} {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := os.WriteFile(path, make([]byte, size), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	files := smallDBFiles(dir, "windows", 4, 4096)
+	var names []string
+	for _, f := range files {
+		names = append(names, filepath.Base(f.path))
+	}
+	if len(names) != 3 || names[0] != "contact.db" || names[1] != "head_image.db" || names[2] != "message_0.db" {
+		t.Errorf("smallDBFiles = %v", names)
+	}
+}
@@ -0,0 +1,236 @@
+package snapshot
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aspnmy/chatlog/internal/errors"
+	"github.com/aspnmy/chatlog/internal/wechat/decrypt/common"
+)
+
+const (
+	// ManifestFile 指向当前快照的清单文件，写完新快照后原子替换
+	ManifestFile = "snapshot.json"
+
+	// ArchiveManifestFile 保存在每个快照目录中的清单，包含全部文件的哈希，
+	// 快照目录单独拷贝或打包后仍可校验
+	ArchiveManifestFile = "manifest.json"
+
+	// DefaultKeep 默认保留的快照数量，正在读取旧快照的服务有时间切换到新快照
+	DefaultKeep = 2
+
+	// ServingFile 正在提供服务的快照目录中的标记文件，由 MarkServing 定期更新，
+	// 删除较早的快照时跳过带有有效标记的快照
+	ServingFile = ".serving"
+
+	// ServingTimeout 标记文件超过该时间未更新即视为失效，服务异常退出后快照仍可被删除
+	ServingTimeout = 10 * time.Minute
+
+	// 快照目录名即快照版本
+	versionLayout = "20060102-150405"
+)
+
+// Manifest 描述当前快照
+type Manifest struct {
+	Version  string    `json:"version"`
+	Created  time.Time `json:"created"`
+	Account  string    `json:"account,omitempty"`
+	Platform string    `json:"platform"`
+	WeChat   int       `json:"wechat_version"`
+
+	// Files 仅写入快照目录中的 manifest.json，snapshot.json 中为空
+	Files []File `json:"files,omitempty"`
+}
+
+// File 快照中的一个文件，Path 为相对快照目录的路径，使用 / 分隔
+type File struct {
+	Path   string `json:"path"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// Dir 返回快照数据所在目录
+func (m *Manifest) Dir(root string) string {
+	return filepath.Join(root, m.Version)
+}
+
+// Read 读取 root 下的快照清单
+func Read(root string) (*Manifest, error) {
+	return readManifest(filepath.Join(root, ManifestFile))
+}
+
+func readManifest(path string) (*Manifest, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.ReadFileFailed(path, err)
+	}
+	var m Manifest
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, errors.Newf(err, http.StatusInternalServerError, "invalid snapshot manifest: %s", path)
+	}
+	if m.Version == "" {
+		return nil, errors.Newf(nil, http.StatusInternalServerError, "snapshot version missing: %s", path)
+	}
+	return &m, nil
+}
+
+// Create 将工作目录复制为 root 下的新快照，复制完成后才更新清单，
+// 之后只保留最近 keep 个快照
+func Create(workDir, root string, m Manifest, keep int) (*Manifest, error) {
+	m.Created = time.Now()
+	m.Version = m.Created.Format(versionLayout)
+	dir := m.Dir(root)
+	if _, err := os.Stat(dir); err == nil {
+		return nil, errors.Newf(nil, http.StatusInternalServerError, "snapshot already exists: %s", dir)
+	}
+
+	tmp := dir + ".tmp"
+	os.RemoveAll(tmp)
+	files, err := copyDir(workDir, tmp)
+	if err != nil {
+		os.RemoveAll(tmp)
+		return nil, err
+	}
+	archive := m
+	archive.Files = files
+	b, err := json.MarshalIndent(archive, "", "  ")
+	if err != nil {
+		os.RemoveAll(tmp)
+		return nil, err
+	}
+	if err := os.WriteFile(filepath.Join(tmp, ArchiveManifestFile), b, 0644); err != nil {
+		os.RemoveAll(tmp)
+		return nil, err
+	}
+	if err := os.Rename(tmp, dir); err != nil {
+		os.RemoveAll(tmp)
+		return nil, err
+	}
+
+	b, err = json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	manifest := filepath.Join(root, ManifestFile)
+	if err := os.WriteFile(manifest+".tmp", b, 0644); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(manifest+".tmp", manifest); err != nil {
+		return nil, err
+	}
+
+	if keep <= 0 {
+		keep = DefaultKeep
+	}
+	prune(root, keep)
+	return &m, nil
+}
+
+// MarkServing 标记 root 下的快照 version 正在提供服务，需在 ServingTimeout 内再次调用以保持标记有效
+func MarkServing(root, version string) error {
+	path := filepath.Join(root, version, ServingFile)
+	now := time.Now()
+	if err := os.Chtimes(path, now, now); err == nil {
+		return nil
+	}
+	return os.WriteFile(path, []byte(strconv.Itoa(os.Getpid())), 0644)
+}
+
+// UnmarkServing 删除快照 version 的服务标记，之后该快照可被删除
+func UnmarkServing(root, version string) {
+	os.Remove(filepath.Join(root, version, ServingFile))
+}
+
+// serving 返回快照目录 dir 是否带有有效的服务标记
+func serving(dir string) bool {
+	info, err := os.Stat(filepath.Join(dir, ServingFile))
+	return err == nil && time.Since(info.ModTime()) < ServingTimeout
+}
+
+// prune 删除较早的快照，保留最近 keep 个，正在提供服务的快照不删除
+func prune(root string, keep int) {
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return
+	}
+	var versions []string
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		if _, err := time.ParseInLocation(versionLayout, e.Name(), time.Local); err == nil {
+			versions = append(versions, e.Name())
+		}
+	}
+	sort.Strings(versions)
+	for i := 0; i < len(versions)-keep; i++ {
+		dir := filepath.Join(root, versions[i])
+		if serving(dir) {
+			continue
+		}
+		os.RemoveAll(dir)
+	}
+}
+
+// copyDir 复制目录并返回复制的文件及其哈希
+func copyDir(src, dst string) ([]File, error) {
+	var files []File
+	err := filepath.WalkDir(src, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		if d.IsDir() {
+			return os.MkdirAll(target, 0755)
+		}
+		// 跳过 SQLite 的共享内存文件（打开时会重新生成）和增量解密的页面校验和
+		if !d.Type().IsRegular() || strings.HasSuffix(path, "-shm") || strings.HasSuffix(path, common.PageSumsExt) {
+			return nil
+		}
+		f, err := copyFile(path, target)
+		if err != nil {
+			return fmt.Errorf("copy %s: %w", rel, err)
+		}
+		f.Path = filepath.ToSlash(rel)
+		files = append(files, f)
+		return nil
+	})
+	return files, err
+}
+
+// copyFile 复制文件，同时计算内容的哈希
+func copyFile(src, dst string) (File, error) {
+	in, err := os.Open(src)
+	if err != nil {
+		return File{}, errors.OpenFileFailed(src, err)
+	}
+	defer in.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		return File{}, err
+	}
+	h := sha256.New()
+	n, err := io.Copy(io.MultiWriter(out, h), in)
+	if err != nil {
+		out.Close()
+		return File{}, err
+	}
+	if err := out.Close(); err != nil {
+		return File{}, err
+	}
+	return File{Size: n, SHA256: hex.EncodeToString(h.Sum(nil))}, nil
+}
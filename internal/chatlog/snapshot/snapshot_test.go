@@ -0,0 +1,43 @@
+package snapshot
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestPruneKeepsServing(t *testing.T) {
+	root := t.TempDir()
+	versions := []string{"20240101-000000", "20240102-000000", "20240103-000000", "20240104-000000"}
+	for _, v := range versions {
+		if err := os.Mkdir(filepath.Join(root, v), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 最早的快照正在提供服务，第二个快照的标记已失效
+	if err := MarkServing(root, versions[0]); err != nil {
+		t.Fatal(err)
+	}
+	if err := MarkServing(root, versions[1]); err != nil {
+		t.Fatal(err)
+	}
+	stale := time.Now().Add(-ServingTimeout - time.Minute)
+	if err := os.Chtimes(filepath.Join(root, versions[1], ServingFile), stale, stale); err != nil {
+		t.Fatal(err)
+	}
+
+	prune(root, 2)
+	for i, want := range []bool{true, false, true, true} {
+		_, err := os.Stat(filepath.Join(root, versions[i]))
+		if got := err == nil; got != want {
+			t.Errorf("%s exists = %v, want %v", versions[i], got, want)
+		}
+	}
+
+	UnmarkServing(root, versions[0])
+	prune(root, 2)
+	if _, err := os.Stat(filepath.Join(root, versions[0])); err == nil {
+		t.Error("unmarked snapshot not pruned")
+	}
+}
@@ -0,0 +1,238 @@
+package snapshot
+
+import (
+	"archive/zip"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/aspnmy/chatlog/internal/errors"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// Report 备份校验结果
+type Report struct {
+	Archive  string `json:"archive"`
+	Version  string `json:"version"`
+	Files    int    `json:"files"`    // 清单中的文件数
+	Hashed   int    `json:"hashed"`   // 已校验哈希的文件数
+	DBs      int    `json:"dbs"`      // 已打开并检查的数据库数
+	Sessions int    `json:"sessions"` // 抽查读取的会话数
+	Messages int    `json:"messages"` // 抽查读取的消息数
+
+	Warnings []string `json:"warnings,omitempty"`
+	Errors   []string `json:"errors,omitempty"`
+}
+
+// Passed 没有任何错误时校验通过
+func (r *Report) Passed() bool {
+	return len(r.Errors) == 0
+}
+
+// Fail 记录一个校验错误
+func (r *Report) Fail(format string, a ...any) {
+	r.Errors = append(r.Errors, fmt.Sprintf(format, a...))
+}
+
+// Warn 记录不影响结果的问题
+func (r *Report) Warn(format string, a ...any) {
+	r.Warnings = append(r.Warnings, fmt.Sprintf(format, a...))
+}
+
+// Restore 将备份恢复到 dst，返回快照清单与恢复后的数据目录
+// archive 可以是快照根目录（包含 snapshot.json）、单个快照目录（包含 manifest.json）
+// 或将二者之一打包的 zip 文件
+func Restore(archive, dst string) (*Manifest, string, error) {
+	fi, err := os.Stat(archive)
+	if err != nil {
+		return nil, "", errors.OpenFileFailed(archive, err)
+	}
+	if !fi.IsDir() {
+		if err := unzip(archive, dst); err != nil {
+			return nil, "", err
+		}
+		return locate(dst)
+	}
+
+	m, src, err := locate(archive)
+	if err != nil {
+		return nil, "", err
+	}
+	dir := filepath.Join(dst, m.Version)
+	if _, err := copyDir(src, dir); err != nil {
+		return nil, "", err
+	}
+	return m, dir, nil
+}
+
+// locate 在 root 中查找快照目录，zip 中的快照可能位于一层子目录中
+func locate(root string) (*Manifest, string, error) {
+	if _, err := os.Stat(filepath.Join(root, ArchiveManifestFile)); err == nil {
+		m, err := readManifest(filepath.Join(root, ArchiveManifestFile))
+		return m, root, err
+	}
+	if _, err := os.Stat(filepath.Join(root, ManifestFile)); err == nil {
+		m, err := Read(root)
+		if err != nil {
+			return nil, "", err
+		}
+		dir := m.Dir(root)
+		// 新版本的快照目录中保存了带哈希的清单
+		if full, err := readManifest(filepath.Join(dir, ArchiveManifestFile)); err == nil {
+			m = full
+		}
+		return m, dir, nil
+	}
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return nil, "", errors.ReadFileFailed(root, err)
+	}
+	if len(entries) == 1 && entries[0].IsDir() {
+		return locate(filepath.Join(root, entries[0].Name()))
+	}
+	return nil, "", errors.Newf(nil, http.StatusBadRequest, "no snapshot manifest found in %s", root)
+}
+
+// unzip 解压 zip 文件到 dst，拒绝指向 dst 之外的路径
+func unzip(archive, dst string) error {
+	r, err := zip.OpenReader(archive)
+	if err != nil {
+		return errors.OpenFileFailed(archive, err)
+	}
+	defer r.Close()
+	for _, f := range r.File {
+		name := filepath.FromSlash(f.Name)
+		if !filepath.IsLocal(name) {
+			return errors.Newf(nil, http.StatusBadRequest, "invalid path in archive: %s", f.Name)
+		}
+		target := filepath.Join(dst, name)
+		if f.FileInfo().IsDir() {
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		}
+		if err := extract(f, target); err != nil {
+			return fmt.Errorf("extract %s: %w", f.Name, err)
+		}
+	}
+	return nil
+}
+
+func extract(f *zip.File, target string) error {
+	in, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(target)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// Verify 校验恢复后的快照目录：数据库文件全部校验哈希，其他文件随机抽查 sample 个，
+// sample 小于 0 时全部校验，之后打开每个数据库执行 quick_check
+// 哈希需在打开数据库之前校验，SQLite 打开时会合并 -wal 文件
+func Verify(dir string, m *Manifest, sample int) *Report {
+	r := &Report{Version: m.Version, Files: len(m.Files)}
+
+	if len(m.Files) == 0 {
+		r.Warn("manifest has no file hashes, snapshot was created by an older version; only databases are checked")
+	}
+	var dbs, others []File
+	for _, f := range m.Files {
+		if isDB(f.Path) {
+			dbs = append(dbs, f)
+		} else {
+			others = append(others, f)
+		}
+	}
+	if sample >= 0 && sample < len(others) {
+		rand.Shuffle(len(others), func(i, j int) { others[i], others[j] = others[j], others[i] })
+		others = others[:sample]
+	}
+	for _, f := range append(dbs, others...) {
+		r.Hashed++
+		if err := checkFile(dir, f); err != nil {
+			r.Fail("%s: %v", f.Path, err)
+		}
+	}
+
+	filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() || !strings.HasSuffix(path, ".db") {
+			return err
+		}
+		rel, _ := filepath.Rel(dir, path)
+		r.DBs++
+		if err := checkDB(path); err != nil {
+			r.Fail("%s: %v", filepath.ToSlash(rel), err)
+		}
+		return nil
+	})
+	if r.DBs == 0 {
+		r.Fail("no database found in %s", m.Version)
+	}
+	return r
+}
+
+// isDB 数据库及其 WAL 文件
+func isDB(path string) bool {
+	return strings.HasSuffix(path, ".db") || strings.HasSuffix(path, ".db-wal")
+}
+
+func checkFile(dir string, f File) error {
+	in, err := os.Open(filepath.Join(dir, filepath.FromSlash(f.Path)))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("missing")
+		}
+		return err
+	}
+	defer in.Close()
+	h := sha256.New()
+	n, err := io.Copy(h, in)
+	if err != nil {
+		return err
+	}
+	if n != f.Size {
+		return fmt.Errorf("size mismatch, expected %d, got %d", f.Size, n)
+	}
+	if sum := hex.EncodeToString(h.Sum(nil)); sum != f.SHA256 {
+		return fmt.Errorf("sha256 mismatch, expected %s, got %s", f.SHA256, sum)
+	}
+	return nil
+}
+
+// checkDB 打开数据库并执行 PRAGMA quick_check
+func checkDB(path string) error {
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	var result string
+	if err := db.QueryRow("PRAGMA quick_check").Scan(&result); err != nil {
+		return err
+	}
+	if result != "ok" {
+		return fmt.Errorf("quick_check: %s", result)
+	}
+	return nil
+}
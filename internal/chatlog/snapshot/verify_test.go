@@ -0,0 +1,105 @@
+package snapshot
+
+import (
+	"archive/zip"
+	"database/sql"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func newWorkDir(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	db, err := sql.Open("sqlite3", filepath.Join(dir, "message.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec("CREATE TABLE t(v TEXT); INSERT INTO t VALUES ('hello')"); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+	os.MkdirAll(filepath.Join(dir, "media"), 0755)
+	if err := os.WriteFile(filepath.Join(dir, "media", "a.jpg"), []byte("image"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestVerify(t *testing.T) {
+	root := t.TempDir()
+	m, err := Create(newWorkDir(t), root, Manifest{Platform: "windows", WeChat: 4}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	info, dir, err := Restore(root, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Version != m.Version || len(info.Files) != 2 {
+		t.Fatalf("restored manifest = %+v", info)
+	}
+	if r := Verify(dir, info, -1); !r.Passed() || r.Hashed != 2 || r.DBs != 1 {
+		t.Fatalf("report = %+v", r)
+	}
+
+	// 损坏快照中的媒体文件
+	if err := os.WriteFile(filepath.Join(m.Dir(root), "media", "a.jpg"), []byte("broken"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	info, dir, err = Restore(m.Dir(root), t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r := Verify(dir, info, 0); !r.Passed() {
+		t.Fatalf("media not sampled, report = %+v", r)
+	}
+	if r := Verify(dir, info, -1); r.Passed() {
+		t.Fatal("corrupted media passed")
+	}
+}
+
+func TestRestoreZip(t *testing.T) {
+	root := t.TempDir()
+	m, err := Create(newWorkDir(t), root, Manifest{Platform: "windows", WeChat: 4}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	archive := filepath.Join(t.TempDir(), "backup.zip")
+	f, err := os.Create(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := zip.NewWriter(f)
+	src := m.Dir(root)
+	filepath.WalkDir(src, func(path string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, _ := filepath.Rel(src, path)
+		w, err := zw.Create(filepath.ToSlash(filepath.Join(m.Version, rel)))
+		if err != nil {
+			return err
+		}
+		in, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer in.Close()
+		_, err = io.Copy(w, in)
+		return err
+	})
+	zw.Close()
+	f.Close()
+
+	info, dir, err := Restore(archive, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r := Verify(dir, info, -1); !r.Passed() || r.Hashed != 2 {
+		t.Fatalf("report = %+v", r)
+	}
+}
@@ -0,0 +1,144 @@
+package chatlog
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/aspnmy/chatlog/internal/ui/tray"
+	"github.com/aspnmy/chatlog/pkg/util"
+)
+
+const (
+	TrayRefreshInterval = 5 * time.Second
+)
+
+// CommandTray 以系统托盘模式运行，适合不打开终端的用户
+// 启动后会自动开启 HTTP 服务与自动解密（如果已有可用的配置）
+func (m *Manager) CommandTray() error {
+	m.ctx.WeChatInstances = m.wechat.GetWeChatInstances()
+	if ins := accountInstance(m.ctx.WeChatInstances, Account); ins != nil {
+		m.ctx.SwitchCurrent(ins)
+	}
+
+	if m.ctx.WorkDir != "" {
+		if err := m.StartService(); err != nil {
+			log.Err(err).Msg("启动服务失败")
+			m.StopService()
+		}
+	}
+	if m.ctx.DataKey != "" && m.ctx.DataDir != "" && m.ctx.WorkDir != "" {
+		if err := m.StartAutoDecrypt(); err != nil {
+			log.Err(err).Msg("开启自动解密失败")
+		}
+	}
+
+	var t *tray.Tray
+	t = tray.New(m.trayTooltip(), func() []*tray.Item {
+		return m.trayMenu(t)
+	})
+
+	stop := make(chan struct{})
+	defer close(stop)
+	t.OnReady(func() {
+		tick := time.NewTicker(TrayRefreshInterval)
+		defer tick.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-tick.C:
+				t.SetTooltip(m.trayTooltip())
+			}
+		}
+	})
+
+	err := t.Run()
+
+	status := m.ctx.SyncStatus()
+	if status.AutoDecrypt {
+		m.StopAutoDecrypt()
+	}
+	if status.HTTPEnabled {
+		m.stopService()
+	}
+	return err
+}
+
+func (m *Manager) trayTooltip() string {
+	s := m.ctx.SyncStatus()
+	status := "已暂停"
+	if s.AutoDecrypt {
+		status = "同步中"
+	}
+	return fmt.Sprintf("Chatlog %s\n同步: %s\n上次同步: %s", s.Account, status, lastSyncText(s.LastSync))
+}
+
+func lastSyncText(t time.Time) string {
+	if t.IsZero() {
+		return "无"
+	}
+	return t.Format("2006-01-02 15:04:05")
+}
+
+func (m *Manager) trayMenu(t *tray.Tray) []*tray.Item {
+	s := m.ctx.SyncStatus()
+	status := "同步状态: 已暂停"
+	if s.AutoDecrypt {
+		status = "同步状态: 自动同步中"
+	}
+
+	items := []*tray.Item{
+		{Title: "账号: " + s.Account, Disabled: true},
+		{Title: status, Disabled: true},
+		{Title: "上次同步: " + lastSyncText(s.LastSync), Disabled: true},
+		tray.Separator(),
+		{
+			Title:    "打开 Web 界面",
+			Disabled: !s.HTTPEnabled,
+			OnClicked: func() {
+				if err := util.OpenURL("http://" + s.HTTPAddr); err != nil {
+					log.Err(err).Msg("打开 Web 界面失败")
+				}
+			},
+		},
+		{
+			Title: "立即同步",
+			OnClicked: func() {
+				if err := m.DecryptDBFiles(); err != nil {
+					log.Err(err).Msg("同步失败")
+				}
+				t.SetTooltip(m.trayTooltip())
+			},
+		},
+	}
+
+	if s.AutoDecrypt {
+		items = append(items, &tray.Item{
+			Title: "暂停同步",
+			OnClicked: func() {
+				if err := m.StopAutoDecrypt(); err != nil {
+					log.Err(err).Msg("暂停同步失败")
+				}
+				t.SetTooltip(m.trayTooltip())
+			},
+		})
+	} else {
+		items = append(items, &tray.Item{
+			Title: "恢复同步",
+			OnClicked: func() {
+				if err := m.StartAutoDecrypt(); err != nil {
+					log.Err(err).Msg("恢复同步失败")
+				}
+				t.SetTooltip(m.trayTooltip())
+			},
+		})
+	}
+
+	items = append(items,
+		tray.Separator(),
+		&tray.Item{Title: "退出", OnClicked: t.Quit},
+	)
+	return items
+}
@@ -0,0 +1,169 @@
+package chatlog
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/aspnmy/chatlog/internal/chatlog/conf"
+	"github.com/aspnmy/chatlog/internal/model"
+	"github.com/aspnmy/chatlog/pkg/webhook"
+
+	"github.com/rs/zerolog/log"
+)
+
+// WebhookRetryInterval 推送失败后重试的间隔，期间没有同步也会重试
+const WebhookRetryInterval = time.Minute
+
+// WebhookPayload 一次推送的新消息，以 JSON 发送，也是请求体模板的数据
+type WebhookPayload struct {
+	Webhook  string           `json:"webhook"`
+	Account  string           `json:"account"`
+	Count    int              `json:"count"`
+	Messages []*model.Message `json:"messages"`
+	// Text 全部消息的纯文本，便于放入机器人消息，如 {"msgtype": "text", "text": {"content": {{json .Text}}}}
+	Text string `json:"text"`
+}
+
+// webhookSink 一个 Webhook 与已推送到的位置，推送失败时位置不变，之后重试
+type webhookSink struct {
+	conf   conf.WebhookConfig
+	hook   *webhook.Hook
+	cursor model.Cursor
+}
+
+// startWebhooks 按配置启动 Webhook 推送，返回 Webhook 数量，只推送启动之后同步的消息
+func (m *Manager) startWebhooks() (int, error) {
+	configs := m.conf.GetConfig().Webhooks
+	if len(configs) == 0 {
+		return 0, nil
+	}
+	now := model.CursorAt(time.Now())
+	sinks := make([]*webhookSink, 0, len(configs))
+	for _, c := range configs {
+		hook, err := c.Hook()
+		if err != nil {
+			return 0, err
+		}
+		sinks = append(sinks, &webhookSink{conf: c, hook: hook, cursor: now})
+	}
+	m.stopWebhooks()
+	m.webhookStop = make(chan struct{})
+	go m.webhookLoop(m.webhookStop, sinks)
+	return len(sinks), nil
+}
+
+func (m *Manager) stopWebhooks() {
+	if m.webhookStop != nil {
+		close(m.webhookStop)
+		m.webhookStop = nil
+	}
+}
+
+// webhookLoop 每次同步完成后读取各 Webhook 推送位置之后的新消息并推送，直到 stop 关闭
+func (m *Manager) webhookLoop(stop <-chan struct{}, sinks []*webhookSink) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-stop
+		cancel()
+	}()
+	ticker := time.NewTicker(WebhookRetryInterval)
+	defer ticker.Stop()
+	for {
+		synced := m.ctx.SyncSignal()
+		select {
+		case <-stop:
+			return
+		case <-synced:
+		case <-ticker.C:
+		}
+
+		after := sinks[0].cursor
+		for _, s := range sinks[1:] {
+			if s.cursor.Compare(after) < 0 {
+				after = s.cursor
+			}
+		}
+		page, err := m.db.Changes(after, 0)
+		if err != nil {
+			log.Debug().Err(err).Msg("failed to read new messages for webhooks")
+			continue
+		}
+		for _, s := range sinks {
+			if err := m.pushWebhook(ctx, s, model.MessagesAfter(page.Items, s.cursor)); err != nil {
+				log.Err(err).Msgf("failed to post new messages to webhook %s", &s.conf)
+			}
+		}
+	}
+}
+
+// pushWebhook 按 Batch 分批推送符合条件的消息，每批成功后前移推送位置
+func (m *Manager) pushWebhook(ctx context.Context, s *webhookSink, messages []*model.Message) error {
+	if len(messages) == 0 {
+		return nil
+	}
+	last := model.CursorOf(messages[len(messages)-1])
+	matched := make([]*model.Message, 0, len(messages))
+	for _, msg := range messages {
+		if !m.ctx.Locked(msg.Talker) && matchWebhook(&s.conf, msg) {
+			matched = append(matched, msg)
+		}
+	}
+	batch := s.conf.Batch
+	if batch <= 0 {
+		batch = conf.DefaultWebhookBatch
+	}
+	for len(matched) > 0 {
+		n := min(batch, len(matched))
+		payload := m.webhookPayload(&s.conf, matched[:n])
+		if err := s.hook.Post(ctx, payload); err != nil {
+			return err
+		}
+		log.Info().Msgf("posted %d new messages to webhook %s", n, &s.conf)
+		s.cursor = model.CursorOf(matched[n-1])
+		matched = matched[n:]
+	}
+	s.cursor = last
+	return nil
+}
+
+func (m *Manager) webhookPayload(c *conf.WebhookConfig, messages []*model.Message) *WebhookPayload {
+	texts := make([]string, 0, len(messages))
+	for _, msg := range messages {
+		texts = append(texts, msg.PlainText(true, "", m.ctx.HTTPAddr))
+	}
+	return &WebhookPayload{
+		Webhook:  c.String(),
+		Account:  m.ctx.Account,
+		Count:    len(messages),
+		Messages: messages,
+		Text:     strings.TrimSuffix(strings.Join(texts, "\n"), "\n"),
+	}
+}
+
+// matchWebhook 返回消息是否属于 talkers 中的聊天对象且包含任一关键词，未配置的条件不限
+func matchWebhook(c *conf.WebhookConfig, msg *model.Message) bool {
+	if len(c.Talkers) > 0 {
+		found := false
+		for _, talker := range c.Talkers {
+			if talker == msg.Talker || (msg.TalkerName != "" && talker == msg.TalkerName) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if len(c.Keywords) == 0 {
+		return true
+	}
+	content := strings.ToLower(msg.PlainTextContent())
+	for _, keyword := range c.Keywords {
+		if keyword != "" && strings.Contains(content, strings.ToLower(keyword)) {
+			return true
+		}
+	}
+	return false
+}
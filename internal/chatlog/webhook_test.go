@@ -0,0 +1,50 @@
+package chatlog
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/aspnmy/chatlog/internal/chatlog/conf"
+	"github.com/aspnmy/chatlog/internal/chatlog/ctx"
+	"github.com/aspnmy/chatlog/internal/model"
+)
+
+func TestPushWebhook(t *testing.T) {
+	var posted []WebhookPayload
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var p WebhookPayload
+		json.NewDecoder(r.Body).Decode(&p)
+		posted = append(posted, p)
+	}))
+	defer srv.Close()
+
+	c := conf.WebhookConfig{URL: srv.URL, Talkers: []string{"工作群"}, Keywords: []string{"@Alice"}, Batch: 1}
+	hook, err := c.Hook()
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &webhookSink{conf: c, hook: hook}
+	at := time.Date(2024, 1, 5, 9, 0, 0, 0, time.Local)
+	messages := []*model.Message{
+		{Seq: 1, Type: 1, Talker: "1@chatroom", TalkerName: "工作群", Content: "@alice 请看一下", Time: at},
+		{Seq: 2, Type: 1, Talker: "1@chatroom", TalkerName: "工作群", Content: "收到", Time: at},
+		{Seq: 3, Type: 1, Talker: "wxid_b", Content: "@Alice 在吗", Time: at},
+		{Seq: 4, Type: 1, Talker: "1@chatroom", TalkerName: "工作群", Content: "@Alice 开会", Time: at},
+		{Seq: 5, Type: 1, Talker: "1@chatroom", Content: "其他", Time: at},
+	}
+	m := &Manager{ctx: &ctx.Context{Account: "wxid_a"}}
+	if err := m.pushWebhook(context.Background(), s, messages); err != nil {
+		t.Fatal(err)
+	}
+	if len(posted) != 2 || posted[0].Messages[0].Seq != 1 || posted[1].Messages[0].Seq != 4 || posted[0].Account != "wxid_a" {
+		t.Fatalf("posted = %+v", posted)
+	}
+	// 推送位置前移到最后一条消息，不匹配的消息也不再读取
+	if want := model.CursorOf(messages[4]); s.cursor != want {
+		t.Errorf("cursor = %v, want %v", s.cursor, want)
+	}
+}
@@ -0,0 +1,88 @@
+package wechat
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/aspnmy/chatlog/internal/errors"
+	"github.com/aspnmy/chatlog/pkg/filemonitor"
+)
+
+// fileStamp 数据库文件的大小与修改时间，用于轮询时判断文件是否变化
+type fileStamp struct {
+	size    int64
+	modTime time.Time
+}
+
+// StartPolling 每隔 interval 检查数据目录中数据库文件的大小与修改时间，变化的文件重新解密，
+// 用于文件监控不可用的目录（如网络磁盘、虚拟机共享目录），第一次检查只记录当前状态
+func (s *Service) StartPolling(interval time.Duration) error {
+	if s.ctx.ArchiveOnly {
+		return errors.ErrArchiveOnly
+	}
+	dbGroup, err := filemonitor.NewFileGroup("wechat", s.ctx.DataDir, `.*\.db$`, []string{"fts"})
+	if err != nil {
+		return err
+	}
+	s.StopPolling()
+	stop := make(chan struct{})
+	s.pollStop = stop
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		var stamps map[string]fileStamp
+		for {
+			files, err := dbGroup.List()
+			if err != nil {
+				log.Debug().Err(err).Msg("failed to list database files")
+			} else {
+				var changed []string
+				changed, stamps = changedFiles(stamps, files)
+				if len(changed) > 0 {
+					log.Info().Msgf("%d database(s) changed, decrypting", len(changed))
+				}
+				for _, dbFile := range changed {
+					if err := s.decryptDBFile(context.Background(), dbFile); err != nil {
+						log.Debug().Msgf("DecryptDBFile %s failed: %v", dbFile, err)
+					}
+				}
+			}
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return nil
+}
+
+// StopPolling 停止轮询，未启动时不做任何事
+func (s *Service) StopPolling() {
+	if s.pollStop != nil {
+		close(s.pollStop)
+		s.pollStop = nil
+	}
+}
+
+// changedFiles 返回与上一次检查相比新增或大小、修改时间变化的文件，以及本次的状态
+// prev 为 nil 表示第一次检查，不返回变化的文件
+func changedFiles(prev map[string]fileStamp, files []string) ([]string, map[string]fileStamp) {
+	stamps := make(map[string]fileStamp, len(files))
+	var changed []string
+	for _, file := range files {
+		info, err := os.Stat(file)
+		if err != nil {
+			continue
+		}
+		stamp := fileStamp{size: info.Size(), modTime: info.ModTime()}
+		stamps[file] = stamp
+		if old, ok := prev[file]; prev != nil && (!ok || old != stamp) {
+			changed = append(changed, file)
+		}
+	}
+	return changed, stamps
+}
@@ -0,0 +1,36 @@
+package wechat
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestChangedFiles(t *testing.T) {
+	dir := t.TempDir()
+	a, b, c := filepath.Join(dir, "a.db"), filepath.Join(dir, "b.db"), filepath.Join(dir, "c.db")
+	os.WriteFile(a, []byte("a"), 0644)
+	os.WriteFile(b, []byte("b"), 0644)
+
+	// 第一次检查只记录状态
+	changed, stamps := changedFiles(nil, []string{a, b})
+	if len(changed) != 0 || len(stamps) != 2 {
+		t.Fatalf("first check: changed = %v, stamps = %v", changed, stamps)
+	}
+
+	os.WriteFile(b, []byte("bb"), 0644)
+	os.WriteFile(c, []byte("c"), 0644)
+	later := time.Now().Add(time.Minute)
+	os.Chtimes(a, later, later)
+	changed, _ = changedFiles(stamps, []string{a, b, c})
+	if want := []string{a, b, c}; !reflect.DeepEqual(changed, want) {
+		t.Errorf("changed = %v, want %v", changed, want)
+	}
+
+	_, stamps = changedFiles(stamps, []string{a, b, c})
+	if changed, _ = changedFiles(stamps, []string{a, b, c}); len(changed) != 0 {
+		t.Errorf("unchanged files reported: %v", changed)
+	}
+}
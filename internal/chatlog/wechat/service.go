@@ -1,8 +1,10 @@
 package wechat
 
 import (
+	"bufio"
 	"context"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"sync"
@@ -15,13 +17,24 @@ import (
 	"github.com/aspnmy/chatlog/internal/errors"
 	"github.com/aspnmy/chatlog/internal/wechat"
 	"github.com/aspnmy/chatlog/internal/wechat/decrypt"
+	"github.com/aspnmy/chatlog/internal/wechat/decrypt/common"
 	"github.com/aspnmy/chatlog/pkg/filemonitor"
+	"github.com/aspnmy/chatlog/pkg/membudget"
+	"github.com/aspnmy/chatlog/pkg/throttle"
+	"github.com/aspnmy/chatlog/pkg/trace"
 	"github.com/aspnmy/chatlog/pkg/util"
 )
 
 var (
 	DebounceTime = 1 * time.Second
 	MaxWaitTime  = 10 * time.Second
+
+	// DecryptBufferSize 解密输出的写缓冲区大小，内存预算不足时自动缩小
+	DecryptBufferSize int64 = 4 * 1024 * 1024
+
+	// IncrementalDecrypt 重新解密时以上一次的解密结果为基础，只解密加密内容变化的页面，
+	// 各页面的校验和保存在解密输出旁的 .pages 文件中
+	IncrementalDecrypt bool
 )
 
 type Service struct {
@@ -30,6 +43,9 @@ type Service struct {
 	pendingActions map[string]bool
 	mutex          sync.Mutex
 	fm             *filemonitor.FileMonitor
+
+	// 轮询解密，见 StartPolling
+	pollStop chan struct{}
 }
 
 func NewService(ctx *ctx.Context) *Service {
@@ -41,13 +57,20 @@ func NewService(ctx *ctx.Context) *Service {
 }
 
 // GetWeChatInstances returns all running WeChat instances
+// 归档模式下不查找微信进程，始终返回空
 func (s *Service) GetWeChatInstances() []*wechat.Account {
+	if s.ctx.ArchiveOnly {
+		return nil
+	}
 	wechat.Load()
 	return wechat.GetAccounts()
 }
 
 // GetDataKey extracts the encryption key from a WeChat process
 func (s *Service) GetDataKey(info *wechat.Account) (string, error) {
+	if s.ctx.ArchiveOnly {
+		return "", errors.ErrArchiveOnly
+	}
 	if info == nil {
 		return "", fmt.Errorf("no WeChat instance selected")
 	}
@@ -61,6 +84,9 @@ func (s *Service) GetDataKey(info *wechat.Account) (string, error) {
 }
 
 func (s *Service) StartAutoDecrypt() error {
+	if s.ctx.ArchiveOnly {
+		return errors.ErrArchiveOnly
+	}
 	dbGroup, err := filemonitor.NewFileGroup("wechat", s.ctx.DataDir, `.*\.db$`, []string{"fts"})
 	if err != nil {
 		return err
@@ -128,8 +154,20 @@ func (s *Service) waitAndProcess(dbFile string) {
 }
 
 func (s *Service) DecryptDBFile(dbFile string) error {
+	return s.decryptDBFile(context.Background(), dbFile)
+}
+
+func (s *Service) decryptDBFile(ctx context.Context, dbFile string) (err error) {
+	ctx, span := trace.Start(ctx, "decrypt.file")
+	span.SetAttr("file", dbFile)
+	if info, err := os.Stat(dbFile); err == nil {
+		span.SetAttr("bytes", info.Size())
+	}
+	defer func() {
+		span.SetError(err).End()
+	}()
 
-	decryptor, err := decrypt.NewDecryptor(s.ctx.Platform, s.ctx.Version)
+	decryptor, err := decrypt.NewDecryptorWithCipher(s.ctx.Platform, s.ctx.Version, s.ctx.Cipher)
 	if err != nil {
 		return err
 	}
@@ -139,35 +177,167 @@ func (s *Service) DecryptDBFile(dbFile string) error {
 		return err
 	}
 
+	// 增量解密遇到未加密的数据库时按原来的方式直接复制
+	if IncrementalDecrypt {
+		if err := s.decryptIncremental(ctx, decryptor, dbFile, output); err != errors.ErrAlreadyDecrypted {
+			if err != nil {
+				log.Err(err).Msgf("failed to decrypt %s", dbFile)
+				return err
+			}
+			s.ctx.Synced()
+			return nil
+		}
+	}
+
 	outputTemp := output + ".tmp"
 	outputFile, err := os.Create(outputTemp)
 	if err != nil {
 		return fmt.Errorf("failed to create output file: %v", err)
 	}
-	defer func() {
+
+	fail := func(err error) error {
 		outputFile.Close()
-		if err := os.Rename(outputTemp, output); err != nil {
-			log.Debug().Err(err).Msgf("failed to rename %s to %s", outputTemp, output)
-		}
-	}()
+		os.Remove(outputTemp)
+		return err
+	}
 
-	if err := decryptor.Decrypt(context.Background(), dbFile, s.ctx.DataKey, outputFile); err != nil {
-		if err == errors.ErrAlreadyDecrypted {
-			if data, err := os.ReadFile(dbFile); err == nil {
-				outputFile.Write(data)
-			}
-			return nil
+	bufSize, err := membudget.Default.Acquire(ctx, membudget.Default.ChunkSize(DecryptBufferSize, 64*1024))
+	if err != nil {
+		return fail(err)
+	}
+	defer membudget.Default.Release(bufSize)
+	writer := bufio.NewWriterSize(throttle.Writer(outputFile), int(bufSize))
+
+	// 全部写入成功后才替换解密输出，避免写入失败时用不完整的文件覆盖上一次的结果
+	synced := true
+	if err := decryptor.Decrypt(ctx, dbFile, s.ctx.DataKey, writer); err != nil {
+		if err != errors.ErrAlreadyDecrypted {
+			log.Err(err).Msgf("failed to decrypt %s", dbFile)
+			return fail(err)
+		}
+		// 流式复制，避免大文件整体读入内存
+		if err := copyTo(writer, dbFile); err != nil {
+			log.Err(err).Msgf("failed to copy %s", dbFile)
+			return fail(err)
 		}
-		log.Err(err).Msgf("failed to decrypt %s", dbFile)
+		synced = false
+	}
+	if err := writer.Flush(); err != nil {
+		return fail(err)
+	}
+	if err := outputFile.Close(); err != nil {
+		return fail(err)
+	}
+	if err := os.Rename(outputTemp, output); err != nil {
+		os.Remove(outputTemp)
 		return err
 	}
+	if !synced {
+		return nil
+	}
 
 	log.Debug().Msgf("Decrypted %s to %s", dbFile, output)
+	s.ctx.Synced()
+
+	return nil
+}
+
+// decryptIncremental 在上一次解密结果的副本上只重写变化的页面，完成后替换解密输出
+// 上一次的结果不存在或已被修改时完整解密，同时记录页面校验和供下次使用
+func (s *Service) decryptIncremental(ctx context.Context, decryptor decrypt.Decryptor, dbFile, output string) error {
+	sumsPath := output + common.PageSumsExt
+	prev, err := common.ReadPageSums(sumsPath)
+	if err != nil || !prev.Matches(output) {
+		prev = nil
+	}
+
+	// 复制不经过限速，Linux 上由内核直接完成（copy_file_range）
+	outputTemp := output + ".tmp"
+	if prev != nil {
+		if err := copyFile(output, outputTemp); err != nil {
+			log.Debug().Err(err).Msgf("failed to copy %s, decrypt in full", output)
+			prev = nil
+		}
+	}
+	flag := os.O_RDWR | os.O_CREATE
+	if prev == nil {
+		flag |= os.O_TRUNC
+	}
+	outputFile, err := os.OpenFile(outputTemp, flag, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to create output file: %v", err)
+	}
+	fail := func(err error) error {
+		outputFile.Close()
+		os.Remove(outputTemp)
+		return err
+	}
+
+	bufSize, err := membudget.Default.Acquire(ctx, membudget.Default.ChunkSize(DecryptBufferSize, 64*1024))
+	if err != nil {
+		return fail(err)
+	}
+	defer membudget.Default.Release(bufSize)
+
+	out := common.NewIncremental(outputFile, throttle.Writer(outputFile), int(bufSize), decryptor.GetPageSize(), prev)
+	if err := decryptor.Decrypt(ctx, dbFile, s.ctx.DataKey, out); err != nil {
+		os.Remove(sumsPath)
+		return fail(err)
+	}
+	if err := out.Finish(); err != nil {
+		return fail(err)
+	}
+	if err := outputFile.Close(); err != nil {
+		return fail(err)
+	}
+	if err := os.Rename(outputTemp, output); err != nil {
+		os.Remove(outputTemp)
+		return err
+	}
 
+	sums, err := out.PageSums(output)
+	if err == nil {
+		err = sums.Write(sumsPath)
+	}
+	if err != nil {
+		log.Debug().Err(err).Msgf("failed to save page sums of %s", output)
+	}
+	log.Debug().Msgf("Decrypted %s to %s, %d pages unchanged", dbFile, output, out.Skipped)
 	return nil
 }
 
+// copyTo 将 src 的内容写入 w
+func copyTo(w io.Writer, src string) error {
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(w, f)
+	return err
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
 func (s *Service) DecryptDBFiles() error {
+	if s.ctx.ArchiveOnly {
+		return errors.ErrArchiveOnly
+	}
 	dbGroup, err := filemonitor.NewFileGroup("wechat", s.ctx.DataDir, `.*\.db$`, []string{"fts"})
 	if err != nil {
 		return err
@@ -178,12 +348,27 @@ func (s *Service) DecryptDBFiles() error {
 		return err
 	}
 
+	ctx, span := trace.Start(context.Background(), "decrypt.files")
+	span.SetAttr("files", len(dbFiles)).SetAttr("workers", throttle.Workers())
+	defer span.End()
+
+	// 按 --workers 限制并发解密的文件数
+	sem := make(chan struct{}, throttle.Workers())
+	var wg sync.WaitGroup
 	for _, dbFile := range dbFiles {
-		if err := s.DecryptDBFile(dbFile); err != nil {
-			log.Debug().Msgf("DecryptDBFile %s failed: %v", dbFile, err)
-			continue
-		}
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(dbFile string) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			if err := s.decryptDBFile(ctx, dbFile); err != nil {
+				log.Debug().Msgf("DecryptDBFile %s failed: %v", dbFile, err)
+			}
+		}(dbFile)
 	}
+	wg.Wait()
 
 	return nil
 }
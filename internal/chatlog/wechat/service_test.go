@@ -0,0 +1,43 @@
+package wechat
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aspnmy/chatlog/internal/chatlog/ctx"
+)
+
+func TestDecryptDBFileKeepsOutputOnError(t *testing.T) {
+	dataDir, workDir := t.TempDir(), t.TempDir()
+	dbFile := filepath.Join(dataDir, "db_storage", "message", "message_0.db")
+	output := filepath.Join(workDir, "db_storage", "message", "message_0.db")
+	os.MkdirAll(filepath.Dir(dbFile), 0755)
+	os.MkdirAll(filepath.Dir(output), 0755)
+	// 随机内容不是可解密的数据库
+	if err := os.WriteFile(dbFile, bytes.Repeat([]byte{0x5a}, 8192), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(output, []byte("previous"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	s := NewService(&ctx.Context{
+		Platform: "windows",
+		Version:  4,
+		DataDir:  dataDir,
+		WorkDir:  workDir,
+		DataKey:  strings.Repeat("00", 32),
+	})
+	if err := s.DecryptDBFile(dbFile); err == nil {
+		t.Fatal("DecryptDBFile() succeeded on an invalid database")
+	}
+	if data, err := os.ReadFile(output); err != nil || string(data) != "previous" {
+		t.Fatalf("output = %q, %v, want previous content kept", data, err)
+	}
+	if _, err := os.Stat(output + ".tmp"); !os.IsNotExist(err) {
+		t.Fatalf("temporary file left behind: %v", err)
+	}
+}
@@ -0,0 +1,18 @@
+//go:build cgo
+
+package errors
+
+import (
+	"errors"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// IsBusy 判断错误是否由 SQLITE_BUSY 或 SQLITE_LOCKED 引起
+func IsBusy(err error) bool {
+	var se sqlite3.Error
+	if !errors.As(err, &se) {
+		return false
+	}
+	return se.Code == sqlite3.ErrBusy || se.Code == sqlite3.ErrLocked
+}
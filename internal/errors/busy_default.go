@@ -0,0 +1,8 @@
+//go:build !cgo
+
+package errors
+
+// IsBusy 判断错误是否由 SQLITE_BUSY 或 SQLITE_LOCKED 引起，不使用 cgo 构建时无法访问数据库，始终返回 false
+func IsBusy(err error) bool {
+	return false
+}
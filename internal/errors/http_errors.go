@@ -9,3 +9,6 @@ func InvalidArg(arg string) error {
 func HTTPShutDown(cause error) error {
 	return Newf(cause, http.StatusInternalServerError, "http server shut down")
 }
+
+// ErrTalkerLocked 请求涉及锁定的会话，但未提供正确的口令
+var ErrTalkerLocked = New(nil, http.StatusForbidden, "talker is locked, passphrase required").WithStack()
@@ -14,8 +14,15 @@ var (
 	ErrValidatorNotSet               = New(nil, http.StatusBadRequest, "validator not set")
 	ErrNoValidKey                    = New(nil, http.StatusBadRequest, "no valid key found")
 	ErrWeChatDLLNotFound             = New(nil, http.StatusBadRequest, "WeChatWin.dll module not found")
+	ErrScanLimitReached              = New(nil, http.StatusBadRequest, "memory scan limit reached")
+	ErrArchiveOnly                   = New(nil, http.StatusBadRequest, "not available in archive-only mode")
 )
 
+// PartialKey 密钥提取因超时、取消或达到扫描上限而中断，已找到的密钥会与该错误一并返回
+func PartialKey(cause error) *Error {
+	return New(cause, http.StatusInternalServerError, "key extraction interrupted, result may be incomplete").WithStack()
+}
+
 func PlatformUnsupported(platform string, version int) *Error {
 	return Newf(nil, http.StatusBadRequest, "unsupported platform: %s v%d", platform, version).WithStack()
 }
@@ -60,6 +67,11 @@ func WeChatAccountNotOnline(name string) *Error {
 	return Newf(nil, http.StatusBadRequest, "WeChat account is not online: %s", name).WithStack()
 }
 
+// WeChatAccountUnusable 账号缺少工作目录或密钥等，无法切换到该账号
+func WeChatAccountUnusable(name, reason string) *Error {
+	return Newf(nil, http.StatusBadRequest, "WeChat account %s cannot be used: %s", name, reason).WithStack()
+}
+
 func RefreshProcessStatusFailed(cause error) *Error {
 	return New(cause, http.StatusInternalServerError, "failed to refresh process status").WithStack()
 }
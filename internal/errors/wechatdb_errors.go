@@ -29,15 +29,30 @@ func TalkerNotFound(talker string) *Error {
 	return Newf(nil, http.StatusNotFound, "talker not found: %s", talker).WithStack()
 }
 
+func MessageNotFound(talker string, seq int64) *Error {
+	return Newf(nil, http.StatusNotFound, "message not found: %s %d", talker, seq).WithStack()
+}
+
 func DBCloseFailed(cause error) *Error {
 	return New(cause, http.StatusInternalServerError, "db close failed").WithStack()
 }
 
+// DBBusy 数据库被其他连接锁定，常见于导出与 API 并发访问，稍后重试即可
+func DBBusy(path string, cause error) *Error {
+	return Newf(cause, http.StatusServiceUnavailable, "db busy, try again later: %s", path).WithStack()
+}
+
 func QueryFailed(query string, cause error) *Error {
+	if IsBusy(cause) {
+		return Newf(cause, http.StatusServiceUnavailable, "query failed, db busy, try again later: %s", query).WithStack()
+	}
 	return Newf(cause, http.StatusInternalServerError, "query failed: %s", query).WithStack()
 }
 
 func ScanRowFailed(cause error) *Error {
+	if IsBusy(cause) {
+		return New(cause, http.StatusServiceUnavailable, "scan row failed, db busy, try again later").WithStack()
+	}
 	return New(cause, http.StatusInternalServerError, "scan row failed").WithStack()
 }
 
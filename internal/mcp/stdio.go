@@ -1 +1,72 @@
 package mcp
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"sync"
+
+	"github.com/rs/zerolog/log"
+)
+
+// StdioSessionID stdio 传输只有一个会话
+const StdioSessionID = "stdio"
+
+// StdioMaxLineSize 单条请求的最大长度
+const StdioMaxLineSize = 16 * 1024 * 1024
+
+// StdioWriter 按 stdio 传输的格式写出消息，每条 JSON-RPC 消息占一行
+// Documents: https://modelcontextprotocol.io/docs/concepts/transports#standard-input%2Foutput-stdio
+type StdioWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func NewStdioWriter(w io.Writer) *StdioWriter {
+	return &StdioWriter{w: w}
+}
+
+func (w *StdioWriter) Write(p []byte) (n int, err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	line := make([]byte, 0, len(p)+1)
+	line = append(line, p...)
+	line = append(line, '\n')
+	if _, err := w.w.Write(line); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// ServeStdio 从 r 逐行读取请求，交给 ProcessChan 处理，响应写入 w
+// r 读到 EOF（客户端关闭了标准输入）时返回 nil，stdout 只能写 MCP 消息，日志需输出到 stderr
+func (m *MCP) ServeStdio(r io.Reader, w io.Writer) error {
+	session := &Session{id: StdioSessionID, w: NewStdioWriter(w)}
+	m.sessionMu.Lock()
+	m.sessions[StdioSessionID] = session
+	m.sessionMu.Unlock()
+	defer func() {
+		m.sessionMu.Lock()
+		delete(m.sessions, StdioSessionID)
+		m.sessionMu.Unlock()
+	}()
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), StdioMaxLineSize)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		var req Request
+		if err := json.Unmarshal(line, &req); err != nil {
+			b, _ := json.Marshal(ErrParseError.JsonRPC())
+			session.Write(b)
+			continue
+		}
+		log.Debug().Msgf("session: %s, request: %s", StdioSessionID, req)
+		// stdio 只有一个客户端，队列满时等待而不是拒绝请求
+		m.ProcessChan <- ProcessCtx{Session: session, Request: &req}
+	}
+	return scanner.Err()
+}
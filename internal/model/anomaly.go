@@ -0,0 +1,68 @@
+package model
+
+import "time"
+
+// 消息时间异常类型
+const (
+	TimeAnomalyFuture     = "future"       // 时间晚于当前时间
+	TimeAnomalyOutOfOrder = "out_of_order" // 时间早于同一会话中排在它之前的消息
+)
+
+var (
+	// TimeSkewTolerance 同一会话中允许的时间倒退，设备间时钟的正常误差不视为异常
+	TimeSkewTolerance = 10 * time.Minute
+
+	// FutureTolerance 允许消息时间超过当前时间的范围，避免时区或时钟的小误差误报
+	FutureTolerance = 24 * time.Hour
+)
+
+// DetectTimeAnomalies 检测设备时钟错误导致的时间异常，并设置 TimeAnomaly，返回异常消息数
+// messages 需按会话内的顺序（seq）排列；不同会话的消息分别比较，异常消息不作为后续比较的基准
+func DetectTimeAnomalies(messages []*Message, now time.Time) int {
+	last := make(map[string]time.Time)
+	count := 0
+	for _, m := range messages {
+		switch prev, ok := last[m.Talker]; {
+		case m.Time.After(now.Add(FutureTolerance)):
+			m.TimeAnomaly = TimeAnomalyFuture
+		case ok && m.Time.Before(prev.Add(-TimeSkewTolerance)):
+			m.TimeAnomaly = TimeAnomalyOutOfOrder
+		default:
+			m.TimeAnomaly = ""
+			last[m.Talker] = m.Time
+			continue
+		}
+		count++
+	}
+	return count
+}
+
+// NormalizeTimes 将时间异常的消息修正为同一会话中前一条正常消息的时间，原始时间保存在 OriginalTime
+// 会话中第一条正常消息之前的异常消息使用其后第一条正常消息的时间
+func NormalizeTimes(messages []*Message) {
+	next := make(map[string]time.Time)
+	for i := len(messages) - 1; i >= 0; i-- {
+		if m := messages[i]; m.TimeAnomaly == "" {
+			next[m.Talker] = m.Time
+		}
+	}
+
+	last := make(map[string]time.Time)
+	for _, m := range messages {
+		if m.TimeAnomaly == "" {
+			last[m.Talker] = m.Time
+			continue
+		}
+		t, ok := last[m.Talker]
+		if !ok {
+			if t, ok = next[m.Talker]; !ok {
+				continue
+			}
+		}
+		if m.OriginalTime == nil {
+			original := m.Time
+			m.OriginalTime = &original
+		}
+		m.Time = t
+	}
+}
@@ -21,6 +21,7 @@ type ChatRoom struct {
 type ChatRoomUser struct {
 	UserName    string `json:"userName"`
 	DisplayName string `json:"displayName"`
+	Inviter     string `json:"inviter"` // 邀请人，只有 roomdata 中有记录
 }
 
 // CREATE TABLE ChatRoom(
@@ -84,6 +85,7 @@ func (c *ChatRoomV3) Wrap() *ChatRoom {
 	}
 }
 
+// ParseRoomData 解析 v3 RoomData 与 v4 ext_buffer 中 protobuf 编码的群成员列表，解析失败时返回空
 func ParseRoomData(b []byte) (users []ChatRoomUser) {
 	var pbMsg wxproto.RoomData
 	if err := proto.Unmarshal(b, &pbMsg); err != nil {
@@ -99,6 +101,9 @@ func ParseRoomData(b []byte) (users []ChatRoomUser) {
 		if user.DisplayName != nil {
 			u.DisplayName = *user.DisplayName
 		}
+		if user.Inviter != nil {
+			u.Inviter = *user.Inviter
+		}
 		users = append(users, u)
 	}
 	return users
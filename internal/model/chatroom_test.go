@@ -0,0 +1,31 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/aspnmy/chatlog/internal/model/wxproto"
+
+	"google.golang.org/protobuf/proto"
+)
+
+func TestParseRoomData(t *testing.T) {
+	name, inviter := "小明", "wxid_a"
+	b, err := proto.Marshal(&wxproto.RoomData{Users: []*wxproto.RoomDataUser{
+		{UserName: "wxid_a"},
+		{UserName: "wxid_b", DisplayName: &name, Inviter: &inviter},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	room := (&ChatRoomV4{UserName: "1@chatroom", Owner: "wxid_a", ExtBuffer: b}).Wrap()
+	want := []ChatRoomUser{{UserName: "wxid_a"}, {UserName: "wxid_b", DisplayName: "小明", Inviter: "wxid_a"}}
+	if len(room.Users) != 2 || room.Users[0] != want[0] || room.Users[1] != want[1] {
+		t.Fatalf("users = %+v, want %+v", room.Users, want)
+	}
+	if len(room.User2DisplayName) != 1 || room.User2DisplayName["wxid_b"] != "小明" {
+		t.Errorf("User2DisplayName = %v", room.User2DisplayName)
+	}
+	if users := ParseRoomData([]byte{0xff, 0x01}); users != nil {
+		t.Errorf("ParseRoomData(invalid) = %+v", users)
+	}
+}
@@ -6,6 +6,10 @@ type Contact struct {
 	Remark   string `json:"remark"`
 	NickName string `json:"nickName"`
 	IsFriend bool   `json:"isFriend"`
+
+	// 头像地址，HD 为大图，本地数据中没有时为空
+	Avatar   string `json:"avatar"`
+	AvatarHD string `json:"avatarHD"`
 }
 
 // CREATE TABLE Contact(
@@ -47,6 +51,9 @@ type ContactV3 struct {
 	Remark    string `json:"Remark"`
 	NickName  string `json:"NickName"`
 	Reserved1 int    `json:"Reserved1"` // 1 自己好友或自己加入的群聊; 0 群聊成员(非好友)
+
+	SmallHeadImgUrl string `json:"SmallHeadImgUrl"`
+	BigHeadImgUrl   string `json:"BigHeadImgUrl"`
 }
 
 func (c *ContactV3) Wrap() *Contact {
@@ -56,6 +63,8 @@ func (c *ContactV3) Wrap() *Contact {
 		Remark:   c.Remark,
 		NickName: c.NickName,
 		IsFriend: c.Reserved1 == 1,
+		Avatar:   c.SmallHeadImgUrl,
+		AvatarHD: c.BigHeadImgUrl,
 	}
 }
 
@@ -40,6 +40,9 @@ type ContactDarwinV3 struct {
 	M_nsRemark    string `json:"m_nsRemark"`
 	M_uiSex       int    `json:"m_uiSex"`
 	M_nsAliasName string `json:"m_nsAliasName"`
+
+	M_nsHeadImgUrl   string `json:"m_nsHeadImgUrl"`
+	M_nsHeadHDImgUrl string `json:"m_nsHeadHDImgUrl"`
 }
 
 func (c *ContactDarwinV3) Wrap() *Contact {
@@ -49,5 +52,7 @@ func (c *ContactDarwinV3) Wrap() *Contact {
 		Remark:   c.M_nsRemark,
 		NickName: c.Nickname,
 		IsFriend: true,
+		Avatar:   c.M_nsHeadImgUrl,
+		AvatarHD: c.M_nsHeadHDImgUrl,
 	}
 }
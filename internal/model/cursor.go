@@ -0,0 +1,110 @@
+package model
+
+import (
+	"cmp"
+	"encoding/base64"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 消息的全序与游标
+//
+// 归档中的消息统一按 (Seq, Talker) 排序。Seq 以消息的秒级时间戳乘 1000 为基础，低位区分同一秒内的消息，
+// 在同一会话中唯一且随消息顺序递增；不同会话的 Seq 可能相同，再按 Talker 区分。
+// 因此 (Talker, Seq) 是消息在一个账号归档中的唯一标识（见 MessageID），(Seq, Talker) 是接口分页、
+// 长轮询与增量导出共用的顺序。
+//
+// Cursor 记录读取到的位置，编码为不透明的字符串，之后的读取只返回排在它之后的消息。
+// 与 offset 分页不同，两次读取之间同步进来的新消息不会导致已读的消息重复返回。
+// 全序按消息时间而不是同步的先后排列：较晚才同步进来、时间早于游标的消息（如从手机迁移到电脑的聊天记录、
+// 较晚解密的数据库文件）排在游标之前，之后不会再通过游标返回，需要按时间范围重新读取。
+
+// cursorVersion 游标编码的版本，编码方式变化时递增，旧游标仍可解析
+const cursorVersion = "1"
+
+// Cursor 消息在全序中的位置，零值表示归档的起点
+type Cursor struct {
+	Seq    int64
+	Talker string
+}
+
+// CursorOf 返回消息所在的位置
+func CursorOf(m *Message) Cursor {
+	return Cursor{Seq: m.Seq, Talker: m.Talker}
+}
+
+// CursorAt 返回时间 t 的起点，之后读取 t 及以后的消息
+func CursorAt(t time.Time) Cursor {
+	return Cursor{Seq: t.Unix() * 1000}
+}
+
+// IsZero 是否为归档的起点
+func (c Cursor) IsZero() bool {
+	return c.Seq == 0 && c.Talker == ""
+}
+
+// Compare 比较两个位置的先后，c 在前时返回负数
+func (c Cursor) Compare(o Cursor) int {
+	return cmp.Or(cmp.Compare(c.Seq, o.Seq), strings.Compare(c.Talker, o.Talker))
+}
+
+// Before 消息是否排在游标之后
+func (c Cursor) Before(m *Message) bool {
+	return c.Compare(CursorOf(m)) < 0
+}
+
+// String 返回不透明的游标字符串，零值返回空字符串
+func (c Cursor) String() string {
+	if c.IsZero() {
+		return ""
+	}
+	raw := cursorVersion + "." + strconv.FormatInt(c.Seq, 10) + "." + c.Talker
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// ParseCursor 解析 Cursor.String 返回的游标，空字符串返回零值
+func ParseCursor(s string) (Cursor, error) {
+	if s == "" {
+		return Cursor{}, nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return Cursor{}, fmt.Errorf("invalid cursor")
+	}
+	parts := strings.SplitN(string(raw), ".", 3)
+	if len(parts) != 3 || parts[0] != cursorVersion {
+		return Cursor{}, fmt.Errorf("invalid cursor")
+	}
+	seq, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return Cursor{}, fmt.Errorf("invalid cursor")
+	}
+	return Cursor{Seq: seq, Talker: parts[2]}, nil
+}
+
+// CompareMessages 按 (Seq, Talker) 比较两条消息
+func CompareMessages(a, b *Message) int {
+	return CursorOf(a).Compare(CursorOf(b))
+}
+
+// SortMessages 将消息按全序排列
+func SortMessages(messages []*Message) {
+	slices.SortStableFunc(messages, CompareMessages)
+}
+
+// MessagesAfter 返回排在游标之后的消息，messages 需已按全序排列
+func MessagesAfter(messages []*Message, c Cursor) []*Message {
+	if c.IsZero() {
+		return messages
+	}
+	i, _ := slices.BinarySearchFunc(messages, c, func(m *Message, c Cursor) int {
+		if c.Before(m) {
+			return 1
+		}
+		return -1
+	})
+	return messages[i:]
+}
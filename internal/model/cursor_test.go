@@ -0,0 +1,47 @@
+package model
+
+import "testing"
+
+func TestCursor(t *testing.T) {
+	c := Cursor{Seq: 1700000000001, Talker: "123@chatroom"}
+	got, err := ParseCursor(c.String())
+	if err != nil || got != c {
+		t.Fatalf("ParseCursor(%q) = %+v, %v", c.String(), got, err)
+	}
+	if got, err := ParseCursor(""); err != nil || !got.IsZero() {
+		t.Fatalf("ParseCursor(\"\") = %+v, %v", got, err)
+	}
+	for _, s := range []string{"abc", Cursor{Seq: 1}.String()[:2]} {
+		if _, err := ParseCursor(s); err == nil {
+			t.Errorf("ParseCursor(%q) succeeded", s)
+		}
+	}
+}
+
+func TestMessagesAfter(t *testing.T) {
+	messages := []*Message{
+		{Seq: 2000, Talker: "b"},
+		{Seq: 1000, Talker: "b"},
+		{Seq: 2000, Talker: "a"},
+		{Seq: 3000, Talker: "a"},
+	}
+	SortMessages(messages)
+	want := []Cursor{{1000, "b"}, {2000, "a"}, {2000, "b"}, {3000, "a"}}
+	for i, m := range messages {
+		if CursorOf(m) != want[i] {
+			t.Fatalf("order[%d] = %+v, want %+v", i, CursorOf(m), want[i])
+		}
+	}
+
+	// 同一 seq 的不同会话不会因游标而遗漏或重复
+	after := MessagesAfter(messages, Cursor{2000, "a"})
+	if len(after) != 2 || CursorOf(after[0]) != (Cursor{2000, "b"}) {
+		t.Fatalf("MessagesAfter = %v", after)
+	}
+	if n := len(MessagesAfter(messages, Cursor{})); n != 4 {
+		t.Fatalf("MessagesAfter zero cursor = %d", n)
+	}
+	if n := len(MessagesAfter(messages, CursorOf(messages[3]))); n != 0 {
+		t.Fatalf("MessagesAfter last = %d", n)
+	}
+}
@@ -0,0 +1,328 @@
+package model
+
+import (
+	"cmp"
+	"fmt"
+	"time"
+)
+
+// 消息内容的种类，见 Body.Kind
+const (
+	KindText        = "text"        // 文字
+	KindImage       = "image"       // 图片
+	KindVoice       = "voice"       // 语音
+	KindVideo       = "video"       // 视频
+	KindEmoji       = "emoji"       // 动画表情
+	KindCard        = "card"        // 名片
+	KindLocation    = "location"    // 位置
+	KindCall        = "call"        // 语音/视频通话
+	KindLink        = "link"        // 链接
+	KindFile        = "file"        // 文件
+	KindForward     = "forward"     // 合并转发
+	KindMiniProgram = "miniprogram" // 小程序
+	KindChannels    = "channels"    // 视频号
+	KindQuote       = "quote"       // 引用回复
+	KindPat         = "pat"         // 拍一拍
+	KindTransfer    = "transfer"    // 转账
+	KindRedPacket   = "redpacket"   // 红包
+	KindRevoke      = "revoke"      // 撤回提示
+	KindSystem      = "system"      // 系统消息
+	KindOther       = "other"       // 其他未解码的消息
+)
+
+// 转账方向，见 PayBody.Direction
+const (
+	PaySend    = "send"
+	PayReceive = "receive"
+	PayRefund  = "refund"
+)
+
+// Body 消息内容解码后的结构化形式，Kind 为消息种类，其余字段只在对应种类中出现
+// 导出与 API 输出 Body 而不是原始的 XML
+type Body struct {
+	Kind string `json:"kind"`
+	Text string `json:"text,omitempty"` // 文字、引用回复、拍一拍、通话结果、撤回提示与系统消息的文字
+
+	Media    *MediaBody    `json:"media,omitempty"`    // 图片、语音、视频、动画表情
+	Card     *CardBody     `json:"card,omitempty"`     // 名片
+	Location *LocationBody `json:"location,omitempty"` // 位置
+	Link     *LinkBody     `json:"link,omitempty"`     // 链接、小程序、视频号
+	File     *FileBody     `json:"file,omitempty"`     // 文件
+	Forward  *ForwardBody  `json:"forward,omitempty"`  // 合并转发
+	Quote    *QuoteBody    `json:"quote,omitempty"`    // 引用回复中被引用的消息
+	Pay      *PayBody      `json:"pay,omitempty"`      // 转账、红包
+	Revoke   string        `json:"revoke,omitempty"`   // 撤回提示中被撤回消息的服务器 ID
+
+	Type    int64 `json:"type,omitempty"`    // 其他消息的原始类型
+	SubType int64 `json:"subType,omitempty"` // 其他消息的原始子类型
+}
+
+// MediaBody 图片、语音、视频与动画表情，MD5 与 Key 用于从 /image、/voice、/video 接口获取文件
+type MediaBody struct {
+	MD5      string `json:"md5,omitempty"`
+	Key      string `json:"key,omitempty"`      // 语音的服务器 ID
+	Path     string `json:"path,omitempty"`     // 图片或视频文件在数据目录中的相对路径
+	Thumb    string `json:"thumb,omitempty"`    // 缩略图的相对路径
+	URL      string `json:"url,omitempty"`      // 动画表情的下载地址
+	Duration int64  `json:"duration,omitempty"` // 语音时长，毫秒
+}
+
+// CardBody 名片
+type CardBody struct {
+	UserName string `json:"userName"`
+	NickName string `json:"nickName"`
+}
+
+// LocationBody 位置
+type LocationBody struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	Label     string  `json:"label,omitempty"` // 地址
+	POI       string  `json:"poi,omitempty"`   // 地点名称
+}
+
+// LinkBody 链接、小程序与视频号
+type LinkBody struct {
+	Title  string `json:"title"`
+	Desc   string `json:"desc,omitempty"`
+	URL    string `json:"url,omitempty"`
+	Source string `json:"source,omitempty"` // 来源公众号或应用
+}
+
+// FileBody 文件
+type FileBody struct {
+	Name string `json:"name"`
+	Ext  string `json:"ext,omitempty"`
+	Size int64  `json:"size,omitempty"` // 字节
+	MD5  string `json:"md5,omitempty"`
+}
+
+// ForwardBody 合并转发
+type ForwardBody struct {
+	Title string `json:"title"`
+	Desc  string `json:"desc,omitempty"`
+	Count int    `json:"count"` // 转发的消息数
+}
+
+// QuoteBody 被引用的消息，来自引用回复中保存的副本，找到原消息后以原消息为准
+type QuoteBody struct {
+	ServerID   string    `json:"serverId,omitempty"` // 原消息的服务器 ID
+	Seq        int64     `json:"seq,omitempty"`      // 找到的原消息的 seq
+	Missing    bool      `json:"missing,omitempty"`  // 原消息已删除、已清除或不在数据中，内容为引用时保存的副本
+	Sender     string    `json:"sender,omitempty"`
+	SenderName string    `json:"senderName,omitempty"`
+	Time       time.Time `json:"time"`
+	Body       *Body     `json:"body,omitempty"`
+}
+
+// PayBody 转账与红包
+type PayBody struct {
+	Amount    string `json:"amount,omitempty"`    // 金额描述，如"￥200.00"，红包没有金额
+	Memo      string `json:"memo,omitempty"`      // 转账备注或红包祝福语
+	Direction string `json:"direction,omitempty"` // 转账方向，见 PaySend 等
+}
+
+// Decode 将消息的类型与 ParseMediaInfo 解析出的内容转为 Body
+// 从导入数据读取的消息 Contents 经过 JSON 编码，数字为 float64，引用的消息为 map
+func (m *Message) Decode() *Body {
+	switch m.Type {
+	case 1:
+		return &Body{Kind: KindText, Text: m.Content}
+	case 3:
+		return &Body{Kind: KindImage, Media: &MediaBody{
+			MD5:   m.contentString("md5"),
+			Path:  m.contentString("imgfile"),
+			Thumb: m.contentString("thumb"),
+		}}
+	case 34:
+		return &Body{Kind: KindVoice, Media: &MediaBody{
+			Key:      m.contentString("voice"),
+			Duration: m.contentInt("duration"),
+		}}
+	case 42:
+		return &Body{Kind: KindCard, Card: &CardBody{
+			UserName: m.contentString("username"),
+			NickName: m.contentString("nickname"),
+		}}
+	case 43:
+		return &Body{Kind: KindVideo, Media: &MediaBody{
+			MD5:   m.contentString("md5"),
+			Path:  m.contentString("videofile"),
+			Thumb: m.contentString("thumb"),
+		}}
+	case 47:
+		return &Body{Kind: KindEmoji, Media: &MediaBody{
+			MD5: m.contentString("md5"),
+			URL: m.contentString("url"),
+		}}
+	case 48:
+		return &Body{Kind: KindLocation, Location: &LocationBody{
+			Latitude:  m.contentFloat("latitude"),
+			Longitude: m.contentFloat("longitude"),
+			Label:     m.contentString("label"),
+			POI:       m.contentString("poiname"),
+		}}
+	case 50:
+		return &Body{Kind: KindCall, Text: m.Content}
+	case 49:
+		return m.decodeApp()
+	case 10000:
+		return &Body{Kind: KindSystem, Text: m.Content}
+	case 10002:
+		if revoke := m.contentString("revoke"); revoke != "" {
+			return &Body{Kind: KindRevoke, Text: m.Content, Revoke: revoke}
+		}
+		return &Body{Kind: KindSystem, Text: m.Content}
+	}
+	return &Body{Kind: KindOther, Type: m.Type, SubType: m.SubType}
+}
+
+func (m *Message) decodeApp() *Body {
+	switch m.SubType {
+	case 5:
+		return &Body{Kind: KindLink, Link: &LinkBody{
+			Title:  m.contentString("title"),
+			Desc:   m.contentString("desc"),
+			URL:    m.contentString("url"),
+			Source: m.contentString("source"),
+		}}
+	case 6:
+		return &Body{Kind: KindFile, File: &FileBody{
+			Name: m.contentString("title"),
+			Ext:  m.contentString("ext"),
+			Size: m.contentInt("size"),
+			MD5:  m.contentString("md5"),
+		}}
+	case 19:
+		forward := &ForwardBody{Title: m.contentString("title"), Desc: m.contentString("desc")}
+		switch info := m.Contents["recordInfo"].(type) {
+		case *RecordInfo:
+			forward.Count = len(info.DataList.DataItems)
+		case map[string]interface{}:
+			if list, ok := info["DataList"].(map[string]interface{}); ok {
+				items, _ := list["DataItems"].([]interface{})
+				forward.Count = len(items)
+			}
+		}
+		return &Body{Kind: KindForward, Forward: forward}
+	case 33, 36:
+		return &Body{Kind: KindMiniProgram, Link: &LinkBody{
+			Title:  m.contentString("desc"),
+			URL:    m.contentString("url"),
+			Source: m.contentString("title"),
+		}}
+	case 51:
+		return &Body{Kind: KindChannels, Link: &LinkBody{
+			Title: m.contentString("title"),
+			URL:   m.contentString("url"),
+		}}
+	case 57:
+		return &Body{Kind: KindQuote, Text: m.Content, Quote: m.decodeRefer()}
+	case 62:
+		return &Body{Kind: KindPat, Text: m.Content}
+	case 2000:
+		return &Body{Kind: KindTransfer, Pay: &PayBody{
+			Amount:    m.contentString("amount"),
+			Memo:      m.contentString("memo"),
+			Direction: m.contentString("direction"),
+		}}
+	case 2001:
+		return &Body{Kind: KindRedPacket, Pay: &PayBody{Memo: m.contentString("title")}}
+	}
+	return &Body{Kind: KindOther, Type: m.Type, SubType: m.SubType}
+}
+
+// decodeRefer 解码引用回复中被引用的消息，没有被引用的消息时返回 nil
+func (m *Message) decodeRefer() *QuoteBody {
+	switch refer := m.Contents["refer"].(type) {
+	case *Message:
+		return &QuoteBody{ServerID: m.contentString("referid"), Sender: refer.Sender, SenderName: refer.SenderName, Time: refer.Time, Body: refer.Decode()}
+	case map[string]interface{}:
+		// 导入数据中的引用消息，按 Message 的 JSON 字段还原
+		sub := &Message{}
+		sub.Type, _ = toInt(refer["type"])
+		sub.SubType, _ = toInt(refer["subType"])
+		sub.Sender, _ = refer["sender"].(string)
+		sub.SenderName, _ = refer["senderName"].(string)
+		sub.Content, _ = refer["content"].(string)
+		sub.Contents, _ = refer["contents"].(map[string]interface{})
+		if t, ok := refer["time"].(string); ok {
+			sub.Time, _ = time.Parse(time.RFC3339, t)
+		}
+		return &QuoteBody{ServerID: m.contentString("referid"), Sender: sub.Sender, SenderName: sub.SenderName, Time: sub.Time, Body: sub.Decode()}
+	}
+	return nil
+}
+
+// Summary 返回消息内容的简短文字，用于显示被引用的消息，多媒体消息显示为 [图片] 等
+func (b *Body) Summary() string {
+	label := func(kind, title string) string {
+		if title == "" {
+			return "[" + kind + "]"
+		}
+		return fmt.Sprintf("[%s|%s]", kind, title)
+	}
+	switch b.Kind {
+	case KindImage:
+		return "[图片]"
+	case KindVoice:
+		return "[语音]"
+	case KindVideo:
+		return "[视频]"
+	case KindEmoji:
+		return "[动画表情]"
+	case KindCard:
+		return label("名片", b.Card.NickName)
+	case KindLocation:
+		return label("位置", cmp.Or(b.Location.POI, b.Location.Label))
+	case KindLink:
+		return label("链接", b.Link.Title)
+	case KindMiniProgram:
+		return label("小程序", cmp.Or(b.Link.Title, b.Link.Source))
+	case KindChannels:
+		return label("视频号", b.Link.Title)
+	case KindFile:
+		return label("文件", b.File.Name)
+	case KindForward:
+		return label("合并转发", b.Forward.Title)
+	case KindTransfer:
+		return label("转账", b.Pay.Amount)
+	case KindRedPacket:
+		return label("红包", b.Pay.Memo)
+	case KindOther:
+		return "[消息]"
+	}
+	return b.Text
+}
+
+func (m *Message) contentString(key string) string {
+	s, _ := m.Contents[key].(string)
+	return s
+}
+
+func (m *Message) contentInt(key string) int64 {
+	i, _ := toInt(m.Contents[key])
+	return i
+}
+
+func (m *Message) contentFloat(key string) float64 {
+	switch v := m.Contents[key].(type) {
+	case float64:
+		return v
+	case float32:
+		return float64(v)
+	}
+	return 0
+}
+
+func toInt(v interface{}) (int64, bool) {
+	switch v := v.(type) {
+	case int:
+		return int64(v), true
+	case int64:
+		return v, true
+	case float64:
+		return int64(v), true
+	}
+	return 0, false
+}
@@ -0,0 +1,67 @@
+package model
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDecode(t *testing.T) {
+	for _, tc := range []struct {
+		typ  int64
+		data string
+		want *Body
+	}{
+		{1, "你好", &Body{Kind: KindText, Text: "你好"}},
+		{48, `<msg><location x="39.9087" y="116.3975" scale="15" label="北京市东城区" poiname="天安门" /></msg>`,
+			&Body{Kind: KindLocation, Location: &LocationBody{Latitude: 39.9087, Longitude: 116.3975, Label: "北京市东城区", POI: "天安门"}}},
+		{42, `<msg username="wxid_c" nickname="小红" />`, &Body{Kind: KindCard, Card: &CardBody{UserName: "wxid_c", NickName: "小红"}}},
+		{49, `<msg><appmsg><type>6</type><title>周报.pdf</title><md5>abc</md5><appattach><totallen>2048</totallen><fileext>pdf</fileext></appattach></appmsg></msg>`,
+			&Body{Kind: KindFile, File: &FileBody{Name: "周报.pdf", Ext: "pdf", Size: 2048, MD5: "abc"}}},
+		{49, `<msg><appmsg><type>2000</type><wcpayinfo><paysubtype>1</paysubtype><feedesc>￥20.00</feedesc><pay_memo>午饭</pay_memo></wcpayinfo></appmsg></msg>`,
+			&Body{Kind: KindTransfer, Pay: &PayBody{Amount: "￥20.00", Memo: "午饭", Direction: PaySend}}},
+		{49, `<msg><appmsg><type>2001</type><wcpayinfo><sendertitle>恭喜发财</sendertitle></wcpayinfo></appmsg></msg>`,
+			&Body{Kind: KindRedPacket, Pay: &PayBody{Memo: "恭喜发财"}}},
+		{10002, `<sysmsg type="revokemsg"><revokemsg><newmsgid>123</newmsgid><replacemsg><![CDATA["小明" 撤回了一条消息]]></replacemsg></revokemsg></sysmsg>`,
+			&Body{Kind: KindRevoke, Text: `"小明" 撤回了一条消息`, Revoke: "123"}},
+		{9999, `<msg />`, &Body{Kind: KindOther, Type: 9999}},
+	} {
+		m := &Message{Type: tc.typ}
+		if err := m.ParseMediaInfo(tc.data); err != nil {
+			t.Fatalf("ParseMediaInfo(%q): %v", tc.data, err)
+		}
+		if strings.HasPrefix(m.Content, "<") {
+			t.Errorf("raw xml kept in content: %q", m.Content)
+		}
+		if got := m.Decode(); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("Decode(%q) = %+v, want %+v", tc.data, got, tc.want)
+		}
+	}
+}
+
+func TestDecodeQuote(t *testing.T) {
+	m := &Message{Type: 49}
+	data := `<msg><appmsg><type>57</type><title>收到</title><refermsg><type>1</type><svrid>123</svrid><chatusr>wxid_a</chatusr><displayname>小明</displayname><content>明天开会</content><createtime>1700000000</createtime></refermsg></appmsg></msg>`
+	if err := m.ParseMediaInfo(data); err != nil {
+		t.Fatal(err)
+	}
+	body := m.Decode()
+	if body.Kind != KindQuote || body.Text != "收到" || body.Quote == nil || body.Quote.Sender != "wxid_a" || body.Quote.ServerID != "123" ||
+		!reflect.DeepEqual(body.Quote.Body, &Body{Kind: KindText, Text: "明天开会"}) {
+		t.Fatalf("Decode = %+v", body)
+	}
+
+	// 导入数据中的消息经过 JSON 编码，解码结果应相同
+	b, err := json.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	imported := &Message{}
+	if err := json.Unmarshal(b, imported); err != nil {
+		t.Fatal(err)
+	}
+	if got := imported.Decode(); !reflect.DeepEqual(got.Quote.Body, body.Quote.Body) || !got.Quote.Time.Equal(body.Quote.Time) {
+		t.Errorf("imported Decode = %+v, want %+v", got.Quote, body.Quote)
+	}
+}
@@ -8,10 +8,50 @@ import (
 )
 
 type MediaMsg struct {
-	XMLName xml.Name `xml:"msg"`
-	Image   Image    `xml:"img,omitempty"`
-	Video   Video    `xml:"videomsg,omitempty"`
-	App     App      `xml:"appmsg,omitempty"`
+	XMLName  xml.Name  `xml:"msg"`
+	Image    Image     `xml:"img,omitempty"`
+	Video    Video     `xml:"videomsg,omitempty"`
+	App      App       `xml:"appmsg,omitempty"`
+	Voice    *VoiceMsg `xml:"voicemsg,omitempty"` // type 34 语音
+	Emoji    *Emoji    `xml:"emoji,omitempty"`    // type 47 动画表情
+	Location *Location `xml:"location,omitempty"` // type 48 位置
+	VoIP     *VoIPMsg  `xml:"voipmsg,omitempty"`  // type 50 语音/视频通话
+
+	// type 42 名片，属性直接在 msg 上
+	UserName string `xml:"username,attr,omitempty"`
+	NickName string `xml:"nickname,attr,omitempty"`
+}
+
+// VoiceMsg 语音消息
+type VoiceMsg struct {
+	VoiceLength int `xml:"voicelength,attr"` // 时长，毫秒
+}
+
+// Emoji 动画表情
+type Emoji struct {
+	MD5        string `xml:"md5,attr"`
+	CDNURL     string `xml:"cdnurl,attr"`
+	EncryptURL string `xml:"encrypturl,attr"` // 加密的下载地址，cdnurl 失效时使用
+	AESKey     string `xml:"aeskey,attr"`     // encrypturl 内容的 AES 密钥，十六进制
+	Width      int    `xml:"width,attr"`
+	Height     int    `xml:"height,attr"`
+}
+
+// Location 位置消息，x 为纬度，y 为经度
+type Location struct {
+	X       float64 `xml:"x,attr"`
+	Y       float64 `xml:"y,attr"`
+	Scale   int     `xml:"scale,attr"`
+	Label   string  `xml:"label,attr"`
+	POIName string  `xml:"poiname,attr"`
+}
+
+// VoIPMsg 语音/视频通话结果
+type VoIPMsg struct {
+	Type   string `xml:"type,attr"`
+	Bubble struct {
+		Msg string `xml:"msg"` // 如"通话时长 00:32"、"已取消"
+	} `xml:"VoIPBubbleMsg"`
 }
 
 type Image struct {
@@ -229,6 +269,11 @@ type WCPayInfo struct {
 	PayMemo           string `xml:"pay_memo"`          // 支付备注
 	ReceiverUsername  string `xml:"receiver_username"` // 接收方用户名
 	PayerUsername     string `xml:"payer_username"`    // 支付方用户名
+
+	// type 2001 红包
+	SenderTitle   string `xml:"sendertitle"`   // 红包祝福语
+	ReceiverTitle string `xml:"receivertitle"` // 领取方看到的祝福语
+	SceneText     string `xml:"scenetext"`     // 如"微信红包"
 }
 
 // FinderFeed 视频号信息
@@ -287,6 +332,7 @@ type SysMsg struct {
 	Type              string             `xml:"type,attr"`
 	DelChatRoomMember *DelChatRoomMember `xml:"delchatroommember,omitempty"`
 	SysMsgTemplate    *SysMsgTemplate    `xml:"sysmsgtemplate,omitempty"`
+	RevokeMsg         *RevokeMsg         `xml:"revokemsg,omitempty"` // type 10002 撤回
 }
 
 // 第一种消息类型：删除群成员/二维码邀请
@@ -344,9 +390,22 @@ type Member struct {
 	Nickname string `xml:"nickname"`
 }
 
+// RevokeMsg 撤回消息的提示
+type RevokeMsg struct {
+	Session    string `xml:"session"`
+	NewMsgID   string `xml:"newmsgid"`   // 被撤回消息的服务器 ID
+	ReplaceMsg string `xml:"replacemsg"` // 提示文字，如"xxx" 撤回了一条消息
+}
+
 func (s *SysMsg) String() string {
-	if s.Type == "delchatroommember" {
+	switch s.Type {
+	case "delchatroommember":
 		return s.DelChatRoomMemberString()
+	case "revokemsg":
+		if s.RevokeMsg == nil {
+			return ""
+		}
+		return s.RevokeMsg.ReplaceMsg
 	}
 	return s.SysMsgTemplateString()
 }
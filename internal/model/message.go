@@ -1,8 +1,10 @@
 package model
 
 import (
+	"cmp"
 	"encoding/xml"
 	"fmt"
+	"strconv"
 	"strings"
 	"time"
 
@@ -31,12 +33,61 @@ type Message struct {
 	SubType    int64                  `json:"subType"`            // 消息子类型
 	Content    string                 `json:"content"`            // 消息内容，文字聊天内容
 	Contents   map[string]interface{} `json:"contents,omitempty"` // 消息内容，多媒体消息，采用更灵活的记录方式
+	Body       *Body                  `json:"body,omitempty"`     // 解码后的消息内容，见 Decode
+	Status     string                 `json:"status,omitempty"`   // 发送状态，见 StatusSending 等，数据中没有状态时为空
+	ServerID   int64                  `json:"serverId,omitempty"` // 消息的服务器 ID，引用回复通过它指向原消息
+	ParentID   int64                  `json:"parentId,omitempty"` // 引用回复所引用的原消息的 seq，与本消息在同一个聊天对象中，见 database.ResolveQuotes
+
+	TimeAnomaly  string     `json:"timeAnomaly,omitempty"`  // 时间异常，见 DetectTimeAnomalies
+	OriginalTime *time.Time `json:"originalTime,omitempty"` // 时间被修正前的原始时间，见 NormalizeTimes
+
+	Translation string `json:"translation,omitempty"` // 请求指定语言时的译文，原文保留在 Content 中
 
 	// Debug Info
 	MediaMsg *MediaMsg `json:"mediaMsg,omitempty"` // 原始多媒体消息，XML 格式
 	SysMsg   *SysMsg   `json:"sysMsg,omitempty"`   // 原始系统消息，XML 格式
 }
 
+// 消息的发送状态，见 Message.Status
+const (
+	StatusSending  = "sending"  // 发送中，退出微信时仍未发出的消息会一直保持该状态
+	StatusSent     = "sent"     // 已发送
+	StatusFailed   = "failed"   // 发送失败
+	StatusReceived = "received" // 接收的消息
+)
+
+// ValidStatus 返回 status 是否为已知的发送状态
+func ValidStatus(status string) bool {
+	switch status {
+	case StatusSending, StatusSent, StatusFailed, StatusReceived:
+		return true
+	}
+	return false
+}
+
+// sendStatus 按数据库中的状态码返回消息的发送状态，各版本中发送中与发送失败的状态码不同
+// 接收的消息总是 StatusReceived，状态码为 0（数据中没有状态）时返回空
+func sendStatus(isSelf bool, code, sending, failed int) string {
+	switch {
+	case code == 0:
+		return ""
+	case !isSelf:
+		return StatusReceived
+	case code == sending:
+		return StatusSending
+	case code == failed:
+		return StatusFailed
+	default:
+		return StatusSent
+	}
+}
+
+// MessageID 消息的唯一标识，seq 只在同一个聊天对象中唯一
+type MessageID struct {
+	Talker string `json:"talker"` // 聊天对象，支持微信 ID、群聊 ID 与名称
+	Seq    int64  `json:"seq"`
+}
+
 func (m *Message) ParseMediaInfo(data string) error {
 
 	m.Type, m.SubType = util.SplitInt64ToTwoInt32(m.Type)
@@ -46,7 +97,8 @@ func (m *Message) ParseMediaInfo(data string) error {
 		return nil
 	}
 
-	if m.Type == 10000 {
+	// 10000 系统消息，10002 撤回提示等
+	if m.Type == 10000 || m.Type == 10002 {
 		var sysMsg SysMsg
 		if err := xml.Unmarshal([]byte(data), &sysMsg); err != nil {
 			m.Content = data
@@ -58,6 +110,9 @@ func (m *Message) ParseMediaInfo(data string) error {
 		m.Sender = "系统消息"
 		m.SenderName = ""
 		m.Content = sysMsg.String()
+		if sysMsg.RevokeMsg != nil && sysMsg.RevokeMsg.NewMsgID != "" {
+			m.SetContent("revoke", sysMsg.RevokeMsg.NewMsgID)
+		}
 		return nil
 	}
 
@@ -75,9 +130,41 @@ func (m *Message) ParseMediaInfo(data string) error {
 		m.MediaMsg = &msg
 	}
 
+	// XML 已解析到 Contents 中，不再保留原始 XML（v3 版本 Content 中为原始 XML，与 v4 版本保持一致）
+	// 需要文字的消息类型在下面重新设置 Content
+	m.Content = ""
+
 	switch m.Type {
 	case 3:
 		m.Contents["md5"] = msg.Image.MD5
+	case 34:
+		if msg.Voice != nil {
+			m.Contents["duration"] = msg.Voice.VoiceLength
+		}
+	case 42:
+		// 名片
+		m.Contents["username"] = msg.UserName
+		m.Contents["nickname"] = msg.NickName
+	case 47:
+		if msg.Emoji != nil {
+			m.Contents["md5"] = msg.Emoji.MD5
+			m.Contents["url"] = msg.Emoji.CDNURL
+			if msg.Emoji.EncryptURL != "" {
+				m.Contents["encrypturl"] = msg.Emoji.EncryptURL
+				m.Contents["aeskey"] = msg.Emoji.AESKey
+			}
+		}
+	case 48:
+		if msg.Location != nil {
+			m.Contents["latitude"] = msg.Location.X
+			m.Contents["longitude"] = msg.Location.Y
+			m.Contents["label"] = msg.Location.Label
+			m.Contents["poiname"] = msg.Location.POIName
+		}
+	case 50:
+		if msg.VoIP != nil {
+			m.Content = msg.VoIP.Bubble.Msg
+		}
 	case 43:
 		if msg.Video.Md5 != "" {
 			m.Contents["md5"] = msg.Video.Md5
@@ -92,10 +179,18 @@ func (m *Message) ParseMediaInfo(data string) error {
 			// 链接
 			m.Contents["title"] = msg.App.Title
 			m.Contents["url"] = msg.App.URL
+			m.Contents["desc"] = msg.App.Des
+			m.Contents["source"] = msg.App.SourceDisplayName
 		case 6:
 			// 文件
 			m.Contents["title"] = msg.App.Title
 			m.Contents["md5"] = msg.App.MD5
+			if msg.App.AppAttach != nil {
+				m.Contents["ext"] = msg.App.AppAttach.FileExt
+				if size, err := strconv.ParseInt(msg.App.AppAttach.TotalLen, 10, 64); err == nil {
+					m.Contents["size"] = size
+				}
+			}
 		case 19:
 			// 合并转发
 			m.Contents["title"] = msg.App.Title
@@ -113,6 +208,7 @@ func (m *Message) ParseMediaInfo(data string) error {
 			// 小程序
 			m.Contents["title"] = msg.App.SourceDisplayName
 			m.Contents["url"] = msg.App.URL
+			m.Contents["desc"] = msg.App.Title
 		case 51:
 			// 视频号
 			if msg.App.FinderFeed == nil {
@@ -128,6 +224,9 @@ func (m *Message) ParseMediaInfo(data string) error {
 			if msg.App.ReferMsg == nil {
 				break
 			}
+			if msg.App.ReferMsg.SvrID != "" {
+				m.Contents["referid"] = msg.App.ReferMsg.SvrID
+			}
 			subMsg := &Message{
 				Type:       int64(msg.App.ReferMsg.Type),
 				Time:       time.Unix(msg.App.ReferMsg.CreateTime, 0),
@@ -162,19 +261,30 @@ func (m *Message) ParseMediaInfo(data string) error {
 			// 5 非实时转账收钱回执
 			// 7 非实时转账
 			_type := ""
+			direction := ""
 			switch msg.App.WCPayInfo.PaySubType {
 			case 1, 7:
-				_type = "发送 "
+				_type, direction = "发送 ", PaySend
 			case 3, 5:
-				_type = "接收 "
+				_type, direction = "接收 ", PayReceive
 			case 4:
-				_type = "退还 "
+				_type, direction = "退还 ", PayRefund
 			}
 			payMemo := ""
 			if len(msg.App.WCPayInfo.PayMemo) > 0 {
 				payMemo = "(" + msg.App.WCPayInfo.PayMemo + ")"
 			}
 			m.Content = fmt.Sprintf("[转账|%s%s]%s", _type, msg.App.WCPayInfo.FeeDesc, payMemo)
+			m.Contents["amount"] = msg.App.WCPayInfo.FeeDesc
+			m.Contents["memo"] = msg.App.WCPayInfo.PayMemo
+			m.Contents["direction"] = direction
+		case 2001:
+			// 红包
+			if msg.App.WCPayInfo == nil {
+				break
+			}
+			m.Contents["title"] = cmp.Or(msg.App.WCPayInfo.SenderTitle, msg.App.WCPayInfo.ReceiverTitle, msg.App.Des)
+			m.Contents["scene"] = msg.App.WCPayInfo.SceneText
 		}
 	}
 
@@ -226,10 +336,18 @@ func (m *Message) PlainText(showChatRoom bool, timeFormat string, host string) s
 	}
 
 	buf.WriteString(m.Time.Format(timeFormat))
+	if m.TimeAnomaly != "" {
+		buf.WriteString(" [时间异常]")
+	}
 	buf.WriteString("\n")
 
 	buf.WriteString(m.PlainTextContent())
 	buf.WriteString("\n")
+	if m.Translation != "" {
+		buf.WriteString("> ")
+		buf.WriteString(strings.ReplaceAll(m.Translation, "\n", "\n> "))
+		buf.WriteString("\n")
+	}
 
 	return buf.String()
 }
@@ -262,6 +380,9 @@ func (m *Message) PlainTextContent() string {
 		}
 		return "[语音]"
 	case 42:
+		if nickname, ok := m.Contents["nickname"].(string); ok && nickname != "" {
+			return fmt.Sprintf("[名片|%s]", nickname)
+		}
 		return "[名片]"
 	case 43:
 		keylist := make([]string, 0)
@@ -288,6 +409,15 @@ func (m *Message) PlainTextContent() string {
 		return fmt.Sprintf("![视频](http://%s/video/%s)", m.Contents["host"], strings.Join(keylist, ","))
 	case 47:
 		return "[动画表情]"
+	case 48:
+		label, _ := m.Contents["poiname"].(string)
+		if label == "" {
+			label, _ = m.Contents["label"].(string)
+		}
+		if label == "" {
+			return "[位置]"
+		}
+		return fmt.Sprintf("[位置|%s]", label)
 	case 49:
 		switch m.SubType {
 		case 5:
@@ -361,6 +491,9 @@ func (m *Message) PlainTextContent() string {
 		case 2000:
 			return m.Content
 		case 2001:
+			if title, ok := m.Contents["title"].(string); ok && title != "" {
+				return fmt.Sprintf("[红包|%s]", title)
+			}
 			return "[红包]"
 		case 2003:
 			return "[红包封面]"
@@ -368,8 +501,11 @@ func (m *Message) PlainTextContent() string {
 			return "[分享]"
 		}
 	case 50:
+		if m.Content != "" {
+			return "[语音通话] " + m.Content
+		}
 		return "[语音通话]"
-	case 10000:
+	case 10000, 10002:
 		return m.Content
 	default:
 		content := m.Content
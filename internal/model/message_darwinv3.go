@@ -23,22 +23,29 @@ import (
 // ConBlob BLOB
 // )
 type MessageDarwinV3 struct {
+	MesLocalID    int64  `json:"mesLocalID"`
+	MesSvrID      int64  `json:"mesSvrID"`
 	MsgCreateTime int64  `json:"msgCreateTime"`
 	MsgContent    string `json:"msgContent"`
 	MessageType   int64  `json:"messageType"`
-	MesDes        int    `json:"mesDes"` // 0: 发送, 1: 接收
+	MesDes        int    `json:"mesDes"`    // 0: 发送, 1: 接收
+	MsgStatus     int    `json:"msgStatus"` // 1: 发送中, 2: 已发送, 5: 发送失败
 }
 
 func (m *MessageDarwinV3) Wrap(talker string) *Message {
 
 	_m := &Message{
+		// 表中没有序号，使用本地 ID 的后 3 位补齐为 10位时间戳 + 3位序号
+		Seq:        m.MsgCreateTime*1000 + m.MesLocalID%1000,
 		Time:       time.Unix(m.MsgCreateTime, 0),
 		Type:       m.MessageType,
 		Talker:     talker,
 		IsChatRoom: strings.HasSuffix(talker, "@chatroom"),
 		IsSelf:     m.MesDes == 0,
+		ServerID:   m.MesSvrID,
 		Version:    WeChatDarwinV3,
 	}
+	_m.Status = sendStatus(_m.IsSelf, m.MsgStatus, 1, 5)
 
 	content := m.MsgContent
 	if _m.IsChatRoom {
@@ -53,5 +60,7 @@ func (m *MessageDarwinV3) Wrap(talker string) *Message {
 
 	_m.ParseMediaInfo(content)
 
+	_m.Body = _m.Decode()
+
 	return _m
 }
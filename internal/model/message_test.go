@@ -0,0 +1,22 @@
+package model
+
+import "testing"
+
+func TestWrapStatus(t *testing.T) {
+	for _, tc := range []struct {
+		status   int
+		userName string
+		want     string
+	}{
+		{1, "wxid_self", StatusSending},
+		{2, "wxid_self", StatusSent},
+		{3, "wxid_self", StatusFailed},
+		{4, "wxid_a", StatusReceived},
+		{0, "wxid_a", ""},
+	} {
+		m := (&MessageV4{LocalType: 1, UserName: tc.userName, Status: tc.status, MessageContent: []byte("hi")}).Wrap("wxid_a")
+		if m.Status != tc.want {
+			t.Errorf("status %d from %s = %q, want %q", tc.status, tc.userName, m.Status, tc.want)
+		}
+	}
+}
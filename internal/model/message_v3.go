@@ -50,6 +50,7 @@ type MessageV3 struct {
 	StrContent      string `json:"StrContent"`      // 消息内容，文字聊天内容 或 XML
 	CompressContent []byte `json:"CompressContent"` // 非文字聊天内容，如图片、语音、视频等
 	BytesExtra      []byte `json:"BytesExtra"`      // protobuf 额外数据，记录群聊发送人等信息
+	Status          int    `json:"Status"`          // 消息状态，1 发送中，2 已发送，5 发送失败
 }
 
 func (m *MessageV3) Wrap() *Message {
@@ -63,8 +64,10 @@ func (m *MessageV3) Wrap() *Message {
 		Type:       m.Type,
 		SubType:    int64(m.SubType),
 		Content:    m.StrContent,
+		ServerID:   m.MsgSvrID,
 		Version:    WeChatV3,
 	}
+	_m.Status = sendStatus(_m.IsSelf, m.Status, 1, 5)
 
 	if !_m.IsChatRoom && !_m.IsSelf {
 		_m.Sender = m.StrTalker
@@ -101,6 +104,8 @@ func (m *MessageV3) Wrap() *Message {
 		}
 	}
 
+	_m.Body = _m.Decode()
+
 	return _m
 }
 
@@ -41,7 +41,7 @@ type MessageV4 struct {
 	CreateTime     int64  `json:"create_time"`      // 消息创建时间，10位时间戳
 	MessageContent []byte `json:"message_content"`  // 消息内容，文字聊天内容 或 zstd 压缩内容
 	PackedInfoData []byte `json:"packed_info_data"` // 额外数据，类似 proto，格式与 v3 有差异
-	Status         int    `json:"status"`           // 消息状态，2 是已发送，4 是已接收，可以用于判断 IsSender（FIXME 不准, 需要判断 UserName）
+	Status         int    `json:"status"`           // 消息状态，1 是发送中，2 是已发送，3 是发送失败，4 是已接收，可以用于判断 IsSender（FIXME 不准, 需要判断 UserName）
 }
 
 func (m *MessageV4) Wrap(talker string) *Message {
@@ -54,11 +54,13 @@ func (m *MessageV4) Wrap(talker string) *Message {
 		Sender:     m.UserName,
 		Type:       m.LocalType,
 		Contents:   make(map[string]interface{}),
+		ServerID:   m.ServerID,
 		Version:    WeChatV4,
 	}
 
 	// FIXME 后续通过 UserName 判断是否是自己发送的消息，目前可能不准确
 	_m.IsSelf = m.Status == 2 || (!_m.IsChatRoom && talker != m.UserName)
+	_m.Status = sendStatus(_m.IsSelf, m.Status, 1, 3)
 
 	content := ""
 	if bytes.HasPrefix(m.MessageContent, []byte{0x28, 0xb5, 0x2f, 0xfd}) {
@@ -100,6 +102,8 @@ func (m *MessageV4) Wrap(talker string) *Message {
 		}
 	}
 
+	_m.Body = _m.Decode()
+
 	return _m
 }
 
@@ -0,0 +1,509 @@
+package browser
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/aspnmy/chatlog/internal/model"
+	"github.com/aspnmy/chatlog/internal/ui/style"
+	"github.com/aspnmy/chatlog/internal/wechatdb"
+	"github.com/aspnmy/chatlog/pkg/util"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+const (
+	Title = "browser"
+
+	// SessionPaneWidth 会话列表的宽度
+	SessionPaneWidth = 32
+	// SummaryLen 消息列表中每条消息显示的最大字符数
+	SummaryLen = 120
+)
+
+// Source 浏览器读取数据的接口，由 database.Service 实现
+type Source interface {
+	GetSessions(key string, limit, offset int) (*wechatdb.GetSessionsResp, error)
+	GetMessages(start, end time.Time, talker string, sender string, keyword string, limit, offset int) ([]*model.Message, error)
+}
+
+// 底部输入框的用途
+const (
+	inputNone = iota
+	inputFilter
+	inputSearch
+	inputDate
+	inputRange
+)
+
+// Browser 全屏浏览聊天记录，左侧为会话列表，右侧为时间范围内的消息与选中消息的详情，
+// 默认显示会话最后一条消息所在的月份
+//
+//	Tab        在会话列表与消息列表之间切换
+//	/          会话列表中按名称过滤，消息列表中增量搜索
+//	n / N      跳到下一条 / 上一条搜索结果
+//	g          跳转到指定日期
+//	t          设置时间范围，如 last-7d、2024-01-01~2024-03-31
+//	[ / ]      上一个 / 下一个时间范围，自然月按月切换
+//	r          重新加载会话列表
+//	q / ESC    退出浏览
+type Browser struct {
+	*tview.Flex
+
+	app    *tview.Application
+	src    Source
+	hidden func(talker string) bool
+	host   func() string
+	done   func()
+
+	sessions *tview.Table
+	messages *tview.Table
+	detail   *tview.TextView
+	status   *tview.TextView
+	input    *tview.InputField
+	bottom   *tview.Pages
+
+	allSessions []*model.Session
+	shown       []*model.Session
+	filter      string
+
+	session *model.Session
+	pending string // 会话列表加载完成后打开的会话
+	start   time.Time
+	end     time.Time
+	ranged  bool // 用户指定了时间范围，切换会话时保持不变
+	msgs    []*model.Message
+	loading int // 每次加载递增，丢弃过期的加载结果
+	message string
+
+	inputMode int
+	query     string
+	searchRow int // 开始增量搜索时选中的行
+}
+
+// New 创建浏览器，hidden 返回 true 的会话不显示，host 返回 HTTP 服务地址，服务未启动时返回空字符串
+func New(app *tview.Application, src Source, hidden func(talker string) bool, host func() string) *Browser {
+	b := &Browser{
+		Flex:     tview.NewFlex(),
+		app:      app,
+		src:      src,
+		hidden:   hidden,
+		host:     host,
+		sessions: tview.NewTable(),
+		messages: tview.NewTable(),
+		detail:   tview.NewTextView(),
+		status:   tview.NewTextView(),
+		input:    tview.NewInputField(),
+		bottom:   tview.NewPages(),
+	}
+
+	b.sessions.SetSelectable(true, false).
+		SetSelectedFunc(func(row, column int) {
+			b.openSession(row)
+		})
+	b.sessions.SetBorder(true).SetTitle(" 会话 ").SetBorderColor(style.BorderColor)
+
+	b.messages.SetSelectable(true, false).
+		SetSelectionChangedFunc(func(row, column int) {
+			b.showDetail(row)
+		})
+	b.messages.SetBorder(true).SetTitle(" 消息 ").SetBorderColor(style.BorderColor)
+
+	b.detail.SetDynamicColors(true).SetWrap(true).SetScrollable(true)
+	b.detail.SetBorder(true).SetTitle(" 详情 ").SetBorderColor(style.BorderColor)
+
+	b.status.SetDynamicColors(true)
+	b.input.SetFieldBackgroundColor(style.InputFieldBgColor).
+		SetChangedFunc(b.inputChanged).
+		SetDoneFunc(b.inputDone)
+	b.bottom.
+		AddPage("status", b.status, true, true).
+		AddPage("input", b.input, true, false)
+
+	right := tview.NewFlex().SetDirection(tview.FlexRow).
+		AddItem(b.messages, 0, 2, false).
+		AddItem(b.detail, 0, 1, false)
+	panes := tview.NewFlex().
+		AddItem(b.sessions, SessionPaneWidth, 0, true).
+		AddItem(right, 0, 1, false)
+	b.SetDirection(tview.FlexRow).
+		AddItem(panes, 0, 1, true).
+		AddItem(b.bottom, 1, 0, false)
+
+	b.SetInputCapture(b.inputCapture)
+
+	return b
+}
+
+// SetDoneFunc 设置退出浏览时的回调
+func (b *Browser) SetDoneFunc(done func()) *Browser {
+	b.done = done
+	return b
+}
+
+// Load 加载会话列表，需在 UI 线程外调用
+func (b *Browser) Load() {
+	b.app.QueueUpdateDraw(func() {
+		b.setStatus("加载会话列表...")
+	})
+	resp, err := b.src.GetSessions("", 0, 0)
+	b.app.QueueUpdateDraw(func() {
+		if err != nil {
+			b.setStatus("加载会话列表失败: " + err.Error())
+			return
+		}
+		b.allSessions = b.allSessions[:0]
+		for _, s := range resp.Items {
+			if b.hidden == nil || !b.hidden(s.UserName) {
+				b.allSessions = append(b.allSessions, s)
+			}
+		}
+		b.applyFilter(b.filter)
+		b.setStatus("")
+		if b.pending != "" {
+			b.Open(b.pending)
+		}
+	})
+}
+
+func (b *Browser) inputCapture(event *tcell.EventKey) *tcell.EventKey {
+	if b.inputMode != inputNone {
+		return event
+	}
+
+	switch event.Key() {
+	case tcell.KeyTab, tcell.KeyBacktab:
+		if b.sessions.HasFocus() {
+			b.app.SetFocus(b.messages)
+		} else {
+			b.app.SetFocus(b.sessions)
+		}
+		return nil
+	case tcell.KeyEscape:
+		b.Quit()
+		return nil
+	case tcell.KeyRune:
+	default:
+		return event
+	}
+
+	switch event.Rune() {
+	case 'q':
+		b.Quit()
+	case '/':
+		if b.sessions.HasFocus() {
+			b.startInput(inputFilter, "过滤会话: ", b.filter)
+		} else {
+			b.searchRow, _ = b.messages.GetSelection()
+			b.startInput(inputSearch, "搜索: ", "")
+		}
+	case 'n':
+		b.searchNext(1)
+	case 'N':
+		b.searchNext(-1)
+	case 'g':
+		b.JumpToDate()
+	case 't':
+		b.InputRange()
+	case '[':
+		b.shift(-1)
+	case ']':
+		b.shift(1)
+	case 'r':
+		go b.Load()
+	default:
+		return event
+	}
+	return nil
+}
+
+// Quit 退出浏览
+func (b *Browser) Quit() {
+	if b.done != nil {
+		b.done()
+	}
+}
+
+// startInput 在底部显示输入框
+func (b *Browser) startInput(mode int, label string, text string) {
+	b.inputMode = mode
+	b.input.SetLabel(label)
+	b.input.SetText(text)
+	b.bottom.SwitchToPage("input")
+	b.app.SetFocus(b.input)
+}
+
+// inputChanged 输入时过滤会话或增量搜索消息
+func (b *Browser) inputChanged(text string) {
+	switch b.inputMode {
+	case inputFilter:
+		b.applyFilter(text)
+	case inputSearch:
+		b.query = text
+		if row := findMessage(b.msgs, text, b.searchRow, 1); row >= 0 {
+			b.messages.Select(row, 0)
+		}
+	}
+}
+
+// inputDone Enter 确认输入，ESC 取消
+func (b *Browser) inputDone(key tcell.Key) {
+	mode := b.inputMode
+	text := b.input.GetText()
+	b.inputMode = inputNone
+	b.bottom.SwitchToPage("status")
+
+	switch mode {
+	case inputFilter:
+		if key == tcell.KeyEscape {
+			b.applyFilter(b.filter)
+		} else {
+			b.filter = text
+		}
+		b.app.SetFocus(b.sessions)
+	case inputSearch:
+		if key == tcell.KeyEscape {
+			b.query = ""
+			b.messages.Select(b.searchRow, 0)
+		} else if text != "" && findMessage(b.msgs, text, b.searchRow, 1) < 0 {
+			b.message = fmt.Sprintf("该时间范围内没有包含 %q 的消息", text)
+		}
+		b.app.SetFocus(b.messages)
+	case inputDate:
+		b.app.SetFocus(b.messages)
+		if key == tcell.KeyEscape || text == "" {
+			break
+		}
+		start, _, ok := util.TimeRangeOf(text)
+		if !ok {
+			b.message = fmt.Sprintf("无法解析日期 %q", text)
+			break
+		}
+		b.ranged = false
+		b.loadMonth(start, start)
+	case inputRange:
+		b.app.SetFocus(b.messages)
+		if key == tcell.KeyEscape || text == "" {
+			break
+		}
+		b.SetRange(text)
+		return
+	}
+	b.setStatus("")
+}
+
+// JumpToDate 打开输入框，跳转到指定日期所在的月份
+func (b *Browser) JumpToDate() {
+	b.startInput(inputDate, "跳转到日期 (2006-01-02 或 2006-01-02/15:04): ", "")
+}
+
+// InputRange 打开输入框，设置时间范围
+func (b *Browser) InputRange() {
+	b.startInput(inputRange, "时间范围 (today, last-7d, 2024-01-01~2024-03-31): ", "")
+}
+
+// SetRange 按 util.TimeRangeOf 支持的格式设置时间范围，切换会话时保持不变
+func (b *Browser) SetRange(text string) {
+	start, end, ok := util.TimeRangeOf(text)
+	if !ok {
+		b.message = fmt.Sprintf("无法解析时间范围 %q", text)
+		b.setStatus("")
+		return
+	}
+	b.ranged = true
+	b.loadRange(start, end, time.Time{})
+}
+
+// shift 切换到上一个（step 为 -1）或下一个（step 为 1）时间范围
+func (b *Browser) shift(step int) {
+	if b.start.IsZero() {
+		return
+	}
+	start, end := shiftRange(b.start, b.end, step)
+	b.loadRange(start, end, time.Time{})
+}
+
+// searchNext 从选中的消息开始向后（step 为 1）或向前（step 为 -1）查找下一条搜索结果
+func (b *Browser) searchNext(step int) {
+	if b.query == "" {
+		return
+	}
+	row, _ := b.messages.GetSelection()
+	if found := findMessage(b.msgs, b.query, row+step, step); found >= 0 {
+		b.messages.Select(found, 0)
+		b.message = ""
+	} else {
+		b.message = fmt.Sprintf("该时间范围内没有包含 %q 的消息", b.query)
+	}
+	b.setStatus("")
+}
+
+// applyFilter 按关键词过滤会话列表，保持选中的会话不变
+func (b *Browser) applyFilter(key string) {
+	b.shown = filterSessions(b.allSessions, key)
+	b.sessions.Clear()
+	selected := 0
+	for i, s := range b.shown {
+		name := s.NickName
+		if name == "" {
+			name = s.UserName
+		}
+		b.sessions.SetCell(i, 0, tview.NewTableCell(tview.Escape(name)).SetExpansion(1))
+		if b.session != nil && s.UserName == b.session.UserName {
+			selected = i
+		}
+	}
+	b.sessions.Select(selected, 0)
+	b.sessions.ScrollToBeginning()
+}
+
+// Open 打开聊天对象为 talker 的会话，会话列表尚未加载时在加载完成后打开
+func (b *Browser) Open(talker string) {
+	b.pending = talker
+	if len(b.allSessions) == 0 {
+		return
+	}
+	b.pending = ""
+	row := slices.IndexFunc(b.shown, func(s *model.Session) bool { return s.UserName == talker })
+	if row < 0 {
+		// 会话被过滤掉时清除过滤条件
+		b.filter = ""
+		b.applyFilter("")
+		row = slices.IndexFunc(b.shown, func(s *model.Session) bool { return s.UserName == talker })
+	}
+	if row < 0 {
+		b.message = fmt.Sprintf("找不到会话 %s", talker)
+		b.setStatus("")
+		return
+	}
+	b.sessions.Select(row, 0)
+	b.openSession(row)
+}
+
+// openSession 打开会话，指定了时间范围时显示该范围内的消息，否则显示最后一条消息所在的月份
+func (b *Browser) openSession(row int) {
+	if row < 0 || row >= len(b.shown) {
+		return
+	}
+	b.session = b.shown[row]
+	if b.ranged {
+		b.loadRange(b.start, b.end, time.Time{})
+	} else {
+		anchor := b.session.NTime
+		if anchor.IsZero() {
+			anchor = time.Now()
+		}
+		b.loadMonth(anchor, time.Time{})
+	}
+	b.app.SetFocus(b.messages)
+}
+
+// loadMonth 在后台加载 t 所在月份的消息，at 不为零时选中 at 之后的第一条消息，否则选中最后一条
+func (b *Browser) loadMonth(t time.Time, at time.Time) {
+	start, end := monthOf(t)
+	b.loadRange(start, end, at)
+}
+
+// loadRange 在后台加载时间范围内的消息，没有打开会话时只记录时间范围
+func (b *Browser) loadRange(start, end time.Time, at time.Time) {
+	b.start, b.end = start, end
+	if b.session == nil {
+		b.setStatus("")
+		return
+	}
+	talker := b.session.UserName
+	b.loading++
+	loading := b.loading
+	b.message = "加载中..."
+	b.setStatus("")
+
+	go func() {
+		msgs, err := b.src.GetMessages(start, end, talker, "", "", 0, 0)
+		b.app.QueueUpdateDraw(func() {
+			if loading != b.loading {
+				return
+			}
+			if err != nil {
+				b.msgs = nil
+				b.message = "加载消息失败: " + err.Error()
+			} else {
+				b.msgs = msgs
+				b.message = ""
+				if len(msgs) == 0 {
+					b.message = "该时间范围内没有消息，按 [ ] 切换"
+				}
+			}
+			b.showMessages(at)
+			b.setStatus("")
+		})
+	}()
+}
+
+// showMessages 刷新消息列表
+func (b *Browser) showMessages(at time.Time) {
+	b.messages.Clear()
+	for i, m := range b.msgs {
+		sender := m.SenderName
+		if sender == "" {
+			sender = m.Sender
+		}
+		if m.IsSelf {
+			sender = "我"
+		}
+		b.messages.SetCell(i, 0, tview.NewTableCell(m.Time.Format("01-02 15:04")).SetTextColor(style.HelpHeaderFgColor))
+		b.messages.SetCell(i, 1, tview.NewTableCell(tview.Escape(sender)).SetMaxWidth(16))
+		b.messages.SetCell(i, 2, tview.NewTableCell(tview.Escape(summary(m, SummaryLen))).SetExpansion(1))
+	}
+
+	row := len(b.msgs) - 1
+	if !at.IsZero() {
+		row = firstAfter(b.msgs, at)
+	}
+	if row < 0 {
+		b.detail.Clear()
+		return
+	}
+	b.messages.Select(row, 0)
+	b.showDetail(row)
+}
+
+// showDetail 在详情中显示消息的完整内容，多媒体消息附带预览提示
+func (b *Browser) showDetail(row int) {
+	b.detail.Clear()
+	if row < 0 || row >= len(b.msgs) {
+		return
+	}
+	m := b.msgs[row]
+	host := ""
+	if b.host != nil {
+		host = b.host()
+	}
+	b.detail.SetText(detail(m, host))
+	b.detail.ScrollToBeginning()
+}
+
+// setStatus 刷新底部状态栏，text 为空时显示当前会话、月份与按键提示
+func (b *Browser) setStatus(text string) {
+	if text == "" {
+		parts := make([]string, 0, 4)
+		if b.session != nil {
+			name := b.session.NickName
+			if name == "" {
+				name = b.session.UserName
+			}
+			parts = append(parts, fmt.Sprintf("[%s::b]%s[-:-:-] %s 共 %d 条",
+				style.GetColorHex(style.MenuBgColor), tview.Escape(name), rangeLabel(b.start, b.end), len(b.msgs)))
+		} else if b.ranged {
+			parts = append(parts, rangeLabel(b.start, b.end))
+		}
+		if b.message != "" {
+			parts = append(parts, tview.Escape(b.message))
+		}
+		parts = append(parts, "Ctrl+P: 命令  Tab: 切换  /: 搜索  n/N: 下一个/上一个  g: 跳转日期  t: 时间范围  [/]: 切换  q: 返回")
+		text = strings.Join(parts, "  |  ")
+	}
+	b.status.SetText(text)
+}
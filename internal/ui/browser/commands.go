@@ -0,0 +1,77 @@
+package browser
+
+import (
+	"time"
+
+	"github.com/aspnmy/chatlog/internal/ui/palette"
+)
+
+// rangePresets 命令面板中的常用时间范围，值为 util.TimeRangeOf 支持的格式
+var rangePresets = []struct {
+	Name  string
+	Range string
+}{
+	{"今天", "today"},
+	{"最近 7 天", "last-7d"},
+	{"最近 30 天", "last-30d"},
+	{"本月", "this-month"},
+	{"上个月", "last-month"},
+	{"今年", "this-year"},
+}
+
+// Commands 返回浏览器在命令面板中的命令：打开会话、切换时间范围、跳转日期与搜索
+func (b *Browser) Commands() []palette.Item {
+	items := make([]palette.Item, 0, len(b.allSessions)+len(rangePresets)+4)
+	for _, p := range rangePresets {
+		items = append(items, palette.Item{
+			Name:        "时间范围: " + p.Name,
+			Description: p.Range,
+			Action:      func() { b.SetRange(p.Range) },
+		})
+	}
+	items = append(items,
+		palette.Item{Name: "时间范围: 自定义...", Description: "t", Action: b.InputRange},
+		palette.Item{Name: "跳转到日期...", Description: "g", Action: b.JumpToDate},
+		palette.Item{Name: "搜索消息...", Description: "/", Action: func() {
+			b.app.SetFocus(b.messages)
+			b.searchRow, _ = b.messages.GetSelection()
+			b.startInput(inputSearch, "搜索: ", "")
+		}},
+		palette.Item{Name: "重新加载会话列表", Description: "r", Action: func() { go b.Load() }},
+	)
+	for _, s := range b.allSessions {
+		talker := s.UserName
+		name := s.NickName
+		if name == "" {
+			name = talker
+		}
+		items = append(items, palette.Item{
+			Name:        "会话: " + name,
+			Description: talker,
+			Action:      func() { b.Open(talker) },
+		})
+	}
+	return items
+}
+
+// Session 返回当前打开的会话的聊天对象，没有打开会话时返回空字符串
+func (b *Browser) Session() string {
+	if b.session == nil {
+		return ""
+	}
+	return b.session.UserName
+}
+
+// Range 返回用户指定的时间范围，格式为 2006-01-02~2006-01-02，未指定时返回空字符串
+func (b *Browser) Range() string {
+	if !b.ranged {
+		return ""
+	}
+	return b.start.Format(time.DateOnly) + "~" + b.end.Format(time.DateOnly)
+}
+
+// FilterSessions 切换到会话列表并打开过滤输入框
+func (b *Browser) FilterSessions() {
+	b.app.SetFocus(b.sessions)
+	b.startInput(inputFilter, "过滤会话: ", b.filter)
+}
@@ -0,0 +1,164 @@
+package browser
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aspnmy/chatlog/internal/model"
+	"github.com/aspnmy/chatlog/internal/ui/style"
+
+	"github.com/rivo/tview"
+)
+
+// monthOf 返回 t 所在月份的起止时间（本地时区）
+func monthOf(t time.Time) (start, end time.Time) {
+	t = t.Local()
+	start = time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.Local)
+	end = start.AddDate(0, 1, 0).Add(-time.Nanosecond)
+	return start, end
+}
+
+// isMonth 判断时间范围是否恰好为一个自然月
+func isMonth(start, end time.Time) bool {
+	s, e := monthOf(start)
+	return s.Equal(start) && e.Equal(end)
+}
+
+// shiftRange 将时间范围向后（step 为 1）或向前（step 为 -1）平移，
+// 自然月按月平移，其他范围按范围的长度平移
+func shiftRange(start, end time.Time, step int) (time.Time, time.Time) {
+	if isMonth(start, end) {
+		return monthOf(start.AddDate(0, step, 0))
+	}
+	d := end.Sub(start) + time.Nanosecond
+	return start.Add(time.Duration(step) * d), end.Add(time.Duration(step) * d)
+}
+
+// rangeLabel 返回时间范围在状态栏中的显示，自然月只显示月份
+func rangeLabel(start, end time.Time) string {
+	if isMonth(start, end) {
+		return start.Format("2006-01")
+	}
+	s, e := start.Format(time.DateOnly), end.Format(time.DateOnly)
+	if s == e {
+		return s
+	}
+	return s + " ~ " + e
+}
+
+// firstAfter 返回第一条不早于 t 的消息，都早于 t 时返回最后一条，没有消息时返回 -1
+func firstAfter(msgs []*model.Message, t time.Time) int {
+	if len(msgs) == 0 {
+		return -1
+	}
+	i := sort.Search(len(msgs), func(i int) bool {
+		return !msgs[i].Time.Before(t)
+	})
+	return min(i, len(msgs)-1)
+}
+
+// filterSessions 返回名称或 ID 包含 key 的会话，不区分大小写
+func filterSessions(sessions []*model.Session, key string) []*model.Session {
+	key = strings.ToLower(strings.TrimSpace(key))
+	if key == "" {
+		return sessions
+	}
+	shown := make([]*model.Session, 0, len(sessions))
+	for _, s := range sessions {
+		if strings.Contains(strings.ToLower(s.NickName), key) || strings.Contains(strings.ToLower(s.UserName), key) {
+			shown = append(shown, s)
+		}
+	}
+	return shown
+}
+
+// findMessage 从 from 开始按 step 方向查找内容或发送人包含 query 的消息，到达末尾后从另一端继续，
+// 不区分大小写，找不到时返回 -1
+func findMessage(msgs []*model.Message, query string, from int, step int) int {
+	query = strings.ToLower(query)
+	if query == "" || len(msgs) == 0 {
+		return -1
+	}
+	n := len(msgs)
+	from = ((from % n) + n) % n
+	for i := 0; i < n; i++ {
+		idx := ((from+i*step)%n + n) % n
+		m := msgs[idx]
+		if strings.Contains(strings.ToLower(summary(m, 0)), query) ||
+			strings.Contains(strings.ToLower(m.SenderName), query) {
+			return idx
+		}
+	}
+	return -1
+}
+
+// mediaKind 返回多媒体消息的类型名称，不是多媒体消息时返回空字符串
+func mediaKind(m *model.Message) string {
+	switch {
+	case m.Type == 3:
+		return "图片"
+	case m.Type == 34:
+		return "语音"
+	case m.Type == 43:
+		return "视频"
+	case m.Type == 49 && m.SubType == 6:
+		return "文件"
+	}
+	return ""
+}
+
+// summary 返回消息的单行摘要，n 大于 0 时最多 n 个字符
+func summary(m *model.Message, n int) string {
+	var text string
+	if kind := mediaKind(m); kind != "" {
+		text = "[" + kind + "]"
+		if title, ok := m.Contents["title"].(string); ok && title != "" {
+			text += " " + title
+		}
+	} else {
+		text = m.PlainTextContent()
+	}
+	text = strings.Join(strings.Fields(text), " ")
+	if r := []rune(text); n > 0 && len(r) > n {
+		text = string(r[:n]) + "…"
+	}
+	return text
+}
+
+// detail 返回消息详情，多媒体消息附带预览方式，host 为 HTTP 服务地址，服务未启动时为空
+func detail(m *model.Message, host string) string {
+	buf := strings.Builder{}
+	sender := m.Sender
+	if m.IsSelf {
+		sender = "我"
+	}
+	if m.SenderName != "" {
+		sender = fmt.Sprintf("%s(%s)", m.SenderName, sender)
+	}
+	fmt.Fprintf(&buf, "[%s::b]%s[-:-:-]  %s", style.GetColorHex(style.MenuBgColor), tview.Escape(sender), m.Time.Format("2006-01-02 15:04:05"))
+	if m.TimeAnomaly != "" {
+		buf.WriteString("  [时间异常[]")
+	}
+	fmt.Fprintf(&buf, "  #%d\n\n", m.Seq)
+
+	kind := mediaKind(m)
+	if kind == "" {
+		buf.WriteString(tview.Escape(m.PlainTextContent()))
+		buf.WriteString("\n")
+	} else {
+		m.SetContent("host", host)
+		if host != "" {
+			fmt.Fprintf(&buf, "%s，可在浏览器中打开预览:\n%s\n", kind, tview.Escape(m.PlainTextContent()))
+		} else {
+			fmt.Fprintf(&buf, "%s，启动 HTTP 服务后可在浏览器中预览，或使用 chatlog export 导出\n", kind)
+		}
+	}
+	if m.Translation != "" {
+		buf.WriteString("\n> ")
+		buf.WriteString(tview.Escape(strings.ReplaceAll(m.Translation, "\n", "\n> ")))
+		buf.WriteString("\n")
+	}
+	return buf.String()
+}
@@ -0,0 +1,96 @@
+package browser
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aspnmy/chatlog/internal/model"
+)
+
+func TestFindMessage(t *testing.T) {
+	msgs := []*model.Message{
+		{Type: 1, Content: "Hello"},
+		{Type: 1, Content: "world", SenderName: "Alice"},
+		{Type: 1, Content: "hello again"},
+	}
+	tests := []struct {
+		query string
+		from  int
+		step  int
+		want  int
+	}{
+		{"hello", 0, 1, 0},
+		{"hello", 1, 1, 2},
+		{"hello", 3, 1, 0},   // 到达末尾后从头继续
+		{"hello", -1, -1, 2}, // 向前查找从最后一条继续
+		{"alice", 0, 1, 1},
+		{"missing", 0, 1, -1},
+		{"", 0, 1, -1},
+	}
+	for _, tt := range tests {
+		if got := findMessage(msgs, tt.query, tt.from, tt.step); got != tt.want {
+			t.Errorf("findMessage(%q, %d, %d) = %d, want %d", tt.query, tt.from, tt.step, got, tt.want)
+		}
+	}
+}
+
+func TestFirstAfter(t *testing.T) {
+	base := time.Date(2024, 1, 2, 10, 0, 0, 0, time.Local)
+	msgs := []*model.Message{{Time: base}, {Time: base.Add(time.Hour)}, {Time: base.Add(2 * time.Hour)}}
+	if got := firstAfter(msgs, base.Add(30*time.Minute)); got != 1 {
+		t.Errorf("firstAfter = %d, want 1", got)
+	}
+	if got := firstAfter(msgs, base.Add(-time.Hour)); got != 0 {
+		t.Errorf("firstAfter before all = %d, want 0", got)
+	}
+	if got := firstAfter(msgs, base.Add(5*time.Hour)); got != 2 {
+		t.Errorf("firstAfter after all = %d, want 2", got)
+	}
+	if got := firstAfter(nil, base); got != -1 {
+		t.Errorf("firstAfter empty = %d, want -1", got)
+	}
+}
+
+func TestMonthOf(t *testing.T) {
+	start, end := monthOf(time.Date(2024, 2, 15, 12, 0, 0, 0, time.Local))
+	if !start.Equal(time.Date(2024, 2, 1, 0, 0, 0, 0, time.Local)) {
+		t.Errorf("start = %v", start)
+	}
+	if end.Day() != 29 || end.Month() != 2 || end.Hour() != 23 {
+		t.Errorf("end = %v", end)
+	}
+}
+
+func TestFilterSessions(t *testing.T) {
+	sessions := []*model.Session{
+		{UserName: "123@chatroom", NickName: "工作群"},
+		{UserName: "bob", NickName: "Bob"},
+	}
+	if got := filterSessions(sessions, "BO"); len(got) != 1 || got[0].UserName != "bob" {
+		t.Errorf("filterSessions(BO) = %v", got)
+	}
+	if got := filterSessions(sessions, "@chatroom"); len(got) != 1 || got[0].NickName != "工作群" {
+		t.Errorf("filterSessions(@chatroom) = %v", got)
+	}
+	if got := filterSessions(sessions, " "); len(got) != 2 {
+		t.Errorf("filterSessions(empty) = %v", got)
+	}
+}
+
+func TestShiftRange(t *testing.T) {
+	start, end := monthOf(time.Date(2024, 1, 15, 0, 0, 0, 0, time.Local))
+	s, e := shiftRange(start, end, 1)
+	if !s.Equal(time.Date(2024, 2, 1, 0, 0, 0, 0, time.Local)) || !isMonth(s, e) {
+		t.Errorf("shiftRange month = %v ~ %v", s, e)
+	}
+
+	start = time.Date(2024, 1, 1, 0, 0, 0, 0, time.Local)
+	end = time.Date(2024, 1, 7, 23, 59, 59, 999999999, time.Local)
+	s, e = shiftRange(start, end, -1)
+	if got := rangeLabel(s, e); got != "2023-12-25 ~ 2023-12-31" {
+		t.Errorf("shiftRange week = %s", got)
+	}
+	if got := rangeLabel(monthOf(start)); got != "2024-01" {
+		t.Errorf("rangeLabel month = %s", got)
+	}
+}
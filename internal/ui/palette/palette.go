@@ -0,0 +1,244 @@
+package palette
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/aspnmy/chatlog/internal/ui/style"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+const (
+	Title = "palette"
+
+	// Width 命令面板的宽度
+	Width = 72
+	// Height 命令面板的高度，含输入框与边框
+	Height = 18
+)
+
+// Item 命令面板中的一条命令
+type Item struct {
+	Name        string
+	Description string
+	Action      func()
+}
+
+// Palette 命令面板，输入关键词模糊匹配命令名称，↑/↓ 选择，Enter 执行，ESC 关闭
+type Palette struct {
+	*tview.Flex
+
+	frame *tview.Flex
+	input *tview.InputField
+	list  *tview.Table
+	items []Item
+	shown []int // 匹配的命令在 items 中的下标，按匹配程度排列
+	done  func()
+}
+
+func New(items []Item) *Palette {
+	p := &Palette{
+		Flex:  tview.NewFlex(),
+		frame: tview.NewFlex(),
+		input: tview.NewInputField(),
+		list:  tview.NewTable(),
+	}
+
+	p.input.SetLabel("> ").
+		SetFieldBackgroundColor(style.DialogBgColor).
+		SetChangedFunc(func(text string) {
+			p.refresh()
+		})
+	p.input.SetBackgroundColor(style.DialogBgColor)
+	p.input.SetInputCapture(p.inputCapture)
+
+	p.list.SetSelectable(true, false)
+	p.list.SetBackgroundColor(style.DialogBgColor)
+	p.list.SetSelectedFunc(func(row, column int) {
+		p.run(row)
+	})
+
+	p.frame.SetDirection(tview.FlexRow).
+		AddItem(p.input, 1, 0, true).
+		AddItem(p.list, 0, 1, false)
+	p.frame.SetBorder(true).
+		SetTitle(" 命令面板 ").
+		SetBorderColor(style.DialogBorderColor).
+		SetBackgroundColor(style.DialogBgColor)
+
+	// 居中显示
+	column := tview.NewFlex().SetDirection(tview.FlexRow).
+		AddItem(nil, 0, 1, false).
+		AddItem(p.frame, Height, 0, true).
+		AddItem(nil, 0, 2, false)
+	p.AddItem(nil, 0, 1, false).
+		AddItem(column, Width, 0, true).
+		AddItem(nil, 0, 1, false)
+
+	p.SetItems(items)
+	return p
+}
+
+// Show 在 pages 中打开命令面板，关闭后恢复之前的焦点，已经打开时返回 nil
+func Show(app *tview.Application, pages *tview.Pages, items []Item) *Palette {
+	if pages.HasPage(Title) {
+		return nil
+	}
+	prev := app.GetFocus()
+	p := New(items)
+	p.SetDoneFunc(func() {
+		pages.RemovePage(Title)
+		if prev != nil {
+			app.SetFocus(prev)
+		}
+	})
+	pages.AddPage(Title, p, true, true)
+	app.SetFocus(p)
+	return p
+}
+
+// SetDoneFunc 设置关闭命令面板时的回调，执行命令前也会先关闭
+func (p *Palette) SetDoneFunc(done func()) *Palette {
+	p.done = done
+	return p
+}
+
+// SetItems 替换全部命令，保留已输入的关键词
+func (p *Palette) SetItems(items []Item) {
+	p.items = items
+	p.refresh()
+}
+
+// AddItems 追加命令，用于异步加载的联系人等
+func (p *Palette) AddItems(items ...Item) {
+	p.items = append(p.items, items...)
+	p.refresh()
+}
+
+func (p *Palette) refresh() {
+	p.shown = Filter(p.items, p.input.GetText())
+	p.list.Clear()
+	for row, i := range p.shown {
+		item := p.items[i]
+		p.list.SetCell(row, 0, tview.NewTableCell(tview.Escape(item.Name)).SetTextColor(style.DialogFgColor))
+		p.list.SetCell(row, 1, tview.NewTableCell(tview.Escape(item.Description)).
+			SetTextColor(style.InfoBarItemFgColor).SetExpansion(1))
+	}
+	p.list.Select(0, 0)
+	p.list.ScrollToBeginning()
+	p.frame.SetTitle(fmt.Sprintf(" 命令面板 %d/%d ", len(p.shown), len(p.items)))
+}
+
+func (p *Palette) inputCapture(event *tcell.EventKey) *tcell.EventKey {
+	row, _ := p.list.GetSelection()
+	switch event.Key() {
+	case tcell.KeyUp, tcell.KeyCtrlP:
+		if row > 0 {
+			p.list.Select(row-1, 0)
+		}
+	case tcell.KeyDown, tcell.KeyCtrlN:
+		if row < len(p.shown)-1 {
+			p.list.Select(row+1, 0)
+		}
+	case tcell.KeyEnter:
+		p.run(row)
+	case tcell.KeyEscape:
+		p.close()
+	default:
+		return event
+	}
+	return nil
+}
+
+func (p *Palette) run(row int) {
+	if row < 0 || row >= len(p.shown) {
+		return
+	}
+	item := p.items[p.shown[row]]
+	p.close()
+	if item.Action != nil {
+		item.Action()
+	}
+}
+
+func (p *Palette) close() {
+	if p.done != nil {
+		p.done()
+	}
+}
+
+// Filter 返回与 query 模糊匹配的命令下标，按匹配程度从高到低排列，程度相同时保持原有顺序
+// 名称不匹配时尝试匹配说明，说明的匹配排在名称之后，query 为空时返回全部命令
+func Filter(items []Item, query string) []int {
+	type scored struct {
+		index int
+		score int
+	}
+	matches := make([]scored, 0, len(items))
+	for i, item := range items {
+		score, ok := Match(query, item.Name)
+		if !ok {
+			if score, ok = Match(query, item.Description); ok {
+				score -= 1000
+			}
+		}
+		if ok {
+			matches = append(matches, scored{i, score})
+		}
+	}
+	slices.SortStableFunc(matches, func(a, b scored) int {
+		return b.score - a.score
+	})
+	shown := make([]int, len(matches))
+	for i, m := range matches {
+		shown[i] = m.index
+	}
+	return shown
+}
+
+// Match 判断 query 中的字符（忽略空白与大小写）是否按顺序出现在 text 中，返回匹配程度
+// 连续匹配与出现在单词开头的字符得分更高，越靠前开始匹配得分越高
+func Match(query, text string) (int, bool) {
+	q := []rune(strings.ToLower(strings.Join(strings.Fields(query), "")))
+	if len(q) == 0 {
+		return 0, true
+	}
+	t := []rune(strings.ToLower(text))
+	score, qi, last, first := 0, 0, -2, -1
+	for ti, r := range t {
+		if qi == len(q) {
+			break
+		}
+		if r != q[qi] {
+			continue
+		}
+		score++
+		if ti == last+1 {
+			score += 5
+		}
+		if ti == 0 || !isWordRune(t[ti-1]) || (isCJK(r) && !isCJK(t[ti-1])) {
+			score += 3
+		}
+		if first < 0 {
+			first = ti
+		}
+		last = ti
+		qi++
+	}
+	if qi < len(q) {
+		return 0, false
+	}
+	return score*10 - first, true
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+func isCJK(r rune) bool {
+	return unicode.Is(unicode.Han, r)
+}
@@ -0,0 +1,53 @@
+package palette
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		query string
+		text  string
+		ok    bool
+	}{
+		{"", "anything", true},
+		{"http", "启动 HTTP 服务", true},
+		{"hs", "HTTP Server", true},
+		{"导出", "导出聊天记录...", true},
+		{"出导", "导出聊天记录...", false},
+		{"last 7", "last-7d", true},
+		{"xyz", "HTTP Server", false},
+	}
+	for _, tt := range tests {
+		if _, ok := Match(tt.query, tt.text); ok != tt.ok {
+			t.Errorf("Match(%q, %q) = %v, want %v", tt.query, tt.text, ok, tt.ok)
+		}
+	}
+
+	// 连续匹配与单词开头优先
+	prefix, _ := Match("exp", "export chats")
+	scattered, _ := Match("exp", "e-x-p")
+	if prefix <= scattered {
+		t.Errorf("consecutive match score %d should be higher than %d", prefix, scattered)
+	}
+}
+
+func TestFilter(t *testing.T) {
+	items := []Item{
+		{Name: "设置", Description: "配置 HTTP 地址"},
+		{Name: "会话: http 群"},
+		{Name: "退出"},
+		{Name: "启动 HTTP 服务"},
+	}
+	if got := Filter(items, ""); !slices.Equal(got, []int{0, 1, 2, 3}) {
+		t.Errorf("Filter empty = %v", got)
+	}
+	// 越靠前开始匹配越优先，名称匹配排在说明匹配之前
+	if got := Filter(items, "http"); !slices.Equal(got, []int{3, 1, 0}) {
+		t.Errorf("Filter http = %v", got)
+	}
+	if got := Filter(items, "none"); len(got) != 0 {
+		t.Errorf("Filter none = %v", got)
+	}
+}
@@ -0,0 +1,42 @@
+package tray
+
+// Item 托盘菜单项
+type Item struct {
+	Title     string // 菜单显示文字，为空时作为分隔线
+	Disabled  bool   // 是否置灰（仅用于展示状态）
+	OnClicked func() // 点击回调
+}
+
+// Separator 返回一个分隔线菜单项
+func Separator() *Item {
+	return &Item{}
+}
+
+// Tray 系统托盘图标
+// 菜单在每次弹出时通过 menuFunc 重新生成，以便展示最新的同步状态
+type Tray struct {
+	tooltip  string
+	menuFunc func() []*Item
+	onReady  func()
+}
+
+// New 创建系统托盘
+func New(tooltip string, menuFunc func() []*Item) *Tray {
+	return &Tray{
+		tooltip:  tooltip,
+		menuFunc: menuFunc,
+	}
+}
+
+// OnReady 设置托盘图标创建完成后的回调
+func (t *Tray) OnReady(fn func()) {
+	t.onReady = fn
+}
+
+// items 生成当前菜单项
+func (t *Tray) items() []*Item {
+	if t.menuFunc == nil {
+		return nil
+	}
+	return t.menuFunc()
+}
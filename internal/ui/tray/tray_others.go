@@ -0,0 +1,21 @@
+//go:build !windows
+
+package tray
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// Run 非 Windows 平台暂不支持系统托盘
+func (t *Tray) Run() error {
+	return fmt.Errorf("tray mode is not supported on %s", runtime.GOOS)
+}
+
+// SetTooltip 非 Windows 平台无操作
+func (t *Tray) SetTooltip(tooltip string) {
+	t.tooltip = tooltip
+}
+
+// Quit 非 Windows 平台无操作
+func (t *Tray) Quit() {}
@@ -0,0 +1,317 @@
+//go:build windows
+
+package tray
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+const (
+	wmDestroy      = 0x0002
+	wmClose        = 0x0010
+	wmNull         = 0x0000
+	wmLButtonUp    = 0x0202
+	wmRButtonUp    = 0x0205
+	wmApp          = 0x8000
+	wmTrayCallback = wmApp + 1
+	wmTrayUpdate   = wmApp + 2
+
+	nimAdd    = 0x00000000
+	nimModify = 0x00000001
+	nimDelete = 0x00000002
+
+	nifMessage = 0x00000001
+	nifIcon    = 0x00000002
+	nifTip     = 0x00000004
+
+	mfString    = 0x00000000
+	mfGrayed    = 0x00000001
+	mfSeparator = 0x00000800
+
+	tpmReturnCmd = 0x0100
+	tpmNoNotify  = 0x0080
+
+	idiApplication = 32512
+)
+
+var (
+	user32   = windows.NewLazySystemDLL("user32.dll")
+	shell32  = windows.NewLazySystemDLL("shell32.dll")
+	kernel32 = windows.NewLazySystemDLL("kernel32.dll")
+
+	procRegisterClassExW = user32.NewProc("RegisterClassExW")
+	procCreateWindowExW  = user32.NewProc("CreateWindowExW")
+	procDefWindowProcW   = user32.NewProc("DefWindowProcW")
+	procDestroyWindow    = user32.NewProc("DestroyWindow")
+	procGetMessageW      = user32.NewProc("GetMessageW")
+	procTranslateMessage = user32.NewProc("TranslateMessage")
+	procDispatchMessageW = user32.NewProc("DispatchMessageW")
+	procPostMessageW     = user32.NewProc("PostMessageW")
+	procPostQuitMessage  = user32.NewProc("PostQuitMessage")
+	procLoadIconW        = user32.NewProc("LoadIconW")
+	procCreatePopupMenu  = user32.NewProc("CreatePopupMenu")
+	procAppendMenuW      = user32.NewProc("AppendMenuW")
+	procTrackPopupMenu   = user32.NewProc("TrackPopupMenu")
+	procDestroyMenu      = user32.NewProc("DestroyMenu")
+	procGetCursorPos     = user32.NewProc("GetCursorPos")
+	procSetForegroundWnd = user32.NewProc("SetForegroundWindow")
+	procShellNotifyIconW = shell32.NewProc("Shell_NotifyIconW")
+	procGetModuleHandleW = kernel32.NewProc("GetModuleHandleW")
+)
+
+type wndClassEx struct {
+	cbSize        uint32
+	style         uint32
+	lpfnWndProc   uintptr
+	cbClsExtra    int32
+	cbWndExtra    int32
+	hInstance     uintptr
+	hIcon         uintptr
+	hCursor       uintptr
+	hbrBackground uintptr
+	lpszMenuName  *uint16
+	lpszClassName *uint16
+	hIconSm       uintptr
+}
+
+type notifyIconData struct {
+	cbSize           uint32
+	hWnd             uintptr
+	uID              uint32
+	uFlags           uint32
+	uCallbackMessage uint32
+	hIcon            uintptr
+	szTip            [128]uint16
+	dwState          uint32
+	dwStateMask      uint32
+	szInfo           [256]uint16
+	uVersion         uint32
+	szInfoTitle      [64]uint16
+	dwInfoFlags      uint32
+	guidItem         windows.GUID
+	hBalloonIcon     uintptr
+}
+
+type point struct {
+	x, y int32
+}
+
+type msg struct {
+	hwnd    uintptr
+	message uint32
+	wParam  uintptr
+	lParam  uintptr
+	time    uint32
+	pt      point
+}
+
+// 窗口过程为全局回调，同一进程内只允许一个托盘实例
+var (
+	current   *Tray
+	currentMu sync.Mutex
+)
+
+type state struct {
+	hwnd uintptr
+	nid  notifyIconData
+	mu   sync.Mutex
+}
+
+var st state
+
+// Run 创建托盘图标并进入消息循环，直到 Quit 被调用
+func (t *Tray) Run() error {
+	currentMu.Lock()
+	if current != nil {
+		currentMu.Unlock()
+		return fmt.Errorf("tray already running")
+	}
+	current = t
+	currentMu.Unlock()
+	defer func() {
+		currentMu.Lock()
+		current = nil
+		currentMu.Unlock()
+	}()
+
+	// Win32 窗口与消息循环必须在同一个系统线程上
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	hInstance, _, _ := procGetModuleHandleW.Call(0)
+	className, _ := windows.UTF16PtrFromString("ChatlogTrayWindow")
+
+	wc := wndClassEx{
+		lpfnWndProc:   syscall.NewCallback(wndProc),
+		hInstance:     hInstance,
+		lpszClassName: className,
+	}
+	wc.cbSize = uint32(unsafe.Sizeof(wc))
+	if ret, _, err := procRegisterClassExW.Call(uintptr(unsafe.Pointer(&wc))); ret == 0 {
+		return fmt.Errorf("register window class failed: %v", err)
+	}
+
+	hwnd, _, err := procCreateWindowExW.Call(
+		0,
+		uintptr(unsafe.Pointer(className)),
+		uintptr(unsafe.Pointer(className)),
+		0, 0, 0, 0, 0, 0, 0,
+		hInstance,
+		0,
+	)
+	if hwnd == 0 {
+		return fmt.Errorf("create window failed: %v", err)
+	}
+
+	hIcon, _, _ := procLoadIconW.Call(0, uintptr(idiApplication))
+
+	st.mu.Lock()
+	st.hwnd = hwnd
+	st.nid = notifyIconData{
+		hWnd:             hwnd,
+		uID:              1,
+		uFlags:           nifMessage | nifIcon | nifTip,
+		uCallbackMessage: wmTrayCallback,
+		hIcon:            hIcon,
+	}
+	st.nid.cbSize = uint32(unsafe.Sizeof(st.nid))
+	copyTip(&st.nid, t.tooltip)
+	ret, _, err := procShellNotifyIconW.Call(nimAdd, uintptr(unsafe.Pointer(&st.nid)))
+	st.mu.Unlock()
+	if ret == 0 {
+		procDestroyWindow.Call(hwnd)
+		return fmt.Errorf("add tray icon failed: %v", err)
+	}
+
+	if t.onReady != nil {
+		go t.onReady()
+	}
+
+	var m msg
+	for {
+		ret, _, _ := procGetMessageW.Call(uintptr(unsafe.Pointer(&m)), 0, 0, 0)
+		if int32(ret) <= 0 {
+			break
+		}
+		procTranslateMessage.Call(uintptr(unsafe.Pointer(&m)))
+		procDispatchMessageW.Call(uintptr(unsafe.Pointer(&m)))
+	}
+
+	return nil
+}
+
+// SetTooltip 更新鼠标悬停时的提示文字
+func (t *Tray) SetTooltip(tooltip string) {
+	t.tooltip = tooltip
+
+	st.mu.Lock()
+	hwnd := st.hwnd
+	st.mu.Unlock()
+	if hwnd != 0 {
+		procPostMessageW.Call(hwnd, wmTrayUpdate, 0, 0)
+	}
+}
+
+// Quit 移除托盘图标并退出消息循环
+func (t *Tray) Quit() {
+	st.mu.Lock()
+	hwnd := st.hwnd
+	st.mu.Unlock()
+	if hwnd != 0 {
+		procPostMessageW.Call(hwnd, wmClose, 0, 0)
+	}
+}
+
+func wndProc(hwnd, message, wParam, lParam uintptr) uintptr {
+	switch message {
+	case wmTrayCallback:
+		switch lParam & 0xFFFF {
+		case wmLButtonUp, wmRButtonUp:
+			showMenu(hwnd)
+		}
+		return 0
+	case wmTrayUpdate:
+		currentMu.Lock()
+		t := current
+		currentMu.Unlock()
+		if t != nil {
+			st.mu.Lock()
+			st.nid.uFlags = nifTip
+			copyTip(&st.nid, t.tooltip)
+			procShellNotifyIconW.Call(nimModify, uintptr(unsafe.Pointer(&st.nid)))
+			st.mu.Unlock()
+		}
+		return 0
+	case wmDestroy:
+		st.mu.Lock()
+		procShellNotifyIconW.Call(nimDelete, uintptr(unsafe.Pointer(&st.nid)))
+		st.hwnd = 0
+		st.mu.Unlock()
+		procPostQuitMessage.Call(0)
+		return 0
+	}
+	ret, _, _ := procDefWindowProcW.Call(hwnd, message, wParam, lParam)
+	return ret
+}
+
+// showMenu 在鼠标位置弹出菜单，并执行被点击项的回调
+func showMenu(hwnd uintptr) {
+	currentMu.Lock()
+	t := current
+	currentMu.Unlock()
+	if t == nil {
+		return
+	}
+
+	items := t.items()
+	menu, _, _ := procCreatePopupMenu.Call()
+	if menu == 0 {
+		return
+	}
+	defer procDestroyMenu.Call(menu)
+
+	for i, item := range items {
+		if item.Title == "" {
+			procAppendMenuW.Call(menu, mfSeparator, 0, 0)
+			continue
+		}
+		flags := uintptr(mfString)
+		if item.Disabled {
+			flags |= mfGrayed
+		}
+		title, _ := windows.UTF16PtrFromString(item.Title)
+		// 菜单 ID 从 1 开始，0 表示未选择任何项
+		procAppendMenuW.Call(menu, flags, uintptr(i+1), uintptr(unsafe.Pointer(title)))
+	}
+
+	var pt point
+	procGetCursorPos.Call(uintptr(unsafe.Pointer(&pt)))
+
+	// 不先置前窗口的话，点击菜单外部时菜单不会消失
+	procSetForegroundWnd.Call(hwnd)
+	cmd, _, _ := procTrackPopupMenu.Call(menu, tpmReturnCmd|tpmNoNotify, uintptr(pt.x), uintptr(pt.y), 0, hwnd, 0)
+	procPostMessageW.Call(hwnd, wmNull, 0, 0)
+
+	if cmd == 0 || int(cmd) > len(items) {
+		return
+	}
+	if item := items[cmd-1]; item.OnClicked != nil && !item.Disabled {
+		go item.OnClicked()
+	}
+}
+
+func copyTip(nid *notifyIconData, tooltip string) {
+	tip, _ := windows.UTF16FromString(tooltip)
+	if len(tip) > len(nid.szTip) {
+		tip = tip[:len(nid.szTip)-1]
+		tip = append(tip, 0)
+	}
+	nid.szTip = [128]uint16{}
+	copy(nid.szTip[:], tip)
+}
@@ -5,6 +5,9 @@ import (
 	"crypto/aes"
 	"crypto/cipher"
 	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
 	"encoding/binary"
 	"fmt"
 	"hash"
@@ -48,23 +51,34 @@ func OpenDBFile(dbPath string, pageSize int) (*DBFile, error) {
 	}
 
 	buffer := make([]byte, pageSize)
-	n, err := io.ReadFull(fp, buffer)
-	if err != nil {
+	if _, err := io.ReadFull(fp, buffer); err != nil {
 		return nil, errors.ReadFileFailed(dbPath, err)
 	}
-	if n != pageSize {
-		return nil, errors.IncompleteRead(fmt.Errorf("read %d bytes, expected %d", n, pageSize))
+
+	d, err := NewDBFileFromHeader(buffer, pageSize)
+	if err != nil {
+		return nil, err
+	}
+	d.Path = dbPath
+	d.TotalPages = totalPages
+	return d, nil
+}
+
+// NewDBFileFromHeader 使用数据库文件的第一页创建 DBFile，用于无法访问数据库文件时验证密钥
+func NewDBFileFromHeader(header []byte, pageSize int) (*DBFile, error) {
+	if len(header) < pageSize {
+		return nil, errors.IncompleteRead(fmt.Errorf("got %d bytes, expected %d", len(header), pageSize))
 	}
+	buffer := header[:pageSize]
 
 	if bytes.Equal(buffer[:len(SQLiteHeader)-1], []byte(SQLiteHeader[:len(SQLiteHeader)-1])) {
 		return nil, errors.ErrAlreadyDecrypted
 	}
 
 	return &DBFile{
-		Path:       dbPath,
 		Salt:       buffer[:SaltSize],
 		FirstPage:  buffer,
-		TotalPages: totalPages,
+		TotalPages: 1,
 	}, nil
 }
 
@@ -136,3 +150,107 @@ func DecryptPage(pageBuf []byte, encKey []byte, macKey []byte, pageNum int64, ha
 
 	return decryptedPage, nil
 }
+
+// HMAC 算法名称
+const (
+	HMACSHA1   = "HMAC-SHA1"
+	HMACSHA256 = "HMAC-SHA256"
+	HMACSHA512 = "HMAC-SHA512"
+)
+
+// CipherAES256CBC 页面加密算法，SQLCipher 目前只使用 AES-256-CBC
+const CipherAES256CBC = "AES-256-CBC"
+
+// CipherInfo 密钥验证通过时使用的加密参数，随密钥保存，解密时使用相同的参数
+type CipherInfo struct {
+	DBFile   string `mapstructure:"db_file" json:"db_file"`     // 验证使用的数据库文件
+	PageSize int    `mapstructure:"page_size" json:"page_size"` // 解密后 SQLite 头中的页面大小
+	KDFIter  int    `mapstructure:"kdf_iter" json:"kdf_iter"`   // PBKDF2 迭代次数，0 表示密钥不经派生直接使用
+	HMAC     string `mapstructure:"hmac" json:"hmac"`
+	Cipher   string `mapstructure:"cipher" json:"cipher,omitempty"` // 页面加密算法，为空表示 AES-256-CBC
+}
+
+func (c *CipherInfo) String() string {
+	return fmt.Sprintf("page size %d, kdf iter %d, %s (%s)", c.PageSize, c.KDFIter, c.HMAC, c.DBFile)
+}
+
+// Merge 返回 c 的副本，o 中设置了的参数覆盖 c 中的参数，c 为空时返回 o 的副本
+func (c *CipherInfo) Merge(o *CipherInfo) *CipherInfo {
+	var merged CipherInfo
+	if c != nil {
+		merged = *c
+	}
+	if o == nil {
+		return &merged
+	}
+	if o.DBFile != "" {
+		merged.DBFile = o.DBFile
+	}
+	if o.PageSize > 0 {
+		merged.PageSize = o.PageSize
+	}
+	if o.KDFIter > 0 {
+		merged.KDFIter = o.KDFIter
+	}
+	if o.HMAC != "" {
+		merged.HMAC = o.HMAC
+	}
+	if o.Cipher != "" {
+		merged.Cipher = o.Cipher
+	}
+	return &merged
+}
+
+// CheckCipher 检查页面加密算法是否支持，为空表示默认的 AES-256-CBC
+func CheckCipher(name string) error {
+	if name != "" && name != CipherAES256CBC {
+		return fmt.Errorf("unsupported cipher %q", name)
+	}
+	return nil
+}
+
+// HMACOf 返回 HMAC 算法对应的哈希函数与 HMAC 长度
+func HMACOf(name string) (func() hash.Hash, int, error) {
+	switch name {
+	case HMACSHA1:
+		return sha1.New, sha1.Size, nil
+	case HMACSHA256:
+		return sha256.New, sha256.Size, nil
+	case HMACSHA512:
+		return sha512.New, sha512.Size, nil
+	}
+	return nil, 0, fmt.Errorf("unsupported hmac algorithm %q", name)
+}
+
+// HMACName 返回 HMAC 长度对应的算法名称
+func HMACName(hmacSize int) string {
+	switch hmacSize {
+	case sha512.Size:
+		return HMACSHA512
+	case sha256.Size:
+		return HMACSHA256
+	}
+	return HMACSHA1
+}
+
+// Reserve 返回每页末尾保留的字节数，即 IV 与 HMAC 按 AES 块大小对齐后的长度
+func Reserve(hmacSize int) int {
+	reserve := IVSize + hmacSize
+	if reserve%AESBlockSize != 0 {
+		reserve = ((reserve / AESBlockSize) + 1) * AESBlockSize
+	}
+	return reserve
+}
+
+// PageSizeOf 读取解密后第一页中 SQLite 头记录的页面大小，
+// 解密后的第一页从文件偏移 16 开始，即页面大小字段位于开头
+func PageSizeOf(page1 []byte) int {
+	if len(page1) < 2 {
+		return 0
+	}
+	size := int(binary.BigEndian.Uint16(page1[:2]))
+	if size == 1 {
+		return 65536
+	}
+	return size
+}
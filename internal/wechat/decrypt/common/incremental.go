@@ -0,0 +1,158 @@
+package common
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc64"
+	"io"
+	"os"
+	"time"
+)
+
+// PageSumsExt 保存在解密输出旁、记录上一次各加密页面校验和的文件扩展名
+const PageSumsExt = ".pages"
+
+const pageSumsMagic = "CLPS\x01"
+
+var crcTable = crc64.MakeTable(crc64.ECMA)
+
+// PageSums 上一次解密时各加密页面的 CRC-64，以及当时解密输出的大小与修改时间，
+// 输出在两次解密之间被修改过时不能再作为增量解密的基础
+type PageSums struct {
+	PageSize int
+	Size     int64
+	ModTime  time.Time
+	Sums     []uint64
+}
+
+// ReadPageSums 读取 path 中的页面校验和
+func ReadPageSums(path string) (*PageSums, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.HasPrefix(b, []byte(pageSumsMagic)) || len(b) < len(pageSumsMagic)+28 {
+		return nil, fmt.Errorf("invalid page sums file: %s", path)
+	}
+	b = b[len(pageSumsMagic):]
+	p := &PageSums{
+		PageSize: int(binary.LittleEndian.Uint32(b)),
+		Size:     int64(binary.LittleEndian.Uint64(b[4:])),
+		ModTime:  time.Unix(0, int64(binary.LittleEndian.Uint64(b[12:]))),
+	}
+	n := binary.LittleEndian.Uint64(b[20:])
+	b = b[28:]
+	if uint64(len(b)) != n*8 {
+		return nil, fmt.Errorf("invalid page sums file: %s", path)
+	}
+	p.Sums = make([]uint64, n)
+	for i := range p.Sums {
+		p.Sums[i] = binary.LittleEndian.Uint64(b[i*8:])
+	}
+	return p, nil
+}
+
+// Write 将页面校验和写入 path，先写入临时文件再替换
+func (p *PageSums) Write(path string) error {
+	b := make([]byte, 0, len(pageSumsMagic)+28+len(p.Sums)*8)
+	b = append(b, pageSumsMagic...)
+	b = binary.LittleEndian.AppendUint32(b, uint32(p.PageSize))
+	b = binary.LittleEndian.AppendUint64(b, uint64(p.Size))
+	b = binary.LittleEndian.AppendUint64(b, uint64(p.ModTime.UnixNano()))
+	b = binary.LittleEndian.AppendUint64(b, uint64(len(p.Sums)))
+	for _, sum := range p.Sums {
+		b = binary.LittleEndian.AppendUint64(b, sum)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, b, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// Matches 判断解密输出自记录校验和后是否未被修改
+func (p *PageSums) Matches(output string) bool {
+	info, err := os.Stat(output)
+	return err == nil && info.Size() == p.Size && info.ModTime().Equal(p.ModTime)
+}
+
+// Incremental 增量解密的输出
+//
+// f 为上一次解密结果的副本，加密内容与上一次相同的页面不解密也不写入，直接跳过，
+// 只重写变化的页面；prev 为空时与完整解密相同，同时记录本次的页面校验和
+type Incremental struct {
+	f        *os.File
+	w        *bufio.Writer
+	pageSize int
+	prev     []uint64
+	sums     []uint64
+
+	// Skipped 跳过的页面数
+	Skipped int64
+}
+
+// NewIncremental 创建增量解密的输出，dst 写入 f 当前位置（可以是限速的包装），
+// prev 的页面大小与解密器不同时不跳过任何页面
+func NewIncremental(f *os.File, dst io.Writer, bufSize int, pageSize int, prev *PageSums) *Incremental {
+	o := &Incremental{
+		f:        f,
+		w:        bufio.NewWriterSize(dst, bufSize),
+		pageSize: pageSize,
+	}
+	if prev != nil && prev.PageSize == pageSize {
+		o.prev = prev.Sums
+	}
+	return o
+}
+
+func (o *Incremental) Write(p []byte) (int, error) {
+	return o.w.Write(p)
+}
+
+// Unchanged 记录页面校验和，第一页包含文件头，总是重新解密
+func (o *Incremental) Unchanged(pgno int64, page []byte) bool {
+	sum := crc64.Checksum(page, crcTable)
+	for int64(len(o.sums)) <= pgno {
+		o.sums = append(o.sums, 0)
+	}
+	o.sums[pgno] = sum
+	return pgno > 0 && pgno < int64(len(o.prev)) && o.prev[pgno] == sum
+}
+
+// Skip 跳过输出中未变化的页面
+func (o *Incremental) Skip(n int64) error {
+	if err := o.w.Flush(); err != nil {
+		return err
+	}
+	o.Skipped++
+	_, err := o.f.Seek(n, io.SeekCurrent)
+	return err
+}
+
+// Finish 写入缓冲的数据，并截断副本中多出的页面（数据库变小时）
+func (o *Incremental) Finish() error {
+	if err := o.w.Flush(); err != nil {
+		return err
+	}
+	pos, err := o.f.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	return o.f.Truncate(pos)
+}
+
+// PageSums 返回本次解密的页面校验和，output 为替换后的解密输出
+func (o *Incremental) PageSums(output string) (*PageSums, error) {
+	info, err := os.Stat(output)
+	if err != nil {
+		return nil, err
+	}
+	return &PageSums{
+		PageSize: o.pageSize,
+		Size:     info.Size(),
+		ModTime:  info.ModTime(),
+		Sums:     o.sums,
+	}, nil
+}
@@ -0,0 +1,113 @@
+package common
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestIncremental(t *testing.T) {
+	const pageSize = 16
+	dir := t.TempDir()
+	output := filepath.Join(dir, "out.db")
+	sumsPath := output + PageSumsExt
+
+	decrypted := 0
+	decrypt := func(page []byte, pgno int64) ([]byte, error) {
+		decrypted++
+		out := make([]byte, len(page))
+		for i, b := range page {
+			out[i] = b ^ byte(pgno)
+		}
+		return out, nil
+	}
+	makeDB := func(pages int, changed int) []byte {
+		var src []byte
+		for i := range pages {
+			b := byte(i + 1)
+			if i == changed {
+				b = 0xee
+			}
+			src = append(src, bytes.Repeat([]byte{b}, pageSize)...)
+		}
+		return src
+	}
+	// 在上一次输出的副本上增量解密，返回本次跳过的页数
+	run := func(src []byte) int64 {
+		prev, err := ReadPageSums(sumsPath)
+		if err != nil || !prev.Matches(output) {
+			prev = nil
+		}
+		tmp := output + ".tmp"
+		if prev != nil {
+			b, _ := os.ReadFile(output)
+			os.WriteFile(tmp, b, 0644)
+		}
+		f, err := os.OpenFile(tmp, os.O_RDWR|os.O_CREATE, 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		inc := NewIncremental(f, f, 64, pageSize, prev)
+		total := int64(len(src) / pageSize)
+		if err := DecryptPages(context.Background(), bytes.NewReader(src), "test.db", inc, total, pageSize, decrypt); err != nil {
+			t.Fatal(err)
+		}
+		if err := inc.Finish(); err != nil {
+			t.Fatal(err)
+		}
+		f.Close()
+		if err := os.Rename(tmp, output); err != nil {
+			t.Fatal(err)
+		}
+		sums, err := inc.PageSums(output)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := sums.Write(sumsPath); err != nil {
+			t.Fatal(err)
+		}
+		return inc.Skipped
+	}
+	// 完整解密的结果
+	full := func(src []byte) []byte {
+		var out bytes.Buffer
+		DecryptPages(context.Background(), bytes.NewReader(src), "test.db", &out, int64(len(src)/pageSize), pageSize, decrypt)
+		return out.Bytes()
+	}
+	check := func(src []byte) {
+		t.Helper()
+		got, _ := os.ReadFile(output)
+		if !bytes.Equal(got, full(src)) {
+			t.Error("incremental output differs from full decryption")
+		}
+	}
+
+	if skipped := run(makeDB(10, -1)); skipped != 0 {
+		t.Errorf("first run skipped %d pages", skipped)
+	}
+
+	// 只有第 5 页变化，第 0 页总是重新解密
+	src := makeDB(10, 5)
+	decrypted = 0
+	if skipped := run(src); skipped != 8 || decrypted != 2 {
+		t.Errorf("skipped %d, decrypted %d pages", skipped, decrypted)
+	}
+	check(src)
+
+	// 数据库变小时截断多出的页面
+	src = makeDB(6, 5)
+	run(src)
+	check(src)
+
+	// 输出被修改后不再作为增量的基础
+	f, _ := os.OpenFile(output, os.O_WRONLY|os.O_APPEND, 0644)
+	io.WriteString(f, "x")
+	f.Close()
+	if skipped := run(src); skipped != 0 {
+		t.Errorf("modified output was reused, skipped %d pages", skipped)
+	}
+	check(src)
+}
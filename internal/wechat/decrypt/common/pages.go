@@ -0,0 +1,138 @@
+package common
+
+import (
+	"context"
+	"io"
+	"sync"
+
+	"github.com/aspnmy/chatlog/internal/errors"
+)
+
+// PageWorkers 单个数据库文件内并发解密的页面数，<= 1 时逐页顺序解密
+// chatlog 已按文件并发解密，默认不再对页面并发；decryptdb 等独立工具可调大
+var PageWorkers = 1
+
+// pageBatch 并发解密时每批读取的页数
+const pageBatch = 256
+
+// PageFunc 解密第 pgno 页（从 0 开始），page 在返回后会被复用
+type PageFunc func(page []byte, pgno int64) ([]byte, error)
+
+// PageSkipper 由增量解密的输出实现，见 Incremental
+// Unchanged 按页号顺序调用，返回 true 的页面不解密，由 Skip 跳过输出中对应的位置
+type PageSkipper interface {
+	io.Writer
+	Unchanged(pgno int64, page []byte) bool
+	Skip(n int64) error
+}
+
+// DecryptPages 从 r 读取 totalPages 个页面，解密后按原顺序写入 output
+// 全零页面原样写入，末尾不完整的页面丢弃
+func DecryptPages(ctx context.Context, r io.Reader, path string, output io.Writer, totalPages int64, pageSize int, decrypt PageFunc) error {
+	workers := max(PageWorkers, 1)
+	batch := int64(1)
+	if workers > 1 {
+		batch = pageBatch
+	}
+
+	buf := make([]byte, int(batch)*pageSize)
+	out := make([][]byte, batch)
+	errs := make([]error, batch)
+	skip := make([]bool, batch)
+	skipper, _ := output.(PageSkipper)
+
+	for start := int64(0); start < totalPages; start += batch {
+		// 检查是否取消
+		select {
+		case <-ctx.Done():
+			return errors.ErrDecryptOperationCanceled
+		default:
+		}
+
+		// 读取一批页面，文件在解密过程中变短时只处理已读到的完整页面
+		n := min(batch, totalPages-start)
+		read, err := io.ReadFull(r, buf[:int(n)*pageSize])
+		partial := false
+		if err != nil {
+			if err != io.ErrUnexpectedEOF {
+				return errors.ReadFileFailed(path, err)
+			}
+			n, partial = int64(read/pageSize), true
+		}
+
+		// 未变化的页面不解密
+		for i := range n {
+			skip[i] = skipper != nil && skipper.Unchanged(start+i, buf[int(i)*pageSize:int(i+1)*pageSize])
+		}
+
+		decryptPage := func(i int64) {
+			page := buf[int(i)*pageSize : int(i+1)*pageSize]
+			if skip[i] {
+				out[i], errs[i] = nil, nil
+				return
+			}
+			if allZeros(page) {
+				out[i], errs[i] = page, nil
+				return
+			}
+			out[i], errs[i] = decrypt(page, start+i)
+		}
+		if workers == 1 || n == 1 {
+			for i := range n {
+				decryptPage(i)
+			}
+		} else {
+			var wg sync.WaitGroup
+			next := make(chan int64)
+			for range min(int64(workers), n) {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for i := range next {
+						decryptPage(i)
+					}
+				}()
+			}
+			for i := range n {
+				next <- i
+			}
+			close(next)
+			wg.Wait()
+		}
+
+		// 按顺序写入，遇到错误时与逐页解密一样保留之前的页面
+		for i := range n {
+			if errs[i] != nil {
+				return errs[i]
+			}
+			if skip[i] {
+				if err := skipper.Skip(int64(pageSize)); err != nil {
+					return errors.WriteOutputFailed(err)
+				}
+				continue
+			}
+			if _, err := output.Write(out[i]); err != nil {
+				return errors.WriteOutputFailed(err)
+			}
+		}
+
+		if partial {
+			// 与逐页读取一致：恰好停在页边界视为读取失败，不完整的末页直接丢弃
+			if read%pageSize == 0 {
+				return errors.ReadFileFailed(path, io.EOF)
+			}
+			return nil
+		}
+	}
+
+	return nil
+}
+
+func allZeros(b []byte) bool {
+	for _, c := range b {
+		if c != 0 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,58 @@
+package common
+
+import (
+	"bytes"
+	"context"
+	"testing"
+)
+
+func TestDecryptPages(t *testing.T) {
+	const pageSize = 16
+	// 1000 个页面，第 3 页全零，末尾多出半页
+	var src []byte
+	for i := range 1000 {
+		page := bytes.Repeat([]byte{byte(i%255 + 1)}, pageSize)
+		if i == 3 {
+			page = make([]byte, pageSize)
+		}
+		src = append(src, page...)
+	}
+	src = append(src, 1, 2, 3)
+	total := int64(len(src)/pageSize + 1)
+
+	// 每个字节加上页号，便于检查顺序
+	decrypt := func(page []byte, pgno int64) ([]byte, error) {
+		out := make([]byte, len(page))
+		for i, b := range page {
+			out[i] = b + byte(pgno)
+		}
+		return out, nil
+	}
+
+	defer func(w int) { PageWorkers = w }(PageWorkers)
+	var want []byte
+	for _, workers := range []int{1, 8} {
+		PageWorkers = workers
+		var out bytes.Buffer
+		if err := DecryptPages(context.Background(), bytes.NewReader(src), "test.db", &out, total, pageSize, decrypt); err != nil {
+			t.Fatalf("workers %d: %v", workers, err)
+		}
+		if out.Len() != 1000*pageSize {
+			t.Fatalf("workers %d: got %d bytes", workers, out.Len())
+		}
+		if !allZeros(out.Bytes()[3*pageSize : 4*pageSize]) {
+			t.Errorf("workers %d: zero page was decrypted", workers)
+		}
+		if want == nil {
+			want = out.Bytes()
+		} else if !bytes.Equal(out.Bytes(), want) {
+			t.Errorf("workers %d: output differs from sequential decryption", workers)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := DecryptPages(ctx, bytes.NewReader(src), "test.db", &bytes.Buffer{}, total, pageSize, decrypt); err == nil {
+		t.Error("canceled context not reported")
+	}
+}
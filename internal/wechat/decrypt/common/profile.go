@@ -0,0 +1,86 @@
+package common
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// CipherProfiles 预置的加密参数，可通过 --cipher-profile 指定名称使用
+var CipherProfiles = map[string]CipherInfo{
+	"sqlcipher3": {PageSize: 1024, KDFIter: 64000, HMAC: HMACSHA1, Cipher: CipherAES256CBC},
+	"sqlcipher4": {PageSize: 4096, KDFIter: 256000, HMAC: HMACSHA512, Cipher: CipherAES256CBC},
+}
+
+// CipherProfileNames 返回预置加密参数的名称
+func CipherProfileNames() []string {
+	names := make([]string, 0, len(CipherProfiles))
+	for name := range CipherProfiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ParseCipherProfile 解析加密参数，格式为以英文逗号分隔的预置名称与 key=value，后出现的覆盖先出现的，例如
+//
+//	sqlcipher4
+//	page_size=4096,kdf_iter=256000,hmac=sha512,cipher=aes-256-cbc
+//	sqlcipher4,kdf_iter=300000
+//
+// 未设置的参数使用解密器的默认值，s 为空时返回 nil
+func ParseCipherProfile(s string) (*CipherInfo, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, nil
+	}
+	info := &CipherInfo{}
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(part, "=")
+		if !ok {
+			profile, ok := CipherProfiles[strings.ToLower(part)]
+			if !ok {
+				return nil, fmt.Errorf("unknown cipher profile %q, available: %s", part, strings.Join(CipherProfileNames(), ", "))
+			}
+			info = info.Merge(&profile)
+			continue
+		}
+		key, value = strings.ToLower(strings.TrimSpace(key)), strings.TrimSpace(value)
+		switch key {
+		case "page_size":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 512 || n > 65536 || n&(n-1) != 0 {
+				return nil, fmt.Errorf("invalid page_size %q, must be a power of two between 512 and 65536", value)
+			}
+			info.PageSize = n
+		case "kdf_iter":
+			n, err := strconv.Atoi(value)
+			if err != nil || n <= 0 {
+				return nil, fmt.Errorf("invalid kdf_iter %q", value)
+			}
+			info.KDFIter = n
+		case "hmac":
+			name := strings.ToUpper(value)
+			if !strings.HasPrefix(name, "HMAC-") {
+				name = "HMAC-" + name
+			}
+			if _, _, err := HMACOf(name); err != nil {
+				return nil, err
+			}
+			info.HMAC = name
+		case "cipher":
+			name := strings.ToUpper(value)
+			if err := CheckCipher(name); err != nil {
+				return nil, err
+			}
+			info.Cipher = name
+		default:
+			return nil, fmt.Errorf("unknown cipher parameter %q", key)
+		}
+	}
+	return info, nil
+}
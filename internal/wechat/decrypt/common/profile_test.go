@@ -0,0 +1,31 @@
+package common
+
+import "testing"
+
+func TestParseCipherProfile(t *testing.T) {
+	info, err := ParseCipherProfile("sqlcipher4, kdf_iter=300000, hmac=sha256")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := CipherInfo{PageSize: 4096, KDFIter: 300000, HMAC: HMACSHA256, Cipher: CipherAES256CBC}
+	if *info != want {
+		t.Errorf("info = %+v", info)
+	}
+
+	info, err = ParseCipherProfile("page_size=8192")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *info != (CipherInfo{PageSize: 8192}) {
+		t.Errorf("info = %+v", info)
+	}
+
+	if info, err := ParseCipherProfile(""); info != nil || err != nil {
+		t.Errorf("empty profile = %v, %v", info, err)
+	}
+	for _, s := range []string{"sqlcipher9", "page_size=1000", "kdf_iter=-1", "hmac=md5", "cipher=aes-128-cbc", "salt=1"} {
+		if _, err := ParseCipherProfile(s); err == nil {
+			t.Errorf("%s: expected error", s)
+		}
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"io"
 
 	"github.com/aspnmy/chatlog/internal/errors"
+	"github.com/aspnmy/chatlog/internal/wechat/decrypt/common"
 	"github.com/aspnmy/chatlog/internal/wechat/decrypt/darwin"
 	"github.com/aspnmy/chatlog/internal/wechat/decrypt/windows"
 )
@@ -28,10 +29,43 @@ type Decryptor interface {
 
 	// GetVersion 返回解密器版本
 	GetVersion() string
+
+	// Inspect 验证密钥，并返回验证通过时使用的加密参数
+	Inspect(page1 []byte, key []byte) (*common.CipherInfo, bool)
+
+	// SetCipher 使用验证密钥时记录的加密参数
+	SetCipher(info *common.CipherInfo) error
+
+	// PageFunc 派生密钥并返回解密各页面的函数，salt 为数据库第一页开头的盐值，
+	// 调用前应先通过 Validate 验证密钥
+	PageFunc(key []byte, salt []byte) common.PageFunc
 }
 
-// NewDecryptor 创建一个新的解密器
+// DefaultCipher 覆盖各解密器默认的加密参数，微信升级 SQLCipher 后无需等待新版本即可解密，见 common.ParseCipherProfile
+// 在创建解密器前设置，未设置的参数使用解密器的默认值
+var DefaultCipher *common.CipherInfo
+
+// NewDecryptor 创建一个新的解密器，使用 DefaultCipher 中的加密参数
 func NewDecryptor(platform string, version int) (Decryptor, error) {
+	return NewDecryptorWithCipher(platform, version, nil)
+}
+
+// NewDecryptorWithCipher 创建解密器并使用验证密钥时记录的加密参数，info 为空时使用默认参数
+// DefaultCipher 中设置了的参数优先于 info
+func NewDecryptorWithCipher(platform string, version int, info *common.CipherInfo) (Decryptor, error) {
+	d, err := newDecryptor(platform, version)
+	if err != nil {
+		return nil, err
+	}
+	if info != nil || DefaultCipher != nil {
+		if err := d.SetCipher(info.Merge(DefaultCipher)); err != nil {
+			return nil, err
+		}
+	}
+	return d, nil
+}
+
+func newDecryptor(platform string, version int) (Decryptor, error) {
 	// 根据平台返回对应的实现
 	switch {
 	case platform == "windows" && version == 3:
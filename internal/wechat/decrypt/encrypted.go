@@ -0,0 +1,166 @@
+package decrypt
+
+import (
+	"container/list"
+	"encoding/hex"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/aspnmy/chatlog/internal/errors"
+	"github.com/aspnmy/chatlog/internal/wechat/decrypt/common"
+)
+
+// DefaultPageCache EncryptedFile 默认缓存的已解密页面数
+const DefaultPageCache = 256
+
+// EncryptedFile 按需解密的加密数据库文件
+//
+// 读取到的内容与 Decrypt 的输出相同（包括 SQLite 头），但只在读取时解密涉及的页面，
+// 最近读取的页面保存在内存中，不需要在磁盘上生成完整的解密副本
+// 大小在打开时确定，之后追加的页面不可见，末尾不完整的页面与 Decrypt 一样丢弃
+type EncryptedFile struct {
+	f        *os.File
+	path     string
+	pageSize int
+	pages    int64
+	decrypt  common.PageFunc
+
+	mu    sync.Mutex
+	lru   *list.List // 最近读取的页面在前
+	cache map[int64]*list.Element
+	max   int
+}
+
+type cachedPage struct {
+	pgno int64
+	data []byte
+}
+
+// OpenEncrypted 使用 hexKey 打开 path 中的加密数据库，d 为对应平台与版本的解密器
+// 数据库未加密时返回 errors.ErrAlreadyDecrypted，密钥错误时返回 errors.ErrDecryptIncorrectKey
+func OpenEncrypted(path string, hexKey string, d Decryptor) (*EncryptedFile, error) {
+	key, err := hex.DecodeString(hexKey)
+	if err != nil {
+		return nil, errors.DecodeKeyFailed(err)
+	}
+	dbInfo, err := common.OpenDBFile(path, d.GetPageSize())
+	if err != nil {
+		return nil, err
+	}
+	if !d.Validate(dbInfo.FirstPage, key) {
+		return nil, errors.ErrDecryptIncorrectKey
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, errors.OpenFileFailed(path, err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, errors.StatFileFailed(path, err)
+	}
+	return &EncryptedFile{
+		f:        f,
+		path:     path,
+		pageSize: d.GetPageSize(),
+		pages:    info.Size() / int64(d.GetPageSize()),
+		decrypt:  d.PageFunc(key, dbInfo.Salt),
+		lru:      list.New(),
+		cache:    make(map[int64]*list.Element),
+		max:      DefaultPageCache,
+	}, nil
+}
+
+// SetCacheSize 设置缓存的页面数，至少缓存 1 页
+func (e *EncryptedFile) SetCacheSize(pages int) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.max = max(pages, 1)
+	e.evict()
+}
+
+// Size 返回解密后的大小
+func (e *EncryptedFile) Size() int64 {
+	return e.pages * int64(e.pageSize)
+}
+
+// PageSize 返回页面大小
+func (e *EncryptedFile) PageSize() int {
+	return e.pageSize
+}
+
+// ReadAt 读取解密后从 off 开始的内容，实现 io.ReaderAt，可并发调用
+func (e *EncryptedFile) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, errors.ReadFileFailed(e.path, os.ErrInvalid)
+	}
+	n := 0
+	for n < len(p) {
+		if off >= e.Size() {
+			return n, io.EOF
+		}
+		pgno := off / int64(e.pageSize)
+		page, err := e.page(pgno)
+		if err != nil {
+			return n, err
+		}
+		c := copy(p[n:], page[off-pgno*int64(e.pageSize):])
+		n += c
+		off += int64(c)
+	}
+	return n, nil
+}
+
+// Close 关闭数据库文件
+func (e *EncryptedFile) Close() error {
+	return e.f.Close()
+}
+
+// page 返回第 pgno 页（从 0 开始）解密后的内容，第一页包含 SQLite 头
+func (e *EncryptedFile) page(pgno int64) ([]byte, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if elem, ok := e.cache[pgno]; ok {
+		e.lru.MoveToFront(elem)
+		return elem.Value.(*cachedPage).data, nil
+	}
+
+	buf := make([]byte, e.pageSize)
+	if _, err := e.f.ReadAt(buf, pgno*int64(e.pageSize)); err != nil {
+		return nil, errors.ReadFileFailed(e.path, err)
+	}
+	data := buf
+	if !zeroPage(buf) {
+		var err error
+		if data, err = e.decrypt(buf, pgno); err != nil {
+			return nil, err
+		}
+		if pgno == 0 {
+			data = append([]byte(common.SQLiteHeader), data...)
+		}
+	}
+
+	e.cache[pgno] = e.lru.PushFront(&cachedPage{pgno: pgno, data: data})
+	e.evict()
+	return data, nil
+}
+
+func (e *EncryptedFile) evict() {
+	for e.lru.Len() > e.max {
+		elem := e.lru.Back()
+		e.lru.Remove(elem)
+		delete(e.cache, elem.Value.(*cachedPage).pgno)
+	}
+}
+
+// zeroPage 与 Decrypt 一致，全零页面原样输出
+func zeroPage(b []byte) bool {
+	for _, c := range b {
+		if c != 0 {
+			return false
+		}
+	}
+	return true
+}
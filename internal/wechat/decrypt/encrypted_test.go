@@ -0,0 +1,96 @@
+package decrypt
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aspnmy/chatlog/internal/wechat/decrypt/common"
+)
+
+// xorDecryptor 以异或代替 SQLCipher，用于测试按需解密的页面映射
+type xorDecryptor struct {
+	Decryptor
+	pageSize int
+}
+
+func (x *xorDecryptor) GetPageSize() int                       { return x.pageSize }
+func (x *xorDecryptor) Validate(page1 []byte, key []byte) bool { return true }
+
+func (x *xorDecryptor) PageFunc(key []byte, salt []byte) common.PageFunc {
+	return func(page []byte, pgno int64) ([]byte, error) {
+		offset := 0
+		if pgno == 0 {
+			offset = common.SaltSize
+		}
+		out := make([]byte, len(page)-offset)
+		for i, b := range page[offset:] {
+			out[i] = b ^ byte(pgno) ^ 0x5a
+		}
+		return out, nil
+	}
+}
+
+func TestOpenEncrypted(t *testing.T) {
+	const pageSize = 64
+	d := &xorDecryptor{pageSize: pageSize}
+
+	// 20 个页面，第 5 页全零，末尾多出半页
+	var src []byte
+	for i := range 20 {
+		page := bytes.Repeat([]byte{byte(i*7 + 1)}, pageSize)
+		if i == 5 {
+			page = make([]byte, pageSize)
+		}
+		src = append(src, page...)
+	}
+	src = append(src, bytes.Repeat([]byte{9}, pageSize/2)...)
+	path := filepath.Join(t.TempDir(), "message_0.db")
+	if err := os.WriteFile(path, src, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var want bytes.Buffer
+	want.WriteString(common.SQLiteHeader)
+	if err := common.DecryptPages(context.Background(), bytes.NewReader(src), path, &want, int64(len(src)/pageSize+1), pageSize, d.PageFunc(nil, nil)); err != nil {
+		t.Fatal(err)
+	}
+
+	e, err := OpenEncrypted(path, strings.Repeat("ab", common.KeySize), d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer e.Close()
+	e.SetCacheSize(3)
+
+	if e.Size() != int64(want.Len()) {
+		t.Fatalf("size = %d, want %d", e.Size(), want.Len())
+	}
+	got, err := io.ReadAll(io.NewSectionReader(e, 0, e.Size()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want.Bytes()) {
+		t.Error("content differs from full decryption")
+	}
+
+	// 跨页读取与读到末尾
+	buf := make([]byte, pageSize+10)
+	if n, err := e.ReadAt(buf, 3*pageSize-5); err != nil || !bytes.Equal(buf[:n], want.Bytes()[3*pageSize-5:4*pageSize+5]) {
+		t.Errorf("cross page read: n = %d, err = %v", n, err)
+	}
+	if n, err := e.ReadAt(buf, e.Size()-4); n != 4 || err != io.EOF {
+		t.Errorf("read at end: n = %d, err = %v", n, err)
+	}
+	if e.lru.Len() > 3 {
+		t.Errorf("cache holds %d pages", e.lru.Len())
+	}
+
+	if _, err := OpenEncrypted(path, "zz", d); err == nil {
+		t.Error("invalid key accepted")
+	}
+}
@@ -2,7 +2,9 @@ package decrypt
 
 import (
 	"path/filepath"
+	"sync/atomic"
 
+	"github.com/aspnmy/chatlog/internal/errors"
 	"github.com/aspnmy/chatlog/internal/wechat/decrypt/common"
 	"github.com/aspnmy/chatlog/pkg/util/dat2img"
 )
@@ -14,6 +16,8 @@ type Validator struct {
 	decryptor       Decryptor
 	dbFile          *common.DBFile
 	imgKeyValidator *dat2img.AesKeyValidator
+
+	attempts atomic.Int64 // Validate 验证过的密钥数
 }
 
 // NewValidator 创建一个仅用于验证的验证器
@@ -22,13 +26,17 @@ func NewValidator(platform string, version int, dataDir string) (*Validator, err
 }
 
 func NewValidatorWithFile(platform string, version int, dataDir string) (*Validator, error) {
-	dbFile := GetSimpleDBFile(platform, version)
-	dbPath := filepath.Join(dataDir, dbFile)
-	decryptor, err := NewDecryptor(platform, version)
+	return NewValidatorWithCipher(platform, version, dataDir, nil)
+}
+
+// NewValidatorWithCipher 创建使用指定加密参数（页面大小、KDF 迭代次数、HMAC 算法与加密算法）的验证器，
+// info 中未设置的参数使用解密器的默认值，info 为空时与 NewValidator 相同
+func NewValidatorWithCipher(platform string, version int, dataDir string, info *common.CipherInfo) (*Validator, error) {
+	decryptor, err := NewDecryptorWithCipher(platform, version, info)
 	if err != nil {
 		return nil, err
 	}
-	d, err := common.OpenDBFile(dbPath, decryptor.GetPageSize())
+	dbPath, d, err := openCandidateDB(platform, version, dataDir, decryptor.GetPageSize())
 	if err != nil {
 		return nil, err
 	}
@@ -48,10 +56,101 @@ func NewValidatorWithFile(platform string, version int, dataDir string) (*Valida
 	return validator, nil
 }
 
+// NewValidatorFromHeader 使用数据库文件的第一页创建验证器，无需访问数据目录，
+// 用于数据目录在另一台机器上、仅传回文件头的场景；该验证器不支持验证图片密钥
+func NewValidatorFromHeader(platform string, version int, header []byte) (*Validator, error) {
+	decryptor, err := NewDecryptor(platform, version)
+	if err != nil {
+		return nil, err
+	}
+	d, err := common.NewDBFileFromHeader(header, decryptor.GetPageSize())
+	if err != nil {
+		return nil, err
+	}
+	return &Validator{
+		platform:  platform,
+		version:   version,
+		decryptor: decryptor,
+		dbFile:    d,
+	}, nil
+}
+
+// NewValidatorFromDB 使用指定的加密数据库文件创建验证器，用于只有内存转储与数据库副本、没有完整数据目录的场景；
+// 该验证器不支持验证图片密钥
+func NewValidatorFromDB(platform string, version int, dbPath string) (*Validator, error) {
+	decryptor, err := NewDecryptor(platform, version)
+	if err != nil {
+		return nil, err
+	}
+	d, err := common.OpenDBFile(dbPath, decryptor.GetPageSize())
+	if err != nil {
+		return nil, err
+	}
+	return &Validator{
+		platform:  platform,
+		version:   version,
+		dbPath:    dbPath,
+		decryptor: decryptor,
+		dbFile:    d,
+	}, nil
+}
+
+// ReadHeader 读取用于验证密钥的数据库文件的第一页，供 NewValidatorFromHeader 使用
+func ReadHeader(platform string, version int, dataDir string) ([]byte, error) {
+	decryptor, err := NewDecryptor(platform, version)
+	if err != nil {
+		return nil, err
+	}
+	_, d, err := openCandidateDB(platform, version, dataDir, decryptor.GetPageSize())
+	if err != nil {
+		return nil, err
+	}
+	return d.FirstPage, nil
+}
+
+// openCandidateDB 按 GetCandidateDBFiles 的顺序打开数据目录中第一个可读取的加密数据库，
+// 都无法使用时返回第一个数据库的错误
+func openCandidateDB(platform string, version int, dataDir string, pageSize int) (string, *common.DBFile, error) {
+	var firstErr error
+	for _, file := range GetCandidateDBFiles(platform, version) {
+		dbPath := filepath.Join(dataDir, file)
+		d, err := common.OpenDBFile(dbPath, pageSize)
+		if err == nil {
+			return dbPath, d, nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	if firstErr == nil {
+		firstErr = errors.PlatformUnsupported(platform, version)
+	}
+	return "", nil, firstErr
+}
+
 func (v *Validator) Validate(key []byte) bool {
+	v.attempts.Add(1)
 	return v.decryptor.Validate(v.dbFile.FirstPage, key)
 }
 
+// Attempts 返回 Validate 验证过的密钥数，用于比较搜索策略测试的候选密钥数量
+func (v *Validator) Attempts() int64 {
+	return v.attempts.Load()
+}
+
+// ValidateWithInfo 验证密钥，成功时返回验证使用的数据库文件与加密参数
+func (v *Validator) ValidateWithInfo(key []byte) (*common.CipherInfo, bool) {
+	info, ok := v.decryptor.Inspect(v.dbFile.FirstPage, key)
+	if !ok {
+		return nil, false
+	}
+	info.DBFile = v.dbPath
+	if info.DBFile == "" {
+		info.DBFile = GetSimpleDBFile(v.platform, v.version)
+	}
+	return info, true
+}
+
 func (v *Validator) ValidateImgKey(key []byte) bool {
 	if v.imgKeyValidator == nil {
 		return false
@@ -59,6 +158,36 @@ func (v *Validator) ValidateImgKey(key []byte) bool {
 	return v.imgKeyValidator.Validate(key)
 }
 
+// GetCandidateDBFiles 返回可用于验证密钥的加密数据库，按优先顺序排列，第一个与 GetSimpleDBFile 相同
+// 新登录的账号可能还没有消息数据库，此时使用联系人、会话等其他数据库
+func GetCandidateDBFiles(platform string, version int) []string {
+	switch {
+	case platform == "windows" && version == 3:
+		return []string{"Msg\\Misc.db", "Msg\\MicroMsg.db", "Msg\\Multi\\MSG0.db", "Msg\\Multi\\MediaMSG0.db"}
+	case platform == "windows" && version == 4:
+		return []string{
+			"db_storage\\message\\message_0.db",
+			"db_storage\\contact\\contact.db",
+			"db_storage\\session\\session.db",
+			"db_storage\\hardlink\\hardlink.db",
+			"db_storage\\message\\media_0.db",
+			"db_storage\\head_image\\head_image.db",
+		}
+	case platform == "darwin" && version == 3:
+		return []string{"Message/msg_0.db", "Contact/wccontact_new2.db", "Session/session_new.db"}
+	case platform == "darwin" && version == 4:
+		return []string{
+			"db_storage/message/message_0.db",
+			"db_storage/contact/contact.db",
+			"db_storage/session/session.db",
+			"db_storage/hardlink/hardlink.db",
+			"db_storage/message/media_0.db",
+			"db_storage/head_image/head_image.db",
+		}
+	}
+	return nil
+}
+
 func GetSimpleDBFile(platform string, version int) string {
 	switch {
 	case platform == "windows" && version == 3:
@@ -0,0 +1,46 @@
+package decrypt
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestNewValidatorFallback(t *testing.T) {
+	dir := t.TempDir()
+	write := func(file string, data []byte) {
+		path := filepath.Join(dir, file)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 新账号没有 message_0.db，contact.db 不完整，应使用 session.db
+	if _, err := NewValidator("darwin", 4, dir); err == nil {
+		t.Fatal("empty data dir accepted")
+	}
+	write("db_storage/contact/contact.db", make([]byte, 100))
+	write("db_storage/session/session.db", bytes.Repeat([]byte{0x5a}, 4096))
+
+	v, err := NewValidator("darwin", 4, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := filepath.Join(dir, "db_storage/session/session.db"); v.dbPath != want {
+		t.Errorf("dbPath = %s, want %s", v.dbPath, want)
+	}
+	header, err := ReadHeader("darwin", 4, dir)
+	if err != nil || len(header) != 4096 {
+		t.Errorf("ReadHeader = %d bytes, %v", len(header), err)
+	}
+
+	// 优先使用 message_0.db
+	write("db_storage/message/message_0.db", bytes.Repeat([]byte{0x3c}, 4096))
+	if v, err := NewValidator("darwin", 4, dir); err != nil || filepath.Base(v.dbPath) != "message_0.db" {
+		t.Errorf("NewValidator = %v, %v", v, err)
+	}
+}
@@ -92,9 +92,6 @@ func (d *V3Decryptor) Decrypt(ctx context.Context, dbfile string, hexKey string,
 		return errors.ErrDecryptIncorrectKey
 	}
 
-	// 计算密钥
-	encKey, macKey := d.deriveKeys(key, dbInfo.Salt)
-
 	// 打开数据库文件
 	dbFile, err := os.Open(dbfile)
 	if err != nil {
@@ -109,61 +106,15 @@ func (d *V3Decryptor) Decrypt(ctx context.Context, dbfile string, hexKey string,
 	}
 
 	// 处理每一页
-	pageBuf := make([]byte, d.pageSize)
-
-	for curPage := int64(0); curPage < dbInfo.TotalPages; curPage++ {
-		// 检查是否取消
-		select {
-		case <-ctx.Done():
-			return errors.ErrDecryptOperationCanceled
-		default:
-			// 继续处理
-		}
-
-		// 读取一页
-		n, err := io.ReadFull(dbFile, pageBuf)
-		if err != nil {
-			if err == io.EOF || err == io.ErrUnexpectedEOF {
-				// 处理最后一部分页面
-				if n > 0 {
-					break
-				}
-			}
-			return errors.ReadFileFailed(dbfile, err)
-		}
-
-		// 检查页面是否全为零
-		allZeros := true
-		for _, b := range pageBuf {
-			if b != 0 {
-				allZeros = false
-				break
-			}
-		}
-
-		if allZeros {
-			// 写入零页面
-			_, err = output.Write(pageBuf)
-			if err != nil {
-				return errors.WriteOutputFailed(err)
-			}
-			continue
-		}
-
-		// 解密页面
-		decryptedData, err := common.DecryptPage(pageBuf, encKey, macKey, curPage, d.hashFunc, d.hmacSize, d.reserve, d.pageSize)
-		if err != nil {
-			return err
-		}
+	return common.DecryptPages(ctx, dbFile, dbfile, output, dbInfo.TotalPages, d.pageSize, d.PageFunc(key, dbInfo.Salt))
+}
 
-		// 写入解密后的页面
-		_, err = output.Write(decryptedData)
-		if err != nil {
-			return errors.WriteOutputFailed(err)
-		}
+// PageFunc 派生密钥并返回解密各页面的函数，salt 为数据库第一页开头的盐值
+func (d *V3Decryptor) PageFunc(key []byte, salt []byte) common.PageFunc {
+	encKey, macKey := d.deriveKeys(key, salt)
+	return func(page []byte, pgno int64) ([]byte, error) {
+		return common.DecryptPage(page, encKey, macKey, pgno, d.hashFunc, d.hmacSize, d.reserve, d.pageSize)
 	}
-
-	return nil
 }
 
 // GetPageSize 返回页面大小
@@ -190,3 +141,43 @@ func (d *V3Decryptor) GetVersion() string {
 func (d *V3Decryptor) GetIterCount() int {
 	return d.iterCount
 }
+
+// Inspect 验证密钥，并返回验证通过时使用的加密参数
+func (d *V3Decryptor) Inspect(page1 []byte, key []byte) (*common.CipherInfo, bool) {
+	if !d.Validate(page1, key) {
+		return nil, false
+	}
+	encKey, macKey := d.deriveKeys(key, page1[:common.SaltSize])
+	page, err := common.DecryptPage(page1, encKey, macKey, 0, d.hashFunc, d.hmacSize, d.reserve, d.pageSize)
+	if err != nil {
+		return nil, false
+	}
+	return &common.CipherInfo{
+		PageSize: common.PageSizeOf(page),
+		KDFIter:  d.iterCount,
+		HMAC:     common.HMACName(d.hmacSize),
+	}, true
+}
+
+// SetCipher 使用验证密钥时记录的加密参数，未设置的参数保持不变
+func (d *V3Decryptor) SetCipher(info *common.CipherInfo) error {
+	if err := common.CheckCipher(info.Cipher); err != nil {
+		return err
+	}
+	if info.PageSize > 0 {
+		d.pageSize = info.PageSize
+	}
+	if info.KDFIter > 0 {
+		d.iterCount = info.KDFIter
+	}
+	if info.HMAC != "" {
+		hashFunc, hmacSize, err := common.HMACOf(info.HMAC)
+		if err != nil {
+			return err
+		}
+		d.hashFunc = hashFunc
+		d.hmacSize = hmacSize
+		d.reserve = common.Reserve(hmacSize)
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package windows
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"encoding/binary"
+	"testing"
+
+	"github.com/aspnmy/chatlog/internal/wechat/decrypt/common"
+)
+
+// encryptPage1 按 SQLCipher 的格式加密第一页，页面大小写入 SQLite 头
+func encryptPage1(d *V4Decryptor, key []byte, pageSize int) []byte {
+	salt := bytes.Repeat([]byte{0x11}, common.SaltSize)
+	plain := make([]byte, d.pageSize-d.reserve)
+	binary.BigEndian.PutUint16(plain[common.SaltSize:], uint16(pageSize))
+
+	encKey, macKey := d.deriveKeys(key, salt)
+	iv := bytes.Repeat([]byte{0x22}, common.IVSize)
+	block, _ := aes.NewCipher(encKey)
+	enc := make([]byte, len(plain)-common.SaltSize)
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(enc, plain[common.SaltSize:])
+
+	page := append(append([]byte{}, salt...), enc...)
+	page = append(page, iv...)
+	mac := hmac.New(d.hashFunc, macKey)
+	mac.Write(page[common.SaltSize:])
+	mac.Write([]byte{1, 0, 0, 0})
+	page = append(page, mac.Sum(nil)...)
+	return append(page, make([]byte, d.pageSize-len(page))...)
+}
+
+func TestV4Inspect(t *testing.T) {
+	d := NewV4Decryptor()
+	d.iterCount = 2 // 加快测试
+	key := bytes.Repeat([]byte{0xab}, common.KeySize)
+	page1 := encryptPage1(d, key, PageSize)
+
+	info, ok := d.Inspect(page1, key)
+	if !ok {
+		t.Fatal("valid key rejected")
+	}
+	if info.PageSize != PageSize || info.KDFIter != 2 || info.HMAC != common.HMACSHA512 {
+		t.Errorf("unexpected cipher info: %+v", info)
+	}
+
+	if _, ok := d.Inspect(page1, bytes.Repeat([]byte{0xcd}, common.KeySize)); ok {
+		t.Error("invalid key accepted")
+	}
+
+	// 使用记录的参数创建的解密器应能验证同一密钥
+	d2 := NewV4Decryptor()
+	if err := d2.SetCipher(info); err != nil {
+		t.Fatal(err)
+	}
+	if !d2.Validate(page1, key) {
+		t.Error("decryptor with recorded cipher rejected the key")
+	}
+}
+
+func TestV4CustomCipher(t *testing.T) {
+	// 模拟微信升级为 HMAC-SHA256 与 8K 页面后的数据库
+	d := NewV4Decryptor()
+	if err := d.SetCipher(&common.CipherInfo{PageSize: 8192, KDFIter: 2, HMAC: common.HMACSHA256}); err != nil {
+		t.Fatal(err)
+	}
+	key := bytes.Repeat([]byte{0xab}, common.KeySize)
+	page1 := encryptPage1(d, key, 8192)
+
+	if NewV4Decryptor().Validate(page1, key) {
+		t.Error("default decryptor accepted a page encrypted with other parameters")
+	}
+	info, ok := d.Inspect(page1, key)
+	if !ok {
+		t.Fatal("valid key rejected")
+	}
+	if info.PageSize != 8192 || info.HMAC != common.HMACSHA256 || d.GetReserve() != 48 {
+		t.Errorf("unexpected cipher info: %+v, reserve %d", info, d.GetReserve())
+	}
+	if err := d.SetCipher(&common.CipherInfo{Cipher: "AES-128-CBC"}); err == nil {
+		t.Error("unsupported cipher accepted")
+	}
+}
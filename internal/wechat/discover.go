@@ -0,0 +1,80 @@
+package wechat
+
+import (
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"sort"
+
+	"github.com/aspnmy/chatlog/internal/wechat/process"
+)
+
+// DataRoots 返回 3.x 与 4.x 数据目录的上级目录，包括默认位置，
+// 以及在微信设置中修改过的文件保存位置（Windows 上从注册表与微信的配置文件读取）
+func DataRoots() []string {
+	roots := DefaultDataRoots()
+	for _, r := range customDataRoots() {
+		if !slices.Contains(roots, r) {
+			roots = append(roots, r)
+		}
+	}
+	return roots
+}
+
+// DiscoverDataDirs 查找本机的账号数据目录：扫描 DataRoots，
+// 并合并正在运行的微信打开的数据目录（来自进程打开的数据库文件），结果按账号、版本排序
+func DiscoverDataDirs() []DataDir {
+	dirs := LocateDataDirs(DataRoots())
+
+	processes, _ := process.NewDetector(runtime.GOOS).FindProcesses()
+	for _, p := range processes {
+		if p.DataDir == "" {
+			continue
+		}
+		i := slices.IndexFunc(dirs, func(d DataDir) bool { return filepath.Clean(d.Dir) == filepath.Clean(p.DataDir) })
+		if i >= 0 {
+			dirs[i].Running = true
+			continue
+		}
+		name := filepath.Base(p.DataDir)
+		dirs = append(dirs, DataDir{
+			Account:  NormalizeAccount(name, p.Version),
+			Name:     name,
+			Dir:      p.DataDir,
+			Platform: p.Platform,
+			Version:  p.Version,
+			Running:  true,
+		})
+	}
+
+	sort.SliceStable(dirs, func(i, j int) bool {
+		if dirs[i].Account != dirs[j].Account {
+			return dirs[i].Account < dirs[j].Account
+		}
+		return dirs[i].Version < dirs[j].Version
+	})
+	return dirs
+}
+
+// DefaultDataDir 返回最可能需要处理的数据目录，version 为 0 时不限版本
+// 正在运行的微信使用的目录优先，其次为数据库最近修改的目录
+func DefaultDataDir(version int) (*DataDir, error) {
+	var best *DataDir
+	dirs := DiscoverDataDirs()
+	for i, d := range dirs {
+		if version != 0 && d.Version != version {
+			continue
+		}
+		if best == nil || (d.Running && !best.Running) || (d.Running == best.Running && d.Modified.After(best.Modified)) {
+			best = &dirs[i]
+		}
+	}
+	if best == nil {
+		if version != 0 {
+			return nil, fmt.Errorf("no wechat %d.x data dir found, please specify it", version)
+		}
+		return nil, fmt.Errorf("no wechat data dir found, please specify it")
+	}
+	return best, nil
+}
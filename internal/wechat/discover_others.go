@@ -0,0 +1,8 @@
+//go:build !windows
+
+package wechat
+
+// customDataRoots 非 Windows 平台的微信不支持修改文件保存位置
+func customDataRoots() []string {
+	return nil
+}
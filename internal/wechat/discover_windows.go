@@ -0,0 +1,138 @@
+package wechat
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode/utf16"
+
+	"golang.org/x/sys/windows/registry"
+
+	pwindows "github.com/aspnmy/chatlog/internal/wechat/process/windows"
+)
+
+// defaultSavePath 为文件保存位置未修改时的值，数据位于“文档”目录下
+const defaultSavePath = "MyDocument:"
+
+// customDataRoots 返回在微信设置中修改过的文件保存位置
+//   - 3.x 记录在注册表 HKCU\Software\Tencent\WeChat 的 FileSavePath 中，
+//     同时写入 %APPDATA%\Tencent\WeChat\All Users\config\*.ini，数据位于其下的 WeChat Files
+//   - 4.x 记录在 %APPDATA%\Tencent\xwechat\config\*.ini 中，数据位于其下的 xwechat_files
+//
+// 同时返回 Sandboxie 沙盒中与默认位置、修改过的位置对应的目录，见 sandboxDataRoots
+func customDataRoots() []string {
+	roots := savedDataRoots()
+	for _, r := range sandboxDataRoots(append(DefaultDataRoots(), roots...)) {
+		if !slices.Contains(roots, r) {
+			roots = append(roots, r)
+		}
+	}
+	return roots
+}
+
+// savedDataRoots 返回在微信设置中修改过的文件保存位置
+func savedDataRoots() []string {
+	var roots []string
+	add := func(path, sub string) {
+		if path == "" || path == defaultSavePath {
+			return
+		}
+		dir := filepath.Join(path, sub)
+		if fi, err := os.Stat(dir); err == nil && fi.IsDir() {
+			roots = append(roots, dir)
+		}
+	}
+
+	if k, err := registry.OpenKey(registry.CURRENT_USER, `Software\Tencent\WeChat`, registry.QUERY_VALUE); err == nil {
+		if v, _, err := k.GetStringValue("FileSavePath"); err == nil {
+			add(v, "WeChat Files")
+		}
+		k.Close()
+	}
+
+	appData := os.Getenv("APPDATA")
+	if appData == "" {
+		return roots
+	}
+	for _, c := range []struct{ glob, sub string }{
+		{filepath.Join(appData, "Tencent", "WeChat", "All Users", "config", "*.ini"), "WeChat Files"},
+		{filepath.Join(appData, "Tencent", "xwechat", "config", "*.ini"), "xwechat_files"},
+	} {
+		files, _ := filepath.Glob(c.glob)
+		for _, f := range files {
+			b, err := os.ReadFile(f)
+			if err != nil {
+				continue
+			}
+			b = bytes.TrimPrefix(b, []byte("\xef\xbb\xbf"))
+			add(string(bytes.TrimSpace(b)), c.sub)
+		}
+	}
+	return roots
+}
+
+// sandboxDataRoots 返回 Sandboxie 各个沙盒中与 roots 对应的目录，在沙盒中运行的微信写入的文件重定向到这些目录
+func sandboxDataRoots(roots []string) []string {
+	home, _ := os.UserHomeDir()
+	var dirs []string
+	for _, box := range sandboxBoxes() {
+		for _, r := range roots {
+			dir := pwindows.SandboxedPath(box, r, home)
+			if dir == "" {
+				continue
+			}
+			if fi, err := os.Stat(dir); err == nil && fi.IsDir() {
+				dirs = append(dirs, dir)
+			}
+		}
+	}
+	return dirs
+}
+
+// sandboxBoxes 返回 Sandboxie 沙盒的根目录，包括默认位置 %SystemDrive%\Sandbox\<用户名>\<沙盒名>
+// 与 Sandboxie.ini 中 FileRootPath 指定的位置
+func sandboxBoxes() []string {
+	patterns := []string{filepath.Join(os.Getenv("SystemDrive")+`\`, "Sandbox", os.Getenv("USERNAME"), "*")}
+	if b, err := os.ReadFile(filepath.Join(os.Getenv("WINDIR"), "Sandboxie.ini")); err == nil {
+		for _, line := range strings.Split(decodeINI(b), "\n") {
+			name, value, ok := strings.Cut(strings.TrimSpace(line), "=")
+			if !ok || !strings.EqualFold(name, "FileRootPath") {
+				continue
+			}
+			value = strings.NewReplacer("%SANDBOX%", "*", "%USER%", os.Getenv("USERNAME")).Replace(value)
+			patterns = append(patterns, envVar.ReplaceAllStringFunc(value, func(v string) string {
+				return os.Getenv(v[1 : len(v)-1])
+			}))
+		}
+	}
+
+	var boxes []string
+	for _, p := range patterns {
+		matches, _ := filepath.Glob(p)
+		for _, m := range matches {
+			if fi, err := os.Stat(m); err == nil && fi.IsDir() && !slices.Contains(boxes, m) {
+				boxes = append(boxes, m)
+			}
+		}
+	}
+	return boxes
+}
+
+// envVar Windows 环境变量引用，如 %SystemDrive%
+var envVar = regexp.MustCompile(`%[^%\\]+%`)
+
+// decodeINI Sandboxie.ini 为带 BOM 的 UTF-16 LE 编码
+func decodeINI(b []byte) string {
+	if !bytes.HasPrefix(b, []byte{0xff, 0xfe}) {
+		return string(bytes.TrimPrefix(b, []byte("\xef\xbb\xbf")))
+	}
+	b = b[2:]
+	u := make([]uint16, len(b)/2)
+	for i := range u {
+		u[i] = uint16(b[2*i]) | uint16(b[2*i+1])<<8
+	}
+	return string(utf16.Decode(u))
+}
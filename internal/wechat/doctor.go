@@ -0,0 +1,164 @@
+package wechat
+
+import (
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/aspnmy/chatlog/internal/wechat/key/darwin/glance"
+	keywindows "github.com/aspnmy/chatlog/internal/wechat/key/windows"
+	"github.com/aspnmy/chatlog/internal/wechat/model"
+	"github.com/aspnmy/chatlog/internal/wechat/process"
+)
+
+// 诊断结果的级别
+const (
+	FindingOK   = "ok"
+	FindingWarn = "warn"
+	FindingFail = "fail"
+)
+
+// Finding 一项诊断结果，Detail 说明原因与解决方法
+type Finding struct {
+	Level  string `json:"level"`
+	Title  string `json:"title"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// Diagnose 检查提取密钥需要的条件：权限、微信进程、进程的数据目录与主模块，以及本机的数据目录，
+// 无法提取时说明原因，如微信运行在沙盒或虚拟机中
+func Diagnose() []Finding {
+	var findings []Finding
+	add := func(level, title, detail string, args ...interface{}) {
+		findings = append(findings, Finding{Level: level, Title: title, Detail: fmt.Sprintf(detail, args...)})
+	}
+
+	elevated, known := isElevated()
+	switch {
+	case !known:
+	case elevated:
+		add(FindingOK, "权限", "以管理员身份运行")
+	default:
+		add(FindingWarn, "权限", "未以管理员身份运行，可能无法读取微信进程的内存与打开的文件")
+	}
+
+	if runtime.GOOS == "darwin" {
+		if glance.IsSIPDisabled() {
+			add(FindingOK, "SIP", "系统完整性保护已关闭")
+		} else {
+			add(FindingFail, "SIP", "系统完整性保护（SIP）已开启，无法读取微信进程的内存：请在恢复模式中执行 csrutil disable 后重试")
+		}
+	}
+
+	processes, err := process.NewDetector(runtime.GOOS).FindProcesses()
+	if err != nil {
+		add(FindingFail, "微信进程", "获取进程列表失败: %v", err)
+	}
+	for _, p := range processes {
+		findings = append(findings, diagnoseProcess(p, elevated || !known)...)
+	}
+
+	dirs := DiscoverDataDirs()
+	for _, d := range dirs {
+		detail := fmt.Sprintf("账号 %s，%d.x", d.Account, d.Version)
+		if d.Running {
+			detail += "，正在运行的微信使用该目录"
+		}
+		level := FindingOK
+		if vm := sharedFolder(d.Dir); vm != "" && !d.Running {
+			level = FindingWarn
+			detail += fmt.Sprintf("。目录位于%s的共享位置，微信可能运行在虚拟机或另一台电脑中，"+
+				"密钥只能在运行微信的系统中提取：请在该系统中运行 chatlog key，再在本机使用 chatlog decrypt -k <key> -d %s", vm, d.Dir)
+		}
+		add(level, "数据目录 "+d.Dir, "%s", detail)
+	}
+
+	if len(processes) == 0 && err == nil {
+		detail := "请先启动并登录微信"
+		if len(dirs) > 0 {
+			detail = "找到了数据目录但微信没有运行：请启动并登录微信后再提取密钥，" +
+				"已保存过密钥的账号可以直接使用 chatlog decrypt；微信运行在虚拟机中时需要在虚拟机中提取密钥"
+		}
+		add(FindingFail, "微信进程", "%s", detail)
+	}
+	if len(dirs) == 0 {
+		add(FindingWarn, "数据目录", "没有在默认位置、微信设置的保存位置与 Sandboxie 沙盒中找到数据目录，请使用 -d 指定")
+	}
+	return findings
+}
+
+// diagnoseProcess 检查一个微信进程，canRead 为 false 表示没有读取其他进程的权限
+func diagnoseProcess(p *model.Process, canRead bool) []Finding {
+	title := fmt.Sprintf("微信进程 %d", p.PID)
+	findings := []Finding{{Level: FindingOK, Title: title, Detail: fmt.Sprintf("%s %s", p.FullVersion, p.ExePath)}}
+	add := func(level, detail string, args ...interface{}) {
+		findings = append(findings, Finding{Level: level, Title: title, Detail: fmt.Sprintf(detail, args...)})
+	}
+
+	if p.Sandbox != "" {
+		detail := fmt.Sprintf("运行在 Sandboxie 沙盒 %s 中", p.Sandbox)
+		if p.SandboxRoot != "" {
+			detail += "，沙盒目录 " + p.SandboxRoot
+		}
+		add(FindingWarn, "%s，数据目录按沙盒中重定向后的路径查找；提取密钥需要以管理员身份在沙盒外运行 chatlog", detail)
+	}
+
+	switch {
+	case p.DataDir != "":
+		add(FindingOK, "数据目录 %s，账号 %s", p.DataDir, p.AccountName)
+	case !canRead:
+		add(FindingFail, "无法读取进程打开的文件，找不到数据目录：请以管理员身份运行")
+	case p.Sandbox != "":
+		add(FindingFail, "没有找到进程打开的数据库：请在沙盒中登录微信后重试，或使用 -d 指定沙盒中的数据目录（通常位于 %s 下）", sandboxHint(p))
+	case p.Status != model.StatusOnline:
+		add(FindingFail, "微信尚未登录，登录后才能找到数据目录与密钥")
+	}
+
+	if p.Platform == model.PlatformWindows && runtime.GOOS == "windows" {
+		switch v := keywindows.ModuleVersion(p.PID); {
+		case v == 0:
+			add(FindingFail, "找不到微信的主模块 %s：无法读取进程的模块列表，请以管理员身份运行；"+
+				"32 位与 64 位不匹配或进程被安全软件、沙盒保护时也会出现", mainModule(p.Version))
+		case v != p.Version:
+			add(FindingWarn, "主模块为 %d.x，与可执行文件的版本 %d.x 不一致，提取密钥时按 %d.x 处理", v, p.Version, v)
+		}
+	}
+	return findings
+}
+
+func mainModule(version int) string {
+	if version == 4 {
+		return "Weixin.dll"
+	}
+	return "WeChatWin.dll"
+}
+
+// sandboxHint 返回沙盒中数据目录可能的位置
+func sandboxHint(p *model.Process) string {
+	if p.SandboxRoot != "" {
+		return filepath.Join(p.SandboxRoot, "user", "current", "Documents")
+	}
+	return `C:\Sandbox\<用户名>\<沙盒名>\user\current\Documents`
+}
+
+// sharedFolder 判断目录是否位于虚拟机共享文件夹或网络位置，返回来源的名称，本地目录返回空
+func sharedFolder(dir string) string {
+	lower := strings.ToLower(dir)
+	for prefix, name := range map[string]string{
+		`\\vmware-host\`: "VMware 共享文件夹",
+		`\\vboxsvr\`:     "VirtualBox 共享文件夹",
+		`\\vboxsrv\`:     "VirtualBox 共享文件夹",
+		`\\mac\`:         "Parallels 共享文件夹",
+		`\\psf\`:         "Parallels 共享文件夹",
+		`\\tsclient\`:    "远程桌面共享的驱动器",
+	} {
+		if strings.HasPrefix(lower, prefix) {
+			return name
+		}
+	}
+	if strings.HasPrefix(dir, `\\`) || isRemoteDrive(dir) {
+		return "网络"
+	}
+	return ""
+}
@@ -0,0 +1,14 @@
+//go:build !windows
+
+package wechat
+
+import "os"
+
+// isElevated 返回当前进程是否以 root 运行，macOS 上提取密钥需要 root
+func isElevated() (bool, bool) {
+	return os.Geteuid() == 0, true
+}
+
+func isRemoteDrive(dir string) bool {
+	return false
+}
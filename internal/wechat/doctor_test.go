@@ -0,0 +1,45 @@
+package wechat
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aspnmy/chatlog/internal/wechat/model"
+)
+
+func TestSharedFolder(t *testing.T) {
+	for dir, want := range map[string]string{
+		`\\vmware-host\Shared Folders\WeChat Files\wxid_a`: "VMware 共享文件夹",
+		`\\VBoxSvr\share\xwechat_files\wxid_a_1a2b`:        "VirtualBox 共享文件夹",
+		`\\nas\backup\WeChat Files\wxid_a`:                 "网络",
+		`C:\Users\alice\Documents\WeChat Files\wxid_a`:     "",
+	} {
+		if got := sharedFolder(dir); got != want {
+			t.Errorf("sharedFolder(%s) = %q, want %q", dir, got, want)
+		}
+	}
+}
+
+func TestDiagnoseSandboxedProcess(t *testing.T) {
+	p := &model.Process{
+		PID:         42,
+		Platform:    model.PlatformWindows,
+		Version:     4,
+		Status:      model.StatusOffline,
+		Sandbox:     "DefaultBox",
+		SandboxRoot: `C:\Sandbox\alice\DefaultBox`,
+	}
+	findings := diagnoseProcess(p, true)
+	var failed []string
+	for _, f := range findings {
+		if f.Level == FindingFail {
+			failed = append(failed, f.Detail)
+		}
+	}
+	if len(failed) == 0 || !strings.Contains(failed[0], "沙盒") {
+		t.Errorf("findings = %+v", findings)
+	}
+	if findings := diagnoseProcess(p, false); !strings.Contains(findings[len(findings)-1].Detail, "管理员") {
+		t.Errorf("findings without permission = %+v", findings)
+	}
+}
@@ -0,0 +1,25 @@
+package wechat
+
+import (
+	"path/filepath"
+
+	"golang.org/x/sys/windows"
+)
+
+// isElevated 返回当前进程是否以管理员身份运行
+func isElevated() (bool, bool) {
+	return windows.GetCurrentProcessToken().IsElevated(), true
+}
+
+// isRemoteDrive 判断路径所在的驱动器是否为映射的网络驱动器
+func isRemoteDrive(dir string) bool {
+	vol := filepath.VolumeName(dir)
+	if len(vol) != 2 || vol[1] != ':' {
+		return false
+	}
+	root, err := windows.UTF16PtrFromString(vol + `\`)
+	if err != nil {
+		return false
+	}
+	return windows.GetDriveType(root) == windows.DRIVE_REMOTE
+}
@@ -0,0 +1,107 @@
+package key
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/aspnmy/chatlog/internal/wechat/decrypt"
+	"github.com/aspnmy/chatlog/internal/wechat/model"
+	"github.com/aspnmy/chatlog/internal/wechat/process"
+)
+
+// Result 一个微信进程的密钥提取结果
+type Result struct {
+	Process  *model.Process
+	DataKey  string
+	ImgKey   string
+	Strategy string // 找到密钥的搜索策略，多个时以逗号分隔
+	Duration time.Duration
+	Err      error // 提取失败或中断的原因，中断时已找到的密钥仍会保留
+}
+
+// Account 返回结果对应的账号，进程未登录时使用数据目录名或 PID
+func (r *Result) Account() string {
+	return AccountOf(r.Process)
+}
+
+// AccountOf 返回进程的账号名，未识别账号时使用数据目录名，都没有时使用 PID
+func AccountOf(p *model.Process) string {
+	switch {
+	case p.AccountName != "":
+		return p.AccountName
+	case p.DataDir != "":
+		return filepath.Base(p.DataDir)
+	default:
+		return fmt.Sprintf("pid-%d", p.PID)
+	}
+}
+
+// ExtractAll 查找所有已登录的微信进程，依次提取密钥，返回以账号为键的结果
+// 每个进程使用自己的数据目录验证密钥，单个进程失败不影响其他进程，失败原因记录在 Result.Err 中
+// configure 不为 nil 时在提取前调用，用于设置扫描上限、进度等；ctx 结束时停止处理剩余的进程
+func ExtractAll(ctx context.Context, configure func(Extractor, *model.Process)) (map[string]*Result, error) {
+	processes, err := process.NewDetector(runtime.GOOS).FindProcesses()
+	if err != nil {
+		return nil, err
+	}
+
+	results := make(map[string]*Result)
+	for _, p := range processes {
+		if p.Status != model.StatusOnline || p.DataDir == "" {
+			log.Debug().Msgf("跳过未登录的微信进程 %d", p.PID)
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return results, err
+		}
+		r := extractProcess(ctx, p, configure)
+		results[r.Account()] = r
+	}
+	if len(results) == 0 {
+		return nil, fmt.Errorf("未找到已登录的微信进程")
+	}
+	return results, nil
+}
+
+// extractProcess 提取单个进程的密钥
+func extractProcess(ctx context.Context, p *model.Process, configure func(Extractor, *model.Process)) *Result {
+	r := &Result{Process: p}
+	begin := time.Now()
+	defer func() { r.Duration = time.Since(begin) }()
+
+	extractor, err := NewExtractorFor(p)
+	if err != nil {
+		r.Err = err
+		return r
+	}
+	validator, err := decrypt.NewValidator(p.Platform, p.Version, p.DataDir)
+	if err != nil {
+		r.Err = err
+		return r
+	}
+	extractor.SetValidate(validator)
+	SetProgress(ctx, extractor)
+	if configure != nil {
+		configure(extractor, p)
+	}
+	RankExtractor(extractor, p.Platform, p.Version, p.FullVersion)
+
+	r.DataKey, r.ImgKey, r.Err = extractor.Extract(ctx, p)
+	if ranker, ok := extractor.(Ranker); ok {
+		r.Strategy = strings.Join(slices.Sorted(maps.Keys(ranker.Hits())), ",")
+	}
+	if r.Err == nil {
+		if err := RecordExtractor(extractor, p.Platform, p.Version, p.FullVersion); err != nil {
+			log.Debug().Err(err).Msg("保存搜索策略命中统计失败")
+		}
+	}
+	return r
+}
@@ -34,15 +34,11 @@ func (g *Glance) Read() ([]byte, error) {
 		return g.data, nil
 	}
 
-	regions, err := GetVmmap(g.PID)
+	regions, err := ScanRegions(g.PID)
 	if err != nil {
 		return nil, err
 	}
-	g.MemRegions = MemRegionsFilter(regions)
-
-	if len(g.MemRegions) == 0 {
-		return nil, errors.ErrNoMemoryRegionsFound
-	}
+	g.MemRegions = regions
 
 	region := g.MemRegions[0]
 
@@ -114,6 +114,19 @@ func MemRegionsFilter(regions []MemRegion) []MemRegion {
 	return filteredRegions
 }
 
+// ScanRegions returns the regions Glance.Read reads, only the first one for now
+func ScanRegions(pid uint32) ([]MemRegion, error) {
+	regions, err := GetVmmap(pid)
+	if err != nil {
+		return nil, err
+	}
+	regions = MemRegionsFilter(regions)
+	if len(regions) == 0 {
+		return nil, errors.ErrNoMemoryRegionsFound
+	}
+	return regions[:1], nil
+}
+
 // parseSize converts size strings like "5616K" or "128.0M" to bytes (uint64)
 func parseSize(sizeStr string) uint64 {
 	// Remove any whitespace
@@ -0,0 +1,33 @@
+package darwin
+
+import (
+	"github.com/aspnmy/chatlog/internal/wechat/key/darwin/glance"
+	"github.com/aspnmy/chatlog/internal/wechat/model"
+)
+
+// Regions returns the memory regions Extract reads
+func (e *V3Extractor) Regions(proc *model.Process) ([]model.MemoryRegion, error) {
+	return regions(proc.PID)
+}
+
+// Regions returns the memory regions Extract reads
+func (e *V4Extractor) Regions(proc *model.Process) ([]model.MemoryRegion, error) {
+	return regions(proc.PID)
+}
+
+func regions(pid uint32) ([]model.MemoryRegion, error) {
+	regions, err := glance.ScanRegions(pid)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]model.MemoryRegion, 0, len(regions))
+	for _, r := range regions {
+		result = append(result, model.MemoryRegion{
+			Start:   r.Start,
+			Size:    r.End - r.Start,
+			Protect: r.Permissions,
+			Module:  r.RegionDetail,
+		})
+	}
+	return result, nil
+}
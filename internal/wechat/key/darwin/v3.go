@@ -4,7 +4,7 @@ import (
 	"bytes"
 	"context"
 	"encoding/hex"
-	"runtime"
+	"fmt"
 	"sync"
 
 	"github.com/rs/zerolog/log"
@@ -13,6 +13,8 @@ import (
 	"github.com/aspnmy/chatlog/internal/wechat/decrypt"
 	"github.com/aspnmy/chatlog/internal/wechat/key/darwin/glance"
 	"github.com/aspnmy/chatlog/internal/wechat/model"
+	"github.com/aspnmy/chatlog/pkg/memscan"
+	"github.com/aspnmy/chatlog/pkg/throttle"
 )
 
 const (
@@ -29,6 +31,7 @@ var V3KeyPatterns = []KeyPatternInfo{
 type V3Extractor struct {
 	validator   *decrypt.Validator
 	keyPatterns []KeyPatternInfo
+	progress    memscan.ProgressFunc
 }
 
 func NewV3Extractor() *V3Extractor {
@@ -60,7 +63,7 @@ func (e *V3Extractor) Extract(ctx context.Context, proc *model.Process) (string,
 	resultChannel := make(chan string, 1)
 
 	// Determine number of worker goroutines
-	workerCount := runtime.NumCPU()
+	workerCount := throttle.Workers()
 	if workerCount < 2 {
 		workerCount = 2
 	}
@@ -124,6 +127,8 @@ func (e *V3Extractor) findMemory(ctx context.Context, pid uint32, memoryChannel
 
 	totalSize := len(memory)
 	log.Debug().Msgf("Read memory region, size: %d bytes", totalSize)
+	progress := memscan.NewProgress(e.progress, uint64(totalSize))
+	defer progress.Finish()
 
 	// If memory is small enough, process it as a single chunk
 	if totalSize <= MinChunkSize {
@@ -173,6 +178,8 @@ func (e *V3Extractor) findMemory(ctx context.Context, pid uint32, memoryChannel
 			}
 
 			chunk := memory[start:end]
+			region := fmt.Sprintf("chunk %d/%d", chunkCount-i, chunkCount)
+			progress.Start(region)
 
 			log.Debug().
 				Int("chunk_index", i+1).
@@ -187,6 +194,7 @@ func (e *V3Extractor) findMemory(ctx context.Context, pid uint32, memoryChannel
 			case <-ctx.Done():
 				return ctx.Err()
 			}
+			progress.Add(uint64(end-i*chunkSize), region)
 		}
 	}
 	return nil
@@ -262,3 +270,8 @@ func (e *V3Extractor) SearchKey(ctx context.Context, memory []byte) (string, boo
 func (e *V3Extractor) SetValidate(validator *decrypt.Validator) {
 	e.validator = validator
 }
+
+// SetProgressFunc sets the callback reporting the progress of Extract, nil for none
+func (e *V3Extractor) SetProgressFunc(fn memscan.ProgressFunc) {
+	e.progress = fn
+}
@@ -5,7 +5,6 @@ import (
 	"context"
 	"encoding/hex"
 	"fmt"
-	"runtime"
 	"sync"
 
 	"github.com/rs/zerolog/log"
@@ -14,6 +13,8 @@ import (
 	"github.com/aspnmy/chatlog/internal/wechat/decrypt"
 	"github.com/aspnmy/chatlog/internal/wechat/key/darwin/glance"
 	"github.com/aspnmy/chatlog/internal/wechat/model"
+	"github.com/aspnmy/chatlog/pkg/memscan"
+	"github.com/aspnmy/chatlog/pkg/throttle"
 )
 
 const (
@@ -43,6 +44,7 @@ type V4Extractor struct {
 	imgKeyPatterns    []KeyPatternInfo
 	processedDataKeys sync.Map // Thread-safe map for processed data keys
 	processedImgKeys  sync.Map // Thread-safe map for processed image keys
+	progress          memscan.ProgressFunc
 }
 
 func NewV4Extractor() *V4Extractor {
@@ -75,7 +77,7 @@ func (e *V4Extractor) Extract(ctx context.Context, proc *model.Process) (string,
 	resultChannel := make(chan [2]string, 1)
 
 	// Determine number of worker goroutines
-	workerCount := runtime.NumCPU()
+	workerCount := throttle.Workers()
 	if workerCount < 2 {
 		workerCount = 2
 	}
@@ -159,6 +161,8 @@ func (e *V4Extractor) findMemory(ctx context.Context, pid uint32, memoryChannel
 
 	totalSize := len(memory)
 	log.Debug().Msgf("Read memory region, size: %d bytes", totalSize)
+	progress := memscan.NewProgress(e.progress, uint64(totalSize))
+	defer progress.Finish()
 
 	// If memory is small enough, process it as a single chunk
 	if totalSize <= MinChunkSize {
@@ -208,6 +212,8 @@ func (e *V4Extractor) findMemory(ctx context.Context, pid uint32, memoryChannel
 			}
 
 			chunk := memory[start:end]
+			region := fmt.Sprintf("chunk %d/%d", chunkCount-i, chunkCount)
+			progress.Start(region)
 
 			log.Debug().
 				Int("chunk_index", i+1).
@@ -222,6 +228,7 @@ func (e *V4Extractor) findMemory(ctx context.Context, pid uint32, memoryChannel
 			case <-ctx.Done():
 				return ctx.Err()
 			}
+			progress.Add(uint64(end-i*chunkSize), region)
 		}
 	}
 
@@ -416,6 +423,11 @@ func (e *V4Extractor) SetValidate(validator *decrypt.Validator) {
 	e.validator = validator
 }
 
+// SetProgressFunc sets the callback reporting the progress of Extract, nil for none
+func (e *V4Extractor) SetProgressFunc(fn memscan.ProgressFunc) {
+	e.progress = fn
+}
+
 type KeyPatternInfo struct {
 	Pattern []byte
 	Offsets []int
@@ -0,0 +1,79 @@
+package key
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/aspnmy/chatlog/internal/errors"
+	"github.com/aspnmy/chatlog/internal/wechat/key/linux"
+	"github.com/aspnmy/chatlog/internal/wechat/key/windows"
+	"github.com/aspnmy/chatlog/internal/wechat/model"
+)
+
+// 用于判断数据目录版本的标志文件，与 wechat.LocateDataDirs 相同
+var (
+	v3Markers = map[string]string{
+		model.PlatformWindows: filepath.Join("Msg", "Misc.db"),
+		model.PlatformMacOS:   filepath.Join("Message", "msg_0.db"),
+	}
+	v4Marker = filepath.Join("db_storage", "session", "session.db")
+)
+
+// DetectVersion 判断进程的微信大版本，依次检查：
+//  1. 进程加载的主模块，WeChatWin.dll 为 3.x，Weixin.dll 为 4.x（仅 Windows 版微信）
+//  2. 进程检测时从可执行文件版本信息读取的版本，即 proc.Version
+//  3. 可执行文件名，Weixin.exe 为 4.x，WeChat.exe 为 3.x（仅 Windows 版微信）
+//  4. 数据目录结构，db_storage 为 4.x
+func DetectVersion(proc *model.Process) (int, error) {
+	if proc.Platform == model.PlatformWindows {
+		if v := moduleVersion(proc.PID); v != 0 {
+			return v, nil
+		}
+	}
+	if proc.Version == 3 || proc.Version == 4 {
+		return proc.Version, nil
+	}
+	if proc.Platform == model.PlatformWindows && proc.ExePath != "" {
+		switch strings.ToLower(filepath.Base(strings.ReplaceAll(proc.ExePath, `\`, "/"))) {
+		case "weixin.exe":
+			return 4, nil
+		case "wechat.exe":
+			return 3, nil
+		}
+	}
+	if proc.DataDir != "" {
+		if _, err := os.Stat(filepath.Join(proc.DataDir, v4Marker)); err == nil {
+			return 4, nil
+		}
+		if marker, ok := v3Markers[proc.Platform]; ok {
+			if _, err := os.Stat(filepath.Join(proc.DataDir, marker)); err == nil {
+				return 3, nil
+			}
+		}
+	}
+	return 0, errors.PlatformUnsupported(proc.Platform, proc.Version)
+}
+
+// moduleVersion 按运行环境读取进程加载的模块，Linux 上为 Wine 进程
+func moduleVersion(pid uint32) int {
+	if pid == 0 {
+		return 0
+	}
+	if runtime.GOOS == "linux" {
+		return linux.ModuleVersion(pid)
+	}
+	return windows.ModuleVersion(pid)
+}
+
+// NewExtractorFor 根据进程自动判断微信版本并创建对应的密钥提取器，
+// 判断出的版本写回 proc.Version，之后创建验证器等操作使用相同的版本
+func NewExtractorFor(proc *model.Process) (Extractor, error) {
+	version, err := DetectVersion(proc)
+	if err != nil {
+		return nil, err
+	}
+	proc.Version = version
+	return NewExtractor(proc.Platform, version)
+}
@@ -0,0 +1,37 @@
+package key
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aspnmy/chatlog/internal/wechat/model"
+)
+
+func TestDetectVersion(t *testing.T) {
+	v4Dir := t.TempDir()
+	os.MkdirAll(filepath.Join(v4Dir, "db_storage", "session"), 0755)
+	os.WriteFile(filepath.Join(v4Dir, v4Marker), nil, 0644)
+	v3Dir := t.TempDir()
+	os.MkdirAll(filepath.Join(v3Dir, "Message"), 0755)
+	os.WriteFile(filepath.Join(v3Dir, v3Markers[model.PlatformMacOS]), nil, 0644)
+
+	tests := []struct {
+		name string
+		proc model.Process
+		want int
+	}{
+		{"version resource", model.Process{Platform: model.PlatformWindows, Version: 3, ExePath: `C:\Weixin\Weixin.exe`}, 3},
+		{"exe name v4", model.Process{Platform: model.PlatformWindows, ExePath: `C:\Program Files\Tencent\Weixin\Weixin.exe`}, 4},
+		{"exe name v3", model.Process{Platform: model.PlatformWindows, ExePath: `C:\Program Files\Tencent\WeChat\WeChat.exe`}, 3},
+		{"data dir v4", model.Process{Platform: model.PlatformMacOS, DataDir: v4Dir}, 4},
+		{"data dir v3", model.Process{Platform: model.PlatformMacOS, DataDir: v3Dir}, 3},
+		{"unknown", model.Process{Platform: model.PlatformMacOS}, 0},
+	}
+	for _, tt := range tests {
+		got, err := DetectVersion(&tt.proc)
+		if got != tt.want || (err != nil) != (tt.want == 0) {
+			t.Errorf("%s: DetectVersion() = %d, %v, want %d", tt.name, got, err, tt.want)
+		}
+	}
+}
@@ -2,12 +2,15 @@ package key
 
 import (
 	"context"
+	"runtime"
 
 	"github.com/aspnmy/chatlog/internal/errors"
 	"github.com/aspnmy/chatlog/internal/wechat/decrypt"
 	"github.com/aspnmy/chatlog/internal/wechat/key/darwin"
+	"github.com/aspnmy/chatlog/internal/wechat/key/linux"
 	"github.com/aspnmy/chatlog/internal/wechat/key/windows"
 	"github.com/aspnmy/chatlog/internal/wechat/model"
+	"github.com/aspnmy/chatlog/pkg/memscan"
 )
 
 // Extractor 定义密钥提取器接口
@@ -22,9 +25,39 @@ type Extractor interface {
 	SetValidate(validator *decrypt.Validator)
 }
 
+// RegionLister 由能够列出待扫描内存区域的提取器实现，用于诊断找不到密钥的原因
+type RegionLister interface {
+	Regions(proc *model.Process) ([]model.MemoryRegion, error)
+}
+
+// ProgressReporter 由能够报告内存扫描进度的提取器实现
+type ProgressReporter interface {
+	SetProgressFunc(fn memscan.ProgressFunc)
+}
+
+type progressKey struct{}
+
+// WithProgress 返回携带扫描进度回调的 ctx，Account.GetKey 会将其设置到提取器上
+func WithProgress(ctx context.Context, fn memscan.ProgressFunc) context.Context {
+	return context.WithValue(ctx, progressKey{}, fn)
+}
+
+// SetProgress 将 ctx 中的扫描进度回调设置到提取器上，提取器不支持时忽略
+func SetProgress(ctx context.Context, extractor Extractor) {
+	fn, _ := ctx.Value(progressKey{}).(memscan.ProgressFunc)
+	if r, ok := extractor.(ProgressReporter); ok && fn != nil {
+		r.SetProgressFunc(fn)
+	}
+}
+
 // NewExtractor 创建适合当前平台的密钥提取器
+// 在 Linux 上通过 Wine 运行的 Windows 版微信使用 linux 包读取 /proc 下的进程内存
 func NewExtractor(platform string, version int) (Extractor, error) {
 	switch {
+	case platform == "windows" && runtime.GOOS == "linux" && version == 3:
+		return linux.NewV3Extractor(), nil
+	case platform == "windows" && runtime.GOOS == "linux" && version == 4:
+		return linux.NewV4Extractor(), nil
 	case platform == "windows" && version == 3:
 		return windows.NewV3Extractor(), nil
 	case platform == "windows" && version == 4:
@@ -0,0 +1,119 @@
+package linux
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/aspnmy/chatlog/internal/errors"
+	"github.com/aspnmy/chatlog/internal/wechat/model"
+)
+
+// Region 是 /proc/<pid>/maps 中的一段内存映射
+type Region struct {
+	Start uint64
+	End   uint64
+	Perms string // 例如 rw-p
+	Path  string // 匿名映射为空，Wine 加载的 DLL 为其在宿主机上的路径
+}
+
+// Size 返回映射大小
+func (r Region) Size() uint64 {
+	return r.End - r.Start
+}
+
+// Readable 映射是否可读
+func (r Region) Readable() bool {
+	return len(r.Perms) > 0 && r.Perms[0] == 'r'
+}
+
+// Writable 映射是否可写
+func (r Region) Writable() bool {
+	return len(r.Perms) > 1 && r.Perms[1] == 'w'
+}
+
+// Private 映射是否为私有映射
+func (r Region) Private() bool {
+	return len(r.Perms) > 3 && r.Perms[3] == 'p'
+}
+
+// Anonymous 是否为匿名映射，Wine 进程的堆内存均为匿名映射
+func (r Region) Anonymous() bool {
+	return r.Path == ""
+}
+
+// toModel 转换为 model.MemoryRegion，模块名取映射文件名
+func toModel(regions []Region) []model.MemoryRegion {
+	result := make([]model.MemoryRegion, 0, len(regions))
+	for _, r := range regions {
+		m := model.MemoryRegion{Start: r.Start, Size: r.Size(), Protect: r.Perms}
+		if r.Path != "" {
+			m.Module = filepath.Base(r.Path)
+		}
+		result = append(result, m)
+	}
+	return result
+}
+
+// ReadMaps 读取进程的内存映射
+func ReadMaps(pid uint32) ([]Region, error) {
+	path := fmt.Sprintf("/proc/%d/maps", pid)
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, errors.OpenProcessFailed(err)
+	}
+	defer f.Close()
+	return parseMaps(f)
+}
+
+// ModuleVersion 根据 Wine 进程映射的主模块判断微信版本，无法读取映射或都未找到时返回 0
+func ModuleVersion(pid uint32) int {
+	regions, err := ReadMaps(pid)
+	if err != nil {
+		return 0
+	}
+	if _, _, ok := findModule(regions, V4ModuleName); ok {
+		return 4
+	}
+	if _, _, ok := findModule(regions, V3ModuleName); ok {
+		return 3
+	}
+	return 0
+}
+
+// parseMaps 解析 /proc/<pid>/maps 格式的内容
+//
+//	7f0000000000-7f0000021000 rw-p 00000000 00:00 0
+//	7e0000000000-7e0000001000 r--p 00000000 08:01 1234  /home/user/.wine/drive_c/.../WeChatWin.dll
+func parseMaps(r io.Reader) ([]Region, error) {
+	var regions []Region
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 5 {
+			continue
+		}
+		start, end, ok := strings.Cut(fields[0], "-")
+		if !ok {
+			return nil, fmt.Errorf("invalid maps line: %s", scanner.Text())
+		}
+		region := Region{Perms: fields[1]}
+		var err error
+		if region.Start, err = strconv.ParseUint(start, 16, 64); err != nil {
+			return nil, fmt.Errorf("invalid maps line: %s", scanner.Text())
+		}
+		if region.End, err = strconv.ParseUint(end, 16, 64); err != nil {
+			return nil, fmt.Errorf("invalid maps line: %s", scanner.Text())
+		}
+		if len(fields) > 5 {
+			region.Path = strings.Join(fields[5:], " ")
+		}
+		regions = append(regions, region)
+	}
+	return regions, scanner.Err()
+}
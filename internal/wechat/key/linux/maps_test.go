@@ -0,0 +1,63 @@
+package linux
+
+import (
+	"bytes"
+	"os"
+	"runtime"
+	"strings"
+	"testing"
+	"unsafe"
+)
+
+func TestParseMaps(t *testing.T) {
+	maps := `140000000-140001000 r--p 00000000 08:01 1234                       /home/u/.wine/drive_c/Program Files/Tencent/WeChat/WeChat.exe
+7a000000-7a001000 r--p 00000000 08:01 5678                         /home/u/.wine/drive_c/Program Files/Tencent/WeChat/[3.9.12.17]/WeChatWin.dll
+7a001000-7b000000 r-xp 00001000 08:01 5678                         /home/u/.wine/drive_c/Program Files/Tencent/WeChat/[3.9.12.17]/WeChatWin.dll
+7b000000-7b020000 rw-p 00000000 00:00 0
+7b020000-7b100000 rw-p 01000000 08:01 5678                         /home/u/.wine/drive_c/Program Files/Tencent/WeChat/[3.9.12.17]/WeChatWin.dll
+7ffd0000-7ffd1000 rw-p 00000000 00:00 0                            [stack]
+`
+	regions, err := parseMaps(strings.NewReader(maps))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(regions) != 6 {
+		t.Fatalf("got %d regions, want 6", len(regions))
+	}
+	if got := regions[0].Path; got != "/home/u/.wine/drive_c/Program Files/Tencent/WeChat/WeChat.exe" {
+		t.Errorf("path with spaces: got %q", got)
+	}
+	anon := regions[3]
+	if !anon.Anonymous() || !anon.Writable() || !anon.Private() || anon.Size() != 0x20000 {
+		t.Errorf("anonymous region parsed wrong: %+v", anon)
+	}
+
+	start, end, ok := findModule(regions, "wechatwin.dll")
+	if !ok || start != 0x7a000000 || end != 0x7b100000 {
+		t.Errorf("findModule = %x, %x, %v", start, end, ok)
+	}
+	if _, _, ok := findModule(regions, "Weixin.dll"); ok {
+		t.Error("findModule found a missing module")
+	}
+}
+
+func TestMemoryReadAt(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("/proc is only available on linux")
+	}
+	want := []byte("chatlog-memory-read-test")
+	mem, err := OpenMemory(uint32(os.Getpid()))
+	if err != nil {
+		t.Skip(err)
+	}
+	defer mem.Close()
+
+	got := make([]byte, len(want))
+	if err := mem.ReadAt(got, uint64(uintptr(unsafe.Pointer(&want[0])))); err != nil {
+		t.Fatal(err)
+	}
+	runtime.KeepAlive(want)
+	if !bytes.Equal(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,55 @@
+package linux
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/aspnmy/chatlog/internal/errors"
+	"github.com/aspnmy/chatlog/pkg/memscan"
+)
+
+// Memory 通过 /proc/<pid>/mem 读取进程内存
+// 需要与微信进程为同一用户，且 kernel.yama.ptrace_scope 允许，否则需要 root 权限
+type Memory struct {
+	f *os.File
+}
+
+// OpenMemory 打开进程内存
+func OpenMemory(pid uint32) (*Memory, error) {
+	f, err := os.Open(fmt.Sprintf("/proc/%d/mem", pid))
+	if err != nil {
+		return nil, errors.OpenProcessFailed(err)
+	}
+	return &Memory{f: f}, nil
+}
+
+// ReadAt 从 addr 处读取 len(b) 字节
+func (m *Memory) ReadAt(b []byte, addr uint64) error {
+	_, err := m.f.ReadAt(b, int64(addr))
+	return err
+}
+
+// Close 关闭进程内存
+func (m *Memory) Close() error {
+	return m.f.Close()
+}
+
+// readRegion 分块读取内存区域并发送到 memoryChannel，分块大小与重叠见 memscan
+// 返回 false 表示上下文已取消
+func readRegion(ctx context.Context, mem *Memory, addr uint64, size uint64, memoryChannel chan<- []byte) bool {
+	read := func(addr uint64, b []byte) error {
+		return mem.ReadAt(b, addr)
+	}
+	return memscan.Read(ctx, read, addr, size, memoryChannel)
+}
+
+// releaseMemory 释放 readRegion 为内存块申请的预算额度
+func releaseMemory(memory []byte) {
+	memscan.Release(memory)
+}
+
+// drainMemory 在扫描结束后释放通道中未被处理的内存块
+func drainMemory(memoryChannel <-chan []byte) {
+	memscan.Drain(memoryChannel)
+}
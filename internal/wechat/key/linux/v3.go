@@ -0,0 +1,260 @@
+package linux
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"math"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/aspnmy/chatlog/internal/errors"
+	"github.com/aspnmy/chatlog/internal/wechat/decrypt"
+	"github.com/aspnmy/chatlog/internal/wechat/model"
+	"github.com/aspnmy/chatlog/pkg/memscan"
+	"github.com/aspnmy/chatlog/pkg/throttle"
+)
+
+const (
+	V3ModuleName    = "WeChatWin.dll" // V3版本微信的主模块名称
+	MaxWorkers      = 16              // 最大工作协程数
+	MinV3RegionSize = 100 * 1024      // 只扫描不小于该大小的可写区域，与 Windows 版本一致
+)
+
+// V3Extractor 从 Wine 中运行的 Windows 版微信 3.x 提取密钥
+// 与 Windows 版本相同，在 WeChatWin.dll 的可写区域中查找指向密钥的指针
+type V3Extractor struct {
+	validator *decrypt.Validator
+	progress  memscan.ProgressFunc
+}
+
+func NewV3Extractor() *V3Extractor {
+	return &V3Extractor{}
+}
+
+// Extract 从 Wine 进程中提取V3版本密钥
+// 返回：dataKey, imgKey（V3版本不返回图片密钥）, error
+func (e *V3Extractor) Extract(ctx context.Context, proc *model.Process) (string, string, error) {
+	if proc.Status == model.StatusOffline {
+		return "", "", errors.ErrWeChatOffline
+	}
+
+	regions, err := ReadMaps(proc.PID)
+	if err != nil {
+		return "", "", err
+	}
+	scan, end, err := v3Regions(regions)
+	if err != nil {
+		return "", "", err
+	}
+
+	mem, err := OpenMemory(proc.PID)
+	if err != nil {
+		return "", "", err
+	}
+	defer mem.Close()
+
+	// 模块加载在 4GB 以下时按32位进程处理
+	is64Bit := end > math.MaxUint32
+
+	searchCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	memoryChannel := make(chan []byte, 100)
+	resultChannel := make(chan string, 1)
+
+	workerCount := throttle.Workers()
+	if workerCount < 2 {
+		workerCount = 2
+	}
+	if workerCount > MaxWorkers {
+		workerCount = MaxWorkers
+	}
+	log.Debug().Msgf("启动 %d 个工作协程进行 V3 密钥搜索", workerCount)
+
+	var workerWaitGroup sync.WaitGroup
+	workerWaitGroup.Add(workerCount)
+	for index := 0; index < workerCount; index++ {
+		go func() {
+			defer workerWaitGroup.Done()
+			e.worker(searchCtx, mem, is64Bit, memoryChannel, resultChannel)
+		}()
+	}
+
+	var producerWaitGroup sync.WaitGroup
+	producerWaitGroup.Add(1)
+	go func() {
+		defer producerWaitGroup.Done()
+		defer close(memoryChannel)
+		regions := toModel(scan)
+		progress := memscan.NewProgress(e.progress, model.RegionsSize(regions))
+		for _, r := range regions {
+			progress.Start(r.String())
+			if !readRegion(searchCtx, mem, r.Start, r.Size, memoryChannel) {
+				return
+			}
+			progress.Add(r.Size, r.String())
+			log.Debug().Msgf("内存区域: 0x%X - 0x%X, 大小: %d 字节", r.Start, r.Start+r.Size, r.Size)
+		}
+		progress.Finish()
+	}()
+
+	go func() {
+		producerWaitGroup.Wait()
+		workerWaitGroup.Wait()
+		drainMemory(memoryChannel)
+		close(resultChannel)
+	}()
+
+	select {
+	case <-ctx.Done():
+		return "", "", ctx.Err()
+	case result, ok := <-resultChannel:
+		if ok && result != "" {
+			return result, "", nil
+		}
+	}
+
+	return "", "", errors.ErrNoValidKey
+}
+
+// Regions 返回 Extract 会扫描的内存区域
+func (e *V3Extractor) Regions(proc *model.Process) ([]model.MemoryRegion, error) {
+	regions, err := ReadMaps(proc.PID)
+	if err != nil {
+		return nil, err
+	}
+	scan, _, err := v3Regions(regions)
+	if err != nil {
+		return nil, err
+	}
+	result := toModel(scan)
+	for i := range result {
+		result[i].Module = V3ModuleName // 模块节之间的匿名映射同样属于该模块
+	}
+	return result, nil
+}
+
+// v3Regions 筛选 WeChatWin.dll 地址范围内的可写区域，同时返回模块的结束地址
+func v3Regions(regions []Region) ([]Region, uint64, error) {
+	start, end, ok := findModule(regions, V3ModuleName)
+	if !ok {
+		return nil, 0, errors.ErrWeChatDLLNotFound
+	}
+	log.Debug().Msgf("找到WeChatWin.dll模块，地址: 0x%X - 0x%X", start, end)
+
+	var result []Region
+	for _, r := range regions {
+		if r.Start >= start && r.End <= end && r.Readable() && r.Writable() && r.Size() >= MinV3RegionSize {
+			result = append(result, r)
+		}
+	}
+	return result, end, nil
+}
+
+// findModule 返回模块在进程中的地址范围
+// Wine 按节映射 DLL，节之间可能夹有匿名映射，因此取同名映射的最小起始地址与最大结束地址
+func findModule(regions []Region, name string) (start, end uint64, ok bool) {
+	for _, r := range regions {
+		if r.Path == "" || !strings.EqualFold(filepath.Base(r.Path), name) {
+			continue
+		}
+		if !ok || r.Start < start {
+			start = r.Start
+		}
+		if r.End > end {
+			end = r.End
+		}
+		ok = true
+	}
+	return start, end, ok
+}
+
+// worker 在内存块中查找指向密钥的指针
+func (e *V3Extractor) worker(ctx context.Context, mem *Memory, is64Bit bool, memoryChannel <-chan []byte, resultChannel chan<- string) {
+	keyPattern := []byte{0x20, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}
+	ptrSize := 8
+	littleEndianFunc := binary.LittleEndian.Uint64
+	if !is64Bit {
+		keyPattern = keyPattern[:4]
+		ptrSize = 4
+		littleEndianFunc = func(b []byte) uint64 { return uint64(binary.LittleEndian.Uint32(b)) }
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case memory, ok := <-memoryChannel:
+			if !ok {
+				return
+			}
+			if e.searchMemory(ctx, mem, memory, keyPattern, ptrSize, littleEndianFunc, resultChannel) {
+				return
+			}
+		}
+	}
+}
+
+// searchMemory 在单个内存块中查找密钥，找到密钥或上下文取消时返回 true
+// 返回前释放内存块占用的预算额度
+func (e *V3Extractor) searchMemory(ctx context.Context, mem *Memory, memory []byte, keyPattern []byte, ptrSize int, littleEndianFunc func([]byte) uint64, resultChannel chan<- string) bool {
+	defer releaseMemory(memory)
+
+	index := len(memory)
+	for {
+		select {
+		case <-ctx.Done():
+			return true
+		default:
+		}
+
+		index = bytes.LastIndex(memory[:index], keyPattern)
+		if index == -1 || index-ptrSize < 0 {
+			return false
+		}
+
+		ptrValue := littleEndianFunc(memory[index-ptrSize : index])
+		if ptrValue > 0x10000 && ptrValue < 0x7FFFFFFFFFFF {
+			if key := e.validateKey(mem, ptrValue); key != "" {
+				select {
+				case resultChannel <- key:
+					log.Debug().Msg("找到有效密钥: " + key)
+					return true
+				default:
+				}
+			}
+		}
+		index -= 1
+	}
+}
+
+// validateKey 读取指针处的32字节并根据数据库头验证
+func (e *V3Extractor) validateKey(mem *Memory, addr uint64) string {
+	keyData := make([]byte, 0x20)
+	if err := mem.ReadAt(keyData, addr); err != nil {
+		return ""
+	}
+	if e.validator.Validate(keyData) {
+		return hex.EncodeToString(keyData)
+	}
+	return ""
+}
+
+// SearchKey V3 的密钥通过指针间接引用，无法只在内存块中查找
+func (e *V3Extractor) SearchKey(ctx context.Context, memory []byte) (string, bool) {
+	return "", false
+}
+
+func (e *V3Extractor) SetValidate(validator *decrypt.Validator) {
+	e.validator = validator
+}
+
+// SetProgressFunc 设置 Extract 的扫描进度回调，nil 表示不报告
+func (e *V3Extractor) SetProgressFunc(fn memscan.ProgressFunc) {
+	e.progress = fn
+}
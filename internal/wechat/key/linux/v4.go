@@ -0,0 +1,263 @@
+package linux
+
+import (
+	"context"
+	"encoding/hex"
+	"math"
+	"sync"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/aspnmy/chatlog/internal/errors"
+	"github.com/aspnmy/chatlog/internal/wechat/decrypt"
+	"github.com/aspnmy/chatlog/internal/wechat/key/windows"
+	"github.com/aspnmy/chatlog/internal/wechat/model"
+	"github.com/aspnmy/chatlog/pkg/memscan"
+	"github.com/aspnmy/chatlog/pkg/throttle"
+)
+
+const (
+	V4ModuleName    = "Weixin.dll" // V4版本微信的主模块名称
+	MinV4RegionSize = 1024 * 1024  // 只扫描不小于该大小的匿名内存区域，与 Windows 版本一致
+)
+
+// V4Extractor 从 Wine 中运行的 Windows 版微信 4.x 提取密钥
+// 内存布局与 Windows 相同，搜索策略复用 windows.V4Extractor
+type V4Extractor struct {
+	validator *decrypt.Validator
+	searcher  *windows.V4Extractor
+	progress  memscan.ProgressFunc
+}
+
+func NewV4Extractor() *V4Extractor {
+	return &V4Extractor{
+		searcher: windows.NewV4Extractor(),
+	}
+}
+
+// Extract 从 Wine 进程中提取V4版本密钥
+// 返回：dataKey, imgKey, error
+func (e *V4Extractor) Extract(ctx context.Context, proc *model.Process) (string, string, error) {
+	if proc.Status == model.StatusOffline {
+		return "", "", errors.ErrWeChatOffline
+	}
+
+	regions, err := ReadMaps(proc.PID)
+	if err != nil {
+		return "", "", err
+	}
+	mem, err := OpenMemory(proc.PID)
+	if err != nil {
+		return "", "", err
+	}
+	defer mem.Close()
+
+	// 主模块加载在 4GB 以下时按32位进程处理
+	if _, end, ok := findModule(regions, V4ModuleName); ok && end <= math.MaxUint32 {
+		e.SetPointerSize(windows.PointerSize32)
+	}
+
+	// 创建上下文以控制所有协程
+	searchCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	memoryChannel := make(chan []byte, 100)
+	resultChannel := make(chan [2]string, 1)
+
+	workerCount := throttle.Workers()
+	if workerCount < 2 {
+		workerCount = 2
+	}
+	if workerCount > MaxWorkers {
+		workerCount = MaxWorkers
+	}
+	log.Debug().Msgf("启动 %d 个工作协程进行 V4 密钥搜索", workerCount)
+
+	var workerWaitGroup sync.WaitGroup
+	workerWaitGroup.Add(workerCount)
+	for index := 0; index < workerCount; index++ {
+		go func() {
+			defer workerWaitGroup.Done()
+			e.worker(searchCtx, memoryChannel, resultChannel)
+		}()
+	}
+
+	var producerWaitGroup sync.WaitGroup
+	producerWaitGroup.Add(1)
+	go func() {
+		defer producerWaitGroup.Done()
+		defer close(memoryChannel)
+		e.findMemory(searchCtx, mem, regions, memoryChannel)
+	}()
+
+	go func() {
+		producerWaitGroup.Wait()
+		workerWaitGroup.Wait()
+		drainMemory(memoryChannel)
+		close(resultChannel)
+	}()
+
+	var finalDataKey, finalImgKey string
+	for {
+		select {
+		case <-ctx.Done():
+			return "", "", ctx.Err()
+		case result, ok := <-resultChannel:
+			if !ok {
+				if finalDataKey != "" || finalImgKey != "" {
+					return finalDataKey, finalImgKey, nil
+				}
+				return "", "", errors.ErrNoValidKey
+			}
+			if result[0] != "" {
+				finalDataKey = result[0]
+			}
+			if result[1] != "" {
+				finalImgKey = result[1]
+			}
+			if finalDataKey != "" && finalImgKey != "" {
+				cancel()
+				return finalDataKey, finalImgKey, nil
+			}
+		}
+	}
+}
+
+// Regions 返回 Extract 会扫描的内存区域
+func (e *V4Extractor) Regions(proc *model.Process) ([]model.MemoryRegion, error) {
+	regions, err := ReadMaps(proc.PID)
+	if err != nil {
+		return nil, err
+	}
+	return toModel(v4Regions(regions)), nil
+}
+
+// v4Regions 筛选可读写的私有匿名内存区域，对应 Windows 上的 MEM_PRIVATE 区域
+func v4Regions(regions []Region) []Region {
+	var result []Region
+	for _, r := range regions {
+		if r.Readable() && r.Writable() && r.Private() && r.Anonymous() && r.Size() >= MinV4RegionSize {
+			result = append(result, r)
+		}
+	}
+	return result
+}
+
+// findMemory 读取待扫描的内存区域
+func (e *V4Extractor) findMemory(ctx context.Context, mem *Memory, regions []Region, memoryChannel chan<- []byte) {
+	scan := toModel(v4Regions(regions))
+	progress := memscan.NewProgress(e.progress, model.RegionsSize(scan))
+	for i, r := range scan {
+		progress.Start(r.String())
+		if !readRegion(ctx, mem, r.Start, r.Size, memoryChannel) {
+			return
+		}
+		progress.Add(r.Size, r.String())
+		if (i+1)%10 == 0 {
+			log.Info().Msgf("已处理 %d 个内存区域", i+1)
+		}
+	}
+	progress.Finish()
+	log.Info().Msgf("内存扫描完成，共处理 %d 个内存区域", len(scan))
+}
+
+// worker 在内存块中搜索密钥，并区分数据密钥与图片密钥
+func (e *V4Extractor) worker(ctx context.Context, memoryChannel <-chan []byte, resultChannel chan<- [2]string) {
+	var dataKey, imgKey string
+	report := func() bool {
+		select {
+		case resultChannel <- [2]string{dataKey, imgKey}:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case memory, ok := <-memoryChannel:
+			if !ok {
+				if dataKey != "" || imgKey != "" {
+					select {
+					case resultChannel <- [2]string{dataKey, imgKey}:
+					default:
+					}
+				}
+				return
+			}
+
+			key, found := e.SearchKey(ctx, memory)
+			releaseMemory(memory)
+			if !found {
+				continue
+			}
+			keyData, err := hex.DecodeString(key)
+			if err != nil {
+				continue
+			}
+			switch {
+			case len(keyData) == 32 && e.validator.Validate(keyData):
+				if dataKey == "" {
+					dataKey = key
+					log.Info().Msg("找到数据密钥")
+					if !report() {
+						return
+					}
+				}
+			case len(keyData) == 16 && e.validator.ValidateImgKey(keyData):
+				if imgKey == "" {
+					imgKey = key
+					log.Info().Msg("找到图片密钥")
+					if !report() {
+						return
+					}
+				}
+			case len(keyData) == 32 && e.validator.ValidateImgKey(keyData):
+				if imgKey == "" {
+					imgKey = key[:32] // 图片密钥只需要前16字节
+					log.Info().Msg("找到图片密钥")
+					if !report() {
+						return
+					}
+				}
+			}
+
+			if dataKey != "" && imgKey != "" {
+				log.Info().Msg("找到两个密钥，工作协程退出")
+				return
+			}
+		}
+	}
+}
+
+// SearchKey 使用 Windows 版本的搜索策略在内存中搜索密钥
+func (e *V4Extractor) SearchKey(ctx context.Context, memory []byte) (string, bool) {
+	return e.searcher.SearchKey(ctx, memory)
+}
+
+// Hits 返回本次提取中各策略找到密钥的次数
+func (e *V4Extractor) Hits() map[string]int {
+	return e.searcher.Hits()
+}
+
+// SetPointerSize 设置目标进程的指针宽度
+func (e *V4Extractor) SetPointerSize(p windows.PointerSize) {
+	e.searcher.SetPointerSize(p)
+}
+
+// Rank 按历史命中次数重排搜索策略
+func (e *V4Extractor) Rank(history map[string]int) {
+	e.searcher.Rank(history)
+}
+
+func (e *V4Extractor) SetValidate(validator *decrypt.Validator) {
+	e.validator = validator
+	e.searcher.SetValidate(validator)
+}
+
+// SetProgressFunc 设置 Extract 的扫描进度回调，nil 表示不报告
+func (e *V4Extractor) SetProgressFunc(fn memscan.ProgressFunc) {
+	e.progress = fn
+}
@@ -0,0 +1,123 @@
+package key
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+// StatsFile 保存搜索策略命中统计的文件，为空时不记录也不排序
+var StatsFile string
+
+// statsMu 保护 StatsFile 的读写，同时提取多个账号的密钥时避免互相覆盖
+var statsMu sync.Mutex
+
+// Ranker 由支持多种搜索策略的提取器实现，按历史命中次数调整策略的执行顺序
+type Ranker interface {
+	// Rank 按历史命中次数重排搜索策略
+	Rank(history map[string]int)
+	// Hits 返回本次提取中各策略找到密钥的次数
+	Hits() map[string]int
+}
+
+// Stats 按微信版本记录各搜索策略找到密钥的次数，只保存在本地
+type Stats struct {
+	// Versions 的键为 平台-版本，例如 windows-4.0.3.22，未知完整版本时为 windows-4
+	Versions map[string]map[string]int `json:"versions"`
+}
+
+// LoadStats 读取命中统计，文件不存在或无法解析时返回空统计
+func LoadStats(path string) *Stats {
+	s := &Stats{}
+	if path != "" {
+		if b, err := os.ReadFile(path); err == nil {
+			json.Unmarshal(b, s)
+		}
+	}
+	if s.Versions == nil {
+		s.Versions = make(map[string]map[string]int)
+	}
+	return s
+}
+
+// Save 写入命中统计
+func (s *Stats) Save(path string) error {
+	b, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path+".tmp", b, 0644); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+// Hits 返回某个微信版本的命中次数
+// 该版本没有记录时，汇总同一平台同一大版本的所有记录
+func (s *Stats) Hits(platform string, version int, fullVersion string) map[string]int {
+	if hits := s.Versions[versionKey(platform, version, fullVersion)]; len(hits) > 0 {
+		return hits
+	}
+	major := versionKey(platform, version, "")
+	total := make(map[string]int)
+	for key, hits := range s.Versions {
+		if key != major && !strings.HasPrefix(key, major+".") {
+			continue
+		}
+		for name, n := range hits {
+			total[name] += n
+		}
+	}
+	return total
+}
+
+// Record 累加一次提取中各策略的命中次数
+func (s *Stats) Record(platform string, version int, fullVersion string, hits map[string]int) {
+	key := versionKey(platform, version, fullVersion)
+	for name, n := range hits {
+		if n <= 0 {
+			continue
+		}
+		if s.Versions[key] == nil {
+			s.Versions[key] = make(map[string]int)
+		}
+		s.Versions[key][name] += n
+	}
+}
+
+func versionKey(platform string, version int, fullVersion string) string {
+	if fullVersion != "" {
+		return fmt.Sprintf("%s-%s", platform, fullVersion)
+	}
+	return fmt.Sprintf("%s-%d", platform, version)
+}
+
+// RankExtractor 按 StatsFile 中的历史记录调整提取器的策略顺序
+func RankExtractor(extractor Extractor, platform string, version int, fullVersion string) {
+	r, ok := extractor.(Ranker)
+	if !ok || StatsFile == "" {
+		return
+	}
+	statsMu.Lock()
+	defer statsMu.Unlock()
+	r.Rank(LoadStats(StatsFile).Hits(platform, version, fullVersion))
+}
+
+// RecordExtractor 将提取器本次的命中次数写入 StatsFile
+func RecordExtractor(extractor Extractor, platform string, version int, fullVersion string) error {
+	r, ok := extractor.(Ranker)
+	if !ok || StatsFile == "" {
+		return nil
+	}
+	hits := r.Hits()
+	if len(hits) == 0 {
+		return nil
+	}
+	statsMu.Lock()
+	defer statsMu.Unlock()
+	stats := LoadStats(StatsFile)
+	stats.Record(platform, version, fullVersion, hits)
+	return stats.Save(StatsFile)
+}
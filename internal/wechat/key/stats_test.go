@@ -0,0 +1,31 @@
+package key
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestStats(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "key_stats.json")
+
+	s := LoadStats(path)
+	s.Record("windows", 4, "4.0.3.22", map[string]int{"base_pattern": 2, "weixin_dll": 0})
+	s.Record("windows", 4, "4.1.0.10", map[string]int{"weixin_dll": 3})
+	s.Record("windows", 3, "", map[string]int{"base_pattern": 5})
+	if err := s.Save(path); err != nil {
+		t.Fatal(err)
+	}
+
+	s = LoadStats(path)
+	if hits := s.Hits("windows", 4, "4.0.3.22"); hits["base_pattern"] != 2 || len(hits) != 1 {
+		t.Errorf("exact version hits = %v", hits)
+	}
+	// 未记录的版本汇总同一大版本
+	hits := s.Hits("windows", 4, "4.1.2.0")
+	if hits["base_pattern"] != 2 || hits["weixin_dll"] != 3 {
+		t.Errorf("major version hits = %v", hits)
+	}
+	if hits := s.Hits("darwin", 4, ""); len(hits) != 0 {
+		t.Errorf("other platform hits = %v", hits)
+	}
+}
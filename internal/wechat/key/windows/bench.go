@@ -0,0 +1,48 @@
+package windows
+
+import (
+	"context"
+	"time"
+
+	"github.com/aspnmy/chatlog/internal/wechat/decrypt"
+)
+
+// BenchResult 单个搜索策略在内存转储上的运行结果
+type BenchResult struct {
+	Strategy   string        `json:"strategy"`
+	Duration   time.Duration `json:"duration"`
+	Candidates int64         `json:"candidates"` // 交给 validator 验证的候选密钥数
+	Found      bool          `json:"found"`
+	Key        string        `json:"-"` // 不输出，便于将结果附在 issue 中
+}
+
+// Bench 依次在 memory 上单独执行每个已注册的搜索策略（不受 SetEnabledStrategies 影响），
+// 按优先级顺序返回各策略的耗时、候选密钥数与是否找到密钥，用于决定各微信版本的默认策略顺序
+// memory 为 4.x 进程的内存转储，ptr 为转储进程的指针宽度
+func Bench(ctx context.Context, memory []byte, validator *decrypt.Validator, ptr PointerSize) []BenchResult {
+	registryMu.RLock()
+	regs := sortedRegistrations()
+	registryMu.RUnlock()
+
+	results := make([]BenchResult, 0, len(regs))
+	for _, r := range regs {
+		if ctx.Err() != nil {
+			break
+		}
+		strategy := r.factory()
+		if s, ok := strategy.(pointerAware); ok {
+			s.SetPointerSize(ptr)
+		}
+		attempts := validator.Attempts()
+		begin := time.Now()
+		key, found := strategy.Search(ctx, memory, validator)
+		results = append(results, BenchResult{
+			Strategy:   r.name,
+			Duration:   time.Since(begin),
+			Candidates: validator.Attempts() - attempts,
+			Found:      found,
+			Key:        key,
+		})
+	}
+	return results
+}
@@ -0,0 +1,79 @@
+package windows
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/binary"
+	"encoding/hex"
+	"testing"
+
+	"github.com/aspnmy/chatlog/internal/wechat/decrypt"
+	"github.com/aspnmy/chatlog/internal/wechat/decrypt/common"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// encryptedHeader 按 4.x 的格式（HMAC-SHA512，kdfIter 次迭代）加密数据库第一页
+func encryptedHeader(key []byte, kdfIter int) []byte {
+	const pageSize = 4096
+	reserve := common.Reserve(sha512.Size)
+	salt := bytes.Repeat([]byte{0x11}, common.SaltSize)
+	encKey := pbkdf2.Key(key, salt, kdfIter, common.KeySize, sha512.New)
+	macKey := pbkdf2.Key(encKey, common.XorBytes(salt, 0x3a), 2, common.KeySize, sha512.New)
+
+	plain := make([]byte, pageSize-reserve-common.SaltSize)
+	iv := bytes.Repeat([]byte{0x22}, common.IVSize)
+	block, _ := aes.NewCipher(encKey)
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(plain, plain)
+
+	page := append(append(append([]byte{}, salt...), plain...), iv...)
+	mac := hmac.New(sha512.New, macKey)
+	mac.Write(page[common.SaltSize:])
+	mac.Write([]byte{1, 0, 0, 0})
+	page = append(page, mac.Sum(nil)...)
+	return append(page, make([]byte, pageSize-len(page))...)
+}
+
+func TestBench(t *testing.T) {
+	decrypt.DefaultCipher = &common.CipherInfo{KDFIter: 2} // 加快测试
+	defer func() { decrypt.DefaultCipher = nil }()
+
+	keyData := []byte("0123456789abcdef0123456789abcdef")
+	validator, err := decrypt.NewValidatorFromHeader("windows", 4, encryptedHeader(keyData, 2))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 一个错误的候选密钥与一个正确的密钥，只有 base_pattern 能找到
+	pattern := PointerSize64.Pattern(0, 0x20, 0x2F)
+	memory := make([]byte, 0x10400)
+	copy(memory[0x10100:], bytes.Repeat([]byte{0x7f, 0x01}, 16))
+	binary.LittleEndian.PutUint64(memory[0x200:], 0x10100)
+	copy(memory[0x208:], pattern)
+	copy(memory[0x10200:], keyData)
+	binary.LittleEndian.PutUint64(memory[0x300:], 0x10200)
+	copy(memory[0x308:], pattern)
+
+	results := Bench(context.Background(), memory, validator, PointerSize64)
+	if len(results) != len(Strategies()) {
+		t.Fatalf("got %d results, want %d", len(results), len(Strategies()))
+	}
+	for _, r := range results {
+		if r.Strategy != "base_pattern" {
+			if r.Found {
+				t.Errorf("%s found a key", r.Strategy)
+			}
+			continue
+		}
+		if !r.Found || r.Key != hex.EncodeToString(keyData) {
+			t.Errorf("base_pattern = %+v", r)
+		}
+		if r.Candidates < 1 {
+			t.Errorf("base_pattern tested %d candidates", r.Candidates)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package windows
+
+import (
+	"context"
+
+	"golang.org/x/sys/windows"
+
+	"github.com/aspnmy/chatlog/pkg/memscan"
+)
+
+// readRegion 分块读取内存区域并发送到 memoryChannel，分块大小与重叠见 memscan
+// 返回 false 表示上下文已取消
+func readRegion(ctx context.Context, handle windows.Handle, addr uintptr, size uintptr, memoryChannel chan<- []byte) bool {
+	read := func(addr uint64, b []byte) error {
+		return windows.ReadProcessMemory(handle, uintptr(addr), &b[0], uintptr(len(b)), nil)
+	}
+	return memscan.Read(ctx, read, uint64(addr), uint64(size), memoryChannel)
+}
+
+// releaseMemory 释放 readRegion 为内存块申请的预算额度
+func releaseMemory(memory []byte) {
+	memscan.Release(memory)
+}
+
+// drainMemory 在扫描结束后释放通道中未被处理的内存块
+func drainMemory(memoryChannel <-chan []byte) {
+	memscan.Drain(memoryChannel)
+}
+
+// protectString 将内存保护属性转换为 rwx 形式，写时复制的页面记为 c
+func protectString(protect uint32) string {
+	switch protect &^ (windows.PAGE_GUARD | windows.PAGE_NOCACHE | windows.PAGE_WRITECOMBINE) {
+	case windows.PAGE_READONLY:
+		return "r--"
+	case windows.PAGE_READWRITE:
+		return "rw-"
+	case windows.PAGE_WRITECOPY:
+		return "rc-"
+	case windows.PAGE_EXECUTE:
+		return "--x"
+	case windows.PAGE_EXECUTE_READ:
+		return "r-x"
+	case windows.PAGE_EXECUTE_READWRITE:
+		return "rwx"
+	case windows.PAGE_EXECUTE_WRITECOPY:
+		return "rcx"
+	default:
+		return "---"
+	}
+}
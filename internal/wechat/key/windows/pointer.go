@@ -0,0 +1,47 @@
+package windows
+
+import "encoding/binary"
+
+// PointerSize 目标进程的指针宽度，零值按64位处理
+// 搜索策略在内存中读取指针与 size_t 字段时使用
+type PointerSize int
+
+const (
+	PointerSize32 PointerSize = 4
+	PointerSize64 PointerSize = 8
+)
+
+// Size 返回指针的字节数
+func (p PointerSize) Size() int {
+	if p == PointerSize32 {
+		return 4
+	}
+	return 8
+}
+
+// Read 以小端序读取 b 开头的一个指针
+func (p PointerSize) Read(b []byte) uint64 {
+	if p.Size() == 4 {
+		return uint64(binary.LittleEndian.Uint32(b))
+	}
+	return binary.LittleEndian.Uint64(b)
+}
+
+// Pattern 将若干个指针宽度的字段按小端序拼接为搜索模式
+func (p PointerSize) Pattern(values ...uint64) []byte {
+	size := p.Size()
+	b := make([]byte, size*len(values))
+	for i, v := range values {
+		if size == 4 {
+			binary.LittleEndian.PutUint32(b[i*size:], uint32(v))
+		} else {
+			binary.LittleEndian.PutUint64(b[i*size:], v)
+		}
+	}
+	return b
+}
+
+// pointerAware 由依赖指针宽度的搜索策略实现
+type pointerAware interface {
+	SetPointerSize(p PointerSize)
+}
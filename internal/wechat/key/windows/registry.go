@@ -0,0 +1,127 @@
+package windows
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// StrategyFactory 创建一个搜索策略，每个提取器使用独立的策略实例
+type StrategyFactory func() SearchStrategy
+
+type registration struct {
+	name     string
+	factory  StrategyFactory
+	priority int
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]registration)
+
+	// enabled 为 NewV4Extractor 使用的策略，nil 表示全部已注册的策略
+	enabled map[string]bool
+)
+
+func init() {
+	RegisterStrategy("base_pattern", func() SearchStrategy { return &BasePatternSearch{} }, 10)
+	RegisterStrategy("setdbkey_log", func() SearchStrategy { return &SetDBKeyLogSearch{} }, 20)
+	RegisterStrategy("sqlite_safety", func() SearchStrategy { return &SQLiteSafetySearch{} }, 30)
+	RegisterStrategy("weixin_dll", func() SearchStrategy { return &WeixinDLLSearch{} }, 40) // 微信4.1+版本的Weixin.dll搜索策略
+}
+
+// RegisterStrategy 注册 V4 搜索策略，priority 越小越靠前，同名策略会被替换
+func RegisterStrategy(name string, factory StrategyFactory, priority int) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry[name] = registration{name: name, factory: factory, priority: priority}
+}
+
+// Strategies 返回按优先级排序的已注册策略名称
+func Strategies() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	return names(sortedRegistrations())
+}
+
+// SetEnabledStrategies 选择 NewV4Extractor 使用的策略
+// 例如 base_pattern,weixin_dll 只使用这两个策略，-sqlite_safety 排除该策略，空列表恢复为全部策略
+func SetEnabledStrategies(spec []string) error {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	var include, exclude []string
+	for _, s := range spec {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		name, excluded := strings.CutPrefix(s, "-")
+		if _, ok := registry[name]; !ok {
+			return fmt.Errorf("unknown key search strategy %q, available: %s", name, strings.Join(names(sortedRegistrations()), ","))
+		}
+		if excluded {
+			exclude = append(exclude, name)
+		} else {
+			include = append(include, name)
+		}
+	}
+	if len(include) == 0 && len(exclude) == 0 {
+		enabled = nil
+		return nil
+	}
+
+	set := make(map[string]bool)
+	if len(include) == 0 {
+		for name := range registry {
+			set[name] = true
+		}
+	}
+	for _, name := range include {
+		set[name] = true
+	}
+	for _, name := range exclude {
+		delete(set, name)
+	}
+	if len(set) == 0 {
+		return fmt.Errorf("no key search strategy enabled")
+	}
+	enabled = set
+	return nil
+}
+
+// newStrategies 按优先级创建已启用的策略
+func newStrategies() []SearchStrategy {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	var strategies []SearchStrategy
+	for _, r := range sortedRegistrations() {
+		if enabled == nil || enabled[r.name] {
+			strategies = append(strategies, r.factory())
+		}
+	}
+	return strategies
+}
+
+func sortedRegistrations() []registration {
+	regs := make([]registration, 0, len(registry))
+	for _, r := range registry {
+		regs = append(regs, r)
+	}
+	sort.Slice(regs, func(i, j int) bool {
+		if regs[i].priority != regs[j].priority {
+			return regs[i].priority < regs[j].priority
+		}
+		return regs[i].name < regs[j].name
+	})
+	return regs
+}
+
+func names(regs []registration) []string {
+	names := make([]string, 0, len(regs))
+	for _, r := range regs {
+		names = append(names, r.name)
+	}
+	return names
+}
@@ -0,0 +1,65 @@
+package windows
+
+import (
+	"testing"
+)
+
+func strategyNames(e *V4Extractor) []string {
+	var names []string
+	for _, s := range e.strategies {
+		names = append(names, s.Name())
+	}
+	return names
+}
+
+func TestSetEnabledStrategies(t *testing.T) {
+	defer SetEnabledStrategies(nil)
+
+	tests := []struct {
+		spec []string
+		want []string
+	}{
+		{nil, []string{"base_pattern", "setdbkey_log", "sqlite_safety", "weixin_dll"}},
+		{[]string{"weixin_dll", "base_pattern"}, []string{"base_pattern", "weixin_dll"}},
+		{[]string{"-sqlite_safety", "-setdbkey_log"}, []string{"base_pattern", "weixin_dll"}},
+		{[]string{"base_pattern", "weixin_dll", "-weixin_dll"}, []string{"base_pattern"}},
+	}
+	for _, tt := range tests {
+		if err := SetEnabledStrategies(tt.spec); err != nil {
+			t.Fatalf("SetEnabledStrategies(%v): %v", tt.spec, err)
+		}
+		got := strategyNames(NewV4Extractor())
+		if len(got) != len(tt.want) {
+			t.Errorf("SetEnabledStrategies(%v) = %v, want %v", tt.spec, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("SetEnabledStrategies(%v) = %v, want %v", tt.spec, got, tt.want)
+				break
+			}
+		}
+	}
+
+	if err := SetEnabledStrategies([]string{"unknown"}); err == nil {
+		t.Error("unknown strategy accepted")
+	}
+	if err := SetEnabledStrategies([]string{"-base_pattern", "-setdbkey_log", "-sqlite_safety", "-weixin_dll"}); err == nil {
+		t.Error("excluding all strategies accepted")
+	}
+}
+
+func TestRegisterStrategy(t *testing.T) {
+	RegisterStrategy("custom_first", func() SearchStrategy { return &BasePatternSearch{} }, 0)
+	defer func() {
+		registryMu.Lock()
+		delete(registry, "custom_first")
+		registryMu.Unlock()
+	}()
+	if got := Strategies()[0]; got != "custom_first" {
+		t.Errorf("first strategy = %s, want custom_first", got)
+	}
+	if got := len(NewV4Extractor().strategies); got != 5 {
+		t.Errorf("got %d strategies, want 5", got)
+	}
+}
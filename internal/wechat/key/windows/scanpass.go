@@ -0,0 +1,92 @@
+package windows
+
+import (
+	"context"
+	"encoding/hex"
+	"sync"
+
+	"github.com/aspnmy/chatlog/internal/wechat/decrypt"
+)
+
+// ScanPass V4 提取密钥时一轮内存扫描的条件，前一轮没有找到密钥时依次放宽条件重试
+type ScanPass struct {
+	Name      string
+	MinRegion uint64 // 跳过小于该大小的内存区域
+	Mapped    bool   // 同时扫描映射文件（MEM_MAPPED）与模块镜像（MEM_IMAGE）区域，包括写时复制的页面
+	Aligned   bool   // 不使用已注册的策略，改用 AlignedSearch 逐个测试16字节对齐的候选密钥
+}
+
+// ScanPasses V4 提取密钥时依次尝试的扫描条件，后面的轮次只扫描前面未扫描过的区域（Aligned 除外）
+var ScanPasses = []ScanPass{
+	{Name: "default", MinRegion: 1 << 20},
+	{Name: "mapped", MinRegion: 1 << 20, Mapped: true},
+	{Name: "small_regions", MinRegion: 64 << 10, Mapped: true},
+	{Name: "aligned", MinRegion: 64 << 10, Mapped: true, Aligned: true},
+}
+
+// alignedMinDistinct 候选密钥中至少包含的不同字节数，随机的32字节平均约有30个不同的字节，
+// 用于跳过指针、文本等低熵数据，减少代价很高的密钥验证
+const alignedMinDistinct = 24
+
+// AlignedSearch 不依赖特征的兜底搜索策略，逐个验证16字节对齐、熵较高的32字节数据
+// 每次验证都需要计算 PBKDF2，速度很慢，只在其他策略都找不到密钥时使用，未注册到默认策略中
+// 多个工作协程共用同一实例，可并发调用
+type AlignedSearch struct {
+	mu   sync.Mutex
+	seen map[[32]byte]bool // 已验证过的候选密钥，同一数据常在内存中出现多次
+}
+
+func (s *AlignedSearch) Name() string {
+	return "aligned_16"
+}
+
+func (s *AlignedSearch) Search(ctx context.Context, memory []byte, validator *decrypt.Validator) (string, bool) {
+	if validator == nil {
+		return "", false
+	}
+	for i := 0; i+32 <= len(memory); i += 16 {
+		if i%(1<<20) == 0 && ctx.Err() != nil {
+			return "", false
+		}
+		keyData := memory[i : i+32]
+		if distinctBytes(keyData) < alignedMinDistinct {
+			continue
+		}
+		if !s.first([32]byte(keyData)) {
+			continue
+		}
+		if validator.Validate(keyData) {
+			return hex.EncodeToString(keyData), true
+		}
+		if validator.ValidateImgKey(keyData) {
+			return hex.EncodeToString(keyData[:16]), true
+		}
+	}
+	return "", false
+}
+
+// first 记录候选密钥，返回是否第一次出现
+func (s *AlignedSearch) first(k [32]byte) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.seen[k] {
+		return false
+	}
+	if s.seen == nil {
+		s.seen = make(map[[32]byte]bool)
+	}
+	s.seen[k] = true
+	return true
+}
+
+func distinctBytes(b []byte) int {
+	var set [256]bool
+	n := 0
+	for _, c := range b {
+		if !set[c] {
+			set[c] = true
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,48 @@
+package windows
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"testing"
+
+	"github.com/aspnmy/chatlog/internal/wechat/decrypt"
+	"github.com/aspnmy/chatlog/internal/wechat/decrypt/common"
+)
+
+func TestAlignedSearch(t *testing.T) {
+	decrypt.DefaultCipher = &common.CipherInfo{KDFIter: 2} // 加快测试
+	defer func() { decrypt.DefaultCipher = nil }()
+
+	keyData := []byte("0123456789abcdefghijklmnopqrstuv")
+	validator, err := decrypt.NewValidatorFromHeader("windows", 4, encryptedHeader(keyData, 2))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 没有任何特征，密钥前有一段重复的高熵数据
+	memory := make([]byte, 0x2000)
+	noise := []byte("ZYXWVUTSRQPONMLKJIHGFEDCBA987654")
+	for i := 0; i < 8; i++ {
+		copy(memory[0x100+i*0x40:], noise)
+	}
+	copy(memory[0x1010:], keyData)
+
+	s := &AlignedSearch{}
+	before := validator.Attempts()
+	key, found := s.Search(context.Background(), memory, validator)
+	if !found || key != hex.EncodeToString(keyData) {
+		t.Fatalf("Search = %s, %v", key, found)
+	}
+	// 重复的数据只验证一次，全零等低熵数据不验证
+	if n := validator.Attempts() - before; n != 2 {
+		t.Errorf("validated %d candidates, want 2", n)
+	}
+
+	// 未对齐的密钥找不到
+	memory = bytes.Repeat([]byte{0}, 0x100)
+	copy(memory[0x21:], keyData)
+	if _, found := (&AlignedSearch{}).Search(context.Background(), memory, validator); found {
+		t.Error("found an unaligned key")
+	}
+}
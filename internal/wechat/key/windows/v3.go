@@ -1,24 +1,68 @@
 package windows
 
 import (
+	"bytes"
 	"context"
+	"encoding/hex"
 
 	"github.com/aspnmy/chatlog/internal/wechat/decrypt"
+	"github.com/aspnmy/chatlog/pkg/memscan"
 )
 
 type V3Extractor struct {
 	validator *decrypt.Validator
+	ptr       PointerSize // 目标进程的指针宽度
+	progress  memscan.ProgressFunc
 }
 
 func NewV3Extractor() *V3Extractor {
 	return &V3Extractor{}
 }
 
+// SearchKey 在内存块中查找V3版本密钥，用于内存转储等没有进程可读取的场景
+// 与 Extract 相同，查找长度字段 0x20 之前的密钥指针，指针按内存块内的偏移处理；
+// 候选密钥必须通过 validator 验证，未设置 validator 时不返回密钥
 func (e *V3Extractor) SearchKey(ctx context.Context, memory []byte) (string, bool) {
-	// TODO: 实现V3版本的密钥搜索逻辑
-	return "", false
+	keyPattern := e.ptr.Pattern(0x20)
+	ptrSize := e.ptr.Size()
+
+	index := len(memory)
+	for {
+		select {
+		case <-ctx.Done():
+			return "", false
+		default:
+		}
+
+		// 从末尾向前查找模式
+		index = bytes.LastIndex(memory[:index], keyPattern)
+		if index == -1 || index-ptrSize < 0 {
+			return "", false
+		}
+
+		// 提取并验证指针值
+		ptrValue := e.ptr.Read(memory[index-ptrSize : index])
+		// 先检查长度，避免内存块不足 0x20 字节时 len(memory)-0x20 回绕
+		if ptrValue > 0x10000 && len(memory) >= 0x20 && ptrValue <= uint64(len(memory)-0x20) {
+			keyData := memory[ptrValue : ptrValue+0x20]
+			if e.validator != nil && e.validator.Validate(keyData) {
+				return hex.EncodeToString(keyData), true
+			}
+		}
+		index -= 1 // 从之前的位置继续搜索
+	}
+}
+
+// SetPointerSize 设置目标进程的指针宽度，默认为64位
+func (e *V3Extractor) SetPointerSize(p PointerSize) {
+	e.ptr = p
 }
 
 func (e *V3Extractor) SetValidate(validator *decrypt.Validator) {
 	e.validator = validator
 }
+
+// SetProgressFunc 设置 Extract 的扫描进度回调，nil 表示不报告
+func (e *V3Extractor) SetProgressFunc(fn memscan.ProgressFunc) {
+	e.progress = fn
+}
@@ -13,3 +13,13 @@ import (
 func (e *V3Extractor) Extract(ctx context.Context, proc *model.Process) (string, string, error) {
 	return "", "", nil
 }
+
+// Regions 返回待扫描的内存区域（非Windows平台实现）
+func (e *V3Extractor) Regions(proc *model.Process) ([]model.MemoryRegion, error) {
+	return nil, nil
+}
+
+// ModuleVersion 根据进程加载的主模块判断微信版本（非Windows平台实现）
+func ModuleVersion(pid uint32) int {
+	return 0
+}
@@ -0,0 +1,67 @@
+package windows
+
+import (
+	"context"
+	"encoding/hex"
+	"testing"
+
+	"github.com/aspnmy/chatlog/internal/wechat/decrypt"
+	"github.com/aspnmy/chatlog/internal/wechat/decrypt/common"
+)
+
+func TestV3Extractor_SearchKey(t *testing.T) {
+	decrypt.DefaultCipher = &common.CipherInfo{KDFIter: 2} // 加快测试
+	defer func() { decrypt.DefaultCipher = nil }()
+
+	keyData := []byte("0123456789abcdef0123456789abcdef")
+	validator, err := decrypt.NewValidatorFromHeader("windows", 4, encryptedHeader(keyData, 2))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		ptr  PointerSize
+	}{
+		{"64bit", PointerSize64},
+		{"32bit", PointerSize32},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			memory := make([]byte, 0x10200)
+			copy(memory[0x10100:], keyData)
+			// 密钥指针之后紧跟长度字段 0x20
+			copy(memory[0x200:], tt.ptr.Pattern(0x10100, 0x20))
+
+			extractor := NewV3Extractor()
+			extractor.SetPointerSize(tt.ptr)
+			extractor.SetValidate(validator)
+			key, found := extractor.SearchKey(context.Background(), memory)
+			if !found || key != hex.EncodeToString(keyData) {
+				t.Fatalf("SearchKey = %s, %v", key, found)
+			}
+
+			// 未设置验证器时不接受候选密钥
+			extractor.SetValidate(nil)
+			if _, found := extractor.SearchKey(context.Background(), memory); found {
+				t.Error("found a key without a validator")
+			}
+		})
+	}
+
+	extractor := NewV3Extractor()
+	extractor.SetValidate(validator)
+
+	// 指针超出内存块时不返回密钥
+	memory := make([]byte, 0x1000)
+	copy(memory[0x100:], PointerSize64.Pattern(0x20000, 0x20))
+	if _, found := extractor.SearchKey(context.Background(), memory); found {
+		t.Error("found a key behind an out of range pointer")
+	}
+
+	// 内存块不足 0x20 字节时不回绕
+	short := PointerSize64.Pattern(0x10010, 0x20)[:16]
+	if _, found := extractor.SearchKey(context.Background(), short); found {
+		t.Error("found a key in a short buffer")
+	}
+}
@@ -6,7 +6,7 @@ import (
 	"encoding/binary"
 	"encoding/hex"
 	"fmt"
-	"runtime"
+	"strings"
 	"sync"
 	"unsafe"
 
@@ -15,11 +15,14 @@ import (
 
 	"github.com/aspnmy/chatlog/internal/errors"
 	"github.com/aspnmy/chatlog/internal/wechat/model"
+	"github.com/aspnmy/chatlog/pkg/memscan"
+	"github.com/aspnmy/chatlog/pkg/throttle"
 	"github.com/aspnmy/chatlog/pkg/util"
 )
 
 const (
 	V3ModuleName = "WeChatWin.dll" // V3版本微信的主模块名称
+	V4ModuleName = "Weixin.dll"    // V4版本微信的主模块名称
 	MaxWorkers   = 16              // 最大工作协程数
 )
 
@@ -61,7 +64,7 @@ func (e *V3Extractor) Extract(ctx context.Context, proc *model.Process) (string,
 	resultChannel := make(chan string, 1)
 
 	// 确定工作协程数量
-	workerCount := runtime.NumCPU()
+	workerCount := throttle.Workers()
 	if workerCount < 2 {
 		workerCount = 2 // 至少2个协程
 	}
@@ -96,6 +99,7 @@ func (e *V3Extractor) Extract(ctx context.Context, proc *model.Process) (string,
 	go func() {
 		producerWaitGroup.Wait()
 		workerWaitGroup.Wait()
+		drainMemory(memoryChannel)
 		close(resultChannel)
 	}()
 
@@ -112,30 +116,30 @@ func (e *V3Extractor) Extract(ctx context.Context, proc *model.Process) (string,
 	return "", "", errors.ErrNoValidKey
 }
 
-// findMemory 搜索WeChatWin.dll中的可写内存区域（V3版本）
-// 参数：
-//
-//	ctx: 上下文，用于控制搜索过程
-//	handle: 进程句柄
-//	pid: 进程ID
-//	memoryChannel: 用于传递内存数据的通道
-//
-// 返回：
-//
-//	error: 错误信息
-func (e *V3Extractor) findMemory(ctx context.Context, handle windows.Handle, pid uint32, memoryChannel chan<- []byte) error {
+// Regions 返回 Extract 会扫描的内存区域
+func (e *V3Extractor) Regions(proc *model.Process) ([]model.MemoryRegion, error) {
+	handle, err := windows.OpenProcess(windows.PROCESS_QUERY_INFORMATION|windows.PROCESS_VM_READ, false, proc.PID)
+	if err != nil {
+		return nil, errors.OpenProcessFailed(err)
+	}
+	defer windows.CloseHandle(handle)
+	return e.regions(handle, proc.PID)
+}
+
+// regions 列出WeChatWin.dll中的可写内存区域（V3版本）
+func (e *V3Extractor) regions(handle windows.Handle, pid uint32) ([]model.MemoryRegion, error) {
 	// 查找WeChatWin.dll模块
 	module, isFound := FindModule(pid, V3ModuleName)
 	if !isFound {
-		return errors.ErrWeChatDLLNotFound
+		return nil, errors.ErrWeChatDLLNotFound
 	}
 	log.Debug().Msg("找到WeChatWin.dll模块，基地址: 0x" + fmt.Sprintf("%X", module.ModBaseAddr))
 
-	// 读取可写内存区域
 	baseAddr := uintptr(module.ModBaseAddr)
 	endAddr := baseAddr + uintptr(module.ModBaseSize)
 	currentAddr := baseAddr
 
+	var regions []model.MemoryRegion
 	for currentAddr < endAddr {
 		var mbi windows.MemoryBasicInformation
 		err := windows.VirtualQueryEx(handle, currentAddr, &mbi, unsafe.Sizeof(mbi))
@@ -157,22 +161,48 @@ func (e *V3Extractor) findMemory(ctx context.Context, handle windows.Handle, pid
 			if currentAddr+regionSize > endAddr {
 				regionSize = endAddr - currentAddr
 			}
-
-			// 读取可写内存区域
-			memory := make([]byte, regionSize)
-			if err = windows.ReadProcessMemory(handle, currentAddr, &memory[0], regionSize, nil); err == nil {
-				select {
-				case memoryChannel <- memory:
-					log.Debug().Msgf("内存区域: 0x%X - 0x%X, 大小: %d 字节", currentAddr, currentAddr+regionSize, regionSize)
-				case <-ctx.Done():
-					return nil
-				}
-			}
+			regions = append(regions, model.MemoryRegion{
+				Start:   uint64(currentAddr),
+				Size:    uint64(regionSize),
+				Protect: protectString(mbi.Protect),
+				Module:  V3ModuleName,
+			})
 		}
 
 		// 移动到下一个内存区域
 		currentAddr = uintptr(mbi.BaseAddress) + uintptr(mbi.RegionSize)
 	}
+	return regions, nil
+}
+
+// findMemory 读取WeChatWin.dll中的可写内存区域（V3版本）
+// 参数：
+//
+//	ctx: 上下文，用于控制搜索过程
+//	handle: 进程句柄
+//	pid: 进程ID
+//	memoryChannel: 用于传递内存数据的通道
+//
+// 返回：
+//
+//	error: 错误信息
+func (e *V3Extractor) findMemory(ctx context.Context, handle windows.Handle, pid uint32, memoryChannel chan<- []byte) error {
+	regions, err := e.regions(handle, pid)
+	if err != nil {
+		return err
+	}
+
+	progress := memscan.NewProgress(e.progress, model.RegionsSize(regions))
+	for _, r := range regions {
+		progress.Start(r.String())
+		// 读取可写内存区域，设置了内存预算时分块读取
+		if !readRegion(ctx, handle, uintptr(r.Start), uintptr(r.Size), memoryChannel) {
+			return nil
+		}
+		progress.Add(r.Size, r.String())
+		log.Debug().Msgf("内存区域: 0x%X - 0x%X, 大小: %d 字节", r.Start, r.Start+r.Size, r.Size)
+	}
+	progress.Finish()
 
 	return nil
 }
@@ -206,36 +236,45 @@ func (e *V3Extractor) worker(ctx context.Context, handle windows.Handle, is64Bit
 			if !ok {
 				return
 			}
+			if e.searchMemory(ctx, handle, memory, keyPattern, ptrSize, littleEndianFunc, resultChannel) {
+				return
+			}
+		}
+	}
+}
 
-			index := len(memory)
-			for {
-				select {
-				case <-ctx.Done():
-					return // 如果上下文取消则退出
-				default:
-				}
+// searchMemory 在单个内存块中查找密钥，找到密钥或上下文取消时返回 true
+// 返回前释放内存块占用的预算额度
+func (e *V3Extractor) searchMemory(ctx context.Context, handle windows.Handle, memory []byte, keyPattern []byte, ptrSize int, littleEndianFunc func([]byte) uint64, resultChannel chan<- string) bool {
+	defer releaseMemory(memory)
 
-				// 从末尾向前查找模式
-				index = bytes.LastIndex(memory[:index], keyPattern)
-				if index == -1 || index-ptrSize < 0 {
-					break
-				}
+	index := len(memory)
+	for {
+		select {
+		case <-ctx.Done():
+			return true // 如果上下文取消则退出
+		default:
+		}
 
-				// 提取并验证指针值
-				ptrValue := littleEndianFunc(memory[index-ptrSize : index])
-				if ptrValue > 0x10000 && ptrValue < 0x7FFFFFFFFFFF {
-					if key := e.validateKey(handle, ptrValue); key != "" {
-						select {
-						case resultChannel <- key:
-							log.Debug().Msg("找到有效密钥: " + key)
-							return
-						default:
-						}
-					}
+		// 从末尾向前查找模式
+		index = bytes.LastIndex(memory[:index], keyPattern)
+		if index == -1 || index-ptrSize < 0 {
+			return false
+		}
+
+		// 提取并验证指针值
+		ptrValue := littleEndianFunc(memory[index-ptrSize : index])
+		if ptrValue > 0x10000 && ptrValue < 0x7FFFFFFFFFFF {
+			if key := e.validateKey(handle, ptrValue); key != "" {
+				select {
+				case resultChannel <- key:
+					log.Debug().Msg("找到有效密钥: " + key)
+					return true
+				default:
 				}
-				index -= 1 // 从之前的位置继续搜索
 			}
 		}
+		index -= 1 // 从之前的位置继续搜索
 	}
 }
 
@@ -290,12 +329,23 @@ func FindModule(pid uint32, name string) (module windows.ModuleEntry32, isFound
 		return module, false
 	}
 
-	// 遍历所有模块查找WeChatWin.dll
+	// 遍历所有模块查找，Windows 的文件名不区分大小写
 	var nextErr error
 	for ; nextErr == nil; nextErr = windows.Module32Next(snapshot, &module) {
-		if windows.UTF16ToString(module.Module[:]) == name {
+		if strings.EqualFold(windows.UTF16ToString(module.Module[:]), name) {
 			return module, true
 		}
 	}
 	return module, false
 }
+
+// ModuleVersion 根据进程加载的主模块判断微信版本，无法读取模块或都未找到时返回 0
+func ModuleVersion(pid uint32) int {
+	if _, ok := FindModule(pid, V4ModuleName); ok {
+		return 4
+	}
+	if _, ok := FindModule(pid, V3ModuleName); ok {
+		return 3
+	}
+	return 0
+}
@@ -3,10 +3,16 @@ package windows
 import (
 	"bytes"
 	"context"
-	"encoding/binary"
 	"encoding/hex"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"golang.org/x/sync/errgroup"
 
 	"github.com/aspnmy/chatlog/internal/wechat/decrypt"
+	"github.com/aspnmy/chatlog/pkg/memscan"
 )
 
 // SearchStrategy 定义密钥搜索策略接口
@@ -18,21 +24,22 @@ type SearchStrategy interface {
 }
 
 // BasePatternSearch 基础模式搜索策略
-type BasePatternSearch struct{}
+type BasePatternSearch struct {
+	Ptr PointerSize
+}
+
+func (s *BasePatternSearch) SetPointerSize(p PointerSize) {
+	s.Ptr = p
+}
 
 func (s *BasePatternSearch) Name() string {
 	return "base_pattern"
 }
 
 func (s *BasePatternSearch) Search(ctx context.Context, memory []byte, validator *decrypt.Validator) (string, bool) {
-	// 定义搜索模式（V4版本）
-	keyPattern := []byte{
-		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
-		0x20, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
-		0x2F, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
-	}
-	ptrSize := 8
-	littleEndianFunc := binary.LittleEndian.Uint64
+	// 定义搜索模式（V4版本），三个指针宽度的字段：0、密钥长度 0x20、0x2F
+	keyPattern := s.Ptr.Pattern(0x00, 0x20, 0x2F)
+	ptrSize := s.Ptr.Size()
 
 	index := len(memory)
 	for {
@@ -49,7 +56,7 @@ func (s *BasePatternSearch) Search(ctx context.Context, memory []byte, validator
 		}
 
 		// 提取密钥指针
-		ptrOffset := int(littleEndianFunc(memory[index-ptrSize : index]))
+		ptrOffset := int(s.Ptr.Read(memory[index-ptrSize : index]))
 
 		// 检查指针偏移量是否在有效范围内
 		if ptrOffset > 0x10000 && ptrOffset < len(memory)-0x20 {
@@ -75,7 +82,13 @@ func (s *BasePatternSearch) Search(ctx context.Context, memory []byte, validator
 }
 
 // SetDBKeyLogSearch 基于SetDBKey日志的搜索策略
-type SetDBKeyLogSearch struct{}
+type SetDBKeyLogSearch struct {
+	Ptr PointerSize
+}
+
+func (s *SetDBKeyLogSearch) SetPointerSize(p PointerSize) {
+	s.Ptr = p
+}
 
 func (s *SetDBKeyLogSearch) Name() string {
 	return "setdbkey_log"
@@ -151,10 +164,11 @@ func (s *SetDBKeyLogSearch) findSetDBKeyCall(localMemory, fullMemory []byte, val
 		}
 	}
 
-	// 查找可能的密钥指针（8字节地址）
-	for i := 0; i < len(localMemory)-8; i++ {
+	// 查找可能的密钥指针
+	ptrSize := s.Ptr.Size()
+	for i := 0; i < len(localMemory)-ptrSize; i++ {
 		// 提取可能的指针值
-		ptrValue := binary.LittleEndian.Uint64(localMemory[i : i+8])
+		ptrValue := s.Ptr.Read(localMemory[i : i+ptrSize])
 
 		// 检查指针是否指向有效内存范围
 		if ptrValue > 0x10000 && ptrValue < uint64(len(fullMemory))-32 {
@@ -225,7 +239,13 @@ func (s *SetDBKeyLogSearch) isValidKeyPattern(data []byte) bool {
 }
 
 // SQLiteSafetySearch 基于sqlite3SafetyCheckOk的搜索策略
-type SQLiteSafetySearch struct{}
+type SQLiteSafetySearch struct {
+	Ptr PointerSize
+}
+
+func (s *SQLiteSafetySearch) SetPointerSize(p PointerSize) {
+	s.Ptr = p
+}
 
 func (s *SQLiteSafetySearch) Name() string {
 	return "sqlite_safety"
@@ -343,10 +363,11 @@ func (s *SQLiteSafetySearch) searchForKeyAroundSQLiteFunction(localMemory []byte
 		}
 	}
 
-	// 方法2：搜索密钥指针（8字节地址）
-	for i := 0; i < len(searchArea)-8; i++ {
+	// 方法2：搜索密钥指针
+	ptrSize := s.Ptr.Size()
+	for i := 0; i < len(searchArea)-ptrSize; i++ {
 		// 提取可能的指针值
-		ptrValue := binary.LittleEndian.Uint64(searchArea[i : i+8])
+		ptrValue := s.Ptr.Read(searchArea[i : i+ptrSize])
 
 		// 检查指针是否指向有效内存范围
 		if ptrValue > 0x10000 && ptrValue < uint64(len(fullMemory))-32 {
@@ -394,57 +415,116 @@ func (s *SQLiteSafetySearch) isValidKeyPattern(data []byte) bool {
 type V4Extractor struct {
 	validator  *decrypt.Validator
 	strategies []SearchStrategy
+	ptr        PointerSize // 目标进程的指针宽度
+	progress   memscan.ProgressFunc
+	scanLimit  uint64 // Extract 最多读取的内存字节数，0 表示不限制
+
+	// preferred 为历史上命中过的策略，SearchKey 先单独执行它，未找到再并行执行其余策略
+	preferred SearchStrategy
+
+	mu   sync.Mutex
+	hits map[string]int // 本次提取中各策略找到密钥的次数
 }
 
+// NewV4Extractor 使用已注册并启用的搜索策略创建提取器，见 RegisterStrategy 与 SetEnabledStrategies
 func NewV4Extractor() *V4Extractor {
-	// 初始化默认搜索策略
-	strategies := []SearchStrategy{
-		&BasePatternSearch{},
-		&SetDBKeyLogSearch{},
-		&SQLiteSafetySearch{},
-		&WeixinDLLSearch{}, // 微信4.1+版本的Weixin.dll搜索策略
-	}
-
 	return &V4Extractor{
-		strategies: strategies,
+		strategies: newStrategies(),
+		hits:       make(map[string]int),
 	}
 }
 
+// SearchKey 并行执行所有搜索策略，任一策略找到通过验证的密钥后立即取消其他策略，
+// 等待全部策略退出后返回，调用方可以安全地释放 memory
+// 设置了优先策略时先单独执行它，找到密钥则不再执行其余策略
 func (e *V4Extractor) SearchKey(ctx context.Context, memory []byte) (string, bool) {
-	// 并行执行所有搜索策略
-	resultChan := make(chan struct {
-		key      string
-		found    bool
-		strategy string
-	}, len(e.strategies))
-
-	// 启动所有搜索策略
+	if e.preferred != nil {
+		if key, found := e.search(ctx, e.preferred, memory); found {
+			e.hit(e.preferred)
+			return key, true
+		}
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		once sync.Once
+		key  string
+	)
+	g, gctx := errgroup.WithContext(ctx)
 	for _, strategy := range e.strategies {
-		go func(s SearchStrategy) {
-			key, found := s.Search(ctx, memory, e.validator)
-			resultChan <- struct {
-				key      string
-				found    bool
-				strategy string
-			}{key, found, s.Name()}
-		}(strategy)
+		if strategy == e.preferred {
+			continue
+		}
+		g.Go(func() error {
+			k, found := e.search(gctx, strategy, memory)
+			if found {
+				once.Do(func() {
+					key = k
+					e.hit(strategy)
+					cancel()
+				})
+			}
+			return nil
+		})
 	}
+	g.Wait()
 
-	// 收集搜索结果
-	for i := 0; i < len(e.strategies); i++ {
-		result := <-resultChan
-		if result.found {
-			return result.key, true
-		}
+	return key, key != ""
+}
+
+// search 执行单个搜索策略并记录耗时
+func (e *V4Extractor) search(ctx context.Context, strategy SearchStrategy, memory []byte) (string, bool) {
+	begin := time.Now()
+	key, found := strategy.Search(ctx, memory, e.validator)
+	log.Debug().Msgf("搜索策略 %s 耗时 %s，找到密钥: %v", strategy.Name(), time.Since(begin), found)
+	return key, found
+}
+
+func (e *V4Extractor) hit(strategy SearchStrategy) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.hits[strategy.Name()]++
+}
+
+// Hits 返回本次提取中各策略找到密钥的次数
+func (e *V4Extractor) Hits() map[string]int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	hits := make(map[string]int, len(e.hits))
+	for name, n := range e.hits {
+		hits[name] = n
 	}
+	return hits
+}
 
-	return "", false
+// Rank 按历史命中次数从高到低重排搜索策略，命中次数最多的策略作为优先策略
+func (e *V4Extractor) Rank(history map[string]int) {
+	sort.SliceStable(e.strategies, func(i, j int) bool {
+		return history[e.strategies[i].Name()] > history[e.strategies[j].Name()]
+	})
+	e.preferred = nil
+	if len(e.strategies) > 0 && history[e.strategies[0].Name()] > 0 {
+		e.preferred = e.strategies[0]
+		log.Debug().Msgf("优先使用搜索策略 %s，历史命中 %d 次", e.preferred.Name(), history[e.preferred.Name()])
+	}
 }
 
 func (e *V4Extractor) SetValidate(validator *decrypt.Validator) {
 	e.validator = validator
 }
 
+// SetProgressFunc 设置 Extract 的扫描进度回调，nil 表示不报告
+func (e *V4Extractor) SetProgressFunc(fn memscan.ProgressFunc) {
+	e.progress = fn
+}
+
+// SetScanLimit 设置 Extract 最多读取的内存字节数，超过后停止扫描，0 表示不限制
+func (e *V4Extractor) SetScanLimit(n uint64) {
+	e.scanLimit = n
+}
+
 // WeixinDLLSearch 针对Weixin.dll的搜索策略（微信4.1+版本）
 type WeixinDLLSearch struct{}
 
@@ -543,10 +623,29 @@ func (s *WeixinDLLSearch) isValidKeyPattern(data []byte) bool {
 
 // AddStrategy 添加搜索策略
 func (e *V4Extractor) AddStrategy(strategy SearchStrategy) {
+	e.setStrategyPointerSize(strategy)
 	e.strategies = append(e.strategies, strategy)
 }
 
 // SetStrategies 设置搜索策略列表
 func (e *V4Extractor) SetStrategies(strategies []SearchStrategy) {
+	for _, strategy := range strategies {
+		e.setStrategyPointerSize(strategy)
+	}
 	e.strategies = strategies
+	e.preferred = nil
+}
+
+// SetPointerSize 设置目标进程的指针宽度，用于32位的 4.x 进程或其内存转储，默认为64位
+func (e *V4Extractor) SetPointerSize(p PointerSize) {
+	e.ptr = p
+	for _, strategy := range e.strategies {
+		e.setStrategyPointerSize(strategy)
+	}
+}
+
+func (e *V4Extractor) setStrategyPointerSize(strategy SearchStrategy) {
+	if s, ok := strategy.(pointerAware); ok {
+		s.SetPointerSize(e.ptr)
+	}
 }
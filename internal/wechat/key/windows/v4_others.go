@@ -13,3 +13,8 @@ import (
 func (e *V4Extractor) Extract(ctx context.Context, proc *model.Process) (string, string, error) {
 	return "", "", nil
 }
+
+// Regions 返回待扫描的内存区域（非Windows平台实现）
+func (e *V4Extractor) Regions(proc *model.Process) ([]model.MemoryRegion, error) {
+	return nil, nil
+}
@@ -3,6 +3,7 @@ package windows
 import (
 	"context"
 	"encoding/binary"
+	"encoding/hex"
 	"testing"
 
 	"github.com/aspnmy/chatlog/internal/wechat/decrypt"
@@ -149,3 +150,67 @@ func BenchmarkV4Extractor_SearchKey(b *testing.B) {
 		extractor.SearchKey(ctx, memory)
 	}
 }
+
+func TestV4Extractor_Rank(t *testing.T) {
+	extractor := NewV4Extractor()
+	extractor.Rank(map[string]int{"weixin_dll": 3, "setdbkey_log": 1})
+	if extractor.preferred == nil || extractor.preferred.Name() != "weixin_dll" {
+		t.Fatalf("preferred = %v, want weixin_dll", extractor.preferred)
+	}
+	if got := extractor.strategies[1].Name(); got != "setdbkey_log" {
+		t.Errorf("second strategy = %s, want setdbkey_log", got)
+	}
+
+	// 优先策略未命中时仍执行其余策略
+	keyPattern := []byte{
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x20, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x2F, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	}
+	memory := make([]byte, 0x10200)
+	copy(memory[0x10100:], "0123456789abcdef0123456789abcdef")
+	binary.LittleEndian.PutUint64(memory[0x200:0x208], 0x10100)
+	copy(memory[0x208:0x220], keyPattern)
+	extractor.SetStrategies([]SearchStrategy{&WeixinDLLSearch{}, &BasePatternSearch{}})
+	extractor.Rank(map[string]int{"weixin_dll": 3})
+	if _, found := extractor.SearchKey(context.Background(), memory); !found {
+		t.Fatal("key not found")
+	}
+	if hits := extractor.Hits(); len(hits) == 0 {
+		t.Error("hits not recorded")
+	}
+
+	extractor.Rank(nil)
+	if extractor.preferred != nil {
+		t.Error("preferred should be cleared without history")
+	}
+}
+
+func TestV4Extractor_SearchKey32(t *testing.T) {
+	extractor := NewV4Extractor()
+	extractor.SetStrategies([]SearchStrategy{&BasePatternSearch{}})
+	extractor.SetPointerSize(PointerSize32)
+
+	// 32位进程中模式的每个字段为4字节
+	keyPattern := []byte{
+		0x00, 0x00, 0x00, 0x00,
+		0x20, 0x00, 0x00, 0x00,
+		0x2F, 0x00, 0x00, 0x00,
+	}
+	memory := make([]byte, 0x10200)
+	keyData := []byte("0123456789abcdef0123456789abcdef")
+	copy(memory[0x10100:], keyData)
+	binary.LittleEndian.PutUint32(memory[0x200:0x204], 0x10100)
+	copy(memory[0x204:0x210], keyPattern)
+
+	key, found := extractor.SearchKey(context.Background(), memory)
+	if !found || key != hex.EncodeToString(keyData) {
+		t.Fatalf("SearchKey = %s, %v", key, found)
+	}
+
+	// 按64位搜索时不应匹配4字节的模式
+	extractor.SetPointerSize(PointerSize64)
+	if _, found := extractor.SearchKey(context.Background(), memory); found {
+		t.Error("64-bit search matched a 32-bit pattern")
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/hex"
 	"runtime"
+	"strings"
 	"sync"
 	"unsafe"
 
@@ -12,10 +13,15 @@ import (
 
 	"github.com/aspnmy/chatlog/internal/errors"
 	"github.com/aspnmy/chatlog/internal/wechat/model"
+	"github.com/aspnmy/chatlog/pkg/memscan"
+	"github.com/aspnmy/chatlog/pkg/throttle"
+	"github.com/aspnmy/chatlog/pkg/util"
 )
 
 const (
-	MEM_PRIVATE = 0x20000 // 私有内存类型
+	MEM_PRIVATE = 0x20000   // 私有内存类型
+	MEM_MAPPED  = 0x40000   // 映射文件
+	MEM_IMAGE   = 0x1000000 // 模块镜像
 )
 
 // Extract 从微信进程中提取V4版本密钥
@@ -41,6 +47,51 @@ func (e *V4Extractor) Extract(ctx context.Context, proc *model.Process) (string,
 	}
 	defer windows.CloseHandle(handle)
 
+	// 32位进程的策略按4字节指针搜索
+	is64Bit, err := util.Is64Bit(handle)
+	if err != nil {
+		return "", "", err
+	}
+	if !is64Bit {
+		e.SetPointerSize(PointerSize32)
+	}
+
+	// 没有找到密钥时依次放宽扫描条件重试
+	scanned := make(map[uint64]bool)
+	tried := make([]string, 0, len(ScanPasses))
+	for _, pass := range ScanPasses {
+		tried = append(tried, pass.Name)
+		dataKey, imgKey, err := e.extractPass(ctx, handle, pass, scanned)
+		if err != errors.ErrNoValidKey {
+			return dataKey, imgKey, err
+		}
+		log.Info().Msgf("扫描条件 %s 未找到密钥", pass.Name)
+	}
+	log.Warn().Msgf("已尝试扫描条件 %s，均未找到密钥", strings.Join(tried, ", "))
+	return "", "", errors.ErrNoValidKey
+}
+
+// extractPass 按 pass 的条件扫描一轮内存，scanned 记录已扫描的区域，非 Aligned 的轮次跳过这些区域
+func (e *V4Extractor) extractPass(ctx context.Context, handle windows.Handle, pass ScanPass, scanned map[uint64]bool) (string, string, error) {
+	var regions []model.MemoryRegion
+	for _, r := range e.regions(handle, pass) {
+		if pass.Aligned || !scanned[r.Start] {
+			regions = append(regions, r)
+			scanned[r.Start] = true
+		}
+	}
+	log.Info().Msgf("使用扫描条件 %s，共 %d 个内存区域", pass.Name, len(regions))
+	if len(regions) == 0 {
+		return "", "", errors.ErrNoValidKey
+	}
+	if pass.Aligned {
+		strategies, preferred := e.strategies, e.preferred
+		e.strategies, e.preferred = []SearchStrategy{&AlignedSearch{}}, nil
+		defer func() {
+			e.strategies, e.preferred = strategies, preferred
+		}()
+	}
+
 	// 创建上下文以控制所有协程
 	searchCtx, cancel := context.WithCancel(ctx)
 	defer cancel()
@@ -50,7 +101,7 @@ func (e *V4Extractor) Extract(ctx context.Context, proc *model.Process) (string,
 	resultChannel := make(chan [2]string, 1)
 
 	// 确定工作协程数量
-	workerCount := runtime.NumCPU()
+	workerCount := throttle.Workers()
 	if workerCount < 2 {
 		workerCount = 2 // 至少2个协程
 	}
@@ -71,12 +122,15 @@ func (e *V4Extractor) Extract(ctx context.Context, proc *model.Process) (string,
 
 	// 启动生产者协程
 	var producerWaitGroup sync.WaitGroup
+	var limited bool // 达到扫描上限，未扫描全部内存
 	producerWaitGroup.Add(1)
 	go func() {
 		defer producerWaitGroup.Done()
 		defer close(memoryChannel) // 生产者完成后关闭通道
-		err := e.findMemory(searchCtx, handle, memoryChannel)
-		if err != nil {
+		err := e.findMemory(searchCtx, handle, regions, memoryChannel)
+		if err == errors.ErrScanLimitReached {
+			limited = true
+		} else if err != nil {
 			log.Err(err).Msg("查找内存区域失败")
 		}
 	}()
@@ -85,6 +139,7 @@ func (e *V4Extractor) Extract(ctx context.Context, proc *model.Process) (string,
 	go func() {
 		producerWaitGroup.Wait()
 		workerWaitGroup.Wait()
+		drainMemory(memoryChannel)
 		close(resultChannel)
 	}()
 
@@ -94,10 +149,14 @@ func (e *V4Extractor) Extract(ctx context.Context, proc *model.Process) (string,
 	for {
 		select {
 		case <-ctx.Done():
-			return "", "", ctx.Err()
+			// 超时或取消时返回已找到的密钥
+			return finalDataKey, finalImgKey, errors.PartialKey(ctx.Err())
 		case result, ok := <-resultChannel:
 			if !ok {
 				// 通道关闭，所有工作协程完成，返回找到的任何密钥
+				if limited {
+					return finalDataKey, finalImgKey, errors.PartialKey(errors.ErrScanLimitReached)
+				}
 				if finalDataKey != "" || finalImgKey != "" {
 					return finalDataKey, finalImgKey, nil
 				}
@@ -121,17 +180,18 @@ func (e *V4Extractor) Extract(ctx context.Context, proc *model.Process) (string,
 	}
 }
 
-// findMemory 搜索可写内存区域（V4版本）
-// 参数：
-//
-//	ctx: 上下文，用于控制搜索过程
-//	handle: 进程句柄
-//	memoryChannel: 用于传递内存数据的通道
-//
-// 返回：
-//
-//	error: 错误信息
-func (e *V4Extractor) findMemory(ctx context.Context, handle windows.Handle, memoryChannel chan<- []byte) error {
+// Regions 返回 Extract 会扫描的内存区域
+func (e *V4Extractor) Regions(proc *model.Process) ([]model.MemoryRegion, error) {
+	handle, err := windows.OpenProcess(windows.PROCESS_VM_READ|windows.PROCESS_QUERY_INFORMATION, false, proc.PID)
+	if err != nil {
+		return nil, errors.OpenProcessFailed(err)
+	}
+	defer windows.CloseHandle(handle)
+	return e.regions(handle, ScanPasses[0]), nil
+}
+
+// regions 列出符合 pass 条件的内存区域（V4版本），默认只包括可读写的私有内存区域
+func (e *V4Extractor) regions(handle windows.Handle, pass ScanPass) []model.MemoryRegion {
 	// 定义搜索范围
 	minAddr := uintptr(0x10000)    // 进程空间通常从0x10000开始
 	maxAddr := uintptr(0x7FFFFFFF) // 32位进程空间限制
@@ -139,18 +199,10 @@ func (e *V4Extractor) findMemory(ctx context.Context, handle windows.Handle, mem
 	if runtime.GOARCH == "amd64" {
 		maxAddr = uintptr(0x7FFFFFFFFFFF) // 64位进程空间限制
 	}
-	log.Info().Msgf("开始扫描内存区域从 0x%X 到 0x%X", minAddr, maxAddr)
 
+	var regions []model.MemoryRegion
 	currentAddr := minAddr
-	regionCount := 0
-
 	for currentAddr < maxAddr {
-		select {
-		case <-ctx.Done():
-			return nil
-		default:
-		}
-
 		var memInfo windows.MemoryBasicInformation
 		err := windows.VirtualQueryEx(handle, currentAddr, &memInfo, unsafe.Sizeof(memInfo))
 		if err != nil {
@@ -158,40 +210,83 @@ func (e *V4Extractor) findMemory(ctx context.Context, handle windows.Handle, mem
 		}
 
 		// 跳过小内存区域
-		if memInfo.RegionSize < 1024*1024 {
+		if uint64(memInfo.RegionSize) < pass.MinRegion {
 			currentAddr += uintptr(memInfo.RegionSize)
 			continue
 		}
 
-		// 检查内存区域是否可读且私有
-		if memInfo.State == windows.MEM_COMMIT && (memInfo.Protect&windows.PAGE_READWRITE) != 0 && memInfo.Type == MEM_PRIVATE {
+		// 检查内存区域是否可读写且私有，放宽条件时包括映射文件与模块镜像
+		writable := memInfo.Protect&windows.PAGE_READWRITE != 0
+		typeOK := memInfo.Type == MEM_PRIVATE
+		if pass.Mapped {
+			writable = writable || memInfo.Protect&windows.PAGE_WRITECOPY != 0
+			typeOK = typeOK || memInfo.Type == MEM_MAPPED || memInfo.Type == MEM_IMAGE
+		}
+		if memInfo.State == windows.MEM_COMMIT && writable && typeOK {
 			// 计算区域大小，确保不超出限制
 			regionSize := uintptr(memInfo.RegionSize)
 			if currentAddr+regionSize > maxAddr {
 				regionSize = maxAddr - currentAddr
 			}
-
-			// 读取内存区域
-			memory := make([]byte, regionSize)
-			if err = windows.ReadProcessMemory(handle, currentAddr, &memory[0], regionSize, nil); err == nil {
-				select {
-				case memoryChannel <- memory:
-					regionCount++
-					// 每处理10个区域记录一次日志，避免过多日志输出
-					if regionCount%10 == 0 {
-						log.Info().Msgf("已处理 %d 个内存区域", regionCount)
-					}
-				case <-ctx.Done():
-					return nil
-				}
-			}
+			regions = append(regions, model.MemoryRegion{
+				Start:   uint64(currentAddr),
+				Size:    uint64(regionSize),
+				Protect: protectString(memInfo.Protect),
+			})
 		}
 
 		// 移动到下一个内存区域
 		currentAddr = uintptr(memInfo.BaseAddress) + uintptr(memInfo.RegionSize)
 	}
+	return regions
+}
+
+// findMemory 读取可写内存区域（V4版本）
+// 参数：
+//
+//	ctx: 上下文，用于控制搜索过程
+//	handle: 进程句柄
+//	regions: 要读取的内存区域
+//	memoryChannel: 用于传递内存数据的通道
+//
+// 返回：
+//
+//	error: 错误信息
+func (e *V4Extractor) findMemory(ctx context.Context, handle windows.Handle, regions []model.MemoryRegion, memoryChannel chan<- []byte) error {
+	log.Info().Msgf("开始扫描 %d 个内存区域", len(regions))
+
+	total := model.RegionsSize(regions)
+	if e.scanLimit > 0 {
+		total = min(total, e.scanLimit)
+	}
+	progress := memscan.NewProgress(e.progress, total)
+	var scanned uint64
+	for i, r := range regions {
+		// 达到扫描上限时只读取区域的前一部分
+		size := r.Size
+		if e.scanLimit > 0 {
+			if scanned >= e.scanLimit {
+				log.Warn().Msgf("已扫描 %d 字节，达到扫描上限，跳过剩余 %d 个内存区域", scanned, len(regions)-i)
+				return errors.ErrScanLimitReached
+			}
+			size = min(size, e.scanLimit-scanned)
+		}
+		scanned += size
+
+		progress.Start(r.String())
+		// 读取内存区域，设置了内存预算时分块读取
+		if !readRegion(ctx, handle, uintptr(r.Start), uintptr(size), memoryChannel) {
+			return nil
+		}
+		progress.Add(size, r.String())
+		// 每处理10个区域记录一次日志，避免过多日志输出
+		if (i+1)%10 == 0 {
+			log.Info().Msgf("已处理 %d 个内存区域", i+1)
+		}
+	}
 
-	log.Info().Msgf("内存扫描完成，共处理 %d 个内存区域", regionCount)
+	progress.Finish()
+	log.Info().Msgf("内存扫描完成，共处理 %d 个内存区域", len(regions))
 	return nil
 }
 
@@ -225,12 +320,15 @@ func (e *V4Extractor) worker(ctx context.Context, handle windows.Handle, memoryC
 			// 检查是否已经找到两个密钥，如果是则跳过处理
 			select {
 			case <-ctx.Done():
+				releaseMemory(memory)
 				return
 			default:
 			}
 
 			// 使用SearchKey方法搜索密钥（该方法会并行执行所有搜索策略）
-			if key, found := e.SearchKey(ctx, memory); found {
+			key, found := e.SearchKey(ctx, memory)
+			releaseMemory(memory)
+			if found {
 				// 验证密钥类型
 				keyData, err := hex.DecodeString(key)
 				if err == nil {
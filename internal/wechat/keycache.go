@@ -0,0 +1,77 @@
+package wechat
+
+import (
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/aspnmy/chatlog/internal/wechat/decrypt"
+	"github.com/aspnmy/chatlog/pkg/keybag"
+	"github.com/aspnmy/chatlog/pkg/keystore"
+)
+
+// KeyStore 本机加密保存的密钥缓存，为 nil 时每次都从微信进程中提取
+var KeyStore *keystore.Store
+
+// loadCachedKey 使用缓存中的密钥，验证失败时说明微信重新登录或数据已变化，删除缓存后返回 false
+// 4.x 缺少图片密钥时也返回 false，需要重新提取
+func (a *Account) loadCachedKey() bool {
+	if KeyStore == nil || a.Name == "" || a.DataDir == "" {
+		return false
+	}
+	k, err := KeyStore.Get(a.Name)
+	if err != nil {
+		if !errors.Is(err, keystore.ErrNotFound) {
+			log.Debug().Err(err).Msg("读取密钥缓存失败")
+		}
+		return false
+	}
+	if a.Version == 4 && k.ImgKey == "" {
+		return false
+	}
+
+	validator, err := decrypt.NewValidator(a.Platform, a.Version, a.DataDir)
+	if err != nil {
+		log.Debug().Err(err).Msg("无法验证缓存的密钥")
+		return false
+	}
+	b, err := hex.DecodeString(k.DataKey)
+	if err != nil {
+		return false
+	}
+	info, ok := validator.ValidateWithInfo(b)
+	if !ok {
+		log.Info().Msgf("缓存的 %s 密钥已失效，重新提取", a.Name)
+		if err := KeyStore.Delete(a.Name); err != nil {
+			log.Debug().Err(err).Msg("删除失效的密钥缓存失败")
+		}
+		return false
+	}
+
+	a.Key, a.ImgKey, a.Cipher = k.DataKey, k.ImgKey, info
+	log.Info().Msgf("使用缓存的 %s 密钥", a.Name)
+	return true
+}
+
+// saveCachedKey 将提取到的密钥写入缓存
+func (a *Account) saveCachedKey() {
+	if KeyStore == nil || a.Name == "" || a.Key == "" {
+		return
+	}
+	now := time.Now().UTC()
+	err := KeyStore.Put(keybag.Key{
+		Account:     a.Name,
+		Platform:    a.Platform,
+		Version:     a.Version,
+		FullVersion: a.FullVersion,
+		DataDir:     a.DataDir,
+		DataKey:     a.Key,
+		ImgKey:      a.ImgKey,
+		ExtractedAt: &now,
+	})
+	if err != nil {
+		log.Debug().Err(err).Msg("保存密钥缓存失败")
+	}
+}
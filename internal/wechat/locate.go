@@ -0,0 +1,118 @@
+package wechat
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"time"
+)
+
+// DataDir 表示磁盘上找到的一个账号数据目录
+type DataDir struct {
+	Account  string // 规范化后的账号，3.x 与 4.x 的同一账号相同
+	Name     string // 目录名
+	Dir      string
+	Platform string
+	Version  int
+	Modified time.Time
+	Running  bool // 正在运行的微信使用该目录，见 DiscoverDataDirs
+}
+
+// 4.x 的账号目录名在微信号后附加 4 位后缀，如 wxid_abc_1a2b
+var v4Suffix = regexp.MustCompile(`_[0-9a-f]{4}$`)
+
+// 用于判断数据目录版本的标志文件
+var (
+	v3Markers = map[string]string{
+		"windows": filepath.Join("Msg", "Misc.db"),
+		"darwin":  filepath.Join("Message", "msg_0.db"),
+	}
+	v4Marker = filepath.Join("db_storage", "session", "session.db")
+)
+
+// NormalizeAccount 去掉 4.x 账号目录的后缀，使其与 3.x 的目录名一致
+func NormalizeAccount(name string, version int) string {
+	if version == 4 {
+		return v4Suffix.ReplaceAllString(name, "")
+	}
+	return name
+}
+
+// DefaultDataRoots 返回当前系统下 3.x 与 4.x 数据目录的默认上级目录
+func DefaultDataRoots() []string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil
+	}
+	switch runtime.GOOS {
+	case "windows":
+		docs := filepath.Join(home, "Documents")
+		return []string{
+			filepath.Join(docs, "WeChat Files"),
+			filepath.Join(docs, "xwechat_files"),
+		}
+	case "darwin":
+		roots := []string{
+			filepath.Join(home, "Library", "Containers", "com.tencent.xWeChat", "Data", "Documents", "xwechat_files"),
+		}
+		// 3.x 的账号目录位于版本号目录之下，如 2.0b4.0.9/<id>
+		v3, _ := filepath.Glob(filepath.Join(home, "Library", "Containers", "com.tencent.xinWeChat", "Data", "Library", "Application Support", "com.tencent.xinWeChat", "*"))
+		return append(roots, v3...)
+	}
+	return nil
+}
+
+// LocateDataDirs 在 roots 下查找 3.x 与 4.x 的账号数据目录，按账号、版本排序
+func LocateDataDirs(roots []string) []DataDir {
+	platform := runtime.GOOS
+	if _, ok := v3Markers[platform]; !ok {
+		platform = "windows"
+	}
+
+	var dirs []DataDir
+	seen := make(map[string]bool)
+	for _, root := range roots {
+		entries, err := os.ReadDir(root)
+		if err != nil {
+			continue
+		}
+		for _, e := range entries {
+			if !e.IsDir() {
+				continue
+			}
+			dir := filepath.Join(root, e.Name())
+			if seen[dir] {
+				continue
+			}
+			version := 0
+			var modified time.Time
+			if fi, err := os.Stat(filepath.Join(dir, v4Marker)); err == nil {
+				version, modified = 4, fi.ModTime()
+			} else if fi, err := os.Stat(filepath.Join(dir, v3Markers[platform])); err == nil {
+				version, modified = 3, fi.ModTime()
+			}
+			if version == 0 {
+				continue
+			}
+			seen[dir] = true
+			dirs = append(dirs, DataDir{
+				Account:  NormalizeAccount(e.Name(), version),
+				Name:     e.Name(),
+				Dir:      dir,
+				Platform: platform,
+				Version:  version,
+				Modified: modified,
+			})
+		}
+	}
+
+	sort.Slice(dirs, func(i, j int) bool {
+		if dirs[i].Account != dirs[j].Account {
+			return dirs[i].Account < dirs[j].Account
+		}
+		return dirs[i].Version < dirs[j].Version
+	})
+	return dirs
+}
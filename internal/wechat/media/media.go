@@ -0,0 +1,154 @@
+// Package media 批量解密微信图片目录中的 .dat 文件
+//
+// 3.x 的 FileStorage/MsgAttach/*/Image 使用单字节 XOR，4.x 的 msg/attach/*/Img
+// 使用 AES-ECB + XOR，解密算法本身由 pkg/util/dat2img 提供，这里负责密钥设置与目录遍历
+//
+// 另外负责语音转码（voice.go）与自定义表情的下载缓存（sticker.go）
+package media
+
+import (
+	"context"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/aspnmy/chatlog/internal/errors"
+	"github.com/aspnmy/chatlog/pkg/util/dat2img"
+)
+
+// Configure 设置 4.x 图片的 AES 密钥（imgKey），并从 dir 下的缩略图推算 XOR 密钥
+// imgKey 为空时只能解密 3.x 与 4.x 早期格式的图片
+func Configure(imgKey, dir string) error {
+	if imgKey != "" {
+		b, err := hex.DecodeString(imgKey)
+		if err != nil || len(b) != 16 {
+			return errors.InvalidArg("img-key")
+		}
+		dat2img.SetAesKey(imgKey)
+	}
+	if dir != "" {
+		if _, err := dat2img.ScanAndSetXorKey(dir); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Decode 解密 .dat 文件内容，返回图片数据与扩展名（不含点）
+func Decode(data []byte) ([]byte, string, error) {
+	return dat2img.Dat2Image(data)
+}
+
+// Options 目录转换选项
+type Options struct {
+	Workers    int  // 并发数，<= 0 时为 1
+	Overwrite  bool // 覆盖已存在的输出文件
+	SkipThumbs bool // 跳过 _t.dat 缩略图与 _h.dat 高清预览
+}
+
+// Stats 目录转换结果
+type Stats struct {
+	Converted int64
+	Skipped   int64 // 非 .dat 文件、缩略图及已存在的输出
+	Failed    int64
+}
+
+// Progress 每处理完一个 .dat 文件调用一次，err 不为空表示该文件解密失败
+type Progress func(path string, err error)
+
+// ConvertTree 将 src 下所有 .dat 文件解密后写入 dst，保留相对目录结构，
+// 文件名去掉 .dat 并按实际格式加上 .jpg/.png 等扩展名
+// 单个文件失败不会中断转换，只计入 Stats.Failed
+func ConvertTree(ctx context.Context, src, dst string, opts Options, progress Progress) (*Stats, error) {
+	if _, err := os.Stat(src); err != nil {
+		return nil, errors.StatFileFailed(src, err)
+	}
+	workers := max(opts.Workers, 1)
+
+	stats := &Stats{}
+	paths := make(chan string)
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for path := range paths {
+				ok, err := convertFile(src, dst, path, opts.Overwrite)
+				switch {
+				case err != nil:
+					atomic.AddInt64(&stats.Failed, 1)
+				case ok:
+					atomic.AddInt64(&stats.Converted, 1)
+				default:
+					atomic.AddInt64(&stats.Skipped, 1)
+				}
+				if progress != nil {
+					progress(path, err)
+				}
+			}
+		}()
+	}
+
+	err := filepath.WalkDir(src, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		name := strings.ToLower(d.Name())
+		if !strings.HasSuffix(name, ".dat") ||
+			opts.SkipThumbs && (strings.HasSuffix(name, "_t.dat") || strings.HasSuffix(name, "_h.dat")) {
+			atomic.AddInt64(&stats.Skipped, 1)
+			return nil
+		}
+		select {
+		case paths <- path:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+	close(paths)
+	wg.Wait()
+	return stats, err
+}
+
+// convertFile 解密单个文件，输出已存在且不覆盖时返回 false
+func convertFile(src, dst, path string, overwrite bool) (bool, error) {
+	rel, err := filepath.Rel(src, path)
+	if err != nil {
+		return false, err
+	}
+	base := filepath.Join(dst, rel[:len(rel)-len(filepath.Ext(rel))])
+	if !overwrite {
+		if matches, _ := filepath.Glob(globEscape(base) + ".*"); len(matches) > 0 {
+			return false, nil
+		}
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return false, errors.ReadFileFailed(path, err)
+	}
+	out, ext, err := Decode(data)
+	if err != nil {
+		return false, err
+	}
+	if err := os.MkdirAll(filepath.Dir(base), 0755); err != nil {
+		return false, err
+	}
+	if err := os.WriteFile(base+"."+ext, out, 0644); err != nil {
+		return false, errors.WriteOutputFailed(err)
+	}
+	return true, nil
+}
+
+// globEscape 转义路径中的通配符，避免文件名中的 [ ] 等字符影响 Glob
+func globEscape(path string) string {
+	r := strings.NewReplacer(`*`, `[*]`, `?`, `[?]`, `[`, `[[]`)
+	return r.Replace(path)
+}
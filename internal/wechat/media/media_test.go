@@ -0,0 +1,59 @@
+package media
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func xorFile(t *testing.T, path string, data []byte, key byte) {
+	t.Helper()
+	out := make([]byte, len(data))
+	for i := range data {
+		out[i] = data[i] ^ key
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, out, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestConvertTree(t *testing.T) {
+	src, dst := t.TempDir(), t.TempDir()
+	jpg := []byte{0xFF, 0xD8, 0xFF, 0xE0, 0x01, 0x02, 0xFF, 0xD9}
+	png := []byte{0x89, 0x50, 0x4E, 0x47, 0x0D, 0x0A, 0x1A, 0x0A}
+	xorFile(t, filepath.Join(src, "a", "2024-01", "img.dat"), jpg, 0x5A)
+	xorFile(t, filepath.Join(src, "b", "pic.dat"), png, 0x33)
+	xorFile(t, filepath.Join(src, "b", "pic_t.dat"), jpg, 0x33)
+	xorFile(t, filepath.Join(src, "b", "bad.dat"), []byte{0, 1, 2, 3, 4}, 0x11)
+	if err := os.WriteFile(filepath.Join(src, "note.txt"), []byte("x"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	stats, err := ConvertTree(context.Background(), src, dst, Options{Workers: 2, SkipThumbs: true}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Converted != 2 || stats.Failed != 1 || stats.Skipped != 2 {
+		t.Fatalf("stats = %+v", stats)
+	}
+	got, err := os.ReadFile(filepath.Join(dst, "a", "2024-01", "img.jpg"))
+	if err != nil || string(got) != string(jpg) {
+		t.Fatalf("img.jpg = %x, %v", got, err)
+	}
+	if _, err := os.Stat(filepath.Join(dst, "b", "pic.png")); err != nil {
+		t.Fatal(err)
+	}
+
+	// 再次转换时已存在的输出被跳过
+	stats, err = ConvertTree(context.Background(), src, dst, Options{SkipThumbs: true}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Converted != 0 || stats.Skipped != 4 {
+		t.Fatalf("second run stats = %+v", stats)
+	}
+}
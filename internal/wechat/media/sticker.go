@@ -0,0 +1,250 @@
+package media
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	neturl "net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/aspnmy/chatlog/internal/errors"
+	"github.com/aspnmy/chatlog/pkg/util"
+)
+
+// StickerMaxSize 下载自定义表情时接受的最大文件大小
+const StickerMaxSize = 10 << 20
+
+// Sticker 动画表情消息中的下载信息，cdnurl 为未加密的地址，encrypturl 的内容使用 aeskey 以 AES-CBC 加密
+type Sticker struct {
+	MD5        string
+	URL        string
+	EncryptURL string
+	AESKey     string
+}
+
+var stickerMD5 = regexp.MustCompile(`^[0-9a-f]{32}$`)
+
+// StickerCache 下载并缓存自定义表情，文件保存为 <dir>/<md5>.<ext>
+// 同一表情下载失败后本次运行中不再重试，离线或下载失败时只使用已缓存的文件
+type StickerCache struct {
+	dir    string
+	client *http.Client
+
+	mu     sync.Mutex
+	failed map[string]bool
+}
+
+// NewStickerCache 创建缓存目录为 dir 的表情缓存，目录在第一次保存表情时创建
+func NewStickerCache(dir string) *StickerCache {
+	return &StickerCache{
+		dir:    dir,
+		client: stickerClient(),
+		failed: make(map[string]bool),
+	}
+}
+
+// Lookup 返回已缓存的表情文件路径，未缓存时返回空
+func (c *StickerCache) Lookup(md5 string) string {
+	md5 = strings.ToLower(md5)
+	if !stickerMD5.MatchString(md5) {
+		return ""
+	}
+	matches, _ := filepath.Glob(filepath.Join(c.dir, md5+".*"))
+	for _, path := range matches {
+		if !strings.HasSuffix(path, ".tmp") {
+			return path
+		}
+	}
+	return ""
+}
+
+// Get 返回表情文件路径，未缓存时先下载 cdnurl，失败后下载 encrypturl 并解密
+// offline 为 true 时不下载，未缓存的表情返回 ErrMediaNotFound
+func (c *StickerCache) Get(ctx context.Context, s Sticker, offline bool) (string, error) {
+	md5 := strings.ToLower(s.MD5)
+	if !stickerMD5.MatchString(md5) {
+		return "", errors.InvalidArg("md5")
+	}
+	if path := c.Lookup(md5); path != "" {
+		return path, nil
+	}
+	c.mu.Lock()
+	failed := c.failed[md5]
+	c.mu.Unlock()
+	if offline || failed {
+		return "", errors.ErrMediaNotFound
+	}
+
+	data, ext, err := c.download(ctx, s)
+	if err != nil {
+		c.mu.Lock()
+		c.failed[md5] = true
+		c.mu.Unlock()
+		return "", err
+	}
+	if err := util.PrepareDir(c.dir); err != nil {
+		return "", err
+	}
+	path := filepath.Join(c.dir, md5+"."+ext)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return "", err
+	}
+	return path, nil
+}
+
+func (c *StickerCache) download(ctx context.Context, s Sticker) ([]byte, string, error) {
+	var errs []error
+	if s.URL != "" {
+		data, err := c.fetch(ctx, s.URL)
+		if err == nil {
+			if ext := StickerExt(data); ext != "" {
+				return data, ext, nil
+			}
+			err = fmt.Errorf("not an image")
+		}
+		errs = append(errs, fmt.Errorf("cdnurl: %w", err))
+	}
+	if s.EncryptURL != "" && s.AESKey != "" {
+		data, err := c.fetch(ctx, s.EncryptURL)
+		if err == nil {
+			data, err = DecryptSticker(data, s.AESKey)
+		}
+		if err == nil {
+			if ext := StickerExt(data); ext != "" {
+				return data, ext, nil
+			}
+			err = fmt.Errorf("not an image")
+		}
+		errs = append(errs, fmt.Errorf("encrypturl: %w", err))
+	}
+	if len(errs) == 0 {
+		return nil, "", errors.ErrMediaNotFound
+	}
+	return nil, "", fmt.Errorf("download sticker %s: %v", s.MD5, errs)
+}
+
+// stickerClient 返回下载表情使用的 HTTP 客户端，表情地址来自消息内容，
+// 只连接公网地址，拒绝回环、私有与链路本地地址，重定向后的地址同样检查；不使用代理，代理会绕过地址检查
+func stickerClient() *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = (&net.Dialer{
+		Timeout: 30 * time.Second,
+		Control: publicOnly,
+	}).DialContext
+	return &http.Client{
+		Timeout:   30 * time.Second,
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 10 {
+				return fmt.Errorf("stopped after 10 redirects")
+			}
+			return checkScheme(req.URL)
+		},
+	}
+}
+
+// publicOnly 在建立连接前检查解析后的地址，只允许公网地址
+func publicOnly(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() || ip.IsMulticast() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() || sharedAddress.Contains(ip) {
+		return fmt.Errorf("refusing to connect to non-public address %s", host)
+	}
+	return nil
+}
+
+// sharedAddress 运营商级 NAT 使用的地址段 100.64.0.0/10，同样不是公网地址
+var sharedAddress = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// checkScheme 只允许 http 与 https 地址
+func checkScheme(u *neturl.URL) error {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("unsupported url scheme %q", u.Scheme)
+	}
+	return nil
+}
+
+func (c *StickerCache) fetch(ctx context.Context, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkScheme(req.URL); err != nil {
+		return nil, err
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("http status %d", resp.StatusCode)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, StickerMaxSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > StickerMaxSize {
+		return nil, fmt.Errorf("larger than %d bytes", StickerMaxSize)
+	}
+	return data, nil
+}
+
+// DecryptSticker 解密 encrypturl 下载的表情，密钥为十六进制的 aeskey，IV 与密钥相同，PKCS#7 填充
+func DecryptSticker(data []byte, aesKey string) ([]byte, error) {
+	key, err := hex.DecodeString(aesKey)
+	if err != nil || len(key) != aes.BlockSize {
+		return nil, errors.InvalidArg("aeskey")
+	}
+	if len(data) == 0 || len(data)%aes.BlockSize != 0 {
+		return nil, fmt.Errorf("invalid encrypted size %d", len(data))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]byte, len(data))
+	cipher.NewCBCDecrypter(block, key).CryptBlocks(out, data)
+	pad := int(out[len(out)-1])
+	if pad == 0 || pad > aes.BlockSize || !bytes.Equal(out[len(out)-pad:], bytes.Repeat([]byte{byte(pad)}, pad)) {
+		return nil, fmt.Errorf("invalid padding")
+	}
+	return out[:len(out)-pad], nil
+}
+
+// StickerExt 按文件头返回表情的扩展名，不是图片时返回空
+func StickerExt(data []byte) string {
+	switch http.DetectContentType(data) {
+	case "image/gif":
+		return "gif"
+	case "image/png":
+		return "png"
+	case "image/jpeg":
+		return "jpg"
+	case "image/webp":
+		return "webp"
+	}
+	return ""
+}
@@ -0,0 +1,83 @@
+package media
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestStickerCache(t *testing.T) {
+	gif := []byte("GIF89a\x01\x00\x01\x00\x00\x00\x00;")
+	key := []byte("0123456789abcdef")
+	pad := aes.BlockSize - len(gif)%aes.BlockSize
+	enc := append(append([]byte{}, gif...), bytes.Repeat([]byte{byte(pad)}, pad)...)
+	block, _ := aes.NewCipher(key)
+	cipher.NewCBCEncrypter(block, key).CryptBlocks(enc, enc)
+
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.URL.Path == "/enc" {
+			w.Write(enc)
+			return
+		}
+		http.NotFound(w, r)
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+
+	// 默认的客户端不连接本机与内网地址，也不接受 http 与 https 以外的地址
+	if _, err := NewStickerCache(dir).fetch(context.Background(), srv.URL+"/enc"); err == nil {
+		t.Error("fetched a sticker from a loopback address")
+	}
+	if _, err := NewStickerCache(dir).fetch(context.Background(), "file:///etc/passwd"); err == nil {
+		t.Error("fetched a sticker from a file url")
+	}
+
+	// 测试服务器在本机，使用不检查地址的客户端
+	c := NewStickerCache(dir)
+	c.client = srv.Client()
+	s := Sticker{MD5: "0123456789ABCDEF0123456789abcdef", URL: srv.URL + "/expired", EncryptURL: srv.URL + "/enc", AESKey: hex.EncodeToString(key)}
+	if _, err := c.Get(context.Background(), s, true); err == nil {
+		t.Fatal("offline Get of an uncached sticker should fail")
+	}
+	path, err := c.Get(context.Background(), s, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if path != filepath.Join(dir, "0123456789abcdef0123456789abcdef.gif") {
+		t.Errorf("path = %s", path)
+	}
+	if data, _ := os.ReadFile(path); !bytes.Equal(data, gif) {
+		t.Errorf("cached sticker = %q, want %q", data, gif)
+	}
+
+	// 已缓存的表情离线也可以使用，不再下载
+	n := requests
+	if got, err := NewStickerCache(dir).Get(context.Background(), s, true); err != nil || got != path || requests != n {
+		t.Errorf("cached Get = %s, %v, %d requests", got, err, requests-n)
+	}
+
+	// 下载失败的表情不重复请求
+	bad := Sticker{MD5: "ffffffffffffffffffffffffffffffff", URL: srv.URL + "/missing"}
+	for i := 0; i < 2; i++ {
+		if _, err := c.Get(context.Background(), bad, false); err == nil {
+			t.Fatal("Get of a missing sticker should fail")
+		}
+	}
+	if requests != n+1 {
+		t.Errorf("missing sticker requested %d times, want 1", requests-n)
+	}
+
+	if _, err := c.Get(context.Background(), Sticker{MD5: "../x"}, false); err == nil {
+		t.Error("invalid md5 should be rejected")
+	}
+}
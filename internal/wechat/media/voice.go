@@ -0,0 +1,54 @@
+package media
+
+import (
+	"bytes"
+
+	"github.com/aspnmy/chatlog/internal/errors"
+	"github.com/aspnmy/chatlog/pkg/util/silk"
+)
+
+// 语音导出格式
+const (
+	VoiceMP3  = "mp3"
+	VoiceWAV  = "wav"
+	VoiceSilk = "silk" // 保存数据库中的原始数据，不转码
+)
+
+// VoiceFormats 支持的语音导出格式
+var VoiceFormats = []string{VoiceMP3, VoiceWAV, VoiceSilk}
+
+// VoiceExt 返回语音原始数据的扩展名（不含点），微信语音为 silk 编码，早期版本的部分语音为 amr
+func VoiceExt(data []byte) string {
+	if bytes.HasPrefix(data, []byte("#!AMR")) {
+		return "amr"
+	}
+	return VoiceSilk
+}
+
+// ConvertVoice 将数据库中的语音数据转换为 format 格式，返回转换后的数据与扩展名（不含点）
+// amr 数据不转码，按原格式返回；silk 解码依赖 cgo，silk.Supported 为 false 的构建返回错误
+func ConvertVoice(data []byte, format string) ([]byte, string, error) {
+	return ConvertVoiceWithOptions(data, format, silk.DefaultOptions)
+}
+
+// ConvertVoiceWithOptions 同 ConvertVoice，format 为 mp3 时按 mp3 参数编码
+func ConvertVoiceWithOptions(data []byte, format string, mp3 silk.Options) ([]byte, string, error) {
+	if format == "" {
+		format = VoiceMP3
+	}
+	if ext := VoiceExt(data); ext != VoiceSilk {
+		return data, ext, nil
+	}
+	switch format {
+	case VoiceMP3:
+		out, err := silk.Silk2MP3WithOptions(data, mp3)
+		return out, VoiceMP3, err
+	case VoiceWAV:
+		out, err := silk.Silk2WAV(data)
+		return out, VoiceWAV, err
+	case VoiceSilk:
+		return data, VoiceSilk, nil
+	default:
+		return nil, "", errors.InvalidArg("voice-format")
+	}
+}
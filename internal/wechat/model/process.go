@@ -9,6 +9,8 @@ type Process struct {
 	Status      string
 	DataDir     string
 	AccountName string
+	Sandbox     string // 运行微信的沙盒，如 Sandboxie 的沙盒名，不在沙盒中时为空
+	SandboxRoot string // 沙盒中重定向文件的根目录，未知时为空
 }
 
 // 平台常量定义
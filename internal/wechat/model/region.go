@@ -0,0 +1,28 @@
+package model
+
+import "fmt"
+
+// MemoryRegion 是提取密钥时会扫描的一段进程内存
+type MemoryRegion struct {
+	Start   uint64
+	Size    uint64
+	Protect string // 访问权限，例如 rw-
+	Module  string // 所属模块，私有内存为空
+}
+
+// String 返回起始地址，属于模块时附带模块名，用于进度显示
+func (r MemoryRegion) String() string {
+	if r.Module != "" {
+		return fmt.Sprintf("0x%X %s", r.Start, r.Module)
+	}
+	return fmt.Sprintf("0x%X", r.Start)
+}
+
+// RegionsSize 返回内存区域的总大小
+func RegionsSize(regions []MemoryRegion) uint64 {
+	var total uint64
+	for _, r := range regions {
+		total += r.Size
+	}
+	return total
+}
@@ -3,6 +3,7 @@ package process
 import (
 	"github.com/aspnmy/chatlog/internal/wechat/model"
 	"github.com/aspnmy/chatlog/internal/wechat/process/darwin"
+	"github.com/aspnmy/chatlog/internal/wechat/process/linux"
 	"github.com/aspnmy/chatlog/internal/wechat/process/windows"
 )
 
@@ -18,6 +19,9 @@ func NewDetector(platform string) Detector {
 		return windows.NewDetector()
 	case "darwin":
 		return darwin.NewDetector()
+	case "linux":
+		// Linux 上只支持通过 Wine 运行的 Windows 版微信
+		return linux.NewDetector()
 	default:
 		// 默认返回一个空实现
 		return &nullDetector{}
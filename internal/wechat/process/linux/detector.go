@@ -0,0 +1,126 @@
+package linux
+
+import (
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	"github.com/shirou/gopsutil/v4/process"
+
+	"github.com/aspnmy/chatlog/internal/wechat/model"
+)
+
+const (
+	V3ProcessName = "WeChat"
+	V4ProcessName = "Weixin"
+	V3DBFile      = "Msg/Misc.db"
+	V4DBFile      = "db_storage/session/session.db"
+)
+
+// Detector 检测通过 Wine 运行的 Windows 版微信
+// 数据格式与 Windows 相同，因此进程的平台记为 windows
+type Detector struct{}
+
+// NewDetector 创建一个新的 Linux 检测器
+func NewDetector() *Detector {
+	return &Detector{}
+}
+
+// FindProcesses 查找所有微信进程并返回它们的信息
+func (d *Detector) FindProcesses() ([]*model.Process, error) {
+	processes, err := process.Processes()
+	if err != nil {
+		log.Err(err).Msg("获取进程列表失败")
+		return nil, err
+	}
+
+	var result []*model.Process
+	for _, p := range processes {
+		// Wine 进程的名称为 Windows 可执行文件名，例如 WeChat.exe
+		name, err := p.Name()
+		if err != nil || !strings.HasSuffix(strings.ToLower(name), ".exe") {
+			continue
+		}
+		name = name[:len(name)-len(".exe")]
+		if name != V3ProcessName && name != V4ProcessName {
+			continue
+		}
+
+		// v4 存在同名进程，需要继续判断 cmdline
+		if name == V4ProcessName {
+			cmdline, err := p.Cmdline()
+			if err != nil {
+				log.Err(err).Msg("获取进程命令行失败")
+				continue
+			}
+			if strings.Contains(cmdline, "--") {
+				continue
+			}
+		}
+
+		result = append(result, d.getProcessInfo(p, name))
+	}
+
+	return result, nil
+}
+
+// getProcessInfo 获取微信进程的详细信息
+// Linux 上无法读取 PE 文件的版本资源，按进程名区分 3.x 与 4.x
+func (d *Detector) getProcessInfo(p *process.Process, name string) *model.Process {
+	procInfo := &model.Process{
+		PID:      uint32(p.Pid),
+		Status:   model.StatusOffline,
+		Platform: model.PlatformWindows,
+		Version:  3,
+	}
+	if name == V4ProcessName {
+		procInfo.Version = 4
+	}
+
+	// Wine 进程的第一个参数为 Windows 路径，/proc/<pid>/exe 指向的是 wine 本身
+	if args, err := p.CmdlineSlice(); err == nil && len(args) > 0 {
+		procInfo.ExePath = args[0]
+	}
+
+	if err := initializeProcessInfo(p, procInfo); err != nil {
+		log.Err(err).Msg("初始化进程信息失败")
+	}
+
+	return procInfo
+}
+
+// initializeProcessInfo 通过打开的数据库文件获取数据目录和账户名
+func initializeProcessInfo(p *process.Process, info *model.Process) error {
+	files, err := p.OpenFiles()
+	if err != nil {
+		log.Err(err).Msgf("获取进程 %d 的打开文件失败", p.Pid)
+		return err
+	}
+
+	dbPath := V3DBFile
+	if info.Version == 4 {
+		dbPath = V4DBFile
+	}
+
+	for _, f := range files {
+		if !strings.HasSuffix(f.Path, dbPath) {
+			continue
+		}
+		parts := strings.Split(f.Path, "/")
+		if len(parts) < 4 {
+			log.Debug().Msg("无效的文件路径: " + f.Path)
+			continue
+		}
+
+		info.Status = model.StatusOnline
+		if info.Version == 4 {
+			info.DataDir = strings.Join(parts[:len(parts)-3], "/")
+			info.AccountName = parts[len(parts)-4]
+		} else {
+			info.DataDir = strings.Join(parts[:len(parts)-2], "/")
+			info.AccountName = parts[len(parts)-3]
+		}
+		return nil
+	}
+
+	return nil
+}
@@ -1,6 +1,7 @@
 package windows
 
 import (
+	"os"
 	"strings"
 
 	"github.com/rs/zerolog/log"
@@ -97,5 +98,11 @@ func (d *Detector) getProcessInfo(p *process.Process) (*model.Process, error) {
 		// 即使初始化失败也返回部分信息
 	}
 
+	home, _ := os.UserHomeDir()
+	detectSandbox(procInfo, home)
+	if procInfo.Sandbox != "" {
+		log.Debug().Msgf("进程 %d 运行在沙盒 %s 中", p.Pid, procInfo.Sandbox)
+	}
+
 	return procInfo, nil
 }
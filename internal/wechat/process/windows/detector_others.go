@@ -10,3 +10,7 @@ import (
 func initializeProcessInfo(p *process.Process, info *model.Process) error {
 	return nil
 }
+
+func loadsModule(pid uint32, name string) bool {
+	return false
+}
@@ -6,6 +6,7 @@ import (
 
 	"github.com/rs/zerolog/log"
 	"github.com/shirou/gopsutil/v4/process"
+	"golang.org/x/sys/windows"
 
 	"github.com/aspnmy/chatlog/internal/wechat/model"
 )
@@ -46,3 +47,20 @@ func initializeProcessInfo(p *process.Process, info *model.Process) error {
 
 	return nil
 }
+
+// loadsModule 判断进程是否加载了指定模块，模块名不区分大小写，无法读取模块列表时返回 false
+func loadsModule(pid uint32, name string) bool {
+	snapshot, err := windows.CreateToolhelp32Snapshot(windows.TH32CS_SNAPMODULE|windows.TH32CS_SNAPMODULE32, pid)
+	if err != nil {
+		return false
+	}
+	defer windows.CloseHandle(snapshot)
+
+	module := windows.ModuleEntry32{Size: uint32(windows.SizeofModuleEntry32)}
+	for err := windows.Module32First(snapshot, &module); err == nil; err = windows.Module32Next(snapshot, &module) {
+		if strings.EqualFold(windows.UTF16ToString(module.Module[:]), name) {
+			return true
+		}
+	}
+	return false
+}
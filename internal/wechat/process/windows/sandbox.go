@@ -0,0 +1,62 @@
+package windows
+
+import (
+	"strings"
+
+	"github.com/aspnmy/chatlog/internal/wechat/model"
+)
+
+const (
+	// SandboxieDLL Sandboxie 注入到沙盒中每个进程的模块
+	SandboxieDLL = "SbieDll.dll"
+	// SandboxieName 无法从路径得知沙盒名时使用的名称
+	SandboxieName = "Sandboxie"
+)
+
+// ParseSandboxPath 解析 Sandboxie 重定向到沙盒目录中的路径，返回沙盒根目录、沙盒名与沙盒外对应的路径
+// 沙盒目录的结构为 <根目录>\drive\<盘符>\... 与 <根目录>\user\current\...（当前用户目录，对应 home），
+// 默认根目录为 C:\Sandbox\<用户名>\<沙盒名>
+func ParseSandboxPath(path, home string) (root, box, orig string, ok bool) {
+	parts := strings.Split(path, `\`)
+	for i := 1; i+2 < len(parts); i++ {
+		var prefix string
+		switch {
+		case strings.EqualFold(parts[i], "drive") && len(parts[i+1]) == 1:
+			prefix = strings.ToUpper(parts[i+1]) + ":"
+		case strings.EqualFold(parts[i], "user") && strings.EqualFold(parts[i+1], "current") && home != "":
+			prefix = strings.TrimRight(home, `\`)
+		default:
+			continue
+		}
+		rest := parts[i+2:]
+		return strings.Join(parts[:i], `\`), parts[i-1], prefix + `\` + strings.Join(rest, `\`), true
+	}
+	return "", "", "", false
+}
+
+// SandboxedPath 返回沙盒外的路径 path 在沙盒 root 中重定向后的路径，home 下的路径位于 user\current 中
+func SandboxedPath(root, path, home string) string {
+	root = strings.TrimRight(root, `\`)
+	home = strings.TrimRight(home, `\`)
+	if home != "" && len(path) > len(home) && strings.EqualFold(path[:len(home)], home) && path[len(home)] == '\\' {
+		return root + `\user\current` + path[len(home):]
+	}
+	if len(path) >= 2 && path[1] == ':' {
+		return root + `\drive\` + strings.ToUpper(path[:1]) + path[2:]
+	}
+	return ""
+}
+
+// detectSandbox 判断进程是否运行在 Sandboxie 沙盒中：数据目录或可执行文件位于沙盒目录中，
+// 或进程加载了 SbieDll.dll；沙盒中的进程也能读取沙盒外的文件，因此路径不在沙盒中时仍需检查模块
+func detectSandbox(info *model.Process, home string) {
+	for _, path := range []string{info.DataDir, info.ExePath} {
+		if root, box, _, ok := ParseSandboxPath(path, home); ok {
+			info.Sandbox, info.SandboxRoot = box, root
+			return
+		}
+	}
+	if loadsModule(info.PID, SandboxieDLL) {
+		info.Sandbox = SandboxieName
+	}
+}
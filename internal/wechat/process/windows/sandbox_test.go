@@ -0,0 +1,27 @@
+package windows
+
+import "testing"
+
+func TestParseSandboxPath(t *testing.T) {
+	home := `C:\Users\alice`
+	for _, tc := range []struct {
+		path, root, box, orig string
+		ok                    bool
+	}{
+		{`C:\Sandbox\alice\DefaultBox\drive\D\WeChat Files\wxid_a\Msg\Misc.db`, `C:\Sandbox\alice\DefaultBox`, "DefaultBox", `D:\WeChat Files\wxid_a\Msg\Misc.db`, true},
+		{`C:\Sandbox\alice\Box2\user\current\Documents\xwechat_files\wxid_a_1a2b`, `C:\Sandbox\alice\Box2`, "Box2", `C:\Users\alice\Documents\xwechat_files\wxid_a_1a2b`, true},
+		{`C:\Users\alice\Documents\xwechat_files\wxid_a_1a2b`, "", "", "", false},
+		// 普通目录中的 drive 后不是盘符
+		{`D:\drive\backup\WeChat Files`, "", "", "", false},
+	} {
+		root, box, orig, ok := ParseSandboxPath(tc.path, home)
+		if root != tc.root || box != tc.box || orig != tc.orig || ok != tc.ok {
+			t.Errorf("ParseSandboxPath(%s) = %q %q %q %v", tc.path, root, box, orig, ok)
+		}
+		if tc.ok {
+			if got := SandboxedPath(root, orig, home); got != tc.path {
+				t.Errorf("SandboxedPath(%s) = %s, want %s", orig, got, tc.path)
+			}
+		}
+	}
+}
@@ -2,12 +2,17 @@ package wechat
 
 import (
 	"context"
+	"encoding/hex"
 	"os"
 
 	"github.com/aspnmy/chatlog/internal/errors"
 	"github.com/aspnmy/chatlog/internal/wechat/decrypt"
+	"github.com/aspnmy/chatlog/internal/wechat/decrypt/common"
 	"github.com/aspnmy/chatlog/internal/wechat/key"
 	"github.com/aspnmy/chatlog/internal/wechat/model"
+	"github.com/aspnmy/chatlog/pkg/trace"
+
+	"github.com/rs/zerolog/log"
 )
 
 // Account 表示一个微信账号
@@ -19,6 +24,7 @@ type Account struct {
 	DataDir     string
 	Key         string
 	ImgKey      string
+	Cipher      *common.CipherInfo // 验证密钥时使用的加密参数
 	PID         uint32
 	ExePath     string
 	Status      string
@@ -70,6 +76,11 @@ func (a *Account) GetKey(ctx context.Context) (string, string, error) {
 		return a.Key, a.ImgKey, nil
 	}
 
+	// 优先使用验证通过的缓存密钥，微信未运行时也可以使用
+	if a.loadCachedKey() {
+		return a.Key, a.ImgKey, nil
+	}
+
 	// 刷新进程状态
 	if err := a.RefreshStatus(); err != nil {
 		return "", "", errors.RefreshProcessStatusFailed(err)
@@ -80,16 +91,17 @@ func (a *Account) GetKey(ctx context.Context) (string, string, error) {
 		return "", "", errors.WeChatAccountNotOnline(a.Name)
 	}
 
-	// 创建密钥提取器 - 使用新的接口，传入平台和版本信息
-	extractor, err := key.NewExtractor(a.Platform, a.Version)
+	process, err := GetProcess(a.Name)
 	if err != nil {
 		return "", "", err
 	}
 
-	process, err := GetProcess(a.Name)
+	// 创建密钥提取器，根据进程加载的模块等自动判断 3.x 或 4.x
+	extractor, err := key.NewExtractorFor(process)
 	if err != nil {
 		return "", "", err
 	}
+	a.Version = process.Version
 
 	validator, err := decrypt.NewValidator(process.Platform, process.Version, process.DataDir)
 	if err != nil {
@@ -97,24 +109,65 @@ func (a *Account) GetKey(ctx context.Context) (string, string, error) {
 	}
 
 	extractor.SetValidate(validator)
+	key.SetProgress(ctx, extractor)
+
+	// 优先执行该版本历史上找到过密钥的搜索策略
+	key.RankExtractor(extractor, a.Platform, a.Version, a.FullVersion)
 
 	// 提取密钥
+	ctx, span := trace.Start(ctx, "key.extract")
+	span.SetAttr("platform", a.Platform).SetAttr("version", a.Version).SetAttr("pid", int(process.PID))
 	dataKey, imgKey, err := extractor.Extract(ctx, process)
+	span.SetError(err).End()
 	if err != nil {
 		return "", "", err
 	}
+	if err := key.RecordExtractor(extractor, a.Platform, a.Version, a.FullVersion); err != nil {
+		log.Debug().Err(err).Msg("保存搜索策略命中统计失败")
+	}
 
 	if dataKey != "" {
 		a.Key = dataKey
+		// 记录验证通过的数据库文件与加密参数，之后解密使用相同的参数
+		if b, err := hex.DecodeString(dataKey); err == nil {
+			if info, ok := validator.ValidateWithInfo(b); ok {
+				a.Cipher = info
+				log.Info().Msgf("key validated: %s", info)
+			}
+		}
 	}
 
 	if imgKey != "" {
 		a.ImgKey = imgKey
 	}
+	a.saveCachedKey()
 
 	return dataKey, imgKey, nil
 }
 
+// Regions 返回提取密钥时会扫描的内存区域，用于诊断找不到密钥的原因
+func (a *Account) Regions() ([]model.MemoryRegion, error) {
+	proc := &model.Process{
+		PID:         a.PID,
+		ExePath:     a.ExePath,
+		Platform:    a.Platform,
+		Version:     a.Version,
+		FullVersion: a.FullVersion,
+		Status:      a.Status,
+		DataDir:     a.DataDir,
+		AccountName: a.Name,
+	}
+	extractor, err := key.NewExtractorFor(proc)
+	if err != nil {
+		return nil, err
+	}
+	lister, ok := extractor.(key.RegionLister)
+	if !ok {
+		return nil, errors.PlatformUnsupported(a.Platform, a.Version)
+	}
+	return lister.Regions(proc)
+}
+
 // DecryptDatabase 解密数据库
 func (a *Account) DecryptDatabase(ctx context.Context, dbPath, outputPath string) error {
 	// 获取密钥
@@ -124,7 +177,7 @@ func (a *Account) DecryptDatabase(ctx context.Context, dbPath, outputPath string
 	}
 
 	// 创建解密器 - 传入平台信息和版本
-	decryptor, err := decrypt.NewDecryptor(a.Platform, a.Version)
+	decryptor, err := decrypt.NewDecryptorWithCipher(a.Platform, a.Version, a.Cipher)
 	if err != nil {
 		return err
 	}
@@ -6,7 +6,6 @@ import (
 	"encoding/hex"
 	"fmt"
 	"regexp"
-	"sort"
 	"strings"
 	"time"
 
@@ -244,10 +243,10 @@ func (ds *DataSource) GetMessages(ctx context.Context, startTime, endTime time.T
 
 		// 构建查询条件
 		query := fmt.Sprintf(`
-			SELECT msgCreateTime, msgContent, messageType, mesDes
+			SELECT mesLocalID, IFNULL(mesSvrID, 0), msgCreateTime, msgContent, messageType, mesDes, IFNULL(msgStatus, 0)
 			FROM %s 
 			WHERE msgCreateTime >= ? AND msgCreateTime <= ? 
-			ORDER BY msgCreateTime ASC
+			ORDER BY msgCreateTime ASC, mesLocalID ASC
 		`, tableName)
 
 		// 执行查询
@@ -265,10 +264,13 @@ func (ds *DataSource) GetMessages(ctx context.Context, startTime, endTime time.T
 		for rows.Next() {
 			var msg model.MessageDarwinV3
 			err := rows.Scan(
+				&msg.MesLocalID,
+				&msg.MesSvrID,
 				&msg.MsgCreateTime,
 				&msg.MsgContent,
 				&msg.MessageType,
 				&msg.MesDes,
+				&msg.MsgStatus,
 			)
 			if err != nil {
 				rows.Close()
@@ -304,15 +306,13 @@ func (ds *DataSource) GetMessages(ctx context.Context, startTime, endTime time.T
 			// 通过所有过滤条件，保留此消息
 			filteredMessages = append(filteredMessages, message)
 
-			// 检查是否已经满足分页处理数量
-			if limit > 0 && len(filteredMessages) >= offset+limit {
+			// 检查是否已经满足分页处理数量，多个 talker 时其他 talker 可能有更早的消息，需要全部读取后再分页
+			if limit > 0 && len(talkers) == 1 && len(filteredMessages) >= offset+limit {
 				// 已经获取了足够的消息，可以提前返回
 				rows.Close()
 
-				// 对所有消息按时间排序
-				sort.Slice(filteredMessages, func(i, j int) bool {
-					return filteredMessages[i].Seq < filteredMessages[j].Seq
-				})
+				// 对所有消息按全序排序，见 model.SortMessages
+				model.SortMessages(filteredMessages)
 
 				// 处理分页
 				if offset >= len(filteredMessages) {
@@ -328,11 +328,8 @@ func (ds *DataSource) GetMessages(ctx context.Context, startTime, endTime time.T
 		rows.Close()
 	}
 
-	// 对所有消息按时间排序
-	// FIXME 不同 talker 需要使用 Time 排序
-	sort.Slice(filteredMessages, func(i, j int) bool {
-		return filteredMessages[i].Time.Before(filteredMessages[j].Time)
-	})
+	// 对所有消息按全序排序，见 model.SortMessages
+	model.SortMessages(filteredMessages)
 
 	// 处理分页
 	if limit > 0 {
@@ -349,6 +346,132 @@ func (ds *DataSource) GetMessages(ctx context.Context, startTime, endTime time.T
 	return filteredMessages, nil
 }
 
+// GetMessageCounts 按天统计消息数量，通过 GROUP BY 聚合，不读取消息内容
+func (ds *DataSource) GetMessageCounts(ctx context.Context, talker string, startTime, endTime time.Time) (map[string]int, error) {
+	if talker == "" {
+		return nil, errors.ErrTalkerEmpty
+	}
+
+	_talkerMd5Bytes := md5.Sum([]byte(talker))
+	talkerMd5 := hex.EncodeToString(_talkerMd5Bytes[:])
+	counts := make(map[string]int)
+	dbPath, ok := ds.talkerDBMap[talkerMd5]
+	if !ok {
+		return counts, nil
+	}
+	db, err := ds.dbm.OpenDB(dbPath)
+	if err != nil {
+		return nil, err
+	}
+
+	query := fmt.Sprintf(`
+		SELECT date(msgCreateTime, 'unixepoch', 'localtime') AS day, COUNT(*)
+		FROM %s
+		WHERE msgCreateTime >= ? AND msgCreateTime <= ?
+		GROUP BY day
+	`, "Chat_"+talkerMd5)
+
+	rows, err := db.QueryContext(ctx, query, startTime.Unix(), endTime.Unix())
+	if err != nil {
+		if strings.Contains(err.Error(), "no such table") {
+			return counts, nil
+		}
+		return nil, errors.QueryFailed("", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var day string
+		var count int
+		if err := rows.Scan(&day, &count); err != nil {
+			return nil, errors.ScanRowFailed(err)
+		}
+		counts[day] += count
+	}
+	return counts, rows.Err()
+}
+
+// GetMessageContext 获取消息上下文
+// 通过单次查询定位 seq 对应的消息，并按 (msgCreateTime, mesLocalID) 同时取出之前与之后的消息
+func (ds *DataSource) GetMessageContext(ctx context.Context, talker string, seq int64, before, after int) ([]*model.Message, error) {
+	if talker == "" {
+		return nil, errors.ErrTalkerEmpty
+	}
+
+	_talkerMd5Bytes := md5.Sum([]byte(talker))
+	talkerMd5 := hex.EncodeToString(_talkerMd5Bytes[:])
+	dbPath, ok := ds.talkerDBMap[talkerMd5]
+	if !ok {
+		return nil, errors.TalkerNotFound(talker)
+	}
+	db, err := ds.dbm.OpenDB(dbPath)
+	if err != nil {
+		return nil, err
+	}
+
+	query := fmt.Sprintf(`
+		WITH anchor AS (
+			SELECT msgCreateTime, mesLocalID FROM %[1]s
+			WHERE msgCreateTime = ? AND mesLocalID %% 1000 = ?
+			LIMIT 1
+		)
+		SELECT * FROM (
+			SELECT 0, mesLocalID, IFNULL(mesSvrID, 0), msgCreateTime, msgContent, messageType, mesDes, IFNULL(msgStatus, 0)
+			FROM %[1]s
+			WHERE (msgCreateTime, mesLocalID) < (SELECT msgCreateTime, mesLocalID FROM anchor)
+			ORDER BY msgCreateTime DESC, mesLocalID DESC LIMIT ?
+		)
+		UNION ALL
+		SELECT * FROM (
+			SELECT 1, mesLocalID, IFNULL(mesSvrID, 0), msgCreateTime, msgContent, messageType, mesDes, IFNULL(msgStatus, 0)
+			FROM %[1]s
+			WHERE (msgCreateTime, mesLocalID) >= (SELECT msgCreateTime, mesLocalID FROM anchor)
+			ORDER BY msgCreateTime ASC, mesLocalID ASC LIMIT ?
+		)
+	`, "Chat_"+talkerMd5)
+
+	rows, err := db.QueryContext(ctx, query, seq/1000, seq%1000, before, after+1)
+	if err != nil {
+		return nil, errors.QueryFailed("", err)
+	}
+	defer rows.Close()
+
+	var prev, next []*model.Message
+	for rows.Next() {
+		var dir int
+		var msg model.MessageDarwinV3
+		if err := rows.Scan(
+			&dir,
+			&msg.MesLocalID,
+			&msg.MesSvrID,
+			&msg.MsgCreateTime,
+			&msg.MsgContent,
+			&msg.MessageType,
+			&msg.MesDes,
+			&msg.MsgStatus,
+		); err != nil {
+			return nil, errors.ScanRowFailed(err)
+		}
+		if dir == 0 {
+			prev = append(prev, msg.Wrap(talker))
+		} else {
+			next = append(next, msg.Wrap(talker))
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(next) == 0 || next[0].Seq != seq {
+		return nil, errors.MessageNotFound(talker, seq)
+	}
+
+	messages := make([]*model.Message, 0, len(prev)+len(next))
+	for i := len(prev) - 1; i >= 0; i-- {
+		messages = append(messages, prev[i])
+	}
+	return append(messages, next...), nil
+}
+
 // 从表名中提取 talker
 func extractTalkerFromTableName(tableName string) string {
 
@@ -370,13 +493,13 @@ func (ds *DataSource) GetContacts(ctx context.Context, key string, limit, offset
 
 	if key != "" {
 		// 按照关键字查询
-		query = `SELECT IFNULL(m_nsUsrName,""), IFNULL(nickname,""), IFNULL(m_nsRemark,""), m_uiSex, IFNULL(m_nsAliasName,"") 
+		query = `SELECT IFNULL(m_nsUsrName,""), IFNULL(nickname,""), IFNULL(m_nsRemark,""), m_uiSex, IFNULL(m_nsAliasName,""), IFNULL(m_nsHeadImgUrl,""), IFNULL(m_nsHeadHDImgUrl,"") 
 				FROM WCContact 
 				WHERE m_nsUsrName = ? OR nickname = ? OR m_nsRemark = ? OR m_nsAliasName = ?`
 		args = []interface{}{key, key, key, key}
 	} else {
 		// 查询所有联系人
-		query = `SELECT IFNULL(m_nsUsrName,""), IFNULL(nickname,""), IFNULL(m_nsRemark,""), m_uiSex, IFNULL(m_nsAliasName,"") 
+		query = `SELECT IFNULL(m_nsUsrName,""), IFNULL(nickname,""), IFNULL(m_nsRemark,""), m_uiSex, IFNULL(m_nsAliasName,""), IFNULL(m_nsHeadImgUrl,""), IFNULL(m_nsHeadHDImgUrl,"") 
 				FROM WCContact`
 	}
 
@@ -409,6 +532,8 @@ func (ds *DataSource) GetContacts(ctx context.Context, key string, limit, offset
 			&contactDarwinV3.M_nsRemark,
 			&contactDarwinV3.M_uiSex,
 			&contactDarwinV3.M_nsAliasName,
+			&contactDarwinV3.M_nsHeadImgUrl,
+			&contactDarwinV3.M_nsHeadHDImgUrl,
 		)
 
 		if err != nil {
@@ -18,6 +18,12 @@ type DataSource interface {
 	// 消息
 	GetMessages(ctx context.Context, startTime, endTime time.Time, talker string, sender string, keyword string, limit, offset int) ([]*model.Message, error)
 
+	// 消息上下文，返回 seq 对应消息及其前 before 条、后 after 条消息
+	GetMessageContext(ctx context.Context, talker string, seq int64, before, after int) ([]*model.Message, error)
+
+	// 按天统计消息数量，key 为本地时间的日期（2006-01-02）
+	GetMessageCounts(ctx context.Context, talker string, startTime, endTime time.Time) (map[string]int, error)
+
 	// 联系人
 	GetContacts(ctx context.Context, key string, limit, offset int) ([]*model.Contact, error)
 
@@ -2,6 +2,7 @@ package dbm
 
 import (
 	"database/sql"
+	"fmt"
 	"runtime"
 	"sync"
 	"time"
@@ -15,6 +16,15 @@ import (
 	"github.com/aspnmy/chatlog/pkg/filemonitor"
 )
 
+const (
+	// BusyTimeout 是连接的 busy_timeout，数据库被锁定时 SQLite 在此时间内自动等待
+	BusyTimeout = 5 * time.Second
+
+	// 打开数据库遇到锁定时按指数退避重试
+	OpenRetries    = 5
+	OpenRetryDelay = 100 * time.Millisecond
+)
+
 type DBManager struct {
 	path    string
 	fm      *filemonitor.FileMonitor
@@ -124,17 +134,42 @@ func (d *DBManager) OpenDB(path string) (*sql.DB, error) {
 			return nil, err
 		}
 	}
-	db, err = sql.Open("sqlite3", tempPath)
+	db, err = sql.Open("sqlite3", fmt.Sprintf("%s?_busy_timeout=%d", tempPath, BusyTimeout.Milliseconds()))
 	if err != nil {
 		log.Err(err).Msgf("连接数据库 %s 失败", path)
 		return nil, err
 	}
+	if err := probe(db); err != nil {
+		db.Close()
+		log.Err(err).Msgf("连接数据库 %s 失败", path)
+		if errors.IsBusy(err) {
+			return nil, errors.DBBusy(path, err)
+		}
+		return nil, errors.DBConnectFailed(path, err)
+	}
 	d.mutex.Lock()
 	d.dbs[path] = db
 	d.mutex.Unlock()
 	return db, nil
 }
 
+// probe 执行首次查询读取表结构，确认数据库可用，数据库被锁定时按指数退避重试
+// Ping 成功后表结构仍在首次查询时才读取，锁定可能在那时才出现，因此重试的是查询而不是 Ping
+func probe(db *sql.DB) error {
+	delay := OpenRetryDelay
+	var err error
+	for i := 0; i < OpenRetries; i++ {
+		var n int
+		if err = db.QueryRow(`SELECT COUNT(*) FROM sqlite_master`).Scan(&n); err == nil || !errors.IsBusy(err) {
+			return err
+		}
+		log.Debug().Err(err).Msgf("数据库被锁定，%s 后重试", delay)
+		time.Sleep(delay)
+		delay *= 2
+	}
+	return err
+}
+
 func (d *DBManager) Callback(event fsnotify.Event) error {
 	if !event.Op.Has(fsnotify.Create) {
 		return nil
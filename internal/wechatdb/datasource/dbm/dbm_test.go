@@ -1,9 +1,14 @@
 package dbm
 
 import (
+	"context"
+	"database/sql"
 	"fmt"
+	"path/filepath"
 	"testing"
 	"time"
+
+	"github.com/aspnmy/chatlog/internal/errors"
 )
 
 func TestXxx(t *testing.T) {
@@ -40,3 +45,36 @@ func TestXxx(t *testing.T) {
 	}
 
 }
+
+func TestProbeBusy(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	writer, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer writer.Close()
+	conn, err := writer.Conn(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if _, err := conn.ExecContext(context.Background(), `CREATE TABLE t (v INTEGER); BEGIN EXCLUSIVE; INSERT INTO t VALUES (1)`); err != nil {
+		t.Fatal(err)
+	}
+
+	reader, err := sql.Open("sqlite3", path+"?_busy_timeout=10")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+	if err := probe(reader); !errors.IsBusy(err) {
+		t.Fatalf("probe() on locked db = %v, want busy", err)
+	}
+
+	if _, err := conn.ExecContext(context.Background(), `COMMIT`); err != nil {
+		t.Fatal(err)
+	}
+	if err := probe(reader); err != nil {
+		t.Fatalf("probe() = %v", err)
+	}
+}
@@ -0,0 +1,206 @@
+package imported
+
+import (
+	"context"
+	"crypto/sha1"
+	"database/sql"
+	"encoding/binary"
+	"encoding/json"
+	"path/filepath"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/aspnmy/chatlog/internal/errors"
+	"github.com/aspnmy/chatlog/internal/model"
+	"github.com/aspnmy/chatlog/internal/wechatdb/datasource"
+	"github.com/aspnmy/chatlog/pkg/bloom"
+	"github.com/aspnmy/chatlog/pkg/membudget"
+)
+
+// BloomFile 工作目录中已导入消息特征值的布隆过滤器，去重导入时使用
+// 只是 hash 列的缓存，删除后下次去重导入时重建
+const BloomFile = "imported.bloom"
+
+const (
+	// dedupCacheSize 布隆过滤器块缓存的大小，设置 --max-mem 时按预算缩小
+	dedupCacheSize = 64 << 20
+	// minBloomCapacity 新建布隆过滤器的最小容量，避免少量导入后立即重建
+	minBloomCapacity = 1 << 20
+)
+
+// BloomPath 返回工作目录中布隆过滤器的文件路径
+func BloomPath(dir string) string {
+	return filepath.Join(dir, BloomFile)
+}
+
+// messageHash 返回保存在 hash 列中的消息特征值哈希
+func messageHash(msg *model.Message) int64 {
+	sum := datasource.MessageHash(msg)
+	return int64(binary.BigEndian.Uint64(sum[:8]))
+}
+
+func bloomKey(hash int64) []byte {
+	return binary.BigEndian.AppendUint64(nil, uint64(hash))
+}
+
+// dedup 去重导入时判断消息是否已导入
+// 布隆过滤器排除绝大多数新消息，可能重复的消息再按 hash 列查询已导入的消息逐条比较，
+// 内存占用只有过滤器的块缓存与当前批次，与已导入的消息数无关
+type dedup struct {
+	filter   *bloom.Filter
+	reserved int64
+
+	// pending 当前批次中尚未写入数据库的消息
+	pending map[[sha1.Size]byte]bool
+}
+
+// EnableDedup 之后的 Import 跳过与已导入消息特征值（会话、时间、发送人、类型与内容）相同的消息，
+// 用于合并多台设备导出的同一会话；expected 为预计导入的消息数，用于确定布隆过滤器的容量
+func (ds *DataSource) EnableDedup(ctx context.Context, expected int) error {
+	if ds.dedup != nil {
+		return nil
+	}
+	if err := ds.backfillHashes(ctx); err != nil {
+		return err
+	}
+	var rows int
+	if err := ds.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM message").Scan(&rows); err != nil {
+		return errors.QueryFailed("count imported messages", err)
+	}
+
+	reserved, err := membudget.Default.Acquire(ctx, membudget.Default.ChunkSize(dedupCacheSize, bloom.BlockSize*256))
+	if err != nil {
+		return err
+	}
+	path := BloomPath(ds.dir)
+	want := uint64(rows + expected)
+	filter, err := bloom.Open(path, reserved)
+	switch {
+	case err != nil:
+		log.Debug().Err(err).Msgf("rebuild bloom filter for %d imported messages", rows)
+	case filter.Capacity() < want:
+		// 容量不足时误判率上升，每次误判都要查询数据库
+		log.Debug().Msgf("bloom filter capacity %d is less than %d, rebuild", filter.Capacity(), want)
+		filter.Close()
+		filter = nil
+	}
+	if filter == nil {
+		filter, err = ds.buildBloom(ctx, path, max(2*want, minBloomCapacity), reserved)
+		if err != nil {
+			membudget.Default.Release(reserved)
+			return err
+		}
+	}
+	ds.dedup = &dedup{filter: filter, reserved: reserved, pending: make(map[[sha1.Size]byte]bool)}
+	return nil
+}
+
+// backfillHashes 计算旧版本导入的消息的 hash 列，按 hash 列的索引分批处理
+func (ds *DataSource) backfillHashes(ctx context.Context) error {
+	for {
+		messages, err := ds.query(ctx, "SELECT data FROM message WHERE hash IS NULL LIMIT ?", importBatch)
+		if err != nil {
+			return err
+		}
+		if len(messages) == 0 {
+			return nil
+		}
+		tx, err := ds.db.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		for _, msg := range messages {
+			if _, err := tx.ExecContext(ctx, "UPDATE message SET hash = ? WHERE talker = ? AND seq = ?", messageHash(msg), msg.Talker, msg.Seq); err != nil {
+				tx.Rollback()
+				return err
+			}
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+	}
+}
+
+// buildBloom 按 hash 列重新创建布隆过滤器
+func (ds *DataSource) buildBloom(ctx context.Context, path string, capacity uint64, cacheSize int64) (*bloom.Filter, error) {
+	filter, err := bloom.Create(path, capacity, cacheSize)
+	if err != nil {
+		return nil, err
+	}
+	query := "SELECT hash FROM message"
+	rows, err := ds.db.QueryContext(ctx, query)
+	if err != nil {
+		filter.Close()
+		return nil, errors.QueryFailed(query, err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var hash int64
+		if err := rows.Scan(&hash); err != nil {
+			filter.Close()
+			return nil, errors.ScanRowFailed(err)
+		}
+		if err := filter.Add(bloomKey(hash)); err != nil {
+			filter.Close()
+			return nil, err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		filter.Close()
+		return nil, err
+	}
+	return filter, nil
+}
+
+// seen 返回消息是否已经导入或在当前批次中出现过，没有出现过时记录下来
+func (d *dedup) seen(ctx context.Context, db *sql.DB, msg *model.Message) (bool, error) {
+	sum := datasource.MessageHash(msg)
+	if d.pending[sum] {
+		return true, nil
+	}
+	hash := int64(binary.BigEndian.Uint64(sum[:8]))
+	key := bloomKey(hash)
+	maybe, err := d.filter.Test(key)
+	if err != nil {
+		return false, err
+	}
+	if maybe {
+		dup, err := hasMessage(ctx, db, hash, sum)
+		if err != nil || dup {
+			return dup, err
+		}
+	} else if err := d.filter.Add(key); err != nil {
+		return false, err
+	}
+	d.pending[sum] = true
+	return false, nil
+}
+
+// hasMessage 查询 hash 列相同的已导入消息，逐条比较完整的特征值
+func hasMessage(ctx context.Context, db *sql.DB, hash int64, sum [sha1.Size]byte) (bool, error) {
+	query := "SELECT data FROM message WHERE hash = ?"
+	rows, err := db.QueryContext(ctx, query, hash)
+	if err != nil {
+		return false, errors.QueryFailed(query, err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			return false, errors.ScanRowFailed(err)
+		}
+		var msg model.Message
+		if err := json.Unmarshal([]byte(data), &msg); err != nil {
+			return false, errors.ScanRowFailed(err)
+		}
+		if datasource.MessageHash(&msg) == sum {
+			return true, nil
+		}
+	}
+	return false, rows.Err()
+}
+
+func (d *dedup) close() error {
+	defer membudget.Default.Release(d.reserved)
+	return d.filter.Close()
+}
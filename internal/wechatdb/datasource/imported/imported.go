@@ -0,0 +1,490 @@
+package imported
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/rs/zerolog/log"
+
+	"github.com/aspnmy/chatlog/internal/errors"
+	"github.com/aspnmy/chatlog/internal/model"
+	"github.com/aspnmy/chatlog/pkg/util"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// File 工作目录中保存导入消息的文件，与清除记录一样随工作目录进入快照，重新解密不会覆盖
+const File = "imported.db"
+
+const schema = `
+CREATE TABLE IF NOT EXISTS message (
+	talker TEXT NOT NULL,
+	seq    INTEGER NOT NULL,
+	time   INTEGER NOT NULL,
+	sender TEXT NOT NULL,
+	data   TEXT NOT NULL,
+	hash   INTEGER,
+	PRIMARY KEY (talker, seq)
+) WITHOUT ROWID;
+CREATE INDEX IF NOT EXISTS message_time ON message (talker, time);
+`
+
+// hashSchema hash 为消息特征值哈希的前 8 字节，用于去重导入，见 datasource.MessageHash
+// 旧版本创建的文件没有该列，打开时补上，已有消息的哈希在第一次去重导入时计算
+const hashSchema = `
+ALTER TABLE message ADD COLUMN hash INTEGER;
+`
+
+const hashIndex = `
+CREATE INDEX IF NOT EXISTS message_hash ON message (hash);
+`
+
+// importBatch 每个事务写入的消息数
+const importBatch = 1000
+
+// Path 返回工作目录中导入消息的文件路径
+func Path(dir string) string {
+	return filepath.Join(dir, File)
+}
+
+// Exists 返回工作目录中是否有导入的消息
+func Exists(dir string) bool {
+	info, err := os.Stat(Path(dir))
+	return err == nil && !info.IsDir()
+}
+
+// DataSource 从 JSONL 导入的消息，每条消息以 JSON 原样保存，按会话与 seq 去重
+// 联系人、群聊与会话由消息中的会话与发送人推导，没有媒体文件
+type DataSource struct {
+	db  *sql.DB
+	dir string
+
+	// dedup 去重导入使用的布隆过滤器，见 EnableDedup
+	dedup *dedup
+}
+
+// Open 打开工作目录中导入的消息，文件不存在时创建
+func Open(dir string) (*DataSource, error) {
+	path := Path(dir)
+	db, err := sql.Open("sqlite3", "file:"+path+"?_journal_mode=WAL&_busy_timeout=5000")
+	if err != nil {
+		return nil, errors.DBConnectFailed(path, err)
+	}
+	if _, err := db.Exec(schema); err != nil {
+		db.Close()
+		return nil, errors.DBInitFailed(err)
+	}
+	// 查询不需要 hash 列，只读的工作目录（如快照）中无法添加时仍然可以查询
+	if err := migrate(db); err != nil {
+		log.Warn().Err(err).Msgf("failed to add hash column to %s", path)
+	}
+	return &DataSource{db: db, dir: dir}, nil
+}
+
+// migrate 为旧版本创建的文件添加 hash 列
+func migrate(db *sql.DB) error {
+	var n int
+	if err := db.QueryRow("SELECT COUNT(*) FROM pragma_table_info('message') WHERE name = 'hash'").Scan(&n); err != nil {
+		return err
+	}
+	if n == 0 {
+		if _, err := db.Exec(hashSchema); err != nil {
+			return err
+		}
+	}
+	_, err := db.Exec(hashIndex)
+	return err
+}
+
+// ImportResult 导入结果
+type ImportResult struct {
+	Messages int      `json:"messages"` // 写入的消息数，已存在的消息会被覆盖
+	Talkers  int      `json:"talkers"`
+	Skipped  int      `json:"skipped"` // 无法解析或缺少会话、时间的行数
+	Errors   []string `json:"errors,omitempty"`
+
+	// Duplicates 去重导入时跳过的消息数，这些消息与已导入的消息特征值相同，见 EnableDedup
+	Duplicates int `json:"duplicates,omitempty"`
+
+	talkers map[string]bool
+}
+
+// maxImportErrors ImportResult.Errors 最多记录的错误数
+const maxImportErrors = 20
+
+// Import 读取 JSONL，每行一条与 JSON 导出格式相同的消息，写入导入的消息，结果累加到 result 中，name 用于错误信息
+// 同一会话中 seq 相同的消息视为同一条，后导入的覆盖之前的，因此可以在其他地方处理后重新导入
+// 调用过 EnableDedup 时，跳过与已导入消息特征值相同的消息
+func (ds *DataSource) Import(ctx context.Context, name string, r io.Reader, result *ImportResult) error {
+	if result.talkers == nil {
+		result.talkers = make(map[string]bool)
+	}
+	if ds.dedup == nil {
+		// 不去重时布隆过滤器不会更新，删除后下次去重导入时重建
+		if err := os.Remove(BloomPath(ds.dir)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	seqs := make(map[string]int64) // 没有 seq 的消息按 会话+秒 递增编号
+	batch := make([]*model.Message, 0, importBatch)
+	flush := func() error {
+		if err := ds.insert(ctx, batch); err != nil {
+			return err
+		}
+		result.Messages += len(batch)
+		batch = batch[:0]
+		if ds.dedup != nil {
+			clear(ds.dedup.pending)
+		}
+		return nil
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		var msg model.Message
+		err := json.Unmarshal([]byte(text), &msg)
+		if err == nil && (msg.Talker == "" || msg.Time.IsZero()) {
+			err = fmt.Errorf("talker and time are required")
+		}
+		if err != nil {
+			result.Skipped++
+			if len(result.Errors) < maxImportErrors {
+				result.Errors = append(result.Errors, fmt.Sprintf("%s:%d: %v", name, line, err))
+			}
+			continue
+		}
+		if msg.Seq == 0 {
+			key := fmt.Sprintf("%s\x00%d", msg.Talker, msg.Time.Unix())
+			msg.Seq = msg.Time.Unix()*1000 + seqs[key]
+			seqs[key]++
+		}
+		if ds.dedup != nil {
+			dup, err := ds.dedup.seen(ctx, ds.db, &msg)
+			if err != nil {
+				return err
+			}
+			if dup {
+				result.Duplicates++
+				continue
+			}
+		}
+		if !result.talkers[msg.Talker] {
+			result.talkers[msg.Talker] = true
+			result.Talkers++
+		}
+		batch = append(batch, &msg)
+		if len(batch) == importBatch {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return flush()
+}
+
+func (ds *DataSource) insert(ctx context.Context, messages []*model.Message) error {
+	if len(messages) == 0 {
+		return nil
+	}
+	tx, err := ds.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	stmt, err := tx.PrepareContext(ctx, "INSERT OR REPLACE INTO message (talker, seq, time, sender, data, hash) VALUES (?, ?, ?, ?, ?, ?)")
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	for _, msg := range messages {
+		// 查询时的临时字段不保存
+		msg.Translation = ""
+		data, err := json.Marshal(msg)
+		if err != nil {
+			return err
+		}
+		if _, err := stmt.ExecContext(ctx, msg.Talker, msg.Seq, msg.Time.Unix(), msg.Sender, string(data), messageHash(msg)); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// query 执行查询并解析每一行的 data 列
+func (ds *DataSource) query(ctx context.Context, query string, args ...any) ([]*model.Message, error) {
+	rows, err := ds.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, errors.QueryFailed(query, err)
+	}
+	defer rows.Close()
+
+	messages := []*model.Message{}
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			return nil, errors.ScanRowFailed(err)
+		}
+		msg := &model.Message{}
+		if err := json.Unmarshal([]byte(data), msg); err != nil {
+			return nil, errors.ScanRowFailed(err)
+		}
+		// 较早导入的消息没有 Body
+		if msg.Body == nil {
+			msg.Body = msg.Decode()
+		}
+		messages = append(messages, msg)
+	}
+	return messages, rows.Err()
+}
+
+func (ds *DataSource) GetMessages(ctx context.Context, startTime, endTime time.Time, talker string, sender string, keyword string, limit, offset int) ([]*model.Message, error) {
+	talkers := util.Str2List(talker, ",")
+	if len(talkers) == 0 {
+		return nil, errors.ErrTalkerEmpty
+	}
+	senders := util.Str2List(sender, ",")
+
+	var regex *regexp.Regexp
+	if keyword != "" {
+		var err error
+		regex, err = regexp.Compile(keyword)
+		if err != nil {
+			return nil, errors.QueryFailed("invalid regex pattern", err)
+		}
+	}
+
+	args := []any{startTime.Unix(), endTime.Unix()}
+	for _, t := range talkers {
+		args = append(args, t)
+	}
+	messages, err := ds.query(ctx, "SELECT data FROM message WHERE time >= ? AND time <= ? AND talker IN (?"+strings.Repeat(",?", len(talkers)-1)+")", args...)
+	if err != nil {
+		return nil, err
+	}
+
+	messages = slices.DeleteFunc(messages, func(m *model.Message) bool {
+		if len(senders) > 0 && !slices.Contains(senders, m.Sender) {
+			return true
+		}
+		return regex != nil && !regex.MatchString(m.PlainTextContent())
+	})
+	model.SortMessages(messages)
+
+	if limit > 0 {
+		if offset >= len(messages) {
+			return []*model.Message{}, nil
+		}
+		messages = messages[offset:min(len(messages), offset+limit)]
+	}
+	return messages, nil
+}
+
+func (ds *DataSource) GetMessageContext(ctx context.Context, talker string, seq int64, before, after int) ([]*model.Message, error) {
+	if talker == "" {
+		return nil, errors.ErrTalkerEmpty
+	}
+	prev, err := ds.query(ctx, "SELECT data FROM message WHERE talker = ? AND seq < ? ORDER BY seq DESC LIMIT ?", talker, seq, before)
+	if err != nil {
+		return nil, err
+	}
+	next, err := ds.query(ctx, "SELECT data FROM message WHERE talker = ? AND seq >= ? ORDER BY seq LIMIT ?", talker, seq, after+1)
+	if err != nil {
+		return nil, err
+	}
+	if len(next) == 0 || next[0].Seq != seq {
+		return nil, errors.MessageNotFound(talker, seq)
+	}
+	slices.Reverse(prev)
+	return append(prev, next...), nil
+}
+
+func (ds *DataSource) GetMessageCounts(ctx context.Context, talker string, startTime, endTime time.Time) (map[string]int, error) {
+	if talker == "" {
+		return nil, errors.ErrTalkerEmpty
+	}
+	query := "SELECT time FROM message WHERE talker = ? AND time >= ? AND time <= ?"
+	rows, err := ds.db.QueryContext(ctx, query, talker, startTime.Unix(), endTime.Unix())
+	if err != nil {
+		return nil, errors.QueryFailed(query, err)
+	}
+	defer rows.Close()
+
+	counts := make(map[string]int)
+	for rows.Next() {
+		var t int64
+		if err := rows.Scan(&t); err != nil {
+			return nil, errors.ScanRowFailed(err)
+		}
+		counts[time.Unix(t, 0).Format("2006-01-02")]++
+	}
+	return counts, rows.Err()
+}
+
+// talkerInfo 一个会话的名称、最后一条消息与发送人名称
+type talkerInfo struct {
+	name    string
+	last    *model.Message
+	senders map[string]string
+}
+
+// talkerInfos 按最后一条消息的时间倒序返回所有会话
+func (ds *DataSource) talkerInfos(ctx context.Context) ([]*talkerInfo, error) {
+	messages, err := ds.query(ctx, "SELECT data FROM message ORDER BY talker, time, seq")
+	if err != nil {
+		return nil, err
+	}
+	var list []*talkerInfo
+	for _, m := range messages {
+		if len(list) == 0 || list[len(list)-1].last.Talker != m.Talker {
+			list = append(list, &talkerInfo{senders: make(map[string]string)})
+		}
+		t := list[len(list)-1]
+		t.last = m
+		if m.TalkerName != "" {
+			t.name = m.TalkerName
+		}
+		if m.Sender != "" && (m.SenderName != "" || t.senders[m.Sender] == "") {
+			t.senders[m.Sender] = m.SenderName
+		}
+	}
+	slices.SortStableFunc(list, func(a, b *talkerInfo) int {
+		return b.last.Time.Compare(a.last.Time)
+	})
+	return list, nil
+}
+
+func (ds *DataSource) GetContacts(ctx context.Context, key string, limit, offset int) ([]*model.Contact, error) {
+	list, err := ds.talkerInfos(ctx)
+	if err != nil {
+		return nil, err
+	}
+	contacts := []*model.Contact{}
+	seen := make(map[string]bool)
+	add := func(userName, nickName string) {
+		if seen[userName] || key != "" && key != userName && key != nickName {
+			return
+		}
+		seen[userName] = true
+		contacts = append(contacts, &model.Contact{UserName: userName, NickName: nickName})
+	}
+	for _, t := range list {
+		if !t.last.IsChatRoom {
+			add(t.last.Talker, t.name)
+		}
+	}
+	for _, t := range list {
+		for userName, nickName := range t.senders {
+			if userName != "系统消息" {
+				add(userName, nickName)
+			}
+		}
+	}
+	slices.SortFunc(contacts, func(a, b *model.Contact) int {
+		return strings.Compare(a.UserName, b.UserName)
+	})
+	return paginate(contacts, limit, offset), nil
+}
+
+func (ds *DataSource) GetChatRooms(ctx context.Context, key string, limit, offset int) ([]*model.ChatRoom, error) {
+	list, err := ds.talkerInfos(ctx)
+	if err != nil {
+		return nil, err
+	}
+	chatRooms := []*model.ChatRoom{}
+	for _, t := range list {
+		if !t.last.IsChatRoom || key != "" && key != t.last.Talker && key != t.name {
+			continue
+		}
+		room := &model.ChatRoom{
+			Name:             t.last.Talker,
+			NickName:         t.name,
+			User2DisplayName: make(map[string]string),
+		}
+		for userName, displayName := range t.senders {
+			if userName == "系统消息" {
+				continue
+			}
+			room.Users = append(room.Users, model.ChatRoomUser{UserName: userName, DisplayName: displayName})
+			if displayName != "" {
+				room.User2DisplayName[userName] = displayName
+			}
+		}
+		slices.SortFunc(room.Users, func(a, b model.ChatRoomUser) int {
+			return strings.Compare(a.UserName, b.UserName)
+		})
+		chatRooms = append(chatRooms, room)
+	}
+	return paginate(chatRooms, limit, offset), nil
+}
+
+func (ds *DataSource) GetSessions(ctx context.Context, key string, limit, offset int) ([]*model.Session, error) {
+	list, err := ds.talkerInfos(ctx)
+	if err != nil {
+		return nil, err
+	}
+	sessions := []*model.Session{}
+	for _, t := range list {
+		if key != "" && key != t.last.Talker && key != t.name {
+			continue
+		}
+		sessions = append(sessions, &model.Session{
+			UserName: t.last.Talker,
+			NOrder:   int(t.last.Time.Unix()),
+			NickName: t.name,
+			Content:  t.last.PlainTextContent(),
+			NTime:    t.last.Time,
+		})
+	}
+	return paginate(sessions, limit, offset), nil
+}
+
+// GetMedia 导入的消息不包含媒体文件
+func (ds *DataSource) GetMedia(ctx context.Context, _type string, key string) (*model.Media, error) {
+	return nil, errors.ErrMediaNotFound
+}
+
+// SetCallback 导入的消息只在 chatlog import 时变化，不需要监听
+func (ds *DataSource) SetCallback(name string, callback func(event fsnotify.Event) error) error {
+	return nil
+}
+
+func (ds *DataSource) Close() error {
+	if ds.dedup != nil {
+		if err := ds.dedup.close(); err != nil {
+			ds.db.Close()
+			return err
+		}
+		ds.dedup = nil
+	}
+	return ds.db.Close()
+}
+
+func paginate[T any](list []T, limit, offset int) []T {
+	if offset >= len(list) {
+		return list[:0]
+	}
+	list = list[offset:]
+	if limit > 0 && limit < len(list) {
+		list = list[:limit]
+	}
+	return list
+}
@@ -0,0 +1,140 @@
+package imported
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestImport(t *testing.T) {
+	dir := t.TempDir()
+	ds, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ds.Close()
+	ctx := context.Background()
+
+	jsonl := `{"seq":1700000000000,"time":"2023-11-14T22:13:20Z","talker":"wxid_a","talkerName":"A","sender":"wxid_a","senderName":"A","type":1,"content":"hello"}
+{"time":"2023-11-14T22:13:21Z","talker":"room@chatroom","talkerName":"群","isChatRoom":true,"sender":"wxid_b","senderName":"B","type":1,"content":"one"}
+{"time":"2023-11-14T22:13:21Z","talker":"room@chatroom","isChatRoom":true,"sender":"wxid_a","type":1,"content":"two"}
+not json
+
+{"seq":1,"talker":"wxid_a","content":"no time"}
+`
+	result := &ImportResult{}
+	if err := ds.Import(ctx, "a.jsonl", strings.NewReader(jsonl), result); err != nil {
+		t.Fatal(err)
+	}
+	if result.Messages != 3 || result.Talkers != 2 || result.Skipped != 2 {
+		t.Fatalf("result = %+v", result)
+	}
+
+	start, end := time.Unix(0, 0), time.Now()
+	msgs, err := ds.GetMessages(ctx, start, end, "room@chatroom", "", "", 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 同一秒内没有 seq 的消息按顺序编号，不会互相覆盖
+	if len(msgs) != 2 || msgs[0].Content != "one" || msgs[1].Seq != msgs[0].Seq+1 {
+		t.Fatalf("messages = %+v", msgs)
+	}
+
+	// 重新导入修改后的消息覆盖之前的
+	edited := `{"seq":1700000000000,"time":"2023-11-14T22:13:20Z","talker":"wxid_a","sender":"wxid_a","type":1,"content":"hello, edited"}`
+	if err := ds.Import(ctx, "b.jsonl", strings.NewReader(edited), &ImportResult{}); err != nil {
+		t.Fatal(err)
+	}
+	msgs, err = ds.GetMessages(ctx, start, end, "wxid_a", "", "edited", 0, 0)
+	if err != nil || len(msgs) != 1 {
+		t.Fatalf("messages = %+v, %v", msgs, err)
+	}
+
+	sessions, err := ds.GetSessions(ctx, "", 0, 0)
+	if err != nil || len(sessions) != 2 || sessions[0].UserName != "room@chatroom" {
+		t.Fatalf("sessions = %+v, %v", sessions, err)
+	}
+	rooms, err := ds.GetChatRooms(ctx, "群", 0, 0)
+	if err != nil || len(rooms) != 1 || len(rooms[0].Users) != 2 {
+		t.Fatalf("chatrooms = %+v, %v", rooms, err)
+	}
+	around, err := ds.GetMessageContext(ctx, "room@chatroom", msgs[0].Seq, 1, 1)
+	if err == nil {
+		t.Fatalf("context of message in another talker = %+v", around)
+	}
+}
+
+func TestImportDedup(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	// 旧版本创建的文件没有 hash 列
+	db, err := sql.Open("sqlite3", Path(dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	legacy := `{"seq":1,"time":"2023-11-14T22:13:20Z","talker":"wxid_a","sender":"wxid_a","type":1,"content":"hello"}`
+	for _, q := range []string{
+		"CREATE TABLE message (talker TEXT NOT NULL, seq INTEGER NOT NULL, time INTEGER NOT NULL, sender TEXT NOT NULL, data TEXT NOT NULL, PRIMARY KEY (talker, seq)) WITHOUT ROWID",
+		"INSERT INTO message VALUES ('wxid_a', 1, 1700000000, 'wxid_a', '" + legacy + "')",
+	} {
+		if _, err := db.Exec(q); err != nil {
+			t.Fatal(err)
+		}
+	}
+	db.Close()
+
+	ds, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ds.EnableDedup(ctx, 10); err != nil {
+		t.Fatal(err)
+	}
+	// 另一台设备导出的同一条消息 seq 不同，同一文件中的重复消息也跳过
+	other := `{"seq":900,"time":"2023-11-14T22:13:20Z","talker":"wxid_a","sender":"wxid_a","type":1,"content":"hello"}
+{"seq":901,"time":"2023-11-14T22:13:21Z","talker":"wxid_a","sender":"wxid_a","type":1,"content":"new"}
+{"seq":902,"time":"2023-11-14T22:13:21Z","talker":"wxid_a","sender":"wxid_a","type":1,"content":"new"}
+{"seq":903,"time":"2023-11-14T22:13:21Z","talker":"wxid_a","sender":"wxid_b","type":1,"content":"new"}`
+	result := &ImportResult{}
+	if err := ds.Import(ctx, "other.jsonl", strings.NewReader(other), result); err != nil {
+		t.Fatal(err)
+	}
+	if result.Messages != 2 || result.Duplicates != 2 {
+		t.Fatalf("dedup result = %+v", result)
+	}
+	if err := ds.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 不去重的导入删除布隆过滤器，再次去重时按 hash 列重建
+	ds, err = Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ds.Close()
+	third := `{"seq":5,"time":"2023-11-14T22:13:22Z","talker":"wxid_a","sender":"wxid_a","type":1,"content":"third"}`
+	if err := ds.Import(ctx, "third.jsonl", strings.NewReader(third), &ImportResult{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(BloomPath(dir)); !os.IsNotExist(err) {
+		t.Fatalf("bloom filter not removed: %v", err)
+	}
+	if err := ds.EnableDedup(ctx, 10); err != nil {
+		t.Fatal(err)
+	}
+	result = &ImportResult{}
+	if err := ds.Import(ctx, "again.jsonl", strings.NewReader(other+"\n"+strings.Replace(third, `"seq":5`, `"seq":6`, 1)), result); err != nil {
+		t.Fatal(err)
+	}
+	if result.Messages != 0 || result.Duplicates != 5 {
+		t.Fatalf("dedup result after rebuild = %+v", result)
+	}
+	msgs, err := ds.GetMessages(ctx, time.Unix(0, 0), time.Now(), "wxid_a", "", "", 0, 0)
+	if err != nil || len(msgs) != 4 {
+		t.Fatalf("messages = %d, %v", len(msgs), err)
+	}
+}
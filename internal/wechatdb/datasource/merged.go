@@ -0,0 +1,219 @@
+package datasource
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+
+	"github.com/aspnmy/chatlog/internal/model"
+)
+
+// Merged 将同一账号在不同版本（如 3.x 与 4.x）下的数据合并为一条时间线
+// 消息按时间合并，升级时被迁移到新版本的重复消息只保留一份；
+// 联系人、群聊与会话以主数据源为准，补充旧数据源中独有的条目
+type Merged struct {
+	sources []DataSource
+}
+
+// NewMerged 创建合并数据源，primary 为当前使用的数据源，legacy 为旧版本的数据源
+func NewMerged(primary DataSource, legacy ...DataSource) DataSource {
+	if len(legacy) == 0 {
+		return primary
+	}
+	return &Merged{sources: append([]DataSource{primary}, legacy...)}
+}
+
+func (m *Merged) GetMessages(ctx context.Context, startTime, endTime time.Time, talker string, sender string, keyword string, limit, offset int) ([]*model.Message, error) {
+	// 各数据源都需要取到 offset + limit 条，合并后再分页
+	fetch := 0
+	if limit > 0 {
+		fetch = offset + limit
+	}
+
+	var messages []*model.Message
+	var firstErr error
+	succeeded := false
+	for _, ds := range m.sources {
+		list, err := ds.GetMessages(ctx, startTime, endTime, talker, sender, keyword, fetch, 0)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		succeeded = true
+		messages = append(messages, list...)
+	}
+	if !succeeded {
+		return nil, firstErr
+	}
+
+	messages = dedupMessages(messages)
+	model.SortMessages(messages)
+
+	if offset >= len(messages) {
+		return []*model.Message{}, nil
+	}
+	messages = messages[offset:]
+	if limit > 0 && limit < len(messages) {
+		messages = messages[:limit]
+	}
+	return messages, nil
+}
+
+// dedupMessages 去除迁移产生的重复消息，同一会话中时间、发送人、类型与内容都相同视为同一条
+func dedupMessages(messages []*model.Message) []*model.Message {
+	seen := make(map[string]bool, len(messages))
+	out := messages[:0]
+	for _, msg := range messages {
+		key := messageKey(msg)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		out = append(out, msg)
+	}
+	return out
+}
+
+// GetMessageContext 依次在各数据源中查找 seq 对应的消息
+func (m *Merged) GetMessageContext(ctx context.Context, talker string, seq int64, before, after int) ([]*model.Message, error) {
+	var firstErr error
+	for _, ds := range m.sources {
+		messages, err := ds.GetMessageContext(ctx, talker, seq, before, after)
+		if err == nil {
+			return messages, nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	return nil, firstErr
+}
+
+// GetMessageCounts 同一天在多个数据源中都有消息时取较大值，避免迁移的重复消息被计算两次
+func (m *Merged) GetMessageCounts(ctx context.Context, talker string, startTime, endTime time.Time) (map[string]int, error) {
+	counts := make(map[string]int)
+	for _, ds := range m.sources {
+		c, err := ds.GetMessageCounts(ctx, talker, startTime, endTime)
+		if err != nil {
+			return nil, err
+		}
+		for day, n := range c {
+			counts[day] = max(counts[day], n)
+		}
+	}
+	return counts, nil
+}
+
+func (m *Merged) GetContacts(ctx context.Context, key string, limit, offset int) ([]*model.Contact, error) {
+	var contacts []*model.Contact
+	seen := make(map[string]bool)
+	for i, ds := range m.sources {
+		list, err := ds.GetContacts(ctx, key, 0, 0)
+		if err != nil {
+			if i == 0 {
+				return nil, err
+			}
+			continue
+		}
+		for _, c := range list {
+			if !seen[c.UserName] {
+				seen[c.UserName] = true
+				contacts = append(contacts, c)
+			}
+		}
+	}
+	return paginate(contacts, limit, offset), nil
+}
+
+func (m *Merged) GetChatRooms(ctx context.Context, key string, limit, offset int) ([]*model.ChatRoom, error) {
+	var chatRooms []*model.ChatRoom
+	seen := make(map[string]bool)
+	for i, ds := range m.sources {
+		list, err := ds.GetChatRooms(ctx, key, 0, 0)
+		if err != nil {
+			if i == 0 {
+				return nil, err
+			}
+			continue
+		}
+		for _, c := range list {
+			if !seen[c.Name] {
+				seen[c.Name] = true
+				chatRooms = append(chatRooms, c)
+			}
+		}
+	}
+	return paginate(chatRooms, limit, offset), nil
+}
+
+func (m *Merged) GetSessions(ctx context.Context, key string, limit, offset int) ([]*model.Session, error) {
+	var sessions []*model.Session
+	seen := make(map[string]bool)
+	for i, ds := range m.sources {
+		list, err := ds.GetSessions(ctx, key, 0, 0)
+		if err != nil {
+			if i == 0 {
+				return nil, err
+			}
+			continue
+		}
+		for _, s := range list {
+			if !seen[s.UserName] {
+				seen[s.UserName] = true
+				sessions = append(sessions, s)
+			}
+		}
+	}
+	sort.SliceStable(sessions, func(i, j int) bool {
+		return sessions[i].NTime.After(sessions[j].NTime)
+	})
+	return paginate(sessions, limit, offset), nil
+}
+
+func (m *Merged) GetMedia(ctx context.Context, _type string, key string) (*model.Media, error) {
+	var firstErr error
+	for _, ds := range m.sources {
+		media, err := ds.GetMedia(ctx, _type, key)
+		if err == nil {
+			return media, nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	return nil, firstErr
+}
+
+func (m *Merged) SetCallback(name string, callback func(event fsnotify.Event) error) error {
+	for _, ds := range m.sources {
+		if err := ds.SetCallback(name, callback); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *Merged) Close() error {
+	var firstErr error
+	for _, ds := range m.sources {
+		if err := ds.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+func paginate[T any](list []T, limit, offset int) []T {
+	if offset >= len(list) {
+		return []T{}
+	}
+	list = list[offset:]
+	if limit > 0 && limit < len(list) {
+		list = list[:limit]
+	}
+	return list
+}
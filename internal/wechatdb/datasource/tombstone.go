@@ -0,0 +1,259 @@
+package datasource
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/aspnmy/chatlog/internal/errors"
+	"github.com/aspnmy/chatlog/internal/model"
+)
+
+// TombstoneFile 工作目录中记录已清除消息的文件，随工作目录一起进入快照
+const TombstoneFile = "tombstones.json"
+
+// Tombstone 一条被用户有意清除的消息
+// 只记录消息的特征值，不保存内容，重新解密或合并旧版本数据时不会再出现
+type Tombstone struct {
+	Key     string    `json:"key"`
+	Talker  string    `json:"talker"`
+	Time    time.Time `json:"time"`
+	Created time.Time `json:"created"`
+}
+
+// Tombstones 工作目录中的清除记录
+type Tombstones struct {
+	path  string
+	mu    sync.RWMutex
+	items map[string]Tombstone
+}
+
+// messageKey 返回消息在不同数据源、不同版本间保持不变的特征值，
+// 与 dedupMessages 使用相同的字段，迁移产生的重复消息特征值相同
+func messageKey(msg *model.Message) string {
+	return fmt.Sprintf("%s\x00%d\x00%s\x00%d\x00%d\x00%s", msg.Talker, msg.Time.Unix(), msg.Sender, msg.Type, msg.SubType, msg.Content)
+}
+
+// TombstoneKey 返回消息的清除记录键
+func TombstoneKey(msg *model.Message) string {
+	sum := MessageHash(msg)
+	return hex.EncodeToString(sum[:])
+}
+
+// MessageHash 返回消息特征值的 SHA-1，两条消息的哈希相同时视为同一条，见 messageKey
+func MessageHash(msg *model.Message) [sha1.Size]byte {
+	return sha1.Sum([]byte(messageKey(msg)))
+}
+
+// LoadTombstones 读取 dir 中的清除记录，文件不存在时返回空记录
+func LoadTombstones(dir string) (*Tombstones, error) {
+	t := &Tombstones{
+		path:  filepath.Join(dir, TombstoneFile),
+		items: make(map[string]Tombstone),
+	}
+	b, err := os.ReadFile(t.path)
+	if os.IsNotExist(err) {
+		return t, nil
+	}
+	if err != nil {
+		return nil, errors.ReadFileFailed(t.path, err)
+	}
+	var items []Tombstone
+	if err := json.Unmarshal(b, &items); err != nil {
+		return nil, errors.Newf(err, http.StatusInternalServerError, "invalid tombstone file: %s", t.path)
+	}
+	for _, item := range items {
+		t.items[item.Key] = item
+	}
+	return t, nil
+}
+
+// Len 返回清除记录数量
+func (t *Tombstones) Len() int {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return len(t.items)
+}
+
+// Has 判断消息是否已被清除
+func (t *Tombstones) Has(msg *model.Message) bool {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	if len(t.items) == 0 {
+		return false
+	}
+	_, ok := t.items[TombstoneKey(msg)]
+	return ok
+}
+
+// Times 返回各会话中有清除记录的消息时间（Unix 秒），用于在没有消息内容时筛选可能已清除的消息
+func (t *Tombstones) Times() map[string]map[int64]bool {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	times := make(map[string]map[int64]bool)
+	for _, item := range t.items {
+		if times[item.Talker] == nil {
+			times[item.Talker] = make(map[int64]bool)
+		}
+		times[item.Talker][item.Time.Unix()] = true
+	}
+	return times
+}
+
+// Add 为消息添加清除记录，返回新增的数量
+func (t *Tombstones) Add(messages []*model.Message) int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := time.Now()
+	n := 0
+	for _, msg := range messages {
+		key := TombstoneKey(msg)
+		if _, ok := t.items[key]; ok {
+			continue
+		}
+		t.items[key] = Tombstone{Key: key, Talker: msg.Talker, Time: msg.Time, Created: now}
+		n++
+	}
+	return n
+}
+
+// Remove 删除会话 talker 在时间范围内的清除记录，talker 为空时不限会话，返回删除的数量
+func (t *Tombstones) Remove(talker string, start, end time.Time) int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	n := 0
+	for key, item := range t.items {
+		if talker != "" && item.Talker != talker {
+			continue
+		}
+		if item.Time.Before(start) || item.Time.After(end) {
+			continue
+		}
+		delete(t.items, key)
+		n++
+	}
+	return n
+}
+
+// Save 将清除记录写回工作目录，先写入临时文件再替换
+func (t *Tombstones) Save() error {
+	t.mu.RLock()
+	items := make([]Tombstone, 0, len(t.items))
+	for _, item := range t.items {
+		items = append(items, item)
+	}
+	t.mu.RUnlock()
+	sort.Slice(items, func(i, j int) bool {
+		if !items[i].Time.Equal(items[j].Time) {
+			return items[i].Time.Before(items[j].Time)
+		}
+		return items[i].Key < items[j].Key
+	})
+
+	b, err := json.MarshalIndent(items, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := t.path + ".tmp"
+	if err := os.WriteFile(tmp, b, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, t.path)
+}
+
+// counts 返回会话 talker 在时间范围内按天统计的清除数量
+func (t *Tombstones) counts(talker string, start, end time.Time) map[string]int {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	counts := make(map[string]int)
+	for _, item := range t.items {
+		if (talker == "" || item.Talker == talker) && !item.Time.Before(start) && !item.Time.After(end) {
+			counts[item.Time.Local().Format("2006-01-02")]++
+		}
+	}
+	return counts
+}
+
+// Filtered 从查询结果中去掉已清除的消息，作用于合并后的全部数据源，
+// 旧版本数据或旧快照中仍保留的消息也不会重新出现
+type Filtered struct {
+	DataSource
+	tombstones *Tombstones
+}
+
+// NewFiltered 使用清除记录过滤 ds，没有清除记录时直接返回 ds
+func NewFiltered(ds DataSource, tombstones *Tombstones) DataSource {
+	if tombstones == nil || tombstones.Len() == 0 {
+		return ds
+	}
+	return &Filtered{DataSource: ds, tombstones: tombstones}
+}
+
+func (f *Filtered) GetMessages(ctx context.Context, startTime, endTime time.Time, talker string, sender string, keyword string, limit, offset int) ([]*model.Message, error) {
+	// 多取清除记录数量的消息，过滤后仍能凑满一页
+	fetch := 0
+	if limit > 0 {
+		fetch = offset + limit + f.tombstones.Len()
+	}
+	messages, err := f.DataSource.GetMessages(ctx, startTime, endTime, talker, sender, keyword, fetch, 0)
+	if err != nil {
+		return nil, err
+	}
+	messages = f.filter(messages)
+	if offset >= len(messages) {
+		return []*model.Message{}, nil
+	}
+	messages = messages[offset:]
+	if limit > 0 && limit < len(messages) {
+		messages = messages[:limit]
+	}
+	return messages, nil
+}
+
+func (f *Filtered) GetMessageContext(ctx context.Context, talker string, seq int64, before, after int) ([]*model.Message, error) {
+	messages, err := f.DataSource.GetMessageContext(ctx, talker, seq, before, after)
+	if err != nil {
+		return nil, err
+	}
+	for _, msg := range messages {
+		if msg.Seq == seq && f.tombstones.Has(msg) {
+			return nil, errors.MessageNotFound(talker, seq)
+		}
+	}
+	return f.filter(messages), nil
+}
+
+func (f *Filtered) GetMessageCounts(ctx context.Context, talker string, startTime, endTime time.Time) (map[string]int, error) {
+	counts, err := f.DataSource.GetMessageCounts(ctx, talker, startTime, endTime)
+	if err != nil {
+		return nil, err
+	}
+	for day, n := range f.tombstones.counts(talker, startTime, endTime) {
+		if c, ok := counts[day]; ok {
+			if c -= n; c > 0 {
+				counts[day] = c
+			} else {
+				delete(counts, day)
+			}
+		}
+	}
+	return counts, nil
+}
+
+func (f *Filtered) filter(messages []*model.Message) []*model.Message {
+	out := messages[:0]
+	for _, msg := range messages {
+		if !f.tombstones.Has(msg) {
+			out = append(out, msg)
+		}
+	}
+	return out
+}
@@ -0,0 +1,87 @@
+package datasource
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/aspnmy/chatlog/internal/model"
+)
+
+// fakeSource 只实现消息查询，其余方法不会被调用
+type fakeSource struct {
+	DataSource
+	messages []*model.Message
+}
+
+func (f *fakeSource) GetMessages(ctx context.Context, startTime, endTime time.Time, talker string, sender string, keyword string, limit, offset int) ([]*model.Message, error) {
+	out := append([]*model.Message{}, f.messages[offset:]...)
+	if limit > 0 && limit < len(out) {
+		out = out[:limit]
+	}
+	return out, nil
+}
+
+func TestTombstones(t *testing.T) {
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.Local)
+	var messages []*model.Message
+	for i := range 10 {
+		messages = append(messages, &model.Message{
+			Seq:     int64(i),
+			Time:    base.Add(time.Duration(i) * time.Hour),
+			Talker:  "room",
+			Sender:  "a",
+			Content: fmt.Sprintf("msg %d", i),
+		})
+	}
+
+	dir := t.TempDir()
+	tombs, err := LoadTombstones(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ds := NewFiltered(&fakeSource{messages: messages}, tombs); ds == nil {
+		t.Fatal("nil data source")
+	} else if _, ok := ds.(*Filtered); ok {
+		t.Error("empty tombstones should not wrap the data source")
+	}
+
+	// 旧版本数据中的同一条消息 seq 不同，仍然被清除
+	legacy := *messages[2]
+	legacy.Seq = 1000
+	if n := tombs.Add([]*model.Message{&legacy, messages[3], messages[3]}); n != 2 {
+		t.Fatalf("added %d tombstones, want 2", n)
+	}
+	if err := tombs.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	tombs, err = LoadTombstones(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if times := tombs.Times()["room"]; len(times) != 2 || !times[messages[3].Time.Unix()] {
+		t.Errorf("times = %v", times)
+	}
+
+	ds := NewFiltered(&fakeSource{messages: messages}, tombs)
+	page, err := ds.GetMessages(context.Background(), base, base.Add(24*time.Hour), "room", "", "", 3, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var seqs []int64
+	for _, m := range page {
+		seqs = append(seqs, m.Seq)
+	}
+	if fmt.Sprint(seqs) != "[1 4 5]" {
+		t.Errorf("page = %v, want [1 4 5]", seqs)
+	}
+
+	if n := tombs.Remove("room", base, base.Add(2*time.Hour)); n != 1 {
+		t.Errorf("removed %d tombstones, want 1", n)
+	}
+	if !tombs.Has(messages[3]) || tombs.Has(messages[2]) {
+		t.Error("restore removed the wrong tombstone")
+	}
+}
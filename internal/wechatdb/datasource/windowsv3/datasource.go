@@ -285,7 +285,7 @@ func (ds *DataSource) GetMessages(ctx context.Context, startTime, endTime time.T
 
 			query := fmt.Sprintf(`
 				SELECT MsgSvrID, Sequence, CreateTime, StrTalker, IsSender, 
-					Type, SubType, StrContent, CompressContent, BytesExtra
+					Type, SubType, StrContent, CompressContent, BytesExtra, IFNULL(Status, 0)
 				FROM MSG 
 				WHERE %s 
 				ORDER BY Sequence ASC
@@ -319,6 +319,7 @@ func (ds *DataSource) GetMessages(ctx context.Context, startTime, endTime time.T
 					&msg.StrContent,
 					&compressContent,
 					&bytesExtra,
+					&msg.Status,
 				)
 				if err != nil {
 					rows.Close()
@@ -355,15 +356,13 @@ func (ds *DataSource) GetMessages(ctx context.Context, startTime, endTime time.T
 				// 通过所有过滤条件，保留此消息
 				filteredMessages = append(filteredMessages, message)
 
-				// 检查是否已经满足分页处理数量
-				if limit > 0 && len(filteredMessages) >= offset+limit {
+				// 检查是否已经满足分页处理数量，多个 talker 时其他 talker 可能有更早的消息，需要全部读取后再分页
+				if limit > 0 && len(talkers) == 1 && len(filteredMessages) >= offset+limit {
 					// 已经获取了足够的消息，可以提前返回
 					rows.Close()
 
-					// 对所有消息按时间排序
-					sort.Slice(filteredMessages, func(i, j int) bool {
-						return filteredMessages[i].Seq < filteredMessages[j].Seq
-					})
+					// 对所有消息按全序排序，见 model.SortMessages
+					model.SortMessages(filteredMessages)
 
 					// 处理分页
 					if offset >= len(filteredMessages) {
@@ -380,10 +379,8 @@ func (ds *DataSource) GetMessages(ctx context.Context, startTime, endTime time.T
 		}
 	}
 
-	// 对所有消息按时间排序
-	sort.Slice(filteredMessages, func(i, j int) bool {
-		return filteredMessages[i].Seq < filteredMessages[j].Seq
-	})
+	// 对所有消息按全序排序，见 model.SortMessages
+	model.SortMessages(filteredMessages)
 
 	// 处理分页
 	if limit > 0 {
@@ -400,6 +397,189 @@ func (ds *DataSource) GetMessages(ctx context.Context, startTime, endTime time.T
 	return filteredMessages, nil
 }
 
+// GetMessageContext 获取消息上下文
+// 每个数据库只执行一次查询，通过 Sequence 索引同时取出之前与之后的消息；
+// 消息所在的数据库数量不足时，再到相邻的数据库中补齐
+func (ds *DataSource) GetMessageContext(ctx context.Context, talker string, seq int64, before, after int) ([]*model.Message, error) {
+	if talker == "" {
+		return nil, errors.ErrTalkerEmpty
+	}
+	if len(ds.messageInfos) == 0 {
+		return nil, errors.MessageNotFound(talker, seq)
+	}
+
+	// 按 seq 中的时间戳定位消息所在的数据库
+	t := time.Unix(seq/1000, 0)
+	index := 0
+	for i, info := range ds.messageInfos {
+		if !info.StartTime.After(t) {
+			index = i
+		}
+	}
+
+	prev, next, err := ds.queryAround(ctx, ds.messageInfos[index], talker, seq, before, after+1)
+	if err != nil {
+		return nil, err
+	}
+	if len(next) == 0 || next[0].Seq != seq {
+		return nil, errors.MessageNotFound(talker, seq)
+	}
+
+	for i := index - 1; i >= 0 && len(prev) < before; i-- {
+		p, _, err := ds.queryAround(ctx, ds.messageInfos[i], talker, seq, before-len(prev), 0)
+		if err != nil {
+			return nil, err
+		}
+		prev = append(prev, p...)
+	}
+	for i := index + 1; i < len(ds.messageInfos) && len(next) < after+1; i++ {
+		_, n, err := ds.queryAround(ctx, ds.messageInfos[i], talker, seq, 0, after+1-len(next))
+		if err != nil {
+			return nil, err
+		}
+		next = append(next, n...)
+	}
+
+	messages := make([]*model.Message, 0, len(prev)+len(next))
+	for i := len(prev) - 1; i >= 0; i-- {
+		messages = append(messages, prev[i])
+	}
+	return append(messages, next...), nil
+}
+
+// GetMessageCounts 按天统计消息数量，在每个数据库中通过 GROUP BY 聚合，不读取消息内容
+func (ds *DataSource) GetMessageCounts(ctx context.Context, talker string, startTime, endTime time.Time) (map[string]int, error) {
+	if talker == "" {
+		return nil, errors.ErrTalkerEmpty
+	}
+
+	counts := make(map[string]int)
+	for _, dbInfo := range ds.getDBInfosForTimeRange(startTime, endTime) {
+		if err := ds.countMessages(ctx, dbInfo, talker, startTime, endTime, counts); err != nil {
+			return nil, err
+		}
+	}
+	return counts, nil
+}
+
+func (ds *DataSource) countMessages(ctx context.Context, dbInfo MessageDBInfo, talker string, startTime, endTime time.Time, counts map[string]int) error {
+	db, err := ds.dbm.OpenDB(dbInfo.FilePath)
+	if err != nil {
+		log.Error().Msgf("数据库 %s 未打开", dbInfo.FilePath)
+		return nil
+	}
+
+	condition := "StrTalker = ?"
+	var talkerArg interface{} = talker
+	if talkerID, ok := dbInfo.TalkerMap[talker]; ok {
+		condition = "TalkerId = ?"
+		talkerArg = talkerID
+	}
+
+	query := fmt.Sprintf(`
+		SELECT date(CreateTime, 'unixepoch', 'localtime') AS day, COUNT(*)
+		FROM MSG
+		WHERE Sequence >= ? AND Sequence <= ? AND %s
+		GROUP BY day
+	`, condition)
+
+	rows, err := db.QueryContext(ctx, query, startTime.Unix()*1000, endTime.Unix()*1000, talkerArg)
+	if err != nil {
+		if strings.Contains(err.Error(), "no such table") {
+			return nil
+		}
+		return errors.QueryFailed("", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var day string
+		var count int
+		if err := rows.Scan(&day, &count); err != nil {
+			return errors.ScanRowFailed(err)
+		}
+		counts[day] += count
+	}
+	return rows.Err()
+}
+
+// queryAround 查询 seq 之前的 before 条（按 seq 倒序）与从 seq 开始的 after 条消息
+func (ds *DataSource) queryAround(ctx context.Context, dbInfo MessageDBInfo, talker string, seq int64, before, after int) ([]*model.Message, []*model.Message, error) {
+	db, err := ds.dbm.OpenDB(dbInfo.FilePath)
+	if err != nil {
+		log.Error().Msgf("数据库 %s 未打开", dbInfo.FilePath)
+		return nil, nil, nil
+	}
+
+	condition := "StrTalker = ?"
+	var talkerArg interface{} = talker
+	if talkerID, ok := dbInfo.TalkerMap[talker]; ok {
+		condition = "TalkerId = ?"
+		talkerArg = talkerID
+	}
+
+	query := fmt.Sprintf(`
+		SELECT * FROM (
+			SELECT 0, MsgSvrID, Sequence, CreateTime, StrTalker, IsSender,
+				Type, SubType, StrContent, CompressContent, BytesExtra, IFNULL(Status, 0)
+			FROM MSG
+			WHERE %[1]s AND Sequence < ?
+			ORDER BY Sequence DESC LIMIT ?
+		)
+		UNION ALL
+		SELECT * FROM (
+			SELECT 1, MsgSvrID, Sequence, CreateTime, StrTalker, IsSender,
+				Type, SubType, StrContent, CompressContent, BytesExtra, IFNULL(Status, 0)
+			FROM MSG
+			WHERE %[1]s AND Sequence >= ?
+			ORDER BY Sequence ASC LIMIT ?
+		)
+	`, condition)
+
+	rows, err := db.QueryContext(ctx, query, talkerArg, seq, before, talkerArg, seq, after)
+	if err != nil {
+		if strings.Contains(err.Error(), "no such table") {
+			return nil, nil, nil
+		}
+		return nil, nil, errors.QueryFailed("", err)
+	}
+	defer rows.Close()
+
+	var prev, next []*model.Message
+	for rows.Next() {
+		var dir int
+		var msg model.MessageV3
+		var compressContent []byte
+		var bytesExtra []byte
+		err := rows.Scan(
+			&dir,
+			&msg.MsgSvrID,
+			&msg.Sequence,
+			&msg.CreateTime,
+			&msg.StrTalker,
+			&msg.IsSender,
+			&msg.Type,
+			&msg.SubType,
+			&msg.StrContent,
+			&compressContent,
+			&bytesExtra,
+			&msg.Status,
+		)
+		if err != nil {
+			return nil, nil, errors.ScanRowFailed(err)
+		}
+		msg.CompressContent = compressContent
+		msg.BytesExtra = bytesExtra
+
+		if dir == 0 {
+			prev = append(prev, msg.Wrap())
+		} else {
+			next = append(next, msg.Wrap())
+		}
+	}
+	return prev, next, rows.Err()
+}
+
 // GetContacts 实现获取联系人信息的方法
 func (ds *DataSource) GetContacts(ctx context.Context, key string, limit, offset int) ([]*model.Contact, error) {
 	var query string
@@ -407,12 +587,12 @@ func (ds *DataSource) GetContacts(ctx context.Context, key string, limit, offset
 
 	if key != "" {
 		// 按照关键字查询
-		query = `SELECT UserName, Alias, Remark, NickName, Reserved1 FROM Contact 
+		query = `SELECT UserName, Alias, Remark, NickName, Reserved1, IFNULL(SmallHeadImgUrl,""), IFNULL(BigHeadImgUrl,"") FROM Contact 
                 WHERE UserName = ? OR Alias = ? OR Remark = ? OR NickName = ?`
 		args = []interface{}{key, key, key, key}
 	} else {
 		// 查询所有联系人
-		query = `SELECT UserName, Alias, Remark, NickName, Reserved1 FROM Contact`
+		query = `SELECT UserName, Alias, Remark, NickName, Reserved1, IFNULL(SmallHeadImgUrl,""), IFNULL(BigHeadImgUrl,"") FROM Contact`
 	}
 
 	// 添加排序、分页
@@ -444,6 +624,8 @@ func (ds *DataSource) GetContacts(ctx context.Context, key string, limit, offset
 			&contactV3.Remark,
 			&contactV3.NickName,
 			&contactV3.Reserved1,
+			&contactV3.SmallHeadImgUrl,
+			&contactV3.BigHeadImgUrl,
 		)
 
 		if err != nil {
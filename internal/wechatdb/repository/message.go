@@ -2,9 +2,11 @@ package repository
 
 import (
 	"context"
+	"net/http"
 	"strings"
 	"time"
 
+	"github.com/aspnmy/chatlog/internal/errors"
 	"github.com/aspnmy/chatlog/internal/model"
 	"github.com/aspnmy/chatlog/pkg/util"
 
@@ -28,11 +30,64 @@ func (r *Repository) GetMessages(ctx context.Context, startTime, endTime time.Ti
 	return messages, nil
 }
 
+// GetMessageContext 获取消息上下文，talker 支持联系人与群聊的名称
+func (r *Repository) GetMessageContext(ctx context.Context, talker string, seq int64, before, after int) ([]*model.Message, error) {
+	talker, _ = r.parseTalkerAndSender(ctx, talker, "")
+	messages, err := r.ds.GetMessageContext(ctx, talker, seq, before, after)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := r.EnrichMessages(ctx, messages); err != nil {
+		log.Debug().Msgf("EnrichMessages failed: %v", err)
+	}
+
+	return messages, nil
+}
+
+// GetMessagesByID 按 ID 批量获取消息，结果与 ids 的顺序一致，找不到的消息在 missing 中返回
+func (r *Repository) GetMessagesByID(ctx context.Context, ids []model.MessageID) ([]*model.Message, []model.MessageID, error) {
+	talkers := make(map[string]string)
+	messages := make([]*model.Message, 0, len(ids))
+	var missing []model.MessageID
+	for _, id := range ids {
+		talker, ok := talkers[id.Talker]
+		if !ok {
+			talker, _ = r.parseTalkerAndSender(ctx, id.Talker, "")
+			talkers[id.Talker] = talker
+		}
+		found, err := r.ds.GetMessageContext(ctx, talker, id.Seq, 0, 0)
+		if err != nil {
+			if errors.GetCode(err) != http.StatusNotFound {
+				return nil, nil, err
+			}
+			missing = append(missing, id)
+			continue
+		}
+		messages = append(messages, found...)
+	}
+
+	if err := r.EnrichMessages(ctx, messages); err != nil {
+		log.Debug().Msgf("EnrichMessages failed: %v", err)
+	}
+
+	return messages, missing, nil
+}
+
+// GetMessageCounts 按天统计消息数量，talker 支持联系人与群聊的名称
+func (r *Repository) GetMessageCounts(ctx context.Context, talker string, startTime, endTime time.Time) (map[string]int, error) {
+	talker, _ = r.parseTalkerAndSender(ctx, talker, "")
+	return r.ds.GetMessageCounts(ctx, talker, startTime, endTime)
+}
+
 // EnrichMessages 补充消息的额外信息
 func (r *Repository) EnrichMessages(ctx context.Context, messages []*model.Message) error {
 	for _, msg := range messages {
 		r.enrichMessage(msg)
 	}
+	if n := model.DetectTimeAnomalies(messages, time.Now()); n > 0 {
+		log.Debug().Msgf("%d messages with abnormal time", n)
+	}
 	return nil
 }
 
@@ -60,6 +115,11 @@ func (r *Repository) enrichMessage(msg *model.Message) {
 	}
 }
 
+// ParseTalkerAndSender 将联系人、群聊及群成员的名称解析为微信 ID
+func (r *Repository) ParseTalkerAndSender(ctx context.Context, talker, sender string) (string, string) {
+	return r.parseTalkerAndSender(ctx, talker, sender)
+}
+
 func (r *Repository) parseTalkerAndSender(ctx context.Context, talker, sender string) (string, string) {
 	displayName2User := make(map[string]string)
 	users := make(map[string]bool)
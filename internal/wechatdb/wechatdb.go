@@ -6,25 +6,38 @@ import (
 
 	"github.com/aspnmy/chatlog/internal/model"
 	"github.com/aspnmy/chatlog/internal/wechatdb/datasource"
+	"github.com/aspnmy/chatlog/internal/wechatdb/datasource/imported"
 	"github.com/aspnmy/chatlog/internal/wechatdb/repository"
 
 	_ "github.com/mattn/go-sqlite3"
+	"github.com/rs/zerolog/log"
 )
 
 type DB struct {
 	path     string
 	platform string
 	version  int
+	legacy   []Source
 	ds       datasource.DataSource
+	tombs    *datasource.Tombstones
 	repo     *repository.Repository
 }
 
-func New(path string, platform string, version int) (*DB, error) {
+// Source 数据来源，用于合并同一账号旧版本的数据
+type Source struct {
+	Path     string
+	Platform string
+	Version  int
+}
+
+// New 打开工作目录中的数据，legacy 为同一账号旧版本（如 3.x）的工作目录，消息会合并为一条时间线
+func New(path string, platform string, version int, legacy ...Source) (*DB, error) {
 
 	w := &DB{
 		path:     path,
 		platform: platform,
 		version:  version,
+		legacy:   legacy,
 	}
 
 	// 初始化，加载数据库文件信息
@@ -46,8 +59,52 @@ func (w *DB) Initialize() error {
 	var err error
 	w.ds, err = datasource.New(w.path, w.platform, w.version)
 	if err != nil {
+		// 只有导入的消息的工作目录没有微信数据库
+		if !imported.Exists(w.path) {
+			return err
+		}
+		log.Debug().Err(err).Msg("no wechat database, using imported messages only")
+		w.ds = nil
+	}
+
+	legacy := make([]datasource.DataSource, 0, len(w.legacy)+1)
+	closeAll := func() {
+		for _, l := range legacy {
+			l.Close()
+		}
+		if w.ds != nil {
+			w.ds.Close()
+		}
+	}
+	for _, src := range w.legacy {
+		ds, err := datasource.New(src.Path, src.Platform, src.Version)
+		if err != nil {
+			closeAll()
+			return err
+		}
+		legacy = append(legacy, ds)
+	}
+	// chatlog import 导入的消息与微信数据合并，重复的消息只保留一份
+	if imported.Exists(w.path) {
+		ds, err := imported.Open(w.path)
+		if err != nil {
+			closeAll()
+			return err
+		}
+		legacy = append(legacy, ds)
+	}
+	if w.ds == nil {
+		w.ds, legacy = legacy[len(legacy)-1], legacy[:len(legacy)-1]
+	}
+	w.ds = datasource.NewMerged(w.ds, legacy...)
+
+	// 清除记录作用于合并后的全部数据源
+	w.tombs, err = datasource.LoadTombstones(w.path)
+	if err != nil {
+		w.ds.Close()
 		return err
 	}
+	w.ds = datasource.NewFiltered(w.ds, w.tombs)
 
 	w.repo, err = repository.New(w.ds)
 	if err != nil {
@@ -57,6 +114,11 @@ func (w *DB) Initialize() error {
 	return nil
 }
 
+// Tombstones 返回工作目录中的清除记录，修改后重新打开数据库才会生效
+func (w *DB) Tombstones() *datasource.Tombstones {
+	return w.tombs
+}
+
 func (w *DB) GetMessages(start, end time.Time, talker string, sender string, keyword string, limit, offset int) ([]*model.Message, error) {
 	ctx := context.Background()
 
@@ -69,6 +131,24 @@ func (w *DB) GetMessages(start, end time.Time, talker string, sender string, key
 	return messages, nil
 }
 
+func (w *DB) GetMessageContext(talker string, seq int64, before, after int) ([]*model.Message, error) {
+	return w.repo.GetMessageContext(context.Background(), talker, seq, before, after)
+}
+
+// GetMessagesByID 按 ID 批量获取消息，找不到的消息在 missing 中返回
+func (w *DB) GetMessagesByID(ids []model.MessageID) ([]*model.Message, []model.MessageID, error) {
+	return w.repo.GetMessagesByID(context.Background(), ids)
+}
+
+func (w *DB) GetMessageCounts(talker string, start, end time.Time) (map[string]int, error) {
+	return w.repo.GetMessageCounts(context.Background(), talker, start, end)
+}
+
+// ParseTalkerAndSender 将联系人、群聊及群成员的名称解析为微信 ID
+func (w *DB) ParseTalkerAndSender(talker, sender string) (string, string) {
+	return w.repo.ParseTalkerAndSender(context.Background(), talker, sender)
+}
+
 type GetContactsResp struct {
 	Items []*model.Contact `json:"items"`
 }
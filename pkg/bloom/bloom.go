@@ -0,0 +1,250 @@
+// Package bloom 保存在文件中的分块布隆过滤器，用于在有限内存中判断大量键是否出现过
+// 每个键只落在一个 4KB 的块中，内存中只缓存最近使用的块，其余的在文件中按需读写
+package bloom
+
+import (
+	"container/list"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"os"
+)
+
+const (
+	magic      = "CLBLOOM1"
+	headerSize = 64
+
+	// BlockSize 每个块的字节数
+	BlockSize = 4096
+	blockBits = BlockSize * 8
+
+	// hashes 每个键在块中设置的位数，按 1% 的误判率取整
+	hashes = 7
+	// bitsPerKey 每个键占用的位数，分块后误判率略高于同样大小的普通布隆过滤器
+	bitsPerKey = 10
+)
+
+// ErrUnclean 过滤器上次没有正常关闭，可能缺少部分键，需要重新创建
+var ErrUnclean = errors.New("bloom filter was not closed cleanly")
+
+// Filter 分块布隆过滤器，不是并发安全的
+// 文件头记录容量、已添加的键数与是否正常关闭，之后依次是各个块
+type Filter struct {
+	file     *os.File
+	blocks   uint64
+	capacity uint64
+	count    uint64
+
+	cache    map[uint64]*list.Element
+	lru      *list.List
+	maxCache int
+}
+
+// block 缓存中的一个块，dirty 表示修改后尚未写回文件
+type block struct {
+	index uint64
+	bits  []byte
+	dirty bool
+}
+
+// Create 创建可以容纳 capacity 个键的过滤器，文件已存在时覆盖，cacheSize 为块缓存的字节数上限
+func Create(path string, capacity uint64, cacheSize int64) (*Filter, error) {
+	if capacity == 0 {
+		capacity = 1
+	}
+	blocks := (capacity*bitsPerKey + blockBits - 1) / blockBits
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return nil, err
+	}
+	// 块在第一次写入前都是 0，截断后的空洞不占用磁盘空间
+	if err := file.Truncate(int64(headerSize + blocks*BlockSize)); err != nil {
+		file.Close()
+		return nil, err
+	}
+	f := newFilter(file, blocks, capacity, 0, cacheSize)
+	if err := f.writeHeader(false); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return f, nil
+}
+
+// Open 打开已有的过滤器，文件不完整时返回错误，上次没有正常关闭时返回 ErrUnclean
+func Open(path string, cacheSize int64) (*Filter, error) {
+	file, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return nil, err
+	}
+	header := make([]byte, headerSize)
+	if _, err := file.ReadAt(header, 0); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("read bloom filter header: %w", err)
+	}
+	if string(header[:8]) != magic {
+		file.Close()
+		return nil, fmt.Errorf("%s is not a bloom filter", path)
+	}
+	blocks := binary.LittleEndian.Uint64(header[8:])
+	capacity := binary.LittleEndian.Uint64(header[16:])
+	count := binary.LittleEndian.Uint64(header[24:])
+	clean := header[32] == 1
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	if blocks == 0 || info.Size() != int64(headerSize+blocks*BlockSize) {
+		file.Close()
+		return nil, fmt.Errorf("bloom filter %s is truncated", path)
+	}
+	if !clean {
+		file.Close()
+		return nil, ErrUnclean
+	}
+	f := newFilter(file, blocks, capacity, count, cacheSize)
+	// 打开后标记为未关闭，进程异常退出时下次打开能发现
+	if err := f.writeHeader(false); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return f, nil
+}
+
+func newFilter(file *os.File, blocks, capacity, count uint64, cacheSize int64) *Filter {
+	return &Filter{
+		file:     file,
+		blocks:   blocks,
+		capacity: capacity,
+		count:    count,
+		cache:    make(map[uint64]*list.Element),
+		lru:      list.New(),
+		maxCache: max(1, int(cacheSize/BlockSize)),
+	}
+}
+
+func (f *Filter) writeHeader(clean bool) error {
+	header := make([]byte, headerSize)
+	copy(header, magic)
+	binary.LittleEndian.PutUint64(header[8:], f.blocks)
+	binary.LittleEndian.PutUint64(header[16:], f.capacity)
+	binary.LittleEndian.PutUint64(header[24:], f.count)
+	if clean {
+		header[32] = 1
+	}
+	_, err := f.file.WriteAt(header, 0)
+	return err
+}
+
+// Capacity 返回创建时指定的容量，添加的键超过容量后误判率上升
+func (f *Filter) Capacity() uint64 {
+	return f.capacity
+}
+
+// Count 返回添加过的键数，重复添加的键会重复计数
+func (f *Filter) Count() uint64 {
+	return f.count
+}
+
+// locate 返回键所在的块与块内的各个位
+func (f *Filter) locate(key []byte) (uint64, [hashes]uint32) {
+	h := fnv.New128a()
+	h.Write(key)
+	sum := h.Sum(nil)
+	h1 := binary.LittleEndian.Uint64(sum[:8])
+	a := binary.LittleEndian.Uint32(sum[8:])
+	b := binary.LittleEndian.Uint32(sum[12:]) | 1
+	var bits [hashes]uint32
+	for i := range bits {
+		// 双重哈希生成块内的各个位
+		bits[i] = (a + uint32(i)*b) % blockBits
+	}
+	return h1 % f.blocks, bits
+}
+
+// Add 添加键
+func (f *Filter) Add(key []byte) error {
+	index, bits := f.locate(key)
+	b, err := f.block(index)
+	if err != nil {
+		return err
+	}
+	for _, bit := range bits {
+		b.bits[bit/8] |= 1 << (bit % 8)
+	}
+	b.dirty = true
+	f.count++
+	return nil
+}
+
+// Test 返回键是否可能出现过，返回 false 时一定没有添加过
+func (f *Filter) Test(key []byte) (bool, error) {
+	index, bits := f.locate(key)
+	b, err := f.block(index)
+	if err != nil {
+		return false, err
+	}
+	for _, bit := range bits {
+		if b.bits[bit/8]&(1<<(bit%8)) == 0 {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// block 从缓存或文件中取得块，缓存已满时写回最久未使用的块
+func (f *Filter) block(index uint64) (*block, error) {
+	if e, ok := f.cache[index]; ok {
+		f.lru.MoveToFront(e)
+		return e.Value.(*block), nil
+	}
+	var b *block
+	if f.lru.Len() >= f.maxCache {
+		e := f.lru.Back()
+		b = e.Value.(*block)
+		if err := f.writeBlock(b); err != nil {
+			return nil, err
+		}
+		f.lru.Remove(e)
+		delete(f.cache, b.index)
+		b.index, b.dirty = index, false
+	} else {
+		b = &block{index: index, bits: make([]byte, BlockSize)}
+	}
+	if _, err := f.file.ReadAt(b.bits, headerSize+int64(index)*BlockSize); err != nil {
+		return nil, fmt.Errorf("read bloom filter block %d: %w", index, err)
+	}
+	f.cache[index] = f.lru.PushFront(b)
+	return b, nil
+}
+
+func (f *Filter) writeBlock(b *block) error {
+	if !b.dirty {
+		return nil
+	}
+	if _, err := f.file.WriteAt(b.bits, headerSize+int64(b.index)*BlockSize); err != nil {
+		return err
+	}
+	b.dirty = false
+	return nil
+}
+
+// Close 写回修改过的块并关闭文件，写回成功后才标记为正常关闭
+func (f *Filter) Close() error {
+	for e := f.lru.Front(); e != nil; e = e.Next() {
+		if err := f.writeBlock(e.Value.(*block)); err != nil {
+			f.file.Close()
+			return err
+		}
+	}
+	if err := f.file.Sync(); err != nil {
+		f.file.Close()
+		return err
+	}
+	if err := f.writeHeader(true); err != nil {
+		f.file.Close()
+		return err
+	}
+	return f.file.Close()
+}
@@ -0,0 +1,60 @@
+package bloom
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFilter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.bloom")
+	const n = 20000
+	// 缓存只有 2 个块，大部分块需要写回并重新读取
+	f, err := Create(path, n, 2*BlockSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < n; i++ {
+		if err := f.Add([]byte(fmt.Sprintf("key-%d", i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err = Open(path, 2*BlockSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.Count() != n || f.Capacity() != n {
+		t.Errorf("count = %d, capacity = %d", f.Count(), f.Capacity())
+	}
+	for i := 0; i < n; i++ {
+		if ok, err := f.Test([]byte(fmt.Sprintf("key-%d", i))); err != nil || !ok {
+			t.Fatalf("key-%d not found: %v", i, err)
+		}
+	}
+	positives := 0
+	for i := 0; i < n; i++ {
+		if ok, _ := f.Test([]byte(fmt.Sprintf("other-%d", i))); ok {
+			positives++
+		}
+	}
+	if rate := float64(positives) / n; rate > 0.03 {
+		t.Errorf("false positive rate = %.4f", rate)
+	}
+
+	// 没有关闭时再次打开返回 ErrUnclean
+	if _, err := Open(path, BlockSize); !errors.Is(err, ErrUnclean) {
+		t.Errorf("open unclosed filter: %v, want ErrUnclean", err)
+	}
+	f.Close()
+
+	os.WriteFile(path, []byte("not a filter"), 0644)
+	if _, err := Open(path, BlockSize); err == nil {
+		t.Error("open invalid file succeeded")
+	}
+}
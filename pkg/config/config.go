@@ -17,8 +17,11 @@
 package config
 
 import (
+	"bytes"
+	"encoding/json"
 	"errors"
 	"os"
+	"strings"
 
 	"github.com/rs/zerolog/log"
 	"github.com/spf13/viper"
@@ -78,7 +81,10 @@ func Load(conf interface{}) error {
 	viper.SetConfigName(ConfigName)
 	viper.SetConfigType(ConfigType)
 	viper.AddConfigPath(ConfigPath)
-	if err := viper.ReadInConfig(); err != nil {
+	if err := readInConfig(File()); err != nil {
+		if !os.IsNotExist(err) {
+			return err
+		}
 		if err := viper.SafeWriteConfig(); err != nil {
 			return err
 		}
@@ -94,7 +100,7 @@ func Load(conf interface{}) error {
 // It unmarshals the configuration into the provided conf interface.
 func LoadFile(file string, conf interface{}) error {
 	viper.SetConfigFile(file)
-	if err := viper.ReadInConfig(); err != nil {
+	if err := readInConfig(file); err != nil {
 		return err
 	}
 	if err := viper.Unmarshal(conf); err != nil {
@@ -105,22 +111,53 @@ func LoadFile(file string, conf interface{}) error {
 }
 
 // SetConfig sets a configuration key to a specified value.
-// It also writes the updated configuration back to the file.
+// It also writes the updated configuration back to the file,
+// encrypted when a passphrase has been set.
 func SetConfig(key string, value interface{}) error {
 	viper.Set(key, value)
-	if err := viper.WriteConfig(); err != nil {
+	if err := Save(); err != nil {
 		return err
 	}
 	return nil
 }
 
+// UnsetConfig removes a key such as "flags.work-dir" and writes the
+// configuration back to the file. viper keeps values read from the file
+// under nested keys even when the parent is overridden, so the remaining
+// settings are loaded again from scratch.
+func UnsetConfig(key string) error {
+	settings := viper.AllSettings()
+	path := strings.Split(strings.ToLower(key), ".")
+	m := settings
+	for _, k := range path[:len(path)-1] {
+		next, ok := m[k].(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		m = next
+	}
+	delete(m, path[len(path)-1])
+	b, err := json.Marshal(settings)
+	if err != nil {
+		return err
+	}
+	viper.Reset()
+	viper.SetConfigName(ConfigName)
+	viper.SetConfigType(ConfigType)
+	viper.AddConfigPath(ConfigPath)
+	if err := viper.ReadConfig(bytes.NewReader(b)); err != nil {
+		return err
+	}
+	return Save()
+}
+
 // ResetConfig resets the configuration to empty.
 func ResetConfig() error {
 	viper.Reset()
 	viper.SetConfigName(ConfigName)
 	viper.SetConfigType(ConfigType)
 	viper.AddConfigPath(ConfigPath)
-	return viper.WriteConfig()
+	return Save()
 }
 
 // GetConfig retrieves all configuration settings as a map.
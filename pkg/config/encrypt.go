@@ -0,0 +1,189 @@
+package config
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/viper"
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	// EncryptedFormat identifies a configuration file encrypted with a passphrase.
+	EncryptedFormat = "chatlog-encrypted"
+
+	scryptN      = 1 << 15
+	scryptR      = 8
+	scryptP      = 1
+	scryptKeyLen = 32
+	saltSize     = 16
+)
+
+var (
+	ErrPassphraseRequired = errors.New("config is encrypted, passphrase required")
+	ErrWrongPassphrase    = errors.New("wrong passphrase or corrupted config")
+
+	// Unlock is called once per session when the configuration file is
+	// encrypted and no passphrase has been set yet.
+	Unlock func() (string, error)
+
+	// passphrase encrypts the configuration file on write, empty for plain JSON.
+	passphrase string
+)
+
+// envelope is the on-disk layout of an encrypted configuration file.
+// The whole JSON configuration is sealed with AES-256-GCM using a key
+// derived from the passphrase with scrypt.
+type envelope struct {
+	Format string `json:"format"`
+	KDF    string `json:"kdf"`
+	N      int    `json:"n"`
+	R      int    `json:"r"`
+	P      int    `json:"p"`
+	Salt   []byte `json:"salt"`
+	Nonce  []byte `json:"nonce"`
+	Data   []byte `json:"data"`
+}
+
+// SetPassphrase sets the passphrase used to encrypt the configuration file
+// on the next write. An empty passphrase writes plain JSON.
+func SetPassphrase(p string) {
+	passphrase = p
+}
+
+// Encrypted reports whether the configuration is written encrypted.
+func Encrypted() bool {
+	return passphrase != ""
+}
+
+// File returns the path of the initialized configuration file.
+func File() string {
+	return filepath.Join(ConfigPath, ConfigName+"."+ConfigType)
+}
+
+// IsEncrypted reports whether data is an encrypted configuration file.
+func IsEncrypted(data []byte) bool {
+	var e envelope
+	return json.Unmarshal(data, &e) == nil && e.Format == EncryptedFormat
+}
+
+// Encrypt seals plain with a key derived from pass.
+func Encrypt(plain []byte, pass string) ([]byte, error) {
+	e := envelope{Format: EncryptedFormat, KDF: "scrypt", N: scryptN, R: scryptR, P: scryptP}
+	e.Salt = make([]byte, saltSize)
+	if _, err := rand.Read(e.Salt); err != nil {
+		return nil, err
+	}
+	gcm, err := newGCM(pass, e)
+	if err != nil {
+		return nil, err
+	}
+	e.Nonce = make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(e.Nonce); err != nil {
+		return nil, err
+	}
+	e.Data = gcm.Seal(nil, e.Nonce, plain, []byte(e.Format))
+	return json.MarshalIndent(e, "", "  ")
+}
+
+// Decrypt opens data sealed by Encrypt.
+func Decrypt(data []byte, pass string) ([]byte, error) {
+	var e envelope
+	if err := json.Unmarshal(data, &e); err != nil {
+		return nil, err
+	}
+	if e.Format != EncryptedFormat || e.KDF != "scrypt" {
+		return nil, ErrWrongPassphrase
+	}
+	gcm, err := newGCM(pass, e)
+	if err != nil {
+		return nil, err
+	}
+	if len(e.Nonce) != gcm.NonceSize() {
+		return nil, ErrWrongPassphrase
+	}
+	plain, err := gcm.Open(nil, e.Nonce, e.Data, []byte(e.Format))
+	if err != nil {
+		return nil, ErrWrongPassphrase
+	}
+	return plain, nil
+}
+
+func newGCM(pass string, e envelope) (cipher.AEAD, error) {
+	key, err := scrypt.Key([]byte(pass), e.Salt, e.N, e.R, e.P, scryptKeyLen)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// readConfig reads the configuration file, decrypting it if needed.
+// The passphrase is asked for through Unlock only once per session.
+func readConfig(file string) ([]byte, error) {
+	b, err := os.ReadFile(file)
+	if err != nil || !IsEncrypted(b) {
+		return b, err
+	}
+	if passphrase == "" {
+		if Unlock == nil {
+			return nil, ErrPassphraseRequired
+		}
+		p, err := Unlock()
+		if err != nil {
+			return nil, err
+		}
+		if p == "" {
+			return nil, ErrPassphraseRequired
+		}
+		plain, err := Decrypt(b, p)
+		if err != nil {
+			return nil, err
+		}
+		passphrase = p
+		return plain, nil
+	}
+	return Decrypt(b, passphrase)
+}
+
+// Save writes the current settings to the configuration file,
+// encrypted when a passphrase has been set.
+func Save() error {
+	if passphrase == "" {
+		return viper.WriteConfig()
+	}
+	plain, err := json.MarshalIndent(viper.AllSettings(), "", "  ")
+	if err != nil {
+		return err
+	}
+	b, err := Encrypt(plain, passphrase)
+	if err != nil {
+		return err
+	}
+	file := viper.ConfigFileUsed()
+	if file == "" {
+		file = File()
+	}
+	if err := os.WriteFile(file+".tmp", b, 0600); err != nil {
+		return err
+	}
+	return os.Rename(file+".tmp", file)
+}
+
+// readInConfig loads file into viper, decrypting it if needed.
+func readInConfig(file string) error {
+	b, err := readConfig(file)
+	if err != nil {
+		return err
+	}
+	return viper.ReadConfig(bytes.NewReader(b))
+}
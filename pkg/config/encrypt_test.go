@@ -0,0 +1,31 @@
+package config
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestEncryptDecrypt(t *testing.T) {
+	plain := []byte(`{"history":[{"data_key":"abcd"}]}`)
+	b, err := Encrypt(plain, "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !IsEncrypted(b) || IsEncrypted(plain) {
+		t.Fatal("IsEncrypted mismatch")
+	}
+	if bytes.Contains(b, []byte("abcd")) {
+		t.Fatal("encrypted config contains plain text")
+	}
+
+	got, err := Decrypt(b, "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, plain) {
+		t.Fatalf("got %s, want %s", got, plain)
+	}
+	if _, err := Decrypt(b, "wrong"); err != ErrWrongPassphrase {
+		t.Fatalf("got %v, want ErrWrongPassphrase", err)
+	}
+}
@@ -0,0 +1,158 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// UnknownKey is a key present in the configuration file but not declared by the config struct.
+type UnknownKey struct {
+	Path    string // e.g. history[0].data_dri
+	Suggest string // closest declared key, empty if nothing is similar
+}
+
+func (k UnknownKey) String() string {
+	if k.Suggest != "" {
+		return fmt.Sprintf("unknown key %q, did you mean %q?", k.Path, k.Suggest)
+	}
+	return fmt.Sprintf("unknown key %q", k.Path)
+}
+
+// ReadFile reads the raw configuration file as a generic map.
+// Only JSON is supported, which is the format used by chatlog.
+func ReadFile(file string) (map[string]interface{}, error) {
+	b, err := readConfig(file)
+	if err != nil {
+		return nil, err
+	}
+	raw := make(map[string]interface{})
+	if len(strings.TrimSpace(string(b))) == 0 {
+		return raw, nil
+	}
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return nil, err
+	}
+	return raw, nil
+}
+
+// UnknownKeys walks the raw configuration and reports keys that are not
+// declared by the mapstructure tags of conf.
+func UnknownKeys(raw map[string]interface{}, conf interface{}) []UnknownKey {
+	var keys []UnknownKey
+	walk(raw, reflect.TypeOf(conf), "", &keys)
+	sort.Slice(keys, func(i, j int) bool { return keys[i].Path < keys[j].Path })
+	return keys
+}
+
+func walk(value interface{}, t reflect.Type, path string, keys *[]UnknownKey) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			return
+		}
+		fields := structFields(t)
+		names := make([]string, 0, len(fields))
+		for name := range fields {
+			names = append(names, name)
+		}
+		for k, v := range m {
+			field, ok := fields[strings.ToLower(k)]
+			if !ok {
+				*keys = append(*keys, UnknownKey{Path: join(path, k), Suggest: suggest(k, names)})
+				continue
+			}
+			walk(v, field, join(path, k), keys)
+		}
+	case reflect.Slice, reflect.Array:
+		s, ok := value.([]interface{})
+		if !ok {
+			return
+		}
+		for i, v := range s {
+			walk(v, t.Elem(), fmt.Sprintf("%s[%d]", path, i), keys)
+		}
+	case reflect.Map:
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			return
+		}
+		for k, v := range m {
+			walk(v, t.Elem(), join(path, k), keys)
+		}
+	}
+}
+
+// structFields returns the declared keys of a struct, keyed by lower-cased mapstructure name.
+func structFields(t reflect.Type) map[string]reflect.Type {
+	fields := make(map[string]reflect.Type)
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name, opts, _ := strings.Cut(f.Tag.Get("mapstructure"), ",")
+		if name == "-" {
+			continue
+		}
+		if f.Anonymous && strings.Contains(opts, "squash") {
+			for k, v := range structFields(f.Type) {
+				fields[k] = v
+			}
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		fields[strings.ToLower(name)] = f.Type
+	}
+	return fields
+}
+
+func join(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// suggest returns the candidate closest to key, or empty if none is within a small edit distance.
+func suggest(key string, candidates []string) string {
+	key = strings.ToLower(key)
+	best, bestDist := "", 3
+	for _, c := range candidates {
+		if d := distance(key, c); d < bestDist || (d == bestDist && c < best) {
+			best, bestDist = c, d
+		}
+	}
+	return best
+}
+
+// distance computes the Levenshtein distance between a and b.
+func distance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(rb)]
+}
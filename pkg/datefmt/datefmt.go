@@ -0,0 +1,160 @@
+// Package datefmt 导出、统计与 Web 页面中日期的显示方式：日期格式、星期与月份名称的语言、每周的第一天与农历注记
+package datefmt
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aspnmy/chatlog/pkg/lunar"
+)
+
+// 语言
+const (
+	LocaleEN = "en"
+	LocaleZH = "zh"
+)
+
+// Presets 预置的日期格式，也可以直接使用 Go 的时间格式
+var Presets = map[string]string{
+	"iso": time.DateOnly,
+	"zh":  "2006年1月2日",
+	"us":  "01/02/2006",
+	"eu":  "02.01.2006",
+}
+
+// Style 日期的显示方式，零值按 ISO 格式显示日期，每周从周日开始
+type Style struct {
+	Layout    string       // Go 时间格式，为空时为 2006-01-02
+	Locale    string       // zh 时星期与月份名称显示为中文，默认为英文
+	WeekStart time.Weekday // 每周的第一天
+	Lunar     bool         // 在日期后附加农历月日与传统节日
+}
+
+// Default 未配置时使用的显示方式
+var Default = Style{Layout: time.DateOnly, WeekStart: time.Monday}
+
+// New 按配置创建显示方式，format 为预置格式名或 Go 时间格式，weekStart 为英文星期名称，均可以为空
+func New(format, locale, weekStart string, showLunar bool) (Style, error) {
+	s := Default
+	s.Lunar = showLunar
+	if format != "" {
+		if layout, ok := Presets[strings.ToLower(format)]; ok {
+			s.Layout = layout
+		} else if time.Date(1999, 11, 23, 0, 0, 0, 0, time.UTC).Format(format) == format {
+			// 不包含任何年月日占位符的格式化结果与格式本身相同
+			return s, fmt.Errorf("invalid date format %q, expected a preset (iso, zh, us, eu) or a Go time layout", format)
+		} else {
+			s.Layout = format
+		}
+	}
+	switch l := strings.ToLower(locale); l {
+	case "", LocaleEN:
+	case LocaleZH:
+		s.Locale = l
+	default:
+		return s, fmt.Errorf("unsupported locale %q, expected en or zh", locale)
+	}
+	if weekStart != "" {
+		d, ok := ParseWeekday(weekStart)
+		if !ok {
+			return s, fmt.Errorf("invalid week start %q, expected monday-sunday", weekStart)
+		}
+		s.WeekStart = d
+	}
+	return s, nil
+}
+
+// ParseWeekday 解析英文星期名称，支持全称与前三个字母
+func ParseWeekday(s string) (time.Weekday, bool) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		name := strings.ToLower(d.String())
+		if s == name || s == name[:3] {
+			return d, true
+		}
+	}
+	return 0, false
+}
+
+// Format 按 Layout 格式化日期，不附加农历注记
+func (s Style) Format(t time.Time) string {
+	layout := s.Layout
+	if layout == "" {
+		layout = time.DateOnly
+	}
+	return s.localize(t.Format(layout))
+}
+
+// Date 格式化日期，开启 Lunar 时在后面附加农历注记，如 2024-02-10（正月初一 春节）
+func (s Style) Date(t time.Time) string {
+	date := s.Format(t)
+	if s.Lunar {
+		if note := lunar.Annotate(t); note != "" {
+			date += "（" + note + "）"
+		}
+	}
+	return date
+}
+
+// DateTime 返回带日期的时间格式，timeLayout 中包含年月日时将日期部分替换为 Layout，只有时刻时原样返回
+// 用于 txt 等逐条显示时间的格式
+func (s Style) DateTime(timeLayout string) string {
+	if s.Layout == "" || s.Layout == time.DateOnly {
+		return timeLayout
+	}
+	if date, clock, ok := strings.Cut(timeLayout, " "); ok && strings.Contains(date, "01") {
+		return s.Layout + " " + clock
+	}
+	return timeLayout
+}
+
+// WeekOf 返回 t 所在周第一天的 0 点
+func (s Style) WeekOf(t time.Time) time.Time {
+	days := (int(t.Weekday()) - int(s.WeekStart) + 7) % 7
+	return time.Date(t.Year(), t.Month(), t.Day()-days, 0, 0, 0, 0, t.Location())
+}
+
+// Weekdays 返回按 WeekStart 排列的一周七天
+func (s Style) Weekdays() []time.Weekday {
+	days := make([]time.Weekday, 7)
+	for i := range days {
+		days[i] = (s.WeekStart + time.Weekday(i)) % 7
+	}
+	return days
+}
+
+var (
+	zhWeekdays      = [...]string{"星期日", "星期一", "星期二", "星期三", "星期四", "星期五", "星期六"}
+	zhShortWeekdays = [...]string{"周日", "周一", "周二", "周三", "周四", "周五", "周六"}
+	zhMonths        = [...]string{"一月", "二月", "三月", "四月", "五月", "六月", "七月", "八月", "九月", "十月", "十一月", "十二月"}
+)
+
+// WeekdayName 返回星期名称，zh 时为 周一 等
+func (s Style) WeekdayName(d time.Weekday) string {
+	if s.Locale == LocaleZH {
+		return zhShortWeekdays[d]
+	}
+	return d.String()[:3]
+}
+
+// localize 将格式化结果中的英文星期与月份名称替换为 Locale 对应的名称
+func (s Style) localize(v string) string {
+	if s.Locale != LocaleZH {
+		return v
+	}
+	// 先替换全称，避免 Monday 中的 Mon 被单独替换
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		v = strings.ReplaceAll(v, d.String(), zhWeekdays[d])
+	}
+	for m := time.January; m <= time.December; m++ {
+		v = strings.ReplaceAll(v, m.String(), zhMonths[m-1])
+	}
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		v = strings.ReplaceAll(v, d.String()[:3], zhShortWeekdays[d])
+	}
+	for m := time.January; m <= time.December; m++ {
+		v = strings.ReplaceAll(v, m.String()[:3], zhMonths[m-1])
+	}
+	return v
+}
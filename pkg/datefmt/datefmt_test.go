@@ -0,0 +1,55 @@
+package datefmt
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNew(t *testing.T) {
+	s, err := New("", "", "", false)
+	if err != nil || s != Default {
+		t.Fatalf("New() = %+v, %v, want Default", s, err)
+	}
+	s, err = New("zh", "zh", "sun", true)
+	if err != nil || s.Layout != "2006年1月2日" || s.Locale != LocaleZH || s.WeekStart != time.Sunday || !s.Lunar {
+		t.Fatalf("New(zh) = %+v, %v", s, err)
+	}
+	s, err = New("2006/01/02 Mon", "", "", false)
+	if err != nil || s.Layout != "2006/01/02 Mon" {
+		t.Fatalf("New(layout) = %+v, %v", s, err)
+	}
+	for _, args := range [][3]string{{"yyyy-mm-dd", "", ""}, {"", "fr", ""}, {"", "", "someday"}} {
+		if _, err := New(args[0], args[1], args[2], false); err == nil {
+			t.Errorf("New(%q) want error", args)
+		}
+	}
+}
+
+func TestStyle(t *testing.T) {
+	d := time.Date(2024, 2, 10, 9, 30, 0, 0, time.Local) // 周六，春节
+
+	s := Style{Layout: "Jan 2 Monday", Locale: LocaleZH, Lunar: true}
+	if got := s.Date(d); got != "二月 10 星期六（正月初一 春节）" {
+		t.Errorf("Date = %q", got)
+	}
+	s = Style{Layout: "2006/01/02 Mon", Locale: LocaleZH}
+	if got := s.Date(d); got != "2024/02/10 周六" {
+		t.Errorf("Date = %q", got)
+	}
+	if got := s.DateTime("2006-01-02 15:04:05"); got != "2006/01/02 Mon 15:04:05" {
+		t.Errorf("DateTime = %q", got)
+	}
+	if got := s.DateTime("15:04:05"); got != "15:04:05" {
+		t.Errorf("DateTime = %q", got)
+	}
+
+	for start, want := range map[time.Weekday]int{time.Monday: 5, time.Sunday: 4, time.Saturday: 10} {
+		s := Style{WeekStart: start}
+		if got := s.WeekOf(d); got.Day() != want || got.Hour() != 0 {
+			t.Errorf("WeekOf with %s = %s, want day %d", start, got, want)
+		}
+		if days := s.Weekdays(); days[0] != start || days[6] != (start+6)%7 {
+			t.Errorf("Weekdays with %s = %v", start, days)
+		}
+	}
+}
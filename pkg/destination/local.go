@@ -0,0 +1,93 @@
+package destination
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/aspnmy/chatlog/pkg/util"
+)
+
+// Local 本地目录（包括已挂载的网络磁盘）
+type Local struct {
+	root string
+}
+
+// NewLocal 创建本地目录目标，目录不存在时自动创建
+func NewLocal(root string) (*Local, error) {
+	if err := util.PrepareDir(root); err != nil {
+		return nil, err
+	}
+	return &Local{root: root}, nil
+}
+
+func (l *Local) Create(name string) (io.WriteCloser, error) {
+	name, err := cleanName(name)
+	if err != nil {
+		return nil, err
+	}
+	p := filepath.Join(l.root, filepath.FromSlash(name))
+	if err := util.PrepareDir(filepath.Dir(p)); err != nil {
+		return nil, err
+	}
+	return &atomicFile{path: p}, nil
+}
+
+func (l *Local) Link(name, src string) error {
+	name, err := cleanName(name)
+	if err != nil {
+		return err
+	}
+	p := filepath.Join(l.root, filepath.FromSlash(name))
+	if err := util.PrepareDir(filepath.Dir(p)); err != nil {
+		return err
+	}
+	if err := os.Remove(p); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return os.Link(src, p)
+}
+
+func (l *Local) String() string {
+	return l.root
+}
+
+func (l *Local) Close() error {
+	return nil
+}
+
+// atomicFile 先写入临时文件，关闭时再重命名，避免中断后留下不完整的文件；写入失败时由 Abort 删除临时文件
+type atomicFile struct {
+	path string
+	f    *os.File
+}
+
+func (a *atomicFile) Write(p []byte) (int, error) {
+	if a.f == nil {
+		f, err := os.Create(a.path + ".tmp")
+		if err != nil {
+			return 0, err
+		}
+		a.f = f
+	}
+	return a.f.Write(p)
+}
+
+func (a *atomicFile) Close() error {
+	if a.f == nil {
+		// 未写入任何内容，创建空文件
+		return os.WriteFile(a.path, nil, 0644)
+	}
+	if err := a.f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(a.path+".tmp", a.path)
+}
+
+func (a *atomicFile) Abort() error {
+	if a.f == nil {
+		return nil
+	}
+	a.f.Close()
+	return os.Remove(a.path + ".tmp")
+}
@@ -0,0 +1,104 @@
+package destination
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLocal(t *testing.T) {
+	root := t.TempDir()
+	d, err := New(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	target := filepath.Join(root, "a", "b", "c.txt")
+
+	// 关闭前只写入临时文件，关闭后替换为目标文件
+	w := create(t, d, "a/b/c.txt", "first")
+	if _, err := os.Stat(target); !os.IsNotExist(err) {
+		t.Fatalf("target exists before close: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	assertFile(t, target, "first")
+
+	w = create(t, d, "a/b/c.txt", "second")
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	assertFile(t, target, "second")
+
+	// 放弃写入时保留原文件
+	w = create(t, d, "a/b/c.txt", "partial")
+	if err := Abort(w); err != nil {
+		t.Fatal(err)
+	}
+	assertFile(t, target, "second")
+
+	// 未写入内容时创建空文件
+	w, err = d.Create("empty.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	assertFile(t, filepath.Join(root, "empty.txt"), "")
+
+	// 路径不能写出根目录
+	w = create(t, d, "../../escape.txt", "x")
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	assertFile(t, filepath.Join(root, "escape.txt"), "x")
+	if _, err := d.Create(".."); err == nil {
+		t.Error("Create(..) succeeded")
+	}
+}
+
+func TestLocalLink(t *testing.T) {
+	root := t.TempDir()
+	d, err := NewLocal(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := filepath.Join(t.TempDir(), "src.txt")
+	if err := os.WriteFile(src, []byte("media"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := d.Link("media/src.txt", src); err != nil {
+			t.Skipf("hard links not supported: %v", err)
+		}
+	}
+	assertFile(t, filepath.Join(root, "media", "src.txt"), "media")
+}
+
+// create 创建文件并写入 content，返回未关闭的文件
+func create(t *testing.T, d Destination, name, content string) io.WriteCloser {
+	t.Helper()
+	w, err := d.Create(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.WriteString(w, content); err != nil {
+		t.Fatal(err)
+	}
+	return w
+}
+
+// assertFile 检查文件内容，且没有留下临时文件
+func assertFile(t *testing.T, file, want string) {
+	t.Helper()
+	data, err := os.ReadFile(file)
+	if err != nil || string(data) != want {
+		t.Fatalf("%s = %q, %v, want %q", file, data, err, want)
+	}
+	if _, err := os.Stat(file + ".tmp"); !os.IsNotExist(err) {
+		t.Fatalf("%s.tmp left behind: %v", file, err)
+	}
+}
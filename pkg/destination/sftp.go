@@ -0,0 +1,388 @@
+package destination
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+const (
+	EnvSFTPPassword = "CHATLOG_SFTP_PASSWORD"
+
+	sftpVersion   = 3
+	sftpChunkSize = 32 * 1024
+
+	sshFxpInit    = 1
+	sshFxpVersion = 2
+	sshFxpOpen    = 3
+	sshFxpClose   = 4
+	sshFxpWrite   = 6
+	sshFxpMkdir   = 14
+	sshFxpRemove  = 13
+	sshFxpRename  = 18
+	sshFxpStatus  = 101
+	sshFxpHandle  = 102
+
+	sshFxfWrite = 0x02
+	sshFxfCreat = 0x08
+	sshFxfTrunc = 0x10
+
+	sshFxOK = 0
+)
+
+// SFTP 基于 SSH 的远程目录，适用于开启了 SFTP 的 NAS
+// 仅实现了导出所需的 SFTP v3 子集：创建目录、写文件、重命名
+type SFTP struct {
+	client  *ssh.Client
+	session *ssh.Session
+	in      io.WriteCloser
+	out     io.Reader
+
+	root string
+	desc string
+	dirs map[string]bool // 已创建的目录，由 mu 保护
+
+	mu sync.Mutex
+	id uint32
+}
+
+// NewSFTP 连接 SFTP 服务器
+// 密码可以写在地址中，也可以通过环境变量 CHATLOG_SFTP_PASSWORD 提供
+// 默认使用 ~/.ssh/known_hosts 校验主机密钥，可通过 insecure=1 跳过
+func NewSFTP(u *url.URL) (*SFTP, error) {
+	user := u.User.Username()
+	if user == "" {
+		return nil, fmt.Errorf("sftp user is required")
+	}
+
+	auth := make([]ssh.AuthMethod, 0)
+	password, ok := u.User.Password()
+	if !ok {
+		password = os.Getenv(EnvSFTPPassword)
+	}
+	if password != "" {
+		auth = append(auth, ssh.Password(password))
+	}
+	if signer, err := loadSigner(u.Query().Get("key")); err != nil {
+		return nil, err
+	} else if signer != nil {
+		auth = append(auth, ssh.PublicKeys(signer))
+	}
+	if len(auth) == 0 {
+		return nil, fmt.Errorf("sftp password or private key is required")
+	}
+
+	hostKeyCallback := ssh.InsecureIgnoreHostKey()
+	if u.Query().Get("insecure") != "1" {
+		home, _ := os.UserHomeDir()
+		cb, err := knownhosts.New(filepath.Join(home, ".ssh", "known_hosts"))
+		if err != nil {
+			return nil, fmt.Errorf("load known_hosts failed (add insecure=1 to skip host key check): %w", err)
+		}
+		hostKeyCallback = cb
+	}
+
+	host := u.Host
+	if u.Port() == "" {
+		host = net.JoinHostPort(u.Hostname(), "22")
+	}
+
+	client, err := ssh.Dial("tcp", host, &ssh.ClientConfig{
+		User:            user,
+		Auth:            auth,
+		HostKeyCallback: hostKeyCallback,
+		Timeout:         15 * time.Second,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("ssh connect %s failed: %w", host, err)
+	}
+
+	s := &SFTP{
+		client: client,
+		root:   u.Path,
+		desc:   fmt.Sprintf("sftp://%s@%s%s", user, host, u.Path),
+		dirs:   make(map[string]bool),
+	}
+	if s.root == "" {
+		s.root = "."
+	}
+
+	if err := s.init(); err != nil {
+		client.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// expandHome 将以 ~ 开头的路径展开为用户主目录下的路径，shell 不会展开 URL 参数中的 ~
+func expandHome(p string) string {
+	if p != "~" && !strings.HasPrefix(p, "~/") && !strings.HasPrefix(p, `~\`) {
+		return p
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return p
+	}
+	return filepath.Join(home, p[1:])
+}
+
+func loadSigner(keyFile string) (ssh.Signer, error) {
+	keyFile = expandHome(keyFile)
+	files := []string{keyFile}
+	if keyFile == "" {
+		home, _ := os.UserHomeDir()
+		files = []string{
+			filepath.Join(home, ".ssh", "id_ed25519"),
+			filepath.Join(home, ".ssh", "id_rsa"),
+		}
+	}
+	for _, f := range files {
+		b, err := os.ReadFile(f)
+		if err != nil {
+			if keyFile != "" {
+				return nil, fmt.Errorf("read private key %s failed: %w", f, err)
+			}
+			continue
+		}
+		signer, err := ssh.ParsePrivateKey(b)
+		if err != nil {
+			if keyFile != "" {
+				return nil, fmt.Errorf("parse private key %s failed: %w", f, err)
+			}
+			continue
+		}
+		return signer, nil
+	}
+	return nil, nil
+}
+
+func (s *SFTP) init() error {
+	session, err := s.client.NewSession()
+	if err != nil {
+		return err
+	}
+	in, err := session.StdinPipe()
+	if err != nil {
+		return err
+	}
+	out, err := session.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := session.RequestSubsystem("sftp"); err != nil {
+		return fmt.Errorf("request sftp subsystem failed: %w", err)
+	}
+	s.session, s.in, s.out = session, in, out
+
+	// SSH_FXP_INIT 没有请求 ID
+	if err := s.writePacket(sshFxpInit, u32(sftpVersion)); err != nil {
+		return err
+	}
+	typ, _, err := s.readPacket()
+	if err != nil {
+		return err
+	}
+	if typ != sshFxpVersion {
+		return fmt.Errorf("unexpected sftp packet type: %d", typ)
+	}
+	return nil
+}
+
+func (s *SFTP) Create(name string) (io.WriteCloser, error) {
+	name, err := cleanName(name)
+	if err != nil {
+		return nil, err
+	}
+	p := path.Join(s.root, name)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.mkdirAll(path.Dir(p)); err != nil {
+		return nil, err
+	}
+
+	tmp := p + ".tmp"
+	handle, err := s.call(sshFxpOpen, str(tmp), u32(sshFxfWrite|sshFxfCreat|sshFxfTrunc), u32(0))
+	if err != nil {
+		return nil, fmt.Errorf("open %s failed: %w", tmp, err)
+	}
+	return &sftpFile{s: s, handle: handle, path: p, tmp: tmp}, nil
+}
+
+// mkdirAll 逐级创建目录，已创建的目录记录在 s.dirs 中，调用方需持有 s.mu
+func (s *SFTP) mkdirAll(dir string) error {
+	if dir == "." || dir == "/" || s.dirs[dir] {
+		return nil
+	}
+	if err := s.mkdirAll(path.Dir(dir)); err != nil {
+		return err
+	}
+	// 目录已存在时服务器会返回失败状态，这里忽略错误，由后续的写入操作暴露真实问题
+	s.call(sshFxpMkdir, str(dir), u32(0))
+	s.dirs[dir] = true
+	return nil
+}
+
+func (s *SFTP) String() string {
+	return s.desc
+}
+
+func (s *SFTP) Close() error {
+	if s.session != nil {
+		s.session.Close()
+	}
+	return s.client.Close()
+}
+
+// call 发送请求并等待响应，返回 HANDLE 的内容或 STATUS 错误
+// 调用方需持有 s.mu
+func (s *SFTP) call(typ byte, fields ...[]byte) ([]byte, error) {
+	s.id++
+	id := s.id
+	payload := u32(id)
+	for _, f := range fields {
+		payload = append(payload, f...)
+	}
+	if err := s.writePacket(typ, payload); err != nil {
+		return nil, err
+	}
+
+	respType, resp, err := s.readPacket()
+	if err != nil {
+		return nil, err
+	}
+	if len(resp) < 4 || binary.BigEndian.Uint32(resp) != id {
+		return nil, fmt.Errorf("sftp response id mismatch")
+	}
+	resp = resp[4:]
+
+	switch respType {
+	case sshFxpHandle:
+		handle, _ := readString(resp)
+		return handle, nil
+	case sshFxpStatus:
+		if len(resp) < 4 {
+			return nil, fmt.Errorf("invalid sftp status packet")
+		}
+		code := binary.BigEndian.Uint32(resp)
+		if code == sshFxOK {
+			return nil, nil
+		}
+		msg, _ := readString(resp[4:])
+		return nil, fmt.Errorf("sftp error %d: %s", code, msg)
+	default:
+		return nil, fmt.Errorf("unexpected sftp packet type: %d", respType)
+	}
+}
+
+func (s *SFTP) writePacket(typ byte, payload []byte) error {
+	buf := make([]byte, 0, 5+len(payload))
+	buf = append(buf, u32(uint32(len(payload)+1))...)
+	buf = append(buf, typ)
+	buf = append(buf, payload...)
+	_, err := s.in.Write(buf)
+	return err
+}
+
+func (s *SFTP) readPacket() (byte, []byte, error) {
+	header := make([]byte, 5)
+	if _, err := io.ReadFull(s.out, header); err != nil {
+		return 0, nil, err
+	}
+	length := binary.BigEndian.Uint32(header)
+	if length < 1 || length > 1<<24 {
+		return 0, nil, fmt.Errorf("invalid sftp packet length: %d", length)
+	}
+	body := make([]byte, length-1)
+	if _, err := io.ReadFull(s.out, body); err != nil {
+		return 0, nil, err
+	}
+	return header[4], body, nil
+}
+
+type sftpFile struct {
+	s      *SFTP
+	handle []byte
+	path   string
+	tmp    string
+	offset uint64
+}
+
+func (f *sftpFile) Write(p []byte) (int, error) {
+	f.s.mu.Lock()
+	defer f.s.mu.Unlock()
+
+	written := 0
+	for len(p) > 0 {
+		n := len(p)
+		if n > sftpChunkSize {
+			n = sftpChunkSize
+		}
+		if _, err := f.s.call(sshFxpWrite, str(f.handle), u64(f.offset), str(p[:n])); err != nil {
+			return written, err
+		}
+		f.offset += uint64(n)
+		written += n
+		p = p[n:]
+	}
+	return written, nil
+}
+
+func (f *sftpFile) Close() error {
+	f.s.mu.Lock()
+	defer f.s.mu.Unlock()
+
+	if _, err := f.s.call(sshFxpClose, str(f.handle)); err != nil {
+		return err
+	}
+	// SFTP v3 的 RENAME 不会覆盖已存在的文件，先删除旧文件
+	f.s.call(sshFxpRemove, str(f.path))
+	if _, err := f.s.call(sshFxpRename, str(f.tmp), str(f.path)); err != nil {
+		return fmt.Errorf("rename %s failed: %w", f.tmp, err)
+	}
+	return nil
+}
+
+func (f *sftpFile) Abort() error {
+	f.s.mu.Lock()
+	defer f.s.mu.Unlock()
+
+	f.s.call(sshFxpClose, str(f.handle))
+	if _, err := f.s.call(sshFxpRemove, str(f.tmp)); err != nil {
+		return fmt.Errorf("remove %s failed: %w", f.tmp, err)
+	}
+	return nil
+}
+
+func u32(v uint32) []byte {
+	return binary.BigEndian.AppendUint32(nil, v)
+}
+
+func u64(v uint64) []byte {
+	return binary.BigEndian.AppendUint64(nil, v)
+}
+
+func str[T string | []byte](v T) []byte {
+	return append(u32(uint32(len(v))), v...)
+}
+
+func readString(b []byte) ([]byte, []byte) {
+	if len(b) < 4 {
+		return nil, nil
+	}
+	n := binary.BigEndian.Uint32(b)
+	if int(n) > len(b)-4 {
+		return nil, nil
+	}
+	return b[4 : 4+n], b[4+n:]
+}
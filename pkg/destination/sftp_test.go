@@ -0,0 +1,266 @@
+package destination
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+)
+
+func TestSFTP(t *testing.T) {
+	root := t.TempDir()
+	d := dialTestSFTP(t, root, "secret")
+	defer d.Close()
+	target := filepath.Join(root, "a", "b", "c.txt")
+
+	// 跨越多个写入块，关闭前只写入临时文件
+	big := strings.Repeat("0123456789", sftpChunkSize/4)
+	w := create(t, d, "a/b/c.txt", big)
+	if _, err := os.Stat(target); !os.IsNotExist(err) {
+		t.Fatalf("target exists before close: %v", err)
+	}
+	if _, err := os.Stat(target + ".tmp"); err != nil {
+		t.Fatalf("tmp file missing: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	assertFile(t, target, big)
+
+	// 服务器的 RENAME 不覆盖已存在的文件，关闭时需先删除旧文件
+	w = create(t, d, "a/b/c.txt", "second")
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	assertFile(t, target, "second")
+
+	// 放弃写入时保留原文件并删除临时文件
+	w = create(t, d, "a/b/c.txt", "partial")
+	if err := Abort(w); err != nil {
+		t.Fatal(err)
+	}
+	assertFile(t, target, "second")
+
+	// 并发写入不同目录
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			w, err := d.Create(fmt.Sprintf("dir%d/sub/%d.txt", i%3, i))
+			if err == nil {
+				_, err = io.WriteString(w, strconv.Itoa(i))
+			}
+			if err == nil {
+				err = w.Close()
+			}
+			errs <- err
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 0; i < 8; i++ {
+		assertFile(t, filepath.Join(root, fmt.Sprintf("dir%d", i%3), "sub", fmt.Sprintf("%d.txt", i)), strconv.Itoa(i))
+	}
+}
+
+func TestSFTPAuth(t *testing.T) {
+	root := t.TempDir()
+	addr := startTestSFTP(t, root, "secret")
+	t.Setenv("HOME", t.TempDir())
+
+	if _, err := New("sftp://user:wrong@" + addr + filepath.ToSlash(root) + "?insecure=1"); err == nil {
+		t.Error("connected with a wrong password")
+	}
+	// 未跳过主机密钥校验时，known_hosts 中没有该主机
+	if _, err := New("sftp://user:secret@" + addr + filepath.ToSlash(root)); err == nil {
+		t.Error("connected to an unknown host")
+	}
+	t.Setenv(EnvSFTPPassword, "secret")
+	d, err := New("sftp://user@" + addr + filepath.ToSlash(root) + "?insecure=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.Close()
+}
+
+// dialTestSFTP 启动测试服务器并连接，HOME 指向临时目录以免读取本机的私钥
+func dialTestSFTP(t *testing.T, root, password string) Destination {
+	t.Helper()
+	addr := startTestSFTP(t, root, password)
+	t.Setenv("HOME", t.TempDir())
+	d, err := New("sftp://user:" + password + "@" + addr + filepath.ToSlash(root) + "?insecure=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+// startTestSFTP 在本地端口启动一个仅支持密码认证的 SSH 服务器
+// 其 sftp 子系统直接操作本地文件系统，行为与 OpenSSH 的 SFTP v3 一致：RENAME 不覆盖已存在的文件
+func startTestSFTP(t *testing.T, root, password string) string {
+	t.Helper()
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := &ssh.ServerConfig{
+		PasswordCallback: func(_ ssh.ConnMetadata, pass []byte) (*ssh.Permissions, error) {
+			if string(pass) != password {
+				return nil, fmt.Errorf("password rejected")
+			}
+			return nil, nil
+		},
+	}
+	config.AddHostKey(signer)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go serveSSH(conn, config)
+		}
+	}()
+	return l.Addr().String()
+}
+
+func serveSSH(conn net.Conn, config *ssh.ServerConfig) {
+	defer conn.Close()
+	_, chans, reqs, err := ssh.NewServerConn(conn, config)
+	if err != nil {
+		return
+	}
+	go ssh.DiscardRequests(reqs)
+	for nc := range chans {
+		if nc.ChannelType() != "session" {
+			nc.Reject(ssh.UnknownChannelType, "unknown channel type")
+			continue
+		}
+		ch, reqs, err := nc.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			for req := range reqs {
+				name, _ := readString(req.Payload)
+				ok := req.Type == "subsystem" && string(name) == "sftp"
+				req.Reply(ok, nil)
+				if ok {
+					go func() {
+						defer ch.Close()
+						serveSFTP(ch)
+					}()
+				}
+			}
+		}()
+	}
+}
+
+// serveSFTP 处理客户端用到的 SFTP v3 请求
+func serveSFTP(rw io.ReadWriter) {
+	files := make(map[string]*os.File)
+	next := 0
+	defer func() {
+		for _, f := range files {
+			f.Close()
+		}
+	}()
+
+	reply := func(typ byte, payload []byte) error {
+		buf := append(u32(uint32(len(payload)+1)), typ)
+		_, err := rw.Write(append(buf, payload...))
+		return err
+	}
+	status := func(id uint32, err error) error {
+		code, msg := uint32(sshFxOK), ""
+		if err != nil {
+			code, msg = 4, err.Error() // SSH_FX_FAILURE
+		}
+		return reply(sshFxpStatus, append(append(u32(id), u32(code)...), append(str(msg), str("")...)...))
+	}
+
+	header := make([]byte, 5)
+	for {
+		if _, err := io.ReadFull(rw, header); err != nil {
+			return
+		}
+		body := make([]byte, binary.BigEndian.Uint32(header)-1)
+		if _, err := io.ReadFull(rw, body); err != nil {
+			return
+		}
+		if header[4] == sshFxpInit {
+			if reply(sshFxpVersion, u32(sftpVersion)) != nil {
+				return
+			}
+			continue
+		}
+
+		id := binary.BigEndian.Uint32(body)
+		arg, rest := readString(body[4:])
+		var err error
+		switch header[4] {
+		case sshFxpOpen:
+			var f *os.File
+			f, err = os.OpenFile(string(arg), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+			if err == nil {
+				next++
+				handle := strconv.Itoa(next)
+				files[handle] = f
+				if reply(sshFxpHandle, append(u32(id), str(handle)...)) != nil {
+					return
+				}
+				continue
+			}
+		case sshFxpWrite:
+			data, _ := readString(rest[8:])
+			_, err = files[string(arg)].WriteAt(data, int64(binary.BigEndian.Uint64(rest)))
+		case sshFxpClose:
+			err = files[string(arg)].Close()
+			delete(files, string(arg))
+		case sshFxpMkdir:
+			err = os.Mkdir(string(arg), 0755)
+		case sshFxpRemove:
+			err = os.Remove(string(arg))
+		case sshFxpRename:
+			newPath, _ := readString(rest)
+			if _, serr := os.Stat(string(newPath)); serr == nil {
+				err = fmt.Errorf("%s already exists", newPath)
+			} else {
+				err = os.Rename(string(arg), string(newPath))
+			}
+		default:
+			err = fmt.Errorf("unsupported request %d", header[4])
+		}
+		if status(id, err) != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package destination
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"runtime"
+	"strings"
+)
+
+// SMB 共享目录
+// Windows 下直接通过 UNC 路径（\\server\share\path）访问，使用当前用户的凭据；
+// 其他平台需要先将共享挂载到本地，再使用挂载路径作为本地目录导出。
+type SMB struct {
+	*Local
+	unc string
+}
+
+// NewSMB 创建 SMB 共享目标，支持 smb://server/share/path 与 \\server\share\path 两种写法
+func NewSMB(uri string) (*SMB, error) {
+	unc, err := toUNC(uri)
+	if err != nil {
+		return nil, err
+	}
+
+	if runtime.GOOS != "windows" {
+		return nil, fmt.Errorf("smb destination is only supported on windows, please mount %s and use the mount path instead", unc)
+	}
+
+	if _, err := os.Stat(shareRoot(unc)); err != nil {
+		return nil, fmt.Errorf("smb share %s is not accessible: %w", shareRoot(unc), err)
+	}
+
+	local, err := NewLocal(unc)
+	if err != nil {
+		return nil, err
+	}
+	return &SMB{Local: local, unc: unc}, nil
+}
+
+func (s *SMB) String() string {
+	return s.unc
+}
+
+// toUNC 将 smb:// 地址转换为 UNC 路径
+func toUNC(uri string) (string, error) {
+	if strings.HasPrefix(uri, `\\`) {
+		return uri, nil
+	}
+	u, err := url.Parse(uri)
+	if err != nil {
+		return "", err
+	}
+	if u.Host == "" {
+		return "", fmt.Errorf("invalid smb address: %s", uri)
+	}
+	p := strings.Trim(u.Path, "/")
+	if p == "" {
+		return "", fmt.Errorf("smb address must contain a share name: %s", uri)
+	}
+	return `\\` + u.Host + `\` + strings.ReplaceAll(p, "/", `\`), nil
+}
+
+// shareRoot 返回 \\server\share 部分
+func shareRoot(unc string) string {
+	parts := strings.SplitN(strings.TrimPrefix(unc, `\\`), `\`, 3)
+	if len(parts) < 2 {
+		return unc
+	}
+	return `\\` + parts[0] + `\` + parts[1]
+}
@@ -0,0 +1,148 @@
+// Package hashtag 提取消息中的话题标签（如 #decision、#待办），并按约定的话题归类
+// 团队在群聊中用标签标记重要消息，同一话题可以约定多个标签，如 decision 对应 #decision 与 #决定
+package hashtag
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Extract 返回文本中出现的标签，不含 #，转为小写并去重，按第一次出现的顺序
+// 标签以 # 或全角 ＃ 开头，由字母、数字（含中文）、_ 与 - 组成；
+// # 前面紧跟字母或数字时（如 C#、网址中的 page#top）不是标签
+func Extract(text string) []string {
+	var tags []string
+	seen := make(map[string]bool)
+	prev := ' '
+	for i := 0; i < len(text); {
+		r, size := utf8.DecodeRuneInString(text[i:])
+		i += size
+		if (r != '#' && r != '＃') || isTagRune(prev) {
+			prev = r
+			continue
+		}
+		j := i
+		for j < len(text) {
+			r, size := utf8.DecodeRuneInString(text[j:])
+			if !isTagRune(r) {
+				break
+			}
+			j += size
+		}
+		if j > i {
+			tag := strings.ToLower(text[i:j])
+			if !seen[tag] {
+				seen[tag] = true
+				tags = append(tags, tag)
+			}
+		}
+		prev = r
+		i = j
+	}
+	return tags
+}
+
+func isTagRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '-'
+}
+
+// Normalize 将话题或标签名称转为小写并去掉开头的 #
+func Normalize(name string) string {
+	return strings.ToLower(strings.TrimLeft(strings.TrimSpace(name), "#＃"))
+}
+
+// Matcher 按约定将标签归入话题，没有约定的标签自成一个同名话题
+type Matcher struct {
+	topics map[string][]string // 话题 -> 标签
+	tags   map[string][]string // 标签 -> 话题
+}
+
+// NewMatcher 按话题与标签的约定创建 Matcher，topics 的键为话题名称，值为该话题的标签，可带 #
+// 话题本身的名称总是它的标签之一
+func NewMatcher(topics map[string][]string) *Matcher {
+	m := &Matcher{topics: make(map[string][]string), tags: make(map[string][]string)}
+	for topic, tags := range topics {
+		topic = Normalize(topic)
+		if topic == "" {
+			continue
+		}
+		for _, tag := range append([]string{topic}, tags...) {
+			tag = Normalize(tag)
+			if tag == "" || contains(m.topics[topic], tag) {
+				continue
+			}
+			m.topics[topic] = append(m.topics[topic], tag)
+			m.tags[tag] = append(m.tags[tag], topic)
+		}
+	}
+	return m
+}
+
+func contains(list []string, v string) bool {
+	for _, s := range list {
+		if s == v {
+			return true
+		}
+	}
+	return false
+}
+
+// Names 返回约定的话题名称
+func (m *Matcher) Names() []string {
+	names := make([]string, 0, len(m.topics))
+	for topic := range m.topics {
+		names = append(names, topic)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Tags 返回话题的标签，没有约定的话题只有与其同名的标签
+func (m *Matcher) Tags(topic string) []string {
+	topic = Normalize(topic)
+	if tags, ok := m.topics[topic]; ok {
+		return tags
+	}
+	return []string{topic}
+}
+
+// Topics 返回文本所属的话题，按第一次出现的顺序
+func (m *Matcher) Topics(text string) []string {
+	var topics []string
+	for _, tag := range Extract(text) {
+		names, ok := m.tags[tag]
+		if !ok {
+			names = []string{tag}
+		}
+		for _, name := range names {
+			if !contains(topics, name) {
+				topics = append(topics, name)
+			}
+		}
+	}
+	return topics
+}
+
+// Match 返回文本是否带有话题的任一标签
+func (m *Matcher) Match(text, topic string) bool {
+	tags := m.Tags(topic)
+	for _, tag := range Extract(text) {
+		if contains(tags, tag) {
+			return true
+		}
+	}
+	return false
+}
+
+// Pattern 返回匹配话题任一标签的正则表达式，用于在数据库查询时预先筛选，结果仍需通过 Match 确认
+func (m *Matcher) Pattern(topic string) string {
+	tags := m.Tags(topic)
+	quoted := make([]string, len(tags))
+	for i, tag := range tags {
+		quoted[i] = regexp.QuoteMeta(tag)
+	}
+	return "(?i)[#＃](" + strings.Join(quoted, "|") + ")"
+}
@@ -0,0 +1,38 @@
+package hashtag
+
+import (
+	"reflect"
+	"regexp"
+	"testing"
+)
+
+func TestExtract(t *testing.T) {
+	for text, want := range map[string][]string{
+		"周五上线 #Decision #决定":              {"decision", "决定"},
+		"＃待办 买咖啡，#待办 #todo-list":          {"待办", "todo-list"},
+		"用 C# 写的，见 http://a.com/page#top": nil,
+		"# 标题 和 ## 空标签":                   nil,
+		"#2024年度总结。":                      {"2024年度总结"},
+	} {
+		if got := Extract(text); !reflect.DeepEqual(got, want) {
+			t.Errorf("Extract(%q) = %q, want %q", text, got, want)
+		}
+	}
+}
+
+func TestMatcher(t *testing.T) {
+	m := NewMatcher(map[string][]string{"Decision": {"#决定", "定了"}, "todo": {"待办"}})
+	if got := m.Names(); !reflect.DeepEqual(got, []string{"decision", "todo"}) {
+		t.Errorf("Names = %q", got)
+	}
+	if got := m.Topics("#定了 #TODO #随手记"); !reflect.DeepEqual(got, []string{"decision", "todo", "随手记"}) {
+		t.Errorf("Topics = %q", got)
+	}
+	if !m.Match("方案 #决定", "#decision") || m.Match("方案 #决定", "todo") || !m.Match("#随手记", "随手记") {
+		t.Error("Match mismatch")
+	}
+	re := regexp.MustCompile(m.Pattern("decision"))
+	if !re.MatchString("＃定了") || !re.MatchString("#DECISION") || re.MatchString("决定") {
+		t.Errorf("Pattern = %q", m.Pattern("decision"))
+	}
+}
@@ -0,0 +1,151 @@
+// Package htmlshot 调用本机的 Chrome、Chromium 或 Edge 的无头模式，将 HTML 页面按固定大小的视口渲染为 PNG
+//
+// 截图只包含视口内的部分，超出视口高度的内容会被截断；结果随浏览器版本与系统字体变化，
+// 同一台机器上的前后对比才有意义
+package htmlshot
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+const (
+	// EnvBrowser 指定浏览器可执行文件的环境变量，未设置时在 PATH 与默认安装位置中查找
+	EnvBrowser = "CHATLOG_BROWSER"
+
+	renderTimeout = time.Minute
+)
+
+// ErrNoBrowser 找不到可用的浏览器
+var ErrNoBrowser = fmt.Errorf("no chrome, chromium or edge found, set %s to the browser executable", EnvBrowser)
+
+// Browser 返回用于渲染的浏览器可执行文件
+func Browser() (string, error) {
+	if p := os.Getenv(EnvBrowser); p != "" {
+		if _, err := os.Stat(p); err != nil {
+			return "", fmt.Errorf("%s: %w", EnvBrowser, err)
+		}
+		return p, nil
+	}
+	for _, name := range []string{"chromium", "chromium-browser", "google-chrome", "google-chrome-stable", "chrome", "msedge", "microsoft-edge"} {
+		if p, err := exec.LookPath(name); err == nil {
+			return p, nil
+		}
+	}
+	for _, p := range defaultPaths() {
+		if _, err := os.Stat(p); err == nil {
+			return p, nil
+		}
+	}
+	return "", ErrNoBrowser
+}
+
+// defaultPaths 返回不在 PATH 中的默认安装位置
+func defaultPaths() []string {
+	switch runtime.GOOS {
+	case "darwin":
+		return []string{
+			"/Applications/Google Chrome.app/Contents/MacOS/Google Chrome",
+			"/Applications/Chromium.app/Contents/MacOS/Chromium",
+			"/Applications/Microsoft Edge.app/Contents/MacOS/Microsoft Edge",
+		}
+	case "windows":
+		var paths []string
+		for _, env := range []string{"ProgramFiles", "ProgramFiles(x86)", "LocalAppData"} {
+			if dir := os.Getenv(env); dir != "" {
+				paths = append(paths,
+					filepath.Join(dir, "Google", "Chrome", "Application", "chrome.exe"),
+					filepath.Join(dir, "Microsoft", "Edge", "Application", "msedge.exe"),
+				)
+			}
+		}
+		return paths
+	}
+	return nil
+}
+
+// RenderPNG 将 page 以 width x height 像素的视口渲染为 PNG 写入 w
+// 页面保存在临时目录中打开，相对路径的资源不可用；浏览器使用临时的用户目录，不影响正在运行的浏览器
+func RenderPNG(w io.Writer, page io.Reader, width, height int) error {
+	if width <= 0 || height <= 0 {
+		return fmt.Errorf("invalid viewport %dx%d", width, height)
+	}
+	browser, err := Browser()
+	if err != nil {
+		return err
+	}
+
+	dir, err := os.MkdirTemp("", "chatlog-htmlshot-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	index := filepath.Join(dir, "index.html")
+	f, err := os.Create(index)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(f, page)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+
+	shot := filepath.Join(dir, "shot.png")
+	args := []string{
+		"--headless=new",
+		"--disable-gpu",
+		"--hide-scrollbars",
+		"--no-first-run",
+		"--no-default-browser-check",
+		"--force-device-scale-factor=1",
+		"--run-all-compositor-stages-before-draw",
+		"--virtual-time-budget=2000",
+		"--user-data-dir=" + filepath.Join(dir, "profile"),
+		fmt.Sprintf("--window-size=%d,%d", width, height),
+		"--screenshot=" + shot,
+	}
+	// 以 root 运行时 Chrome 拒绝启用沙箱
+	if os.Geteuid() == 0 {
+		args = append(args, "--no-sandbox")
+	}
+	args = append(args, fileURL(index))
+
+	ctx, cancel := context.WithTimeout(context.Background(), renderTimeout)
+	defer cancel()
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, browser, args...)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s failed: %w: %s", filepath.Base(browser), err, strings.TrimSpace(stderr.String()))
+	}
+
+	png, err := os.Open(shot)
+	if err != nil {
+		return fmt.Errorf("%s wrote no screenshot: %s", filepath.Base(browser), strings.TrimSpace(stderr.String()))
+	}
+	defer png.Close()
+	_, err = io.Copy(w, png)
+	return err
+}
+
+// fileURL 返回本地文件的 file:// 地址，Windows 的盘符路径前需要补 /
+func fileURL(name string) string {
+	p := filepath.ToSlash(name)
+	if !strings.HasPrefix(p, "/") {
+		p = "/" + p
+	}
+	return (&url.URL{Scheme: "file", Path: p}).String()
+}
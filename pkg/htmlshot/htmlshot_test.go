@@ -0,0 +1,72 @@
+package htmlshot
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/png"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const envFakeBrowser = "HTMLSHOT_FAKE_BROWSER"
+
+// TestMain 在设置了 HTMLSHOT_FAKE_BROWSER 时把测试程序当作浏览器运行：
+// 检查页面文件存在，按 --window-size 写出空白的 --screenshot
+func TestMain(m *testing.M) {
+	if os.Getenv(envFakeBrowser) == "" {
+		os.Exit(m.Run())
+	}
+	var shot, page string
+	var width, height int
+	for _, arg := range os.Args[1:] {
+		switch {
+		case strings.HasPrefix(arg, "--screenshot="):
+			shot = strings.TrimPrefix(arg, "--screenshot=")
+		case strings.HasPrefix(arg, "--window-size="):
+			fmt.Sscanf(strings.TrimPrefix(arg, "--window-size="), "%d,%d", &width, &height)
+		case strings.HasPrefix(arg, "file://"):
+			u, _ := url.Parse(arg)
+			page = filepath.FromSlash(u.Path)
+		}
+	}
+	if _, err := os.Stat(page); err != nil {
+		fmt.Fprintln(os.Stderr, "page not found:", page)
+		os.Exit(1)
+	}
+	f, err := os.Create(shot)
+	if err != nil {
+		os.Exit(1)
+	}
+	png.Encode(f, image.NewRGBA(image.Rect(0, 0, width, height)))
+	f.Close()
+	os.Exit(0)
+}
+
+func TestRenderPNG(t *testing.T) {
+	t.Setenv(EnvBrowser, os.Args[0])
+	t.Setenv(envFakeBrowser, "1")
+
+	var buf bytes.Buffer
+	if err := RenderPNG(&buf, strings.NewReader("<p>hi</p>"), 480, 320); err != nil {
+		t.Fatal(err)
+	}
+	img, err := png.Decode(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size := img.Bounds().Size(); size != image.Pt(480, 320) {
+		t.Errorf("size = %v", size)
+	}
+
+	if err := RenderPNG(&buf, strings.NewReader(""), 0, 320); err == nil {
+		t.Error("accepted an empty viewport")
+	}
+	t.Setenv(EnvBrowser, filepath.Join(t.TempDir(), "missing"))
+	if err := RenderPNG(&buf, strings.NewReader(""), 480, 320); err == nil {
+		t.Error("rendered without a browser")
+	}
+}
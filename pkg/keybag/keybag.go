@@ -0,0 +1,143 @@
+package keybag
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+const (
+	// Format 与 FormatVersion 标识交换格式，其他工具据此识别文件
+	Format        = "wechat-keybag"
+	FormatVersion = 1
+
+	DataKeySize = 32
+	ImgKeySize  = 16
+)
+
+// Keybag 是微信密钥的交换格式
+//
+//	{
+//	  "format": "wechat-keybag",
+//	  "formatVersion": 1,
+//	  "keys": [{"account": "wxid_xxx", "version": 4, "dataKey": "<64 hex>", "imgKey": "<32 hex>", "extractedAt": "2025-01-01T00:00:00Z"}]
+//	}
+type Keybag struct {
+	Format        string `json:"format"`
+	FormatVersion int    `json:"formatVersion"`
+	Keys          []Key  `json:"keys"`
+}
+
+// Key 是一个账号的密钥
+type Key struct {
+	Account     string     `json:"account"`
+	Platform    string     `json:"platform,omitempty"`
+	Version     int        `json:"version"`
+	FullVersion string     `json:"fullVersion,omitempty"`
+	DataDir     string     `json:"dataDir,omitempty"`
+	DataKey     string     `json:"dataKey"`
+	ImgKey      string     `json:"imgKey,omitempty"`
+	ExtractedAt *time.Time `json:"extractedAt,omitempty"`
+}
+
+// New 创建空的 Keybag
+func New() *Keybag {
+	return &Keybag{Format: Format, FormatVersion: FormatVersion}
+}
+
+// Validate 校验账号、版本与密钥长度
+func (k *Key) Validate() error {
+	if k.Account == "" {
+		return fmt.Errorf("account is empty")
+	}
+	if k.Version != 0 && k.Version != 3 && k.Version != 4 {
+		return fmt.Errorf("%s: unsupported version %d, expected 3 or 4", k.Account, k.Version)
+	}
+	if err := checkHex(k.DataKey, DataKeySize); err != nil {
+		return fmt.Errorf("%s: dataKey %v", k.Account, err)
+	}
+	if k.ImgKey != "" {
+		if err := checkHex(k.ImgKey, ImgKeySize); err != nil {
+			return fmt.Errorf("%s: imgKey %v", k.Account, err)
+		}
+	}
+	return nil
+}
+
+// Write 以缩进的 JSON 写出
+func (b *Keybag) Write(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(b)
+}
+
+// Read 读取并校验 Keybag，也接受单个密钥对象或密钥数组，便于导入其他工具的输出
+func Read(r io.Reader) (*Keybag, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	data = bytes.TrimSpace(data)
+
+	b := New()
+	switch {
+	case bytes.HasPrefix(data, []byte("[")):
+		if err := json.Unmarshal(data, &b.Keys); err != nil {
+			return nil, fmt.Errorf("invalid keybag: %w", err)
+		}
+	default:
+		var probe struct {
+			Format  string          `json:"format"`
+			Keys    json.RawMessage `json:"keys"`
+			DataKey string          `json:"dataKey"`
+		}
+		if err := json.Unmarshal(data, &probe); err != nil {
+			return nil, fmt.Errorf("invalid keybag: %w", err)
+		}
+		if probe.Keys != nil {
+			if err := json.Unmarshal(data, b); err != nil {
+				return nil, fmt.Errorf("invalid keybag: %w", err)
+			}
+			if b.Format != Format {
+				return nil, fmt.Errorf("unknown keybag format %q", b.Format)
+			}
+			if b.FormatVersion > FormatVersion {
+				return nil, fmt.Errorf("keybag format version %d is newer than supported %d", b.FormatVersion, FormatVersion)
+			}
+		} else {
+			var k Key
+			if err := json.Unmarshal(data, &k); err != nil {
+				return nil, fmt.Errorf("invalid keybag: %w", err)
+			}
+			b.Keys = []Key{k}
+		}
+	}
+
+	for i := range b.Keys {
+		b.Keys[i].DataKey = strings.ToLower(b.Keys[i].DataKey)
+		b.Keys[i].ImgKey = strings.ToLower(b.Keys[i].ImgKey)
+		if err := b.Keys[i].Validate(); err != nil {
+			return nil, err
+		}
+	}
+	return b, nil
+}
+
+// checkHex 校验十六进制密钥的字节数
+func checkHex(s string, size int) error {
+	if s == "" {
+		return fmt.Errorf("is empty")
+	}
+	raw, err := hex.DecodeString(s)
+	if err != nil {
+		return fmt.Errorf("is not a valid hex string")
+	}
+	if len(raw) != size {
+		return fmt.Errorf("should be %d bytes, got %d", size, len(raw))
+	}
+	return nil
+}
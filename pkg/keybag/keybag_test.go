@@ -0,0 +1,59 @@
+package keybag
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestRead(t *testing.T) {
+	key := strings.Repeat("ab", DataKeySize)
+	for _, tc := range []struct {
+		name  string
+		input string
+		n     int
+		err   bool
+	}{
+		{"keybag", `{"format":"wechat-keybag","formatVersion":1,"keys":[{"account":"a","version":4,"dataKey":"` + key + `"}]}`, 1, false},
+		{"array", `[{"account":"a","dataKey":"` + key + `"},{"account":"b","dataKey":"` + key + `"}]`, 2, false},
+		{"single", `{"account":"a","dataKey":"` + strings.ToUpper(key) + `"}`, 1, false},
+		{"unknown format", `{"format":"other","keys":[]}`, 0, true},
+		{"newer format", `{"format":"wechat-keybag","formatVersion":2,"keys":[]}`, 0, true},
+		{"short key", `{"account":"a","dataKey":"abcd"}`, 0, true},
+		{"bad img key", `{"account":"a","dataKey":"` + key + `","imgKey":"zz"}`, 0, true},
+		{"no account", `{"dataKey":"` + key + `"}`, 0, true},
+	} {
+		b, err := Read(strings.NewReader(tc.input))
+		if tc.err {
+			if err == nil {
+				t.Errorf("%s: expected error", tc.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		if len(b.Keys) != tc.n {
+			t.Errorf("%s: got %d keys, want %d", tc.name, len(b.Keys), tc.n)
+		}
+		if b.Keys[0].DataKey != key {
+			t.Errorf("%s: data key not normalized: %s", tc.name, b.Keys[0].DataKey)
+		}
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	b := New()
+	b.Keys = append(b.Keys, Key{Account: "wxid_a", Version: 4, DataKey: strings.Repeat("01", DataKeySize), ImgKey: strings.Repeat("02", ImgKeySize)})
+	var buf bytes.Buffer
+	if err := b.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	got, err := Read(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Keys) != 1 || got.Keys[0] != b.Keys[0] {
+		t.Errorf("round trip mismatch: %+v", got.Keys)
+	}
+}
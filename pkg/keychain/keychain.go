@@ -0,0 +1,28 @@
+// Package keychain 将口令保存在系统凭据存储中
+// macOS 使用钥匙串，Windows 使用凭据管理器，Linux 使用 Secret Service（secret-tool）
+package keychain
+
+import "errors"
+
+var (
+	ErrNotFound    = errors.New("keychain item not found")
+	ErrUnsupported = errors.New("keychain is not supported on this platform")
+)
+
+// Get 读取 service 下 account 的口令，不存在时返回 ErrNotFound
+func Get(service, account string) (string, error) {
+	return get(service, account)
+}
+
+// Set 保存口令，已存在时覆盖
+func Set(service, account, secret string) error {
+	return set(service, account, secret)
+}
+
+// Delete 删除口令，不存在时不报错
+func Delete(service, account string) error {
+	if err := del(service, account); err != nil && err != ErrNotFound {
+		return err
+	}
+	return nil
+}
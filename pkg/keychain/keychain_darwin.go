@@ -0,0 +1,56 @@
+package keychain
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// errItemNotFound security 找不到钥匙串项目时的退出码
+const errItemNotFound = 44
+
+func get(service, account string) (string, error) {
+	out, err := exec.Command("security", "find-generic-password", "-s", service, "-a", account, "-w").Output()
+	if err != nil {
+		return "", convert(err)
+	}
+	return strings.TrimSuffix(string(out), "\n"), nil
+}
+
+// set 通过标准输入向交互模式的 security 传入命令，密钥以十六进制（-X）写在命令中，
+// 不出现在进程参数里，本机其他用户无法通过 ps 看到
+func set(service, account, secret string) error {
+	cmd := exec.Command("security", "-i")
+	cmd.Stdin = strings.NewReader(fmt.Sprintf("add-generic-password -U -s %s -a %s -X %s\n",
+		quote(service), quote(account), hex.EncodeToString([]byte(secret))))
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return convert(err)
+	}
+	// 交互模式下命令失败时 security 仍正常退出，只输出错误信息
+	if msg := strings.TrimSpace(stderr.String()); msg != "" {
+		return fmt.Errorf("security add-generic-password: %s", msg)
+	}
+	return nil
+}
+
+// quote 按 security 交互模式的规则为参数加双引号
+func quote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+func del(service, account string) error {
+	return convert(exec.Command("security", "delete-generic-password", "-s", service, "-a", account).Run())
+}
+
+func convert(err error) error {
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == errItemNotFound {
+		return ErrNotFound
+	}
+	return err
+}
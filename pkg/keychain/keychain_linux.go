@@ -0,0 +1,40 @@
+package keychain
+
+import (
+	"errors"
+	"os/exec"
+	"strings"
+)
+
+// secret-tool 由 libsecret 提供，通过 D-Bus 访问 GNOME Keyring、KWallet 等 Secret Service
+func get(service, account string) (string, error) {
+	out, err := exec.Command("secret-tool", "lookup", "service", service, "account", account).Output()
+	if err != nil {
+		return "", convert(err)
+	}
+	if len(out) == 0 {
+		return "", ErrNotFound
+	}
+	return strings.TrimSuffix(string(out), "\n"), nil
+}
+
+func set(service, account, secret string) error {
+	cmd := exec.Command("secret-tool", "store", "--label="+service, "service", service, "account", account)
+	cmd.Stdin = strings.NewReader(secret)
+	return convert(cmd.Run())
+}
+
+func del(service, account string) error {
+	return convert(exec.Command("secret-tool", "clear", "service", service, "account", account).Run())
+}
+
+func convert(err error) error {
+	if errors.Is(err, exec.ErrNotFound) {
+		return ErrUnsupported
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 && len(exitErr.Stderr) == 0 {
+		return ErrNotFound
+	}
+	return err
+}
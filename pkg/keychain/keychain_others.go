@@ -0,0 +1,15 @@
+//go:build !windows && !linux && !darwin
+
+package keychain
+
+func get(service, account string) (string, error) {
+	return "", ErrUnsupported
+}
+
+func set(service, account, secret string) error {
+	return ErrUnsupported
+}
+
+func del(service, account string) error {
+	return ErrUnsupported
+}
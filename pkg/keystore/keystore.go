@@ -0,0 +1,169 @@
+// Package keystore 在本机加密保存各账号的微信密钥，之后运行时直接复用，无需再扫描微信进程内存
+// Windows 使用 DPAPI 将文件绑定到当前用户，其他平台使用保存在系统凭据存储中的随机主密钥加密
+// 文件复制到其他机器或其他用户下无法解密
+package keystore
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/aspnmy/chatlog/pkg/keybag"
+)
+
+const (
+	// Format 标识密钥缓存文件
+	Format = "chatlog-keystore"
+
+	// KeychainService 非 Windows 平台在系统凭据存储中保存主密钥使用的服务名，账号为文件路径
+	KeychainService = "chatlog-keystore"
+)
+
+var (
+	ErrNotFound = errors.New("key not found in keystore")
+
+	// ErrUnsupported 当前平台无法安全保存主密钥，例如 Linux 上没有 secret-tool
+	ErrUnsupported = errors.New("keystore is not supported on this platform")
+)
+
+// envelope 密钥缓存文件的内容，Data 为加密后的 keybag
+type envelope struct {
+	Format string `json:"format"`
+	Data   []byte `json:"data"`
+}
+
+// Store 密钥缓存文件，同一进程中可并发使用
+type Store struct {
+	path string
+	mu   sync.Mutex
+}
+
+// Open 返回 path 对应的密钥缓存，文件不存在时在第一次 Put 时创建
+func Open(path string) *Store {
+	return &Store{path: path}
+}
+
+// Path 返回缓存文件路径
+func (s *Store) Path() string {
+	return s.path
+}
+
+// Get 返回账号的密钥，不存在时返回 ErrNotFound
+func (s *Store) Get(account string) (*keybag.Key, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	for _, k := range b.Keys {
+		if strings.EqualFold(k.Account, account) {
+			return &k, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+// Put 保存账号的密钥，已存在时覆盖
+func (s *Store) Put(k keybag.Key) error {
+	if err := k.Validate(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, err := s.load()
+	if err != nil {
+		// 无法解密的旧文件（例如从其他机器复制而来）直接覆盖
+		if errors.Is(err, ErrUnsupported) {
+			return err
+		}
+		b = keybag.New()
+	}
+	b.Keys = without(b.Keys, k.Account)
+	b.Keys = append(b.Keys, k)
+	return s.save(b)
+}
+
+// Delete 删除账号的密钥，密钥验证失败时调用，不存在时不报错
+func (s *Store) Delete(account string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, err := s.load()
+	if err != nil {
+		return err
+	}
+	n := len(b.Keys)
+	b.Keys = without(b.Keys, account)
+	if len(b.Keys) == n {
+		return nil
+	}
+	return s.save(b)
+}
+
+// Clear 删除缓存文件与主密钥
+func (s *Store) Clear() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := os.Remove(s.path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return forget(s.path)
+}
+
+func (s *Store) load() (*keybag.Keybag, error) {
+	data, err := os.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return keybag.New(), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var e envelope
+	if err := json.Unmarshal(data, &e); err != nil || e.Format != Format {
+		return nil, fmt.Errorf("%s is not a keystore file", s.path)
+	}
+	plain, err := unprotect(s.path, e.Data)
+	if err != nil {
+		return nil, fmt.Errorf("decrypt keystore %s: %w", s.path, err)
+	}
+	return keybag.Read(bytes.NewReader(plain))
+}
+
+func (s *Store) save(b *keybag.Keybag) error {
+	var buf bytes.Buffer
+	if err := b.Write(&buf); err != nil {
+		return err
+	}
+	sealed, err := protect(s.path, buf.Bytes())
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(envelope{Format: Format, Data: sealed})
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0700); err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
+
+// without 返回去掉 account 后的密钥列表
+func without(keys []keybag.Key, account string) []keybag.Key {
+	out := keys[:0]
+	for _, k := range keys {
+		if !strings.EqualFold(k.Account, account) {
+			out = append(out, k)
+		}
+	}
+	return out
+}
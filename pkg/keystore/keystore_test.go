@@ -0,0 +1,66 @@
+//go:build !windows
+
+package keystore
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aspnmy/chatlog/pkg/keybag"
+)
+
+func TestStore(t *testing.T) {
+	keys := make(map[string][]byte)
+	masterKey = func(path string, create bool) ([]byte, error) {
+		if k, ok := keys[path]; ok || !create {
+			if !ok {
+				return nil, errors.New("not found")
+			}
+			return k, nil
+		}
+		keys[path] = []byte(strings.Repeat("k", 32))
+		return keys[path], nil
+	}
+
+	path := filepath.Join(t.TempDir(), "keys.dat")
+	s := Open(path)
+	if _, err := s.Get("wxid_a"); err != ErrNotFound {
+		t.Fatalf("Get on empty store = %v", err)
+	}
+
+	k := keybag.Key{Account: "wxid_a", Version: 4, DataKey: strings.Repeat("ab", 32), ImgKey: strings.Repeat("cd", 16)}
+	if err := s.Put(k); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(path)
+	if strings.Contains(string(data), k.DataKey) {
+		t.Fatal("data key stored in plain text")
+	}
+
+	got, err := Open(path).Get("WXID_A")
+	if err != nil || got.DataKey != k.DataKey || got.ImgKey != k.ImgKey {
+		t.Fatalf("Get = %+v, %v", got, err)
+	}
+
+	if err := s.Delete("wxid_a"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Get("wxid_a"); err != ErrNotFound {
+		t.Fatalf("Get after Delete = %v", err)
+	}
+
+	// 主密钥丢失后无法解密，Put 重新生成并覆盖
+	delete(keys, path)
+	if _, err := s.Get("wxid_a"); err == nil {
+		t.Fatal("Get without master key succeeded")
+	}
+	if err := s.Put(k); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Get("wxid_a"); err != nil {
+		t.Fatal(err)
+	}
+}
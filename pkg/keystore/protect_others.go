@@ -0,0 +1,88 @@
+//go:build !windows
+
+package keystore
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/aspnmy/chatlog/pkg/keychain"
+)
+
+// masterKey 返回加密缓存文件的主密钥，create 为 true 且不存在时生成并保存到系统凭据存储
+// 测试中可以替换
+var masterKey = func(path string, create bool) ([]byte, error) {
+	s, err := keychain.Get(KeychainService, path)
+	switch {
+	case err == nil:
+		return hex.DecodeString(s)
+	case errors.Is(err, keychain.ErrUnsupported):
+		return nil, ErrUnsupported
+	case !errors.Is(err, keychain.ErrNotFound) || !create:
+		return nil, err
+	}
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	if err := keychain.Set(KeychainService, path, hex.EncodeToString(key)); err != nil {
+		if errors.Is(err, keychain.ErrUnsupported) {
+			return nil, ErrUnsupported
+		}
+		return nil, err
+	}
+	return key, nil
+}
+
+// protect 使用 AES-256-GCM 加密，nonce 放在密文之前
+func protect(path string, plain []byte) ([]byte, error) {
+	key, err := masterKey(path, true)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, plain, []byte(Format)), nil
+}
+
+func unprotect(path string, sealed []byte) ([]byte, error) {
+	key, err := masterKey(path, false)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return nil, fmt.Errorf("ciphertext too short")
+	}
+	nonce, data := sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():]
+	return gcm.Open(nil, nonce, data, []byte(Format))
+}
+
+// forget 删除系统凭据存储中的主密钥
+func forget(path string) error {
+	if err := keychain.Delete(KeychainService, path); err != nil && !errors.Is(err, keychain.ErrUnsupported) {
+		return err
+	}
+	return nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
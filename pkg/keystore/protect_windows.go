@@ -0,0 +1,42 @@
+package keystore
+
+import (
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// protect 使用 DPAPI 加密，只有当前用户在本机上才能解密
+func protect(_ string, plain []byte) ([]byte, error) {
+	var out windows.DataBlob
+	if err := windows.CryptProtectData(blob(plain), nil, nil, 0, nil, windows.CRYPTPROTECT_UI_FORBIDDEN, &out); err != nil {
+		return nil, err
+	}
+	return take(&out), nil
+}
+
+func unprotect(_ string, sealed []byte) ([]byte, error) {
+	var out windows.DataBlob
+	if err := windows.CryptUnprotectData(blob(sealed), nil, nil, 0, nil, windows.CRYPTPROTECT_UI_FORBIDDEN, &out); err != nil {
+		return nil, err
+	}
+	return take(&out), nil
+}
+
+// forget DPAPI 的密钥由系统管理，没有需要删除的主密钥
+func forget(string) error {
+	return nil
+}
+
+func blob(b []byte) *windows.DataBlob {
+	if len(b) == 0 {
+		return &windows.DataBlob{}
+	}
+	return &windows.DataBlob{Size: uint32(len(b)), Data: &b[0]}
+}
+
+// take 复制 DPAPI 分配的输出并释放
+func take(b *windows.DataBlob) []byte {
+	defer windows.LocalFree(windows.Handle(unsafe.Pointer(b.Data)))
+	return append([]byte(nil), unsafe.Slice(b.Data, b.Size)...)
+}
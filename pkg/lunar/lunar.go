@@ -0,0 +1,205 @@
+// Package lunar 农历日期换算与传统节日，支持 1900 年至 2100 年
+package lunar
+
+import (
+	"fmt"
+	"time"
+)
+
+// info 每年的农历数据：
+// 低 4 位为闰月月份，没有闰月为 0；第 5 至 16 位依次为十二月到正月是否为大月（30 天）；第 17 位为闰月是否为大月
+var info = [...]uint32{
+	0x04bd8, 0x04ae0, 0x0a570, 0x054d5, 0x0d260, 0x0d950, 0x16554, 0x056a0, 0x09ad0, 0x055d2, // 1900
+	0x04ae0, 0x0a5b6, 0x0a4d0, 0x0d250, 0x1d255, 0x0b540, 0x0d6a0, 0x0ada2, 0x095b0, 0x14977, // 1910
+	0x04970, 0x0a4b0, 0x0b4b5, 0x06a50, 0x06d40, 0x1ab54, 0x02b60, 0x09570, 0x052f2, 0x04970, // 1920
+	0x06566, 0x0d4a0, 0x0ea50, 0x16a95, 0x05ad0, 0x02b60, 0x186e3, 0x092e0, 0x1c8d7, 0x0c950, // 1930
+	0x0d4a0, 0x1d8a6, 0x0b550, 0x056a0, 0x1a5b4, 0x025d0, 0x092d0, 0x0d2b2, 0x0a950, 0x0b557, // 1940
+	0x06ca0, 0x0b550, 0x15355, 0x04da0, 0x0a5b0, 0x14573, 0x052b0, 0x0a9a8, 0x0e950, 0x06aa0, // 1950
+	0x0aea6, 0x0ab50, 0x04b60, 0x0aae4, 0x0a570, 0x05260, 0x0f263, 0x0d950, 0x05b57, 0x056a0, // 1960
+	0x096d0, 0x04dd5, 0x04ad0, 0x0a4d0, 0x0d4d4, 0x0d250, 0x0d558, 0x0b540, 0x0b6a0, 0x195a6, // 1970
+	0x095b0, 0x049b0, 0x0a974, 0x0a4b0, 0x0b27a, 0x06a50, 0x06d40, 0x0af46, 0x0ab60, 0x09570, // 1980
+	0x04af5, 0x04970, 0x064b0, 0x074a3, 0x0ea50, 0x06b58, 0x05ac0, 0x0ab60, 0x096d5, 0x092e0, // 1990
+	0x0c960, 0x0d954, 0x0d4a0, 0x0da50, 0x07552, 0x056a0, 0x0abb7, 0x025d0, 0x092d0, 0x0cab5, // 2000
+	0x0a950, 0x0b4a0, 0x0baa4, 0x0ad50, 0x055d9, 0x04ba0, 0x0a5b0, 0x15176, 0x052b0, 0x0a930, // 2010
+	0x07954, 0x06aa0, 0x0ad50, 0x05b52, 0x04b60, 0x0a6e6, 0x0a4e0, 0x0d260, 0x0ea65, 0x0d530, // 2020
+	0x05aa0, 0x076a3, 0x096d0, 0x04afb, 0x04ad0, 0x0a4d0, 0x1d0b6, 0x0d250, 0x0d520, 0x0dd45, // 2030
+	0x0b5a0, 0x056d0, 0x055b2, 0x049b0, 0x0a577, 0x0a4b0, 0x0aa50, 0x1b255, 0x06d20, 0x0ada0, // 2040
+	0x14b63, 0x09370, 0x049f8, 0x04970, 0x064b0, 0x168a6, 0x0ea50, 0x06b20, 0x1a6c4, 0x0aae0, // 2050
+	0x092e0, 0x0d2e3, 0x0c960, 0x0d557, 0x0d4a0, 0x0da50, 0x05d55, 0x056a0, 0x0a6d0, 0x055d4, // 2060
+	0x052d0, 0x0a9b8, 0x0a950, 0x0b4a0, 0x0b6a6, 0x0ad50, 0x055a0, 0x0aba4, 0x0a5b0, 0x052b0, // 2070
+	0x0b273, 0x06930, 0x07337, 0x06aa0, 0x0ad50, 0x14b55, 0x04b60, 0x0a570, 0x054e4, 0x0d160, // 2080
+	0x0e968, 0x0d520, 0x0daa0, 0x16aa6, 0x056d0, 0x04ae0, 0x0a9d4, 0x0a2d0, 0x0d150, 0x0f252, // 2090
+	0x0d520, // 2100
+}
+
+const firstYear = 1900
+
+// epoch 1900 年正月初一对应的公历日期
+var epoch = time.Date(1900, 1, 31, 0, 0, 0, 0, time.UTC)
+
+// Date 农历日期
+type Date struct {
+	Year  int
+	Month int // 1 至 12
+	Day   int // 1 至 30
+	Leap  bool
+}
+
+// leapMonth 返回闰月月份，没有闰月时为 0
+func leapMonth(year int) int {
+	return int(info[year-firstYear] & 0xf)
+}
+
+func leapDays(year int) int {
+	if leapMonth(year) == 0 {
+		return 0
+	}
+	if info[year-firstYear]&0x10000 != 0 {
+		return 30
+	}
+	return 29
+}
+
+func monthDays(year, month int) int {
+	if info[year-firstYear]&(0x10000>>month) != 0 {
+		return 30
+	}
+	return 29
+}
+
+func yearDays(year int) int {
+	days := leapDays(year)
+	for m := 1; m <= 12; m++ {
+		days += monthDays(year, m)
+	}
+	return days
+}
+
+// FromSolar 将公历日期换算为农历日期，只使用 t 的年月日，超出支持的范围时返回 false
+func FromSolar(t time.Time) (Date, bool) {
+	offset := int(time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC).Sub(epoch).Hours() / 24)
+	if offset < 0 {
+		return Date{}, false
+	}
+	year := firstYear
+	for ; year-firstYear < len(info); year++ {
+		days := yearDays(year)
+		if offset < days {
+			break
+		}
+		offset -= days
+	}
+	if year-firstYear >= len(info) {
+		return Date{}, false
+	}
+
+	leap := leapMonth(year)
+	for month := 1; month <= 12; month++ {
+		days := monthDays(year, month)
+		if offset < days {
+			return Date{Year: year, Month: month, Day: offset + 1}, true
+		}
+		offset -= days
+		if month == leap {
+			days = leapDays(year)
+			if offset < days {
+				return Date{Year: year, Month: month, Day: offset + 1, Leap: true}, true
+			}
+			offset -= days
+		}
+	}
+	// 各月天数之和等于全年天数，不会执行到这里
+	return Date{}, false
+}
+
+var (
+	monthNames = [...]string{"正", "二", "三", "四", "五", "六", "七", "八", "九", "十", "冬", "腊"}
+	dayNames   = [...]string{"初", "十", "廿", "三"}
+	digitNames = [...]string{"十", "一", "二", "三", "四", "五", "六", "七", "八", "九"}
+)
+
+// MonthName 返回农历月份名称，如 正月、闰四月
+func (d Date) MonthName() string {
+	name := monthNames[d.Month-1] + "月"
+	if d.Leap {
+		name = "闰" + name
+	}
+	return name
+}
+
+// DayName 返回农历日名称，如 初一、十五、廿三
+func (d Date) DayName() string {
+	switch d.Day {
+	case 10:
+		return "初十"
+	case 20:
+		return "二十"
+	case 30:
+		return "三十"
+	}
+	return dayNames[d.Day/10] + digitNames[d.Day%10]
+}
+
+// String 返回农历月日，如 正月初一
+func (d Date) String() string {
+	return d.MonthName() + d.DayName()
+}
+
+// festivals 按农历月日排列的传统节日，闰月中的日期不算
+var festivals = map[[2]int]string{
+	{1, 1}:   "春节",
+	{1, 15}:  "元宵节",
+	{2, 2}:   "龙抬头",
+	{5, 5}:   "端午节",
+	{7, 7}:   "七夕",
+	{7, 15}:  "中元节",
+	{8, 15}:  "中秋节",
+	{9, 9}:   "重阳节",
+	{12, 8}:  "腊八节",
+	{12, 23}: "小年",
+}
+
+// Festival 返回公历日期对应的传统节日，包括按农历计算的节日、除夕与清明，不是节日时返回空字符串
+func Festival(t time.Time) string {
+	if t.Month() == time.April && t.Day() == qingming(t.Year()) {
+		return "清明节"
+	}
+	d, ok := FromSolar(t)
+	if !ok || d.Leap {
+		return ""
+	}
+	if name := festivals[[2]int{d.Month, d.Day}]; name != "" {
+		return name
+	}
+	if d.Month == 12 && d.Day == monthDays(d.Year, 12) {
+		return "除夕"
+	}
+	return ""
+}
+
+// qingming 返回清明在四月中的日期，按节气的通用公式计算，不支持的年份返回 0
+func qingming(year int) int {
+	var c float64
+	switch {
+	case year >= 1901 && year <= 1999:
+		c = 5.59
+	case year >= 2000 && year <= 2099:
+		c = 4.81
+	default:
+		return 0
+	}
+	y := year % 100
+	return int(float64(y)*0.2422+c) - y/4
+}
+
+// Annotate 返回公历日期的农历注记，如 正月初一 春节，超出支持的范围时返回空字符串
+func Annotate(t time.Time) string {
+	d, ok := FromSolar(t)
+	if !ok {
+		return ""
+	}
+	if f := Festival(t); f != "" {
+		return fmt.Sprintf("%s %s", d, f)
+	}
+	return d.String()
+}
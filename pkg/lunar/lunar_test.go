@@ -0,0 +1,68 @@
+package lunar
+
+import (
+	"testing"
+	"time"
+)
+
+func date(y int, m time.Month, d int) time.Time {
+	return time.Date(y, m, d, 12, 0, 0, 0, time.Local)
+}
+
+func TestFromSolar(t *testing.T) {
+	tests := []struct {
+		solar time.Time
+		want  Date
+	}{
+		{date(1900, 1, 31), Date{1900, 1, 1, false}},
+		{date(1949, 10, 1), Date{1949, 8, 10, false}},
+		{date(2000, 2, 5), Date{2000, 1, 1, false}},
+		{date(2017, 8, 21), Date{2017, 6, 30, true}},
+		{date(2020, 5, 23), Date{2020, 4, 1, true}},
+		{date(2023, 1, 22), Date{2023, 1, 1, false}},
+		{date(2023, 9, 29), Date{2023, 8, 15, false}},
+		{date(2024, 2, 9), Date{2023, 12, 30, false}},
+		{date(2024, 2, 10), Date{2024, 1, 1, false}},
+		{date(2024, 9, 17), Date{2024, 8, 15, false}},
+		{date(2025, 1, 29), Date{2025, 1, 1, false}},
+		{date(2025, 7, 25), Date{2025, 6, 1, true}},
+		{date(2025, 10, 6), Date{2025, 8, 15, false}},
+		{date(2026, 2, 17), Date{2026, 1, 1, false}},
+		{date(2033, 12, 22), Date{2033, 11, 1, true}},
+		{date(2034, 1, 20), Date{2033, 12, 1, false}},
+	}
+	for _, tt := range tests {
+		got, ok := FromSolar(tt.solar)
+		if !ok || got != tt.want {
+			t.Errorf("FromSolar(%s) = %+v, %v, want %+v", tt.solar.Format(time.DateOnly), got, ok, tt.want)
+		}
+	}
+
+	for _, d := range []time.Time{date(1900, 1, 30), date(2101, 6, 1)} {
+		if _, ok := FromSolar(d); ok {
+			t.Errorf("FromSolar(%s) ok, want out of range", d.Format(time.DateOnly))
+		}
+	}
+}
+
+func TestAnnotate(t *testing.T) {
+	tests := map[time.Time]string{
+		date(2024, 2, 9):  "腊月三十 除夕",
+		date(2024, 2, 10): "正月初一 春节",
+		date(2024, 2, 24): "正月十五 元宵节",
+		date(2024, 4, 4):  "二月廿六 清明节",
+		date(2024, 6, 10): "五月初五 端午节",
+		date(2024, 6, 11): "五月初六",
+		date(2020, 5, 23): "闰四月初一",
+		date(2023, 1, 21): "腊月三十 除夕",
+		date(2025, 1, 28): "腊月廿九 除夕",
+		date(2025, 4, 4):  "三月初七 清明节",
+		date(2023, 4, 5):  "闰二月十五 清明节",
+		date(2101, 6, 1):  "",
+	}
+	for d, want := range tests {
+		if got := Annotate(d); got != want {
+			t.Errorf("Annotate(%s) = %q, want %q", d.Format(time.DateOnly), got, want)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package membudget
+
+import (
+	"bytes"
+	"io"
+	"os"
+)
+
+// Buffer 优先在内存中缓存数据，预算不足时溢出到临时文件
+// 写入完成后通过 Reader 读取，使用结束后必须调用 Close 释放额度与临时文件
+type Buffer struct {
+	budget   *Budget
+	mem      bytes.Buffer
+	reserved int64
+	file     *os.File
+	size     int64
+}
+
+func NewBuffer(budget *Budget) *Buffer {
+	if budget == nil {
+		budget = Default
+	}
+	return &Buffer{budget: budget}
+}
+
+func (b *Buffer) Write(p []byte) (int, error) {
+	if b.file == nil {
+		if b.budget.TryAcquire(int64(len(p))) {
+			b.reserved += int64(len(p))
+			n, err := b.mem.Write(p)
+			b.size += int64(n)
+			return n, err
+		}
+		if err := b.spill(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := b.file.Write(p)
+	b.size += int64(n)
+	return n, err
+}
+
+// spill 将已缓存的数据写入临时文件，并释放内存额度
+func (b *Buffer) spill() error {
+	f, err := os.CreateTemp("", "chatlog_spill_*")
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(b.mem.Bytes()); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	b.file = f
+	b.mem = bytes.Buffer{}
+	b.budget.Release(b.reserved)
+	b.reserved = 0
+	return nil
+}
+
+// Len 返回已写入的字节数
+func (b *Buffer) Len() int64 {
+	return b.size
+}
+
+// Spilled 返回数据是否已溢出到磁盘
+func (b *Buffer) Spilled() bool {
+	return b.file != nil
+}
+
+// Reader 从头读取已写入的数据，调用后不应再写入
+func (b *Buffer) Reader() (io.Reader, error) {
+	if b.file == nil {
+		return bytes.NewReader(b.mem.Bytes()), nil
+	}
+	if _, err := b.file.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	return b.file, nil
+}
+
+func (b *Buffer) Close() error {
+	b.budget.Release(b.reserved)
+	b.reserved = 0
+	b.mem = bytes.Buffer{}
+	if b.file == nil {
+		return nil
+	}
+	name := b.file.Name()
+	err := b.file.Close()
+	os.Remove(name)
+	b.file = nil
+	return err
+}
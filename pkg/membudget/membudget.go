@@ -0,0 +1,150 @@
+// Package membudget 提供进程级的内存预算，用于限制解密、内存扫描、索引构建等任务的缓冲区总大小
+// 超出预算时调用方应缩小分块或将数据溢出到磁盘，而不是无限制地分配内存
+package membudget
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Default 全局内存预算，默认不限制，通过 --max-mem 设置
+var Default = New(0)
+
+// Budget 内存预算，limit 为 0 表示不限制
+type Budget struct {
+	mu    sync.Mutex
+	cond  *sync.Cond
+	limit int64
+	used  int64
+}
+
+func New(limit int64) *Budget {
+	b := &Budget{limit: limit}
+	b.cond = sync.NewCond(&b.mu)
+	return b
+}
+
+// SetLimit 修改预算上限，已分配的额度不受影响
+func (b *Budget) SetLimit(limit int64) {
+	b.mu.Lock()
+	b.limit = limit
+	b.mu.Unlock()
+	b.cond.Broadcast()
+}
+
+func (b *Budget) Limit() int64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.limit
+}
+
+func (b *Budget) Used() int64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.used
+}
+
+// Acquire 申请 n 字节额度，额度不足时阻塞直到其他任务释放或 ctx 取消
+// 单次申请超过上限时按上限处理，避免永久阻塞，返回实际占用的额度
+func (b *Budget) Acquire(ctx context.Context, n int64) (int64, error) {
+	stop := context.AfterFunc(ctx, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		b.cond.Broadcast()
+	})
+	defer stop()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for {
+		if err := ctx.Err(); err != nil {
+			return 0, err
+		}
+		if b.limit <= 0 {
+			b.used += n
+			return n, nil
+		}
+		if n > b.limit {
+			n = b.limit
+		}
+		if b.used+n <= b.limit {
+			b.used += n
+			return n, nil
+		}
+		b.cond.Wait()
+	}
+}
+
+// TryAcquire 尝试申请 n 字节额度，不阻塞
+func (b *Budget) TryAcquire(n int64) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.limit > 0 && b.used+n > b.limit {
+		return false
+	}
+	b.used += n
+	return true
+}
+
+// Release 释放额度
+func (b *Budget) Release(n int64) {
+	if n <= 0 {
+		return
+	}
+	b.mu.Lock()
+	b.used -= n
+	if b.used < 0 {
+		b.used = 0
+	}
+	b.mu.Unlock()
+	b.cond.Broadcast()
+}
+
+// ChunkSize 根据预算调整分块大小：不限制时返回 want，否则最多使用预算的 1/8，且不小于 min
+func (b *Budget) ChunkSize(want, min int64) int64 {
+	limit := b.Limit()
+	if limit <= 0 || want <= min {
+		return want
+	}
+	size := limit / 8
+	if size < min {
+		size = min
+	}
+	if size > want {
+		size = want
+	}
+	return size
+}
+
+// ParseSize 解析 512M、2G、1.5GB 等格式的大小，不带单位时按字节处理，0 表示不限制
+func ParseSize(s string) (int64, error) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	if s == "" {
+		return 0, nil
+	}
+	s = strings.TrimSuffix(strings.TrimSuffix(s, "IB"), "B")
+
+	unit := int64(1)
+	switch {
+	case strings.HasSuffix(s, "K"):
+		unit = 1 << 10
+	case strings.HasSuffix(s, "M"):
+		unit = 1 << 20
+	case strings.HasSuffix(s, "G"):
+		unit = 1 << 30
+	case strings.HasSuffix(s, "T"):
+		unit = 1 << 40
+	}
+	if unit > 1 {
+		s = s[:len(s)-1]
+	}
+
+	v, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil || v < 0 {
+		return 0, fmt.Errorf("invalid size: %s", s)
+	}
+	return int64(v * float64(unit)), nil
+}
@@ -0,0 +1,65 @@
+package membudget
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    int64
+		wantErr bool
+	}{
+		{input: "", want: 0},
+		{input: "1024", want: 1024},
+		{input: "512M", want: 512 << 20},
+		{input: "2G", want: 2 << 30},
+		{input: "2gb", want: 2 << 30},
+		{input: "1.5GiB", want: 3 << 29},
+		{input: "abc", wantErr: true},
+		{input: "-1G", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseSize(tt.input)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseSize(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseSize(%q) = %d, want %d", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestBufferSpill(t *testing.T) {
+	budget := New(16)
+	buf := NewBuffer(budget)
+	defer buf.Close()
+
+	data := bytes.Repeat([]byte("0123456789"), 10)
+	for i := 0; i < len(data); i += 10 {
+		if _, err := buf.Write(data[i : i+10]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !buf.Spilled() {
+		t.Fatal("expected buffer to spill to disk")
+	}
+	if budget.Used() != 0 {
+		t.Fatalf("budget used = %d after spill, want 0", budget.Used())
+	}
+
+	r, err := buf.Reader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Fatal("spilled data mismatch")
+	}
+}
@@ -0,0 +1,83 @@
+// Package memscan 分块读取进程内存，避免一次为几百 MB 的内存区域分配缓冲区
+package memscan
+
+import (
+	"context"
+
+	"github.com/aspnmy/chatlog/pkg/membudget"
+)
+
+const (
+	DefaultChunkSize = 16 * 1024 * 1024 // 默认分块大小
+	DefaultOverlap   = 4 * 1024         // 默认重叠区域，大于各搜索策略在模式前后查找的范围
+	MinChunkSize     = 1024 * 1024      // 内存预算不足时的最小分块
+)
+
+var (
+	// ChunkSize 每次读取的最大字节数，0 表示整块读取；设置了内存预算时不超过预算允许的大小
+	ChunkSize int64 = DefaultChunkSize
+
+	// Overlap 相邻分块的重叠字节数，避免模式被分块边界截断
+	Overlap int64 = DefaultOverlap
+)
+
+// ReadFunc 从进程的 addr 处读取 len(b) 字节
+type ReadFunc func(addr uint64, b []byte) error
+
+// Read 分块读取 [addr, addr+size) 并发送到 memoryChannel，读取失败的分块会被跳过
+// 每块占用的预算额度由接收方处理完成后调用 Release 释放
+// 返回 false 表示上下文已取消
+func Read(ctx context.Context, read ReadFunc, addr uint64, size uint64, memoryChannel chan<- []byte) bool {
+	want := int64(size)
+	if ChunkSize > 0 && ChunkSize < want {
+		want = ChunkSize
+	}
+	chunkSize := uint64(membudget.Default.ChunkSize(want, MinChunkSize))
+	overlap := uint64(max(Overlap, 0))
+
+	for offset := uint64(0); offset < size; {
+		n := chunkSize
+		if offset+n > size {
+			n = size - offset
+		}
+		acquired, err := membudget.Default.Acquire(ctx, int64(n))
+		if err != nil {
+			return false
+		}
+		n = uint64(acquired)
+
+		memory := make([]byte, n)
+		if err := read(addr+offset, memory); err != nil {
+			Release(memory)
+		} else {
+			select {
+			case memoryChannel <- memory:
+			case <-ctx.Done():
+				Release(memory)
+				return false
+			}
+		}
+
+		if offset+n >= size {
+			break
+		}
+		// 分块不大于重叠区域时不再重叠，保证读取向前推进
+		if n > overlap {
+			n -= overlap
+		}
+		offset += n
+	}
+	return true
+}
+
+// Release 释放 Read 为内存块申请的预算额度
+func Release(memory []byte) {
+	membudget.Default.Release(int64(len(memory)))
+}
+
+// Drain 在扫描结束后释放通道中未被处理的内存块
+func Drain(memoryChannel <-chan []byte) {
+	for memory := range memoryChannel {
+		Release(memory)
+	}
+}
@@ -0,0 +1,92 @@
+package memscan
+
+import (
+	"bytes"
+	"context"
+	"testing"
+)
+
+func TestRead(t *testing.T) {
+	defer func(size, overlap int64) { ChunkSize, Overlap = size, overlap }(ChunkSize, Overlap)
+	ChunkSize, Overlap = 1000, 100
+
+	// 模拟进程内存，模式跨越第一个分块的边界
+	const base = 0x10000
+	process := make([]byte, 2500)
+	pattern := []byte("SetDBKey")
+	copy(process[996:], pattern)
+	read := func(addr uint64, b []byte) error {
+		copy(b, process[addr-base:])
+		return nil
+	}
+
+	ch := make(chan []byte, 10)
+	if !Read(context.Background(), read, base, uint64(len(process)), ch) {
+		t.Fatal("Read canceled")
+	}
+	close(ch)
+
+	var chunks [][]byte
+	found := false
+	for memory := range ch {
+		chunks = append(chunks, memory)
+		if bytes.Contains(memory, pattern) {
+			found = true
+		}
+		Release(memory)
+	}
+	if len(chunks) != 3 {
+		t.Errorf("got %d chunks, want 3", len(chunks))
+	}
+	for i, memory := range chunks {
+		if len(memory) > 1000 {
+			t.Errorf("chunk %d is %d bytes, larger than ChunkSize", i, len(memory))
+		}
+	}
+	if !found {
+		t.Error("pattern across chunk boundary not found")
+	}
+	// 最后一块结束于区域末尾
+	if last := chunks[len(chunks)-1]; len(last) != 2500-2*900 {
+		t.Errorf("last chunk is %d bytes", len(last))
+	}
+}
+
+func TestReadWholeRegion(t *testing.T) {
+	defer func(size int64) { ChunkSize = size }(ChunkSize)
+	ChunkSize = 0
+
+	ch := make(chan []byte, 10)
+	Read(context.Background(), func(addr uint64, b []byte) error { return nil }, 0, 5000, ch)
+	close(ch)
+	if memory := <-ch; len(memory) != 5000 {
+		t.Errorf("got %d bytes, want the whole region", len(memory))
+	}
+}
+
+func TestProgress(t *testing.T) {
+	// 未设置回调时为 nil，调用不会出错
+	p := NewProgress(nil, 100)
+	p.Start("0x10000")
+	p.Add(10, "0x10000")
+	p.Finish()
+
+	var got [][2]uint64
+	p = NewProgress(func(scanned, total uint64, region string) {
+		got = append(got, [2]uint64{scanned, total})
+	}, 100)
+	p.Start("0x10000")
+	p.Add(60, "0x10000")
+	p.Add(60, "0x20000") // 不超过总大小
+	p.Finish()
+
+	want := [][2]uint64{{0, 100}, {60, 100}, {100, 100}, {100, 100}}
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("got %v, want %v", got, want)
+		}
+	}
+}
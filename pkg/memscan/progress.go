@@ -0,0 +1,98 @@
+package memscan
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/aspnmy/chatlog/pkg/util"
+)
+
+// ProgressFunc 接收扫描进度，scanned 与 total 为字节数，region 为正在读取的内存区域
+// 在读取内存的协程中调用，应尽快返回
+type ProgressFunc func(scanned, total uint64, region string)
+
+// Progress 累计已交给搜索协程的字节数并回调 ProgressFunc，为 nil 时不做任何事
+type Progress struct {
+	fn      ProgressFunc
+	total   uint64
+	scanned uint64
+}
+
+// NewProgress 创建进度，fn 为 nil 时返回 nil
+func NewProgress(fn ProgressFunc, total uint64) *Progress {
+	if fn == nil {
+		return nil
+	}
+	return &Progress{fn: fn, total: total}
+}
+
+// Start 报告开始读取 region
+func (p *Progress) Start(region string) {
+	if p == nil {
+		return
+	}
+	p.fn(p.scanned, p.total, region)
+}
+
+// Add 报告 region 中又有 n 字节已读取
+func (p *Progress) Add(n uint64, region string) {
+	if p == nil {
+		return
+	}
+	p.scanned = min(p.scanned+n, p.total)
+	p.fn(p.scanned, p.total, region)
+}
+
+// Finish 报告全部内存已读取
+func (p *Progress) Finish() {
+	if p == nil {
+		return
+	}
+	p.scanned = p.total
+	p.fn(p.scanned, p.total, "")
+}
+
+// Bar 在终端中以单行刷新的方式显示扫描进度
+type Bar struct {
+	w        io.Writer
+	interval time.Duration
+
+	mu   sync.Mutex
+	last time.Time
+	drew bool
+}
+
+// NewBar 创建进度条，每 interval 最多刷新一次
+func NewBar(w io.Writer, interval time.Duration) *Bar {
+	return &Bar{w: w, interval: interval}
+}
+
+// Update 实现 ProgressFunc
+func (b *Bar) Update(scanned, total uint64, region string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := time.Now()
+	if scanned < total && now.Sub(b.last) < b.interval {
+		return
+	}
+	b.last = now
+
+	percent := 100.0
+	if total > 0 {
+		percent = float64(scanned) * 100 / float64(total)
+	}
+	fmt.Fprintf(b.w, "\r\033[K扫描内存 %5.1f%% %s/%s %s", percent, util.ByteCountSI(int64(scanned)), util.ByteCountSI(int64(total)), region)
+	b.drew = true
+}
+
+// Done 结束进度条所在的行，之后的输出从新行开始
+func (b *Bar) Done() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.drew {
+		fmt.Fprintln(b.w)
+		b.drew = false
+	}
+}
@@ -0,0 +1,170 @@
+package publish
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	FeishuAPI = "https://open.feishu.cn"
+	LarkAPI   = "https://open.larksuite.com"
+
+	// 飞书单次请求最多创建 50 个块
+	feishuMaxBlocks = 50
+	feishuMaxText   = 10000
+
+	feishuBlockText     = 2
+	feishuBlockHeading2 = 4
+)
+
+// Feishu 使用自建应用的身份在 folder 文件夹中创建飞书云文档（docx），需要将应用添加为文件夹的协作者
+type Feishu struct {
+	appID     string
+	appSecret string
+	folder    string
+	api       string
+	client    *client
+
+	mu      sync.Mutex
+	token   string
+	expires time.Time
+}
+
+// NewFeishu 创建飞书推送，api 为空时使用 FeishuAPI，Lark 国际版使用 LarkAPI
+// rate 为每秒请求数，不大于 0 时使用 DefaultRate
+func NewFeishu(api, appID, appSecret, folder string, rate float64) *Feishu {
+	if api == "" {
+		api = FeishuAPI
+	}
+	return &Feishu{
+		appID:     appID,
+		appSecret: appSecret,
+		folder:    folder,
+		api:       strings.TrimSuffix(api, "/"),
+		client:    newClient(rate),
+	}
+}
+
+func (f *Feishu) String() string {
+	if f.folder == "" {
+		return "feishu root folder"
+	}
+	return "feishu folder " + f.folder
+}
+
+// feishuResp 飞书接口的通用响应，code 不为 0 时表示失败
+type feishuResp struct {
+	Code int    `json:"code"`
+	Msg  string `json:"msg"`
+}
+
+func (r feishuResp) err(api string) error {
+	if r.Code != 0 {
+		return fmt.Errorf("feishu %s: %d %s", api, r.Code, r.Msg)
+	}
+	return nil
+}
+
+func (f *Feishu) Publish(ctx context.Context, doc *Document) (string, error) {
+	var created struct {
+		feishuResp
+		Data struct {
+			Document struct {
+				DocumentID string `json:"document_id"`
+			} `json:"document"`
+		} `json:"data"`
+	}
+	err := f.client.do(ctx, http.MethodPost, f.api+"/open-apis/docx/v1/documents", f.header(ctx), map[string]any{
+		"folder_token": f.folder,
+		"title":        doc.Title,
+	}, &created)
+	if err == nil {
+		err = created.err("create document")
+	}
+	if err != nil {
+		return "", err
+	}
+	id := created.Data.Document.DocumentID
+	url := f.docURL(id)
+
+	// 文档根块的 ID 与文档 ID 相同，内容按顺序追加到末尾
+	blocks := make([]map[string]any, 0, len(doc.Blocks))
+	for _, b := range doc.Blocks {
+		blocks = append(blocks, feishuBlock(b))
+	}
+	for len(blocks) > 0 {
+		batch := blocks[:min(len(blocks), feishuMaxBlocks)]
+		blocks = blocks[len(batch):]
+		var resp feishuResp
+		err := f.client.do(ctx, http.MethodPost, fmt.Sprintf("%s/open-apis/docx/v1/documents/%s/blocks/%s/children?document_revision_id=-1", f.api, id, id), f.header(ctx), map[string]any{
+			"children": batch,
+			"index":    -1,
+		}, &resp)
+		if err == nil {
+			err = resp.err("create blocks")
+		}
+		if err != nil {
+			return url, err
+		}
+	}
+	return url, nil
+}
+
+// docURL 返回文档链接，飞书会重定向到所在租户的域名
+func (f *Feishu) docURL(id string) string {
+	return strings.Replace(f.api, "://open.", "://", 1) + "/docx/" + id
+}
+
+// header 返回设置 tenant_access_token 的请求头函数，令牌过期前 5 分钟自动刷新
+func (f *Feishu) header(ctx context.Context) func(http.Header) error {
+	return func(h http.Header) error {
+		token, err := f.tenantToken(ctx)
+		if err != nil {
+			return err
+		}
+		h.Set("Authorization", "Bearer "+token)
+		return nil
+	}
+}
+
+func (f *Feishu) tenantToken(ctx context.Context) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.token != "" && time.Now().Before(f.expires) {
+		return f.token, nil
+	}
+
+	var resp struct {
+		feishuResp
+		Token  string `json:"tenant_access_token"`
+		Expire int    `json:"expire"`
+	}
+	err := f.client.do(ctx, http.MethodPost, f.api+"/open-apis/auth/v3/tenant_access_token/internal", nil, map[string]any{
+		"app_id":     f.appID,
+		"app_secret": f.appSecret,
+	}, &resp)
+	if err == nil {
+		err = resp.err("get tenant access token")
+	}
+	if err != nil {
+		return "", err
+	}
+	f.token = resp.Token
+	f.expires = time.Now().Add(time.Duration(resp.Expire)*time.Second - 5*time.Minute)
+	return f.token, nil
+}
+
+func feishuBlock(b Block) map[string]any {
+	var elements []map[string]any
+	for _, c := range chunks(b.Text, feishuMaxText) {
+		elements = append(elements, map[string]any{"text_run": map[string]any{"content": c}})
+	}
+	if b.Kind == Heading {
+		return map[string]any{"block_type": feishuBlockHeading2, "heading2": map[string]any{"elements": elements}}
+	}
+	return map[string]any{"block_type": feishuBlockText, "text": map[string]any{"elements": elements}}
+}
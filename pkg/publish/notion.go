@@ -0,0 +1,99 @@
+package publish
+
+import (
+	"context"
+	"net/http"
+)
+
+const (
+	NotionAPI     = "https://api.notion.com/v1"
+	NotionVersion = "2022-06-28"
+
+	// Notion 单次请求最多 100 个块，单段文本最多 2000 个字符
+	notionMaxBlocks = 100
+	notionMaxText   = 2000
+)
+
+// Notion 将文档创建为 parent 页面的子页面，需要在 parent 页面中添加集成（integration）的访问权限
+type Notion struct {
+	token  string
+	parent string
+	api    string
+	client *client
+}
+
+// NewNotion 创建 Notion 推送，rate 为每秒请求数，不大于 0 时使用 DefaultRate
+func NewNotion(token, parent string, rate float64) *Notion {
+	return &Notion{
+		token:  token,
+		parent: parent,
+		api:    NotionAPI,
+		client: newClient(rate),
+	}
+}
+
+func (n *Notion) String() string {
+	return "notion page " + n.parent
+}
+
+func (n *Notion) Publish(ctx context.Context, doc *Document) (string, error) {
+	blocks := make([]map[string]any, 0, len(doc.Blocks))
+	for _, b := range doc.Blocks {
+		blocks = append(blocks, notionBlock(b))
+	}
+	first := blocks[:min(len(blocks), notionMaxBlocks)]
+
+	var page struct {
+		ID  string `json:"id"`
+		URL string `json:"url"`
+	}
+	err := n.client.do(ctx, http.MethodPost, n.api+"/pages", n.header, map[string]any{
+		"parent": map[string]any{"page_id": n.parent},
+		"properties": map[string]any{
+			"title": map[string]any{"title": notionText(doc.Title)},
+		},
+		"children": first,
+	}, &page)
+	if err != nil {
+		return "", err
+	}
+
+	// 其余的块分批追加
+	for rest := blocks[len(first):]; len(rest) > 0; {
+		batch := rest[:min(len(rest), notionMaxBlocks)]
+		rest = rest[len(batch):]
+		if err := n.client.do(ctx, http.MethodPatch, n.api+"/blocks/"+page.ID+"/children", n.header, map[string]any{"children": batch}, nil); err != nil {
+			return page.URL, err
+		}
+	}
+	return page.URL, nil
+}
+
+func (n *Notion) header(h http.Header) error {
+	h.Set("Authorization", "Bearer "+n.token)
+	h.Set("Notion-Version", NotionVersion)
+	return nil
+}
+
+func notionBlock(b Block) map[string]any {
+	kind := "paragraph"
+	if b.Kind == Heading {
+		kind = "heading_2"
+	}
+	return map[string]any{
+		"object": "block",
+		"type":   kind,
+		kind:     map[string]any{"rich_text": notionText(b.Text)},
+	}
+}
+
+func notionText(text string) []map[string]any {
+	var rich []map[string]any
+	for _, c := range chunks(text, notionMaxText) {
+		rich = append(rich, map[string]any{
+			"type": "text",
+			"text": map[string]any{"content": c},
+		})
+	}
+	return rich
+}
@@ -0,0 +1,173 @@
+package publish
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultRate 默认每秒请求数，Notion 与飞书文档接口的频率限制都在每秒 3 次左右
+	DefaultRate = 3
+
+	// maxRetries 被限流或服务端出错时的最大重试次数
+	maxRetries = 3
+)
+
+// BlockKind 文档块类型
+type BlockKind int
+
+const (
+	Paragraph BlockKind = iota
+	Heading
+)
+
+// Block 文档中的一段内容
+type Block struct {
+	Kind BlockKind
+	Text string
+}
+
+// Document 要推送的文档
+type Document struct {
+	Title  string
+	Blocks []Block
+}
+
+// Publisher 将文档推送到在线文档服务
+type Publisher interface {
+	// Publish 创建文档并写入全部内容，返回文档链接
+	Publish(ctx context.Context, doc *Document) (string, error)
+
+	// String 返回用于日志展示的目标描述，不包含令牌等敏感信息
+	String() string
+}
+
+// limiter 按固定间隔放行请求
+type limiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	next     time.Time
+}
+
+func newLimiter(rate float64) *limiter {
+	if rate <= 0 {
+		rate = DefaultRate
+	}
+	return &limiter{interval: time.Duration(float64(time.Second) / rate)}
+}
+
+// Wait 等待到下一个可以发送请求的时间
+func (l *limiter) Wait(ctx context.Context) error {
+	l.mu.Lock()
+	now := time.Now()
+	at := l.next
+	if at.Before(now) {
+		at = now
+	}
+	l.next = at.Add(l.interval)
+	l.mu.Unlock()
+
+	if d := time.Until(at); d > 0 {
+		t := time.NewTimer(d)
+		defer t.Stop()
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-t.C:
+		}
+	}
+	return nil
+}
+
+// client 发送 JSON 请求，受 limiter 限速，被限流（429）或服务端出错时按 Retry-After 重试
+type client struct {
+	http    *http.Client
+	limiter *limiter
+}
+
+func newClient(rate float64) *client {
+	return &client{
+		http:    &http.Client{Timeout: 30 * time.Second},
+		limiter: newLimiter(rate),
+	}
+}
+
+// do 发送请求并将响应解析到 out，header 在每次请求时调用，便于刷新令牌
+// 非 2xx 响应返回包含响应内容的错误
+func (c *client) do(ctx context.Context, method, url string, header func(http.Header) error, in, out any) error {
+	var body []byte
+	if in != nil {
+		var err error
+		if body, err = json.Marshal(in); err != nil {
+			return err
+		}
+	}
+
+	for attempt := 0; ; attempt++ {
+		if err := c.limiter.Wait(ctx); err != nil {
+			return err
+		}
+		req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json; charset=utf-8")
+		if header != nil {
+			if err := header(req.Header); err != nil {
+				return err
+			}
+		}
+		resp, err := c.http.Do(req)
+		if err != nil {
+			return err
+		}
+		b, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return err
+		}
+
+		retry := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+		if retry && attempt < maxRetries {
+			wait := time.Duration(attempt+1) * time.Second
+			if s, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && s >= 0 {
+				wait = time.Duration(s) * time.Second
+			}
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(wait):
+			}
+			continue
+		}
+		if resp.StatusCode < 200 || resp.StatusCode > 299 {
+			return fmt.Errorf("%s %s: %s: %s", method, req.URL.Path, resp.Status, bytes.TrimSpace(b))
+		}
+		if out == nil {
+			return nil
+		}
+		return json.Unmarshal(b, out)
+	}
+}
+
+// chunks 按 size 拆分文本，避免超过单段文本的长度限制，按字符而不是字节拆分
+func chunks(text string, size int) []string {
+	r := []rune(text)
+	if len(r) <= size {
+		return []string{text}
+	}
+	var result []string
+	for len(r) > 0 {
+		n := min(size, len(r))
+		result = append(result, string(r[:n]))
+		r = r[n:]
+	}
+	return result
+}
@@ -0,0 +1,111 @@
+package publish
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func testDocument(n int) *Document {
+	doc := &Document{Title: "test"}
+	for i := range n {
+		doc.Blocks = append(doc.Blocks, Block{Text: fmt.Sprint(i)})
+	}
+	return doc
+}
+
+func TestNotion(t *testing.T) {
+	var mu sync.Mutex
+	var requests []string
+	blocks := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" || r.Header.Get("Notion-Version") == "" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		var body struct {
+			Children []json.RawMessage `json:"children"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		mu.Lock()
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		blocks += len(body.Children)
+		retry := len(requests) == 2
+		mu.Unlock()
+		if retry {
+			// 第一次追加时模拟限流
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			blocks -= len(body.Children)
+			return
+		}
+		fmt.Fprint(w, `{"id":"page1","url":"https://notion.so/page1"}`)
+	}))
+	defer srv.Close()
+
+	n := NewNotion("secret", "parent", 1000)
+	n.api = srv.URL
+	url, err := n.Publish(context.Background(), testDocument(250))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if url != "https://notion.so/page1" {
+		t.Errorf("url = %s", url)
+	}
+	want := "POST /pages,PATCH /blocks/page1/children,PATCH /blocks/page1/children,PATCH /blocks/page1/children"
+	if got := strings.Join(requests, ","); got != want || blocks != 250 {
+		t.Errorf("requests = %s, blocks = %d", got, blocks)
+	}
+}
+
+func TestFeishu(t *testing.T) {
+	var tokens, blocks int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/open-apis/auth/v3/tenant_access_token/internal":
+			tokens++
+			fmt.Fprint(w, `{"code":0,"tenant_access_token":"t-1","expire":7200}`)
+		case r.Header.Get("Authorization") != "Bearer t-1":
+			fmt.Fprint(w, `{"code":99991663,"msg":"invalid token"}`)
+		case r.URL.Path == "/open-apis/docx/v1/documents":
+			fmt.Fprint(w, `{"code":0,"data":{"document":{"document_id":"doc1"}}}`)
+		case r.URL.Path == "/open-apis/docx/v1/documents/doc1/blocks/doc1/children":
+			var body struct {
+				Children []json.RawMessage `json:"children"`
+			}
+			json.NewDecoder(r.Body).Decode(&body)
+			if len(body.Children) > feishuMaxBlocks {
+				fmt.Fprint(w, `{"code":1770001,"msg":"too many children"}`)
+				return
+			}
+			blocks += len(body.Children)
+			fmt.Fprint(w, `{"code":0}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	f := NewFeishu(srv.URL, "app", "secret", "folder", 1000)
+	url, err := f.Publish(context.Background(), testDocument(120))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(url, "/docx/doc1") {
+		t.Errorf("url = %s", url)
+	}
+	if tokens != 1 || blocks != 120 {
+		t.Errorf("tokens = %d, blocks = %d", tokens, blocks)
+	}
+}
+
+func TestChunks(t *testing.T) {
+	if got := chunks("你好世界", 3); len(got) != 2 || got[0] != "你好世" || got[1] != "界" {
+		t.Errorf("chunks = %q", got)
+	}
+}
@@ -0,0 +1,143 @@
+// Package search 提供搜索结果的命中高亮与摘要生成
+// 所有偏移量均以 Unicode 字符（rune）为单位，保证中日韩文字不会被截断
+package search
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// DefaultSnippetLength 摘要默认的最大字符数
+	DefaultSnippetLength = 80
+
+	ellipsis = "…"
+)
+
+// Range 命中区间，[Start, End) 为字符偏移
+type Range struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
+
+// Snippet 带高亮区间的摘要
+type Snippet struct {
+	Text       string  `json:"text"`
+	Highlights []Range `json:"highlights"`
+}
+
+// Match 返回 text 中所有匹配 re 的区间，相邻或重叠的区间会被合并
+func Match(text string, re *regexp.Regexp) []Range {
+	if re == nil || text == "" {
+		return nil
+	}
+	locs := re.FindAllStringIndex(text, -1)
+	ranges := make([]Range, 0, len(locs))
+	for _, loc := range locs {
+		if loc[0] == loc[1] {
+			continue
+		}
+		ranges = append(ranges, Range{
+			Start: utf8.RuneCountInString(text[:loc[0]]),
+			End:   utf8.RuneCountInString(text[:loc[1]]),
+		})
+	}
+	return merge(ranges)
+}
+
+// MatchTerms 返回 text 中所有出现 terms 的区间（忽略大小写），相邻或重叠的区间会被合并
+func MatchTerms(text string, terms []string) []Range {
+	quoted := make([]string, 0, len(terms))
+	for _, t := range terms {
+		if t = strings.TrimSpace(t); t != "" {
+			quoted = append(quoted, regexp.QuoteMeta(t))
+		}
+	}
+	if len(quoted) == 0 {
+		return nil
+	}
+	// 长词优先，避免短词抢先匹配
+	sort.Slice(quoted, func(i, j int) bool { return len(quoted[i]) > len(quoted[j]) })
+	return Match(text, regexp.MustCompile("(?i)"+strings.Join(quoted, "|")))
+}
+
+// MakeSnippet 以第一个命中为中心截取不超过 maxLen 个字符的摘要，并换算高亮区间
+func MakeSnippet(text string, hits []Range, maxLen int) Snippet {
+	if maxLen <= 0 {
+		maxLen = DefaultSnippetLength
+	}
+	runes := []rune(text)
+	if len(runes) <= maxLen {
+		return Snippet{Text: text, Highlights: hits}
+	}
+
+	start := 0
+	if len(hits) > 0 {
+		first := hits[0]
+		start = first.Start - (maxLen-(first.End-first.Start))/2
+	}
+	start = max(0, min(start, len(runes)-maxLen))
+	end := start + maxLen
+
+	var sb strings.Builder
+	shift := -start
+	if start > 0 {
+		sb.WriteString(ellipsis)
+		shift++
+	}
+	sb.WriteString(string(runes[start:end]))
+	if end < len(runes) {
+		sb.WriteString(ellipsis)
+	}
+
+	highlights := make([]Range, 0, len(hits))
+	for _, h := range hits {
+		s, e := max(h.Start, start), min(h.End, end)
+		if s >= e {
+			continue
+		}
+		highlights = append(highlights, Range{Start: s + shift, End: e + shift})
+	}
+	return Snippet{Text: sb.String(), Highlights: highlights}
+}
+
+// Mark 在高亮区间前后插入标记，如 Mark("<b>", "</b>")
+func (s Snippet) Mark(pre, post string) string {
+	if len(s.Highlights) == 0 {
+		return s.Text
+	}
+	runes := []rune(s.Text)
+	var sb strings.Builder
+	last := 0
+	for _, h := range s.Highlights {
+		if h.Start < last || h.End > len(runes) {
+			continue
+		}
+		sb.WriteString(string(runes[last:h.Start]))
+		sb.WriteString(pre)
+		sb.WriteString(string(runes[h.Start:h.End]))
+		sb.WriteString(post)
+		last = h.End
+	}
+	sb.WriteString(string(runes[last:]))
+	return sb.String()
+}
+
+func merge(ranges []Range) []Range {
+	if len(ranges) <= 1 {
+		return ranges
+	}
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i].Start < ranges[j].Start })
+	merged := ranges[:1]
+	for _, r := range ranges[1:] {
+		last := &merged[len(merged)-1]
+		if r.Start <= last.End {
+			last.End = max(last.End, r.End)
+			continue
+		}
+		merged = append(merged, r)
+	}
+	return merged
+}
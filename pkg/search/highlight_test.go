@@ -0,0 +1,44 @@
+package search
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestMatchCJK(t *testing.T) {
+	text := "明天下午开会，记得带上会议纪要"
+	hits := Match(text, regexp.MustCompile("会议|开会"))
+	want := []Range{{Start: 4, End: 6}, {Start: 11, End: 13}}
+	if len(hits) != len(want) {
+		t.Fatalf("Match() = %v, want %v", hits, want)
+	}
+	for i := range want {
+		if hits[i] != want[i] {
+			t.Fatalf("Match() = %v, want %v", hits, want)
+		}
+	}
+
+	s := MakeSnippet(text, hits, 0)
+	if got := s.Mark("[", "]"); got != "明天下午[开会]，记得带上[会议]纪要" {
+		t.Fatalf("Mark() = %q", got)
+	}
+}
+
+func TestMakeSnippetWindow(t *testing.T) {
+	text := "一二三四五六七八九十甲乙丙丁戊己庚辛壬癸"
+	hits := MatchTerms(text, []string{"甲乙"})
+	s := MakeSnippet(text, hits, 6)
+	if s.Text != "…九十甲乙丙丁…" {
+		t.Fatalf("MakeSnippet().Text = %q", s.Text)
+	}
+	if got := s.Mark("<", ">"); got != "…九十<甲乙>丙丁…" {
+		t.Fatalf("Mark() = %q", got)
+	}
+}
+
+func TestMatchTermsLongest(t *testing.T) {
+	hits := MatchTerms("Hello World", []string{"he", "HELLO"})
+	if len(hits) != 1 || hits[0] != (Range{Start: 0, End: 5}) {
+		t.Fatalf("MatchTerms() = %v", hits)
+	}
+}
@@ -0,0 +1,593 @@
+package search
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// IndexVersion 索引结构版本，结构变化时需要重建索引
+const IndexVersion = 1
+
+const schema = `
+CREATE TABLE IF NOT EXISTS meta (
+	key   TEXT PRIMARY KEY,
+	value TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS docs (
+	id          INTEGER PRIMARY KEY,
+	talker      TEXT NOT NULL,
+	talker_name TEXT NOT NULL,
+	seq         INTEGER NOT NULL,
+	time        INTEGER NOT NULL,
+	sender      TEXT NOT NULL,
+	sender_name TEXT NOT NULL,
+	is_self     INTEGER NOT NULL,
+	is_chatroom INTEGER NOT NULL,
+	type        INTEGER NOT NULL,
+	sub_type    INTEGER NOT NULL,
+	content     TEXT NOT NULL,
+	UNIQUE (talker, seq)
+);
+CREATE INDEX IF NOT EXISTS docs_time ON docs (time, seq);
+CREATE TABLE IF NOT EXISTS checkpoints (
+	talker TEXT PRIMARY KEY
+);
+CREATE TABLE IF NOT EXISTS postings (
+	token TEXT NOT NULL,
+	doc   INTEGER NOT NULL,
+	PRIMARY KEY (token, doc)
+) WITHOUT ROWID;
+`
+
+// Options 索引构建参数，保存在索引中，查询时使用相同的分词器
+type Options struct {
+	Tokenizer string
+	Dict      string
+	UserDict  string
+}
+
+// Doc 索引中的一条消息
+type Doc struct {
+	ID         int64 // 索引中的编号，写入时忽略
+	Talker     string
+	TalkerName string
+	Seq        int64
+	Time       time.Time
+	Sender     string
+	SenderName string
+	IsSelf     bool
+	IsChatRoom bool
+	Type       int64
+	SubType    int64
+	Content    string
+}
+
+// Query 索引查询条件，Text 中以空白分隔的多个词需同时命中，每个词命中其任一同义词即可
+type Query struct {
+	Text     string
+	Synonyms *Synonyms
+	Talkers  []string
+	Senders  []string
+	Start    time.Time
+	End      time.Time
+	Types    []int64
+
+	ExcludeTalkers []string // 排除的会话
+	ExcludeIDs     []int64  // 排除的文档编号
+	Limit          int      // 为 0 时不限制数量
+	Offset         int
+}
+
+// Info 索引概况
+type Info struct {
+	Path      string    `json:"path"`
+	Options   Options   `json:"options"`
+	Docs      int64     `json:"docs"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// Index 基于 SQLite 的倒排索引
+type Index struct {
+	path string
+	db   *sql.DB
+	opts Options
+	tok  Tokenizer
+}
+
+// Create 新建索引，已存在的索引文件会被删除
+func Create(path string, opts Options) (*Index, error) {
+	tok, err := NewTokenizer(opts.Tokenizer, opts.Dict, opts.UserDict)
+	if err != nil {
+		return nil, err
+	}
+	opts.Tokenizer = tok.Name()
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	for _, suffix := range []string{"", "-wal", "-shm"} {
+		if err := os.Remove(path + suffix); err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+
+	ix, err := open(path)
+	if err != nil {
+		return nil, err
+	}
+	ix.opts, ix.tok = opts, tok
+	if err := ix.setMeta(map[string]string{
+		"version":   strconv.Itoa(IndexVersion),
+		"tokenizer": opts.Tokenizer,
+		"dict":      opts.Dict,
+		"user_dict": opts.UserDict,
+		"building":  "1",
+	}); err != nil {
+		ix.Close()
+		return nil, err
+	}
+	return ix, nil
+}
+
+// Open 打开已建立完成的索引，索引不存在、未建立完成或版本不匹配时返回 ErrNotBuilt
+func Open(path string) (*Index, error) {
+	ix, meta, err := openExisting(path)
+	if err != nil {
+		return nil, err
+	}
+	if meta["building"] == "1" {
+		ix.Close()
+		return nil, ErrNotBuilt
+	}
+	return ix, nil
+}
+
+// Resume 打开使用相同参数且未建立完成的索引，用于中断后继续建立
+// 索引不存在、已建立完成或参数不同时返回 ErrNotBuilt
+func Resume(path string, opts Options) (*Index, error) {
+	tok, err := NewTokenizer(opts.Tokenizer, opts.Dict, opts.UserDict)
+	if err != nil {
+		return nil, err
+	}
+	opts.Tokenizer = tok.Name()
+
+	ix, meta, err := openExisting(path)
+	if err != nil {
+		return nil, err
+	}
+	if meta["building"] != "1" || ix.opts != opts {
+		ix.Close()
+		return nil, ErrNotBuilt
+	}
+	return ix, nil
+}
+
+func openExisting(path string) (*Index, map[string]string, error) {
+	if _, err := os.Stat(path); err != nil {
+		return nil, nil, ErrNotBuilt
+	}
+	ix, err := open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	meta, err := ix.meta()
+	if err != nil {
+		ix.Close()
+		return nil, nil, err
+	}
+	if meta["version"] != strconv.Itoa(IndexVersion) {
+		ix.Close()
+		return nil, nil, ErrNotBuilt
+	}
+	ix.opts = Options{Tokenizer: meta["tokenizer"], Dict: meta["dict"], UserDict: meta["user_dict"]}
+	if ix.tok, err = NewTokenizer(ix.opts.Tokenizer, ix.opts.Dict, ix.opts.UserDict); err != nil {
+		ix.Close()
+		return nil, nil, err
+	}
+	return ix, meta, nil
+}
+
+// ErrNotBuilt 索引尚未建立
+var ErrNotBuilt = fmt.Errorf("search index not built, run `chatlog index rebuild` first")
+
+// driverName 注册了 unicode_lower 函数的 SQLite 驱动，SQLite 内置的 lower 只转换 ASCII 字母
+const driverName = "sqlite3_search"
+
+func init() {
+	sql.Register(driverName, &sqlite3.SQLiteDriver{
+		ConnectHook: func(conn *sqlite3.SQLiteConn) error {
+			return conn.RegisterFunc("unicode_lower", strings.ToLower, true)
+		},
+	})
+}
+
+func open(path string) (*Index, error) {
+	db, err := sql.Open(driverName, path+"?_journal_mode=WAL&_synchronous=NORMAL&_busy_timeout=5000")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := db.Exec(schema); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &Index{path: path, db: db}, nil
+}
+
+func (ix *Index) Close() error {
+	return ix.db.Close()
+}
+
+func (ix *Index) Options() Options {
+	return ix.opts
+}
+
+func (ix *Index) meta() (map[string]string, error) {
+	rows, err := ix.db.Query(`SELECT key, value FROM meta`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	meta := make(map[string]string)
+	for rows.Next() {
+		var k, v string
+		if err := rows.Scan(&k, &v); err != nil {
+			return nil, err
+		}
+		meta[k] = v
+	}
+	return meta, rows.Err()
+}
+
+func (ix *Index) setMeta(kv map[string]string) error {
+	tx, err := ix.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for k, v := range kv {
+		if _, err := tx.Exec(`INSERT OR REPLACE INTO meta (key, value) VALUES (?, ?)`, k, v); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// Batch 一次提交的文档，分词在 NewBatch 中完成
+type Batch struct {
+	docs   []Doc
+	tokens [][]string
+	done   []string
+}
+
+// NewBatch 对文档分词并生成提交批次，done 为本批次提交后即全部写入的会话
+// NewBatch 可在多个 goroutine 中并发调用，Commit 需串行调用
+func (ix *Index) NewBatch(docs []Doc, done ...string) *Batch {
+	b := &Batch{docs: docs, tokens: make([][]string, len(docs)), done: done}
+	for i, d := range docs {
+		b.tokens[i] = ix.tok.Tokenize(d.Content)
+	}
+	return b
+}
+
+// Len 返回批次中的文档数
+func (b *Batch) Len() int {
+	return len(b.docs)
+}
+
+// Add 在一个事务中写入一批文档，已存在的文档（talker + seq 相同）会被跳过
+func (ix *Index) Add(docs []Doc) error {
+	return ix.Commit(ix.NewBatch(docs))
+}
+
+// Commit 在一个事务中写入批次，并记录已完成的会话，中断后可据此继续建立
+func (ix *Index) Commit(b *Batch) error {
+	tx, err := ix.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	insDoc, err := tx.Prepare(`INSERT OR IGNORE INTO docs (talker, talker_name, seq, time, sender, sender_name, is_self, is_chatroom, type, sub_type, content) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return err
+	}
+	defer insDoc.Close()
+	insPosting, err := tx.Prepare(`INSERT OR IGNORE INTO postings (token, doc) VALUES (?, ?)`)
+	if err != nil {
+		return err
+	}
+	defer insPosting.Close()
+
+	for i, d := range b.docs {
+		res, err := insDoc.Exec(d.Talker, d.TalkerName, d.Seq, d.Time.Unix(), d.Sender, d.SenderName, d.IsSelf, d.IsChatRoom, d.Type, d.SubType, d.Content)
+		if err != nil {
+			return err
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			continue
+		}
+		id, err := res.LastInsertId()
+		if err != nil {
+			return err
+		}
+		for _, token := range b.tokens[i] {
+			if _, err := insPosting.Exec(token, id); err != nil {
+				return err
+			}
+		}
+	}
+	for _, talker := range b.done {
+		if _, err := tx.Exec(`INSERT OR IGNORE INTO checkpoints (talker) VALUES (?)`, talker); err != nil {
+			return err
+		}
+	}
+
+	if _, err := tx.Exec(`INSERT OR REPLACE INTO meta (key, value) VALUES ('updated_at', ?)`, strconv.FormatInt(time.Now().Unix(), 10)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Checkpoints 返回已全部写入索引的会话
+func (ix *Index) Checkpoints() (map[string]bool, error) {
+	rows, err := ix.db.Query(`SELECT talker FROM checkpoints`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	done := make(map[string]bool)
+	for rows.Next() {
+		var talker string
+		if err := rows.Scan(&talker); err != nil {
+			return nil, err
+		}
+		done[talker] = true
+	}
+	return done, rows.Err()
+}
+
+// Latest 返回每个会话已写入索引的最新消息时间，用于增量更新
+func (ix *Index) Latest() (map[string]time.Time, error) {
+	rows, err := ix.db.Query(`SELECT talker, MAX(time) FROM docs GROUP BY talker`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	latest := make(map[string]time.Time)
+	for rows.Next() {
+		var talker string
+		var ts int64
+		if err := rows.Scan(&talker, &ts); err != nil {
+			return nil, err
+		}
+		latest[talker] = time.Unix(ts, 0)
+	}
+	return latest, rows.Err()
+}
+
+// Finish 标记索引建立完成，之后才能通过 Open 打开
+func (ix *Index) Finish() error {
+	if _, err := ix.db.Exec(`DELETE FROM checkpoints`); err != nil {
+		return err
+	}
+	if _, err := ix.db.Exec(`DELETE FROM meta WHERE key = 'building'`); err != nil {
+		return err
+	}
+	_, err := ix.db.Exec(`PRAGMA optimize`)
+	return err
+}
+
+// Facet 分面统计的字段
+const (
+	FacetTalker = "talker"
+	FacetYear   = "year"
+	FacetType   = "type"
+)
+
+// facetExprs 分面字段对应的分组表达式及名称表达式，年份按本地时区计算
+var facetExprs = map[string][2]string{
+	FacetTalker: {`talker`, `MAX(talker_name)`},
+	FacetYear:   {`strftime('%Y', time, 'unixepoch', 'localtime')`, `''`},
+	FacetType:   {`CAST(type AS TEXT)`, `''`},
+}
+
+// Bucket 分面统计项
+type Bucket struct {
+	Value string
+	Name  string
+	Count int
+}
+
+// Search 返回同时命中所有查询词的文档，按时间排序，按 Limit、Offset 分页
+// 索引只能保证每个词都出现，查询时会再按原文校验，确保整个查询词连续出现
+func (ix *Index) Search(q Query) ([]Doc, error) {
+	where, args, err := ix.where(q)
+	if err != nil || where == "" {
+		return nil, err
+	}
+	query := `SELECT id, talker, talker_name, seq, time, sender, sender_name, is_self, is_chatroom, type, sub_type, content FROM docs WHERE ` +
+		where + ` ORDER BY time, seq`
+	if q.Limit > 0 || q.Offset > 0 {
+		query += ` LIMIT ? OFFSET ?`
+		limit := q.Limit
+		if limit <= 0 {
+			limit = -1
+		}
+		args = append(args, limit, q.Offset)
+	}
+	rows, err := ix.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var docs []Doc
+	for rows.Next() {
+		var d Doc
+		var ts int64
+		if err := rows.Scan(&d.ID, &d.Talker, &d.TalkerName, &d.Seq, &ts, &d.Sender, &d.SenderName, &d.IsSelf, &d.IsChatRoom, &d.Type, &d.SubType, &d.Content); err != nil {
+			return nil, err
+		}
+		d.Time = time.Unix(ts, 0)
+		docs = append(docs, d)
+	}
+	return docs, rows.Err()
+}
+
+// Facets 在索引中按 field 分组统计命中数量，忽略 Limit、Offset，按取值排序
+func (ix *Index) Facets(q Query, field string) ([]Bucket, error) {
+	expr, ok := facetExprs[field]
+	if !ok {
+		return nil, fmt.Errorf("unknown facet field: %s", field)
+	}
+	where, args, err := ix.where(q)
+	if err != nil || where == "" {
+		return nil, err
+	}
+	rows, err := ix.db.Query(`SELECT `+expr[0]+`, `+expr[1]+`, COUNT(*) FROM docs WHERE `+where+` GROUP BY 1 ORDER BY 1`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var buckets []Bucket
+	for rows.Next() {
+		var b Bucket
+		if err := rows.Scan(&b.Value, &b.Name, &b.Count); err != nil {
+			return nil, err
+		}
+		buckets = append(buckets, b)
+	}
+	return buckets, rows.Err()
+}
+
+// DocsAt 返回会话中指定时间（精确到秒）的文档，不含消息内容
+func (ix *Index) DocsAt(talker string, at time.Time) ([]Doc, error) {
+	rows, err := ix.db.Query(`SELECT id, seq FROM docs WHERE time = ? AND talker = ?`, at.Unix(), talker)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var docs []Doc
+	for rows.Next() {
+		d := Doc{Talker: talker, Time: time.Unix(at.Unix(), 0)}
+		if err := rows.Scan(&d.ID, &d.Seq); err != nil {
+			return nil, err
+		}
+		docs = append(docs, d)
+	}
+	return docs, rows.Err()
+}
+
+// where 生成查询条件，查询词分词后没有可用的词时返回空条件
+// 每组同义词中至少一个需在原文中出现，大小写按 Unicode 规则忽略
+func (ix *Index) where(q Query) (string, []interface{}, error) {
+	words := strings.Fields(q.Text)
+	if len(words) == 0 {
+		return "", nil, fmt.Errorf("empty query")
+	}
+
+	var where, verify []string
+	var args, verifyArgs []interface{}
+	for _, w := range words {
+		group := q.Synonyms.Expand(w)
+
+		var alts, contains []string
+		for _, alt := range group {
+			var terms []string
+			for _, t := range ix.tok.Query(alt) {
+				if t.Prefix {
+					terms = append(terms, `id IN (SELECT doc FROM postings WHERE token >= ? AND token < ?)`)
+					args = append(args, t.Text, t.Text+"\U0010FFFF")
+				} else {
+					terms = append(terms, `id IN (SELECT doc FROM postings WHERE token = ?)`)
+					args = append(args, t.Text)
+				}
+			}
+			if len(terms) > 0 {
+				alts = append(alts, "("+strings.Join(terms, " AND ")+")")
+			}
+			contains = append(contains, `instr(unicode_lower(content), ?) > 0`)
+			verifyArgs = append(verifyArgs, strings.ToLower(alt))
+		}
+		if len(alts) > 0 {
+			where = append(where, "("+strings.Join(alts, " OR ")+")")
+		}
+		verify = append(verify, "("+strings.Join(contains, " OR ")+")")
+	}
+	if len(where) == 0 {
+		return "", nil, nil
+	}
+	where = append(where, verify...)
+	args = append(args, verifyArgs...)
+
+	if len(q.Talkers) > 0 {
+		where = append(where, `talker IN (`+placeholders(len(q.Talkers))+`)`)
+		for _, t := range q.Talkers {
+			args = append(args, t)
+		}
+	}
+	if len(q.ExcludeTalkers) > 0 {
+		where = append(where, `talker NOT IN (`+placeholders(len(q.ExcludeTalkers))+`)`)
+		for _, t := range q.ExcludeTalkers {
+			args = append(args, t)
+		}
+	}
+	if len(q.ExcludeIDs) > 0 {
+		// 编号为整数，直接写入语句，避免数量较多时超出参数上限
+		ids := make([]string, len(q.ExcludeIDs))
+		for i, id := range q.ExcludeIDs {
+			ids[i] = strconv.FormatInt(id, 10)
+		}
+		where = append(where, `id NOT IN (`+strings.Join(ids, ",")+`)`)
+	}
+	if len(q.Senders) > 0 {
+		where = append(where, `sender IN (`+placeholders(len(q.Senders))+`)`)
+		for _, s := range q.Senders {
+			args = append(args, s)
+		}
+	}
+	if len(q.Types) > 0 {
+		where = append(where, `type IN (`+placeholders(len(q.Types))+`)`)
+		for _, t := range q.Types {
+			args = append(args, t)
+		}
+	}
+	if !q.Start.IsZero() {
+		where = append(where, `time >= ?`)
+		args = append(args, q.Start.Unix())
+	}
+	if !q.End.IsZero() {
+		where = append(where, `time <= ?`)
+		args = append(args, q.End.Unix())
+	}
+	return strings.Join(where, " AND "), args, nil
+}
+
+// Info 返回索引概况
+func (ix *Index) Info() (*Info, error) {
+	info := &Info{Path: ix.path, Options: ix.opts}
+	if err := ix.db.QueryRow(`SELECT COUNT(*) FROM docs`).Scan(&info.Docs); err != nil {
+		return nil, err
+	}
+	meta, err := ix.meta()
+	if err != nil {
+		return nil, err
+	}
+	if ts, err := strconv.ParseInt(meta["updated_at"], 10, 64); err == nil {
+		info.UpdatedAt = time.Unix(ts, 0)
+	}
+	return info, nil
+}
+
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?,", n), ",")
+}
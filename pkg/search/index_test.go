@@ -0,0 +1,199 @@
+package search
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestIndexSearch(t *testing.T) {
+	dir := t.TempDir()
+	userDict := filepath.Join(dir, "user.dict")
+	if err := os.WriteFile(userDict, []byte("张总\n项目进度 100\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	docs := []Doc{
+		{Talker: "a", Seq: 1000, Time: time.Unix(1, 0), Content: "张总说项目进度要加快"},
+		{Talker: "a", Seq: 2000, Time: time.Unix(2, 0), Content: "明天开会 Meeting at 10"},
+		{Talker: "b", Seq: 3000, Time: time.Unix(3, 0), Content: "会开完了，进度不错"},
+	}
+
+	for _, tc := range []struct {
+		opts  Options
+		query string
+		want  []int64
+	}{
+		{Options{Tokenizer: TokenizerBigram}, "会", []int64{2000, 3000}},
+		{Options{Tokenizer: TokenizerBigram}, "开会", []int64{2000}},
+		{Options{Tokenizer: TokenizerBigram}, "meet", []int64{2000}},
+		{Options{Tokenizer: TokenizerUnigram}, "进度", []int64{1000, 3000}},
+		{Options{Tokenizer: TokenizerJieba, UserDict: userDict}, "张总", []int64{1000}},
+		{Options{Tokenizer: TokenizerJieba, UserDict: userDict}, "进度", []int64{1000, 3000}},
+	} {
+		ix, err := Create(filepath.Join(dir, tc.opts.Tokenizer+".db"), tc.opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := ix.Add(docs); err != nil {
+			t.Fatal(err)
+		}
+		got, err := ix.Search(Query{Text: tc.query})
+		ix.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != len(tc.want) {
+			t.Fatalf("%s: Search(%q) returned %d docs, want %d", tc.opts.Tokenizer, tc.query, len(got), len(tc.want))
+		}
+		for i := range got {
+			if got[i].Seq != tc.want[i] {
+				t.Fatalf("%s: Search(%q)[%d].Seq = %d, want %d", tc.opts.Tokenizer, tc.query, i, got[i].Seq, tc.want[i])
+			}
+		}
+	}
+}
+
+func TestIndexResume(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "search.db")
+	opts := Options{Tokenizer: TokenizerBigram}
+
+	ix, err := Create(path, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ix.Commit(ix.NewBatch([]Doc{{Talker: "a", Seq: 1000, Content: "你好"}}, "a")); err != nil {
+		t.Fatal(err)
+	}
+	ix.Close()
+
+	if _, err := Open(path); err != ErrNotBuilt {
+		t.Fatalf("Open() on unfinished index err = %v, want ErrNotBuilt", err)
+	}
+	if _, err := Resume(path, Options{Tokenizer: TokenizerUnigram}); err != ErrNotBuilt {
+		t.Fatalf("Resume() with other tokenizer err = %v, want ErrNotBuilt", err)
+	}
+
+	ix, err = Resume(path, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	done, err := ix.Checkpoints()
+	if err != nil || !done["a"] {
+		t.Fatalf("Checkpoints() = %v, %v", done, err)
+	}
+	if err := ix.Finish(); err != nil {
+		t.Fatal(err)
+	}
+	ix.Close()
+
+	ix, err = Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ix.Close()
+	if docs, err := ix.Search(Query{Text: "你好"}); err != nil || len(docs) != 1 {
+		t.Fatalf("Search() = %v, %v", docs, err)
+	}
+}
+
+func TestIndexSynonyms(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "synonyms.txt")
+	if err := os.WriteFile(file, []byte("# 人名\n老板 = 张总 = zhang三\n张总 = 张经理\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	ix, err := Create(filepath.Join(dir, "search.db"), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ix.Close()
+	if err := ix.Add([]Doc{
+		{Talker: "a", Seq: 1000, Content: "张总明天到"},
+		{Talker: "a", Seq: 2000, Content: "问下Zhang三方案"},
+		{Talker: "a", Seq: 3000, Content: "张经理在开会"},
+		{Talker: "a", Seq: 4000, Content: "老师好"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	docs, err := ix.Search(Query{Text: "老板", Synonyms: NewSynonyms(file)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(docs) != 3 {
+		t.Fatalf("Search() returned %d docs, want 3", len(docs))
+	}
+}
+
+func TestIndexLatest(t *testing.T) {
+	ix, err := Create(filepath.Join(t.TempDir(), "search.db"), Options{Tokenizer: TokenizerBigram})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ix.Close()
+	day := time.Date(2024, 2, 10, 0, 0, 0, 0, time.Local)
+	if err := ix.Add([]Doc{
+		{Talker: "a", Seq: 1, Time: day, Content: "你好"},
+		{Talker: "a", Seq: 2, Time: day.Add(time.Hour), Content: "新年好"},
+		{Talker: "b", Seq: 1, Time: day, Content: "早"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	latest, err := ix.Latest()
+	if err != nil || len(latest) != 2 || !latest["a"].Equal(day.Add(time.Hour)) || !latest["b"].Equal(day) {
+		t.Fatalf("Latest() = %v, %v", latest, err)
+	}
+}
+
+func TestIndexFacets(t *testing.T) {
+	ix, err := Create(filepath.Join(t.TempDir(), "search.db"), Options{Tokenizer: TokenizerBigram})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ix.Close()
+	y2023 := time.Date(2023, 6, 1, 0, 0, 0, 0, time.Local)
+	y2024 := time.Date(2024, 6, 1, 0, 0, 0, 0, time.Local)
+	if err := ix.Add([]Doc{
+		{Talker: "a", TalkerName: "张三", Seq: 1, Time: y2023, Type: 1, Content: "Über 项目"},
+		{Talker: "a", TalkerName: "张三", Seq: 2, Time: y2024, Type: 1, Content: "über 项目进度"},
+		{Talker: "b", TalkerName: "李四", Seq: 1, Time: y2024, Type: 49, Content: "项目 über"},
+		{Talker: "b", TalkerName: "李四", Seq: 2, Time: y2024, Type: 1, Content: "无关"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	q := Query{Text: "ÜBER"}
+	docs, err := ix.Search(q)
+	if err != nil || len(docs) != 3 {
+		t.Fatalf("Search() = %v, %v", docs, err)
+	}
+
+	for field, want := range map[string][]Bucket{
+		FacetTalker: {{"a", "张三", 2}, {"b", "李四", 1}},
+		FacetYear:   {{"2023", "", 1}, {"2024", "", 2}},
+		FacetType:   {{"1", "", 2}, {"49", "", 1}},
+	} {
+		got, err := ix.Facets(q, field)
+		if err != nil || !slices.Equal(got, want) {
+			t.Errorf("Facets(%s) = %v, %v, want %v", field, got, err, want)
+		}
+	}
+
+	q.Limit, q.Offset = 1, 1
+	if docs, err := ix.Search(q); err != nil || len(docs) != 1 || docs[0].Talker != "b" || docs[0].Seq != 1 {
+		t.Fatalf("Search() page = %v, %v", docs, err)
+	}
+
+	at, err := ix.DocsAt("a", y2024)
+	if err != nil || len(at) != 1 || at[0].Seq != 2 {
+		t.Fatalf("DocsAt() = %v, %v", at, err)
+	}
+	q = Query{Text: "über", ExcludeTalkers: []string{"b"}, ExcludeIDs: []int64{at[0].ID}}
+	if docs, err := ix.Search(q); err != nil || len(docs) != 1 || docs[0].Seq != 1 {
+		t.Fatalf("Search() with exclusions = %v, %v", docs, err)
+	}
+}
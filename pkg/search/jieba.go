@@ -0,0 +1,155 @@
+package search
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Jieba 基于词典的最大概率分词，词典格式与 jieba 相同：每行 "词 [词频 [词性]]"
+// 未登录的字逐字切分；建立索引时会额外输出多字词的单字及其中在词典内的二字、三字词（类似 jieba 的搜索引擎模式）
+type Jieba struct {
+	freq  map[string]float64 // 词频，值为 0 的项为词的前缀
+	total float64
+	max   float64
+}
+
+// NewJieba 加载词典与用户词典，用户词典中未指定词频的词使用词典中的最大词频，保证能被切出
+func NewJieba(dict, userDict string) (*Jieba, error) {
+	if dict == "" && userDict == "" {
+		return nil, fmt.Errorf("jieba tokenizer requires a dictionary")
+	}
+	j := &Jieba{freq: make(map[string]float64)}
+	if dict != "" {
+		if err := j.load(dict); err != nil {
+			return nil, err
+		}
+	}
+	if userDict != "" {
+		if err := j.load(userDict); err != nil {
+			return nil, err
+		}
+	}
+	return j, nil
+}
+
+func (j *Jieba) load(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		word := strings.ToLower(fields[0])
+		freq := j.max
+		if len(fields) > 1 {
+			if v, err := strconv.ParseFloat(fields[1], 64); err == nil {
+				freq = v
+			}
+		}
+		freq = max(freq, 1)
+		j.add(word, freq)
+	}
+	return scanner.Err()
+}
+
+func (j *Jieba) add(word string, freq float64) {
+	j.total += freq - j.freq[word]
+	j.freq[word] = freq
+	j.max = max(j.max, freq)
+	runes := []rune(word)
+	for i := 1; i < len(runes); i++ {
+		if _, ok := j.freq[string(runes[:i])]; !ok {
+			j.freq[string(runes[:i])] = 0
+		}
+	}
+}
+
+func (j *Jieba) Name() string { return TokenizerJieba }
+
+func (j *Jieba) Tokenize(text string) []string {
+	var tokens []string
+	split(text, func(run []rune) {
+		for _, w := range j.cut(run) {
+			tokens = append(tokens, string(w))
+			if len(w) == 1 {
+				continue
+			}
+			// 多字词额外输出单字及词典中的子词，查询词未被切成同样的词时仍能召回
+			for _, r := range w {
+				tokens = append(tokens, string(r))
+			}
+			for n := 2; n <= 3 && n < len(w); n++ {
+				for i := 0; i+n <= len(w); i++ {
+					if j.freq[string(w[i:i+n])] > 0 {
+						tokens = append(tokens, string(w[i:i+n]))
+					}
+				}
+			}
+		}
+	}, func(w string) {
+		tokens = append(tokens, w)
+	})
+	return tokens
+}
+
+func (j *Jieba) Query(text string) []Term {
+	var list []Term
+	split(text, func(run []rune) {
+		for _, w := range j.cut(run) {
+			list = append(list, Term{Text: string(w)})
+		}
+	}, func(w string) {
+		list = append(list, Term{Text: w, Prefix: true})
+	})
+	return dedup(list)
+}
+
+// cut 构建有向无环图后动态规划求最大概率路径
+func (j *Jieba) cut(run []rune) [][]rune {
+	n := len(run)
+	logTotal := math.Log(max(j.total, 1))
+
+	// route[i] 为从 i 开始的最大对数概率及对应词的结束位置
+	type step struct {
+		prob float64
+		end  int
+	}
+	route := make([]step, n+1)
+	for i := n - 1; i >= 0; i-- {
+		best := step{prob: math.Inf(-1), end: i + 1}
+		for k := i + 1; k <= n; k++ {
+			freq, ok := j.freq[string(run[i:k])]
+			if !ok {
+				break
+			}
+			if freq == 0 && k > i+1 {
+				continue
+			}
+			p := math.Log(max(freq, 1)) - logTotal + route[k].prob
+			if p > best.prob {
+				best = step{prob: p, end: k}
+			}
+		}
+		if math.IsInf(best.prob, -1) {
+			// 未登录字
+			best = step{prob: -logTotal + route[i+1].prob, end: i + 1}
+		}
+		route[i] = best
+	}
+
+	words := make([][]rune, 0, n)
+	for i := 0; i < n; i = route[i].end {
+		words = append(words, run[i:route[i].end])
+	}
+	return words
+}
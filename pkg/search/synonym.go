@@ -0,0 +1,102 @@
+package search
+
+import (
+	"bufio"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Synonyms 同义词表，文件中每行为一组同义词，以 "=" 分隔，如 "老板 = 张总 = zhang三"
+// 空行与 # 开头的注释被忽略；同一个词出现在多行时，这些行合并为一组
+// 文件修改后在下次查询时自动重新加载
+type Synonyms struct {
+	path string
+
+	mu      sync.Mutex
+	modTime time.Time
+	groups  map[string][]string
+}
+
+// NewSynonyms 创建同义词表，文件不存在时不做任何扩展
+func NewSynonyms(path string) *Synonyms {
+	return &Synonyms{path: path}
+}
+
+// Expand 返回 word 及其全部同义词，word 总是第一个
+func (s *Synonyms) Expand(word string) []string {
+	if s == nil || s.path == "" {
+		return []string{word}
+	}
+	groups := s.load()
+	words := []string{word}
+	for _, w := range groups[strings.ToLower(word)] {
+		if !strings.EqualFold(w, word) {
+			words = append(words, w)
+		}
+	}
+	return words
+}
+
+func (s *Synonyms) load() map[string][]string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	info, err := os.Stat(s.path)
+	if err != nil {
+		s.groups, s.modTime = nil, time.Time{}
+		return nil
+	}
+	if s.groups != nil && info.ModTime().Equal(s.modTime) {
+		return s.groups
+	}
+	groups, err := ParseSynonyms(s.path)
+	if err != nil {
+		return s.groups
+	}
+	s.groups, s.modTime = groups, info.ModTime()
+	return s.groups
+}
+
+// ParseSynonyms 解析同义词文件，返回每个词（小写）到所在组全部词的映射
+func ParseSynonyms(path string) (map[string][]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	groups := make(map[string][]string)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		var group []string
+		seen := make(map[string]bool)
+		add := func(w string) {
+			if key := strings.ToLower(w); !seen[key] {
+				seen[key] = true
+				group = append(group, w)
+			}
+		}
+		for _, w := range strings.Split(line, "=") {
+			if w = strings.TrimSpace(w); w != "" {
+				add(w)
+				// 合并已有的组
+				for _, o := range groups[strings.ToLower(w)] {
+					add(o)
+				}
+			}
+		}
+		if len(group) < 2 {
+			continue
+		}
+		for _, w := range group {
+			groups[strings.ToLower(w)] = group
+		}
+	}
+	return groups, scanner.Err()
+}
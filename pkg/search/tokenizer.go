@@ -0,0 +1,160 @@
+package search
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// 分词器名称
+const (
+	TokenizerUnigram = "unigram"
+	TokenizerBigram  = "bigram"
+	TokenizerJieba   = "jieba"
+
+	DefaultTokenizer = TokenizerBigram
+)
+
+// Term 查询词，Prefix 为 true 时按前缀匹配索引中的词
+// 英文与数字单词总是按前缀匹配，便于输入部分单词即可召回
+type Term struct {
+	Text   string
+	Prefix bool
+}
+
+// Tokenizer 索引分词器
+// Tokenize 用于建立索引，Query 用于解析查询，二者需保证查询词一定出现在包含该文本的文档中
+type Tokenizer interface {
+	Name() string
+	Tokenize(text string) []string
+	Query(text string) []Term
+}
+
+// NewTokenizer 按名称创建分词器，dict 与 userDict 仅对 jieba 分词有效
+func NewTokenizer(name, dict, userDict string) (Tokenizer, error) {
+	switch strings.ToLower(name) {
+	case TokenizerUnigram:
+		return unigram{}, nil
+	case "", TokenizerBigram:
+		return bigram{}, nil
+	case TokenizerJieba:
+		return NewJieba(dict, userDict)
+	default:
+		return nil, fmt.Errorf("unsupported tokenizer: %s", name)
+	}
+}
+
+// isCJK 中日韩文字逐字处理，其他字母与数字按单词处理
+func isCJK(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul)
+}
+
+// split 将文本切分为连续的中日韩文字片段与小写单词，标点与空白被丢弃
+func split(text string, cjk func(run []rune), word func(w string)) {
+	var run []rune
+	var sb strings.Builder
+	flush := func() {
+		if len(run) > 0 {
+			cjk(run)
+			run = run[:0]
+		}
+		if sb.Len() > 0 {
+			word(sb.String())
+			sb.Reset()
+		}
+	}
+	for _, r := range text {
+		switch {
+		case isCJK(r):
+			if sb.Len() > 0 {
+				word(sb.String())
+				sb.Reset()
+			}
+			run = append(run, r)
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			if len(run) > 0 {
+				cjk(run)
+				run = run[:0]
+			}
+			sb.WriteRune(unicode.ToLower(r))
+		default:
+			flush()
+		}
+	}
+	flush()
+}
+
+// unigram 中日韩文字按单字索引，召回率最高但索引较大、精度较低
+type unigram struct{}
+
+func (unigram) Name() string { return TokenizerUnigram }
+
+func (unigram) Tokenize(text string) []string {
+	var tokens []string
+	split(text, func(run []rune) {
+		for _, r := range run {
+			tokens = append(tokens, string(r))
+		}
+	}, func(w string) {
+		tokens = append(tokens, w)
+	})
+	return tokens
+}
+
+func (unigram) Query(text string) []Term {
+	var list []Term
+	split(text, func(run []rune) {
+		for _, r := range run {
+			list = append(list, Term{Text: string(r)})
+		}
+	}, func(w string) {
+		list = append(list, Term{Text: w, Prefix: true})
+	})
+	return dedup(list)
+}
+
+// bigram 中日韩文字按相邻两字索引，每段末字额外按单字索引，使单字查询可通过前缀匹配召回
+type bigram struct{}
+
+func (bigram) Name() string { return TokenizerBigram }
+
+func (bigram) Tokenize(text string) []string {
+	var tokens []string
+	split(text, func(run []rune) {
+		for i := 0; i+1 < len(run); i++ {
+			tokens = append(tokens, string(run[i:i+2]))
+		}
+		tokens = append(tokens, string(run[len(run)-1]))
+	}, func(w string) {
+		tokens = append(tokens, w)
+	})
+	return tokens
+}
+
+func (bigram) Query(text string) []Term {
+	var list []Term
+	split(text, func(run []rune) {
+		if len(run) == 1 {
+			list = append(list, Term{Text: string(run), Prefix: true})
+			return
+		}
+		for i := 0; i+1 < len(run); i++ {
+			list = append(list, Term{Text: string(run[i : i+2])})
+		}
+	}, func(w string) {
+		list = append(list, Term{Text: w, Prefix: true})
+	})
+	return dedup(list)
+}
+
+func dedup(list []Term) []Term {
+	seen := make(map[Term]bool, len(list))
+	out := list[:0]
+	for _, t := range list {
+		if !seen[t] {
+			seen[t] = true
+			out = append(out, t)
+		}
+	}
+	return out
+}
@@ -0,0 +1,39 @@
+package throttle
+
+import (
+	"os"
+	"strconv"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	niceLow = 10
+
+	ioprioClassIdle  = 3
+	ioprioClassShift = 13
+	ioprioWhoProcess = 1
+)
+
+// setLowPriority Linux 的 nice 值按线程生效，需要逐个设置已存在的线程，新线程会继承创建者的 nice 值
+func setLowPriority() error {
+	tids := []int{0}
+	if entries, err := os.ReadDir("/proc/self/task"); err == nil {
+		tids = tids[:0]
+		for _, e := range entries {
+			if tid, err := strconv.Atoi(e.Name()); err == nil {
+				tids = append(tids, tid)
+			}
+		}
+	}
+
+	var lastErr error
+	for _, tid := range tids {
+		if err := unix.Setpriority(unix.PRIO_PROCESS, tid, niceLow); err != nil {
+			lastErr = err
+		}
+		// 磁盘 IO 使用 idle 调度类，仅在磁盘空闲时执行
+		unix.Syscall(unix.SYS_IOPRIO_SET, ioprioWhoProcess, uintptr(tid), ioprioClassIdle<<ioprioClassShift)
+	}
+	return lastErr
+}
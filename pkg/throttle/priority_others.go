@@ -0,0 +1,13 @@
+//go:build !windows && !linux
+
+package throttle
+
+import (
+	"golang.org/x/sys/unix"
+)
+
+const niceLow = 10
+
+func setLowPriority() error {
+	return unix.Setpriority(unix.PRIO_PROCESS, 0, niceLow)
+}
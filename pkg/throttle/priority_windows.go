@@ -0,0 +1,16 @@
+package throttle
+
+import (
+	"golang.org/x/sys/windows"
+)
+
+// PROCESS_MODE_BACKGROUND_BEGIN 同时降低 CPU、磁盘 IO 与内存页优先级
+const processModeBackgroundBegin = 0x00100000
+
+func setLowPriority() error {
+	handle := windows.CurrentProcess()
+	if err := windows.SetPriorityClass(handle, processModeBackgroundBegin); err == nil {
+		return nil
+	}
+	return windows.SetPriorityClass(handle, windows.BELOW_NORMAL_PRIORITY_CLASS)
+}
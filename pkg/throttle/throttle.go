@@ -0,0 +1,154 @@
+// Package throttle 控制后台任务（解密、索引、导出）占用的 CPU 与磁盘资源，
+// 避免常驻运行时影响同一台电脑上的游戏或工作
+package throttle
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	PriorityNormal = "normal"
+	PriorityLow    = "low"
+)
+
+var workers atomic.Int32
+
+// Workers 返回后台任务可使用的并发数，默认为 CPU 核数
+func Workers() int {
+	if n := workers.Load(); n > 0 {
+		return int(n)
+	}
+	return runtime.NumCPU()
+}
+
+// SetWorkers 设置后台任务的并发数，n <= 0 时恢复默认值
+func SetWorkers(n int) {
+	if n < 0 {
+		n = 0
+	}
+	workers.Store(int32(n))
+}
+
+// SetPriority 设置当前进程的调度优先级
+// low 会同时降低 CPU 与磁盘 IO 优先级（视平台支持情况）
+func SetPriority(priority string) error {
+	switch priority {
+	case "", PriorityNormal:
+		return nil
+	case PriorityLow:
+		return setLowPriority()
+	default:
+		return fmt.Errorf("invalid priority: %s", priority)
+	}
+}
+
+// Default 全局 IO 限速器，默认不限速，通过 --io-limit 设置
+var Default = NewLimiter(0)
+
+// Limiter 令牌桶限速器，rate 为每秒字节数，0 表示不限速
+type Limiter struct {
+	mu     sync.Mutex
+	rate   int64
+	tokens float64
+	last   time.Time
+}
+
+func NewLimiter(rate int64) *Limiter {
+	return &Limiter{rate: rate, last: time.Now()}
+}
+
+func (l *Limiter) SetRate(rate int64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.rate = rate
+	l.tokens = 0
+	l.last = time.Now()
+}
+
+func (l *Limiter) Rate() int64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.rate
+}
+
+// WaitN 等待 n 字节的配额，允许短时突发不超过 1 秒的配额
+func (l *Limiter) WaitN(ctx context.Context, n int) error {
+	l.mu.Lock()
+	if l.rate <= 0 {
+		l.mu.Unlock()
+		return nil
+	}
+	now := time.Now()
+	l.tokens += now.Sub(l.last).Seconds() * float64(l.rate)
+	if l.tokens > float64(l.rate) {
+		l.tokens = float64(l.rate)
+	}
+	l.last = now
+	l.tokens -= float64(n)
+	wait := time.Duration(0)
+	if l.tokens < 0 {
+		wait = time.Duration(-l.tokens / float64(l.rate) * float64(time.Second))
+	}
+	l.mu.Unlock()
+
+	if wait == 0 {
+		return nil
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Writer 返回受全局限速器约束的 io.Writer，未限速时直接返回 w
+func Writer(w io.Writer) io.Writer {
+	if Default.Rate() <= 0 {
+		return w
+	}
+	return &writer{w: w, l: Default}
+}
+
+// Reader 返回受全局限速器约束的 io.Reader，未限速时直接返回 r
+func Reader(r io.Reader) io.Reader {
+	if Default.Rate() <= 0 {
+		return r
+	}
+	return &reader{r: r, l: Default}
+}
+
+type writer struct {
+	w io.Writer
+	l *Limiter
+}
+
+func (w *writer) Write(p []byte) (int, error) {
+	if err := w.l.WaitN(context.Background(), len(p)); err != nil {
+		return 0, err
+	}
+	return w.w.Write(p)
+}
+
+type reader struct {
+	r io.Reader
+	l *Limiter
+}
+
+func (r *reader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	if n > 0 {
+		if werr := r.l.WaitN(context.Background(), n); werr != nil {
+			return n, werr
+		}
+	}
+	return n, err
+}
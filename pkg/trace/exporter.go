@@ -0,0 +1,212 @@
+package trace
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aspnmy/chatlog/pkg/version"
+)
+
+const ServiceName = "chatlog"
+
+// Exporter 输出一批已结束的 span
+type Exporter interface {
+	Export(spans []*Span) error
+	Close() error
+}
+
+// NewExporter 根据 target 创建 Exporter
+func NewExporter(target string) (Exporter, error) {
+	if strings.HasPrefix(target, "http://") || strings.HasPrefix(target, "https://") {
+		return NewOTLPExporter(target), nil
+	}
+	return NewFileExporter(target)
+}
+
+// FileExporter 每次导出写入一行 OTLP/JSON，可被 OpenTelemetry Collector 的 otlpjsonfile receiver 读取
+type FileExporter struct {
+	f *os.File
+}
+
+func NewFileExporter(path string) (*FileExporter, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &FileExporter{f: f}, nil
+}
+
+func (e *FileExporter) Export(spans []*Span) error {
+	b, err := json.Marshal(encode(spans))
+	if err != nil {
+		return err
+	}
+	_, err = e.f.Write(append(b, '\n'))
+	return err
+}
+
+func (e *FileExporter) Close() error {
+	return e.f.Close()
+}
+
+// OTLPExporter 通过 OTLP/HTTP（JSON 编码）发送到 Collector，如 http://localhost:4318
+type OTLPExporter struct {
+	url    string
+	client *http.Client
+}
+
+func NewOTLPExporter(endpoint string) *OTLPExporter {
+	url := strings.TrimSuffix(endpoint, "/")
+	if !strings.HasSuffix(url, "/v1/traces") {
+		url += "/v1/traces"
+	}
+	return &OTLPExporter{
+		url:    url,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (e *OTLPExporter) Export(spans []*Span) error {
+	b, err := json.Marshal(encode(spans))
+	if err != nil {
+		return err
+	}
+	resp, err := e.client.Post(e.url, "application/json", bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("otlp endpoint returned %s", resp.Status)
+	}
+	return nil
+}
+
+func (e *OTLPExporter) Close() error {
+	return nil
+}
+
+// OTLP/JSON 结构，仅包含 chatlog 用到的字段
+type (
+	otlpTraces struct {
+		ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+	}
+	otlpResourceSpans struct {
+		Resource   otlpResource     `json:"resource"`
+		ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+	}
+	otlpResource struct {
+		Attributes []otlpKeyValue `json:"attributes"`
+	}
+	otlpScopeSpans struct {
+		Scope otlpScope  `json:"scope"`
+		Spans []otlpSpan `json:"spans"`
+	}
+	otlpScope struct {
+		Name    string `json:"name"`
+		Version string `json:"version,omitempty"`
+	}
+	otlpSpan struct {
+		TraceID           string         `json:"traceId"`
+		SpanID            string         `json:"spanId"`
+		ParentSpanID      string         `json:"parentSpanId,omitempty"`
+		Name              string         `json:"name"`
+		Kind              int            `json:"kind"`
+		StartTimeUnixNano string         `json:"startTimeUnixNano"`
+		EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+		Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+		Status            *otlpStatus    `json:"status,omitempty"`
+	}
+	otlpStatus struct {
+		Code    int    `json:"code"`
+		Message string `json:"message,omitempty"`
+	}
+	otlpKeyValue struct {
+		Key   string         `json:"key"`
+		Value map[string]any `json:"value"`
+	}
+)
+
+const (
+	spanKindInternal = 1
+	statusCodeError  = 2
+)
+
+func encode(spans []*Span) *otlpTraces {
+	out := make([]otlpSpan, 0, len(spans))
+	for _, s := range spans {
+		s.mu.Lock()
+		span := otlpSpan{
+			TraceID:           hex.EncodeToString(s.traceID[:]),
+			SpanID:            hex.EncodeToString(s.spanID[:]),
+			Name:              s.name,
+			Kind:              spanKindInternal,
+			StartTimeUnixNano: strconv.FormatInt(s.start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(s.end.UnixNano(), 10),
+			Attributes:        attributes(s.attrs),
+		}
+		if s.parentID != [8]byte{} {
+			span.ParentSpanID = hex.EncodeToString(s.parentID[:])
+		}
+		if s.err != nil {
+			span.Status = &otlpStatus{Code: statusCodeError, Message: s.err.Error()}
+		}
+		s.mu.Unlock()
+		out = append(out, span)
+	}
+
+	return &otlpTraces{
+		ResourceSpans: []otlpResourceSpans{{
+			Resource: otlpResource{Attributes: attributes(map[string]any{
+				"service.name":    ServiceName,
+				"service.version": version.Version,
+			})},
+			ScopeSpans: []otlpScopeSpans{{
+				Scope: otlpScope{Name: ServiceName, Version: version.Version},
+				Spans: out,
+			}},
+		}},
+	}
+}
+
+func attributes(attrs map[string]any) []otlpKeyValue {
+	if len(attrs) == 0 {
+		return nil
+	}
+	keys := make([]string, 0, len(attrs))
+	for k := range attrs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	kvs := make([]otlpKeyValue, 0, len(keys))
+	for _, k := range keys {
+		var v map[string]any
+		switch val := attrs[k].(type) {
+		case string:
+			v = map[string]any{"stringValue": val}
+		case bool:
+			v = map[string]any{"boolValue": val}
+		case int:
+			v = map[string]any{"intValue": strconv.Itoa(val)}
+		case int64:
+			v = map[string]any{"intValue": strconv.FormatInt(val, 10)}
+		case float64:
+			v = map[string]any{"doubleValue": val}
+		case time.Duration:
+			v = map[string]any{"intValue": strconv.FormatInt(val.Milliseconds(), 10)}
+		default:
+			v = map[string]any{"stringValue": fmt.Sprint(val)}
+		}
+		kvs = append(kvs, otlpKeyValue{Key: k, Value: v})
+	}
+	return kvs
+}
@@ -0,0 +1,205 @@
+// Package trace 记录主要流程（获取密钥、解密、导出、HTTP 请求）的耗时分布
+// 默认关闭，开启后以 OTLP/JSON 格式输出到文件或 OpenTelemetry Collector，
+// 方便用户在反馈"解密很慢"等问题时附上 trace，定位耗时所在
+package trace
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Span 一次计时区间，未开启 trace 时为 nil，所有方法均可安全地在 nil 上调用
+type Span struct {
+	tracer   *Tracer
+	traceID  [16]byte
+	spanID   [8]byte
+	parentID [8]byte
+	name     string
+	start    time.Time
+	end      time.Time
+	attrs    map[string]any
+	err      error
+	mu       sync.Mutex
+}
+
+type spanKey struct{}
+
+var (
+	mu      sync.RWMutex
+	current *Tracer
+)
+
+// Tracer 收集结束的 span 并批量交给 Exporter 输出
+type Tracer struct {
+	exporter Exporter
+	mu       sync.Mutex
+	spans    []*Span
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+// Init 根据 target 开启 trace：http(s):// 开头时发送到 OTLP/HTTP 接口，否则写入本地文件
+// target 为空时关闭 trace
+func Init(target string) error {
+	if target == "" {
+		return Shutdown()
+	}
+	exporter, err := NewExporter(target)
+	if err != nil {
+		return err
+	}
+	t := &Tracer{
+		exporter: exporter,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go t.loop()
+
+	mu.Lock()
+	old := current
+	current = t
+	mu.Unlock()
+	if old != nil {
+		old.shutdown()
+	}
+	return nil
+}
+
+// Shutdown 输出剩余的 span 并关闭 trace
+func Shutdown() error {
+	mu.Lock()
+	t := current
+	current = nil
+	mu.Unlock()
+	if t == nil {
+		return nil
+	}
+	return t.shutdown()
+}
+
+// Enabled 返回是否已开启 trace
+func Enabled() bool {
+	mu.RLock()
+	defer mu.RUnlock()
+	return current != nil
+}
+
+// Start 开始一个 span，ctx 中已有 span 时作为其子 span
+func Start(ctx context.Context, name string) (context.Context, *Span) {
+	mu.RLock()
+	t := current
+	mu.RUnlock()
+	if t == nil {
+		return ctx, nil
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	s := &Span{
+		tracer: t,
+		name:   name,
+		start:  time.Now(),
+	}
+	rand.Read(s.spanID[:])
+	if parent, ok := ctx.Value(spanKey{}).(*Span); ok && parent != nil {
+		s.traceID = parent.traceID
+		s.parentID = parent.spanID
+	} else {
+		rand.Read(s.traceID[:])
+	}
+	return context.WithValue(ctx, spanKey{}, s), s
+}
+
+// SetAttr 设置 span 属性，值支持字符串、整数、浮点数与布尔值，其他类型按 fmt 格式化
+func (s *Span) SetAttr(key string, value any) *Span {
+	if s == nil {
+		return s
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.attrs == nil {
+		s.attrs = make(map[string]any)
+	}
+	s.attrs[key] = value
+	return s
+}
+
+// SetError 记录错误，err 为 nil 时忽略
+func (s *Span) SetError(err error) *Span {
+	if s == nil || err == nil {
+		return s
+	}
+	s.mu.Lock()
+	s.err = err
+	s.mu.Unlock()
+	return s
+}
+
+// End 结束 span，重复调用无效
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if !s.end.IsZero() {
+		s.mu.Unlock()
+		return
+	}
+	s.end = time.Now()
+	s.mu.Unlock()
+
+	s.tracer.mu.Lock()
+	s.tracer.spans = append(s.tracer.spans, s)
+	s.tracer.mu.Unlock()
+}
+
+// TraceID 返回十六进制的 trace id，用于在日志中关联
+func (s *Span) TraceID() string {
+	if s == nil {
+		return ""
+	}
+	return hex.EncodeToString(s.traceID[:])
+}
+
+func (t *Tracer) loop() {
+	defer close(t.done)
+	ticker := time.NewTicker(5 * time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			t.flush()
+		case <-t.stop:
+			return
+		}
+	}
+}
+
+func (t *Tracer) flush() error {
+	t.mu.Lock()
+	spans := t.spans
+	t.spans = nil
+	t.mu.Unlock()
+	if len(spans) == 0 {
+		return nil
+	}
+	if err := t.exporter.Export(spans); err != nil {
+		return fmt.Errorf("export %d spans failed: %w", len(spans), err)
+	}
+	return nil
+}
+
+func (t *Tracer) shutdown() error {
+	close(t.stop)
+	<-t.done
+	err := t.flush()
+	if cerr := t.exporter.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
@@ -0,0 +1,61 @@
+package translate
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	DeepLAPI     = "https://api.deepl.com"
+	DeepLFreeAPI = "https://api-free.deepl.com"
+)
+
+// DeepL 通过 DeepL API 翻译
+type DeepL struct {
+	api    string
+	apiKey string
+	client *http.Client
+}
+
+// NewDeepL 创建 DeepL 翻译，api 为空时按密钥类型选择：免费版密钥以 :fx 结尾，使用 DeepLFreeAPI
+func NewDeepL(api, apiKey string) *DeepL {
+	if api == "" {
+		api = DeepLAPI
+		if strings.HasSuffix(apiKey, ":fx") {
+			api = DeepLFreeAPI
+		}
+	}
+	return &DeepL{
+		api:    strings.TrimSuffix(api, "/"),
+		apiKey: apiKey,
+		client: &http.Client{Timeout: time.Minute},
+	}
+}
+
+func (d *DeepL) String() string {
+	return "deepl"
+}
+
+func (d *DeepL) Translate(ctx context.Context, texts []string, lang string) ([]string, error) {
+	header := http.Header{}
+	header.Set("Authorization", "DeepL-Auth-Key "+d.apiKey)
+	var resp struct {
+		Translations []struct {
+			Text string `json:"text"`
+		} `json:"translations"`
+	}
+	err := postJSON(ctx, d.client, d.api+"/v2/translate", header, map[string]any{
+		"text":        texts,
+		"target_lang": strings.ToUpper(lang),
+	}, &resp)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]string, len(resp.Translations))
+	for i, t := range resp.Translations {
+		result[i] = t.Text
+	}
+	return result, nil
+}
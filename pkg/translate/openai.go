@@ -0,0 +1,96 @@
+package translate
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	OpenAIAPI   = "https://api.openai.com/v1"
+	OpenAIModel = "gpt-4o-mini"
+)
+
+// OpenAI 通过 Chat Completions 接口翻译，也可用于 DeepSeek、通义千问、Ollama 等兼容该接口的服务
+type OpenAI struct {
+	api    string
+	apiKey string
+	model  string
+	client *http.Client
+}
+
+// NewOpenAI 创建 OpenAI 翻译，api 为空时使用 OpenAIAPI，model 为空时使用 OpenAIModel
+func NewOpenAI(api, apiKey, model string) *OpenAI {
+	if api == "" {
+		api = OpenAIAPI
+	}
+	if model == "" {
+		model = OpenAIModel
+	}
+	return &OpenAI{
+		api:    strings.TrimSuffix(api, "/"),
+		apiKey: apiKey,
+		model:  model,
+		client: &http.Client{Timeout: 2 * time.Minute},
+	}
+}
+
+func (o *OpenAI) String() string {
+	return "openai " + o.model
+}
+
+// Translate 将全部文本以 JSON 数组发送，要求模型返回同样长度的 JSON 数组
+func (o *OpenAI) Translate(ctx context.Context, texts []string, lang string) ([]string, error) {
+	in, err := json.Marshal(texts)
+	if err != nil {
+		return nil, err
+	}
+	prompt := fmt.Sprintf("You translate chat messages. Translate every string in the JSON array from the user into the language with code %q. "+
+		"Keep emoji, names, links and [bracketed] placeholders unchanged. "+
+		"Reply with only a JSON array of strings of the same length and order, without any explanation.", lang)
+
+	header := http.Header{}
+	if o.apiKey != "" {
+		header.Set("Authorization", "Bearer "+o.apiKey)
+	}
+	var resp struct {
+		Choices []struct {
+			Message struct {
+				Content string `json:"content"`
+			} `json:"message"`
+		} `json:"choices"`
+	}
+	err = postJSON(ctx, o.client, o.api+"/chat/completions", header, map[string]any{
+		"model":       o.model,
+		"temperature": 0,
+		"messages": []map[string]string{
+			{"role": "system", "content": prompt},
+			{"role": "user", "content": string(in)},
+		},
+	}, &resp)
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Choices) == 0 {
+		return nil, fmt.Errorf("%s returned no choices", o)
+	}
+	return parseArray(resp.Choices[0].Message.Content)
+}
+
+// parseArray 解析模型返回的 JSON 数组，去掉模型可能添加的 Markdown 代码块标记
+func parseArray(content string) ([]string, error) {
+	content = strings.TrimSpace(content)
+	if strings.HasPrefix(content, "```") {
+		content = strings.TrimPrefix(content, "```json")
+		content = strings.TrimPrefix(content, "```")
+		content = strings.TrimSuffix(strings.TrimSpace(content), "```")
+	}
+	var result []string
+	if err := json.Unmarshal([]byte(content), &result); err != nil {
+		return nil, fmt.Errorf("invalid translation response: %w", err)
+	}
+	return result, nil
+}
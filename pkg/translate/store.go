@@ -0,0 +1,116 @@
+package translate
+
+import (
+	"crypto/sha1"
+	"database/sql"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+const storeSchema = `
+CREATE TABLE IF NOT EXISTS translations (
+	hash     TEXT NOT NULL,
+	lang     TEXT NOT NULL,
+	text     TEXT NOT NULL,
+	provider TEXT NOT NULL,
+	created  INTEGER NOT NULL,
+	PRIMARY KEY (hash, lang)
+) WITHOUT ROWID;
+`
+
+// storeQueryBatch 每次查询的原文数，不超过 SQLite 的参数数量限制
+const storeQueryBatch = 500
+
+// Store 保存译文的 SQLite 文件，以原文的哈希与目标语言为键
+type Store struct {
+	db *sql.DB
+}
+
+// OpenStore 打开 path 中的译文，文件不存在时创建
+func OpenStore(path string) (*Store, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	db, err := sql.Open("sqlite3", "file:"+path+"?_journal_mode=WAL&_busy_timeout=5000")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := db.Exec(storeSchema); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &Store{db: db}, nil
+}
+
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+func hashText(text string) string {
+	sum := sha1.Sum([]byte(text))
+	return hex.EncodeToString(sum[:])
+}
+
+// Get 返回已保存的 lang 语言译文，以原文为键
+func (s *Store) Get(lang string, texts []string) (map[string]string, error) {
+	byHash := make(map[string]string, len(texts))
+	for _, text := range texts {
+		byHash[hashText(text)] = text
+	}
+	hashes := make([]any, 0, len(byHash))
+	for hash := range byHash {
+		hashes = append(hashes, hash)
+	}
+
+	result := make(map[string]string)
+	for len(hashes) > 0 {
+		batch := hashes[:min(len(hashes), storeQueryBatch)]
+		hashes = hashes[len(batch):]
+		query := "SELECT hash, text FROM translations WHERE lang = ? AND hash IN (?" + strings.Repeat(",?", len(batch)-1) + ")"
+		rows, err := s.db.Query(query, append([]any{lang}, batch...)...)
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			var hash, text string
+			if err := rows.Scan(&hash, &text); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			result[byHash[hash]] = text
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// Put 保存 lang 语言的译文，pairs 以原文为键，provider 记录译文来源
+func (s *Store) Put(lang, provider string, pairs map[string]string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	stmt, err := tx.Prepare("INSERT OR REPLACE INTO translations (hash, lang, text, provider, created) VALUES (?, ?, ?, ?, ?)")
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	defer stmt.Close()
+	now := time.Now().Unix()
+	for text, translation := range pairs {
+		if _, err := stmt.Exec(hashText(text), lang, translation, provider, now); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,137 @@
+// Package translate 调用在线翻译服务翻译消息文本，并将译文按原文缓存在本地 SQLite 文件中，
+// 同一段原文只翻译一次，重新解密或合并数据后仍可使用
+package translate
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// BatchSize 每次请求翻译的文本数
+	BatchSize = 20
+
+	// maxRetries 被限流或服务端出错时的最大重试次数
+	maxRetries = 3
+)
+
+// Translator 翻译服务
+type Translator interface {
+	// Translate 将 texts 翻译为 lang 语言（如 en、zh、ja），返回的译文与 texts 一一对应
+	Translate(ctx context.Context, texts []string, lang string) ([]string, error)
+
+	// String 返回用于日志展示与记录译文来源的服务描述，不包含密钥等敏感信息
+	String() string
+}
+
+// 翻译服务
+const (
+	ProviderOpenAI = "openai"
+	ProviderDeepL  = "deepl"
+)
+
+// New 创建翻译服务，provider 为 openai（兼容 OpenAI Chat Completions 接口的服务）或 deepl
+// api 为空时使用服务的默认地址，model 仅 openai 使用
+func New(provider, api, apiKey, model string) (Translator, error) {
+	switch strings.ToLower(provider) {
+	case ProviderOpenAI:
+		return NewOpenAI(api, apiKey, model), nil
+	case ProviderDeepL:
+		return NewDeepL(api, apiKey), nil
+	default:
+		return nil, fmt.Errorf("unsupported translation provider %q, use %s or %s", provider, ProviderOpenAI, ProviderDeepL)
+	}
+}
+
+// Fill 返回 texts 的 lang 语言译文，以原文为键
+// 先从 store 中查找，其余的由 tr 分批翻译并保存；tr 为空时只返回已保存的译文
+func Fill(ctx context.Context, store *Store, tr Translator, lang string, texts []string) (map[string]string, error) {
+	result, err := store.Get(lang, texts)
+	if err != nil {
+		return nil, err
+	}
+	if tr == nil {
+		return result, nil
+	}
+
+	var missing []string
+	seen := make(map[string]bool)
+	for _, text := range texts {
+		if _, ok := result[text]; ok || seen[text] {
+			continue
+		}
+		seen[text] = true
+		missing = append(missing, text)
+	}
+
+	for len(missing) > 0 {
+		batch := missing[:min(len(missing), BatchSize)]
+		missing = missing[len(batch):]
+		translated, err := tr.Translate(ctx, batch, lang)
+		if err != nil {
+			return result, err
+		}
+		if len(translated) != len(batch) {
+			return result, fmt.Errorf("%s returned %d translations for %d texts", tr, len(translated), len(batch))
+		}
+		pairs := make(map[string]string, len(batch))
+		for i, text := range batch {
+			pairs[text] = translated[i]
+			result[text] = translated[i]
+		}
+		if err := store.Put(lang, tr.String(), pairs); err != nil {
+			return result, err
+		}
+	}
+	return result, nil
+}
+
+// postJSON 发送 JSON 请求并将响应解析到 out，被限流（429）或服务端出错时按 Retry-After 重试
+func postJSON(ctx context.Context, client *http.Client, url string, header http.Header, in, out any) error {
+	body, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header = header.Clone()
+		req.Header.Set("Content-Type", "application/json; charset=utf-8")
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		b, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return err
+		}
+
+		retry := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+		if retry && attempt < maxRetries {
+			wait := time.Duration(attempt+1) * time.Second
+			if s, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && s >= 0 {
+				wait = time.Duration(s) * time.Second
+			}
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(wait):
+			}
+			continue
+		}
+		if resp.StatusCode < 200 || resp.StatusCode > 299 {
+			return fmt.Errorf("POST %s: %s: %s", req.URL.Path, resp.Status, bytes.TrimSpace(b))
+		}
+		return json.Unmarshal(b, out)
+	}
+}
@@ -0,0 +1,99 @@
+package translate
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type upperTranslator struct {
+	calls int
+	texts int
+}
+
+func (u *upperTranslator) Translate(ctx context.Context, texts []string, lang string) ([]string, error) {
+	u.calls++
+	u.texts += len(texts)
+	result := make([]string, len(texts))
+	for i, text := range texts {
+		result[i] = lang + ":" + strings.ToUpper(text)
+	}
+	return result, nil
+}
+
+func (u *upperTranslator) String() string {
+	return "upper"
+}
+
+func TestFill(t *testing.T) {
+	store, err := OpenStore(filepath.Join(t.TempDir(), "translations.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	var texts []string
+	for i := range BatchSize + 5 {
+		texts = append(texts, fmt.Sprint("msg ", i))
+	}
+	texts = append(texts, "msg 0") // 重复的原文只翻译一次
+
+	tr := &upperTranslator{}
+	got, err := Fill(context.Background(), store, tr, "en", texts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tr.calls != 2 || tr.texts != BatchSize+5 {
+		t.Errorf("calls = %d, texts = %d", tr.calls, tr.texts)
+	}
+	if got["msg 3"] != "en:MSG 3" {
+		t.Errorf("translation = %q", got["msg 3"])
+	}
+
+	// 已保存的译文不再翻译，不同语言分别保存
+	tr = &upperTranslator{}
+	if got, _ := Fill(context.Background(), store, tr, "en", texts[:3]); tr.calls != 0 || len(got) != 3 {
+		t.Errorf("cached: calls = %d, got %d", tr.calls, len(got))
+	}
+	if got, _ := Fill(context.Background(), store, nil, "ja", texts); len(got) != 0 {
+		t.Errorf("ja without translator got %d", len(got))
+	}
+}
+
+func TestOpenAI(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/chat/completions" || r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		var body struct {
+			Messages []struct {
+				Content string `json:"content"`
+			} `json:"messages"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		var texts []string
+		json.Unmarshal([]byte(body.Messages[1].Content), &texts)
+		for i := range texts {
+			texts[i] = "en " + texts[i]
+		}
+		b, _ := json.Marshal(texts)
+		// 模型有时会用代码块包裹结果
+		content, _ := json.Marshal("```json\n" + string(b) + "\n```")
+		fmt.Fprintf(w, `{"choices":[{"message":{"content":%s}}]}`, content)
+	}))
+	defer srv.Close()
+
+	got, err := NewOpenAI(srv.URL, "secret", "").Translate(context.Background(), []string{"你好", "晚安"}, "en")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(got, "|") != "en 你好|en 晚安" {
+		t.Errorf("got %v", got)
+	}
+}
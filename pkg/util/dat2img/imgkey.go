@@ -3,59 +3,101 @@ package dat2img
 import (
 	"bytes"
 	"crypto/aes"
+	"io"
+	"io/fs"
 	"os"
 	"path/filepath"
 	"strings"
 )
 
+// DefaultImgKeySamples NewImgKeyValidator 采样的图片文件数
+const DefaultImgKeySamples = 3
+
+// imgKeySampleOffset V4Format2 文件中第一个 AES 加密块的偏移
+const imgKeySampleOffset = 15
+
+// imageFormats 解密后的图片应以这些格式的文件头开始
+var imageFormats = []*Format{&JPG, &PNG, &GIF, &TIFF, &BMP, &WXGF}
+
 type AesKeyValidator struct {
 	Path          string
-	EncryptedData []byte
+	EncryptedData []byte // 第一个样本的第一个加密块
+
+	// Samples 各样本的第一个加密块，取自不同目录的 .dat 文件
+	Samples [][]byte
 }
 
+// NewImgKeyValidator 在数据目录中采样 DefaultImgKeySamples 个图片文件，用于验证图片密钥
 func NewImgKeyValidator(path string) *AesKeyValidator {
+	return NewImgKeyValidatorWithSamples(path, DefaultImgKeySamples)
+}
+
+// NewImgKeyValidatorWithSamples 在数据目录中采样最多 n 个使用图片密钥加密的 .dat 文件（不包括缩略图），
+// 每个目录最多取一个，候选密钥需要将所有样本都解密为已知的图片格式，减少误判
+func NewImgKeyValidatorWithSamples(path string, n int) *AesKeyValidator {
 	validator := &AesKeyValidator{
 		Path: path,
 	}
+	n = max(n, 1)
+	sampled := make(map[string]bool)
 
-	// Walk the directory to find *.dat files (excluding *_t.dat files)
-	filepath.Walk(path, func(filePath string, info os.FileInfo, err error) error {
+	filepath.WalkDir(path, func(filePath string, d fs.DirEntry, err error) error {
 		if err != nil {
 			return err
 		}
 
 		// Skip directories
-		if info.IsDir() {
+		if d.IsDir() {
 			return nil
 		}
 
 		// Only process *.dat files but exclude *_t.dat files
-		if !strings.HasSuffix(info.Name(), ".dat") || strings.HasSuffix(info.Name(), "_t.dat") {
+		if !strings.HasSuffix(d.Name(), ".dat") || strings.HasSuffix(d.Name(), "_t.dat") {
 			return nil
 		}
-
-		// Read file content
-		data, err := os.ReadFile(filePath)
-		if err != nil {
+		dir := filepath.Dir(filePath)
+		if sampled[dir] {
 			return nil
 		}
 
-		// Check if header matches V4Format2.Header
-		// Get aes.BlockSize (16) bytes starting from position 15
-		if len(data) >= 15+aes.BlockSize && bytes.Equal(data[:4], V4Format2.Header) {
-			validator.EncryptedData = make([]byte, aes.BlockSize)
-			copy(validator.EncryptedData, data[15:15+aes.BlockSize])
+		block, ok := readImgKeySample(filePath)
+		if !ok {
+			return nil
+		}
+		sampled[dir] = true
+		validator.Samples = append(validator.Samples, block)
+		if validator.EncryptedData == nil {
+			validator.EncryptedData = block
+		}
+		if len(validator.Samples) >= n {
 			return filepath.SkipAll // Found what we need, stop walking
 		}
-
 		return nil
 	})
 
 	return validator
 }
 
+// readImgKeySample 读取 V4Format2 文件的第一个加密块，只有这种格式使用图片密钥加密
+func readImgKeySample(path string) ([]byte, bool) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, false
+	}
+	defer f.Close()
+	data := make([]byte, imgKeySampleOffset+aes.BlockSize)
+	if _, err := io.ReadFull(f, data); err != nil {
+		return nil, false
+	}
+	if !bytes.Equal(data[:4], V4Format2.Header) {
+		return nil, false
+	}
+	return data[imgKeySampleOffset:], true
+}
+
+// Validate 返回 key 的前16字节能否将所有样本解密为已知的图片格式，没有样本时返回 false
 func (v *AesKeyValidator) Validate(key []byte) bool {
-	if len(key) < 16 {
+	if len(key) < 16 || len(v.Samples) == 0 {
 		return false
 	}
 	aesKey := key[:16]
@@ -65,8 +107,21 @@ func (v *AesKeyValidator) Validate(key []byte) bool {
 		return false
 	}
 
-	decrypted := make([]byte, len(v.EncryptedData))
-	cipher.Decrypt(decrypted, v.EncryptedData)
+	decrypted := make([]byte, aes.BlockSize)
+	for _, sample := range v.Samples {
+		cipher.Decrypt(decrypted, sample)
+		if !isImageHeader(decrypted) {
+			return false
+		}
+	}
+	return true
+}
 
-	return bytes.HasPrefix(decrypted, JPG.Header) || bytes.HasPrefix(decrypted, WXGF.Header)
+func isImageHeader(b []byte) bool {
+	for _, format := range imageFormats {
+		if bytes.HasPrefix(b, format.Header) {
+			return true
+		}
+	}
+	return false
 }
@@ -0,0 +1,53 @@
+package dat2img
+
+import (
+	"crypto/aes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestImgKeyValidator(t *testing.T) {
+	key := []byte("0123456789abcdef")
+	block, _ := aes.NewCipher(key)
+	dir := t.TempDir()
+	write := func(name string, plain []byte) {
+		data := make([]byte, imgKeySampleOffset+aes.BlockSize+16)
+		copy(data, V4Format2.Header)
+		first := make([]byte, aes.BlockSize)
+		copy(first, plain)
+		block.Encrypt(data[imgKeySampleOffset:], first)
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("a/1.dat", JPG.Header)
+	write("a/2.dat", PNG.Header) // 同一目录只取一个
+	write("b/1.dat", GIF.Header)
+	write("b/1_t.dat", []byte("thumb"))
+	write("c/1.dat", PNG.Header)
+
+	v := NewImgKeyValidator(dir)
+	if len(v.Samples) != 3 {
+		t.Fatalf("got %d samples, want 3", len(v.Samples))
+	}
+	if !v.Validate(key) {
+		t.Error("valid key rejected")
+	}
+	if v.Validate([]byte("fedcba9876543210")) {
+		t.Error("invalid key accepted")
+	}
+
+	// 有一个样本解密后不是图片时拒绝
+	write("d/1.dat", []byte("not an image"))
+	if v := NewImgKeyValidatorWithSamples(dir, 10); len(v.Samples) != 4 || v.Validate(key) {
+		t.Errorf("%d samples, key accepted with a non-image sample", len(v.Samples))
+	}
+	if NewImgKeyValidator(t.TempDir()).Validate(key) {
+		t.Error("key accepted without samples")
+	}
+}
@@ -0,0 +1,82 @@
+package silk
+
+import (
+	"encoding/binary"
+	"fmt"
+	"slices"
+)
+
+// Options mp3 编码参数
+type Options struct {
+	// SampleRate 输出采样率（Hz），为 0 时与 silk 数据的采样率相同
+	SampleRate int
+	// Bitrate 比特率（kbps），VBR 为 true 时为平均比特率
+	Bitrate int
+	// Channels 声道数，1 或 2，微信语音为单声道，2 时复制为立体声
+	Channels int
+	// VBR 使用平均比特率（ABR）编码
+	VBR bool
+}
+
+// DefaultOptions Silk2MP3 使用的编码参数，单声道 16kbps，采样率与语音相同
+var DefaultOptions = Options{Bitrate: 16, Channels: 1}
+
+// mp3SampleRates mp3 支持的采样率
+var mp3SampleRates = []int{8000, 11025, 12000, 16000, 22050, 24000, 32000, 44100, 48000}
+
+// WithDefaults 返回未设置的参数使用 DefaultOptions 填充后的参数
+func (o Options) WithDefaults() Options {
+	if o.Bitrate == 0 {
+		o.Bitrate = DefaultOptions.Bitrate
+	}
+	if o.Channels == 0 {
+		o.Channels = DefaultOptions.Channels
+	}
+	return o
+}
+
+// Validate 检查参数是否为 mp3 支持的取值，为 0 的参数视为使用默认值
+func (o Options) Validate() error {
+	if o.SampleRate != 0 && !slices.Contains(mp3SampleRates, o.SampleRate) {
+		return fmt.Errorf("unsupported mp3 sample rate %d, expected one of %v", o.SampleRate, mp3SampleRates)
+	}
+	if o.Bitrate != 0 && (o.Bitrate < 8 || o.Bitrate > 320) {
+		return fmt.Errorf("unsupported mp3 bitrate %d, expected 8-320 kbps", o.Bitrate)
+	}
+	if o.Channels != 0 && o.Channels != 1 && o.Channels != 2 {
+		return fmt.Errorf("unsupported channels %d, expected 1 or 2", o.Channels)
+	}
+	return nil
+}
+
+// firstFrame 返回 silk 数据中第一个非空的帧，文件头后每帧以 2 字节小端长度开始
+func firstFrame(data []byte) []byte {
+	if !IsSilk(data) {
+		return nil
+	}
+	if data[0] == 0x02 {
+		data = data[1:]
+	}
+	data = data[len(silkHeader):]
+	for len(data) >= 2 {
+		n := int(int16(binary.LittleEndian.Uint16(data)))
+		data = data[2:]
+		if n <= 0 {
+			continue
+		}
+		if n > len(data) {
+			return nil
+		}
+		return data[:n]
+	}
+	return nil
+}
+
+// stereo 将单声道16位 PCM 数据复制为交错的双声道数据
+func stereo(pcm []byte) []byte {
+	out := make([]byte, 0, len(pcm)*2)
+	for i := 0; i+1 < len(pcm); i += 2 {
+		out = append(out, pcm[i], pcm[i+1], pcm[i], pcm[i+1])
+	}
+	return out
+}
@@ -0,0 +1,132 @@
+//go:build cgo && !nosilk
+
+package silk
+
+/*
+typedef struct {
+	int framesInPacket;
+	int fs_kHz;
+	int inbandLBRR;
+	int corrupt;
+	int vadFlags[5];
+	int sigtypeFlags[5];
+} SKP_Silk_TOC_struct;
+
+void SKP_Silk_SDK_get_TOC(const unsigned char *inData, const int nBytesIn, SKP_Silk_TOC_struct *Silk_TOC);
+*/
+import "C"
+
+import (
+	"fmt"
+	"unsafe"
+
+	"github.com/aspnmy/go-lame-v1"
+	"github.com/aspnmy/go-silk"
+)
+
+// Supported 当前构建是否可以解码 silk，需要 cgo，使用 nosilk 构建标签编译时不可用
+const Supported = true
+
+// DetectSampleRate 从 silk 数据第一帧的 TOC 中读取编码采样率（Hz），无法识别时返回 0
+// 微信语音通常为 24000，部分旧版本或其他客户端的语音为 8000、12000 或 16000
+func DetectSampleRate(data []byte) int {
+	frame := firstFrame(data)
+	if len(frame) == 0 {
+		return 0
+	}
+	// SKP_Silk_SDK_get_TOC 由 go-silk 中的 silk SDK 提供
+	var toc C.SKP_Silk_TOC_struct
+	C.SKP_Silk_SDK_get_TOC((*C.uchar)(unsafe.Pointer(&frame[0])), C.int(len(frame)), &toc)
+	if toc.corrupt != 0 {
+		return 0
+	}
+	switch rate := int(toc.fs_kHz) * 1000; rate {
+	case 8000, 12000, 16000, 24000:
+		return rate
+	}
+	return 0
+}
+
+// Silk2PCM 将silk格式解码为 SampleRate 采样率、单声道、16位小端的 PCM 数据
+func Silk2PCM(data []byte) ([]byte, error) {
+	return silk2PCM(data, SampleRate)
+}
+
+func silk2PCM(data []byte, sampleRate int) ([]byte, error) {
+	if !IsSilk(data) {
+		return nil, fmt.Errorf("not silk data")
+	}
+	sd := silk.SilkInit()
+	defer sd.Close()
+	sd.SetSampleRate(sampleRate)
+
+	pcmdata := sd.Decode(data)
+	if len(pcmdata) == 0 {
+		return nil, fmt.Errorf("silk decode failed")
+	}
+	return pcmdata, nil
+}
+
+// Silk2MP3 将silk格式转换为mp3格式
+// 参数：
+//
+//	data: silk格式的音频数据
+//
+// 返回：
+//
+//	[]byte: mp3格式的音频数据
+//	error: 错误信息
+func Silk2MP3(data []byte) ([]byte, error) {
+	return Silk2MP3WithOptions(data, DefaultOptions)
+}
+
+// Silk2MP3WithOptions 按 opts 将silk格式转换为mp3格式，未设置的参数使用 DefaultOptions
+// 解码采样率由 DetectSampleRate 识别，识别失败时使用 SampleRate
+func Silk2MP3WithOptions(data []byte, opts Options) ([]byte, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+	opts = opts.WithDefaults()
+
+	inRate := DetectSampleRate(data)
+	if inRate == 0 {
+		inRate = SampleRate
+	}
+	outRate := opts.SampleRate
+	if outRate == 0 {
+		outRate = inRate
+	}
+
+	pcmdata, err := silk2PCM(data, inRate)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Channels == 2 {
+		pcmdata = stereo(pcmdata)
+	}
+
+	le := lame.Init()
+	defer le.Close()
+
+	le.SetInSamplerate(inRate)
+	le.SetOutSamplerate(outRate)
+	le.SetNumChannels(opts.Channels)
+	if opts.VBR {
+		le.SetVBR(lame.VBR_ABR)
+		le.SetVBRAverageBitRate(opts.Bitrate)
+	} else {
+		le.SetBitrate(opts.Bitrate)
+	}
+	// IMPORTANT!
+	if le.InitParams() < 0 {
+		return nil, fmt.Errorf("invalid mp3 encoding options")
+	}
+
+	mp3data := le.Encode(pcmdata)
+	mp3data = append(mp3data, le.Flush()...)
+	if len(mp3data) == 0 {
+		return nil, fmt.Errorf("mp3 encode failed")
+	}
+
+	return mp3data, nil
+}
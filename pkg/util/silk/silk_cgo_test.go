@@ -0,0 +1,34 @@
+//go:build cgo && !nosilk
+
+package silk
+
+import (
+	"os"
+	"testing"
+)
+
+func TestSilk2MP3WithOptions(t *testing.T) {
+	// 16kHz 采样率编码的 0.5 秒正弦波
+	data, err := os.ReadFile("testdata/sine_16k.silk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := DetectSampleRate(data); got != 16000 {
+		t.Fatalf("DetectSampleRate = %d, want 16000", got)
+	}
+
+	small, err := Silk2MP3(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	large, err := Silk2MP3WithOptions(data, Options{SampleRate: 44100, Bitrate: 128, Channels: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(large) <= len(small) {
+		t.Errorf("128kbps stereo mp3 (%d bytes) not larger than default (%d bytes)", len(large), len(small))
+	}
+	if _, err := Silk2MP3WithOptions(data, Options{SampleRate: 1000}); err == nil {
+		t.Error("invalid sample rate accepted")
+	}
+}
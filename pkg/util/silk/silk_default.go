@@ -1,11 +1,22 @@
-//go:build !windows
+//go:build !cgo || nosilk
 
 package silk
 
 import (
-	"fmt"
+	"errors"
 )
 
+// Supported 当前构建是否可以解码 silk，需要 cgo，使用 nosilk 构建标签编译时不可用
+const Supported = false
+
+// ErrUnsupported 当前构建不支持解码 silk
+var ErrUnsupported = errors.New("silk decoding requires a cgo build without the nosilk tag")
+
+// Silk2PCM 将silk格式解码为 PCM 数据
+func Silk2PCM(data []byte) ([]byte, error) {
+	return nil, ErrUnsupported
+}
+
 // Silk2MP3 将silk格式转换为mp3格式
 // 参数：
 //
@@ -16,6 +27,15 @@ import (
 //	[]byte: mp3格式的音频数据
 //	error: 错误信息
 func Silk2MP3(data []byte) ([]byte, error) {
-	// 默认实现，不支持任何平台
-	return nil, fmt.Errorf("silk2mp3 not supported on this platform")
+	return nil, ErrUnsupported
+}
+
+// Silk2MP3WithOptions 按 opts 将silk格式转换为mp3格式
+func Silk2MP3WithOptions(data []byte, opts Options) ([]byte, error) {
+	return nil, ErrUnsupported
+}
+
+// DetectSampleRate 从 silk 数据第一帧中读取编码采样率，当前构建不支持时返回 0
+func DetectSampleRate(data []byte) int {
+	return 0
 }
@@ -0,0 +1,21 @@
+package silk
+
+import "testing"
+
+func TestIsSilk(t *testing.T) {
+	for data, want := range map[string]bool{
+		"\x02#!SILK_V3\x00\x01": true,
+		"#!SILK_V3":             true,
+		"#!AMR\n":               false,
+		"\x02":                  false,
+		"":                      false,
+	} {
+		if got := IsSilk([]byte(data)); got != want {
+			t.Errorf("IsSilk(%q) = %v", data, got)
+		}
+	}
+	// 文件头不完整的数据不应传给解码器
+	if _, err := Silk2MP3([]byte{0x02, '#'}); err == nil {
+		t.Error("truncated data accepted")
+	}
+}
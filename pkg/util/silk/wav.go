@@ -0,0 +1,52 @@
+package silk
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+// SampleRate 微信语音解码后的 PCM 采样率
+const SampleRate = 24000
+
+// silkHeader silk 文件头，微信的语音在文件头前多一个 0x02 字节
+const silkHeader = "#!SILK_V3"
+
+// IsSilk 返回数据是否以 silk 文件头开始
+func IsSilk(data []byte) bool {
+	if len(data) > 0 && data[0] == 0x02 {
+		data = data[1:]
+	}
+	return bytes.HasPrefix(data, []byte(silkHeader))
+}
+
+// Silk2WAV 将silk格式转换为 wav 格式，只在 Supported 为 true 时可用
+func Silk2WAV(data []byte) ([]byte, error) {
+	pcm, err := Silk2PCM(data)
+	if err != nil {
+		return nil, err
+	}
+	return PCM2WAV(pcm, SampleRate, 1), nil
+}
+
+// PCM2WAV 为16位小端的 PCM 数据加上 wav 文件头
+func PCM2WAV(pcm []byte, sampleRate int, channels int) []byte {
+	const bitsPerSample = 16
+	blockAlign := channels * bitsPerSample / 8
+
+	var buf bytes.Buffer
+	buf.Grow(44 + len(pcm))
+	buf.WriteString("RIFF")
+	binary.Write(&buf, binary.LittleEndian, uint32(36+len(pcm)))
+	buf.WriteString("WAVEfmt ")
+	binary.Write(&buf, binary.LittleEndian, uint32(16))
+	binary.Write(&buf, binary.LittleEndian, uint16(1)) // PCM
+	binary.Write(&buf, binary.LittleEndian, uint16(channels))
+	binary.Write(&buf, binary.LittleEndian, uint32(sampleRate))
+	binary.Write(&buf, binary.LittleEndian, uint32(sampleRate*blockAlign))
+	binary.Write(&buf, binary.LittleEndian, uint16(blockAlign))
+	binary.Write(&buf, binary.LittleEndian, uint16(bitsPerSample))
+	buf.WriteString("data")
+	binary.Write(&buf, binary.LittleEndian, uint32(len(pcm)))
+	buf.Write(pcm)
+	return buf.Bytes()
+}
@@ -0,0 +1,22 @@
+package silk
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+func TestPCM2WAV(t *testing.T) {
+	pcm := []byte{1, 2, 3, 4, 5, 6}
+	wav := PCM2WAV(pcm, SampleRate, 1)
+	if len(wav) != 44+len(pcm) || string(wav[:4]) != "RIFF" || string(wav[8:16]) != "WAVEfmt " || string(wav[36:40]) != "data" {
+		t.Fatalf("invalid header: %q", wav[:44])
+	}
+	le := binary.LittleEndian
+	if le.Uint32(wav[4:]) != uint32(36+len(pcm)) || le.Uint32(wav[24:]) != SampleRate || le.Uint32(wav[28:]) != SampleRate*2 || le.Uint32(wav[40:]) != uint32(len(pcm)) {
+		t.Errorf("invalid sizes: %v", wav[:44])
+	}
+	if !bytes.Equal(wav[44:], pcm) {
+		t.Error("pcm data changed")
+	}
+}
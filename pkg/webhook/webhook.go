@@ -0,0 +1,118 @@
+// Package webhook 将数据按模板渲染为请求体并 POST 到 Webhook 地址，如 Slack、钉钉、飞书机器人
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"text/template"
+	"time"
+)
+
+// maxRetries 被限流或服务端出错时的最大重试次数
+const maxRetries = 3
+
+// Funcs 请求体模板中可用的函数
+//   - json: 编码为 JSON，用于在 JSON 请求体中嵌入文本，如 {"text": {{json .Text}}}
+var Funcs = template.FuncMap{
+	"json": func(v any) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+}
+
+// Hook 一个 Webhook 地址
+type Hook struct {
+	url     string
+	headers map[string]string
+	body    *template.Template
+	http    *http.Client
+}
+
+// New 创建 Webhook，body 为 text/template 格式的请求体模板，为空时将数据编码为 JSON 发送
+// 请求默认带有 Content-Type: application/json，可以通过 headers 覆盖
+func New(rawURL string, headers map[string]string, body string) (*Hook, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid webhook url %q", rawURL)
+	}
+	h := &Hook{
+		url:     rawURL,
+		headers: headers,
+		http:    &http.Client{Timeout: 30 * time.Second},
+	}
+	if body != "" {
+		if h.body, err = template.New("body").Funcs(Funcs).Parse(body); err != nil {
+			return nil, fmt.Errorf("invalid webhook body: %w", err)
+		}
+	}
+	return h, nil
+}
+
+// Render 返回 data 渲染后的请求体
+func (h *Hook) Render(data any) ([]byte, error) {
+	if h.body == nil {
+		return json.Marshal(data)
+	}
+	var buf bytes.Buffer
+	if err := h.body.Execute(&buf, data); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Post 渲染请求体并发送，被限流（429）或服务端出错时按 Retry-After 重试，非 2xx 响应返回包含响应内容的错误
+func (h *Hook) Post(ctx context.Context, data any) error {
+	body, err := h.Render(data)
+	if err != nil {
+		return err
+	}
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.url, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json; charset=utf-8")
+		for k, v := range h.headers {
+			req.Header.Set(k, v)
+		}
+		resp, err := h.http.Do(req)
+		if err != nil {
+			return err
+		}
+		b, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		resp.Body.Close()
+
+		retry := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+		if retry && attempt < maxRetries {
+			wait := time.Duration(attempt+1) * time.Second
+			if s, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && s >= 0 {
+				wait = time.Duration(s) * time.Second
+			}
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(wait):
+			}
+			continue
+		}
+		if resp.StatusCode < 200 || resp.StatusCode > 299 {
+			return fmt.Errorf("POST %s: %s: %s", h, resp.Status, bytes.TrimSpace(b))
+		}
+		return nil
+	}
+}
+
+// String 返回用于日志展示的地址，不包含路径与参数中可能带有的令牌
+func (h *Hook) String() string {
+	u, err := url.Parse(h.url)
+	if err != nil {
+		return "webhook"
+	}
+	return u.Scheme + "://" + u.Host
+}
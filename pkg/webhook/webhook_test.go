@@ -0,0 +1,36 @@
+package webhook
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestPost(t *testing.T) {
+	var got, token string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		got, token = string(b), r.Header.Get("X-Token")
+	}))
+	defer srv.Close()
+
+	h, err := New(srv.URL+"/hook", map[string]string{"X-Token": "t"}, `{"text": {{json .Text}}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Post(context.Background(), map[string]string{"Text": "a \"b\"\nc"}); err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"text": "a \"b\"\nc"}`; got != want || token != "t" {
+		t.Errorf("body = %s, header = %q, want %s", got, token, want)
+	}
+
+	if _, err := New("ftp://example.com", nil, ""); err == nil {
+		t.Error("invalid url accepted")
+	}
+	if _, err := New(srv.URL, nil, "{{"); err == nil {
+		t.Error("invalid template accepted")
+	}
+}
@@ -0,0 +1,198 @@
+// Package zipaes 写入 WinZip AES-256 (AE-2) 加密的 zip 文件
+// 生成的文件可以被 7-Zip、WinRAR、macOS 归档实用工具等常见解压软件直接打开
+package zipaes
+
+import (
+	"archive/zip"
+	"compress/flate"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"time"
+
+	"golang.org/x/crypto/pbkdf2"
+
+	"github.com/aspnmy/chatlog/pkg/membudget"
+)
+
+const (
+	// methodAES WinZip AES 加密使用的压缩方法编号
+	methodAES = 99
+
+	// extraAES WinZip AES 扩展字段 ID
+	extraAES = 0x9901
+
+	keySize    = 32 // AES-256
+	saltSize   = 16
+	verifySize = 2
+	macSize    = 10
+	iterations = 1000
+)
+
+// Writer 加密 zip 写入器，所有条目使用同一个密码
+type Writer struct {
+	zw       *zip.Writer
+	password []byte
+}
+
+func NewWriter(w io.Writer, password string) *Writer {
+	return &Writer{
+		zw:       zip.NewWriter(w),
+		password: []byte(password),
+	}
+}
+
+// Create 创建一个加密条目，内容在条目关闭时压缩、加密并写入
+// 同一时间只能有一个未关闭的条目
+func (w *Writer) Create(name string, modified time.Time) (io.WriteCloser, error) {
+	if len(w.password) == 0 {
+		return nil, fmt.Errorf("password is empty")
+	}
+	return &entry{w: w, name: name, modified: modified}, nil
+}
+
+func (w *Writer) Close() error {
+	return w.zw.Close()
+}
+
+type entry struct {
+	w        *Writer
+	name     string
+	modified time.Time
+	buf      *membudget.Buffer
+}
+
+func (e *entry) Write(p []byte) (int, error) {
+	if e.buf == nil {
+		e.buf = membudget.NewBuffer(nil)
+	}
+	return e.buf.Write(p)
+}
+
+func (e *entry) Close() error {
+	if e.buf == nil {
+		e.buf = membudget.NewBuffer(nil)
+	}
+	defer e.buf.Close()
+
+	// 明文与压缩后的数据都可能很大，预算不足时溢出到临时文件
+	compressed := membudget.NewBuffer(nil)
+	defer compressed.Close()
+	fw, err := flate.NewWriter(compressed, flate.DefaultCompression)
+	if err != nil {
+		return err
+	}
+	plain, err := e.buf.Reader()
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(fw, plain); err != nil {
+		return err
+	}
+	if err := fw.Close(); err != nil {
+		return err
+	}
+
+	fh := &zip.FileHeader{
+		Name:               e.name,
+		Method:             methodAES,
+		Flags:              0x1 | 0x800, // 已加密 | 文件名为 UTF-8
+		CompressedSize64:   uint64(saltSize + verifySize + compressed.Len() + macSize),
+		UncompressedSize64: uint64(e.buf.Len()),
+		// AE-2 不保存 CRC，由 HMAC 校验完整性
+		CRC32: 0,
+		Extra: aesExtra(zip.Deflate),
+	}
+	// CreateRaw 不会根据 Modified 填充 DOS 时间
+	fh.SetModTime(e.modified)
+	w, err := e.w.zw.CreateRaw(fh)
+	if err != nil {
+		return err
+	}
+	r, err := compressed.Reader()
+	if err != nil {
+		return err
+	}
+	return encrypt(w, r, e.w.password)
+}
+
+// aesExtra 生成 AES 扩展字段：版本 AE-2、厂商 "AE"、强度 AES-256 以及实际的压缩方法
+func aesExtra(method uint16) []byte {
+	b := make([]byte, 0, 11)
+	b = binary.LittleEndian.AppendUint16(b, extraAES)
+	b = binary.LittleEndian.AppendUint16(b, 7)
+	b = binary.LittleEndian.AppendUint16(b, 2)
+	b = append(b, 'A', 'E', 3)
+	b = binary.LittleEndian.AppendUint16(b, method)
+	return b
+}
+
+// encrypt 依次写入 salt | 密码校验值 | 密文 | HMAC-SHA1 前 10 字节
+func encrypt(w io.Writer, r io.Reader, password []byte) error {
+	salt := make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return err
+	}
+	dk := pbkdf2.Key(password, salt, iterations, 2*keySize+verifySize, sha1.New)
+	encKey, macKey, verify := dk[:keySize], dk[keySize:2*keySize], dk[2*keySize:]
+
+	block, err := aes.NewCipher(encKey)
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(append(salt, verify...)); err != nil {
+		return err
+	}
+
+	mac := hmac.New(sha1.New, macKey)
+	ctr := &ctrStream{block: block}
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := r.Read(buf)
+		if n > 0 {
+			ctr.xor(buf[:n])
+			mac.Write(buf[:n])
+			if _, err := w.Write(buf[:n]); err != nil {
+				return err
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+	_, err = w.Write(mac.Sum(nil)[:macSize])
+	return err
+}
+
+// ctrStream WinZip 使用从 1 开始的小端计数器，与 cipher.NewCTR 的大端计数器不同
+type ctrStream struct {
+	block   cipher.Block
+	counter [aes.BlockSize]byte
+	stream  [aes.BlockSize]byte
+	used    int
+}
+
+func (c *ctrStream) xor(p []byte) {
+	for i := range p {
+		if c.used == 0 || c.used == aes.BlockSize {
+			for j := range c.counter {
+				c.counter[j]++
+				if c.counter[j] != 0 {
+					break
+				}
+			}
+			c.block.Encrypt(c.stream[:], c.counter[:])
+			c.used = 0
+		}
+		p[i] ^= c.stream[c.used]
+		c.used++
+	}
+}
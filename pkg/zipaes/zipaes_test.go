@@ -0,0 +1,150 @@
+package zipaes
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/flate"
+	"crypto/aes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+func TestRoundTrip(t *testing.T) {
+	// 随机数据压缩后仍超过 32KB，覆盖分块加密时计数器的衔接
+	random := make([]byte, 100*1024)
+	rand.Read(random)
+	files := map[string][]byte{
+		"聊天记录.json":  []byte(`[{"talker":"张三","content":"你好"}]`),
+		"random.bin": random,
+		"empty.txt":  nil,
+	}
+
+	var buf bytes.Buffer
+	w := NewWriter(&buf, "secret")
+	modified := time.Date(2024, 5, 1, 12, 30, 0, 0, time.Local)
+	for _, name := range []string{"聊天记录.json", "random.bin", "empty.txt"} {
+		ew, err := w.Create(name, modified)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := ew.Write(files[name]); err != nil {
+			t.Fatal(err)
+		}
+		if err := ew.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(zr.File) != len(files) {
+		t.Fatalf("entries = %d, want %d", len(zr.File), len(files))
+	}
+	for _, f := range zr.File {
+		if f.Method != methodAES || f.Flags&0x1 == 0 || f.CRC32 != 0 {
+			t.Errorf("%s: method = %d, flags = %#x, crc = %d", f.Name, f.Method, f.Flags, f.CRC32)
+		}
+		if !f.Modified.Equal(modified) {
+			t.Errorf("%s: modified = %s", f.Name, f.Modified)
+		}
+		if !bytes.Equal(f.Extra, aesExtra(zip.Deflate)) {
+			t.Errorf("%s: extra = %x", f.Name, f.Extra)
+		}
+		data, err := decryptEntry(f, "secret")
+		if err != nil {
+			t.Fatalf("%s: %v", f.Name, err)
+		}
+		if !bytes.Equal(data, files[f.Name]) {
+			t.Errorf("%s: content mismatch, %d bytes", f.Name, len(data))
+		}
+		if _, err := decryptEntry(f, "wrong"); err == nil {
+			t.Errorf("%s: wrong password accepted", f.Name)
+		}
+	}
+
+	// 篡改密文后 HMAC 校验失败
+	f := zr.File[0]
+	raw, err := f.OpenRaw()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := io.ReadAll(raw)
+	data[saltSize+verifySize] ^= 0xff
+	if _, err := decrypt(data, "secret"); err == nil {
+		t.Error("tampered entry accepted")
+	}
+
+	if _, err := NewWriter(io.Discard, "").Create("a.txt", modified); err == nil {
+		t.Error("empty password accepted")
+	}
+}
+
+// decryptEntry 按 AE-2 格式解密并解压条目，检查扩展字段、密码校验值与 HMAC
+func decryptEntry(f *zip.File, password string) ([]byte, error) {
+	if len(f.Extra) != 11 || binary.LittleEndian.Uint16(f.Extra) != extraAES ||
+		binary.LittleEndian.Uint16(f.Extra[4:]) != 2 || string(f.Extra[6:8]) != "AE" || f.Extra[8] != 3 {
+		return nil, fmt.Errorf("not an AE-2 AES-256 entry")
+	}
+	raw, err := f.OpenRaw()
+	if err != nil {
+		return nil, err
+	}
+	data, err := io.ReadAll(raw)
+	if err != nil {
+		return nil, err
+	}
+	compressed, err := decrypt(data, password)
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(flate.NewReader(bytes.NewReader(compressed)))
+}
+
+// decrypt 解密 salt | 密码校验值 | 密文 | HMAC，返回压缩后的数据
+func decrypt(data []byte, password string) ([]byte, error) {
+	if len(data) < saltSize+verifySize+macSize {
+		return nil, fmt.Errorf("entry too short")
+	}
+	salt := data[:saltSize]
+	verify := data[saltSize : saltSize+verifySize]
+	body := data[saltSize+verifySize : len(data)-macSize]
+	sum := data[len(data)-macSize:]
+
+	dk := pbkdf2.Key([]byte(password), salt, iterations, 2*keySize+verifySize, sha1.New)
+	if !bytes.Equal(dk[2*keySize:], verify) {
+		return nil, fmt.Errorf("wrong password")
+	}
+	mac := hmac.New(sha1.New, dk[keySize:2*keySize])
+	mac.Write(body)
+	if !hmac.Equal(mac.Sum(nil)[:macSize], sum) {
+		return nil, fmt.Errorf("hmac mismatch")
+	}
+	block, err := aes.NewCipher(dk[:keySize])
+	if err != nil {
+		return nil, err
+	}
+	// 独立于 ctrStream 实现 WinZip 的计数器：小端、从 1 开始
+	plain := make([]byte, len(body))
+	var counter, stream [aes.BlockSize]byte
+	for i := 0; i < len(body); i += aes.BlockSize {
+		binary.LittleEndian.PutUint64(counter[:], uint64(i/aes.BlockSize+1))
+		block.Encrypt(stream[:], counter[:])
+		for j := i; j < min(i+aes.BlockSize, len(body)); j++ {
+			plain[j] = body[j] ^ stream[j-i]
+		}
+	}
+	return plain, nil
+}
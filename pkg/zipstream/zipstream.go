@@ -0,0 +1,179 @@
+// Package zipstream 将目录边压缩边输出为 zip，不生成临时文件
+//
+// 同一目录内容每次生成的字节完全相同（文件按路径排序、头部时间取文件修改时间、
+// 压缩级别固定），因此可以通过重新生成并跳过前 N 个字节实现断点续传
+package zipstream
+
+import (
+	"archive/zip"
+	"compress/flate"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// storeExts 已压缩的格式直接存储，避免重复压缩占用 CPU
+var storeExts = map[string]bool{
+	".jpg": true, ".jpeg": true, ".png": true, ".gif": true, ".webp": true, ".heic": true,
+	".mp4": true, ".mov": true, ".mp3": true, ".m4a": true, ".silk": true, ".amr": true,
+	".zip": true, ".gz": true, ".7z": true, ".rar": true,
+}
+
+// Entry 压缩包中的一个文件
+type Entry struct {
+	Name    string // 压缩包中的路径，使用 / 分隔
+	Path    string
+	Size    int64
+	ModTime time.Time
+}
+
+// Archive 目录的文件列表，创建后目录中的文件不应再修改
+type Archive struct {
+	Entries []Entry
+}
+
+// New 列出 root 下的所有文件
+func New(root string) (*Archive, error) {
+	a := &Archive{}
+	err := filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		a.Entries = append(a.Entries, Entry{
+			Name:    filepath.ToSlash(rel),
+			Path:    path,
+			Size:    info.Size(),
+			ModTime: info.ModTime().UTC().Truncate(time.Second),
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(a.Entries, func(i, j int) bool {
+		return a.Entries[i].Name < a.Entries[j].Name
+	})
+	return a, nil
+}
+
+// ETag 由文件路径、大小与修改时间计算，内容不变时生成的压缩包相同
+func (a *Archive) ETag() string {
+	h := sha1.New()
+	for _, e := range a.Entries {
+		fmt.Fprintf(h, "%s\x00%d\x00%d\n", e.Name, e.Size, e.ModTime.Unix())
+	}
+	return `"` + hex.EncodeToString(h.Sum(nil)[:12]) + `"`
+}
+
+// WriteTo 将压缩包写入 w，返回写入的字节数
+func (a *Archive) WriteTo(w io.Writer) (int64, error) {
+	cw := &countWriter{w: w}
+	zw := zip.NewWriter(cw)
+	zw.RegisterCompressor(zip.Deflate, func(out io.Writer) (io.WriteCloser, error) {
+		return flate.NewWriter(out, flate.DefaultCompression)
+	})
+	for _, e := range a.Entries {
+		if err := writeEntry(zw, e); err != nil {
+			return cw.n, err
+		}
+	}
+	err := zw.Close()
+	return cw.n, err
+}
+
+// Size 生成一次压缩包并丢弃，返回压缩包的大小
+func (a *Archive) Size() (int64, error) {
+	return a.WriteTo(io.Discard)
+}
+
+func writeEntry(zw *zip.Writer, e Entry) error {
+	method := zip.Deflate
+	if storeExts[strings.ToLower(filepath.Ext(e.Name))] {
+		method = zip.Store
+	}
+	w, err := zw.CreateHeader(&zip.FileHeader{
+		Name:     e.Name,
+		Method:   method,
+		Modified: e.ModTime,
+	})
+	if err != nil {
+		return err
+	}
+	f, err := os.Open(e.Path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	// 只写入列出时的大小，文件在下载过程中变长也不影响已计算的大小
+	n, err := io.Copy(w, io.LimitReader(f, e.Size))
+	if err != nil {
+		return err
+	}
+	if n != e.Size {
+		return fmt.Errorf("%s changed during download", e.Name)
+	}
+	return nil
+}
+
+type countWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// RangeWriter 只将 [Offset, Offset+Length) 范围内的字节写入 W，之后的写入返回 ErrRangeDone
+type RangeWriter struct {
+	W      io.Writer
+	Offset int64 // 尚需跳过的字节数
+	Length int64 // 尚需写入的字节数
+}
+
+// ErrRangeDone 请求的范围已全部写入
+var ErrRangeDone = errors.New("range done")
+
+func (r *RangeWriter) Write(p []byte) (int, error) {
+	total := len(p)
+	if r.Offset > 0 {
+		if int64(len(p)) <= r.Offset {
+			r.Offset -= int64(len(p))
+			return total, nil
+		}
+		p = p[r.Offset:]
+		r.Offset = 0
+	}
+	if r.Length <= 0 {
+		return 0, ErrRangeDone
+	}
+	if int64(len(p)) > r.Length {
+		p = p[:r.Length]
+	}
+	n, err := r.W.Write(p)
+	r.Length -= int64(n)
+	if err != nil {
+		return 0, err
+	}
+	return total, nil
+}